@@ -1,29 +1,37 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"net"
 	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
 
 	"agent-server/internal/api"
+	"agent-server/internal/cli"
 	"agent-server/internal/config"
 	contextpkg "agent-server/internal/context"
-	"agent-server/internal/llm"
-	"agent-server/internal/llm/ollama"
-	"agent-server/internal/storage/sqlite"
+	"agent-server/internal/redact"
+	"agent-server/pkg/agentserver"
 
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
+	// Client subcommands talk to a running server instead of starting one
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
 	// Parse command line flags
-	var configPath string
-	flag.StringVar(&configPath, "config", "", "Path to configuration file")
+	flags := config.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
 	// Load configuration
-	cfg, err := config.Load(configPath)
+	cfg, err := flags.Load()
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
@@ -36,17 +44,27 @@ func main() {
 	// Setup logging
 	setupLogging(cfg.Logging)
 
-	logrus.Info("Starting Agent Server...")
+	// Mask credentials in log entries
+	redactor, err := redact.NewFromConfig(cfg.Redaction)
+	if err != nil {
+		logrus.Fatalf("Invalid redaction configuration: %v", err)
+	}
+	if redactor != nil {
+		logrus.AddHook(redact.NewLogrusHook(redactor))
+	}
 
-	// Ensure data directory exists
-	if err := ensureDataDir(cfg.Database.Path); err != nil {
-		logrus.Fatalf("Failed to create data directory: %v", err)
+	logrus.Info("Starting Agent Server...")
+	if flags.Demo {
+		logrus.Warn("Demo mode: data is kept in memory and lost when the server stops")
+	}
+	if profiles := flags.Profiles(); len(profiles) > 0 {
+		logrus.Infof("Using config profiles %s", strings.Join(profiles, ", "))
 	}
 
-	// Initialize storage
-	repo, err := sqlite.NewRepository(cfg.Database.Path)
+	// Initialize storage, encrypting sensitive columns when a key is set
+	repo, err := agentserver.OpenRepository(cfg)
 	if err != nil {
-		logrus.Fatalf("Failed to initialize storage: %v", err)
+		logrus.Fatalf("Failed to open database: %v", err)
 	}
 	defer func() {
 		if err := repo.Close(); err != nil {
@@ -58,19 +76,19 @@ func main() {
 	ctxRegistry := contextpkg.NewStrategyRegistry()
 
 	// Initialize LLM provider registry
-	llmRegistry := llm.NewRegistry()
-
-	// Register Ollama provider
-	if providerCfg, exists := cfg.LLM.Providers["ollama"]; exists {
-		ollamaProvider := ollama.NewProvider(providerCfg.BaseURL)
-		llmRegistry.Register(ollamaProvider)
-		logrus.Info("Registered Ollama LLM provider")
+	llmRegistry, err := agentserver.NewProviderRegistry(cfg)
+	if err != nil {
+		logrus.Fatalf("Failed to register LLM providers: %v", err)
 	}
 
 	// Create and setup server
 	server := api.NewServer(cfg, repo, ctxRegistry, llmRegistry)
 	server.SetupRoutes()
 
+	if flags.Demo {
+		seedDemo(server, cfg)
+	}
+
 	logrus.Infof("Server starting on %s", cfg.GetAddress())
 
 	// Start server
@@ -79,6 +97,37 @@ func main() {
 	}
 }
 
+// seedDemo creates the sample agent and session of demo mode and shows how
+// to chat with them
+func seedDemo(server *api.Server, cfg *config.Config) {
+	agent, session, err := server.Runtime().SeedDemo(context.Background())
+	if err != nil {
+		logrus.Fatalf("Failed to seed demo data: %v", err)
+	}
+	logrus.WithFields(logrus.Fields{
+		"agent_id":   agent.ID,
+		"session_id": session.ID,
+	}).Info("Seeded demo agent and session")
+
+	fmt.Printf(`
+Demo agent %q is ready. Try:
+
+  curl -X POST http://%s/api/v1/sessions/%s/chat/tools \
+    -H "Content-Type: application/json" \
+    -d '{"message": "What is 6 times 7?\n/tool calculator {\"expression\": \"6*7\"}"}'
+
+`, agent.Name, demoAddress(cfg), session.ID)
+}
+
+// demoAddress is where a local client reaches the server
+func demoAddress(cfg *config.Config) string {
+	host := cfg.Server.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(cfg.Server.Port))
+}
+
 // setupLogging configures the logging system
 func setupLogging(cfg config.LoggingConfig) {
 	// Set log level
@@ -103,9 +152,3 @@ func setupLogging(cfg config.LoggingConfig) {
 	// Output to stdout
 	logrus.SetOutput(os.Stdout)
 }
-
-// ensureDataDir creates the data directory if it doesn't exist
-func ensureDataDir(dbPath string) error {
-	dir := filepath.Dir(dbPath)
-	return os.MkdirAll(dir, 0755)
-}
\ No newline at end of file
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/models"
+	"agent-server/internal/redact"
+	"agent-server/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// credentialReference prefixes preset values that name a configured
+// credential rather than holding one
+const credentialReference = "credential://"
+
+// yamlContentTypes are the media types bundles are read and written as YAML
+var yamlContentTypes = map[string]bool{
+	"application/yaml":   true,
+	"application/x-yaml": true,
+	"text/yaml":          true,
+	"text/x-yaml":        true,
+}
+
+// SetRedactor sets what masks credentials in the tool presets of exported
+// bundles
+func (h *AgentHandler) SetRedactor(r *redact.Redactor) {
+	h.redactor = r
+}
+
+// SetStore sets the repository imports write an agent and its seed memories
+// to in one transaction. Bundles with memories cannot be imported without it.
+func (h *AgentHandler) SetStore(store storage.Repository) {
+	h.store = store
+}
+
+// Import installs an agent bundle as a new agent with its seed memories.
+// The bundle is read as YAML when sent with a YAML content type.
+func (h *AgentHandler) Import(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidBody, "Failed to read request body", err.Error())
+		return
+	}
+
+	var bundle models.AgentBundle
+	if err := decodeBundle(body, yamlContentTypes[c.ContentType()], &bundle); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	// Validate the bundle, then the agent it installs
+	if err := h.validator.Struct(&bundle); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+	req := bundle.AgentRequest()
+	if err := h.validator.Struct(req); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	if len(bundle.Memories) > 0 && h.store == nil {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Memory import is not available", "")
+		return
+	}
+
+	agent := req.ToAgent()
+	if !h.validateTools(c, agent) {
+		return
+	}
+
+	imported, err := h.importAgent(c.Request.Context(), agent, &bundle)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to import agent")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to import agent", "")
+		return
+	}
+
+	response := models.AgentImportResponse{
+		Agent:            agent,
+		MemoriesImported: imported,
+	}
+	if h.linter != nil {
+		response.Warnings = h.linter.Lint(c.Request.Context(), agent)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"agent_id":          agent.ID,
+		"bundle_author":     bundle.Metadata.Author,
+		"memories_imported": imported,
+	}).Info("Agent imported successfully")
+	c.JSON(http.StatusCreated, response)
+}
+
+// importAgent creates an imported agent with the bundle's seed memories in
+// one transaction, so that a failed import leaves nothing behind. It returns
+// the number of memories imported.
+func (h *AgentHandler) importAgent(ctx context.Context, agent *models.Agent, bundle *models.AgentBundle) (int, error) {
+	if h.store == nil {
+		return 0, h.repo.Create(ctx, agent)
+	}
+
+	imported := 0
+	err := h.store.WithTx(ctx, func(tx storage.Repository) error {
+		if err := tx.Agent().Create(ctx, agent); err != nil {
+			return fmt.Errorf("failed to create agent: %w", err)
+		}
+		memories := bundle.SeedMemories(agent.ID)
+		for _, memory := range memories {
+			if err := tx.Memory().Create(ctx, memory); err != nil {
+				return fmt.Errorf("failed to create memory: %w", err)
+			}
+		}
+		imported = len(memories)
+		return nil
+	})
+	return imported, err
+}
+
+// Export returns an agent as a bundle other servers can import. Memories
+// are included with include_memories=true; the bundle is written as YAML
+// with format=yaml or a YAML Accept header.
+func (h *AgentHandler) Export(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Agent ID is required", "")
+		return
+	}
+
+	includeMemories := false
+	if raw := c.Query("include_memories"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Invalid include_memories parameter", "include_memories must be true or false")
+			return
+		}
+		includeMemories = parsed
+	}
+
+	asYAML := false
+	switch format := c.Query("format"); format {
+	case "json":
+	case "yaml":
+		asYAML = true
+	case "":
+		asYAML = acceptsYAML(c.GetHeader("Accept"))
+	default:
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Invalid format parameter", "format must be json or yaml")
+		return
+	}
+
+	if includeMemories && h.memories == nil {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Memory export is not available", "")
+		return
+	}
+
+	agent, err := h.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		logrus.WithError(err).WithField("agent_id", id).Error("Failed to get agent for export")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve agent", "")
+		return
+	}
+
+	if agent == nil {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Agent not found", "")
+		return
+	}
+
+	var memories []*models.Memory
+	if includeMemories {
+		memories, err = h.memories.ListByAgent(c.Request.Context(), agent.ID, -1, -1)
+		if err != nil {
+			logrus.WithError(err).WithField("agent_id", id).Error("Failed to list memories for export")
+			problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve agent memories", "")
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	bundle := models.NewAgentBundle(agent, memories, models.AgentBundleMetadata{ExportedAt: &now})
+	for tool, preset := range bundle.Presets {
+		bundle.Presets[tool] = h.sanitizePreset(preset)
+	}
+
+	filename := bundleFilename(agent.Name)
+	if !asYAML {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		c.JSON(http.StatusOK, bundle)
+		return
+	}
+
+	data, err := encodeBundleYAML(bundle)
+	if err != nil {
+		logrus.WithError(err).WithField("agent_id", id).Error("Failed to encode agent bundle")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to export agent", "")
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.yaml"`, filename))
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", data)
+}
+
+// sanitizePreset masks credentials held in a tool preset. References to
+// configured credentials are kept, as they carry no secret and the
+// importing server resolves them.
+func (h *AgentHandler) sanitizePreset(preset models.JSON) models.JSON {
+	sanitized := make(models.JSON, len(preset))
+	for key, value := range preset {
+		if s, ok := value.(string); ok && strings.HasPrefix(s, credentialReference) {
+			sanitized[key] = s
+			continue
+		}
+		sanitized[key] = h.redactor.Map(map[string]interface{}{key: value})[key]
+	}
+	return sanitized
+}
+
+// decodeBundle reads a bundle from JSON or YAML. YAML is converted to JSON
+// first so that both use the bundle's JSON field names.
+func decodeBundle(data []byte, asYAML bool, bundle *models.AgentBundle) error {
+	if asYAML && len(bytes.TrimSpace(data)) > 0 {
+		var document interface{}
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return fmt.Errorf("invalid YAML: %w", err)
+		}
+		converted, err := json.Marshal(document)
+		if err != nil {
+			return fmt.Errorf("invalid YAML: %w", err)
+		}
+		data = converted
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return io.EOF
+	}
+	return json.Unmarshal(data, bundle)
+}
+
+// encodeBundleYAML writes a bundle as block-style YAML, keeping the field
+// order of its JSON form
+func encodeBundleYAML(bundle *models.AgentBundle) ([]byte, error) {
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is YAML, so the document parses with its order intact
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	clearStyle(&node)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// clearStyle drops the flow and quoting styles of parsed JSON so that the
+// encoder picks plain YAML styles
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+// acceptsYAML reports whether an Accept header asks for YAML
+func acceptsYAML(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && yamlContentTypes[mediaType] {
+			return true
+		}
+	}
+	return false
+}
+
+// bundleFilename derives a download file name from an agent name
+func bundleFilename(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+	}
+	if filename := strings.TrimSuffix(b.String(), "-"); filename != "" {
+		return filename
+	}
+	return "agent"
+}
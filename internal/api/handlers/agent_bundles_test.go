@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestAgentHandler_ExportImport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	source := &models.Agent{
+		Name:             "Support Bot",
+		Provider:         "openai",
+		Model:            "gpt-4",
+		SystemPrompt:     "You help {{customer}} with orders",
+		LocalizedPrompts: models.StringMap{"de": "Du hilfst {{customer}} bei Bestellungen"},
+		Temperature:      0.2,
+		MaxTokens:        500,
+		Config: models.JSON{
+			"tools": []interface{}{"issue_tracker", "memory"},
+			"tool_presets": map[string]interface{}{
+				"issue_tracker": map[string]interface{}{
+					"tracker":       "jira",
+					"authorization": "credential://jira",
+					"api_token":     "secret-token",
+				},
+			},
+		},
+		Tags: models.StringList{"support"},
+	}
+	require.NoError(t, repo.Agent().Create(ctx, source))
+	require.NoError(t, repo.Memory().Create(ctx, &models.Memory{
+		AgentID: source.ID, Topic: "policy", Content: "Refunds within 30 days",
+		MemoryType: "fact", Importance: 8, Tags: models.JSON{"tags": []string{"refunds"}},
+	}))
+
+	handler := NewAgentHandler(repo.Agent(), repo.Memory())
+	handler.SetStore(repo)
+	router := gin.New()
+	router.POST("/agents/import", handler.Import)
+	router.GET("/agents/:id/export", handler.Export)
+	do := func(method, path, contentType string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("GET", "/agents/"+source.ID+"/export?include_memories=true", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="support-bot.json"`)
+
+	var bundle models.AgentBundle
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &bundle))
+	assert.Equal(t, models.AgentBundleFormat, bundle.Format)
+	require.NotNil(t, bundle.Prompts)
+	assert.Equal(t, source.SystemPrompt, bundle.Prompts.System)
+	assert.NotContains(t, bundle.Agent.Config, "tool_presets")
+	// Credential references survive; credentials do not
+	assert.Equal(t, "credential://jira", bundle.Presets["issue_tracker"]["authorization"])
+	assert.Equal(t, "REDACTED", bundle.Presets["issue_tracker"]["api_token"])
+	require.Len(t, bundle.Memories, 1)
+	assert.Equal(t, []string{"refunds"}, bundle.Memories[0].Tags)
+
+	// YAML exports import as YAML
+	w = do("GET", "/agents/"+source.ID+"/export?format=yaml&include_memories=true", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.True(t, strings.HasPrefix(w.Body.String(), "format: agent-server/agent-bundle\n"), w.Body.String())
+	var document map[string]interface{}
+	require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &document))
+
+	w = do("POST", "/agents/import", "application/yaml", w.Body.Bytes())
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var imported models.AgentImportResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &imported))
+	assert.Equal(t, 1, imported.MemoriesImported)
+	assert.NotEqual(t, source.ID, imported.Agent.ID)
+
+	agent, err := repo.Agent().GetByID(ctx, imported.Agent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, source.SystemPrompt, agent.SystemPrompt)
+	assert.Equal(t, source.LocalizedPrompts, agent.LocalizedPrompts)
+	assert.Equal(t, "credential://jira", agent.ToolPresets("issue_tracker")["authorization"])
+	assert.Equal(t, []interface{}{"issue_tracker", "memory"}, agent.Config["tools"])
+
+	memories, err := repo.Memory().ListByAgent(ctx, agent.ID, -1, -1)
+	require.NoError(t, err)
+	require.Len(t, memories, 1)
+	assert.Equal(t, "Refunds within 30 days", memories[0].Content)
+	assert.Equal(t, 8, memories[0].Importance)
+	assert.Nil(t, memories[0].SessionID)
+}
+
+// failingMemoryRepository fails to create memories in its transactions
+type failingMemoryRepository struct {
+	storage.Repository
+}
+
+func (r failingMemoryRepository) Memory() storage.MemoryRepository {
+	return failingMemories{r.Repository.Memory()}
+}
+
+func (r failingMemoryRepository) WithTx(ctx context.Context, fn func(tx storage.Repository) error) error {
+	return r.Repository.WithTx(ctx, func(tx storage.Repository) error {
+		return fn(failingMemoryRepository{tx})
+	})
+}
+
+type failingMemories struct {
+	storage.MemoryRepository
+}
+
+func (failingMemories) Create(context.Context, *models.Memory) error {
+	return errors.New("disk full")
+}
+
+func TestAgentHandler_ImportIsAtomic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	body := `{"format": "agent-server/agent-bundle", "version": 1,
+		"agent": {"name": "Paused Bot", "provider": "mock", "model": "m", "enabled": false},
+		"prompts": {"system": "Hi"},
+		"memories": [{"topic": "policy", "content": "Refunds within 30 days"}]}`
+	importBundle := func(store storage.Repository) *httptest.ResponseRecorder {
+		handler := NewAgentHandler(repo.Agent(), repo.Memory())
+		handler.SetStore(store)
+		req := httptest.NewRequest("POST", "/agents/import", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+		handler.Import(c)
+		return w
+	}
+
+	// A failed memory leaves no agent behind
+	w := importBundle(failingMemoryRepository{repo})
+	require.Equal(t, http.StatusInternalServerError, w.Code, w.Body.String())
+	agents, total, err := repo.Agent().List(ctx, &models.AgentFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	assert.Empty(t, agents)
+
+	// Disabled agents are imported disabled
+	w = importBundle(repo)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var imported models.AgentImportResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &imported))
+	assert.Equal(t, 1, imported.MemoriesImported)
+	agent, err := repo.Agent().GetByID(ctx, imported.Agent.ID)
+	require.NoError(t, err)
+	assert.False(t, agent.Enabled)
+}
+
+func TestAgentHandler_ImportRejectsInvalidBundles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "empty body"},
+		{name: "unknown format", body: `{"format": "other", "version": 1, "agent": {"name": "Bot", "provider": "mock", "model": "m", "system_prompt": "Hi"}}`},
+		{name: "newer version", body: `{"format": "agent-server/agent-bundle", "version": 2, "agent": {"name": "Bot", "provider": "mock", "model": "m", "system_prompt": "Hi"}}`},
+		{name: "missing prompt", body: `{"format": "agent-server/agent-bundle", "version": 1, "agent": {"name": "Bot", "provider": "mock", "model": "m"}}`},
+		{name: "invalid memory", body: `{"format": "agent-server/agent-bundle", "version": 1, "agent": {"name": "Bot", "provider": "mock", "model": "m"}, "prompts": {"system": "Hi"}, "memories": [{"topic": "t"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockAgentRepository)
+			handler := NewAgentHandler(mockRepo, nil)
+
+			req := httptest.NewRequest("POST", "/agents/import", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+
+			handler.Import(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AgentStatusHandler handles agent health requests
+type AgentStatusHandler struct {
+	statusService *services.AgentStatusService
+}
+
+// NewAgentStatusHandler creates a new agent status handler
+func NewAgentStatusHandler(statusService *services.AgentStatusService) *AgentStatusHandler {
+	return &AgentStatusHandler{
+		statusService: statusService,
+	}
+}
+
+// Get reports provider, model and tool availability together with recent
+// chat activity for an agent
+func (h *AgentStatusHandler) Get(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Agent ID is required", "")
+		return
+	}
+
+	status, err := h.statusService.GetStatus(c.Request.Context(), id)
+	if err != nil {
+		logrus.WithError(err).WithField("agent_id", id).Error("Failed to get agent status")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve agent status", err.Error())
+		return
+	}
+
+	if status == nil {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Agent not found", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 
+	"agent-server/internal/api/problem"
 	"agent-server/internal/models"
+	"agent-server/internal/redact"
 	"agent-server/internal/storage"
 
 	"github.com/gin-gonic/gin"
@@ -12,68 +15,152 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// AgentToolValidator checks the tools an agent enables before it is saved
+type AgentToolValidator interface {
+	ValidateTools(ctx context.Context, agent *models.Agent) []models.ValidationError
+}
+
+// AgentContextReporter reports the context window of an agent's model and
+// how much of it recent prompts used
+type AgentContextReporter interface {
+	ContextWindowReport(ctx context.Context, agent *models.Agent) *models.ContextWindowReport
+}
+
+// AgentLinter finds issues in an agent's prompt and tools that do not stop
+// it from being saved
+type AgentLinter interface {
+	Lint(ctx context.Context, agent *models.Agent) []models.LintWarning
+}
+
 // AgentHandler handles agent-related requests
 type AgentHandler struct {
 	repo      storage.AgentRepository
+	memories  storage.MemoryRepository
 	validator *validator.Validate
+	tools     AgentToolValidator
+	contexts  AgentContextReporter
+	linter    AgentLinter
+	redactor  *redact.Redactor
+	store     storage.Repository
 }
 
-// NewAgentHandler creates a new agent handler
-func NewAgentHandler(repo storage.AgentRepository) *AgentHandler {
+// NewAgentHandler creates a new agent handler. memories may be nil, in which
+// case clones cannot copy memories.
+func NewAgentHandler(repo storage.AgentRepository, memories storage.MemoryRepository) *AgentHandler {
 	return &AgentHandler{
 		repo:      repo,
-		validator: validator.New(),
+		memories:  memories,
+		validator: newValidator(),
+		redactor:  redact.Default(),
+	}
+}
+
+// SetToolValidator rejects agents whose tools cannot be used with their
+// provider when they are created or updated
+func (h *AgentHandler) SetToolValidator(tools AgentToolValidator) {
+	h.tools = tools
+}
+
+// SetContextReporter adds the context window report to agents fetched by ID
+func (h *AgentHandler) SetContextReporter(contexts AgentContextReporter) {
+	h.contexts = contexts
+}
+
+// SetLinter adds lint warnings to created and updated agents and enables
+// the lint endpoints
+func (h *AgentHandler) SetLinter(linter AgentLinter) {
+	h.linter = linter
+}
+
+// respondWithWarnings writes the saved agent, with its lint warnings when a
+// linter is set
+func (h *AgentHandler) respondWithWarnings(c *gin.Context, status int, agent *models.Agent) {
+	if h.linter == nil {
+		c.JSON(status, agent)
+		return
+	}
+	c.JSON(status, models.AgentWithWarnings{
+		Agent:    agent,
+		Warnings: h.linter.Lint(c.Request.Context(), agent),
+	})
+}
+
+// validateTools writes a validation problem and reports false when the
+// agent's tools cannot be used
+func (h *AgentHandler) validateTools(c *gin.Context, agent *models.Agent) bool {
+	if h.tools == nil {
+		return true
+	}
+	errs := h.tools.ValidateTools(c.Request.Context(), agent)
+	if len(errs) == 0 {
+		return true
+	}
+	fields := make([]problem.FieldError, len(errs))
+	for i, e := range errs {
+		fields[i] = problem.FieldError{Name: e.Parameter, Reason: e.Message}
 	}
+	respondError(c, http.StatusBadRequest, problem.ValidationFailed, "Agent tools cannot be used", fields...)
+	return false
 }
 
 // Create creates a new agent
 func (h *AgentHandler) Create(c *gin.Context) {
 	var req models.CreateAgentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	// Validate request
 	if err := h.validator.Struct(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		respondValidationError(c, err)
 		return
 	}
 
 	// Convert to agent model
 	agent := req.ToAgent()
+	if !h.validateTools(c, agent) {
+		return
+	}
 
 	// Save to database
 	if err := h.repo.Create(c.Request.Context(), agent); err != nil {
 		logrus.WithError(err).Error("Failed to create agent")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create agent"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to create agent", "")
 		return
 	}
 
 	logrus.WithField("agent_id", agent.ID).Info("Agent created successfully")
-	c.JSON(http.StatusCreated, agent)
+	h.respondWithWarnings(c, http.StatusCreated, agent)
 }
 
 // GetByID retrieves an agent by ID
 func (h *AgentHandler) GetByID(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Agent ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Agent ID is required", "")
 		return
 	}
 
 	agent, err := h.repo.GetByID(c.Request.Context(), id)
 	if err != nil {
 		logrus.WithError(err).WithField("agent_id", id).Error("Failed to get agent")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve agent"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve agent", "")
 		return
 	}
 
 	if agent == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Agent not found", "")
 		return
 	}
 
+	if h.contexts != nil {
+		c.JSON(http.StatusOK, models.AgentWithContext{
+			Agent:         agent,
+			ContextWindow: h.contexts.ContextWindowReport(c.Request.Context(), agent),
+		})
+		return
+	}
 	c.JSON(http.StatusOK, agent)
 }
 
@@ -81,19 +168,19 @@ func (h *AgentHandler) GetByID(c *gin.Context) {
 func (h *AgentHandler) Update(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Agent ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Agent ID is required", "")
 		return
 	}
 
 	var req models.UpdateAgentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	// Validate request
 	if err := h.validator.Struct(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		respondValidationError(c, err)
 		return
 	}
 
@@ -101,34 +188,81 @@ func (h *AgentHandler) Update(c *gin.Context) {
 	agent, err := h.repo.GetByID(c.Request.Context(), id)
 	if err != nil {
 		logrus.WithError(err).WithField("agent_id", id).Error("Failed to get agent for update")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve agent"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve agent", "")
 		return
 	}
 
 	if agent == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Agent not found", "")
 		return
 	}
 
 	// Update agent fields
 	agent.UpdateFromRequest(&req)
 
+	// Tools only need checking when the tool list or provider changes, so an
+	// agent whose tool went away can still be edited
+	if req.Config != nil || req.Provider != nil {
+		if !h.validateTools(c, agent) {
+			return
+		}
+	}
+
 	// Save updated agent
 	if err := h.repo.Update(c.Request.Context(), agent); err != nil {
 		logrus.WithError(err).WithField("agent_id", id).Error("Failed to update agent")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update agent"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to update agent", "")
 		return
 	}
 
 	logrus.WithField("agent_id", id).Info("Agent updated successfully")
-	c.JSON(http.StatusOK, agent)
+	h.respondWithWarnings(c, http.StatusOK, agent)
+}
+
+// Lint lists the warnings for a saved agent's prompt and tools
+func (h *AgentHandler) Lint(c *gin.Context) {
+	if h.linter == nil {
+		problem.Write(c, http.StatusServiceUnavailable, problem.ServiceUnavailable, "Agent linting is not configured", "")
+		return
+	}
+	id := c.Param("id")
+	agent, err := h.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		logrus.WithError(err).WithField("agent_id", id).Error("Failed to get agent for linting")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve agent", "")
+		return
+	}
+	if agent == nil {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Agent not found", "")
+		return
+	}
+	c.JSON(http.StatusOK, models.LintResult{Warnings: h.linter.Lint(c.Request.Context(), agent)})
+}
+
+// LintDraft lists the warnings for an agent definition without saving it.
+// It takes the body of Create.
+func (h *AgentHandler) LintDraft(c *gin.Context) {
+	if h.linter == nil {
+		problem.Write(c, http.StatusServiceUnavailable, problem.ServiceUnavailable, "Agent linting is not configured", "")
+		return
+	}
+	var req models.CreateAgentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if err := h.validator.Struct(&req); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.LintResult{Warnings: h.linter.Lint(c.Request.Context(), req.ToAgent())})
 }
 
 // Delete deletes an agent
 func (h *AgentHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Agent ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Agent ID is required", "")
 		return
 	}
 
@@ -136,19 +270,19 @@ func (h *AgentHandler) Delete(c *gin.Context) {
 	agent, err := h.repo.GetByID(c.Request.Context(), id)
 	if err != nil {
 		logrus.WithError(err).WithField("agent_id", id).Error("Failed to get agent for deletion")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve agent"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve agent", "")
 		return
 	}
 
 	if agent == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Agent not found", "")
 		return
 	}
 
 	// Delete agent
 	if err := h.repo.Delete(c.Request.Context(), id); err != nil {
 		logrus.WithError(err).WithField("agent_id", id).Error("Failed to delete agent")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete agent"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to delete agent", "")
 		return
 	}
 
@@ -156,7 +290,171 @@ func (h *AgentHandler) Delete(c *gin.Context) {
 	c.JSON(http.StatusNoContent, nil)
 }
 
-// List retrieves a paginated list of agents
+// Disable takes an agent offline. Existing history stays readable but new
+// sessions and chats are rejected with the optional maintenance message.
+func (h *AgentHandler) Disable(c *gin.Context) {
+	var req models.DisableAgentRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	h.setEnabled(c, false, req.MaintenanceMessage)
+}
+
+// Enable brings a disabled agent back online
+func (h *AgentHandler) Enable(c *gin.Context) {
+	h.setEnabled(c, true, "")
+}
+
+// setEnabled toggles an agent's enabled flag and maintenance message
+func (h *AgentHandler) setEnabled(c *gin.Context, enabled bool, message string) {
+	id := c.Param("id")
+	if id == "" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Agent ID is required", "")
+		return
+	}
+
+	agent, err := h.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		logrus.WithError(err).WithField("agent_id", id).Error("Failed to get agent")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve agent", "")
+		return
+	}
+
+	if agent == nil {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Agent not found", "")
+		return
+	}
+
+	agent.Enabled = enabled
+	agent.MaintenanceMessage = message
+
+	if err := h.repo.Update(c.Request.Context(), agent); err != nil {
+		logrus.WithError(err).WithField("agent_id", id).Error("Failed to update agent")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to update agent", "")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"agent_id": id,
+		"enabled":  enabled,
+	}).Info("Agent availability changed")
+	c.JSON(http.StatusOK, agent)
+}
+
+// Clone creates a new agent from an existing agent's configuration,
+// optionally copying its memories
+func (h *AgentHandler) Clone(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Agent ID is required", "")
+		return
+	}
+
+	var req models.CloneAgentRequest
+	// The body is optional; an empty one clones with defaults
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	if req.CopyMemories && h.memories == nil {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Memory copy is not available", "")
+		return
+	}
+
+	source, err := h.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		logrus.WithError(err).WithField("agent_id", id).Error("Failed to get agent for cloning")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve agent", "")
+		return
+	}
+
+	if source == nil {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Agent not found", "")
+		return
+	}
+
+	name := ""
+	if req.Name != nil {
+		name = *req.Name
+	}
+	agent := source.Clone(name)
+
+	if err := h.repo.Create(c.Request.Context(), agent); err != nil {
+		logrus.WithError(err).WithField("agent_id", id).Error("Failed to create agent clone")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to clone agent", "")
+		return
+	}
+
+	copied := 0
+	if req.CopyMemories {
+		copied, err = h.copyMemories(c.Request.Context(), source.ID, agent.ID)
+		if err != nil {
+			logrus.WithError(err).WithField("agent_id", id).Error("Failed to copy agent memories")
+			// Don't leave a half-cloned agent behind
+			if delErr := h.repo.Delete(c.Request.Context(), agent.ID); delErr != nil {
+				logrus.WithError(delErr).WithField("agent_id", agent.ID).Error("Failed to remove incomplete agent clone")
+			}
+			problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to copy agent memories", err.Error())
+			return
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"agent_id":        agent.ID,
+		"source_agent_id": source.ID,
+		"memories_copied": copied,
+	}).Info("Agent cloned successfully")
+	c.JSON(http.StatusCreated, agent)
+}
+
+// copyMemories duplicates all unexpired memories of one agent onto another.
+// Session-scoped memories become agent-wide since sessions are not cloned. On
+// failure the memories copied so far are removed again.
+func (h *AgentHandler) copyMemories(ctx context.Context, fromAgentID, toAgentID string) (int, error) {
+	memories, err := h.memories.ListByAgent(ctx, fromAgentID, -1, -1)
+	if err != nil {
+		return 0, err
+	}
+
+	created := make([]string, 0, len(memories))
+	for _, memory := range memories {
+		clone := *memory
+		clone.ID = ""
+		clone.AgentID = toAgentID
+		clone.SessionID = nil
+		if err := h.memories.Create(ctx, &clone); err != nil {
+			for _, memoryID := range created {
+				_ = h.memories.Delete(ctx, memoryID)
+			}
+			return 0, err
+		}
+		created = append(created, clone.ID)
+	}
+
+	return len(memories), nil
+}
+
+// List retrieves a paginated list of agents, optionally filtered and sorted.
+// Pages are addressed by page number or by the opaque next_cursor returned
+// with each page.
 func (h *AgentHandler) List(c *gin.Context) {
 	// Parse pagination parameters
 	page := 1
@@ -174,19 +472,63 @@ func (h *AgentHandler) List(c *gin.Context) {
 		}
 	}
 
-	offset := (page - 1) * pageSize
+	filter := &models.AgentFilter{
+		Provider: c.Query("provider"),
+		Model:    c.Query("model"),
+		Name:     c.Query("name"),
+		Tag:      c.Query("tag"),
+		SortBy:   models.AgentSortCreatedAt,
+		Desc:     true,
+		Limit:    pageSize,
+		Offset:   (page - 1) * pageSize,
+	}
+
+	switch sort := c.Query("sort"); sort {
+	case "":
+	case models.AgentSortCreatedAt, models.AgentSortUpdatedAt, models.AgentSortName:
+		filter.SortBy = sort
+		// Names read naturally A-Z, timestamps newest first
+		filter.Desc = sort != models.AgentSortName
+	default:
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Invalid sort field", sort)
+		return
+	}
+
+	switch order := c.Query("order"); order {
+	case "":
+	case "asc":
+		filter.Desc = false
+	case "desc":
+		filter.Desc = true
+	default:
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Invalid sort order", order)
+		return
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		decoded, err := models.DecodeAgentCursor(cursor)
+		if err != nil {
+			problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Invalid cursor", err.Error())
+			return
+		}
+		filter.Cursor = decoded
+		filter.Offset = 0
+	}
 
 	// Get agents from database
-	agents, total, err := h.repo.List(c.Request.Context(), pageSize, offset)
+	agents, total, err := h.repo.List(c.Request.Context(), filter)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to list agents")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve agents"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve agents", "")
 		return
 	}
 
 	// Calculate pagination info
 	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
 	hasMore := page < int(totalPages)
+	if filter.Cursor != nil {
+		hasMore = len(agents) == pageSize
+	}
 
 	response := gin.H{
 		"agents":      agents,
@@ -196,6 +538,9 @@ func (h *AgentHandler) List(c *gin.Context) {
 		"total_pages": totalPages,
 		"has_more":    hasMore,
 	}
+	if hasMore && len(agents) > 0 {
+		response["next_cursor"] = models.NewAgentCursor(agents[len(agents)-1], filter.SortBy).Encode()
+	}
 
 	c.JSON(http.StatusOK, response)
-}
\ No newline at end of file
+}
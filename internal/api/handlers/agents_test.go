@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"agent-server/internal/api/problem"
 	"agent-server/internal/models"
 
 	"github.com/gin-gonic/gin"
@@ -44,8 +45,8 @@ func (m *MockAgentRepository) Delete(ctx context.Context, id string) error {
 	return args.Error(0)
 }
 
-func (m *MockAgentRepository) List(ctx context.Context, limit, offset int) ([]*models.Agent, int64, error) {
-	args := m.Called(ctx, limit, offset)
+func (m *MockAgentRepository) List(ctx context.Context, filter *models.AgentFilter) ([]*models.Agent, int64, error) {
+	args := m.Called(ctx, filter)
 	return args.Get(0).([]*models.Agent), args.Get(1).(int64), args.Error(2)
 }
 
@@ -54,7 +55,7 @@ func TestAgentHandler_Create(t *testing.T) {
 
 	tests := []struct {
 		name           string
-		requestBody    models.CreateAgentRequest
+		requestBody    interface{}
 		setupMock      func(*MockAgentRepository)
 		expectedStatus int
 		checkResponse  func(*testing.T, *httptest.ResponseRecorder)
@@ -96,9 +97,14 @@ func TestAgentHandler_Create(t *testing.T) {
 			setupMock:      func(repo *MockAgentRepository) {},
 			expectedStatus: http.StatusBadRequest,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
-				var response map[string]interface{}
-				json.Unmarshal(w.Body.Bytes(), &response)
-				assert.Equal(t, "Validation failed", response["error"])
+				var response problem.Problem
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, problem.ValidationFailed, response.Code)
+				assert.Equal(t, "Validation failed", response.Title)
+				assert.Equal(t, http.StatusBadRequest, response.Status)
+				require.Len(t, response.Fields, 1)
+				assert.Equal(t, "provider", response.Fields[0].Name)
+				assert.Contains(t, response.Fields[0].Reason, "must be one of: openai")
 			},
 		},
 		{
@@ -110,9 +116,22 @@ func TestAgentHandler_Create(t *testing.T) {
 			setupMock:      func(repo *MockAgentRepository) {},
 			expectedStatus: http.StatusBadRequest,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
-				var response map[string]interface{}
-				json.Unmarshal(w.Body.Bytes(), &response)
-				assert.Contains(t, response, "error")
+				var response problem.Problem
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, problem.ValidationFailed, response.Code)
+				assert.Contains(t, response.Fields, problem.FieldError{Name: "provider", Reason: "is required"})
+				assert.Contains(t, response.Fields, problem.FieldError{Name: "system_prompt", Reason: "is required"})
+			},
+		},
+		{
+			name:           "wrong field type",
+			requestBody:    map[string]interface{}{"name": "Test Agent", "temperature": "hot"},
+			setupMock:      func(repo *MockAgentRepository) {},
+			expectedStatus: http.StatusBadRequest,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response problem.Problem
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, []problem.FieldError{{Name: "temperature", Reason: "must be of type number"}}, response.Fields)
 			},
 		},
 	}
@@ -123,7 +142,7 @@ func TestAgentHandler_Create(t *testing.T) {
 			mockRepo := new(MockAgentRepository)
 			tt.setupMock(mockRepo)
 
-			handler := NewAgentHandler(mockRepo)
+			handler := NewAgentHandler(mockRepo, nil)
 
 			// Create request
 			body, _ := json.Marshal(tt.requestBody)
@@ -186,9 +205,13 @@ func TestAgentHandler_GetByID(t *testing.T) {
 			},
 			expectedStatus: http.StatusNotFound,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
-				var response map[string]interface{}
-				json.Unmarshal(w.Body.Bytes(), &response)
-				assert.Equal(t, "Agent not found", response["error"])
+				var response problem.Problem
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+				assert.Equal(t, problem.NotFound, response.Code)
+				assert.Equal(t, "urn:agent-server:problem:not-found", response.Type)
+				assert.Equal(t, "Agent not found", response.Title)
+				assert.Equal(t, "/agents/nonexistent", response.Instance)
 			},
 		},
 		{
@@ -199,9 +222,10 @@ func TestAgentHandler_GetByID(t *testing.T) {
 			},
 			expectedStatus: http.StatusBadRequest,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
-				var response map[string]interface{}
-				json.Unmarshal(w.Body.Bytes(), &response)
-				assert.Equal(t, "Agent ID is required", response["error"])
+				var response problem.Problem
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, problem.BadRequest, response.Code)
+				assert.Equal(t, "Agent ID is required", response.Title)
 			},
 		},
 	}
@@ -212,7 +236,7 @@ func TestAgentHandler_GetByID(t *testing.T) {
 			mockRepo := new(MockAgentRepository)
 			tt.setupMock(mockRepo)
 
-			handler := NewAgentHandler(mockRepo)
+			handler := NewAgentHandler(mockRepo, nil)
 
 			// Create request
 			req := httptest.NewRequest("GET", "/agents/"+tt.agentID, nil)
@@ -233,6 +257,40 @@ func TestAgentHandler_GetByID(t *testing.T) {
 	}
 }
 
+// fixedContextReporter reports the same context window for every agent
+type fixedContextReporter struct {
+	report models.ContextWindowReport
+}
+
+func (r *fixedContextReporter) ContextWindowReport(ctx context.Context, agent *models.Agent) *models.ContextWindowReport {
+	return &r.report
+}
+
+func TestAgentHandler_GetByIDWithContextWindow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := new(MockAgentRepository)
+	mockRepo.On("GetByID", mock.Anything, "test-id").Return(&models.Agent{ID: "test-id", Name: "Test Agent", Model: "llama3"}, nil)
+
+	handler := NewAgentHandler(mockRepo, nil)
+	handler.SetContextReporter(&fixedContextReporter{report: models.ContextWindowReport{
+		Tokens: 8192, Source: models.ContextWindowProvider, RecentTurns: 3, AverageUtilization: 0.42, PeakUtilization: 0.6,
+	}})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/agents/test-id", nil)
+	c.Params = gin.Params{{Key: "id", Value: "test-id"}}
+	handler.GetByID(c)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response models.AgentWithContext
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Test Agent", response.Name)
+	require.NotNil(t, response.ContextWindow)
+	assert.Equal(t, 8192, response.ContextWindow.Tokens)
+	assert.Equal(t, 0.42, response.ContextWindow.AverageUtilization)
+}
+
 func TestAgentHandler_List(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -252,9 +310,13 @@ func TestAgentHandler_List(t *testing.T) {
 		},
 	}
 
-	mockRepo.On("List", mock.Anything, 20, 0).Return(agents, int64(2), nil)
+	mockRepo.On("List", mock.Anything, &models.AgentFilter{
+		SortBy: models.AgentSortCreatedAt,
+		Desc:   true,
+		Limit:  20,
+	}).Return(agents, int64(2), nil)
 
-	handler := NewAgentHandler(mockRepo)
+	handler := NewAgentHandler(mockRepo, nil)
 
 	// Create request
 	req := httptest.NewRequest("GET", "/agents", nil)
@@ -281,4 +343,222 @@ func TestAgentHandler_List(t *testing.T) {
 	assert.Len(t, agentsData, 2)
 
 	mockRepo.AssertExpectations(t)
-}
\ No newline at end of file
+}
+func TestAgentHandler_Clone(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	source := &models.Agent{
+		ID:           "source-id",
+		Name:         "Support Bot",
+		Provider:     "openai",
+		Model:        "gpt-4",
+		SystemPrompt: "You are helpful",
+		Temperature:  0.2,
+		MaxTokens:    500,
+		Config:       models.JSON{"tools": []interface{}{"memory"}},
+		Tags:         models.StringList{"support"},
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		setupMock      func(*MockAgentRepository)
+		expectedStatus int
+		expectedName   string
+	}{
+		{
+			name: "clone with default name",
+			setupMock: func(repo *MockAgentRepository) {
+				repo.On("GetByID", mock.Anything, "source-id").Return(source, nil)
+				repo.On("Create", mock.Anything, mock.AnythingOfType("*models.Agent")).Return(nil)
+			},
+			expectedStatus: http.StatusCreated,
+			expectedName:   "Support Bot (copy)",
+		},
+		{
+			name: "clone with new name",
+			body: `{"name": "Support Bot v2"}`,
+			setupMock: func(repo *MockAgentRepository) {
+				repo.On("GetByID", mock.Anything, "source-id").Return(source, nil)
+				repo.On("Create", mock.Anything, mock.AnythingOfType("*models.Agent")).Return(nil)
+			},
+			expectedStatus: http.StatusCreated,
+			expectedName:   "Support Bot v2",
+		},
+		{
+			name: "source not found",
+			setupMock: func(repo *MockAgentRepository) {
+				repo.On("GetByID", mock.Anything, "source-id").Return(nil, nil)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "memory copy unavailable",
+			body:           `{"copy_memories": true}`,
+			setupMock:      func(repo *MockAgentRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockAgentRepository)
+			tt.setupMock(mockRepo)
+
+			handler := NewAgentHandler(mockRepo, nil)
+
+			req := httptest.NewRequest("POST", "/agents/source-id/clone", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = gin.Params{{Key: "id", Value: "source-id"}}
+
+			handler.Clone(c)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusCreated {
+				var agent models.Agent
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &agent))
+				assert.Equal(t, tt.expectedName, agent.Name)
+				assert.Equal(t, source.SystemPrompt, agent.SystemPrompt)
+				assert.Equal(t, source.Model, agent.Model)
+				assert.Equal(t, source.Temperature, agent.Temperature)
+				assert.Equal(t, source.Config, agent.Config)
+				assert.Equal(t, source.Tags, agent.Tags)
+				assert.NotEqual(t, source.ID, agent.ID)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+// rejectTools is an AgentToolValidator that rejects every tool list
+type rejectTools struct{}
+
+func (rejectTools) ValidateTools(ctx context.Context, agent *models.Agent) []models.ValidationError {
+	if len(agent.AllowedTools()) == 0 {
+		return nil
+	}
+	return []models.ValidationError{{Parameter: "config.tools[0]", Message: "unknown tool teleport"}}
+}
+
+func TestAgentHandler_ValidatesTools(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockRepo := new(MockAgentRepository)
+	handler := NewAgentHandler(mockRepo, nil)
+	handler.SetToolValidator(rejectTools{})
+
+	body := `{"name": "Agent", "provider": "ollama", "model": "llama3", "system_prompt": "Help", "config": {"tools": ["teleport"]}}`
+	req := httptest.NewRequest("POST", "/agents", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	handler.Create(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var p problem.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, problem.ValidationFailed, p.Code)
+	require.Len(t, p.Fields, 1)
+	assert.Equal(t, "config.tools[0]", p.Fields[0].Name)
+	assert.Equal(t, "unknown tool teleport", p.Fields[0].Reason)
+
+	// Updates that leave tools and provider alone are not checked
+	existing := &models.Agent{ID: "agent-1", Name: "Agent", Provider: "ollama", Model: "llama3", Config: models.JSON{"tools": []interface{}{"teleport"}}}
+	mockRepo.On("GetByID", mock.Anything, "agent-1").Return(existing, nil)
+	mockRepo.On("Update", mock.Anything, existing).Return(nil).Once()
+
+	for _, tt := range []struct {
+		body   string
+		status int
+	}{
+		{`{"name": "Renamed"}`, http.StatusOK},
+		{`{"provider": "openai"}`, http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest("PUT", "/agents/agent-1", bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+		c.Params = gin.Params{{Key: "id", Value: "agent-1"}}
+
+		handler.Update(c)
+		assert.Equal(t, tt.status, w.Code, tt.body)
+	}
+
+	mockRepo.AssertExpectations(t)
+}
+
+// shortPromptLinter warns about system prompts of less than 20 characters
+type shortPromptLinter struct{}
+
+func (shortPromptLinter) Lint(ctx context.Context, agent *models.Agent) []models.LintWarning {
+	if len(agent.SystemPrompt) >= 20 {
+		return []models.LintWarning{}
+	}
+	return []models.LintWarning{{Code: "PROMPT_SHORT", Field: "system_prompt", Message: "too short"}}
+}
+
+func TestAgentHandler_Lint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockRepo := new(MockAgentRepository)
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.Agent")).Return(nil)
+	handler := NewAgentHandler(mockRepo, nil)
+	handler.SetLinter(shortPromptLinter{})
+
+	body := `{"name": "Agent", "provider": "ollama", "model": "llama3", "system_prompt": "Help"}`
+	for _, tt := range []struct {
+		handle func(*gin.Context)
+		status int
+	}{
+		{handler.Create, http.StatusCreated},
+		{handler.LintDraft, http.StatusOK},
+	} {
+		req := httptest.NewRequest("POST", "/agents", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+
+		tt.handle(c)
+
+		require.Equal(t, tt.status, w.Code)
+		var response models.AgentWithWarnings
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Warnings, 1)
+		assert.Equal(t, "system_prompt", response.Warnings[0].Field)
+	}
+	mockRepo.AssertNumberOfCalls(t, "Create", 1)
+
+	// Saved agents are linted by ID
+	mockRepo.On("GetByID", mock.Anything, "agent-1").Return(&models.Agent{ID: "agent-1", SystemPrompt: "You are a careful assistant."}, nil)
+	mockRepo.On("GetByID", mock.Anything, "missing").Return(nil, nil)
+	for _, tt := range []struct {
+		id     string
+		status int
+	}{
+		{"agent-1", http.StatusOK},
+		{"missing", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/agents/"+tt.id+"/lint", nil)
+		c.Params = gin.Params{{Key: "id", Value: tt.id}}
+
+		handler.Lint(c)
+
+		require.Equal(t, tt.status, w.Code, tt.id)
+		if tt.status == http.StatusOK {
+			var result models.LintResult
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			assert.NotNil(t, result.Warnings)
+			assert.Empty(t, result.Warnings)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+
+	"agent-server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AlertHandler reports the state of the alert rules
+type AlertHandler struct {
+	monitor *services.AlertMonitor
+}
+
+// NewAlertHandler creates a new alert handler; monitor is nil when alerts
+// are disabled
+func NewAlertHandler(monitor *services.AlertMonitor) *AlertHandler {
+	return &AlertHandler{
+		monitor: monitor,
+	}
+}
+
+// List returns the state of every alert, firing ones first
+// @Summary List alerts
+// @Description List the alerts of the configured rules with their state, value and last notification
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/alerts [get]
+func (h *AlertHandler) List(c *gin.Context) {
+	if h.monitor == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"enabled": false,
+			"alerts":  []services.Alert{},
+			"firing":  0,
+		})
+		return
+	}
+
+	alerts := h.monitor.Alerts()
+	firing := 0
+	for _, alert := range alerts {
+		if alert.State == services.AlertFiring {
+			firing++
+		}
+	}
+	response := gin.H{
+		"enabled": true,
+		"rules":   len(h.monitor.Rules()),
+		"alerts":  alerts,
+		"firing":  firing,
+	}
+	if checked := h.monitor.LastCheck(); !checked.IsZero() {
+		response["checked_at"] = checked
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/jobs"
+	"agent-server/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ArchiveHandler handles session archival requests
+type ArchiveHandler struct {
+	archiveService *services.ArchiveService
+	jobRunner      *jobs.Runner
+	idleDays       int
+}
+
+// NewArchiveHandler creates a new archive handler. idleDays is the default
+// inactivity period used when archiving idle sessions in bulk.
+func NewArchiveHandler(archiveService *services.ArchiveService, jobRunner *jobs.Runner, idleDays int) *ArchiveHandler {
+	return &ArchiveHandler{
+		archiveService: archiveService,
+		jobRunner:      jobRunner,
+		idleDays:       idleDays,
+	}
+}
+
+// ArchiveIdleRequest represents a bulk archival request
+type ArchiveIdleRequest struct {
+	IdleDays int `json:"idle_days,omitempty" validate:"omitempty,min=1"`
+	Limit    int `json:"limit,omitempty" validate:"omitempty,min=1,max=10000"`
+}
+
+// CompactRequest represents a session compaction request
+type CompactRequest struct {
+	KeepRecent int `json:"keep_recent,omitempty" validate:"omitempty,min=1"`
+
+	// Async compacts in a background job instead of within the request
+	Async bool `json:"async,omitempty"`
+}
+
+// Archive moves a session's messages into cold storage
+func (h *ArchiveHandler) Archive(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
+		return
+	}
+
+	archive, err := h.archiveService.Archive(c.Request.Context(), id)
+	if err != nil {
+		h.writeError(c, id, "Failed to archive session", err)
+		return
+	}
+
+	response := gin.H{
+		"session_id": id,
+		"status":     "archived",
+	}
+	if archive != nil {
+		response["archive"] = archive
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Unarchive restores a session's archived messages
+func (h *ArchiveHandler) Unarchive(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
+		return
+	}
+
+	result, err := h.archiveService.Unarchive(c.Request.Context(), id)
+	if err != nil {
+		h.writeError(c, id, "Failed to unarchive session", err)
+		return
+	}
+
+	response := gin.H{
+		"session_id":        id,
+		"status":            "active",
+		"restored_messages": result.Restored,
+	}
+	if len(result.MissingSequences) > 0 {
+		response["missing_sequences"] = result.MissingSequences
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Compact replaces a session's older messages with a summary message and
+// archives them
+func (h *ArchiveHandler) Compact(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
+		return
+	}
+
+	var req CompactRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+	}
+	if req.KeepRecent < 0 {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "keep_recent must be positive", "")
+		return
+	}
+
+	if req.Async {
+		job, err := h.jobRunner.Enqueue(c.Request.Context(), services.JobTypeCompactSession, map[string]interface{}{
+			"session_id":  id,
+			"keep_recent": req.KeepRecent,
+		})
+		if err != nil {
+			logrus.WithError(err).WithField("session_id", id).Error("Failed to enqueue compact job")
+			problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to compact session", "")
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"session_id": id,
+			"job_id":     job.ID,
+		})
+		return
+	}
+
+	result, err := h.archiveService.Compact(c.Request.Context(), id, req.KeepRecent)
+	if err != nil {
+		if errors.Is(err, services.ErrNothingToCompact) {
+			problem.Write(c, http.StatusConflict, problem.Conflict, "Nothing to compact", err.Error())
+			return
+		}
+		logrus.WithError(err).WithField("session_id", id).Error("Failed to compact session")
+		writeChatError(c, "Failed to compact session", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id":         id,
+		"compacted_messages": result.Archive.MessageCount,
+		"summary":            result.Summary,
+		"archive":            result.Archive,
+	})
+}
+
+// ListCompactions lists the archives of a session's compactions
+func (h *ArchiveHandler) ListCompactions(c *gin.Context) {
+	id := c.Param("id")
+	compactions, err := h.archiveService.Compactions(c.Request.Context(), id)
+	if err != nil {
+		h.writeError(c, id, "Failed to list compactions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"compactions": compactions,
+		"total_count": len(compactions),
+	})
+}
+
+// CompactedMessages returns the messages and tool calls a compaction
+// replaced
+func (h *ArchiveHandler) CompactedMessages(c *gin.Context) {
+	id := c.Param("id")
+	payload, err := h.archiveService.CompactedMessages(c.Request.Context(), id, c.Param("archive_id"))
+	if err != nil {
+		h.writeError(c, id, "Failed to read compaction", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, payload)
+}
+
+// ArchiveIdle enqueues archive jobs for sessions idle longer than idle_days
+func (h *ArchiveHandler) ArchiveIdle(c *gin.Context) {
+	var req ArchiveIdleRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+	}
+
+	if req.IdleDays <= 0 {
+		req.IdleDays = h.idleDays
+	}
+	if req.IdleDays <= 0 {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "idle_days is required", "")
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 500
+	}
+
+	sessions, err := h.archiveService.ListIdleSessions(c.Request.Context(), time.Duration(req.IdleDays)*24*time.Hour, req.Limit)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list idle sessions")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to list idle sessions", "")
+		return
+	}
+
+	jobIDs := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		job, err := h.jobRunner.Enqueue(c.Request.Context(), services.JobTypeArchiveSession, map[string]interface{}{
+			"session_id": session.ID,
+		})
+		if err != nil {
+			logrus.WithError(err).WithField("session_id", session.ID).Error("Failed to enqueue archive job")
+			continue
+		}
+		jobIDs = append(jobIDs, job.ID)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"idle_days": req.IdleDays,
+		"enqueued":  len(jobIDs),
+	}).Info("Enqueued idle session archival")
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"idle_days": req.IdleDays,
+		"enqueued":  len(jobIDs),
+		"job_ids":   jobIDs,
+	})
+}
+
+// writeError maps archive service errors to HTTP responses
+func (h *ArchiveHandler) writeError(c *gin.Context, sessionID, message string, err error) {
+	switch {
+	case errors.Is(err, services.ErrSessionNotFound):
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Session not found", "")
+	case errors.Is(err, services.ErrArchiveNotFound):
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Compaction not found", "")
+	case errors.Is(err, services.ErrSessionArchived):
+		problem.Write(c, http.StatusConflict, problem.SessionArchived, message, err.Error())
+	case errors.Is(err, services.ErrSessionNotArchived):
+		problem.Write(c, http.StatusConflict, problem.Conflict, message, err.Error())
+	default:
+		logrus.WithError(err).WithField("session_id", sessionID).Error(message)
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, message, err.Error())
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/storage/blob"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// signatureVerifier is implemented by blob stores whose signed URLs are served
+// by this API rather than by the storage backend itself
+type signatureVerifier interface {
+	VerifySignature(key, expires, signature string) error
+}
+
+// BlobHandler serves signed blob downloads
+type BlobHandler struct {
+	store blob.Store
+}
+
+// NewBlobHandler creates a new blob handler
+func NewBlobHandler(store blob.Store) *BlobHandler {
+	return &BlobHandler{
+		store: store,
+	}
+}
+
+// Download streams an object to the client after checking its URL signature
+func (h *BlobHandler) Download(c *gin.Context) {
+	if h.store == nil {
+		problem.Write(c, http.StatusServiceUnavailable, problem.ServiceUnavailable, "Blob storage is not configured", "")
+		return
+	}
+
+	verifier, ok := h.store.(signatureVerifier)
+	if !ok {
+		// Backends like S3 serve their own signed URLs
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Blob downloads are served by the storage backend", "")
+		return
+	}
+
+	key := c.Param("key")
+	if err := verifier.VerifySignature(key, c.Query("expires"), c.Query("signature")); err != nil {
+		problem.Write(c, http.StatusForbidden, problem.Forbidden, "Invalid or expired download link", "")
+		return
+	}
+
+	reader, info, err := h.store.Get(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) || errors.Is(err, blob.ErrInvalidKey) {
+			problem.Write(c, http.StatusNotFound, problem.NotFound, "Object not found", "")
+			return
+		}
+		logrus.WithError(err).WithField("key", key).Error("Failed to read blob")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to read object", "")
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Type", info.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		logrus.WithError(err).WithField("key", key).Warn("Blob download interrupted")
+	}
+}
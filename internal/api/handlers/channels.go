@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"agent-server/internal/channels"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChannelHandler reports the chat platforms the server is connected to
+type ChannelHandler struct {
+	registry *channels.Registry
+}
+
+// NewChannelHandler creates a new channel handler
+func NewChannelHandler(registry *channels.Registry) *ChannelHandler {
+	return &ChannelHandler{
+		registry: registry,
+	}
+}
+
+// List returns the configured channels and whether they are running
+// @Summary List chat channels
+// @Description List the configured chat platform channels (Discord, Matrix, email) with their connection status
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/channels [get]
+func (h *ChannelHandler) List(c *gin.Context) {
+	statuses := h.registry.Status()
+	c.JSON(http.StatusOK, gin.H{
+		"channels": statuses,
+		"total":    len(statuses),
+	})
+}
@@ -1,24 +1,54 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	"agent-server/internal/api/problem"
+	"agent-server/internal/llm"
 	"agent-server/internal/models"
+	"agent-server/internal/postprocess"
 	"agent-server/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 )
 
+// DefaultKeepAliveInterval is how often idle SSE streams get a keep-alive
+// comment unless configured otherwise
+const DefaultKeepAliveInterval = 15 * time.Second
+
+// sseKeepAlive is an SSE comment; clients ignore it, but it keeps proxies
+// from closing streams that wait on slow models or tools
+const sseKeepAlive = ": keepalive\n\n"
+
+// DebugContextHeader set to true on a chat request returns the trace of how
+// the reply's context was built inline, as "context_trace" in its metadata
+const DebugContextHeader = "X-Debug-Context"
+
+// chatContext returns the context of a chat request, asking for the context
+// trace inline when the request carries the debug header
+func chatContext(r *http.Request) context.Context {
+	if r.Header.Get(DebugContextHeader) == "true" {
+		return services.WithContextTrace(r.Context())
+	}
+	return r.Context()
+}
+
 // ChatHandler handles chat-related requests with tool calling support
 type ChatHandler struct {
 	chatService *services.ChatService
 	toolService *services.ToolService
 	validator   *validator.Validate
 	logger      *slog.Logger
+	keepAlive   time.Duration
 }
 
 // NewChatHandler creates a new chat handler
@@ -30,22 +60,39 @@ func NewChatHandler(
 	return &ChatHandler{
 		chatService: chatService,
 		toolService: toolService,
-		validator:   validator.New(),
+		validator:   newValidator(),
 		logger:      logger,
+		keepAlive:   DefaultKeepAliveInterval,
 	}
 }
 
+// SetKeepAliveInterval sets how often idle SSE streams get a keep-alive
+// comment; zero disables keep-alives
+func (h *ChatHandler) SetKeepAliveInterval(interval time.Duration) {
+	h.keepAlive = interval
+}
+
+// keepAliveTicks returns a channel that ticks at the keep-alive interval, or
+// nil when keep-alives are disabled, and a function that stops it
+func (h *ChatHandler) keepAliveTicks() (<-chan time.Time, func()) {
+	if h.keepAlive <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(h.keepAlive)
+	return ticker.C, ticker.Stop
+}
+
 // Chat handles synchronous chat requests
 func (h *ChatHandler) Chat(c *gin.Context) {
 	sessionID := c.Param("id")
 	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
 		return
 	}
 
 	var req services.ChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
@@ -54,15 +101,15 @@ func (h *ChatHandler) Chat(c *gin.Context) {
 
 	// Validate request
 	if err := h.validator.Struct(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		respondValidationError(c, err)
 		return
 	}
 
 	// Process chat request
-	response, err := h.chatService.Chat(c.Request.Context(), &req)
+	response, err := h.chatService.Chat(chatContext(c.Request), &req)
 	if err != nil {
 		h.logger.Error("Chat request failed", "session_id", sessionID, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Chat request failed", "details": err.Error()})
+		writeChatError(c, "Chat request failed", err)
 		return
 	}
 
@@ -73,13 +120,13 @@ func (h *ChatHandler) Chat(c *gin.Context) {
 func (h *ChatHandler) Stream(c *gin.Context) {
 	sessionID := c.Param("id")
 	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
 		return
 	}
 
 	var req services.ChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
@@ -89,7 +136,7 @@ func (h *ChatHandler) Stream(c *gin.Context) {
 
 	// Validate request
 	if err := h.validator.Struct(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		respondValidationError(c, err)
 		return
 	}
 
@@ -97,39 +144,47 @@ func (h *ChatHandler) Stream(c *gin.Context) {
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
-	c.Header("Access-Control-Allow-Origin", "*")
 
 	// Start streaming
-	chunks, err := h.chatService.Stream(c.Request.Context(), &req)
+	chunks, err := h.chatService.Stream(chatContext(c.Request), &req)
 	if err != nil {
 		h.logger.Error("Streaming chat failed", "session_id", sessionID, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Streaming failed", "details": err.Error()})
+		writeChatError(c, "Streaming failed", err)
 		return
 	}
 
 	// Stream chunks to client
 	flusher, ok := c.Writer.(http.Flusher)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Streaming not supported"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Streaming not supported", "")
 		return
 	}
 
-	for chunk := range chunks {
-		// Write chunk as SSE
-		if chunk.Content != "" || chunk.Done {
-			fmt.Fprintf(c.Writer, "data: %s\n\n", h.formatSSEData(chunk))
-			flusher.Flush()
-		}
-
-		if chunk.Done {
-			break
-		}
+	ticks, stop := h.keepAliveTicks()
+	defer stop()
 
-		// Check if client disconnected
+	for {
 		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				return
+			}
+
+			// Write chunk as SSE
+			if chunk.Content != "" || chunk.Done {
+				fmt.Fprintf(c.Writer, "data: %s\n\n", h.formatSSEData(chunk))
+				flusher.Flush()
+			}
+
+			if chunk.Done {
+				return
+			}
+		case <-ticks:
+			fmt.Fprint(c.Writer, sseKeepAlive)
+			flusher.Flush()
 		case <-c.Request.Context().Done():
+			// Client disconnected
 			return
-		default:
 		}
 	}
 }
@@ -145,6 +200,14 @@ func (h *ChatHandler) formatSSEData(chunk services.StreamChunk) string {
 		data["message_id"] = chunk.MessageID
 	}
 
+	if chunk.FinishReason != "" {
+		data["finish_reason"] = chunk.FinishReason
+	}
+
+	if chunk.Usage != nil {
+		data["usage"] = chunk.Usage
+	}
+
 	if chunk.Metadata != nil {
 		data["metadata"] = chunk.Metadata
 	}
@@ -158,25 +221,19 @@ func (h *ChatHandler) formatSSEData(chunk services.StreamChunk) string {
 func (h *ChatHandler) ChatWithTools(c *gin.Context) {
 	sessionID := c.Param("id")
 	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
 		return
 	}
 
 	var req models.EnhancedChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
 
 	// Validate request
 	if err := h.validator.Struct(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Validation failed",
-			"details": err.Error(),
-		})
+		respondValidationError(c, err)
 		return
 	}
 
@@ -186,17 +243,19 @@ func (h *ChatHandler) ChatWithTools(c *gin.Context) {
 		"tools_count", len(req.Tools),
 		"tool_choice", req.ToolChoice)
 
+	if req.Stream {
+		h.streamWithTools(c, &req, sessionID)
+		return
+	}
+
 	// Process chat request with tools
-	response, err := h.chatService.ChatWithTools(c.Request.Context(), &req, sessionID)
+	response, err := h.chatService.ChatWithTools(chatContext(c.Request), &req, sessionID)
 	if err != nil {
 		h.logger.Error("Chat with tools request failed",
 			"session_id", sessionID,
 			"error", err)
 		
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Chat request failed",
-			"details": err.Error(),
-		})
+		writeChatError(c, "Chat request failed", err)
 		return
 	}
 
@@ -214,34 +273,32 @@ func (h *ChatHandler) ChatWithTools(c *gin.Context) {
 func (h *ChatHandler) ChatWithAutoTools(c *gin.Context) {
 	sessionID := c.Param("id")
 	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
 		return
 	}
 
 	var basicReq services.ChatRequest
 	if err := c.ShouldBindJSON(&basicReq); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
 
 	// Convert to enhanced request with auto tool selection
 	req := models.EnhancedChatRequest{
-		Message:    basicReq.Message,
-		Tools:      []string{}, // Empty means all available tools
-		ToolChoice: "auto",     // Let the LLM decide
-		Metadata:   basicReq.Metadata,
-		Stream:     basicReq.Stream,
+		Message:          basicReq.Message,
+		Tools:            []string{}, // Empty means all available tools
+		ToolChoice:       "auto",     // Let the LLM decide
+		Metadata:         basicReq.Metadata,
+		Stream:           basicReq.Stream,
+		Stop:             basicReq.Stop,
+		ResponseLanguage: basicReq.ResponseLanguage,
+		Priority:         basicReq.Priority,
+		Accept:           basicReq.Accept,
 	}
 
 	// Validate request
 	if err := h.validator.Struct(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Validation failed",
-			"details": err.Error(),
-		})
+		respondValidationError(c, err)
 		return
 	}
 
@@ -249,17 +306,19 @@ func (h *ChatHandler) ChatWithAutoTools(c *gin.Context) {
 		"session_id", sessionID,
 		"message_length", len(req.Message))
 
+	if req.Stream {
+		h.streamWithTools(c, &req, sessionID)
+		return
+	}
+
 	// Process chat request with automatic tool selection
-	response, err := h.chatService.ChatWithTools(c.Request.Context(), &req, sessionID)
+	response, err := h.chatService.ChatWithTools(chatContext(c.Request), &req, sessionID)
 	if err != nil {
 		h.logger.Error("Auto-tools chat request failed",
 			"session_id", sessionID,
 			"error", err)
 		
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Chat request failed",
-			"details": err.Error(),
-		})
+		writeChatError(c, "Chat request failed", err)
 		return
 	}
 
@@ -271,11 +330,64 @@ func (h *ChatHandler) ChatWithAutoTools(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// streamWithTools runs a tool-calling chat and reports its progress as
+// Server-Sent Events named after the event type, ending with a done event
+// carrying the response or an error event carrying a problem
+func (h *ChatHandler) streamWithTools(c *gin.Context, req *models.EnhancedChatRequest, sessionID string) {
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Streaming not supported", "")
+		return
+	}
+
+	events, err := h.chatService.StreamWithTools(chatContext(c.Request), req, sessionID)
+	if err != nil {
+		h.logger.Error("Streaming chat with tools failed", "session_id", sessionID, "error", err)
+		writeChatError(c, "Streaming failed", err)
+		return
+	}
+
+	// Set headers for Server-Sent Events
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	ticks, stop := h.keepAliveTicks()
+	defer stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			var data interface{} = event
+			if event.Type == services.EventError {
+				h.logger.Error("Chat with tools request failed", "session_id", sessionID, "error", event.Err)
+				p := chatProblem("Chat request failed", event.Err)
+				p.Instance = c.Request.URL.Path
+				data = p
+			}
+
+			payload, _ := json.Marshal(data)
+			fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event.Type, payload)
+			flusher.Flush()
+		case <-ticks:
+			// Tool executions can take longer than proxy idle timeouts
+			fmt.Fprint(c.Writer, sseKeepAlive)
+			flusher.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
 // ListAvailableTools returns tools available for a session
 func (h *ChatHandler) ListAvailableTools(c *gin.Context) {
 	sessionID := c.Param("id")
 	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
 		return
 	}
 
@@ -288,10 +400,7 @@ func (h *ChatHandler) ListAvailableTools(c *gin.Context) {
 			"session_id", sessionID,
 			"error", err)
 		
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to list tools",
-			"details": err.Error(),
-		})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to list tools", err.Error())
 		return
 	}
 
@@ -317,12 +426,12 @@ func (h *ChatHandler) GetToolSchema(c *gin.Context) {
 	toolName := c.Param("tool_name")
 	
 	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
 		return
 	}
 	
 	if toolName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Tool name is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Tool name is required", "")
 		return
 	}
 
@@ -336,18 +445,12 @@ func (h *ChatHandler) GetToolSchema(c *gin.Context) {
 			"tool_name", toolName,
 			"error", err)
 		
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to get tool schema",
-			"details": err.Error(),
-		})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to get tool schema", err.Error())
 		return
 	}
 
 	if len(definitions) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":     "Tool not found or not available",
-			"tool_name": toolName,
-		})
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Tool not found or not available", toolName)
 		return
 	}
 
@@ -360,21 +463,18 @@ func (h *ChatHandler) TestToolForSession(c *gin.Context) {
 	toolName := c.Param("tool_name")
 	
 	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
 		return
 	}
 	
 	if toolName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Tool name is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Tool name is required", "")
 		return
 	}
 
 	var req models.ToolTestRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
 
@@ -393,10 +493,16 @@ func (h *ChatHandler) TestToolForSession(c *gin.Context) {
 			"tool_name", toolName,
 			"error", err)
 		
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to test tool",
-			"details": err.Error(),
-		})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to test tool", err.Error())
+		return
+	}
+
+	if len(response.Validation) > 0 {
+		respondToolValidationError(c, response.Validation)
+		return
+	}
+	if response.ErrorCode == "TIMEOUT" {
+		problem.Write(c, http.StatusGatewayTimeout, problem.ToolTimeout, "Tool execution timed out", response.Error)
 		return
 	}
 
@@ -413,7 +519,7 @@ func (h *ChatHandler) TestToolForSession(c *gin.Context) {
 func (h *ChatHandler) GetToolCallHistory(c *gin.Context) {
 	sessionID := c.Param("id")
 	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
 		return
 	}
 
@@ -452,6 +558,40 @@ func (h *ChatHandler) GetToolCallHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// GetToolExecutionLog returns the audited tool executions of a session,
+// including captured outbound HTTP requests
+func (h *ChatHandler) GetToolExecutionLog(c *gin.Context) {
+	sessionID := c.Param("id")
+	if sessionID == "" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
+		return
+	}
+
+	page := 1
+	pageSize := 20
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p := parseInt(pageParam, 1); p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s := parseInt(sizeParam, 20); s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	logs, err := h.toolService.ListExecutionLogs(c.Request.Context(), sessionID, page, pageSize)
+	if err != nil {
+		h.logger.Error("Failed to list tool execution logs", "session_id", sessionID, "error", err)
+		writeChatError(c, "Failed to retrieve tool execution log", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, logs)
+}
+
 // Helper function to parse integer with default
 func parseInt(s string, defaultValue int) int {
 	if s == "" {
@@ -468,4 +608,96 @@ func parseInt(s string, defaultValue int) int {
 		}
 	}
 	return result
-}
\ No newline at end of file
+}
+
+// writeChatError maps chat service errors to problem responses, passing on
+// how long a rate-limited provider or a busy agent asks to wait
+func writeChatError(c *gin.Context, title string, err error) {
+	wait := llm.RetryAfter(err)
+	var busy *services.AgentBusyError
+	if errors.As(err, &busy) {
+		wait = busy.RetryAfter
+	}
+	if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	}
+	problem.Send(c, chatProblem(title, err))
+}
+
+// chatProblem maps a chat service error to a problem
+func chatProblem(title string, err error) *problem.Problem {
+	status, code := http.StatusInternalServerError, problem.Internal
+	switch {
+	case errors.Is(err, services.ErrSessionNotFound), errors.Is(err, services.ErrAgentNotFound):
+		status, code = http.StatusNotFound, problem.NotFound
+	case errors.Is(err, services.ErrSessionArchived):
+		status, code = http.StatusConflict, problem.SessionArchived
+	case errors.Is(err, services.ErrAgentDisabled):
+		status, code = http.StatusConflict, problem.AgentDisabled
+	case errors.Is(err, services.ErrSameAgent):
+		status, code = http.StatusConflict, problem.Conflict
+	case errors.Is(err, llm.ErrContextOverflow):
+		status, code = http.StatusUnprocessableEntity, problem.ContextOverflow
+	case errors.Is(err, services.ErrToolLoopExceeded):
+		status, code = http.StatusUnprocessableEntity, problem.ToolLoopExceeded
+	case errors.Is(err, services.ErrToolChoiceUnmet):
+		status, code = http.StatusUnprocessableEntity, problem.ToolChoiceUnmet
+	case errors.Is(err, services.ErrInvalidToolChoice):
+		status, code = http.StatusBadRequest, problem.BadRequest
+	case errors.Is(err, postprocess.ErrNotAcceptable):
+		status, code = http.StatusNotAcceptable, problem.NotAcceptable
+	case errors.Is(err, services.ErrBudgetExceeded):
+		status, code = http.StatusPaymentRequired, problem.BudgetExceeded
+	case errors.Is(err, services.ErrContentBlocked):
+		status, code = http.StatusUnprocessableEntity, problem.ContentBlocked
+	case errors.Is(err, services.ErrAgentBusy):
+		status, code = http.StatusTooManyRequests, problem.AgentBusy
+	case errors.Is(err, llm.ErrRateLimited):
+		status, code = http.StatusTooManyRequests, problem.RateLimited
+	case errors.Is(err, llm.ErrModelNotFound):
+		status, code = http.StatusBadGateway, problem.ModelNotFound
+	case errors.Is(err, llm.ErrAuthFailed):
+		status, code = http.StatusBadGateway, problem.ProviderAuthFailed
+	case errors.Is(err, llm.ErrTimeout):
+		status, code = http.StatusGatewayTimeout, problem.Timeout
+	case errors.Is(err, services.ErrProviderUnavailable), errors.Is(err, llm.ErrUnavailable):
+		status, code = http.StatusServiceUnavailable, problem.ProviderUnavailable
+	case errors.Is(err, services.ErrNoWorker):
+		status, code = http.StatusServiceUnavailable, problem.ServiceUnavailable
+	case errors.Is(err, services.ErrLLMRequest):
+		status, code = http.StatusBadGateway, problem.ProviderError
+	case errors.Is(err, context.DeadlineExceeded):
+		status, code = http.StatusGatewayTimeout, problem.Timeout
+	}
+	p := problem.New(status, code, title)
+	p.Detail = err.Error()
+	var missing *services.ModelMissingError
+	if errors.As(err, &missing) {
+		p.Remediation = modelRemediation(missing)
+	}
+	return p
+}
+
+// modelRemediation tells the client how to get a missing model: wait for
+// the download already started, or start one
+func modelRemediation(missing *services.ModelMissingError) *problem.Remediation {
+	href := "/api/v1/providers/" + missing.Provider + "/pulls"
+	switch {
+	case missing.Pull != nil:
+		return &problem.Remediation{
+			Action:      "await_pull",
+			Description: fmt.Sprintf("Model %s is being pulled onto %s; retry once the pull has completed", missing.Model, missing.Provider),
+			Method:      http.MethodGet,
+			Href:        href,
+		}
+	case missing.CanPull:
+		return &problem.Remediation{
+			Action:      "pull_model",
+			Description: fmt.Sprintf("Pull model %s onto %s, then retry", missing.Model, missing.Provider),
+			Method:      http.MethodPost,
+			Href:        href,
+			Body:        map[string]interface{}{"model": missing.Model},
+		}
+	}
+	return nil
+}
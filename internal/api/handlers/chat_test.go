@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/llm/mock"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatHandler_StreamKeepAlive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "slow", Provider: mock.Name, Model: "mock", Enabled: true}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	// Words arrive slower than the keep-alive interval
+	registry := llm.NewRegistry()
+	registry.Register(mock.NewProvider(mock.Options{ReplyWords: 3, StreamDelay: 60 * time.Millisecond}))
+	chatService := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+
+	for _, tt := range []struct {
+		name      string
+		interval  time.Duration
+		keepAlive bool
+	}{
+		{name: "enabled", interval: 20 * time.Millisecond, keepAlive: true},
+		{name: "disabled", interval: 0, keepAlive: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewChatHandler(chatService, nil, slog.Default())
+			handler.SetKeepAliveInterval(tt.interval)
+			router := gin.New()
+			router.POST("/sessions/:id/stream", handler.Stream)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/sessions/"+session.ID+"/stream", strings.NewReader(`{"message":"hi"}`))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			body := w.Body.String()
+			assert.Equal(t, tt.keepAlive, strings.Contains(body, "\n: keepalive\n\n"), body)
+			assert.Contains(t, body, `"done":true`)
+		})
+	}
+}
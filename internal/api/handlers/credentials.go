@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/credentials"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// CredentialHandler handles admin requests for the tools' OAuth2 credentials
+type CredentialHandler struct {
+	oauth2 *credentials.Manager
+}
+
+// NewCredentialHandler creates a new credential handler
+func NewCredentialHandler(oauth2 *credentials.Manager) *CredentialHandler {
+	return &CredentialHandler{oauth2: oauth2}
+}
+
+// List returns the OAuth2 credentials with the state of their tokens
+// @Summary List OAuth2 credentials
+// @Description List the OAuth2 credentials of the tools and when their cached tokens expire; tokens are never returned
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/credentials [get]
+func (h *CredentialHandler) List(c *gin.Context) {
+	statuses := h.oauth2.Status()
+	if statuses == nil {
+		statuses = []credentials.TokenStatus{}
+	}
+	c.JSON(http.StatusOK, gin.H{"credentials": statuses})
+}
+
+// Refresh replaces the cached token of an OAuth2 credential, for instance
+// after its client secret was rotated
+// @Summary Refresh an OAuth2 credential
+// @Description Drop the cached access token and request a new one
+// @Tags admin
+// @Produce json
+// @Param name path string true "Credential name"
+// @Success 200 {object} credentials.TokenStatus
+// @Router /admin/credentials/{name}/refresh [post]
+func (h *CredentialHandler) Refresh(c *gin.Context) {
+	name := c.Param("name")
+	h.oauth2.Invalidate(name)
+	if _, err := h.oauth2.Token(c.Request.Context(), name); err != nil {
+		if errors.Is(err, credentials.ErrUnknownCredential) {
+			problem.Write(c, http.StatusNotFound, problem.NotFound, "Credential not found", name)
+			return
+		}
+		logrus.WithError(err).WithField("credential", name).Warn("Failed to refresh OAuth2 credential")
+		problem.Write(c, http.StatusServiceUnavailable, problem.ServiceUnavailable, "Failed to refresh credential", err.Error())
+		return
+	}
+
+	for _, status := range h.oauth2.Status() {
+		if status.Name == name {
+			c.JSON(http.StatusOK, status)
+			return
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// DryRunHandler runs agents on test prompts with stubbed tools
+type DryRunHandler struct {
+	chatService *services.ChatService
+	validator   *validator.Validate
+}
+
+// NewDryRunHandler creates a new dry-run handler
+func NewDryRunHandler(chatService *services.ChatService) *DryRunHandler {
+	return &DryRunHandler{
+		chatService: chatService,
+		validator:   newValidator(),
+	}
+}
+
+// Run answers a prompt with the agent, stubbing its tools with the canned
+// responses of the request; no session or message is saved
+func (h *DryRunHandler) Run(c *gin.Context) {
+	agentID := c.Param("id")
+
+	var req models.DryRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if err := h.validator.Struct(&req); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	result, err := h.chatService.DryRun(c.Request.Context(), agentID, &req)
+	if err != nil {
+		logrus.WithError(err).WithField("agent_id", agentID).Error("Agent dry run failed")
+		writeChatError(c, "Agent dry run failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/channels/email"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxInboundEmailSize bounds the webhook request body
+const maxInboundEmailSize = 10 << 20
+
+// EmailReceiver turns received emails into chat turns
+type EmailReceiver interface {
+	Receive(ctx context.Context, r io.Reader) (*email.Receipt, error)
+}
+
+// EmailHandler receives emails from the mail provider's inbound webhook
+type EmailHandler struct {
+	receiver EmailReceiver
+	secret   string
+}
+
+// NewEmailHandler creates a new email handler. Requests must carry secret as
+// a bearer token or in the token query parameter.
+func NewEmailHandler(receiver EmailReceiver, secret string) *EmailHandler {
+	return &EmailHandler{
+		receiver: receiver,
+		secret:   secret,
+	}
+}
+
+// Inbound receives an email
+// @Summary Receive an email
+// @Description Inbound webhook for the email channel. The body is the raw RFC 5322 email, or a multipart form with the raw email in the "email" or "body-mime" field. The agent's answer is sent back by email.
+// @Tags channels
+// @Accept message/rfc822
+// @Accept multipart/form-data
+// @Produce json
+// @Param token query string false "Webhook secret, when it is not sent as a bearer token"
+// @Success 202 {object} email.Receipt
+// @Success 200 {object} email.Receipt "The email was ignored"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /channels/email/inbound [post]
+func (h *EmailHandler) Inbound(c *gin.Context) {
+	if !h.authorized(c) {
+		problem.Write(c, http.StatusUnauthorized, problem.Unauthorized, "Invalid webhook secret", "")
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxInboundEmailSize)
+	raw, err := h.rawEmail(c)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidBody, "Failed to read email", err.Error())
+		return
+	}
+
+	receipt, err := h.receiver.Receive(c.Request.Context(), raw)
+	if errors.Is(err, email.ErrInvalidEmail) {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidBody, "Invalid email", err.Error())
+		return
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to receive email", err.Error())
+		return
+	}
+
+	status := http.StatusAccepted
+	if receipt.Status != email.StatusAccepted {
+		status = http.StatusOK
+	}
+	c.JSON(status, receipt)
+}
+
+// authorized checks the webhook secret
+func (h *EmailHandler) authorized(c *gin.Context) bool {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" || token == c.GetHeader("Authorization") {
+		token = c.Query("token")
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.secret)) == 1
+}
+
+// rawEmail returns the raw email of a request, which providers post either
+// as the body or as a form field
+func (h *EmailHandler) rawEmail(c *gin.Context) (io.Reader, error) {
+	if !strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		return c.Request.Body, nil
+	}
+	for _, field := range []string{"email", "body-mime"} {
+		if value := c.PostForm(field); value != "" {
+			return strings.NewReader(value), nil
+		}
+		if file, err := c.FormFile(field); err == nil {
+			f, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			data, err := io.ReadAll(f)
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(data), nil
+		}
+	}
+	return nil, errors.New(`the form has no "email" or "body-mime" field`)
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// newValidator creates a validator that reports fields by their JSON names
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
+
+// respondError writes a problem response, optionally listing rejected fields
+func respondError(c *gin.Context, status int, code problem.Code, title string, fields ...problem.FieldError) {
+	p := problem.New(status, code, title)
+	p.Fields = fields
+	problem.Send(c, p)
+}
+
+// respondBindError reports a request body that could not be decoded
+func respondBindError(c *gin.Context, err error) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		respondError(c, http.StatusBadRequest, problem.InvalidBody, "Request body is empty")
+	case errors.As(err, &syntaxErr):
+		respondError(c, http.StatusBadRequest, problem.InvalidBody,
+			fmt.Sprintf("Invalid JSON at offset %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		respondError(c, http.StatusBadRequest, problem.ValidationFailed, "Validation failed", problem.FieldError{
+			Name:   typeErr.Field,
+			Reason: fmt.Sprintf("must be of type %s", jsonType(typeErr.Type)),
+		})
+	default:
+		respondValidationError(c, err)
+	}
+}
+
+// respondValidationError reports a request that failed struct validation
+func respondValidationError(c *gin.Context, err error) {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		respondError(c, http.StatusBadRequest, problem.InvalidBody, err.Error())
+		return
+	}
+	respondError(c, http.StatusBadRequest, problem.ValidationFailed, "Validation failed", fieldErrors(validationErrs)...)
+}
+
+// respondToolValidationError reports tool arguments rejected by the tool's
+// parameter schema
+func respondToolValidationError(c *gin.Context, validation []models.ValidationError) {
+	fields := make([]problem.FieldError, len(validation))
+	for i, v := range validation {
+		fields[i] = problem.FieldError{Name: "arguments", Reason: v.Message}
+		if v.Parameter != "" {
+			fields[i].Name += "." + v.Parameter
+		}
+	}
+	respondError(c, http.StatusBadRequest, problem.ValidationFailed, "Tool arguments failed validation", fields...)
+}
+
+// fieldErrors converts validator errors into field errors named by their JSON
+// path, e.g. "tags[2]" or "context_config.max_messages"
+func fieldErrors(errs validator.ValidationErrors) []problem.FieldError {
+	fields := make([]problem.FieldError, len(errs))
+	for i, fe := range errs {
+		// Drop the struct name the namespace starts with
+		_, name, found := strings.Cut(fe.Namespace(), ".")
+		if !found {
+			name = fe.Field()
+		}
+		fields[i] = problem.FieldError{Name: name, Reason: validationReason(fe)}
+	}
+	return fields
+}
+
+// validationReason describes a failed validation rule in words
+func validationReason(fe validator.FieldError) string {
+	unit := "characters"
+	switch fe.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = "items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		unit = ""
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "min", "gte":
+		if unit == "" {
+			return "must be at least " + fe.Param()
+		}
+		return fmt.Sprintf("must have at least %s %s", fe.Param(), unit)
+	case "max", "lte":
+		if unit == "" {
+			return "must be at most " + fe.Param()
+		}
+		return fmt.Sprintf("must have at most %s %s", fe.Param(), unit)
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "len":
+		return fmt.Sprintf("must have exactly %s %s", fe.Param(), unit)
+	case "url", "http_url":
+		return "must be a valid URL"
+	case "email":
+		return "must be a valid email address"
+	case "uuid", "uuid4":
+		return "must be a valid UUID"
+	default:
+		if fe.Param() != "" {
+			return fmt.Sprintf("failed the %s=%s rule", fe.Tag(), fe.Param())
+		}
+		return fmt.Sprintf("failed the %s rule", fe.Tag())
+	}
+}
+
+// jsonType names a Go type the way JSON clients think about it
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Ptr:
+		return jsonType(t.Elem())
+	default:
+		return t.String()
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// maxFlagNameLength bounds the names of flags set at runtime
+const maxFlagNameLength = 100
+
+// FeatureFlagHandler handles admin requests for feature flags
+type FeatureFlagHandler struct {
+	flags     *services.FeatureFlags
+	validator *validator.Validate
+}
+
+// NewFeatureFlagHandler creates a new feature flag handler
+func NewFeatureFlagHandler(flags *services.FeatureFlags) *FeatureFlagHandler {
+	return &FeatureFlagHandler{
+		flags:     flags,
+		validator: newValidator(),
+	}
+}
+
+// List returns every flag with where it is defined
+// @Summary List feature flags
+// @Description List the feature flags from the config and those set at runtime
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/flags [get]
+func (h *FeatureFlagHandler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"flags": h.flags.List(c.Request.Context()),
+	})
+}
+
+// Set creates or replaces a flag at runtime, overriding the config's
+// @Summary Set a feature flag
+// @Description Turn a capability on for everyone, for some agents or for a percentage of sessions
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param name path string true "Flag name"
+// @Param flag body models.SetFeatureFlagRequest true "Flag"
+// @Success 200 {object} models.FeatureFlag
+// @Router /admin/flags/{name} [put]
+func (h *FeatureFlagHandler) Set(c *gin.Context) {
+	name := c.Param("name")
+	if name == "" || len(name) > maxFlagNameLength {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Invalid flag name", name)
+		return
+	}
+
+	var req models.SetFeatureFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if err := h.validator.Struct(&req); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	flag := req.ToFeatureFlag(name)
+	if err := h.flags.Set(c.Request.Context(), flag); err != nil {
+		logrus.WithError(err).WithField("flag", name).Error("Failed to set feature flag")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to set feature flag", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, flag)
+}
+
+// Delete removes a flag set at runtime; a flag from the config reverts to it
+// @Summary Reset a feature flag
+// @Description Remove a flag set at runtime, reverting to the config's flag of the same name if any
+// @Tags admin
+// @Param name path string true "Flag name"
+// @Success 204
+// @Router /admin/flags/{name} [delete]
+func (h *FeatureFlagHandler) Delete(c *gin.Context) {
+	name := c.Param("name")
+	removed, err := h.flags.Reset(c.Request.Context(), name)
+	if err != nil {
+		logrus.WithError(err).WithField("flag", name).Error("Failed to reset feature flag")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to reset feature flag", "")
+		return
+	}
+	if !removed {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "No flag of this name was set at runtime", name)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
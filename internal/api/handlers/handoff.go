@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// HandoffHandler transfers sessions between agents
+type HandoffHandler struct {
+	chatService *services.ChatService
+	validator   *validator.Validate
+}
+
+// NewHandoffHandler creates a new handoff handler
+func NewHandoffHandler(chatService *services.ChatService) *HandoffHandler {
+	return &HandoffHandler{
+		chatService: chatService,
+		validator:   newValidator(),
+	}
+}
+
+// Transfer reassigns a session to another agent, keeping its history
+func (h *HandoffHandler) Transfer(c *gin.Context) {
+	sessionID := c.Param("id")
+	if sessionID == "" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
+		return
+	}
+
+	var req models.TransferSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if err := h.validator.Struct(&req); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	response, err := h.chatService.Transfer(c.Request.Context(), sessionID, &req)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"session_id": sessionID,
+			"agent_id":   req.AgentID,
+		}).Error("Failed to transfer session")
+		writeChatError(c, "Failed to transfer session", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
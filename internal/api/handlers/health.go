@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"agent-server/internal/jobs"
+	"agent-server/internal/llm"
+	"agent-server/internal/queue"
+	"agent-server/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Component status values
+const (
+	ComponentOK       = "ok"
+	ComponentFailing  = "failing"
+	ComponentDisabled = "disabled"
+)
+
+// probeTimeout bounds the time a readiness check may take in total
+const probeTimeout = 5 * time.Second
+
+// ComponentStatus is the result of a single readiness check
+type ComponentStatus struct {
+	Status    string            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	LatencyMS int64             `json:"latency_ms"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// ProbeResponse is returned by the readiness and liveness probes
+type ProbeResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components,omitempty"`
+	CheckedAt  time.Time                  `json:"checked_at"`
+}
+
+// HealthHandler serves Kubernetes-style liveness and readiness probes
+type HealthHandler struct {
+	repo        storage.Repository
+	llmRegistry *llm.Registry
+	jobRunner   *jobs.Runner
+	jobsEnabled bool
+	queue       queue.Broker
+}
+
+// NewHealthHandler creates a new health handler. The jobs component is only
+// required to be running when jobsEnabled is set.
+func NewHealthHandler(repo storage.Repository, llmRegistry *llm.Registry, jobRunner *jobs.Runner, jobsEnabled bool) *HealthHandler {
+	return &HealthHandler{
+		repo:        repo,
+		llmRegistry: llmRegistry,
+		jobRunner:   jobRunner,
+		jobsEnabled: jobsEnabled,
+	}
+}
+
+// SetQueue adds the chat queue of distributed mode to the readiness checks
+func (h *HealthHandler) SetQueue(broker queue.Broker) {
+	h.queue = broker
+}
+
+// Live reports that the process is up and serving requests. It performs no
+// dependency checks so a slow database never gets the pod restarted.
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, ProbeResponse{Status: ComponentOK, CheckedAt: time.Now()})
+}
+
+// Ready checks the database, migrations, LLM providers and background
+// workers. It returns 503 when any component is failing.
+func (h *HealthHandler) Ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), probeTimeout)
+	defer cancel()
+
+	components := map[string]ComponentStatus{
+		"database":   h.check(ctx, h.repo.Ping),
+		"migrations": h.check(ctx, h.repo.CheckSchema),
+		"providers":  h.checkProviders(ctx),
+		"jobs":       h.checkJobs(),
+		"queue":      {Status: ComponentDisabled},
+	}
+	if h.queue != nil {
+		components["queue"] = h.check(ctx, h.queue.Ping)
+	}
+
+	response := ProbeResponse{
+		Status:     ComponentOK,
+		Components: components,
+		CheckedAt:  time.Now(),
+	}
+	code := http.StatusOK
+	for _, component := range components {
+		if component.Status == ComponentFailing {
+			response.Status = ComponentFailing
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	c.JSON(code, response)
+}
+
+// check times a single error-returning probe
+func (h *HealthHandler) check(ctx context.Context, probe func(context.Context) error) ComponentStatus {
+	start := time.Now()
+	err := probe(ctx)
+	status := ComponentStatus{Status: ComponentOK, LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		status.Status = ComponentFailing
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// checkProviders probes all registered providers concurrently; at least one
+// must be reachable
+func (h *HealthHandler) checkProviders(ctx context.Context) ComponentStatus {
+	start := time.Now()
+	names := h.llmRegistry.List()
+	sort.Strings(names)
+
+	results := make([]bool, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		provider, exists := h.llmRegistry.Get(name)
+		if !exists {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, provider llm.Provider) {
+			defer wg.Done()
+			results[i] = provider.IsAvailable(ctx)
+		}(i, provider)
+	}
+	wg.Wait()
+
+	status := ComponentStatus{
+		Status:    ComponentFailing,
+		LatencyMS: time.Since(start).Milliseconds(),
+		Details:   make(map[string]string, len(names)),
+	}
+	for i, name := range names {
+		if results[i] {
+			status.Details[name] = ComponentOK
+			status.Status = ComponentOK
+		} else {
+			status.Details[name] = "unreachable"
+		}
+	}
+	if status.Status == ComponentFailing {
+		status.Error = "no LLM provider is reachable"
+	}
+	return status
+}
+
+// checkJobs verifies the background worker pool is running when enabled
+func (h *HealthHandler) checkJobs() ComponentStatus {
+	switch {
+	case !h.jobsEnabled:
+		return ComponentStatus{Status: ComponentDisabled}
+	case h.jobRunner == nil || !h.jobRunner.Running():
+		return ComponentStatus{Status: ComponentFailing, Error: "job runner is not running"}
+	default:
+		// Only the leader releases the jobs of lost workers
+		return ComponentStatus{Status: ComponentOK, Details: map[string]string{
+			"leader": strconv.FormatBool(h.jobRunner.Leader()),
+		}}
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// JobHandler handles admin requests for the job outbox
+type JobHandler struct {
+	repo storage.JobRepository
+}
+
+// NewJobHandler creates a new job handler
+func NewJobHandler(repo storage.JobRepository) *JobHandler {
+	return &JobHandler{
+		repo: repo,
+	}
+}
+
+// List retrieves a paginated list of jobs, optionally filtered by status
+func (h *JobHandler) List(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", models.JobStatusPending, models.JobStatusRunning, models.JobStatusSucceeded, models.JobStatusFailed, models.JobStatusDead:
+	default:
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Invalid job status", status)
+		return
+	}
+
+	// Parse pagination parameters
+	page := 1
+	pageSize := 50
+
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			page = parsed
+		}
+	}
+
+	if ps := c.Query("page_size"); ps != "" {
+		if parsed, err := strconv.Atoi(ps); err == nil && parsed > 0 && parsed <= 200 {
+			pageSize = parsed
+		}
+	}
+
+	offset := (page - 1) * pageSize
+
+	jobs, total, err := h.repo.List(c.Request.Context(), status, pageSize, offset)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list jobs")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve jobs", "")
+		return
+	}
+
+	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
+
+	c.JSON(http.StatusOK, models.JobList{
+		Jobs:       jobs,
+		TotalCount: total,
+		Page:       page,
+		PageSize:   pageSize,
+		HasMore:    page < int(totalPages),
+	})
+}
+
+// GetByID retrieves a single job
+func (h *JobHandler) GetByID(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Job ID is required", "")
+		return
+	}
+
+	job, err := h.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		logrus.WithError(err).WithField("job_id", id).Error("Failed to get job")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve job", "")
+		return
+	}
+
+	if job == nil {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Job not found", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// Requeue resets a failed or dead-lettered job so it runs again
+func (h *JobHandler) Requeue(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Job ID is required", "")
+		return
+	}
+
+	job, err := h.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		logrus.WithError(err).WithField("job_id", id).Error("Failed to get job")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve job", "")
+		return
+	}
+
+	if job == nil {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Job not found", "")
+		return
+	}
+
+	if job.Status != models.JobStatusFailed && job.Status != models.JobStatusDead {
+		problem.Write(c, http.StatusConflict, problem.Conflict, "Only failed or dead jobs can be requeued", job.Status)
+		return
+	}
+
+	if err := h.repo.Requeue(c.Request.Context(), id); err != nil {
+		logrus.WithError(err).WithField("job_id", id).Error("Failed to requeue job")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to requeue job", err.Error())
+		return
+	}
+
+	job, err = h.repo.GetByID(c.Request.Context(), id)
+	if err != nil || job == nil {
+		c.JSON(http.StatusOK, gin.H{"id": id, "status": models.JobStatusPending})
+		return
+	}
+
+	logrus.WithField("job_id", id).Info("Job requeued")
+	c.JSON(http.StatusOK, job)
+}
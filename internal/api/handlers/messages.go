@@ -4,7 +4,9 @@ import (
 	"net/http"
 	"strconv"
 
+	"agent-server/internal/api/problem"
 	"agent-server/internal/models"
+	"agent-server/internal/services"
 	"agent-server/internal/storage"
 
 	"github.com/gin-gonic/gin"
@@ -14,35 +16,45 @@ import (
 
 // MessageHandler handles message-related requests
 type MessageHandler struct {
-	repo      storage.MessageRepository
-	validator *validator.Validate
+	repo        storage.MessageRepository
+	chatService *services.ChatService
+	validator   *validator.Validate
 }
 
-// NewMessageHandler creates a new message handler
-func NewMessageHandler(repo storage.MessageRepository) *MessageHandler {
+// NewMessageHandler creates a new message handler; chatService answers
+// messages created with generate set
+func NewMessageHandler(repo storage.MessageRepository, chatService *services.ChatService) *MessageHandler {
 	return &MessageHandler{
-		repo:      repo,
-		validator: validator.New(),
+		repo:        repo,
+		chatService: chatService,
+		validator:   newValidator(),
 	}
 }
 
-// Create creates a new message in a session
+// Create appends a message to a session without generating a reply, e.g. to
+// queue multi-part user input. With generate set, a user message is answered
+// like a chat request and the chat response is returned.
 func (h *MessageHandler) Create(c *gin.Context) {
 	sessionID := c.Param("id")
 	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
 		return
 	}
 
 	var req models.CreateMessageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	// Validate request
 	if err := h.validator.Struct(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		respondValidationError(c, err)
+		return
+	}
+
+	if req.Generate {
+		h.createAndGenerate(c, sessionID, &req)
 		return
 	}
 
@@ -52,9 +64,10 @@ func (h *MessageHandler) Create(c *gin.Context) {
 	// Save to database
 	if err := h.repo.Create(c.Request.Context(), message); err != nil {
 		logrus.WithError(err).Error("Failed to create message")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create message"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to create message", "")
 		return
 	}
+	h.chatService.RecordActivity(c.Request.Context(), message)
 
 	logrus.WithFields(logrus.Fields{
 		"message_id": message.ID,
@@ -65,11 +78,62 @@ func (h *MessageHandler) Create(c *gin.Context) {
 	c.JSON(http.StatusCreated, message)
 }
 
-// ListBySession retrieves a paginated list of messages for a session
+// createAndGenerate saves a user message and answers it like a chat request
+func (h *MessageHandler) createAndGenerate(c *gin.Context, sessionID string, req *models.CreateMessageRequest) {
+	if req.Role != "user" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Only user messages can be answered", "generate requires role \"user\"")
+		return
+	}
+
+	response, err := h.chatService.Chat(c.Request.Context(), &services.ChatRequest{
+		SessionID: sessionID,
+		Message:   req.Content,
+		Metadata:  req.Metadata,
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to answer message")
+		writeChatError(c, "Chat request failed", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// CreateNote appends a system note to a session, hidden from transcripts
+// unless visible is set, to steer the conversation from outside
+func (h *MessageHandler) CreateNote(c *gin.Context) {
+	sessionID := c.Param("id")
+	if sessionID == "" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
+		return
+	}
+
+	var req models.CreateNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if err := h.validator.Struct(&req); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	note, err := h.chatService.AddNote(c.Request.Context(), sessionID, &req)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to add note")
+		writeChatError(c, "Failed to add note", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, note)
+}
+
+// ListBySession retrieves a paginated list of messages for a session.
+// Hidden messages are left out unless include_hidden=true.
 func (h *MessageHandler) ListBySession(c *gin.Context) {
 	sessionID := c.Param("id")
 	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
 		return
 	}
 
@@ -89,13 +153,27 @@ func (h *MessageHandler) ListBySession(c *gin.Context) {
 		}
 	}
 
+	if after := c.Query("after"); after != "" {
+		cursor, err := strconv.ParseInt(after, 10, 64)
+		if err != nil || cursor < 0 {
+			problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Invalid cursor", "after must be a message sequence number")
+			return
+		}
+		h.listAfter(c, sessionID, cursor, pageSize)
+		return
+	}
+
 	offset := (page - 1) * pageSize
 
 	// Get messages from database
-	messages, total, err := h.repo.ListBySessionID(c.Request.Context(), sessionID, pageSize, offset)
+	list := h.repo.ListVisibleBySessionID
+	if includeHidden(c) {
+		list = h.repo.ListBySessionID
+	}
+	messages, total, err := list(c.Request.Context(), sessionID, pageSize, offset)
 	if err != nil {
 		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to list messages")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve messages"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve messages", "")
 		return
 	}
 
@@ -115,22 +193,65 @@ func (h *MessageHandler) ListBySession(c *gin.Context) {
 	for i, msg := range messages {
 		response.Messages[i] = *msg
 	}
+	if hasMore && len(messages) > 0 {
+		response.NextCursor = messages[len(messages)-1].Sequence
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// listAfter serves a page of messages following the cursor sequence. Unlike
+// offsets, cursors stay stable while new messages are appended.
+func (h *MessageHandler) listAfter(c *gin.Context, sessionID string, cursor int64, pageSize int) {
+	// Fetch one extra message to learn whether another page follows
+	list := h.repo.ListVisibleAfter
+	if includeHidden(c) {
+		list = h.repo.ListAfter
+	}
+	messages, err := list(c.Request.Context(), sessionID, cursor, pageSize+1)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to list messages")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve messages", "")
+		return
+	}
+
+	hasMore := len(messages) > pageSize
+	if hasMore {
+		messages = messages[:pageSize]
+	}
+
+	response := models.MessageList{
+		Messages: make([]models.Message, len(messages)),
+		PageSize: pageSize,
+		HasMore:  hasMore,
+	}
+	for i, msg := range messages {
+		response.Messages[i] = *msg
+	}
+	if hasMore {
+		response.NextCursor = messages[len(messages)-1].Sequence
+	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// includeHidden reports whether the request asks for hidden messages
+func includeHidden(c *gin.Context) bool {
+	return c.Query("include_hidden") == "true"
+}
+
 // DeleteBySession deletes all messages in a session
 func (h *MessageHandler) DeleteBySession(c *gin.Context) {
 	sessionID := c.Param("id")
 	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
 		return
 	}
 
 	// Delete all messages for the session
 	if err := h.repo.DeleteBySessionID(c.Request.Context(), sessionID); err != nil {
 		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to delete messages")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete messages"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to delete messages", "")
 		return
 	}
 
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/llm/mock"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageHandler_CreateDraftsThenGenerate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "drafts", Provider: mock.Name, Model: "mock", Enabled: true}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	registry := llm.NewRegistry()
+	registry.Register(mock.NewProvider(mock.Options{ReplyWords: 4}))
+	chatService := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+
+	handler := NewMessageHandler(repo.Message(), chatService)
+	router := gin.New()
+	router.POST("/sessions/:id/messages", handler.Create)
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/sessions/"+session.ID+"/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Drafts are saved without a reply
+	assert.Equal(t, http.StatusCreated, post(`{"role":"user","content":"Part one"}`).Code)
+	assert.Equal(t, http.StatusCreated, post(`{"role":"user","content":"Part two","generate":false}`).Code)
+	_, total, err := repo.Message().ListBySessionID(ctx, session.ID, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	// Only user messages can be answered
+	w := post(`{"role":"assistant","content":"Hi","generate":true}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// The last part asks for the reply
+	w = post(`{"role":"user","content":"Part three","generate":true}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var response services.ChatResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotEmpty(t, response.AssistantMessageID)
+	assert.Equal(t, "Mock reply: the agent", response.Response)
+
+	messages, _, err := repo.Message().ListBySessionID(ctx, session.ID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+	assert.Equal(t, []string{"Part one", "Part two", "Part three", "Mock reply: the agent"},
+		[]string{messages[0].Content, messages[1].Content, messages[2].Content, messages[3].Content})
+}
+
+func TestMessageHandler_Notes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "notes", Provider: mock.Name, Model: "mock"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+	require.NoError(t, repo.Message().Create(ctx, &models.Message{SessionID: session.ID, Role: "user", Content: "Hi"}))
+
+	chatService := services.NewChatService(repo, llm.NewRegistry(), contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+	handler := NewMessageHandler(repo.Message(), chatService)
+	router := gin.New()
+	router.POST("/sessions/:id/notes", handler.CreateNote)
+	router.GET("/sessions/:id/messages", handler.ListBySession)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/sessions/"+session.ID+"/notes", `{"content":"The user has upgraded to premium"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var note models.Message
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &note))
+	assert.Equal(t, "system", note.Role)
+	assert.True(t, note.Hidden)
+	assert.Equal(t, true, note.Metadata["note"])
+
+	w = do(http.MethodPost, "/sessions/missing/notes", `{"content":"Hello"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	count := func(query string) int {
+		w := do(http.MethodGet, "/sessions/"+session.ID+"/messages"+query, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var list models.MessageList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		return len(list.Messages)
+	}
+	assert.Equal(t, 1, count(""))
+	assert.Equal(t, 2, count("?include_hidden=true"))
+	assert.Equal(t, 1, count("?after=0"))
+	assert.Equal(t, 2, count("?after=0&include_hidden=true"))
+}
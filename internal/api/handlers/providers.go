@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/llm"
+	"agent-server/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// ProviderHandler reports the LLM providers and downloads models onto them
+type ProviderHandler struct {
+	llmRegistry *llm.Registry
+	puller      *services.ModelPuller
+	validator   *validator.Validate
+}
+
+// NewProviderHandler creates a new provider handler
+func NewProviderHandler(llmRegistry *llm.Registry, puller *services.ModelPuller) *ProviderHandler {
+	return &ProviderHandler{
+		llmRegistry: llmRegistry,
+		puller:      puller,
+		validator:   newValidator(),
+	}
+}
+
+// ProviderInfo describes a registered provider
+type ProviderInfo struct {
+	Name          string `json:"name"`
+	Available     bool   `json:"available"`
+	SupportsTools bool   `json:"supports_tools"`
+	CanPull       bool   `json:"can_pull"` // whether models can be downloaded onto it
+}
+
+// PullModelRequest asks for a model to be downloaded onto a provider
+type PullModelRequest struct {
+	Model string `json:"model" validate:"required,max=200"`
+}
+
+// List returns the registered providers, probing their availability
+func (h *ProviderHandler) List(c *gin.Context) {
+	names := h.llmRegistry.List()
+	sort.Strings(names)
+
+	providers := make([]ProviderInfo, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		provider, _ := h.llmRegistry.Get(name)
+		providers[i] = ProviderInfo{
+			Name:          name,
+			SupportsTools: llm.SupportsTools(provider),
+			CanPull:       h.puller.CanPull(name),
+		}
+		wg.Add(1)
+		go func(info *ProviderInfo, provider llm.Provider) {
+			defer wg.Done()
+			info.Available = provider.IsAvailable(c.Request.Context())
+		}(&providers[i], provider)
+	}
+	wg.Wait()
+
+	c.JSON(http.StatusOK, gin.H{"providers": providers})
+}
+
+// ListPulls returns the model downloads on a provider, most recent first
+func (h *ProviderHandler) ListPulls(c *gin.Context) {
+	name := c.Param("name")
+	if _, ok := h.llmRegistry.Get(name); !ok {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Provider not found", name)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pulls": h.puller.List(name)})
+}
+
+// Pull starts downloading a model onto a provider; progress is polled with
+// ListPulls
+func (h *ProviderHandler) Pull(c *gin.Context) {
+	name := c.Param("name")
+
+	var req PullModelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if err := h.validator.Struct(&req); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	pull, err := h.puller.Pull(name, req.Model)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrProviderUnavailable):
+			problem.Write(c, http.StatusNotFound, problem.NotFound, "Provider not found", name)
+		case errors.Is(err, services.ErrPullUnsupported):
+			problem.Write(c, http.StatusConflict, problem.Conflict, "Provider cannot pull models", err.Error())
+		default:
+			logrus.WithError(err).WithField("provider", name).Error("Failed to pull model")
+			problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to pull model", err.Error())
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, pull)
+}
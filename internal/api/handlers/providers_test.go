@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"agent-server/internal/api/problem"
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/llm/mock"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pullingProvider lacks every model until it has been pulled
+type pullingProvider struct {
+	*mock.Provider
+
+	mu     sync.Mutex
+	pulled map[string]bool
+}
+
+func (p *pullingProvider) Name() string {
+	return "ollama"
+}
+
+func (p *pullingProvider) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.mu.Lock()
+	pulled := p.pulled[req.Model]
+	p.mu.Unlock()
+	if !pulled {
+		return nil, &llm.Error{Provider: "ollama", StatusCode: http.StatusNotFound, Kind: llm.ErrModelNotFound,
+			Message: `model "` + req.Model + `" not found, try pulling it first`}
+	}
+	return p.Provider.Chat(ctx, req)
+}
+
+func (p *pullingProvider) Pull(ctx context.Context, model string, progress func(llm.PullProgress)) error {
+	progress(llm.PullProgress{Status: "pulling manifest"})
+	progress(llm.PullProgress{Status: "pulling layer", Completed: 10, Total: 10})
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pulled[model] = true
+	return nil
+}
+
+func TestProviderHandler_RemediatesMissingModels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "local", Provider: "ollama", Model: "llama3", Enabled: true}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	registry := llm.NewRegistry()
+	registry.Register(&pullingProvider{Provider: mock.NewProvider(mock.Options{ReplyWords: 2}), pulled: map[string]bool{}})
+	registry.Register(mock.NewProvider(mock.Options{}))
+	puller := services.NewModelPuller(registry, slog.Default())
+	chatService := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+
+	chatHandler := NewChatHandler(chatService, nil, slog.Default())
+	providerHandler := NewProviderHandler(registry, puller)
+	router := gin.New()
+	router.POST("/sessions/:id/chat", chatHandler.Chat)
+	router.GET("/providers", providerHandler.List)
+	router.GET("/providers/:name/pulls", providerHandler.ListPulls)
+	router.POST("/providers/:name/pulls", providerHandler.Pull)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	chat := func() (int, *problem.Problem) {
+		w := do(http.MethodPost, "/sessions/"+session.ID+"/chat", `{"message":"hi"}`)
+		var p problem.Problem
+		json.Unmarshal(w.Body.Bytes(), &p)
+		return w.Code, &p
+	}
+	pulls := func() []services.ModelPull {
+		var response struct {
+			Pulls []services.ModelPull `json:"pulls"`
+		}
+		w := do(http.MethodGet, "/providers/ollama/pulls", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Pulls
+	}
+
+	w := do(http.MethodGet, "/providers", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `{"name":"ollama","available":true,"supports_tools":true,"can_pull":true}`)
+	assert.Contains(t, w.Body.String(), `"name":"mock","available":true,"supports_tools":true,"can_pull":false`)
+
+	// Without a puller the failure is only classified
+	status, p := chat()
+	assert.Equal(t, http.StatusBadGateway, status)
+	assert.Equal(t, problem.ModelNotFound, p.Code)
+	assert.Nil(t, p.Remediation)
+
+	// With one, the client is told how to pull the model
+	chatService.SetModelPuller(puller, false)
+	status, p = chat()
+	assert.Equal(t, http.StatusBadGateway, status)
+	require.NotNil(t, p.Remediation)
+	assert.Equal(t, "pull_model", p.Remediation.Action)
+	assert.Equal(t, http.MethodPost, p.Remediation.Method)
+	assert.Equal(t, "/api/v1/providers/ollama/pulls", p.Remediation.Href)
+	assert.Equal(t, "llama3", p.Remediation.Body["model"])
+	assert.Empty(t, pulls())
+
+	// Auto-pull starts the download; the chat succeeds once it is done
+	chatService.SetModelPuller(puller, true)
+	status, p = chat()
+	assert.Equal(t, http.StatusBadGateway, status)
+	require.NotNil(t, p.Remediation)
+	assert.Equal(t, "await_pull", p.Remediation.Action)
+	require.Eventually(t, func() bool {
+		list := pulls()
+		return len(list) == 1 && list[0].Status == services.PullCompleted
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(10), pulls()[0].Completed)
+	status, _ = chat()
+	assert.Equal(t, http.StatusOK, status)
+
+	// Pulls are started explicitly on providers that support them
+	w = do(http.MethodPost, "/providers/ollama/pulls", `{"model":"mistral"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"pulling"`)
+	w = do(http.MethodPost, "/providers/mock/pulls", `{"model":"mistral"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = do(http.MethodPost, "/providers/openai/pulls", `{"model":"gpt-4"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = do(http.MethodPost, "/providers/ollama/pulls", `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
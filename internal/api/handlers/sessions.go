@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"agent-server/internal/api/problem"
 	"agent-server/internal/models"
 	"agent-server/internal/storage"
 
@@ -24,7 +28,7 @@ func NewSessionHandler(sessionRepo storage.SessionRepository, agentRepo storage.
 	return &SessionHandler{
 		sessionRepo: sessionRepo,
 		agentRepo:   agentRepo,
-		validator:   validator.New(),
+		validator:   newValidator(),
 	}
 }
 
@@ -32,7 +36,7 @@ func NewSessionHandler(sessionRepo storage.SessionRepository, agentRepo storage.
 func (h *SessionHandler) Create(c *gin.Context) {
 	agentID := c.Param("id")
 	if agentID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Agent ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Agent ID is required", "")
 		return
 	}
 
@@ -40,24 +44,29 @@ func (h *SessionHandler) Create(c *gin.Context) {
 	agent, err := h.agentRepo.GetByID(c.Request.Context(), agentID)
 	if err != nil {
 		logrus.WithError(err).WithField("agent_id", agentID).Error("Failed to get agent")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve agent"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve agent", "")
 		return
 	}
 
 	if agent == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Agent not found", "")
+		return
+	}
+
+	if !agent.Enabled {
+		problem.Write(c, http.StatusConflict, problem.AgentDisabled, "Agent is disabled", agent.MaintenanceMessage)
 		return
 	}
 
 	var req models.CreateSessionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	// Validate request
 	if err := h.validator.Struct(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		respondValidationError(c, err)
 		return
 	}
 
@@ -67,7 +76,7 @@ func (h *SessionHandler) Create(c *gin.Context) {
 	// Save to database
 	if err := h.sessionRepo.Create(c.Request.Context(), session); err != nil {
 		logrus.WithError(err).Error("Failed to create session")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to create session", "")
 		return
 	}
 
@@ -83,19 +92,19 @@ func (h *SessionHandler) Create(c *gin.Context) {
 func (h *SessionHandler) GetByID(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
 		return
 	}
 
 	session, err := h.sessionRepo.GetByID(c.Request.Context(), id)
 	if err != nil {
 		logrus.WithError(err).WithField("session_id", id).Error("Failed to get session")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve session"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve session", "")
 		return
 	}
 
 	if session == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Session not found", "")
 		return
 	}
 
@@ -106,19 +115,19 @@ func (h *SessionHandler) GetByID(c *gin.Context) {
 func (h *SessionHandler) Update(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
 		return
 	}
 
 	var req models.UpdateSessionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	// Validate request
 	if err := h.validator.Struct(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		respondValidationError(c, err)
 		return
 	}
 
@@ -126,12 +135,12 @@ func (h *SessionHandler) Update(c *gin.Context) {
 	session, err := h.sessionRepo.GetByID(c.Request.Context(), id)
 	if err != nil {
 		logrus.WithError(err).WithField("session_id", id).Error("Failed to get session for update")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve session"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve session", "")
 		return
 	}
 
 	if session == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Session not found", "")
 		return
 	}
 
@@ -141,7 +150,7 @@ func (h *SessionHandler) Update(c *gin.Context) {
 	// Save updated session
 	if err := h.sessionRepo.Update(c.Request.Context(), session); err != nil {
 		logrus.WithError(err).WithField("session_id", id).Error("Failed to update session")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update session"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to update session", "")
 		return
 	}
 
@@ -149,11 +158,53 @@ func (h *SessionHandler) Update(c *gin.Context) {
 	c.JSON(http.StatusOK, session)
 }
 
+// PatchVariables merges variables into a session's; a null value removes
+// the variable
+func (h *SessionHandler) PatchVariables(c *gin.Context) {
+	id := c.Param("id")
+
+	var req models.PatchSessionVariablesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if err := h.validator.Struct(&req); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	session, err := h.sessionRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", id).Error("Failed to get session for variables")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve session", "")
+		return
+	}
+	if session == nil {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Session not found", "")
+		return
+	}
+
+	session.MergeVariables(req.Variables)
+	if len(session.Variables) > models.MaxSessionVariables {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Too many session variables",
+			fmt.Sprintf("a session holds at most %d variables", models.MaxSessionVariables))
+		return
+	}
+
+	if err := h.sessionRepo.Update(c.Request.Context(), session); err != nil {
+		logrus.WithError(err).WithField("session_id", id).Error("Failed to update session variables")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to update session", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"variables": session.Variables})
+}
+
 // Delete deletes a session
 func (h *SessionHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
 		return
 	}
 
@@ -161,19 +212,19 @@ func (h *SessionHandler) Delete(c *gin.Context) {
 	session, err := h.sessionRepo.GetByID(c.Request.Context(), id)
 	if err != nil {
 		logrus.WithError(err).WithField("session_id", id).Error("Failed to get session for deletion")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve session"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve session", "")
 		return
 	}
 
 	if session == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Session not found", "")
 		return
 	}
 
 	// Delete session (this will also delete associated messages due to cascade)
 	if err := h.sessionRepo.Delete(c.Request.Context(), id); err != nil {
 		logrus.WithError(err).WithField("session_id", id).Error("Failed to delete session")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete session"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to delete session", "")
 		return
 	}
 
@@ -181,11 +232,37 @@ func (h *SessionHandler) Delete(c *gin.Context) {
 	c.JSON(http.StatusNoContent, nil)
 }
 
+// MarkRead resets the unread count of a session
+func (h *SessionHandler) MarkRead(c *gin.Context) {
+	id := c.Param("id")
+	session, err := h.sessionRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", id).Error("Failed to get session")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve session", "")
+		return
+	}
+	if session == nil {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Session not found", "")
+		return
+	}
+
+	now := time.Now()
+	if err := h.sessionRepo.MarkRead(c.Request.Context(), id, now); err != nil {
+		logrus.WithError(err).WithField("session_id", id).Error("Failed to mark session read")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to mark session read", "")
+		return
+	}
+
+	session.UnreadCount = 0
+	session.LastReadAt = &now
+	c.JSON(http.StatusOK, session)
+}
+
 // ListByAgent retrieves a paginated list of sessions for an agent
 func (h *SessionHandler) ListByAgent(c *gin.Context) {
 	agentID := c.Param("id")
 	if agentID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Agent ID is required"})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Agent ID is required", "")
 		return
 	}
 
@@ -193,12 +270,12 @@ func (h *SessionHandler) ListByAgent(c *gin.Context) {
 	agent, err := h.agentRepo.GetByID(c.Request.Context(), agentID)
 	if err != nil {
 		logrus.WithError(err).WithField("agent_id", agentID).Error("Failed to get agent")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve agent"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve agent", "")
 		return
 	}
 
 	if agent == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Agent not found", "")
 		return
 	}
 
@@ -218,13 +295,65 @@ func (h *SessionHandler) ListByAgent(c *gin.Context) {
 		}
 	}
 
-	offset := (page - 1) * pageSize
+	filter := &models.SessionFilter{
+		AgentID: agentID,
+		Tag:     c.Query("tag"),
+		Limit:   pageSize,
+		Offset:  (page - 1) * pageSize,
+	}
+
+	// Archived sessions are hidden unless requested
+	status := c.DefaultQuery("status", models.SessionStatusActive)
+	if archived := c.Query("archived"); archived != "" {
+		value, err := strconv.ParseBool(archived)
+		if err != nil {
+			problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Invalid archived filter", archived)
+			return
+		}
+		status = models.SessionStatusActive
+		if value {
+			status = models.SessionStatusArchived
+		}
+	}
+	switch status {
+	case models.SessionStatusActive, models.SessionStatusArchived:
+		filter.Status = status
+	case "all":
+	default:
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Invalid session status", status)
+		return
+	}
+
+	if starred := c.Query("starred"); starred != "" {
+		value, err := strconv.ParseBool(starred)
+		if err != nil {
+			problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Invalid starred filter", starred)
+			return
+		}
+		filter.Starred = &value
+	}
+
+	// metadata.<key>=<value> matches sessions by metadata
+	for param, values := range c.Request.URL.Query() {
+		key, ok := strings.CutPrefix(param, "metadata.")
+		if !ok {
+			continue
+		}
+		if key == "" {
+			problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Invalid metadata filter", param)
+			return
+		}
+		if filter.Metadata == nil {
+			filter.Metadata = make(map[string]string)
+		}
+		filter.Metadata[key] = values[0]
+	}
 
 	// Get sessions from database
-	sessions, total, err := h.sessionRepo.ListByAgentID(c.Request.Context(), agentID, pageSize, offset)
+	sessions, total, err := h.sessionRepo.List(c.Request.Context(), filter)
 	if err != nil {
 		logrus.WithError(err).WithField("agent_id", agentID).Error("Failed to list sessions")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sessions"})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve sessions", "")
 		return
 	}
 
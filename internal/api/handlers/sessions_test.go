@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionHandler_MetadataTagsAndStars(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+
+	agent := &models.Agent{Name: "inbox", Provider: "ollama", Model: "llama3", Enabled: true, SystemPrompt: "test"}
+	require.NoError(t, repo.Agent().Create(context.Background(), agent))
+
+	handler := NewSessionHandler(repo.Session(), repo.Agent())
+	router := gin.New()
+	router.POST("/agents/:id/sessions", handler.Create)
+	router.GET("/agents/:id/sessions", handler.ListByAgent)
+	router.PUT("/sessions/:id", handler.Update)
+	router.POST("/sessions/:id/read", handler.MarkRead)
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	create := func(body string) models.ChatSession {
+		w := send(http.MethodPost, "/agents/"+agent.ID+"/sessions", body)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var session models.ChatSession
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+		return session
+	}
+	list := func(query string) []string {
+		w := send(http.MethodGet, "/agents/"+agent.ID+"/sessions?"+query, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Sessions []models.ChatSession `json:"sessions"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		titles := make([]string, len(response.Sessions))
+		for i, session := range response.Sessions {
+			titles[i] = session.Title
+		}
+		return titles
+	}
+
+	refund := create(`{"title":"Refund","tags":["Billing"," urgent "],"metadata":{"customer":"acme"}}`)
+	assert.Equal(t, models.StringList{"billing", "urgent"}, refund.Tags)
+	assert.Equal(t, "acme", refund.Metadata["customer"])
+	assert.False(t, refund.Starred)
+	create(`{"title":"Login","tags":["support"],"metadata":{"customer":"globex"}}`)
+
+	w := send(http.MethodPut, "/sessions/"+refund.ID, `{"starred":true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var updated models.ChatSession
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.True(t, updated.Starred)
+	assert.Equal(t, models.StringList{"billing", "urgent"}, updated.Tags)
+
+	assert.Equal(t, []string{"Refund"}, list("tag=billing"))
+	assert.Equal(t, []string{"Refund"}, list("starred=true"))
+	assert.Equal(t, []string{"Login"}, list("starred=false"))
+	assert.Equal(t, []string{"Login"}, list("metadata.customer=globex"))
+	assert.Empty(t, list("archived=true"))
+	assert.Len(t, list("archived=false"), 2)
+
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/agents/"+agent.ID+"/sessions?starred=maybe", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/agents/"+agent.ID+"/sessions?metadata.=x", "").Code)
+
+	// Replies are unread until the session is marked read
+	require.NoError(t, repo.Session().RecordActivity(context.Background(), refund.ID, "assistant", time.Now(), true))
+	w = send(http.MethodPost, "/sessions/"+refund.ID+"/read", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var read models.ChatSession
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &read))
+	assert.Zero(t, read.UnreadCount)
+	assert.NotNil(t, read.LastReadAt)
+	assert.Equal(t, "assistant", read.LastMessageRole)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodPost, "/sessions/missing/read", "").Code)
+}
+
+func TestSessionHandler_PatchVariables(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+
+	agent := &models.Agent{Name: "support", Provider: "ollama", Model: "llama3", Enabled: true, SystemPrompt: "test"}
+	require.NoError(t, repo.Agent().Create(context.Background(), agent))
+
+	handler := NewSessionHandler(repo.Session(), repo.Agent())
+	router := gin.New()
+	router.POST("/agents/:id/sessions", handler.Create)
+	router.PATCH("/sessions/:id/variables", handler.PatchVariables)
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(http.MethodPost, "/agents/"+agent.ID+"/sessions", `{"variables":{"customer_id":"C-1042","plan":"pro"}}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var session models.ChatSession
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+	assert.Equal(t, "C-1042", session.Variables["customer_id"])
+
+	// Variables are merged; null removes one
+	w = send(http.MethodPatch, "/sessions/"+session.ID+"/variables", `{"variables":{"order_id":"O-7","plan":null}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	stored, err := repo.Session().GetByID(context.Background(), session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JSON{"customer_id": "C-1042", "order_id": "O-7"}, stored.Variables)
+
+	w = send(http.MethodPatch, "/sessions/"+session.ID+"/variables", `{"variables":{"":"empty name"}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send(http.MethodPatch, "/sessions/missing/variables", `{"variables":{"a":1}}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
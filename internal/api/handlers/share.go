@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/models"
+	"agent-server/internal/share"
+	"agent-server/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// sharedPage renders a shared transcript for browsers
+var sharedPage = template.Must(template.New("shared").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .Title}}{{.Title}}{{else}}Conversation with {{.AgentName}}{{end}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+.message { margin: 1rem 0; padding: .75rem 1rem; border-radius: .5rem; background: #f4f4f5; }
+.user { background: #e0ecff; }
+.role { font-size: .75rem; font-weight: 600; text-transform: uppercase; color: #666; }
+.content { white-space: pre-wrap; }
+details { margin-top: .5rem; font-size: .875rem; }
+pre { overflow-x: auto; background: #fff; padding: .5rem; }
+footer { font-size: .75rem; color: #666; }
+</style>
+</head>
+<body>
+<h1>{{if .Title}}{{.Title}}{{else}}Conversation with {{.AgentName}}{{end}}</h1>
+{{range .Messages}}<div class="message {{.Role}}">
+<div class="role">{{if eq .Role "assistant"}}{{$.AgentName}}{{else}}{{.Role}}{{end}}</div>
+<div class="content">{{.Content}}</div>
+{{range .ToolCalls}}<details><summary>{{.ToolName}}{{if .Error}} (failed){{end}}</summary>
+<pre>{{printf "%v" .Arguments}}</pre>{{if .Result}}<pre>{{printf "%v" .Result}}</pre>{{end}}{{if .Error}}<pre>{{.Error}}</pre>{{end}}
+</details>{{end}}
+</div>
+{{end}}<footer>Shared read-only transcript, available until {{.ExpiresAt.UTC.Format "2006-01-02 15:04 MST"}}</footer>
+</body>
+</html>
+`))
+
+// ShareHandler creates share links for sessions and serves the transcripts
+// they grant access to
+type ShareHandler struct {
+	repo      storage.Repository
+	signer    *share.Signer
+	baseURL   string
+	expiry    time.Duration
+	maxExpiry time.Duration
+	validator *validator.Validate
+}
+
+// NewShareHandler creates a new share handler. Links are built from baseURL
+// and are valid for expiry unless a request asks for another duration up to
+// maxExpiry.
+func NewShareHandler(repo storage.Repository, signer *share.Signer, baseURL string, expiry, maxExpiry time.Duration) *ShareHandler {
+	return &ShareHandler{
+		repo:      repo,
+		signer:    signer,
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		expiry:    expiry,
+		maxExpiry: maxExpiry,
+		validator: newValidator(),
+	}
+}
+
+// Create issues a signed read-only link to a session's transcript
+func (h *ShareHandler) Create(c *gin.Context) {
+	if h.signer == nil {
+		problem.Write(c, http.StatusServiceUnavailable, problem.ServiceUnavailable, "Sharing is not available", "")
+		return
+	}
+
+	sessionID := c.Param("id")
+	if sessionID == "" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
+		return
+	}
+
+	var req models.CreateShareRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+	}
+	if err := h.validator.Struct(&req); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	expiry := h.expiry
+	if req.ExpiresIn > 0 {
+		expiry = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if expiry > h.maxExpiry {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Expiry is too long",
+			"Share links are valid for at most "+h.maxExpiry.String())
+		return
+	}
+
+	session, err := h.repo.Session().GetByID(c.Request.Context(), sessionID)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to get session")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve session", "")
+		return
+	}
+	if session == nil {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Session not found", "")
+		return
+	}
+	if session.Status == models.SessionStatusArchived {
+		problem.Write(c, http.StatusConflict, problem.SessionArchived, "Session is archived", "Unarchive the session to share it")
+		return
+	}
+
+	claims := share.Claims{
+		SessionID:    sessionID,
+		ExpiresAt:    time.Now().Add(expiry).Unix(),
+		IncludeTools: !req.ExcludeTools,
+	}
+	token, err := h.signer.Sign(claims)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to sign share token")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to create share link", "")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"session_id":    sessionID,
+		"include_tools": claims.IncludeTools,
+		"expires_at":    claims.Expires(),
+	}).Info("Session shared")
+
+	c.JSON(http.StatusCreated, models.ShareLink{
+		Token:        token,
+		URL:          h.baseURL + "/" + token,
+		IncludeTools: claims.IncludeTools,
+		ExpiresAt:    claims.Expires(),
+	})
+}
+
+// Get returns the transcript a share token grants access to
+func (h *ShareHandler) Get(c *gin.Context) {
+	transcript, ok := h.transcript(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, transcript)
+}
+
+// Render serves the transcript a share token grants access to as a web page
+func (h *ShareHandler) Render(c *gin.Context) {
+	transcript, ok := h.transcript(c)
+	if !ok {
+		return
+	}
+
+	// Tokens are in the URL, so they are kept out of referrers and indexes
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Robots-Tag", "noindex")
+	c.Status(http.StatusOK)
+	if err := sharedPage.Execute(c.Writer, transcript); err != nil {
+		logrus.WithError(err).Warn("Failed to render shared transcript")
+	}
+}
+
+// transcript verifies the share token of the request and loads the
+// transcript it grants access to, writing the error response when it cannot
+func (h *ShareHandler) transcript(c *gin.Context) (*models.SharedTranscript, bool) {
+	if h.signer == nil {
+		problem.Write(c, http.StatusServiceUnavailable, problem.ServiceUnavailable, "Sharing is not available", "")
+		return nil, false
+	}
+
+	claims, err := h.signer.Verify(c.Param("token"))
+	if err != nil {
+		problem.Write(c, http.StatusForbidden, problem.Forbidden, "Invalid or expired share link", "")
+		return nil, false
+	}
+
+	ctx := c.Request.Context()
+	session, err := h.repo.Session().GetByID(ctx, claims.SessionID)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", claims.SessionID).Error("Failed to get session")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve transcript", "")
+		return nil, false
+	}
+	if session == nil {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Shared session no longer exists", "")
+		return nil, false
+	}
+	if session.Status == models.SessionStatusArchived {
+		problem.Write(c, http.StatusConflict, problem.SessionArchived, "Shared session is archived", "")
+		return nil, false
+	}
+
+	messages, err := h.sharedMessages(ctx, claims)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", claims.SessionID).Error("Failed to list shared messages")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve transcript", "")
+		return nil, false
+	}
+
+	return &models.SharedTranscript{
+		Title:     session.Title,
+		AgentName: session.Agent.Name,
+		Messages:  messages,
+		ExpiresAt: claims.Expires(),
+	}, true
+}
+
+// sharedMessages returns the visible, completed messages of the shared
+// session. Without tool details, tool results and the steps that only
+// requested tools are left out.
+func (h *ShareHandler) sharedMessages(ctx context.Context, claims *share.Claims) ([]models.SharedMessage, error) {
+	messages, _, err := h.repo.Message().ListVisibleBySessionID(ctx, claims.SessionID, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	toolCalls := make(map[string][]models.SharedToolCall)
+	if claims.IncludeTools {
+		calls, err := h.repo.ToolCall().ListBySessionID(ctx, claims.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		for _, call := range calls {
+			toolCalls[call.MessageID] = append(toolCalls[call.MessageID], models.SharedToolCall{
+				ToolName:  call.ToolName,
+				Arguments: call.Arguments,
+				Result:    call.Result,
+				Success:   call.Success,
+				Error:     call.Error,
+			})
+		}
+	}
+
+	shared := make([]models.SharedMessage, 0, len(messages))
+	for _, message := range messages {
+		if message.Status != models.MessageStatusComplete {
+			continue
+		}
+		calls := toolCalls[message.ID]
+		if !claims.IncludeTools && (message.Role == "tool" || (message.Role == "assistant" && strings.TrimSpace(message.Content) == "")) {
+			continue
+		}
+		shared = append(shared, models.SharedMessage{
+			Role:      message.Role,
+			Content:   message.Content,
+			ToolCalls: calls,
+			CreatedAt: message.CreatedAt,
+		})
+	}
+	return shared, nil
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"agent-server/internal/models"
+	"agent-server/internal/share"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShareHandler_SharesReadOnlyTranscript(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "helper", Provider: "ollama", Model: "llama3", SystemPrompt: "p"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, Title: "Weather <chat>", ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	user := &models.Message{SessionID: session.ID, Role: "user", Content: "Weather in Paris?"}
+	require.NoError(t, repo.Message().Create(ctx, user))
+	step := &models.Message{SessionID: session.ID, Role: "assistant", Content: "", TurnID: user.ID}
+	require.NoError(t, repo.Message().Create(ctx, step))
+	require.NoError(t, repo.ToolCall().Create(ctx, &models.ToolCall{MessageID: step.ID, ToolName: "weather", Arguments: models.JSON{"city": "Paris"}, Success: true}))
+	require.NoError(t, repo.Message().Create(ctx, &models.Message{SessionID: session.ID, Role: "tool", Content: `{"temp":21}`, TurnID: user.ID}))
+	require.NoError(t, repo.Message().Create(ctx, &models.Message{SessionID: session.ID, Role: "system", Content: "private note", Hidden: true}))
+	require.NoError(t, repo.Message().Create(ctx, &models.Message{SessionID: session.ID, Role: "assistant", Content: "It is 21°C.", TurnID: user.ID, Metadata: models.JSON{"model": "llama3"}}))
+
+	signer, err := share.NewSigner("secret")
+	require.NoError(t, err)
+	handler := NewShareHandler(repo, signer, "https://chat.example.com/share/", 24*time.Hour, 48*time.Hour)
+	router := gin.New()
+	router.POST("/sessions/:id/share", handler.Create)
+	router.GET("/shared/:token", handler.Get)
+	router.GET("/share/:token", handler.Render)
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	createLink := func(body string) models.ShareLink {
+		w := request(http.MethodPost, "/sessions/"+session.ID+"/share", body)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var link models.ShareLink
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+		return link
+	}
+	getTranscript := func(token string) models.SharedTranscript {
+		w := request(http.MethodGet, "/shared/"+token, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var transcript models.SharedTranscript
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &transcript))
+		return transcript
+	}
+
+	// With tool details
+	link := createLink("")
+	assert.Equal(t, "https://chat.example.com/share/"+link.Token, link.URL)
+	assert.True(t, link.IncludeTools)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), link.ExpiresAt, time.Minute)
+
+	transcript := getTranscript(link.Token)
+	assert.Equal(t, "helper", transcript.AgentName)
+	require.Len(t, transcript.Messages, 4)
+	assert.Equal(t, []string{"user", "assistant", "tool", "assistant"}, []string{
+		transcript.Messages[0].Role, transcript.Messages[1].Role, transcript.Messages[2].Role, transcript.Messages[3].Role,
+	})
+	require.Len(t, transcript.Messages[1].ToolCalls, 1)
+	assert.Equal(t, "weather", transcript.Messages[1].ToolCalls[0].ToolName)
+
+	// Without tool details
+	link = createLink(`{"exclude_tools":true,"expires_in":3600}`)
+	assert.False(t, link.IncludeTools)
+	transcript = getTranscript(link.Token)
+	require.Len(t, transcript.Messages, 2)
+	assert.Equal(t, "Weather in Paris?", transcript.Messages[0].Content)
+	assert.Equal(t, "It is 21°C.", transcript.Messages[1].Content)
+	assert.Empty(t, transcript.Messages[1].ToolCalls)
+
+	// The page escapes the transcript
+	w := request(http.MethodGet, "/share/"+link.Token, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "Weather &lt;chat&gt;")
+	assert.NotContains(t, w.Body.String(), "private note")
+
+	// Expiries are capped and tokens checked
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "/sessions/"+session.ID+"/share", `{"expires_in":604800}`).Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodPost, "/sessions/missing/share", "").Code)
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/shared/"+link.Token+"x", "").Code)
+}
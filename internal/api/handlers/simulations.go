@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// SimulationHandler runs conversations between two agents
+type SimulationHandler struct {
+	simulator *services.Simulator
+	validator *validator.Validate
+	logger    *slog.Logger
+}
+
+// NewSimulationHandler creates a new simulation handler
+func NewSimulationHandler(simulator *services.Simulator, logger *slog.Logger) *SimulationHandler {
+	return &SimulationHandler{
+		simulator: simulator,
+		validator: newValidator(),
+		logger:    logger,
+	}
+}
+
+// Run lets two agents talk to each other for a number of turns
+// @Summary Run an agent-to-agent simulation
+// @Description Send the opening message to the first agent, then pass each reply to the other agent until the number of turns is reached. Each agent's side of the dialogue is persisted in a session of its own. When a later turn fails, the turns before it are returned with status "incomplete".
+// @Tags simulations
+// @Accept json
+// @Produce json
+// @Param request body models.SimulationRequest true "Simulation"
+// @Success 201 {object} models.SimulationResult
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Router /simulations [post]
+func (h *SimulationHandler) Run(c *gin.Context) {
+	var req models.SimulationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if err := h.validator.Struct(&req); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	result, err := h.simulator.Run(c.Request.Context(), &req)
+	if err != nil && (result == nil || len(result.Turns) == 0) {
+		h.logger.Error("Simulation failed",
+			"first_agent_id", req.FirstAgentID,
+			"second_agent_id", req.SecondAgentID,
+			"error", err)
+		writeChatError(c, "Simulation failed", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
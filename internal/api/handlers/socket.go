@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/pubsub"
+	"agent-server/internal/services"
+	"agent-server/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"golang.org/x/net/websocket"
+)
+
+// Presence events of session WebSockets. They are sent to every connection
+// on the session, so UIs can show that the agent is typing or running a tool
+// without polling.
+const (
+	PresenceGenerationStarted  = "generation_started"
+	PresenceGenerationProgress = "generation_progress"
+	PresenceToolRunning        = "tool_running"
+	PresenceGenerationComplete = "generation_complete"
+)
+
+// Frames only the connection that asked for the reply receives
+const (
+	frameContent = "content"
+	frameError   = "error"
+)
+
+// DefaultProgressInterval is how often generation_progress is sent while a
+// reply streams unless configured otherwise
+const DefaultProgressInterval = time.Second
+
+// socketBuffer is the number of frames queued for a slow connection. Presence
+// events for other connections are dropped when their queue is full.
+const socketBuffer = 64
+
+// presenceTopic is the event bus topic presence events are relayed on
+const presenceTopic = "presence"
+
+// socketRequest is a message from a WebSocket client. The chat type asks for
+// a reply, streamed like POST /sessions/:id/stream or, with tools set, run
+// like POST /sessions/:id/chat/auto-tools.
+type socketRequest struct {
+	Type string `json:"type"`
+	services.ChatRequest
+	Tools bool `json:"tools"`
+}
+
+// socketFrame is a message to WebSocket clients
+type socketFrame struct {
+	Type            string                       `json:"type"`
+	SessionID       string                       `json:"session_id,omitempty"`
+	Content         string                       `json:"content,omitempty"`
+	Tokens          int                          `json:"tokens,omitempty"`
+	TokensPerSecond float64                      `json:"tokens_per_second,omitempty"`
+	ToolCallID      string                       `json:"tool_call_id,omitempty"`
+	ToolName        string                       `json:"tool_name,omitempty"`
+	MessageID       string                       `json:"message_id,omitempty"`
+	FinishReason    string                       `json:"finish_reason,omitempty"`
+	Usage           *llm.Usage                   `json:"usage,omitempty"`
+	Response        *models.EnhancedChatResponse `json:"response,omitempty"`
+	Error           *problem.Problem             `json:"error,omitempty"`
+}
+
+// SocketHandler serves chat over WebSockets, with presence events for every
+// connection on a session
+type SocketHandler struct {
+	sessionRepo storage.SessionRepository
+	chatService *services.ChatService
+	allowOrigin func(origin string) bool
+	validator   *validator.Validate
+	logger      *slog.Logger
+	progress    time.Duration
+	hub         *presenceHub
+}
+
+// NewSocketHandler creates a WebSocket handler; allowOrigin checks the
+// Origin of upgrade requests
+func NewSocketHandler(
+	sessionRepo storage.SessionRepository,
+	chatService *services.ChatService,
+	allowOrigin func(origin string) bool,
+	logger *slog.Logger,
+) *SocketHandler {
+	return &SocketHandler{
+		sessionRepo: sessionRepo,
+		chatService: chatService,
+		allowOrigin: allowOrigin,
+		validator:   newValidator(),
+		logger:      logger,
+		progress:    DefaultProgressInterval,
+		hub: &presenceHub{
+			sessions: make(map[string]map[*socketConn]bool),
+			origin:   uuid.New().String(),
+			logger:   logger,
+		},
+	}
+}
+
+// SetEventBus relays presence events through bus, so that connections to
+// other instances on the same session receive them too
+func (h *SocketHandler) SetEventBus(bus pubsub.Bus) {
+	h.hub.mu.Lock()
+	defer h.hub.mu.Unlock()
+	h.hub.bus = bus
+}
+
+// SetProgressInterval sets how often generation_progress is sent while a
+// reply streams
+func (h *SocketHandler) SetProgressInterval(interval time.Duration) {
+	h.progress = interval
+}
+
+// errOriginNotAllowed fails the handshake of upgrades from other origins
+var errOriginNotAllowed = errors.New("origin not allowed")
+
+// Serve upgrades a request to a WebSocket on a session
+func (h *SocketHandler) Serve(c *gin.Context) {
+	sessionID := c.Param("id")
+	session, err := h.sessionRepo.GetByID(c.Request.Context(), sessionID)
+	if err != nil {
+		h.logger.Error("Failed to get session", "session_id", sessionID, "error", err)
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve session", "")
+		return
+	}
+	if session == nil {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Session not found", "")
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			if !h.allowOrigin(r.Header.Get("Origin")) {
+				return errOriginNotAllowed
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			h.serve(ws, sessionID)
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// serve relays requests and frames of one connection until it closes
+func (h *SocketHandler) serve(ws *websocket.Conn, sessionID string) {
+	ctx, cancel := context.WithCancel(chatContext(ws.Request()))
+	defer cancel()
+
+	conn := &socketConn{send: make(chan socketFrame, socketBuffer)}
+	h.hub.join(sessionID, conn)
+	defer h.hub.leave(sessionID, conn)
+
+	go func() {
+		for {
+			select {
+			case frame := <-conn.send:
+				if err := websocket.JSON.Send(ws, frame); err != nil {
+					cancel()
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// One reply at a time; closing the connection cancels it
+	var generating atomic.Bool
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		var req socketRequest
+		if err := websocket.JSON.Receive(ws, &req); err != nil {
+			return
+		}
+		if req.Type != "chat" {
+			conn.deliver(ctx, errorFrame(problem.New(http.StatusBadRequest, problem.BadRequest, "Unknown message type")))
+			continue
+		}
+		req.SessionID = sessionID
+		req.Stream = true
+		if err := h.validator.Struct(&req.ChatRequest); err != nil || strings.TrimSpace(req.Message) == "" {
+			conn.deliver(ctx, errorFrame(problem.New(http.StatusBadRequest, problem.ValidationFailed, "Invalid chat message")))
+			continue
+		}
+		if !generating.CompareAndSwap(false, true) {
+			conn.deliver(ctx, errorFrame(problem.New(http.StatusConflict, problem.Conflict, "A reply is already being generated")))
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer generating.Store(false)
+			if req.Tools {
+				h.generateWithTools(ctx, conn, &req)
+			} else {
+				h.generate(ctx, conn, &req)
+			}
+		}()
+	}
+}
+
+// generate streams a reply to the connection and its progress to the session
+func (h *SocketHandler) generate(ctx context.Context, conn *socketConn, req *socketRequest) {
+	chunks, err := h.chatService.Stream(ctx, &req.ChatRequest)
+	if err != nil {
+		h.logger.Error("Streaming chat failed", "session_id", req.SessionID, "error", err)
+		conn.deliver(ctx, errorFrame(chatProblem("Streaming failed", err)))
+		return
+	}
+	h.presence(ctx, conn, req.SessionID, socketFrame{Type: PresenceGenerationStarted})
+
+	var ticks <-chan time.Time
+	if h.progress > 0 {
+		ticker := time.NewTicker(h.progress)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	// Providers stream about one token per chunk
+	start := time.Now()
+	tokens := 0
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				// The stream broke off; the reply is over all the same
+				h.presence(ctx, conn, req.SessionID, socketFrame{Type: PresenceGenerationComplete})
+				return
+			}
+			if chunk.Content != "" {
+				tokens++
+				conn.deliver(ctx, socketFrame{Type: frameContent, Content: chunk.Content})
+			}
+			if chunk.Done {
+				h.presence(ctx, conn, req.SessionID, socketFrame{
+					Type:         PresenceGenerationComplete,
+					MessageID:    chunk.MessageID,
+					FinishReason: chunk.FinishReason,
+					Usage:        chunk.Usage,
+				})
+				return
+			}
+		case <-ticks:
+			h.presence(ctx, conn, req.SessionID, socketFrame{
+				Type:            PresenceGenerationProgress,
+				Tokens:          tokens,
+				TokensPerSecond: tokensPerSecond(tokens, time.Since(start)),
+			})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// generateWithTools runs a tool-calling chat, reporting the tools it runs to
+// the session and the response to the connection
+func (h *SocketHandler) generateWithTools(ctx context.Context, conn *socketConn, req *socketRequest) {
+	events, err := h.chatService.StreamWithTools(ctx, &models.EnhancedChatRequest{
+		Message:          req.Message,
+		ToolChoice:       "auto",
+		Metadata:         req.Metadata,
+		Stop:             req.Stop,
+		ResponseLanguage: req.ResponseLanguage,
+		Accept:           req.Accept,
+	}, req.SessionID)
+	if err != nil {
+		h.logger.Error("Streaming chat with tools failed", "session_id", req.SessionID, "error", err)
+		conn.deliver(ctx, errorFrame(chatProblem("Chat request failed", err)))
+		return
+	}
+	h.presence(ctx, conn, req.SessionID, socketFrame{Type: PresenceGenerationStarted})
+
+	for event := range events {
+		switch event.Type {
+		case services.EventToolCallStarted:
+			h.presence(ctx, conn, req.SessionID, socketFrame{
+				Type:       PresenceToolRunning,
+				ToolCallID: event.ToolCallID,
+				ToolName:   event.ToolName,
+			})
+		case services.EventDone:
+			conn.deliver(ctx, socketFrame{Type: frameContent, Content: event.Response.Response, Response: event.Response})
+			h.presence(ctx, conn, req.SessionID, socketFrame{
+				Type:         PresenceGenerationComplete,
+				MessageID:    event.Response.AssistantMessageID,
+				FinishReason: event.Response.FinishReason,
+			})
+		case services.EventError:
+			h.logger.Error("Chat with tools request failed", "session_id", req.SessionID, "error", event.Err)
+			conn.deliver(ctx, errorFrame(chatProblem("Chat request failed", event.Err)))
+			h.presence(ctx, conn, req.SessionID, socketFrame{Type: PresenceGenerationComplete})
+		}
+	}
+}
+
+// presence sends a presence event to the connection that asked for the reply
+// and to the other connections on the session
+func (h *SocketHandler) presence(ctx context.Context, conn *socketConn, sessionID string, frame socketFrame) {
+	frame.SessionID = sessionID
+	conn.deliver(ctx, frame)
+	h.hub.publish(sessionID, frame, conn)
+	h.hub.relay(ctx, sessionID, frame)
+}
+
+func errorFrame(p *problem.Problem) socketFrame {
+	return socketFrame{Type: frameError, Error: p}
+}
+
+func tokensPerSecond(tokens int, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(tokens) / elapsed.Seconds()
+}
+
+// socketConn queues the frames of one connection for its writer
+type socketConn struct {
+	send chan socketFrame
+}
+
+// deliver queues a frame, waiting while the connection is slow
+func (c *socketConn) deliver(ctx context.Context, frame socketFrame) {
+	select {
+	case c.send <- frame:
+	case <-ctx.Done():
+	}
+}
+
+// presenceHub tracks the connections on each session. With an event bus it
+// also relays presence events between instances while it has connections.
+type presenceHub struct {
+	mu       sync.Mutex
+	sessions map[string]map[*socketConn]bool
+	bus      pubsub.Bus
+	origin   string // identifies this instance's events on the bus
+	logger   *slog.Logger
+	stop     context.CancelFunc
+}
+
+// presenceMessage is a presence event relayed between instances
+type presenceMessage struct {
+	Origin    string      `json:"origin"`
+	SessionID string      `json:"session_id"`
+	Frame     socketFrame `json:"frame"`
+}
+
+func (p *presenceHub) join(sessionID string, conn *socketConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.sessions) == 0 && p.bus != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		p.stop = cancel
+		go p.receive(ctx, p.bus)
+	}
+	if p.sessions[sessionID] == nil {
+		p.sessions[sessionID] = make(map[*socketConn]bool)
+	}
+	p.sessions[sessionID][conn] = true
+}
+
+func (p *presenceHub) leave(sessionID string, conn *socketConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.sessions[sessionID], conn)
+	if len(p.sessions[sessionID]) == 0 {
+		delete(p.sessions, sessionID)
+	}
+	if len(p.sessions) == 0 && p.stop != nil {
+		p.stop()
+		p.stop = nil
+	}
+}
+
+// publish sends a frame to the session's connections except one, without
+// waiting for slow connections
+func (p *presenceHub) publish(sessionID string, frame socketFrame, except *socketConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for conn := range p.sessions[sessionID] {
+		if conn == except {
+			continue
+		}
+		select {
+		case conn.send <- frame:
+		default:
+		}
+	}
+}
+
+// relay publishes a frame on the event bus for the other instances
+func (p *presenceHub) relay(ctx context.Context, sessionID string, frame socketFrame) {
+	p.mu.Lock()
+	bus := p.bus
+	p.mu.Unlock()
+	if bus == nil {
+		return
+	}
+	data, err := json.Marshal(presenceMessage{Origin: p.origin, SessionID: sessionID, Frame: frame})
+	if err != nil {
+		return
+	}
+	if err := bus.Publish(ctx, presenceTopic, data); err != nil {
+		p.logger.Warn("Failed to relay presence event", "session_id", sessionID, "error", err)
+	}
+}
+
+// receive delivers the presence events of other instances to this
+// instance's connections until ctx is done
+func (p *presenceHub) receive(ctx context.Context, bus pubsub.Bus) {
+	events, err := bus.Subscribe(ctx, presenceTopic)
+	if err != nil {
+		p.logger.Warn("Failed to subscribe to presence events", "error", err)
+		return
+	}
+	for data := range events {
+		var message presenceMessage
+		if err := json.Unmarshal(data, &message); err != nil || message.Origin == p.origin {
+			continue
+		}
+		p.publish(message.SessionID, message.Frame, nil)
+	}
+}
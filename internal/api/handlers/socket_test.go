@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/llm/mock"
+	"agent-server/internal/models"
+	"agent-server/internal/pubsub"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func TestSocketHandler_PresenceEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "typing", Provider: mock.Name, Model: "mock", Enabled: true}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	registry := llm.NewRegistry()
+	registry.Register(mock.NewProvider(mock.Options{ReplyWords: 6, StreamDelay: 20 * time.Millisecond}))
+	chatService := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+
+	allowed := func(origin string) bool { return origin != "https://evil.example" }
+	handler := NewSocketHandler(repo.Session(), chatService, allowed, slog.Default())
+	handler.SetProgressInterval(30 * time.Millisecond)
+	router := gin.New()
+	router.GET("/sessions/:id/ws", handler.Serve)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/sessions/" + session.ID + "/ws"
+	writer, err := websocket.Dial(url, "", "http://localhost")
+	require.NoError(t, err)
+	defer writer.Close()
+	watcher, err := websocket.Dial(url, "", "http://localhost")
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	_, err = websocket.Dial(url, "", "https://evil.example")
+	assert.Error(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sessions/missing/ws", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// receive reads frames until the reply is complete
+	receive := func(ws *websocket.Conn) []socketFrame {
+		var frames []socketFrame
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+		for {
+			var frame socketFrame
+			require.NoError(t, websocket.JSON.Receive(ws, &frame))
+			frames = append(frames, frame)
+			if frame.Type == PresenceGenerationComplete || frame.Type == frameError {
+				return frames
+			}
+		}
+	}
+	types := func(frames []socketFrame) map[string]int {
+		counts := map[string]int{}
+		for _, frame := range frames {
+			counts[frame.Type]++
+		}
+		return counts
+	}
+
+	// Ask for a reply once the watcher has joined the session
+	require.Eventually(t, func() bool {
+		handler.hub.mu.Lock()
+		defer handler.hub.mu.Unlock()
+		return len(handler.hub.sessions[session.ID]) == 2
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, websocket.JSON.Send(writer, map[string]interface{}{"type": "chat", "message": "hi"}))
+
+	frames := receive(writer)
+	assert.Equal(t, PresenceGenerationStarted, frames[0].Type)
+	assert.Equal(t, session.ID, frames[0].SessionID)
+	counts := types(frames)
+	assert.Equal(t, 6, counts[frameContent])
+	assert.Greater(t, counts[PresenceGenerationProgress], 0)
+	last := frames[len(frames)-1]
+	assert.NotEmpty(t, last.MessageID)
+	assert.Equal(t, "stop", last.FinishReason)
+
+	// Other connections see the presence events, but not the reply
+	watched := receive(watcher)
+	assert.Equal(t, PresenceGenerationStarted, watched[0].Type)
+	assert.Zero(t, types(watched)[frameContent])
+	assert.Equal(t, last.MessageID, watched[len(watched)-1].MessageID)
+
+	require.NoError(t, websocket.JSON.Send(writer, map[string]interface{}{"type": "chat", "message": " "}))
+	frames = receive(writer)
+	require.Len(t, frames, 1)
+	assert.Equal(t, frameError, frames[0].Type)
+	assert.Equal(t, http.StatusBadRequest, frames[0].Error.Status)
+}
+
+func TestSocketHandler_ToolRunning(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "tools", Provider: mock.Name, Model: "mock", Enabled: true, Config: models.JSON{}}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	registry := llm.NewRegistry()
+	registry.Register(mock.NewProvider(mock.Options{ReplyWords: 6}))
+	toolService := services.NewToolService(repo, slog.Default())
+	chatService := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+
+	handler := NewSocketHandler(repo.Session(), chatService, func(string) bool { return true }, slog.Default())
+	router := gin.New()
+	router.GET("/sessions/:id/ws", handler.Serve)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/sessions/"+session.ID+"/ws", "", "http://localhost")
+	require.NoError(t, err)
+	defer ws.Close()
+	require.NoError(t, websocket.JSON.Send(ws, map[string]interface{}{
+		"type": "chat", "tools": true, "message": "/tool calculator {\"expression\": \"2+3\"}",
+	}))
+
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var frames []socketFrame
+	for len(frames) == 0 || frames[len(frames)-1].Type != PresenceGenerationComplete {
+		var frame socketFrame
+		require.NoError(t, websocket.JSON.Receive(ws, &frame))
+		require.NotEqual(t, frameError, frame.Type, frame.Error)
+		frames = append(frames, frame)
+	}
+
+	require.Len(t, frames, 4)
+	assert.Equal(t, PresenceGenerationStarted, frames[0].Type)
+	assert.Equal(t, PresenceToolRunning, frames[1].Type)
+	assert.Equal(t, "calculator", frames[1].ToolName)
+	assert.Equal(t, frameContent, frames[2].Type)
+	require.NotNil(t, frames[2].Response)
+	assert.Len(t, frames[2].Response.ToolCalls, 1)
+	assert.Equal(t, frames[2].Response.AssistantMessageID, frames[3].MessageID)
+}
+
+// subscribedBus counts the subscriptions to a bus
+type subscribedBus struct {
+	*pubsub.Memory
+	subscriptions atomic.Int32
+}
+
+func (b *subscribedBus) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	events, err := b.Memory.Subscribe(ctx, topic)
+	b.subscriptions.Add(1)
+	return events, err
+}
+
+func TestSocketHandler_RelaysPresenceBetweenInstances(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "relayed", Provider: mock.Name, Model: "mock", Enabled: true}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	registry := llm.NewRegistry()
+	registry.Register(mock.NewProvider(mock.Options{ReplyWords: 3}))
+	chatService := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+
+	// Two instances share the bus
+	bus := &subscribedBus{Memory: pubsub.NewMemory()}
+	dial := func() *websocket.Conn {
+		handler := NewSocketHandler(repo.Session(), chatService, func(string) bool { return true }, slog.Default())
+		handler.SetEventBus(bus)
+		handler.SetProgressInterval(0)
+		router := gin.New()
+		router.GET("/sessions/:id/ws", handler.Serve)
+		server := httptest.NewServer(router)
+		t.Cleanup(server.Close)
+		ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/sessions/"+session.ID+"/ws", "", "http://localhost")
+		require.NoError(t, err)
+		t.Cleanup(func() { ws.Close() })
+		return ws
+	}
+	writer := dial()
+	watcher := dial()
+	require.Eventually(t, func() bool { return bus.subscriptions.Load() == 2 }, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, websocket.JSON.Send(writer, map[string]interface{}{"type": "chat", "message": "hi"}))
+
+	// The watcher on the other instance sees the presence events once
+	require.NoError(t, watcher.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var watched []socketFrame
+	for len(watched) == 0 || watched[len(watched)-1].Type != PresenceGenerationComplete {
+		var frame socketFrame
+		require.NoError(t, websocket.JSON.Receive(watcher, &frame))
+		watched = append(watched, frame)
+	}
+	require.Len(t, watched, 2)
+	assert.Equal(t, PresenceGenerationStarted, watched[0].Type)
+	assert.Equal(t, session.ID, watched[1].SessionID)
+	assert.NotEmpty(t, watched[1].MessageID)
+}
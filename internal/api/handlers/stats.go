@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// StatsHandler serves aggregate statistics for monitoring dashboards
+type StatsHandler struct {
+	statsService *services.StatsService
+}
+
+// NewStatsHandler creates a new stats handler
+func NewStatsHandler(statsService *services.StatsService) *StatsHandler {
+	return &StatsHandler{
+		statsService: statsService,
+	}
+}
+
+// Get returns totals plus daily chat, token, tool and latency series for the
+// last `days` days (default 30, max 365)
+func (h *StatsHandler) Get(c *gin.Context) {
+	days := 30
+	if d := c.Query("days"); d != "" {
+		parsed, err := strconv.Atoi(d)
+		if err != nil || parsed < 1 || parsed > 365 {
+			problem.Write(c, http.StatusBadRequest, problem.BadRequest, "days must be between 1 and 365", d)
+			return
+		}
+		days = parsed
+	}
+
+	stats, err := h.statsService.AdminStats(c.Request.Context(), days)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to compute admin stats")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to compute stats", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// ToolStateHandler handles admin requests that disable tools across the
+// deployment
+type ToolStateHandler struct {
+	tools     *services.ToolService
+	validator *validator.Validate
+}
+
+// NewToolStateHandler creates a new tool state handler
+func NewToolStateHandler(tools *services.ToolService) *ToolStateHandler {
+	return &ToolStateHandler{
+		tools:     tools,
+		validator: newValidator(),
+	}
+}
+
+// List returns every registered tool with whether it is disabled
+// @Summary List tool states
+// @Description List the registered tools with whether they are disabled and where that is defined
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.ToolStateList
+// @Router /admin/tools [get]
+func (h *ToolStateHandler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, models.ToolStateList{
+		Tools: h.tools.ToolStates(c.Request.Context()),
+	})
+}
+
+// Set disables or enables a tool at runtime, overriding the config
+// @Summary Disable or enable a tool
+// @Description Turn a tool off or on for every agent, whatever the agents' settings
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param name path string true "Tool name"
+// @Param state body models.SetToolStateRequest true "Tool state"
+// @Success 200 {object} models.ToolState
+// @Router /admin/tools/{name} [put]
+func (h *ToolStateHandler) Set(c *gin.Context) {
+	name := c.Param("name")
+
+	var req models.SetToolStateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if err := h.validator.Struct(&req); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	state := &models.ToolState{Name: name, Disabled: *req.Disabled, Reason: req.Reason}
+	if err := h.tools.SetToolState(c.Request.Context(), state); err != nil {
+		if errors.Is(err, services.ErrToolNotRegistered) {
+			problem.Write(c, http.StatusNotFound, problem.NotFound, "Tool not found", name)
+			return
+		}
+		logrus.WithError(err).WithField("tool", name).Error("Failed to set tool state")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to set tool state", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+// Delete removes a tool state set at runtime; the tool reverts to the config
+// @Summary Reset a tool state
+// @Description Remove a tool state set at runtime, reverting to the config
+// @Tags admin
+// @Param name path string true "Tool name"
+// @Success 204
+// @Router /admin/tools/{name} [delete]
+func (h *ToolStateHandler) Delete(c *gin.Context) {
+	name := c.Param("name")
+	removed, err := h.tools.ResetToolState(c.Request.Context(), name)
+	if err != nil {
+		logrus.WithError(err).WithField("tool", name).Error("Failed to reset tool state")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to reset tool state", "")
+		return
+	}
+	if !removed {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "No state of this tool was set at runtime", name)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
+	"agent-server/internal/api/problem"
 	"agent-server/internal/models"
 	"agent-server/internal/services"
+	"agent-server/internal/tools"
 
 	"github.com/gin-gonic/gin"
 )
@@ -36,10 +40,7 @@ func (h *ToolsHandler) ListTools(c *gin.Context) {
 
 	tools, err := h.toolService.ListTools(ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to list tools",
-			"details": err.Error(),
-		})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to list tools", err.Error())
 		return
 	}
 
@@ -62,19 +63,14 @@ func (h *ToolsHandler) GetTool(c *gin.Context) {
 	toolName := c.Param("tool_name")
 
 	if toolName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Tool name is required",
-		})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Tool name is required", "")
 		return
 	}
 
 	// Get tool from registry
 	tool, exists := h.toolService.GetRegistry().Get(toolName)
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Tool not found",
-			"tool_name": toolName,
-		})
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Tool not found", toolName)
 		return
 	}
 
@@ -131,28 +127,20 @@ func (h *ToolsHandler) TestTool(c *gin.Context) {
 	toolName := c.Param("tool_name")
 
 	if toolName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Tool name is required",
-		})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Tool name is required", "")
 		return
 	}
 
 	// Check if tool exists
 	_, exists := h.toolService.GetRegistry().Get(toolName)
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Tool not found",
-			"tool_name": toolName,
-		})
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Tool not found", toolName)
 		return
 	}
 
 	var req models.ToolTestRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request body",
-			"details": err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
 
@@ -162,10 +150,16 @@ func (h *ToolsHandler) TestTool(c *gin.Context) {
 	// Test the tool
 	response, err := h.toolService.TestTool(ctx, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to test tool",
-			"details": err.Error(),
-		})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to test tool", err.Error())
+		return
+	}
+
+	if len(response.Validation) > 0 {
+		respondToolValidationError(c, response.Validation)
+		return
+	}
+	if response.ErrorCode == "TIMEOUT" {
+		problem.Write(c, http.StatusGatewayTimeout, problem.ToolTimeout, "Tool execution timed out", response.Error)
 		return
 	}
 
@@ -193,10 +187,7 @@ func (h *ToolsHandler) GetToolSchemas(c *gin.Context) {
 
 	definitions, err := h.toolService.GetToolDefinitions(ctx, toolNames)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get tool schemas",
-			"details": err.Error(),
-		})
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to get tool schemas", err.Error())
 		return
 	}
 
@@ -211,6 +202,7 @@ func (h *ToolsHandler) GetToolSchemas(c *gin.Context) {
 // @Produce json
 // @Param tool_name path string true "Tool name"
 // @Param request body map[string]interface{} true "Tool execution parameters"
+// @Param timeout_seconds query int false "Timeout override, bounded by the configured maximum"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
@@ -221,33 +213,31 @@ func (h *ToolsHandler) ExecuteTool(c *gin.Context) {
 	toolName := c.Param("tool_name")
 
 	if toolName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Tool name is required",
-		})
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Tool name is required", "")
 		return
 	}
 
 	// Check if tool exists
 	_, exists := h.toolService.GetRegistry().Get(toolName)
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Tool not found",
-			"tool_name": toolName,
-		})
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Tool not found", toolName)
 		return
 	}
 
 	var parameters map[string]interface{}
 	if err := c.ShouldBindJSON(&parameters); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request body",
-			"details": err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
 
+	// ?timeout_seconds= overrides the tool's default timeout, up to the maximum
+	var opts tools.ExecuteOptions
+	if seconds, err := strconv.Atoi(c.Query("timeout_seconds")); err == nil && seconds > 0 {
+		opts.Timeout = time.Duration(seconds) * time.Second
+	}
+
 	// Execute the tool
-	result := h.toolService.GetExecutor().Execute(ctx, toolName, "direct-execution", parameters)
+	result := h.toolService.GetExecutor().ExecuteWithOptions(ctx, toolName, "direct-execution", parameters, opts)
 
 	// Return the raw result
 	response := map[string]interface{}{
@@ -267,6 +257,11 @@ func (h *ToolsHandler) ExecuteTool(c *gin.Context) {
 		response["metadata"] = result.Metadata
 	}
 
+	if !result.Success && result.ErrorCode == "TIMEOUT" {
+		problem.Write(c, http.StatusGatewayTimeout, problem.ToolTimeout, "Tool execution timed out", result.Error)
+		return
+	}
+
 	status := http.StatusOK
 	if !result.Success {
 		status = http.StatusBadRequest
@@ -275,6 +270,100 @@ func (h *ToolsHandler) ExecuteTool(c *gin.Context) {
 	c.JSON(status, response)
 }
 
+// GetToolExecution returns the status and result of a background tool execution
+// @Summary Get a background tool execution
+// @Description Poll a long-running tool call started in the background
+// @Tags tools
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Success 200 {object} models.ToolExecution
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tool-executions/{id} [get]
+func (h *ToolsHandler) GetToolExecution(c *gin.Context) {
+	execution, err := h.toolService.GetExecution(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, services.ErrToolExecutionNotFound) {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Tool execution not found", c.Param("id"))
+		return
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to get tool execution", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, execution)
+}
+
+// RejectToolExecutionRequest is the optional body of a rejection
+type RejectToolExecutionRequest struct {
+	// Reason is passed on to the LLM
+	Reason string `json:"reason"`
+}
+
+// ApproveToolExecution runs a tool call that awaits approval
+// @Summary Approve a tool call
+// @Description Run a tool call held for approval and return the finished execution
+// @Tags tools
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Success 200 {object} models.ToolExecution
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tool-executions/{id}/approve [post]
+func (h *ToolsHandler) ApproveToolExecution(c *gin.Context) {
+	execution, err := h.toolService.ApproveExecution(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeApprovalError(c, "Failed to approve tool execution", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, execution)
+}
+
+// RejectToolExecution rejects a tool call that awaits approval
+// @Summary Reject a tool call
+// @Description Reject a tool call held for approval; the reason is passed on to the LLM
+// @Tags tools
+// @Accept json
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Param request body RejectToolExecutionRequest false "Rejection reason"
+// @Success 200 {object} models.ToolExecution
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tool-executions/{id}/reject [post]
+func (h *ToolsHandler) RejectToolExecution(c *gin.Context) {
+	var req RejectToolExecutionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+	}
+
+	execution, err := h.toolService.RejectExecution(c.Request.Context(), c.Param("id"), req.Reason)
+	if err != nil {
+		writeApprovalError(c, "Failed to reject tool execution", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, execution)
+}
+
+// writeApprovalError writes the problem of a failed approval or rejection
+func writeApprovalError(c *gin.Context, title string, err error) {
+	switch {
+	case errors.Is(err, services.ErrToolExecutionNotFound):
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Tool execution not found", c.Param("id"))
+	case errors.Is(err, services.ErrNotAwaitingApproval):
+		problem.Write(c, http.StatusConflict, problem.Conflict, "Tool execution is not awaiting approval", err.Error())
+	default:
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, title, err.Error())
+	}
+}
+
 // GetToolUsageStats returns usage statistics for tools
 // @Summary Get tool usage statistics
 // @Description Get usage statistics for all tools or a specific tool
@@ -334,6 +423,43 @@ func (h *ToolsHandler) GetToolUsageStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+// GetToolQuotaUsage returns tool call counters against the configured quotas
+// @Summary Get tool quota usage
+// @Description Get the tool calls an agent made today and, for a session, in that session, with the configured quota limits
+// @Tags tools
+// @Produce json
+// @Param agent_id query string false "Agent ID (required without session_id)"
+// @Param session_id query string false "Session ID; its agent is used"
+// @Success 200 {object} models.ToolQuotaUsage
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tools/stats/quotas [get]
+func (h *ToolsHandler) GetToolQuotaUsage(c *gin.Context) {
+	agentID := c.Query("agent_id")
+	sessionID := c.Query("session_id")
+	if agentID == "" && sessionID == "" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Missing scope", "agent_id or session_id is required")
+		return
+	}
+
+	usage, err := h.toolService.QuotaUsage(c.Request.Context(), agentID, sessionID)
+	if errors.Is(err, services.ErrSessionNotFound) {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Session not found", sessionID)
+		return
+	}
+	if errors.Is(err, services.ErrAgentNotFound) {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Agent not found", agentID)
+		return
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to get tool quota usage", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
 // Helper function to parse comma-separated strings
 func parseCommaSeparatedString(s string) []string {
 	if s == "" {
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// TurnHandler serves a session's messages grouped into turns
+type TurnHandler struct {
+	repo storage.Repository
+}
+
+// NewTurnHandler creates a new turn handler
+func NewTurnHandler(repo storage.Repository) *TurnHandler {
+	return &TurnHandler{
+		repo: repo,
+	}
+}
+
+// ListBySession retrieves a paginated list of a session's turns, oldest
+// first. Hidden messages are left out unless include_hidden=true.
+func (h *TurnHandler) ListBySession(c *gin.Context) {
+	sessionID := c.Param("id")
+	if sessionID == "" {
+		problem.Write(c, http.StatusBadRequest, problem.BadRequest, "Session ID is required", "")
+		return
+	}
+
+	// Parse pagination parameters
+	page := 1
+	pageSize := 20
+
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			page = parsed
+		}
+	}
+
+	if ps := c.Query("page_size"); ps != "" {
+		if parsed, err := strconv.Atoi(ps); err == nil && parsed > 0 && parsed <= 100 {
+			pageSize = parsed
+		}
+	}
+
+	ctx := c.Request.Context()
+	session, err := h.repo.Session().GetByID(ctx, sessionID)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to get session")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve session", "")
+		return
+	}
+	if session == nil {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Session not found", "")
+		return
+	}
+
+	// Turns span a variable number of messages, so group the whole session
+	messages, _, err := h.repo.Message().ListBySessionID(ctx, sessionID, -1, -1)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to list messages")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve turns", "")
+		return
+	}
+	toolCalls, err := h.repo.ToolCall().ListBySessionID(ctx, sessionID)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to list tool calls")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve turns", "")
+		return
+	}
+
+	if !includeHidden(c) {
+		visible := messages[:0]
+		for _, message := range messages {
+			if !message.Hidden {
+				visible = append(visible, message)
+			}
+		}
+		messages = visible
+	}
+	turns := models.GroupTurns(messages, toolCalls)
+
+	start := (page - 1) * pageSize
+	if start > len(turns) {
+		start = len(turns)
+	}
+	end := start + pageSize
+	if end > len(turns) {
+		end = len(turns)
+	}
+
+	c.JSON(http.StatusOK, models.TurnList{
+		Turns:      turns[start:end],
+		TotalCount: len(turns),
+		Page:       page,
+		PageSize:   pageSize,
+		HasMore:    end < len(turns),
+	})
+}
+
+// Trace retrieves how the context of a turn's reply was built: which
+// messages the session's strategy included, excluded or summarized, and why
+func (h *TurnHandler) Trace(c *gin.Context) {
+	sessionID := c.Param("id")
+	turnID := c.Param("turn_id")
+
+	trace, err := h.repo.ContextTrace().GetByTurnID(c.Request.Context(), turnID)
+	if err != nil {
+		logrus.WithError(err).WithField("turn_id", turnID).Error("Failed to get context trace")
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to retrieve context trace", "")
+		return
+	}
+	if trace == nil || trace.SessionID != sessionID {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Context trace not found", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, trace)
+}
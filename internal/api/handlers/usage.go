@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"agent-server/internal/api/problem"
+	"agent-server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UsageHandler reports token and tool spending against the budgets
+type UsageHandler struct {
+	accounting *services.Accounting
+}
+
+// NewUsageHandler creates a new usage handler
+func NewUsageHandler(accounting *services.Accounting) *UsageHandler {
+	return &UsageHandler{
+		accounting: accounting,
+	}
+}
+
+// Session returns what a session has spent
+// @Summary Get session usage
+// @Description Get the tokens and costs of a session's replies and tool calls, with the remaining session budget
+// @Tags sessions
+// @Produce json
+// @Param id path string true "Session ID"
+// @Success 200 {object} models.UsageReport
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sessions/{id}/usage [get]
+func (h *UsageHandler) Session(c *gin.Context) {
+	id := c.Param("id")
+
+	report, err := h.accounting.SessionUsage(c.Request.Context(), id)
+	if errors.Is(err, services.ErrSessionNotFound) {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Session not found", id)
+		return
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to get session usage", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// Agent returns what an agent has spent over the last `days` UTC days
+// (default 1, max 365)
+// @Summary Get agent usage
+// @Description Get the tokens and costs of an agent's sessions; for a single day the remaining daily budget is included
+// @Tags agents
+// @Produce json
+// @Param id path string true "Agent ID"
+// @Param days query int false "Number of UTC days including today" default(1)
+// @Success 200 {object} models.UsageReport
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /agents/{id}/usage [get]
+func (h *UsageHandler) Agent(c *gin.Context) {
+	id := c.Param("id")
+
+	days := 1
+	if d := c.Query("days"); d != "" {
+		parsed, err := strconv.Atoi(d)
+		if err != nil || parsed < 1 || parsed > 365 {
+			problem.Write(c, http.StatusBadRequest, problem.BadRequest, "days must be between 1 and 365", d)
+			return
+		}
+		days = parsed
+	}
+
+	report, err := h.accounting.AgentUsage(c.Request.Context(), id, days)
+	if errors.Is(err, services.ErrAgentNotFound) {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Agent not found", id)
+		return
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Failed to get agent usage", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"agent-server/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSPolicy decides which cross-origin requests are allowed. The same
+// policy backs the CORS middleware and the Origin check of WebSocket
+// upgrades, so every endpoint type follows one configuration.
+type CORSPolicy struct {
+	allowAll         bool
+	origins          map[string]bool
+	wildcards        []string // suffixes from patterns like https://*.example.com
+	methods          string
+	headers          string
+	exposedHeaders   string
+	allowCredentials bool
+	maxAge           string
+}
+
+// NewCORSPolicy builds a policy from configuration
+func NewCORSPolicy(cfg config.CORSConfig) *CORSPolicy {
+	p := &CORSPolicy{
+		origins:          make(map[string]bool),
+		methods:          strings.Join(upper(cfg.AllowedMethods), ", "),
+		headers:          strings.Join(cfg.AllowedHeaders, ", "),
+		exposedHeaders:   strings.Join(cfg.ExposedHeaders, ", "),
+		allowCredentials: cfg.AllowCredentials,
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(cfg.MaxAge)
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimRight(strings.TrimSpace(origin), "/"))
+		switch {
+		case origin == "*":
+			p.allowAll = true
+		case strings.Contains(origin, "://*."):
+			scheme, host, _ := strings.Cut(origin, "://*")
+			p.wildcards = append(p.wildcards, scheme+"://|"+host)
+		case origin != "":
+			p.origins[origin] = true
+		}
+	}
+	return p
+}
+
+// AllowsOrigin reports whether requests from origin are allowed. Requests
+// without an Origin header are same-origin or non-browser and always allowed.
+func (p *CORSPolicy) AllowsOrigin(origin string) bool {
+	if origin == "" || p.allowAll {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, wildcard := range p.wildcards {
+		scheme, suffix, _ := strings.Cut(wildcard, "|")
+		if strings.HasPrefix(origin, scheme) && strings.HasSuffix(origin, suffix) &&
+			len(origin) > len(scheme)+len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS returns a gin.HandlerFunc for handling CORS on REST, SSE and
+// WebSocket endpoints
+func CORS(cfg config.CORSConfig) gin.HandlerFunc {
+	policy := NewCORSPolicy(cfg)
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		if !policy.AllowsOrigin(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			// Without CORS headers the browser hides the response
+			c.Next()
+			return
+		}
+
+		// Any origin is never allowed credentials, which a literal "*" does
+		// not carry
+		if policy.allowAll {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+			if policy.allowCredentials {
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if !preflight {
+			if policy.exposedHeaders != "" {
+				c.Header("Access-Control-Expose-Headers", policy.exposedHeaders)
+			}
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+		c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		c.Header("Access-Control-Allow-Methods", policy.methods)
+		if policy.headers != "" {
+			c.Header("Access-Control-Allow-Headers", policy.headers)
+		} else if requested := c.GetHeader("Access-Control-Request-Headers"); requested != "" {
+			// No configured list means any requested header is allowed
+			c.Header("Access-Control-Allow-Headers", requested)
+		}
+		if policy.maxAge != "" {
+			c.Header("Access-Control-Max-Age", policy.maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+func upper(values []string) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = strings.ToUpper(strings.TrimSpace(value))
+	}
+	return result
+}
@@ -1,8 +1,13 @@
 package middleware
 
 import (
+	"fmt"
+	"net/http"
 	"time"
 
+	"agent-server/internal/api/problem"
+	"agent-server/internal/config"
+
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
@@ -27,23 +32,25 @@ func Logger() gin.HandlerFunc {
 func Recovery() gin.HandlerFunc {
 	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
 		logrus.WithField("panic", recovered).Error("Panic recovered")
-		c.JSON(500, gin.H{"error": "Internal server error"})
-		c.Abort()
+		problem.Write(c, http.StatusInternalServerError, problem.Internal, "Internal server error", "")
 	})
 }
 
-// CORS returns a gin.HandlerFunc for handling CORS
-func CORS() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// HSTS returns a gin.HandlerFunc that sets Strict-Transport-Security on
+// HTTPS responses
+func HSTS(cfg config.HSTSConfig) gin.HandlerFunc {
+	value := fmt.Sprintf("max-age=%d", cfg.MaxAge)
+	if cfg.IncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if cfg.Preload {
+		value += "; preload"
+	}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+	return func(c *gin.Context) {
+		if cfg.MaxAge > 0 && c.Request.TLS != nil {
+			c.Header("Strict-Transport-Security", value)
 		}
-
 		c.Next()
 	}
-}
\ No newline at end of file
+}
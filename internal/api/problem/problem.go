@@ -0,0 +1,101 @@
+// Package problem writes API errors as RFC 7807 problem details
+// (application/problem+json) with a machine-readable error code.
+package problem
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the media type of problem responses
+const ContentType = "application/problem+json"
+
+// Code is a stable, machine-readable error code clients can branch on
+type Code string
+
+// Error codes
+const (
+	BadRequest          Code = "BAD_REQUEST"
+	InvalidBody         Code = "INVALID_BODY"
+	ValidationFailed    Code = "VALIDATION_FAILED"
+	Unauthorized        Code = "UNAUTHORIZED"
+	Forbidden           Code = "FORBIDDEN"
+	NotFound            Code = "NOT_FOUND"
+	NotAcceptable       Code = "NOT_ACCEPTABLE"
+	Conflict            Code = "CONFLICT"
+	AgentDisabled       Code = "AGENT_DISABLED"
+	SessionArchived     Code = "SESSION_ARCHIVED"
+	ProviderUnavailable Code = "PROVIDER_UNAVAILABLE"
+	ProviderError       Code = "PROVIDER_ERROR"
+	ProviderAuthFailed  Code = "PROVIDER_AUTH_FAILED"
+	ModelNotFound       Code = "MODEL_NOT_FOUND"
+	ContextOverflow     Code = "CONTEXT_OVERFLOW"
+	ToolTimeout         Code = "TOOL_TIMEOUT"
+	ToolLoopExceeded    Code = "TOOL_LOOP_EXCEEDED"
+	ToolChoiceUnmet     Code = "TOOL_CHOICE_UNMET"
+	BudgetExceeded      Code = "BUDGET_EXCEEDED"
+	ContentBlocked      Code = "CONTENT_BLOCKED"
+	RateLimited         Code = "RATE_LIMITED"
+	AgentBusy           Code = "AGENT_BUSY"
+	Timeout             Code = "TIMEOUT"
+	ServiceUnavailable  Code = "SERVICE_UNAVAILABLE"
+	Internal            Code = "INTERNAL"
+)
+
+// typePrefix makes each code a URI, as RFC 7807 requires for "type"
+const typePrefix = "urn:agent-server:problem:"
+
+// FieldError explains why a single request field was rejected
+type FieldError struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Remediation is a request the client can make to resolve the problem
+type Remediation struct {
+	Action      string                 `json:"action"` // e.g. "pull_model"
+	Description string                 `json:"description"`
+	Method      string                 `json:"method"`
+	Href        string                 `json:"href"`
+	Body        map[string]interface{} `json:"body,omitempty"`
+}
+
+// Problem is an RFC 7807 problem details object. Code, Fields and
+// Remediation are extension members.
+type Problem struct {
+	Type        string       `json:"type"`
+	Title       string       `json:"title"`
+	Status      int          `json:"status"`
+	Detail      string       `json:"detail,omitempty"`
+	Instance    string       `json:"instance,omitempty"`
+	Code        Code         `json:"code"`
+	Fields      []FieldError `json:"fields,omitempty"`
+	Remediation *Remediation `json:"remediation,omitempty"`
+}
+
+// New creates a problem for code with a short human-readable title
+func New(status int, code Code, title string) *Problem {
+	return &Problem{
+		Type:   typePrefix + strings.ReplaceAll(strings.ToLower(string(code)), "_", "-"),
+		Title:  title,
+		Status: status,
+		Code:   code,
+	}
+}
+
+// Write sends a problem response and aborts the request
+func Write(c *gin.Context, status int, code Code, title, detail string) {
+	p := New(status, code, title)
+	p.Detail = detail
+	Send(c, p)
+}
+
+// Send writes p, filling in the request path as instance
+func Send(c *gin.Context, p *Problem) {
+	if p.Instance == "" && c.Request != nil {
+		p.Instance = c.Request.URL.Path
+	}
+	c.Abort()
+	c.Render(p.Status, render{p})
+}
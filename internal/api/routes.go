@@ -1,16 +1,31 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"log/slog"
+	"net/http"
 	"os"
+	"time"
 
 	"agent-server/internal/api/handlers"
 	"agent-server/internal/api/middleware"
+	"agent-server/internal/channels"
+	"agent-server/internal/channels/discord"
+	"agent-server/internal/channels/email"
+	"agent-server/internal/channels/matrix"
 	"agent-server/internal/config"
+	"agent-server/internal/jobs"
 	contextpkg "agent-server/internal/context"
 	"agent-server/internal/llm"
+	"agent-server/internal/metrics"
+	"agent-server/internal/pubsub"
+	"agent-server/internal/queue"
 	"agent-server/internal/services"
+	"agent-server/internal/share"
 	"agent-server/internal/storage"
+	"agent-server/internal/storage/blob"
+	"agent-server/pkg/agentserver"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,12 +33,24 @@ import (
 // Server represents the HTTP server
 type Server struct {
 	router            *gin.Engine
+	runtime           *agentserver.Runtime
 	config            *config.Config
 	repo              storage.Repository
 	ctxRegistry       *contextpkg.StrategyRegistry
 	llmRegistry       *llm.Registry
 	toolService     *services.ToolService
 	chatService     *services.ChatService
+	modelPuller     *services.ModelPuller
+	blobStore       blob.Store
+	shareSigner     *share.Signer
+	chatQueue       queue.Broker
+	chatWorker      *services.ChatWorker
+	eventBus        pubsub.Bus
+	jobRunner       *jobs.Runner
+	archiveService  *services.ArchiveService
+	statusService   *services.AgentStatusService
+	accounting      *services.Accounting
+	channels        *channels.Registry
 	logger          *slog.Logger
 }
 
@@ -36,48 +63,165 @@ func NewServer(cfg *config.Config, repo storage.Repository, ctxRegistry *context
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	router := gin.New()
-	
-	// Initialize logger
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}))
-	
-	// Initialize tool service
-	toolService := services.NewToolService(repo, logger)
-	
-	// Initialize prompt service
-	promptService := services.NewPromptService(toolService)
-	
-	// Initialize unified chat service with tool support
-	chatService := services.NewChatService(repo, llmRegistry, ctxRegistry, toolService, promptService, logger)
+	// The service layer is the runtime library programs can embed
+	return newServer(gin.New(), agentserver.NewRuntime(cfg, repo, ctxRegistry, llmRegistry))
+}
+
+// newServer creates a server serving the runtime's services on router
+func newServer(router *gin.Engine, runtime *agentserver.Runtime) *Server {
+	cfg := runtime.Config
+	logger := runtime.Logger
+	chatService := runtime.Chat
+
+	// Share links stay unavailable when no signing key can be set up
+	shareSigner, err := share.NewSigner(cfg.Sharing.SigningKey)
+	if err != nil {
+		logger.Error("Failed to initialize share links", "error", err)
+	}
+
+	// In distributed mode chats are published to a Redis stream and run by
+	// worker instances
+	var chatQueue queue.Broker
+	var chatWorker *services.ChatWorker
+	if cfg.Distributed.Role != "" {
+		consumer := cfg.Distributed.Consumer
+		if consumer == "" {
+			consumer, _ = os.Hostname()
+		}
+		chatQueue = queue.NewRedis(runtime.Redis, queue.RedisOptions{
+			Stream:    cfg.Distributed.Stream,
+			Group:     cfg.Distributed.Group,
+			Consumer:  consumer,
+			ResultTTL: time.Duration(cfg.Distributed.ResultTTL) * time.Second,
+		})
+		if cfg.Distributed.Dispatches() {
+			chatService.SetBroker(chatQueue, time.Duration(cfg.Distributed.AcceptTimeout)*time.Second)
+		}
+		if cfg.Distributed.Works() {
+			chatWorker = services.NewChatWorker(chatService, chatQueue, cfg.Distributed.Workers, logger)
+		}
+		logger.Info("Distributed mode enabled", "role", cfg.Distributed.Role, "consumer", consumer)
+	}
+
+	// Events reach the clients of other instances through Redis; the
+	// in-process default needs no bus
+	var eventBus pubsub.Bus
+	if cfg.Events.Backend == config.EventsRedis {
+		eventBus = pubsub.NewRedis(runtime.Redis, cfg.Events.Prefix)
+	}
+
+	// Chat platforms answered by agents
+	channelRegistry := channels.NewRegistry(logger)
+	channelSessions := services.NewChannelSessions(runtime.Repo)
+	if cfg.Channels.Discord.Enabled {
+		discordBot := discord.New(cfg.Channels.Discord, chatService, channelSessions, logger)
+		discordBot.SetEgress(runtime.Egress.Transport(""), runtime.Egress.Proxy(""))
+		channelRegistry.Register(discordBot)
+	}
+	if cfg.Channels.Matrix.Enabled {
+		matrixBot := matrix.New(cfg.Channels.Matrix, chatService, channelSessions, logger)
+		matrixBot.SetTransport(runtime.Egress.Transport(""))
+		channelRegistry.Register(matrixBot)
+	}
+	if cfg.Channels.Email.Enabled && runtime.Mailer != nil {
+		emailChannel, err := email.New(cfg.Channels.Email, chatService, channelSessions, runtime.Mailer, logger)
+		if err != nil {
+			logger.Error("Failed to set up email channel", "error", err)
+		} else {
+			channelRegistry.Register(emailChannel)
+		}
+	}
 	
 	return &Server{
-		router:      router,
-		config:      cfg,
-		repo:        repo,
-		ctxRegistry: ctxRegistry,
-		llmRegistry: llmRegistry,
-		toolService: toolService,
-		chatService: chatService,
-		logger:      logger,
+		router:         router,
+		runtime:        runtime,
+		config:         cfg,
+		repo:           runtime.Repo,
+		ctxRegistry:    runtime.Contexts,
+		llmRegistry:    runtime.Providers,
+		toolService:    runtime.Tools,
+		chatService:    chatService,
+		modelPuller:    runtime.ModelPuller,
+		blobStore:      runtime.BlobStore,
+		shareSigner:    shareSigner,
+		chatQueue:      chatQueue,
+		chatWorker:     chatWorker,
+		eventBus:       eventBus,
+		jobRunner:      runtime.Jobs,
+		archiveService: runtime.Archive,
+		statusService:  runtime.Status,
+		accounting:     runtime.Accounting,
+		channels:       channelRegistry,
+		logger:         logger,
 	}
 }
 
+// MountOptions configure how MountRoutes adds the API to an engine
+type MountOptions struct {
+	// Runtime provides the services behind the routes
+	Runtime *agentserver.Runtime
+	// Middleware runs before every route of the API, after the server's own
+	// chain
+	Middleware []gin.HandlerFunc
+	// SkipDefaultMiddleware leaves out the server's request logging, panic
+	// recovery, HSTS and CORS, for engines that bring their own
+	SkipDefaultMiddleware bool
+}
+
+// MountRoutes adds the API to an existing engine under prefix, e.g. "/agents"
+// puts the API at /agents/api/v1. The middleware applies to the API's
+// routes only. The caller serves the engine and runs the background work
+// with StartBackground and StopBackground instead of Start.
+func MountRoutes(engine *gin.Engine, prefix string, opts MountOptions) (*Server, error) {
+	if opts.Runtime == nil {
+		return nil, errors.New("mounting the API requires a runtime")
+	}
+	s := newServer(engine, opts.Runtime)
+	s.mount(engine.Group(prefix), opts)
+	return s, nil
+}
+
 // SetupRoutes configures all routes and middleware
 func (s *Server) SetupRoutes() {
-	// Global middleware
-	s.router.Use(middleware.Logger())
-	s.router.Use(middleware.Recovery())
-	s.router.Use(middleware.CORS())
+	s.mount(s.router, MountOptions{})
+}
+
+// mount registers the middleware and the routes on router
+func (s *Server) mount(router gin.IRouter, opts MountOptions) {
+	if !opts.SkipDefaultMiddleware {
+		router.Use(middleware.Logger())
+		router.Use(middleware.Recovery())
+		if s.config.Server.TLS.Enabled {
+			router.Use(middleware.HSTS(s.config.Server.TLS.HSTS))
+		}
+		router.Use(middleware.CORS(s.config.Server.CORS))
+	}
+	if len(opts.Middleware) > 0 {
+		router.Use(opts.Middleware...)
+	}
 
 	// Health check
-	s.router.GET("/health", func(c *gin.Context) {
+	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Prometheus metrics
+	router.GET("/metrics", func(c *gin.Context) {
+		c.Header("Content-Type", metrics.ContentType)
+		c.Status(http.StatusOK)
+		if _, err := metrics.Default.WriteTo(c.Writer); err != nil {
+			s.logger.Warn("Failed to write metrics", "error", err)
+		}
+	})
+
+	// Kubernetes probes
+	healthHandler := handlers.NewHealthHandler(s.repo, s.llmRegistry, s.jobRunner, s.config.Jobs.Enabled)
+	healthHandler.SetQueue(s.chatQueue)
+	router.GET("/livez", healthHandler.Live)
+	router.GET("/readyz", healthHandler.Ready)
+
 	// API v1 routes
-	v1 := s.router.Group("/api/v1")
+	v1 := router.Group("/api/v1")
 	{
 		// Tool routes
 		toolHandler := handlers.NewToolsHandler(s.toolService)
@@ -89,17 +233,48 @@ func (s *Server) SetupRoutes() {
 			tools.POST("/:tool_name/execute", toolHandler.ExecuteTool)
 			tools.GET("/schemas", toolHandler.GetToolSchemas)
 			tools.GET("/stats", toolHandler.GetToolUsageStats)
+			tools.GET("/stats/quotas", toolHandler.GetToolQuotaUsage)
+		}
+
+		// Background executions of long-running tools and calls awaiting
+		// approval
+		toolExecutions := v1.Group("/tool-executions")
+		{
+			toolExecutions.GET("/:id", toolHandler.GetToolExecution)
+			toolExecutions.POST("/:id/approve", toolHandler.ApproveToolExecution)
+			toolExecutions.POST("/:id/reject", toolHandler.RejectToolExecution)
 		}
 
 		// Agent routes
-		agentHandler := handlers.NewAgentHandler(s.repo.Agent())
+		agentHandler := handlers.NewAgentHandler(s.repo.Agent(), s.repo.Memory())
+		agentHandler.SetToolValidator(s.statusService)
+		agentHandler.SetContextReporter(s.chatService)
+		agentHandler.SetLinter(s.statusService)
+		agentHandler.SetRedactor(s.runtime.Redactor)
+		agentHandler.SetStore(s.repo)
 		agents := v1.Group("/agents")
 		{
 			agents.POST("", agentHandler.Create)
+			agents.POST("/lint", agentHandler.LintDraft)
+			agents.POST("/import", agentHandler.Import)
 			agents.GET("", agentHandler.List)
 			agents.GET("/:id", agentHandler.GetByID)
 			agents.PUT("/:id", agentHandler.Update)
 			agents.DELETE("/:id", agentHandler.Delete)
+			agents.POST("/:id/clone", agentHandler.Clone)
+			agents.GET("/:id/export", agentHandler.Export)
+			agents.POST("/:id/disable", agentHandler.Disable)
+			agents.POST("/:id/enable", agentHandler.Enable)
+			agents.GET("/:id/lint", agentHandler.Lint)
+
+			statusHandler := handlers.NewAgentStatusHandler(s.statusService)
+			agents.GET("/:id/status", statusHandler.Get)
+
+			dryRunHandler := handlers.NewDryRunHandler(s.chatService)
+			agents.POST("/:id/test", dryRunHandler.Run)
+
+			usageHandler := handlers.NewUsageHandler(s.accounting)
+			agents.GET("/:id/usage", usageHandler.Agent)
 
 			// Session routes under agents
 			sessionHandler := handlers.NewSessionHandler(s.repo.Session(), s.repo.Agent())
@@ -108,32 +283,130 @@ func (s *Server) SetupRoutes() {
 		}
 
 		// Session routes
+		shareHandler := handlers.NewShareHandler(s.repo, s.shareSigner, s.config.Sharing.BaseURL,
+			time.Duration(s.config.Sharing.Expiry)*time.Second, time.Duration(s.config.Sharing.MaxExpiry)*time.Second)
 		sessionHandler := handlers.NewSessionHandler(s.repo.Session(), s.repo.Agent())
 		sessions := v1.Group("/sessions")
 		{
 			sessions.GET("/:id", sessionHandler.GetByID)
 			sessions.PUT("/:id", sessionHandler.Update)
 			sessions.DELETE("/:id", sessionHandler.Delete)
+			sessions.POST("/:id/read", sessionHandler.MarkRead)
+			sessions.PATCH("/:id/variables", sessionHandler.PatchVariables)
+
+			// Archival routes
+			archiveHandler := handlers.NewArchiveHandler(s.archiveService, s.jobRunner, s.config.Storage.Archive.IdleDays)
+			sessions.POST("/:id/archive", archiveHandler.Archive)
+			sessions.POST("/:id/unarchive", archiveHandler.Unarchive)
+			sessions.POST("/:id/compact", archiveHandler.Compact)
+			sessions.GET("/:id/compactions", archiveHandler.ListCompactions)
+			sessions.GET("/:id/compactions/:archive_id/messages", archiveHandler.CompactedMessages)
 
 			// Message routes under sessions
-			messageHandler := handlers.NewMessageHandler(s.repo.Message())
+			messageHandler := handlers.NewMessageHandler(s.repo.Message(), s.chatService)
 			sessions.POST("/:id/messages", messageHandler.Create)
 			sessions.GET("/:id/messages", messageHandler.ListBySession)
 			sessions.DELETE("/:id/messages", messageHandler.DeleteBySession)
+			sessions.POST("/:id/notes", messageHandler.CreateNote)
+
+			turnHandler := handlers.NewTurnHandler(s.repo)
+			sessions.GET("/:id/turns", turnHandler.ListBySession)
+			sessions.GET("/:id/turns/:turn_id/trace", turnHandler.Trace)
+
+			usageHandler := handlers.NewUsageHandler(s.accounting)
+			sessions.GET("/:id/usage", usageHandler.Session)
+
+			sessions.POST("/:id/share", shareHandler.Create)
+
+			handoffHandler := handlers.NewHandoffHandler(s.chatService)
+			sessions.POST("/:id/transfer", handoffHandler.Transfer)
 
 			// Chat routes with tool calling support
 			chatHandler := handlers.NewChatHandler(s.chatService, s.toolService, s.logger)
+			chatHandler.SetKeepAliveInterval(time.Duration(s.config.Server.StreamKeepAlive) * time.Second)
 			sessions.POST("/:id/chat", chatHandler.Chat)
 			sessions.POST("/:id/stream", chatHandler.Stream)
 			sessions.POST("/:id/chat/tools", chatHandler.ChatWithTools)
 			sessions.POST("/:id/chat/auto-tools", chatHandler.ChatWithAutoTools)
+
+			// Chat and presence events over WebSockets
+			socketHandler := handlers.NewSocketHandler(s.repo.Session(), s.chatService,
+				middleware.NewCORSPolicy(s.config.Server.CORS).AllowsOrigin, s.logger)
+			if s.eventBus != nil {
+				socketHandler.SetEventBus(s.eventBus)
+			}
+			sessions.GET("/:id/ws", socketHandler.Serve)
 			
 			// Tool-related routes for sessions
 			sessions.GET("/:id/tools", chatHandler.ListAvailableTools)
 			sessions.GET("/:id/tools/:tool_name/schema", chatHandler.GetToolSchema)
 			sessions.POST("/:id/tools/:tool_name/test", chatHandler.TestToolForSession)
 			sessions.GET("/:id/tool-calls", chatHandler.GetToolCallHistory)
+			sessions.GET("/:id/tool-executions", chatHandler.GetToolExecutionLog)
+		}
+
+		// LLM providers and model downloads
+		providerHandler := handlers.NewProviderHandler(s.llmRegistry, s.modelPuller)
+		providers := v1.Group("/providers")
+		{
+			providers.GET("", providerHandler.List)
+			providers.GET("/:name/pulls", providerHandler.ListPulls)
+			providers.POST("/:name/pulls", providerHandler.Pull)
+		}
+
+		// Agent-to-agent simulations
+		simulationHandler := handlers.NewSimulationHandler(services.NewSimulator(s.repo, s.chatService, s.logger), s.logger)
+		v1.POST("/simulations", simulationHandler.Run)
+
+		// Admin routes
+		jobHandler := handlers.NewJobHandler(s.repo.Job())
+		admin := v1.Group("/admin")
+		{
+			admin.GET("/jobs", jobHandler.List)
+			admin.GET("/jobs/:id", jobHandler.GetByID)
+			admin.POST("/jobs/:id/requeue", jobHandler.Requeue)
+
+			archiveHandler := handlers.NewArchiveHandler(s.archiveService, s.jobRunner, s.config.Storage.Archive.IdleDays)
+			admin.POST("/sessions/archive-idle", archiveHandler.ArchiveIdle)
+
+			statsHandler := handlers.NewStatsHandler(services.NewStatsService(s.repo))
+			admin.GET("/stats", statsHandler.Get)
+
+			channelHandler := handlers.NewChannelHandler(s.channels)
+			admin.GET("/channels", channelHandler.List)
+
+			alertHandler := handlers.NewAlertHandler(s.runtime.Alerts)
+			admin.GET("/alerts", alertHandler.List)
+
+			flagHandler := handlers.NewFeatureFlagHandler(s.runtime.Features)
+			admin.GET("/flags", flagHandler.List)
+			admin.PUT("/flags/:name", flagHandler.Set)
+			admin.DELETE("/flags/:name", flagHandler.Delete)
+
+			toolStateHandler := handlers.NewToolStateHandler(s.toolService)
+			admin.GET("/tools", toolStateHandler.List)
+			admin.PUT("/tools/:name", toolStateHandler.Set)
+			admin.DELETE("/tools/:name", toolStateHandler.Delete)
+
+			credentialHandler := handlers.NewCredentialHandler(s.runtime.OAuth2)
+			admin.GET("/credentials", credentialHandler.List)
+			admin.POST("/credentials/:name/refresh", credentialHandler.Refresh)
+		}
+
+		// Inbound webhooks of chat channels
+		if channel, ok := s.channels.Get(email.Platform); ok {
+			emailChannel := channel.(*email.Channel)
+			emailHandler := handlers.NewEmailHandler(emailChannel, emailChannel.WebhookSecret())
+			v1.POST("/channels/email/inbound", emailHandler.Inbound)
 		}
+
+		// Signed blob downloads
+		blobHandler := handlers.NewBlobHandler(s.blobStore)
+		v1.GET("/blobs/*key", blobHandler.Download)
+
+		// Read-only transcripts of shared sessions; the token is the credential
+		v1.GET("/shared/:token", shareHandler.Get)
+		router.GET("/share/:token", shareHandler.Render)
 	}
 }
 
@@ -142,7 +415,60 @@ func (s *Server) GetRouter() *gin.Engine {
 	return s.router
 }
 
-// Start starts the HTTP server
+// JobRunner returns the background job runner
+func (s *Server) JobRunner() *jobs.Runner {
+	return s.jobRunner
+}
+
+// Runtime returns the service layer the server serves
+func (s *Server) Runtime() *agentserver.Runtime {
+	return s.runtime
+}
+
+// StartBackground starts the background job runner, the chat worker and
+// the chat channels. Start calls it; servers mounted with MountRoutes call
+// it themselves.
+func (s *Server) StartBackground(ctx context.Context) {
+	s.runtime.Start(ctx)
+
+	if s.chatWorker != nil {
+		s.chatWorker.Start(ctx)
+	}
+
+	// A channel that fails to start is reported by GET /admin/channels
+	s.channels.Start(ctx)
+}
+
+// StopBackground stops what StartBackground started
+func (s *Server) StopBackground() {
+	s.channels.Stop()
+	if s.chatWorker != nil {
+		s.chatWorker.Stop()
+	}
+	s.runtime.Stop()
+}
+
+// Start starts the background work and the HTTP server
 func (s *Server) Start() error {
-	return s.router.Run(s.config.GetAddress())
+	s.StartBackground(context.Background())
+	defer s.StopBackground()
+
+	server := &http.Server{
+		Addr:    s.config.GetAddress(),
+		Handler: s.router,
+	}
+
+	tlsCfg := s.config.Server.TLS
+	if !tlsCfg.Enabled {
+		return server.ListenAndServe()
+	}
+
+	tlsConfig, err := newTLSConfig(tlsCfg, s.logger)
+	if err != nil {
+		return err
+	}
+	server.TLSConfig = tlsConfig
+	s.logger.Info("Serving HTTPS", "client_auth", tlsCfg.ClientAuth, "min_version", tlsCfg.MinVersion)
+	// Certificates come from TLSConfig so that rotated files are reloaded
+	return server.ListenAndServeTLS("", "")
 }
\ No newline at end of file
@@ -0,0 +1,59 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"agent-server/internal/api"
+	"agent-server/internal/config"
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/storage/sqlite"
+	"agent-server/pkg/agentserver"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMountRoutes(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	runtime := agentserver.NewRuntime(config.Default(), repo, contextpkg.NewStrategyRegistry(), llm.NewRegistry())
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/own", func(c *gin.Context) { c.String(http.StatusOK, "own") })
+
+	_, err = api.MountRoutes(engine, "/agents", api.MountOptions{})
+	assert.Error(t, err)
+
+	_, err = api.MountRoutes(engine, "/agents", api.MountOptions{
+		Runtime: runtime,
+		Middleware: []gin.HandlerFunc{func(c *gin.Context) {
+			c.Header("X-Mounted", "yes")
+		}},
+		SkipDefaultMiddleware: true,
+	})
+	require.NoError(t, err)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// The API lives under the prefix and runs the injected middleware
+	w := serve("/agents/health")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "yes", w.Header().Get("X-Mounted"))
+	assert.Equal(t, http.StatusOK, serve("/agents/api/v1/tools").Code)
+	assert.Equal(t, http.StatusNotFound, serve("/api/v1/tools").Code)
+
+	// The engine's own routes are left alone
+	w = serve("/own")
+	assert.Equal(t, "own", w.Body.String())
+	assert.Empty(t, w.Header().Get("X-Mounted"))
+}
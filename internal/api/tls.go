@@ -0,0 +1,151 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"agent-server/internal/config"
+)
+
+// certReloader serves the configured certificate and client CA pool and
+// reloads them when the files change, so rotated certificates are picked up
+// without a restart
+type certReloader struct {
+	certFile, keyFile, caFile string
+	interval                  time.Duration
+	logger                    *slog.Logger
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+	checkedAt time.Time
+}
+
+func newCertReloader(cfg config.TLSConfig, logger *slog.Logger) (*certReloader, error) {
+	r := &certReloader{
+		logger:   logger,
+		certFile: cfg.CertFile,
+		keyFile:  cfg.KeyFile,
+		caFile:   cfg.ClientCAFile,
+		interval: time.Duration(cfg.ReloadInterval) * time.Second,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// files returns the paths whose modification times trigger a reload
+func (r *certReloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
+
+// load reads the key pair and client CA bundle
+func (r *certReloader) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", file, err)
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in client CA file %s", r.caFile)
+		}
+	}
+
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	return nil
+}
+
+// current returns the certificate and CA pool, reloading them first if the
+// files changed since the last check. A failed reload keeps serving the
+// previous certificate.
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.interval > 0 && time.Since(r.checkedAt) >= r.interval {
+		r.checkedAt = time.Now()
+		if r.changed() {
+			if err := r.load(); err != nil {
+				r.logger.Error("Failed to reload TLS certificate, keeping the previous one", "error", err)
+			} else {
+				r.logger.Info("Reloaded TLS certificate", "cert_file", r.certFile)
+			}
+		}
+	}
+	return r.cert, r.clientCAs
+}
+
+func (r *certReloader) changed() bool {
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil || !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// newTLSConfig builds the server TLS configuration, including client
+// certificate verification for mutual TLS
+func newTLSConfig(cfg config.TLSConfig, logger *slog.Logger) (*tls.Config, error) {
+	reloader, err := newCertReloader(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	base := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.MinVersion == "1.3" {
+		base.MinVersion = tls.VersionTLS13
+	}
+	switch cfg.ClientAuth {
+	case "optional":
+		base.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		base.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	base.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, _ := reloader.current()
+		return cert, nil
+	}
+	// Per-connection configs let a rotated client CA bundle take effect too
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cert, clientCAs := reloader.current()
+		conf := base.Clone()
+		conf.GetConfigForClient = nil
+		conf.Certificates = []tls.Certificate{*cert}
+		conf.ClientCAs = clientCAs
+		return conf, nil
+	}
+
+	return base, nil
+}
@@ -0,0 +1,160 @@
+// Package channels connects agents to external chat platforms. Each platform
+// is a Channel plugin, e.g. the Discord bot or the Matrix bot, that maps its
+// conversations to sessions and relays the agent's answers. The server runs
+// the configured channels through a Registry.
+package channels
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+)
+
+// Channel is a connection to a chat platform
+type Channel interface {
+	// Name identifies the platform, e.g. "discord"; it is also the platform
+	// of the channel's session bindings
+	Name() string
+	// Start connects to the platform; long-running work continues in the
+	// background until Stop
+	Start(ctx context.Context) error
+	// Stop disconnects and waits for answers in progress
+	Stop()
+}
+
+// Chat is the part of the chat service channels drive
+type Chat interface {
+	Stream(ctx context.Context, req *services.ChatRequest) (<-chan services.StreamChunk, error)
+	StreamWithTools(ctx context.Context, req *models.EnhancedChatRequest, sessionID string) (<-chan services.ToolChatEvent, error)
+}
+
+// Sessions maps platform conversations to sessions; it is implemented by
+// services.ChannelSessions
+type Sessions interface {
+	Resolve(ctx context.Context, platform, externalID, agentID, title string) (*models.ChatSession, error)
+	Find(ctx context.Context, platform, externalID, agentID string) (*models.ChatSession, error)
+	Bind(ctx context.Context, platform, externalID string, session *models.ChatSession) error
+}
+
+// Turn is one message to answer
+type Turn struct {
+	SessionID string
+	Message   string
+	// Tools is the tool allowlist; without tools the answer is streamed
+	Tools    []string
+	Metadata map[string]interface{}
+}
+
+// Reply shows an answer on the platform while it is generated
+type Reply interface {
+	// Update shows the answer so far; the final update has the whole answer
+	Update(ctx context.Context, text string, final bool) error
+	// Status shows that the agent is working before the answer is ready;
+	// tool is the tool being called, or empty while the agent thinks
+	Status(ctx context.Context, tool string) error
+}
+
+// Answer runs a chat turn and shows its progress in reply. Without tools
+// the answer is streamed token by token; with tools the reply shows which
+// tool runs until the answer is ready.
+func Answer(ctx context.Context, chat Chat, turn Turn, reply Reply) error {
+	if len(turn.Tools) == 0 {
+		return streamAnswer(ctx, chat, turn, reply)
+	}
+
+	events, err := chat.StreamWithTools(ctx, &models.EnhancedChatRequest{
+		Message:    turn.Message,
+		Tools:      turn.Tools,
+		ToolChoice: "auto",
+		Metadata:   turn.Metadata,
+	}, turn.SessionID)
+	if err != nil {
+		return err
+	}
+
+	if err := reply.Status(ctx, ""); err != nil {
+		return err
+	}
+	for event := range events {
+		switch event.Type {
+		case services.EventToolCallStarted:
+			if err := reply.Status(ctx, event.ToolName); err != nil {
+				return err
+			}
+		case services.EventDone:
+			return reply.Update(ctx, event.Response.Response, true)
+		case services.EventError:
+			return event.Err
+		}
+	}
+	return errIncomplete
+}
+
+// errIncomplete is returned when a chat ends without its final event
+var errIncomplete = errors.New("reply stream ended before the reply was complete")
+
+func streamAnswer(ctx context.Context, chat Chat, turn Turn, reply Reply) error {
+	chunks, err := chat.Stream(ctx, &services.ChatRequest{
+		SessionID: turn.SessionID,
+		Message:   turn.Message,
+		Metadata:  turn.Metadata,
+		Stream:    true,
+	})
+	if err != nil {
+		return err
+	}
+
+	var response strings.Builder
+	done := false
+	for chunk := range chunks {
+		response.WriteString(chunk.Content)
+		if chunk.Done {
+			done = true
+			continue
+		}
+		if err := reply.Update(ctx, response.String(), false); err != nil {
+			return err
+		}
+	}
+	if !done {
+		return errIncomplete
+	}
+	return reply.Update(ctx, response.String(), true)
+}
+
+// ErrorText explains a failed answer to the platform user without exposing
+// internal details
+func ErrorText(err error) string {
+	switch {
+	case errors.Is(err, services.ErrAgentDisabled):
+		return "This agent is currently disabled."
+	case errors.Is(err, services.ErrBudgetExceeded):
+		return "The spending budget for this conversation is used up."
+	case errors.Is(err, services.ErrProviderUnavailable):
+		return "The language model is unavailable right now. Please try again later."
+	case errors.Is(err, services.ErrAgentNotFound):
+		return "This conversation's agent no longer exists."
+	default:
+		return "Sorry, something went wrong while answering."
+	}
+}
+
+// Settings are the agent and tools answering one conversation
+type Settings struct {
+	AgentID string
+	Tools   []string
+}
+
+// Merge fills what s leaves unset from defaults
+func (s Settings) Merge(defaults Settings) Settings {
+	if s.AgentID == "" {
+		s.AgentID = defaults.AgentID
+	}
+	if s.Tools == nil {
+		s.Tools = defaults.Tools
+	}
+	return s
+}
@@ -0,0 +1,306 @@
+// Package discord connects agents to Discord. The bot maps guild channels
+// and threads to agent sessions and streams replies by editing its messages
+// as tokens arrive.
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"agent-server/internal/channels"
+	"agent-server/internal/config"
+)
+
+// Platform names Discord conversations in channel bindings
+const Platform = "discord"
+
+// defaultEditInterval keeps streamed edits under Discord's rate limits
+const defaultEditInterval = time.Second
+
+// User is the part of a Discord user object the bot uses
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Bot      bool   `json:"bot,omitempty"`
+}
+
+// Message is the part of a MESSAGE_CREATE event the bot uses
+type Message struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	GuildID   string `json:"guild_id,omitempty"`
+	Author    User   `json:"author"`
+	Content   string `json:"content"`
+	Mentions  []User `json:"mentions,omitempty"`
+}
+
+// Bot answers Discord messages with agents
+type Bot struct {
+	cfg          config.DiscordConfig
+	chat         channels.Chat
+	sessions     channels.Sessions
+	rest         *rest
+	gateway      *gatewayConn
+	editInterval time.Duration
+	logger       *slog.Logger
+
+	mu       sync.Mutex
+	userID   string
+	channels map[string]*Channel
+	locks    map[string]*sync.Mutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a Discord bot from its configuration
+func New(cfg config.DiscordConfig, chat channels.Chat, sessions channels.Sessions, logger *slog.Logger) *Bot {
+	editInterval := time.Duration(cfg.EditInterval) * time.Millisecond
+	if editInterval <= 0 {
+		editInterval = defaultEditInterval
+	}
+	return &Bot{
+		cfg:          cfg,
+		chat:         chat,
+		sessions:     sessions,
+		rest:         newREST(DefaultAPIURL, cfg.BotToken, nil),
+		gateway:      &gatewayConn{url: DefaultGatewayURL, token: cfg.BotToken},
+		editInterval: editInterval,
+		logger:       logger,
+		channels:     make(map[string]*Channel),
+		locks:        make(map[string]*sync.Mutex),
+	}
+}
+
+// Name returns the platform name
+func (b *Bot) Name() string {
+	return Platform
+}
+
+// SetEgress routes REST calls through transport and the gateway connection
+// through the proxy the egress policy selects
+func (b *Bot) SetEgress(transport http.RoundTripper, proxy func(*http.Request) (*url.URL, error)) {
+	b.rest.client = &http.Client{Timeout: 30 * time.Second, Transport: transport}
+	b.gateway.proxy = proxy
+}
+
+// Start checks the bot token and connects to the gateway in the background
+func (b *Bot) Start(ctx context.Context) error {
+	user, err := b.rest.CurrentUser(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to verify discord bot token: %w", err)
+	}
+	b.setUserID(user.ID)
+
+	ctx, b.cancel = context.WithCancel(ctx)
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.gateway.run(ctx, func(eventType string, data json.RawMessage) {
+			b.dispatch(ctx, eventType, data)
+		}, func(msg string, args ...interface{}) {
+			b.logger.Warn(msg, args...)
+		})
+	}()
+
+	b.logger.Info("Discord bot started", "user", user.Username, "channels", len(b.cfg.Channels))
+	return nil
+}
+
+// Stop disconnects from the gateway and waits for replies in progress
+func (b *Bot) Stop() {
+	if b.cancel == nil {
+		return
+	}
+	b.cancel()
+	b.wg.Wait()
+}
+
+// dispatch handles a gateway event
+func (b *Bot) dispatch(ctx context.Context, eventType string, data json.RawMessage) {
+	switch eventType {
+	case "READY":
+		var ready readyData
+		if err := json.Unmarshal(data, &ready); err == nil && ready.User.ID != "" {
+			b.setUserID(ready.User.ID)
+		}
+	case "MESSAGE_CREATE":
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			b.logger.Warn("Invalid Discord message event", "error", err)
+			return
+		}
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.handleMessage(ctx, &msg)
+		}()
+	}
+}
+
+// handleMessage answers a message in a channel the bot serves. Messages of a
+// channel are answered one at a time, in order.
+func (b *Bot) handleMessage(ctx context.Context, msg *Message) {
+	if msg.Author.Bot || msg.Author.ID == b.botUserID() || msg.GuildID == "" {
+		return
+	}
+
+	settings, ok := b.channelSettings(ctx, msg.ChannelID)
+	if !ok {
+		return
+	}
+
+	text, mentioned := b.stripMention(msg)
+	if b.cfg.RequireMention && !mentioned {
+		return
+	}
+	if text == "" {
+		return
+	}
+
+	lock := b.channelLock(msg.ChannelID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	logger := b.logger.With("channel_id", msg.ChannelID, "message_id", msg.ID)
+	reply := newStreamedReply(b.rest, msg.ChannelID, msg.ID, b.editInterval)
+
+	session, err := b.sessions.Resolve(ctx, Platform, msg.ChannelID, settings.AgentID, b.sessionTitle(ctx, msg.ChannelID))
+	if err != nil {
+		logger.Error("Failed to resolve Discord session", "error", err)
+		if err := reply.fail(ctx, channels.ErrorText(err)); err != nil {
+			logger.Warn("Failed to post Discord reply", "error", err)
+		}
+		return
+	}
+
+	if err := b.rest.TriggerTyping(ctx, msg.ChannelID); err != nil {
+		logger.Debug("Failed to trigger typing", "error", err)
+	}
+
+	err = channels.Answer(ctx, b.chat, channels.Turn{
+		SessionID: session.ID,
+		Message:   text,
+		Tools:     settings.Tools,
+		Metadata: map[string]interface{}{
+			"channel":            Platform,
+			"discord_message_id": msg.ID,
+			"discord_channel_id": msg.ChannelID,
+			"discord_author_id":  msg.Author.ID,
+			"discord_author":     msg.Author.Username,
+		},
+	}, reply)
+	if err != nil {
+		logger.Error("Failed to answer Discord message", "session_id", session.ID, "error", err)
+		if err := reply.fail(ctx, channels.ErrorText(err)); err != nil {
+			logger.Warn("Failed to post Discord reply", "error", err)
+		}
+	}
+}
+
+// channelSettings returns the agent and tools of a channel, and false when
+// the bot does not serve it. Threads use their parent channel's settings.
+func (b *Bot) channelSettings(ctx context.Context, channelID string) (channels.Settings, bool) {
+	if len(b.cfg.Channels) == 0 {
+		return b.defaults(), b.cfg.AgentID != ""
+	}
+
+	channelCfg, ok := b.cfg.Channels[channelID]
+	if !ok {
+		channel, err := b.channel(ctx, channelID)
+		if err != nil {
+			b.logger.Warn("Failed to look up Discord channel", "channel_id", channelID, "error", err)
+			return channels.Settings{}, false
+		}
+		if !channel.IsThread() {
+			return channels.Settings{}, false
+		}
+		if channelCfg, ok = b.cfg.Channels[channel.ParentID]; !ok {
+			return channels.Settings{}, false
+		}
+	}
+
+	return channels.Settings{AgentID: channelCfg.AgentID, Tools: channelCfg.Tools}.Merge(b.defaults()), true
+}
+
+// defaults returns the agent and tools of channels without their own
+func (b *Bot) defaults() channels.Settings {
+	return channels.Settings{AgentID: b.cfg.AgentID, Tools: b.cfg.Tools}
+}
+
+// channel returns a channel, fetching it once
+func (b *Bot) channel(ctx context.Context, channelID string) (*Channel, error) {
+	b.mu.Lock()
+	channel, ok := b.channels[channelID]
+	b.mu.Unlock()
+	if ok {
+		return channel, nil
+	}
+
+	channel, err := b.rest.GetChannel(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	b.channels[channelID] = channel
+	b.mu.Unlock()
+	return channel, nil
+}
+
+// sessionTitle names the session of a channel after it
+func (b *Bot) sessionTitle(ctx context.Context, channelID string) string {
+	if channel, err := b.channel(ctx, channelID); err == nil && channel.Name != "" {
+		return "Discord #" + channel.Name
+	}
+	return "Discord channel " + channelID
+}
+
+// stripMention removes mentions of the bot from a message and reports
+// whether there were any
+func (b *Bot) stripMention(msg *Message) (string, bool) {
+	userID := b.botUserID()
+	mentioned := false
+	for _, user := range msg.Mentions {
+		if user.ID == userID {
+			mentioned = true
+		}
+	}
+
+	text := msg.Content
+	if userID != "" {
+		text = strings.ReplaceAll(text, "<@"+userID+">", "")
+		text = strings.ReplaceAll(text, "<@!"+userID+">", "")
+	}
+	return strings.TrimSpace(text), mentioned
+}
+
+// channelLock returns the lock that orders the replies of a channel
+func (b *Bot) channelLock(channelID string) *sync.Mutex {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lock, ok := b.locks[channelID]
+	if !ok {
+		lock = &sync.Mutex{}
+		b.locks[channelID] = lock
+	}
+	return lock
+}
+
+func (b *Bot) botUserID() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.userID
+}
+
+func (b *Bot) setUserID(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.userID = id
+}
@@ -0,0 +1,265 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"agent-server/internal/channels"
+	"agent-server/internal/config"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+// fakeDiscord records REST calls and serves the few endpoints the bot uses
+type fakeDiscord struct {
+	mu       sync.Mutex
+	channels map[string]Channel
+	calls    []string
+	messages map[string]string // message ID to content
+	nextID   int
+}
+
+func (f *fakeDiscord) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body outgoingMessage
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	f.calls = append(f.calls, r.Method+" "+r.URL.Path)
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/users/@me":
+		json.NewEncoder(w).Encode(User{ID: "bot", Username: "agentbot", Bot: true})
+	case len(parts) == 2 && parts[0] == "channels":
+		channel, ok := f.channels[parts[1]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(channel)
+	case len(parts) == 3 && parts[2] == "typing":
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 3 && parts[2] == "messages":
+		f.nextID++
+		id := fmt.Sprintf("reply-%d", f.nextID)
+		f.messages[id] = body.Content
+		json.NewEncoder(w).Encode(map[string]string{"id": id})
+	case len(parts) == 4 && r.Method == http.MethodPatch:
+		f.messages[parts[3]] = body.Content
+		json.NewEncoder(w).Encode(map[string]string{"id": parts[3]})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeDiscord) message(id string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.messages[id]
+}
+
+func (f *fakeDiscord) count(call string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, c := range f.calls {
+		if c == call {
+			n++
+		}
+	}
+	return n
+}
+
+// fakeChat streams a fixed reply and records the requests it got
+type fakeChat struct {
+	mu       sync.Mutex
+	requests []string
+	tools    [][]string
+	sessions []string
+}
+
+func (c *fakeChat) record(sessionID, message string, tools []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sessions = append(c.sessions, sessionID)
+	c.requests = append(c.requests, message)
+	c.tools = append(c.tools, tools)
+}
+
+func (c *fakeChat) Stream(ctx context.Context, req *services.ChatRequest) (<-chan services.StreamChunk, error) {
+	c.record(req.SessionID, req.Message, nil)
+	chunks := make(chan services.StreamChunk, 4)
+	for _, token := range []string{"Hello", " from", " the agent"} {
+		chunks <- services.StreamChunk{Content: token}
+	}
+	chunks <- services.StreamChunk{Done: true, MessageID: "assistant-1"}
+	close(chunks)
+	return chunks, nil
+}
+
+func (c *fakeChat) StreamWithTools(ctx context.Context, req *models.EnhancedChatRequest, sessionID string) (<-chan services.ToolChatEvent, error) {
+	c.record(sessionID, req.Message, req.Tools)
+	events := make(chan services.ToolChatEvent, 3)
+	events <- services.ToolChatEvent{Type: services.EventToolCallStarted, ToolName: "calculator"}
+	events <- services.ToolChatEvent{Type: services.EventDone, Response: &models.EnhancedChatResponse{Response: "It is 4"}}
+	close(events)
+	return events, nil
+}
+
+// fakeSessions hands out one session per channel
+type fakeSessions struct{}
+
+func (fakeSessions) Resolve(ctx context.Context, platform, externalID, agentID, title string) (*models.ChatSession, error) {
+	return &models.ChatSession{ID: platform + ":" + externalID, AgentID: agentID, Title: title}, nil
+}
+
+func (fakeSessions) Find(ctx context.Context, platform, externalID, agentID string) (*models.ChatSession, error) {
+	return nil, nil
+}
+
+func (fakeSessions) Bind(ctx context.Context, platform, externalID string, session *models.ChatSession) error {
+	return nil
+}
+
+func newTestBot(t *testing.T, cfg config.DiscordConfig, api *fakeDiscord, chat channels.Chat) *Bot {
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	cfg.BotToken = "test-token"
+	cfg.EditInterval = 1
+	bot := New(cfg, chat, fakeSessions{}, slog.Default())
+	bot.rest.baseURL = server.URL
+	return bot
+}
+
+func TestBot_GatewayMessage(t *testing.T) {
+	api := &fakeDiscord{
+		channels: map[string]Channel{"general": {ID: "general", Name: "general"}},
+		messages: map[string]string{},
+	}
+	chat := &fakeChat{}
+	bot := newTestBot(t, config.DiscordConfig{AgentID: "agent-1", RequireMention: true}, api, chat)
+
+	identified := make(chan identifyData, 1)
+	gateway := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		websocket.JSON.Send(ws, map[string]interface{}{"op": opHello, "d": map[string]int{"heartbeat_interval": 60000}})
+
+		var identify struct {
+			Op   int          `json:"op"`
+			Data identifyData `json:"d"`
+		}
+		if err := websocket.JSON.Receive(ws, &identify); err != nil || identify.Op != opIdentify {
+			return
+		}
+		identified <- identify.Data
+
+		seq := 1
+		dispatch := func(eventType string, data interface{}) {
+			websocket.JSON.Send(ws, map[string]interface{}{"op": opDispatch, "t": eventType, "s": seq, "d": data})
+			seq++
+		}
+		dispatch("READY", map[string]interface{}{"session_id": "gw-1", "user": User{ID: "bot"}})
+		// Without a mention the message is ignored
+		dispatch("MESSAGE_CREATE", Message{ID: "m0", ChannelID: "general", GuildID: "g", Author: User{ID: "u1"}, Content: "chatter"})
+		dispatch("MESSAGE_CREATE", Message{
+			ID: "m1", ChannelID: "general", GuildID: "g",
+			Author:   User{ID: "u1", Username: "alice"},
+			Content:  "<@bot> hi there",
+			Mentions: []User{{ID: "bot"}},
+		})
+		io.Copy(io.Discard, ws)
+	}))
+	defer gateway.Close()
+	bot.gateway.url = "ws" + strings.TrimPrefix(gateway.URL, "http")
+
+	require.NoError(t, bot.Start(context.Background()))
+	defer bot.Stop()
+
+	select {
+	case data := <-identified:
+		assert.Equal(t, "test-token", data.Token)
+		assert.Equal(t, gatewayIntents, data.Intents)
+	case <-time.After(5 * time.Second):
+		t.Fatal("bot did not identify")
+	}
+
+	require.Eventually(t, func() bool {
+		return api.message("reply-1") == "Hello from the agent"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	chat.mu.Lock()
+	assert.Equal(t, []string{"hi there"}, chat.requests)
+	assert.Equal(t, []string{"discord:general"}, chat.sessions)
+	chat.mu.Unlock()
+	assert.Equal(t, 1, api.count("POST /channels/general/messages"))
+}
+
+func TestBot_ChannelSettings(t *testing.T) {
+	api := &fakeDiscord{
+		channels: map[string]Channel{
+			"thread-1": {ID: "thread-1", Type: channelTypePublicThread, ParentID: "support", Name: "printer broken"},
+			"random":   {ID: "random", Name: "random"},
+		},
+		messages: map[string]string{},
+	}
+	chat := &fakeChat{}
+	bot := newTestBot(t, config.DiscordConfig{
+		AgentID: "default-agent",
+		Channels: map[string]config.DiscordChannelConfig{
+			"support": {AgentID: "support-agent", Tools: []string{"calculator"}},
+			"general": {},
+		},
+	}, api, chat)
+	ctx := context.Background()
+
+	settings, ok := bot.channelSettings(ctx, "general")
+	assert.True(t, ok)
+	assert.Equal(t, "default-agent", settings.AgentID)
+	assert.Empty(t, settings.Tools)
+
+	// Threads use their parent channel's settings but get their own session
+	settings, ok = bot.channelSettings(ctx, "thread-1")
+	assert.True(t, ok)
+	assert.Equal(t, "support-agent", settings.AgentID)
+	assert.Equal(t, []string{"calculator"}, settings.Tools)
+
+	_, ok = bot.channelSettings(ctx, "random")
+	assert.False(t, ok)
+
+	bot.handleMessage(ctx, &Message{ID: "m1", ChannelID: "thread-1", GuildID: "g", Author: User{ID: "u1"}, Content: "what is 2+2?"})
+	assert.Equal(t, "It is 4", api.message("reply-1"))
+	assert.Equal(t, [][]string{{"calculator"}}, chat.tools)
+	assert.Equal(t, []string{"discord:thread-1"}, chat.sessions)
+
+	// Bots, including this one, are never answered
+	bot.handleMessage(ctx, &Message{ID: "m2", ChannelID: "general", GuildID: "g", Author: User{ID: "other", Bot: true}, Content: "beep"})
+	assert.Len(t, chat.requests, 1)
+}
+
+func TestSplitMessage(t *testing.T) {
+	assert.Equal(t, []string{"short"}, splitMessage("short", 20))
+
+	parts := splitMessage("first line here\nsecond line here", 20)
+	assert.Equal(t, []string{"first line here", "second line here"}, parts)
+
+	long := strings.Repeat("é", 15)
+	parts = splitMessage(long, 9)
+	for _, part := range parts {
+		assert.LessOrEqual(t, len(part), 9)
+	}
+	assert.Equal(t, long, strings.Join(parts, ""))
+}
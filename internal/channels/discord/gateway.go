@@ -0,0 +1,404 @@
+package discord
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// DefaultGatewayURL is the Discord gateway websocket endpoint
+const DefaultGatewayURL = "wss://gateway.discord.gg/?v=10&encoding=json"
+
+// Gateway opcodes
+const (
+	opDispatch       = 0
+	opHeartbeat      = 1
+	opIdentify       = 2
+	opResume         = 6
+	opReconnect      = 7
+	opInvalidSession = 9
+	opHello          = 10
+	opHeartbeatACK   = 11
+)
+
+// Gateway intents the bot subscribes to. MESSAGE_CONTENT is privileged and
+// must be enabled for the bot in the Discord developer portal.
+const (
+	intentGuilds         = 1 << 0
+	intentGuildMessages  = 1 << 9
+	intentMessageContent = 1 << 15
+
+	gatewayIntents = intentGuilds | intentGuildMessages | intentMessageContent
+)
+
+// Reconnect backoff bounds
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+)
+
+// errReconnect ends a connection that should be resumed on a new one
+var errReconnect = errors.New("gateway asked to reconnect")
+
+// payload is a gateway message
+type payload struct {
+	Op   int             `json:"op"`
+	Data json.RawMessage `json:"d,omitempty"`
+	Seq  *int64          `json:"s,omitempty"`
+	Type string          `json:"t,omitempty"`
+}
+
+// outgoingPayload is a gateway message sent by the bot
+type outgoingPayload struct {
+	Op   int         `json:"op"`
+	Data interface{} `json:"d"`
+}
+
+type identifyData struct {
+	Token      string             `json:"token"`
+	Intents    int                `json:"intents"`
+	Properties identifyProperties `json:"properties"`
+}
+
+type identifyProperties struct {
+	OS      string `json:"os"`
+	Browser string `json:"browser"`
+	Device  string `json:"device"`
+}
+
+type resumeData struct {
+	Token     string `json:"token"`
+	SessionID string `json:"session_id"`
+	Seq       int64  `json:"seq"`
+}
+
+type readyData struct {
+	SessionID        string `json:"session_id"`
+	ResumeGatewayURL string `json:"resume_gateway_url"`
+	User             User   `json:"user"`
+}
+
+// gatewayConn is a connection to the gateway with the state needed to
+// resume it
+type gatewayConn struct {
+	url   string
+	token string
+	proxy func(*http.Request) (*url.URL, error)
+
+	// seq, sessionID and resumeURL survive reconnects so that missed events
+	// are replayed
+	mu        sync.Mutex
+	seq       int64
+	sessionID string
+	resumeURL string
+}
+
+// run keeps a gateway connection open until ctx is done, reconnecting with
+// backoff. Dispatch events are passed to dispatch.
+func (g *gatewayConn) run(ctx context.Context, dispatch func(eventType string, data json.RawMessage), logger logFunc) {
+	delay := minReconnectDelay
+	for ctx.Err() == nil {
+		start := time.Now()
+		err := g.connect(ctx, dispatch)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A connection that stayed up for a while resets the backoff
+		if time.Since(start) > maxReconnectDelay {
+			delay = minReconnectDelay
+		}
+		if errors.Is(err, errReconnect) {
+			logger("Discord gateway reconnecting", "reason", err)
+			delay = minReconnectDelay
+		} else {
+			logger("Discord gateway disconnected", "error", err, "retry_in", delay)
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// logFunc logs a gateway event with key-value attributes
+type logFunc func(msg string, args ...interface{})
+
+// connect runs one gateway connection: hello, identify or resume, then the
+// heartbeat and event loop until the connection fails
+func (g *gatewayConn) connect(ctx context.Context, dispatch func(string, json.RawMessage)) error {
+	g.mu.Lock()
+	target, resuming := g.url, g.sessionID != ""
+	if resuming && g.resumeURL != "" {
+		target = g.resumeURL
+	}
+	g.mu.Unlock()
+
+	ws, err := dialWebsocket(ctx, target, g.proxy)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	// Closing the socket unblocks the receive loop on shutdown
+	stop := context.AfterFunc(ctx, func() { ws.Close() })
+	defer stop()
+
+	var hello payload
+	if err := websocket.JSON.Receive(ws, &hello); err != nil {
+		return fmt.Errorf("failed to read hello: %w", err)
+	}
+	if hello.Op != opHello {
+		return fmt.Errorf("expected hello, got opcode %d", hello.Op)
+	}
+	var helloData struct {
+		HeartbeatInterval int64 `json:"heartbeat_interval"`
+	}
+	if err := json.Unmarshal(hello.Data, &helloData); err != nil || helloData.HeartbeatInterval <= 0 {
+		return fmt.Errorf("invalid hello payload")
+	}
+
+	var sendMu sync.Mutex
+	send := func(op int, data interface{}) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		return websocket.JSON.Send(ws, outgoingPayload{Op: op, Data: data})
+	}
+
+	if resuming {
+		g.mu.Lock()
+		resume := resumeData{Token: g.token, SessionID: g.sessionID, Seq: g.seq}
+		g.mu.Unlock()
+		err = send(opResume, resume)
+	} else {
+		err = send(opIdentify, identifyData{
+			Token:   g.token,
+			Intents: gatewayIntents,
+			Properties: identifyProperties{
+				OS:      runtime.GOOS,
+				Browser: "agent-server",
+				Device:  "agent-server",
+			},
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to identify: %w", err)
+	}
+
+	// The heartbeat loop closes the socket when an acknowledgement is missed,
+	// which ends the receive loop below
+	var acked sync.Mutex
+	ackPending := false
+	heartbeatDone := make(chan struct{})
+	defer close(heartbeatDone)
+	go func() {
+		interval := time.Duration(helloData.HeartbeatInterval) * time.Millisecond
+		timer := time.NewTimer(time.Duration(rand.Int63n(int64(interval))))
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+			case <-heartbeatDone:
+				return
+			}
+
+			acked.Lock()
+			missed := ackPending
+			ackPending = true
+			acked.Unlock()
+			if missed {
+				ws.Close()
+				return
+			}
+
+			if err := send(opHeartbeat, g.lastSeq()); err != nil {
+				return
+			}
+			timer.Reset(interval)
+		}
+	}()
+
+	for {
+		var msg payload
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			return fmt.Errorf("gateway connection lost: %w", err)
+		}
+		if msg.Seq != nil {
+			g.mu.Lock()
+			g.seq = *msg.Seq
+			g.mu.Unlock()
+		}
+
+		switch msg.Op {
+		case opDispatch:
+			if msg.Type == "READY" {
+				var ready readyData
+				if err := json.Unmarshal(msg.Data, &ready); err == nil {
+					g.mu.Lock()
+					g.sessionID = ready.SessionID
+					g.resumeURL = gatewayURLWithQuery(ready.ResumeGatewayURL, g.url)
+					g.mu.Unlock()
+				}
+			}
+			dispatch(msg.Type, msg.Data)
+		case opHeartbeat:
+			if err := send(opHeartbeat, g.lastSeq()); err != nil {
+				return fmt.Errorf("failed to send heartbeat: %w", err)
+			}
+		case opHeartbeatACK:
+			acked.Lock()
+			ackPending = false
+			acked.Unlock()
+		case opReconnect:
+			return errReconnect
+		case opInvalidSession:
+			var resumable bool
+			_ = json.Unmarshal(msg.Data, &resumable)
+			if !resumable {
+				g.mu.Lock()
+				g.sessionID, g.resumeURL, g.seq = "", "", 0
+				g.mu.Unlock()
+			}
+			return fmt.Errorf("%w: invalid session", errReconnect)
+		}
+	}
+}
+
+// lastSeq returns the last sequence number received, or nil before the first
+// dispatch
+func (g *gatewayConn) lastSeq() interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.seq == 0 {
+		return nil
+	}
+	return g.seq
+}
+
+// gatewayURLWithQuery adds the version and encoding query of base to a resume
+// URL, which Discord sends without them
+func gatewayURLWithQuery(resumeURL, base string) string {
+	if resumeURL == "" {
+		return ""
+	}
+	if i := strings.Index(base, "?"); i >= 0 && !strings.Contains(resumeURL, "?") {
+		return strings.TrimSuffix(resumeURL, "/") + "/" + base[i:]
+	}
+	return resumeURL
+}
+
+// dialWebsocket opens a websocket to target, tunnelling through the proxy
+// the egress policy selects for it
+func dialWebsocket(ctx context.Context, target string, proxy func(*http.Request) (*url.URL, error)) (*websocket.Conn, error) {
+	location, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gateway url: %w", err)
+	}
+	config, err := websocket.NewConfig(target, "https://discord.com")
+	if err != nil {
+		return nil, fmt.Errorf("invalid gateway url: %w", err)
+	}
+
+	host := location.Host
+	if location.Port() == "" {
+		port := "443"
+		if location.Scheme == "ws" {
+			port = "80"
+		}
+		host = net.JoinHostPort(location.Hostname(), port)
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var proxyURL *url.URL
+	if proxy != nil {
+		// Proxy functions decide by scheme, so ask as the HTTPS upgrade would
+		probe := &http.Request{URL: &url.URL{Scheme: "https", Host: location.Host}}
+		if location.Scheme == "ws" {
+			probe.URL.Scheme = "http"
+		}
+		if proxyURL, err = proxy(probe); err != nil {
+			return nil, fmt.Errorf("failed to resolve proxy: %w", err)
+		}
+	}
+
+	var dialer net.Dialer
+	var conn net.Conn
+	if proxyURL != nil {
+		conn, err = dialer.DialContext(dialCtx, "tcp", proxyURL.Host)
+		if err == nil {
+			err = connectTunnel(conn, host, proxyURL)
+		}
+	} else {
+		conn, err = dialer.DialContext(dialCtx, "tcp", host)
+	}
+	if err != nil {
+		if conn != nil {
+			conn.Close()
+		}
+		return nil, fmt.Errorf("failed to connect to gateway: %w", err)
+	}
+
+	if location.Scheme == "wss" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: location.Hostname()})
+		if err := tlsConn.HandshakeContext(dialCtx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("gateway TLS handshake failed: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	ws, err := websocket.NewClient(config, conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("gateway handshake failed: %w", err)
+	}
+	return ws, nil
+}
+
+// connectTunnel asks an HTTP proxy to open a tunnel to host over conn
+func connectTunnel(conn net.Conn, host string, proxyURL *url.URL) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: host},
+		Host:   host,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+		req.Header.Set("Proxy-Authorization", req.Header.Get("Authorization"))
+		req.Header.Del("Authorization")
+	}
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxy refused tunnel: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxMessageLength is Discord's limit on the content of one message
+const maxMessageLength = 2000
+
+// streamedReply shows a reply that grows while it is generated. It is posted
+// as a reply to the user's message and edited as text arrives; text beyond
+// the message length limit continues in follow-up messages.
+type streamedReply struct {
+	rest      *rest
+	channelID string
+	replyTo   string
+	interval  time.Duration
+
+	ids       []string // posted messages, in order
+	sent      []string // content last sent for each message
+	text      string
+	lastFlush time.Time
+}
+
+func newStreamedReply(r *rest, channelID, replyTo string, interval time.Duration) *streamedReply {
+	return &streamedReply{rest: r, channelID: channelID, replyTo: replyTo, interval: interval}
+}
+
+// Update shows text as the reply. Updates that are not final are skipped
+// while the last one is more recent than the edit interval.
+func (r *streamedReply) Update(ctx context.Context, text string, final bool) error {
+	r.text = text
+	return r.show(ctx, text, final)
+}
+
+// Status shows what the agent is doing until the reply is ready
+func (r *streamedReply) Status(ctx context.Context, tool string) error {
+	if tool == "" {
+		return r.show(ctx, "_Thinking…_", true)
+	}
+	return r.show(ctx, fmt.Sprintf("_Using %s…_", tool), false)
+}
+
+// show posts or edits the reply messages to show text
+func (r *streamedReply) show(ctx context.Context, text string, final bool) error {
+	if !final && time.Since(r.lastFlush) < r.interval {
+		return nil
+	}
+	if strings.TrimSpace(text) == "" {
+		if !final {
+			return nil
+		}
+		text = "_(empty reply)_"
+	}
+	r.lastFlush = time.Now()
+
+	parts := splitMessage(text, maxMessageLength)
+	for i, part := range parts {
+		if i < len(r.ids) {
+			if r.sent[i] == part {
+				continue
+			}
+			if err := r.rest.EditMessage(ctx, r.channelID, r.ids[i], part); err != nil {
+				return err
+			}
+			r.sent[i] = part
+			continue
+		}
+
+		replyTo := ""
+		if i == 0 {
+			replyTo = r.replyTo
+		}
+		id, err := r.rest.CreateMessage(ctx, r.channelID, part, replyTo)
+		if err != nil {
+			return err
+		}
+		r.ids = append(r.ids, id)
+		r.sent = append(r.sent, part)
+	}
+
+	// Text that got shorter leaves follow-up messages behind
+	for len(r.ids) > len(parts) {
+		last := len(r.ids) - 1
+		if err := r.rest.DeleteMessage(ctx, r.channelID, r.ids[last]); err != nil {
+			return err
+		}
+		r.ids, r.sent = r.ids[:last], r.sent[:last]
+	}
+	return nil
+}
+
+// fail appends a notice to whatever part of the reply was shown
+func (r *streamedReply) fail(ctx context.Context, notice string) error {
+	text := strings.TrimSpace(r.text)
+	if text != "" {
+		text += "\n\n"
+	}
+	return r.Update(ctx, text+"⚠️ "+notice, true)
+}
+
+// splitMessage splits text into parts of at most limit bytes, preferring to
+// break at a newline, then at a space, in the second half of a part
+func splitMessage(text string, limit int) []string {
+	var parts []string
+	for len(text) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		if i := strings.LastIndex(text[:cut], "\n"); i > limit/2 {
+			cut = i
+		} else if i := strings.LastIndex(text[:cut], " "); i > limit/2 {
+			cut = i
+		}
+		parts = append(parts, text[:cut])
+		text = strings.TrimLeft(text[cut:], "\n ")
+	}
+	if text != "" || len(parts) == 0 {
+		parts = append(parts, text)
+	}
+	return parts
+}
@@ -0,0 +1,202 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultAPIURL is the base URL of the Discord REST API
+const DefaultAPIURL = "https://discord.com/api/v10"
+
+// maxRateLimitRetries bounds how often a rate-limited request is retried
+const maxRateLimitRetries = 3
+
+// Channel types of threads
+const (
+	channelTypeAnnouncementThread = 10
+	channelTypePublicThread       = 11
+	channelTypePrivateThread      = 12
+)
+
+// Channel is the part of a Discord channel object the bot uses
+type Channel struct {
+	ID       string `json:"id"`
+	Type     int    `json:"type"`
+	GuildID  string `json:"guild_id,omitempty"`
+	ParentID string `json:"parent_id,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+// IsThread reports whether the channel is a thread
+func (c *Channel) IsThread() bool {
+	switch c.Type {
+	case channelTypeAnnouncementThread, channelTypePublicThread, channelTypePrivateThread:
+		return true
+	}
+	return false
+}
+
+// outgoingMessage is the body of message create and edit requests
+type outgoingMessage struct {
+	Content          string            `json:"content"`
+	MessageReference *messageReference `json:"message_reference,omitempty"`
+	AllowedMentions  *allowedMentions  `json:"allowed_mentions,omitempty"`
+}
+
+type messageReference struct {
+	MessageID       string `json:"message_id"`
+	FailIfNotExists bool   `json:"fail_if_not_exists"`
+}
+
+// allowedMentions keeps agent replies from pinging users and roles
+type allowedMentions struct {
+	Parse       []string `json:"parse"`
+	RepliedUser bool     `json:"replied_user"`
+}
+
+// rest is a minimal client for the Discord REST API
+type rest struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func newREST(baseURL, token string, client *http.Client) *rest {
+	if baseURL == "" {
+		baseURL = DefaultAPIURL
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &rest{baseURL: baseURL, token: token, client: client}
+}
+
+// GetChannel fetches a channel
+func (r *rest) GetChannel(ctx context.Context, channelID string) (*Channel, error) {
+	var channel Channel
+	if err := r.do(ctx, http.MethodGet, "/channels/"+channelID, nil, &channel); err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+// CreateMessage posts a message, as a reply when replyTo is set, and returns
+// its ID
+func (r *rest) CreateMessage(ctx context.Context, channelID, content, replyTo string) (string, error) {
+	body := outgoingMessage{
+		Content:         content,
+		AllowedMentions: &allowedMentions{Parse: []string{}},
+	}
+	if replyTo != "" {
+		body.MessageReference = &messageReference{MessageID: replyTo}
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := r.do(ctx, http.MethodPost, "/channels/"+channelID+"/messages", body, &created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// EditMessage replaces the content of a message the bot posted
+func (r *rest) EditMessage(ctx context.Context, channelID, messageID, content string) error {
+	body := outgoingMessage{
+		Content:         content,
+		AllowedMentions: &allowedMentions{Parse: []string{}},
+	}
+	return r.do(ctx, http.MethodPatch, "/channels/"+channelID+"/messages/"+messageID, body, nil)
+}
+
+// DeleteMessage deletes a message the bot posted
+func (r *rest) DeleteMessage(ctx context.Context, channelID, messageID string) error {
+	return r.do(ctx, http.MethodDelete, "/channels/"+channelID+"/messages/"+messageID, nil, nil)
+}
+
+// CurrentUser returns the bot's own user
+func (r *rest) CurrentUser(ctx context.Context) (*User, error) {
+	var user User
+	if err := r.do(ctx, http.MethodGet, "/users/@me", nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// TriggerTyping shows the typing indicator in a channel for a few seconds
+func (r *rest) TriggerTyping(ctx context.Context, channelID string) error {
+	return r.do(ctx, http.MethodPost, "/channels/"+channelID+"/typing", nil, nil)
+}
+
+// do sends an API request and decodes the response into out. Rate-limited
+// requests are retried after the wait Discord asks for.
+func (r *rest) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, r.baseURL+path, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bot "+r.token)
+		req.Header.Set("User-Agent", "DiscordBot (agent-server, 1.0)")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("discord request failed: %w", err)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read discord response: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRateLimitRetries {
+			var limit struct {
+				RetryAfter float64 `json:"retry_after"`
+			}
+			_ = json.Unmarshal(data, &limit)
+			wait := time.Duration(limit.RetryAfter * float64(time.Second))
+			if wait <= 0 {
+				wait = time.Second
+			}
+			select {
+			case <-time.After(wait):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("discord %s %s returned %d: %s", method, path, resp.StatusCode, truncate(string(data), 200))
+		}
+		if out != nil && len(data) > 0 {
+			if err := json.Unmarshal(data, out); err != nil {
+				return fmt.Errorf("failed to decode discord response: %w", err)
+			}
+		}
+		return nil
+	}
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,300 @@
+// Package email connects agents to email. Emails delivered to the inbound
+// webhook become chat turns, and the agent's answer is sent back as a reply
+// in the same thread. Threads map to sessions through their Message-IDs.
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	netmail "net/mail"
+	"strings"
+	"sync"
+
+	"agent-server/internal/channels"
+	"agent-server/internal/config"
+	"agent-server/internal/mail"
+	"agent-server/internal/models"
+)
+
+// Platform names email threads in channel bindings
+const Platform = "email"
+
+// maxEmailSize bounds the size of a received email
+const maxEmailSize = 10 << 20
+
+// ErrInvalidEmail is returned for emails that cannot be parsed
+var ErrInvalidEmail = errors.New("invalid email")
+
+// Receipt statuses
+const (
+	StatusAccepted = "accepted"
+	StatusIgnored  = "ignored"
+)
+
+// Receipt tells the webhook caller what happened to an email
+type Receipt struct {
+	Status    string `json:"status"`
+	Reason    string `json:"reason,omitempty"`
+	MessageID string `json:"message_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// Sender delivers the replies; it is the sender of the send_email tool
+type Sender interface {
+	Send(ctx context.Context, msg *mail.Message) error
+}
+
+// Channel answers emails with agents
+type Channel struct {
+	cfg      config.EmailConfig
+	address  *netmail.Address
+	chat     channels.Chat
+	sessions channels.Sessions
+	sender   Sender
+	logger   *slog.Logger
+
+	// Emails of a thread are answered one at a time, in order
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates an email channel from its configuration
+func New(cfg config.EmailConfig, chat channels.Chat, sessions channels.Sessions, sender Sender, logger *slog.Logger) (*Channel, error) {
+	address, err := netmail.ParseAddress(cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email channel address %q: %w", cfg.Address, err)
+	}
+	addresses := make(map[string]config.EmailAddressConfig, len(cfg.Addresses))
+	for addr, settings := range cfg.Addresses {
+		addresses[strings.ToLower(addr)] = settings
+	}
+	cfg.Addresses = addresses
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Channel{
+		cfg:      cfg,
+		address:  address,
+		chat:     chat,
+		sessions: sessions,
+		sender:   sender,
+		logger:   logger,
+		locks:    make(map[string]*sync.Mutex),
+		ctx:      ctx,
+		cancel:   cancel,
+	}, nil
+}
+
+// Name returns the platform name
+func (c *Channel) Name() string {
+	return Platform
+}
+
+// Start does nothing: emails arrive through the inbound webhook
+func (c *Channel) Start(ctx context.Context) error {
+	return nil
+}
+
+// WebhookSecret returns the secret the inbound webhook expects
+func (c *Channel) WebhookSecret() string {
+	return c.cfg.WebhookSecret
+}
+
+// Stop cancels the replies in progress and waits for them to end
+func (c *Channel) Stop() {
+	c.cancel()
+	c.wg.Wait()
+}
+
+// Receive accepts an RFC 5322 email. The email is mapped to the session of
+// its thread right away; the agent answers in the background.
+func (c *Channel) Receive(ctx context.Context, r io.Reader) (*Receipt, error) {
+	in, err := mail.Parse(io.LimitReader(r, maxEmailSize))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEmail, err)
+	}
+	if in.MessageID == "" {
+		in.MessageID = mail.NewMessageID(in.From)
+	}
+	receipt := &Receipt{Status: StatusIgnored, MessageID: in.MessageID}
+	logger := c.logger.With("message_id", in.MessageID, "from", in.From)
+
+	switch {
+	case in.AutoGenerated:
+		receipt.Reason = "automatic email"
+	case strings.EqualFold(in.From, c.address.Address):
+		receipt.Reason = "sent by the channel itself"
+	case !mail.MatchAddress(in.From, c.cfg.AllowedSenders):
+		receipt.Reason = "sender not allowed"
+	case in.Text == "":
+		receipt.Reason = "empty message"
+	}
+	if receipt.Reason != "" {
+		logger.Info("Ignoring email", "reason", receipt.Reason)
+		return receipt, nil
+	}
+
+	settings, ok := c.addressSettings(in)
+	if !ok {
+		receipt.Reason = "no agent for the recipient"
+		logger.Info("Ignoring email", "reason", receipt.Reason, "to", in.To)
+		return receipt, nil
+	}
+
+	// Mail providers retry deliveries; an email already bound was answered
+	if session, err := c.sessions.Find(ctx, Platform, in.MessageID, settings.AgentID); err != nil {
+		return nil, err
+	} else if session != nil {
+		receipt.Reason = "duplicate"
+		receipt.SessionID = session.ID
+		return receipt, nil
+	}
+
+	session, err := c.threadSession(ctx, in, settings.AgentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.sessions.Bind(ctx, Platform, in.MessageID, session); err != nil {
+		return nil, err
+	}
+
+	receipt.Status = StatusAccepted
+	receipt.SessionID = session.ID
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.answer(c.ctx, in, session, settings)
+	}()
+	return receipt, nil
+}
+
+// threadSession returns the session of the thread an email belongs to,
+// starting one when the email opens a new thread
+func (c *Channel) threadSession(ctx context.Context, in *mail.Inbound, agentID string) (*models.ChatSession, error) {
+	for _, id := range in.Thread() {
+		session, err := c.sessions.Find(ctx, Platform, id, agentID)
+		if err != nil {
+			return nil, err
+		}
+		if session != nil {
+			return session, nil
+		}
+	}
+
+	title := in.Subject
+	if title == "" {
+		title = "Email from " + in.From
+	}
+	return c.sessions.Resolve(ctx, Platform, in.MessageID, agentID, title)
+}
+
+// answer runs the chat turn of an email and sends the reply
+func (c *Channel) answer(ctx context.Context, in *mail.Inbound, session *models.ChatSession, settings channels.Settings) {
+	lock := c.threadLock(session.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	logger := c.logger.With("message_id", in.MessageID, "session_id", session.ID)
+	metadata := map[string]interface{}{
+		"channel":          Platform,
+		"email_message_id": in.MessageID,
+		"email_from":       in.From,
+		"email_subject":    in.Subject,
+	}
+	if in.FromName != "" {
+		metadata["email_from_name"] = in.FromName
+	}
+
+	answer := &collectedReply{}
+	err := channels.Answer(ctx, c.chat, channels.Turn{
+		SessionID: session.ID,
+		Message:   in.Text,
+		Tools:     settings.Tools,
+		Metadata:  metadata,
+	}, answer)
+	response := answer.text
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Error("Failed to answer email", "error", err)
+		response = channels.ErrorText(err)
+	}
+
+	reply := c.reply(in, response)
+	if err := c.sender.Send(ctx, reply); err != nil {
+		logger.Error("Failed to send email reply", "error", err)
+		return
+	}
+	// Replies to the agent's answer continue the session
+	if err := c.sessions.Bind(ctx, Platform, reply.MessageID, session); err != nil {
+		logger.Warn("Failed to bind email reply", "reply_id", reply.MessageID, "error", err)
+	}
+}
+
+// reply builds the answer to an email in its thread
+func (c *Channel) reply(in *mail.Inbound, text string) *mail.Message {
+	subject := in.Subject
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = strings.TrimSpace("Re: " + subject)
+	}
+	references := append(append([]string{}, in.References...), in.MessageID)
+
+	return &mail.Message{
+		From:       c.address.String(),
+		To:         []string{in.ReplyAddress()},
+		Subject:    subject,
+		Body:       text,
+		MessageID:  mail.NewMessageID(c.address.Address),
+		InReplyTo:  in.MessageID,
+		References: references,
+		// Keeps vacation responders and other agents from answering
+		Headers: map[string]string{"Auto-Submitted": "auto-replied"},
+	}
+}
+
+// addressSettings returns the agent and tools answering an email, and false
+// when no agent answers any of its recipients
+func (c *Channel) addressSettings(in *mail.Inbound) (channels.Settings, bool) {
+	defaults := channels.Settings{AgentID: c.cfg.AgentID, Tools: c.cfg.Tools}
+	for _, recipient := range append(append([]string{}, in.To...), in.Cc...) {
+		if addressCfg, ok := c.cfg.Addresses[recipient]; ok {
+			return channels.Settings{AgentID: addressCfg.AgentID, Tools: addressCfg.Tools}.Merge(defaults), true
+		}
+	}
+	return defaults, c.cfg.AgentID != ""
+}
+
+// threadLock returns the lock that orders the replies of a session
+func (c *Channel) threadLock(sessionID string) *sync.Mutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lock, ok := c.locks[sessionID]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.locks[sessionID] = lock
+	}
+	return lock
+}
+
+// collectedReply keeps the final answer; emails are sent once it is complete
+type collectedReply struct {
+	text string
+}
+
+func (r *collectedReply) Update(ctx context.Context, text string, final bool) error {
+	if final {
+		r.text = text
+	}
+	return nil
+}
+
+func (r *collectedReply) Status(ctx context.Context, tool string) error {
+	return nil
+}
@@ -0,0 +1,143 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"agent-server/internal/config"
+	"agent-server/internal/mail"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChat answers every message with a fixed reply
+type fakeChat struct {
+	mu       sync.Mutex
+	sessions []string
+	messages []string
+}
+
+func (c *fakeChat) Stream(ctx context.Context, req *services.ChatRequest) (<-chan services.StreamChunk, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sessions = append(c.sessions, req.SessionID)
+	c.messages = append(c.messages, req.Message)
+	chunks := make(chan services.StreamChunk, 3)
+	chunks <- services.StreamChunk{Content: "It is "}
+	chunks <- services.StreamChunk{Content: "noon."}
+	chunks <- services.StreamChunk{Done: true}
+	close(chunks)
+	return chunks, nil
+}
+
+func (c *fakeChat) StreamWithTools(ctx context.Context, req *models.EnhancedChatRequest, sessionID string) (<-chan services.ToolChatEvent, error) {
+	return nil, fmt.Errorf("unexpected tool chat")
+}
+
+// fakeSender records sent mail
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []*mail.Message
+}
+
+func (s *fakeSender) Send(ctx context.Context, msg *mail.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func (s *fakeSender) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sent)
+}
+
+func rawEmail(messageID, inReplyTo, subject, body string, extraHeaders ...string) string {
+	headers := []string{
+		"From: Alice <alice@example.com>",
+		"To: agent@example.org",
+		"Subject: " + subject,
+		"Message-ID: " + messageID,
+	}
+	if inReplyTo != "" {
+		headers = append(headers, "In-Reply-To: "+inReplyTo, "References: "+inReplyTo)
+	}
+	headers = append(headers, extraHeaders...)
+	return strings.Join(headers, "\r\n") + "\r\n\r\n" + body + "\r\n"
+}
+
+func TestChannel_Threads(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "support", Provider: "ollama", Model: "llama3"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+
+	chat := &fakeChat{}
+	sender := &fakeSender{}
+	channel, err := New(config.EmailConfig{
+		Address:        "Support <agent@example.org>",
+		AgentID:        agent.ID,
+		AllowedSenders: []string{"@example.com"},
+	}, chat, services.NewChannelSessions(repo), sender, slog.Default())
+	require.NoError(t, err)
+	defer channel.Stop()
+
+	receipt, err := channel.Receive(ctx, strings.NewReader(rawEmail("<q1@example.com>", "", "Time", "What time is it?")))
+	require.NoError(t, err)
+	assert.Equal(t, StatusAccepted, receipt.Status)
+	require.Eventually(t, func() bool { return sender.count() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	reply := sender.sent[0]
+	assert.Equal(t, []string{"alice@example.com"}, reply.To)
+	assert.Equal(t, "Re: Time", reply.Subject)
+	assert.Equal(t, "It is noon.", reply.Body)
+	assert.Equal(t, "<q1@example.com>", reply.InReplyTo)
+	assert.Equal(t, []string{"<q1@example.com>"}, reply.References)
+	assert.Equal(t, "auto-replied", reply.Headers["Auto-Submitted"])
+
+	// A reply to the agent's answer continues the session
+	followUp, err := channel.Receive(ctx, strings.NewReader(rawEmail("<q2@example.com>", reply.MessageID, "Re: Time", "Thanks!\r\n\r\n> It is noon.")))
+	require.NoError(t, err)
+	assert.Equal(t, StatusAccepted, followUp.Status)
+	assert.Equal(t, receipt.SessionID, followUp.SessionID)
+	require.Eventually(t, func() bool { return sender.count() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	chat.mu.Lock()
+	assert.Equal(t, []string{"What time is it?", "Thanks!"}, chat.messages)
+	assert.Equal(t, []string{receipt.SessionID, receipt.SessionID}, chat.sessions)
+	chat.mu.Unlock()
+
+	// Redelivered, automatic and unknown senders' emails are not answered
+	duplicate, err := channel.Receive(ctx, strings.NewReader(rawEmail("<q2@example.com>", reply.MessageID, "Re: Time", "Thanks!")))
+	require.NoError(t, err)
+	assert.Equal(t, StatusIgnored, duplicate.Status)
+	assert.Equal(t, "duplicate", duplicate.Reason)
+
+	auto, err := channel.Receive(ctx, strings.NewReader(rawEmail("<ooo@example.com>", "", "Out of office", "Away", "Auto-Submitted: auto-replied")))
+	require.NoError(t, err)
+	assert.Equal(t, StatusIgnored, auto.Status)
+
+	stranger := strings.Replace(rawEmail("<s1@other.com>", "", "Hi", "Hello"), "alice@example.com", "eve@other.com", 1)
+	ignored, err := channel.Receive(ctx, strings.NewReader(stranger))
+	require.NoError(t, err)
+	assert.Equal(t, "sender not allowed", ignored.Reason)
+
+	_, err = channel.Receive(ctx, strings.NewReader("garbage"))
+	assert.ErrorIs(t, err, ErrInvalidEmail)
+
+	channel.Stop()
+	assert.Equal(t, 2, sender.count())
+}
@@ -0,0 +1,374 @@
+// Package matrix connects agents to Matrix. The bot joins rooms, maps rooms
+// and threads to agent sessions and streams replies by editing its messages
+// as tokens arrive.
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"agent-server/internal/channels"
+	"agent-server/internal/config"
+)
+
+// Platform names Matrix conversations in channel bindings
+const Platform = "matrix"
+
+// defaultEditInterval keeps streamed edits under homeserver rate limits
+const defaultEditInterval = time.Second
+
+// Sync retry backoff bounds
+const (
+	minRetryDelay = time.Second
+	maxRetryDelay = time.Minute
+)
+
+// Bot answers Matrix messages with agents
+type Bot struct {
+	cfg          config.MatrixConfig
+	rooms        map[string]config.MatrixRoomConfig // by lower-cased room ID
+	chat         channels.Chat
+	sessions     channels.Sessions
+	client       *client
+	editInterval time.Duration
+	logger       *slog.Logger
+
+	mu          sync.Mutex
+	userID      string
+	displayName string
+	roomNames   map[string]string
+	locks       map[string]*sync.Mutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a Matrix bot from its configuration
+func New(cfg config.MatrixConfig, chat channels.Chat, sessions channels.Sessions, logger *slog.Logger) *Bot {
+	editInterval := time.Duration(cfg.EditInterval) * time.Millisecond
+	if editInterval <= 0 {
+		editInterval = defaultEditInterval
+	}
+	// Configuration keys are lower-cased when loaded, so rooms are matched
+	// regardless of case
+	rooms := make(map[string]config.MatrixRoomConfig, len(cfg.Rooms))
+	for id, room := range cfg.Rooms {
+		rooms[strings.ToLower(id)] = room
+	}
+	return &Bot{
+		cfg:          cfg,
+		rooms:        rooms,
+		chat:         chat,
+		sessions:     sessions,
+		client:       newClient(cfg.Homeserver, cfg.AccessToken),
+		editInterval: editInterval,
+		logger:       logger,
+		roomNames:    make(map[string]string),
+		locks:        make(map[string]*sync.Mutex),
+	}
+}
+
+// Name returns the platform name
+func (b *Bot) Name() string {
+	return Platform
+}
+
+// SetTransport routes requests to the homeserver through transport
+func (b *Bot) SetTransport(transport http.RoundTripper) {
+	b.client.client = &http.Client{Timeout: b.client.client.Timeout, Transport: transport}
+}
+
+// Start checks the access token, joins the configured rooms and syncs in
+// the background. Messages sent while the bot was offline are not answered.
+func (b *Bot) Start(ctx context.Context) error {
+	userID, err := b.client.Whoami(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to verify matrix access token: %w", err)
+	}
+	displayName, err := b.client.DisplayName(ctx, userID)
+	if err != nil {
+		b.logger.Debug("Failed to get Matrix display name", "error", err)
+	}
+	b.mu.Lock()
+	b.userID, b.displayName = userID, displayName
+	b.mu.Unlock()
+
+	for roomID := range b.cfg.Rooms {
+		if err := b.client.Join(ctx, roomID); err != nil {
+			b.logger.Warn("Failed to join Matrix room", "room_id", roomID, "error", err)
+		}
+	}
+
+	initial, err := b.client.Sync(ctx, "", 0)
+	if err != nil {
+		return fmt.Errorf("initial matrix sync failed: %w", err)
+	}
+
+	ctx, b.cancel = context.WithCancel(ctx)
+	b.handleInvites(ctx, initial)
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.syncLoop(ctx, initial.NextBatch)
+	}()
+
+	b.logger.Info("Matrix bot started", "user_id", userID, "rooms", len(b.cfg.Rooms))
+	return nil
+}
+
+// Stop ends the sync loop and waits for replies in progress
+func (b *Bot) Stop() {
+	if b.cancel == nil {
+		return
+	}
+	b.cancel()
+	b.wg.Wait()
+}
+
+// syncLoop long-polls the homeserver for new events until ctx is done,
+// retrying with backoff when a sync fails
+func (b *Bot) syncLoop(ctx context.Context, since string) {
+	delay := minRetryDelay
+	for ctx.Err() == nil {
+		resp, err := b.client.Sync(ctx, since, syncTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			b.logger.Warn("Matrix sync failed", "error", err, "retry_in", delay)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return
+			}
+			if delay *= 2; delay > maxRetryDelay {
+				delay = maxRetryDelay
+			}
+			continue
+		}
+		delay = minRetryDelay
+		since = resp.NextBatch
+
+		b.handleInvites(ctx, resp)
+		for roomID, room := range resp.Rooms.Join {
+			for _, event := range room.Timeline.Events {
+				event := event
+				b.wg.Add(1)
+				go func(roomID string) {
+					defer b.wg.Done()
+					b.handleEvent(ctx, roomID, &event)
+				}(roomID)
+			}
+		}
+	}
+}
+
+// handleInvites joins the rooms the bot is invited to, when it may
+func (b *Bot) handleInvites(ctx context.Context, resp *syncResponse) {
+	for roomID := range resp.Rooms.Invite {
+		if !b.cfg.AutoJoin {
+			continue
+		}
+		if _, ok := b.rooms[strings.ToLower(roomID)]; len(b.rooms) > 0 && !ok {
+			b.logger.Info("Ignoring invitation to unconfigured Matrix room", "room_id", roomID)
+			continue
+		}
+		if err := b.client.Join(ctx, roomID); err != nil {
+			b.logger.Warn("Failed to join Matrix room", "room_id", roomID, "error", err)
+			continue
+		}
+		b.logger.Info("Joined Matrix room", "room_id", roomID)
+	}
+}
+
+// handleEvent answers a text message in a room the bot serves. Messages of
+// a room or thread are answered one at a time, in order.
+func (b *Bot) handleEvent(ctx context.Context, roomID string, event *Event) {
+	if event.Type != "m.room.message" || event.Sender == b.botUserID() {
+		return
+	}
+	var content messageContent
+	if err := json.Unmarshal(event.Content, &content); err != nil || content.MsgType != "m.text" {
+		return
+	}
+	// Edits of earlier messages are not answered again
+	if content.RelatesTo != nil && content.RelatesTo.RelType == "m.replace" {
+		return
+	}
+
+	settings, ok := b.roomSettings(roomID)
+	if !ok {
+		return
+	}
+	text, mentioned := b.stripMention(&content)
+	if b.cfg.RequireMention && !mentioned {
+		return
+	}
+	if text == "" {
+		return
+	}
+
+	// A thread continues its own session
+	conversation, threadID := roomID, ""
+	if content.RelatesTo != nil && content.RelatesTo.RelType == "m.thread" && content.RelatesTo.EventID != "" {
+		threadID = content.RelatesTo.EventID
+		conversation = roomID + "/" + threadID
+	}
+
+	lock := b.conversationLock(conversation)
+	lock.Lock()
+	defer lock.Unlock()
+
+	logger := b.logger.With("room_id", roomID, "event_id", event.EventID)
+	reply := newStreamedReply(b.client, roomID, event.EventID, threadID, b.editInterval)
+
+	session, err := b.sessions.Resolve(ctx, Platform, conversation, settings.AgentID, b.sessionTitle(ctx, roomID, threadID))
+	if err != nil {
+		logger.Error("Failed to resolve Matrix session", "error", err)
+		if err := reply.fail(ctx, channels.ErrorText(err)); err != nil {
+			logger.Warn("Failed to send Matrix reply", "error", err)
+		}
+		return
+	}
+
+	if err := b.client.Typing(ctx, roomID, b.botUserID(), true); err != nil {
+		logger.Debug("Failed to send typing notification", "error", err)
+	}
+	defer func() {
+		if err := b.client.Typing(context.WithoutCancel(ctx), roomID, b.botUserID(), false); err != nil {
+			logger.Debug("Failed to clear typing notification", "error", err)
+		}
+	}()
+
+	metadata := map[string]interface{}{
+		"channel":         Platform,
+		"matrix_event_id": event.EventID,
+		"matrix_room_id":  roomID,
+		"matrix_sender":   event.Sender,
+	}
+	if threadID != "" {
+		metadata["matrix_thread_id"] = threadID
+	}
+	err = channels.Answer(ctx, b.chat, channels.Turn{
+		SessionID: session.ID,
+		Message:   text,
+		Tools:     settings.Tools,
+		Metadata:  metadata,
+	}, reply)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Error("Failed to answer Matrix message", "session_id", session.ID, "error", err)
+		if err := reply.fail(ctx, channels.ErrorText(err)); err != nil {
+			logger.Warn("Failed to send Matrix reply", "error", err)
+		}
+	}
+}
+
+// roomSettings returns the agent and tools of a room, and false when the
+// bot does not serve it
+func (b *Bot) roomSettings(roomID string) (channels.Settings, bool) {
+	defaults := channels.Settings{AgentID: b.cfg.AgentID, Tools: b.cfg.Tools}
+	if len(b.rooms) == 0 {
+		return defaults, defaults.AgentID != ""
+	}
+	room, ok := b.rooms[strings.ToLower(roomID)]
+	if !ok {
+		return channels.Settings{}, false
+	}
+	return channels.Settings{AgentID: room.AgentID, Tools: room.Tools}.Merge(defaults), true
+}
+
+// stripMention removes mentions of the bot from a message and reports
+// whether there were any. Clients mention by user ID, or by display name
+// at the start of the message.
+func (b *Bot) stripMention(content *messageContent) (string, bool) {
+	b.mu.Lock()
+	userID, displayName := b.userID, b.displayName
+	b.mu.Unlock()
+
+	mentioned := false
+	if content.Mentions != nil {
+		for _, id := range content.Mentions.UserIDs {
+			if id == userID {
+				mentioned = true
+			}
+		}
+	}
+
+	text := strings.TrimSpace(content.Body)
+	if userID != "" && strings.Contains(text, userID) {
+		mentioned = true
+		text = strings.ReplaceAll(text, userID, "")
+	}
+	for _, name := range []string{displayName, localpart(userID)} {
+		if name == "" {
+			continue
+		}
+		if len(text) > len(name) && strings.EqualFold(text[:len(name)], name) && strings.ContainsRune(":, ", rune(text[len(name)])) {
+			mentioned = true
+			text = text[len(name)+1:]
+			break
+		}
+	}
+	return strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(text), ":,")), mentioned
+}
+
+// sessionTitle names the session of a room after it
+func (b *Bot) sessionTitle(ctx context.Context, roomID, threadID string) string {
+	b.mu.Lock()
+	name, ok := b.roomNames[roomID]
+	b.mu.Unlock()
+	if !ok {
+		var err error
+		if name, err = b.client.RoomName(ctx, roomID); err != nil {
+			name = ""
+		}
+		b.mu.Lock()
+		b.roomNames[roomID] = name
+		b.mu.Unlock()
+	}
+
+	title := "Matrix room " + roomID
+	if name != "" {
+		title = "Matrix " + name
+	}
+	if threadID != "" {
+		title += " (thread)"
+	}
+	return title
+}
+
+// conversationLock returns the lock that orders the replies of a room or
+// thread
+func (b *Bot) conversationLock(conversation string) *sync.Mutex {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lock, ok := b.locks[conversation]
+	if !ok {
+		lock = &sync.Mutex{}
+		b.locks[conversation] = lock
+	}
+	return lock
+}
+
+func (b *Bot) botUserID() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.userID
+}
+
+// localpart returns the user name of a user ID, e.g. "agent" for
+// "@agent:example.org"
+func localpart(userID string) string {
+	name := strings.TrimPrefix(userID, "@")
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
@@ -0,0 +1,211 @@
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"agent-server/internal/config"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHomeserver serves the client-server API endpoints the bot uses. The
+// first sync returns history, which must not be answered; the second
+// returns the messages in events.
+type fakeHomeserver struct {
+	mu     sync.Mutex
+	events map[string][]Event // by room
+	joined []string
+	sent   []messageContent
+	syncs  int
+}
+
+func (f *fakeHomeserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/_matrix/client/v3")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case path == "/account/whoami":
+		json.NewEncoder(w).Encode(map[string]string{"user_id": "@agent:test"})
+	case strings.HasSuffix(path, "/displayname"):
+		json.NewEncoder(w).Encode(map[string]string{"displayname": "Agent"})
+	case strings.HasSuffix(path, "/state/m.room.name"):
+		json.NewEncoder(w).Encode(map[string]string{"name": "General"})
+	case strings.HasPrefix(path, "/join/"):
+		f.joined = append(f.joined, strings.TrimPrefix(path, "/join/"))
+		json.NewEncoder(w).Encode(map[string]string{})
+	case strings.Contains(path, "/typing/"):
+		json.NewEncoder(w).Encode(map[string]string{})
+	case strings.Contains(path, "/send/m.room.message/"):
+		var content messageContent
+		json.NewDecoder(r.Body).Decode(&content)
+		f.sent = append(f.sent, content)
+		json.NewEncoder(w).Encode(map[string]string{"event_id": fmt.Sprintf("$reply%d", len(f.sent))})
+	case path == "/sync":
+		f.syncs++
+		resp := map[string]interface{}{"next_batch": fmt.Sprintf("s%d", f.syncs)}
+		switch f.syncs {
+		case 1:
+			resp["rooms"] = map[string]interface{}{
+				"invite": map[string]interface{}{"!invited:test": map[string]interface{}{}},
+				"join": map[string]interface{}{"!general:test": map[string]interface{}{
+					"timeline": map[string]interface{}{"events": []Event{message("$old", "@alice:test", `{"msgtype":"m.text","body":"Agent: old"}`)}},
+				}},
+			}
+		case 2:
+			join := map[string]interface{}{}
+			for room, events := range f.events {
+				join[room] = map[string]interface{}{"timeline": map[string]interface{}{"events": events}}
+			}
+			resp["rooms"] = map[string]interface{}{"join": join}
+		default:
+			// Nothing new; answer like an expired long poll
+			f.mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			f.mu.Lock()
+		}
+		json.NewEncoder(w).Encode(resp)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeHomeserver) sentMessages() []messageContent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]messageContent{}, f.sent...)
+}
+
+func message(id, sender, content string) Event {
+	return Event{Type: "m.room.message", EventID: id, Sender: sender, Content: json.RawMessage(content)}
+}
+
+// fakeChat streams a fixed reply and records the requests it got
+type fakeChat struct {
+	mu       sync.Mutex
+	messages []string
+	sessions []string
+}
+
+func (c *fakeChat) Stream(ctx context.Context, req *services.ChatRequest) (<-chan services.StreamChunk, error) {
+	c.mu.Lock()
+	c.messages = append(c.messages, req.Message)
+	c.sessions = append(c.sessions, req.SessionID)
+	c.mu.Unlock()
+	chunks := make(chan services.StreamChunk, 3)
+	chunks <- services.StreamChunk{Content: "Hello"}
+	chunks <- services.StreamChunk{Content: " there"}
+	chunks <- services.StreamChunk{Done: true}
+	close(chunks)
+	return chunks, nil
+}
+
+func (c *fakeChat) StreamWithTools(ctx context.Context, req *models.EnhancedChatRequest, sessionID string) (<-chan services.ToolChatEvent, error) {
+	return nil, fmt.Errorf("unexpected tool chat")
+}
+
+// fakeSessions hands out one session per conversation
+type fakeSessions struct{}
+
+func (fakeSessions) Resolve(ctx context.Context, platform, externalID, agentID, title string) (*models.ChatSession, error) {
+	return &models.ChatSession{ID: platform + ":" + externalID, AgentID: agentID, Title: title}, nil
+}
+
+func (fakeSessions) Find(ctx context.Context, platform, externalID, agentID string) (*models.ChatSession, error) {
+	return nil, nil
+}
+
+func (fakeSessions) Bind(ctx context.Context, platform, externalID string, session *models.ChatSession) error {
+	return nil
+}
+
+func TestBot_SyncAndReply(t *testing.T) {
+	homeserver := &fakeHomeserver{events: map[string][]Event{
+		"!general:test": {
+			message("$own", "@agent:test", `{"msgtype":"m.notice","body":"ignored"}`),
+			message("$chatter", "@alice:test", `{"msgtype":"m.text","body":"no mention"}`),
+			message("$q1", "@alice:test", `{"msgtype":"m.text","body":"Agent: hi there","m.mentions":{"user_ids":["@agent:test"]}}`),
+		},
+		"!other:test": {
+			message("$q2", "@bob:test", `{"msgtype":"m.text","body":"@agent:test hello"}`),
+		},
+	}}
+	server := httptest.NewServer(homeserver)
+	defer server.Close()
+
+	chat := &fakeChat{}
+	bot := New(config.MatrixConfig{
+		Homeserver:     server.URL,
+		AccessToken:    "token",
+		AgentID:        "agent-1",
+		RequireMention: true,
+		AutoJoin:       true,
+		EditInterval:   1,
+		Rooms:          map[string]config.MatrixRoomConfig{"!General:test": {}},
+	}, chat, fakeSessions{}, slog.Default())
+
+	require.NoError(t, bot.Start(context.Background()))
+	defer bot.Stop()
+
+	require.Eventually(t, func() bool {
+		chat.mu.Lock()
+		defer chat.mu.Unlock()
+		return len(chat.messages) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		sent := homeserver.sentMessages()
+		return len(sent) > 0 && sent[len(sent)-1].NewContent != nil && sent[len(sent)-1].NewContent.Body == "Hello there"
+	}, 5*time.Second, 10*time.Millisecond)
+	bot.Stop()
+
+	// Only the mention in the configured room is answered; history, own
+	// messages and other rooms are not
+	chat.mu.Lock()
+	assert.Equal(t, []string{"hi there"}, chat.messages)
+	assert.Equal(t, []string{"matrix:!general:test"}, chat.sessions)
+	chat.mu.Unlock()
+
+	sent := homeserver.sentMessages()
+	first := sent[0]
+	assert.Equal(t, msgTypeNotice, first.MsgType)
+	require.NotNil(t, first.RelatesTo)
+	assert.Equal(t, "$q1", first.RelatesTo.InReplyTo.EventID)
+	last := sent[len(sent)-1]
+	assert.Equal(t, "m.replace", last.RelatesTo.RelType)
+	assert.Equal(t, "$reply1", last.RelatesTo.EventID)
+
+	// The configured room is joined; the invitation to another room is not
+	// accepted
+	assert.Equal(t, []string{"!General:test"}, homeserver.joined)
+}
+
+func TestBot_StripMention(t *testing.T) {
+	bot := New(config.MatrixConfig{Homeserver: "http://localhost"}, &fakeChat{}, fakeSessions{}, slog.Default())
+	bot.userID, bot.displayName = "@agent:test", "Agent Smith"
+
+	for body, want := range map[string]string{
+		"Agent Smith: what time is it?": "what time is it?",
+		"agent, hello":                  "hello",
+		"@agent:test hi":                "hi",
+	} {
+		text, mentioned := bot.stripMention(&messageContent{Body: body})
+		assert.True(t, mentioned, body)
+		assert.Equal(t, want, text, body)
+	}
+
+	text, mentioned := bot.stripMention(&messageContent{Body: "agents are great"})
+	assert.False(t, mentioned)
+	assert.Equal(t, "agents are great", text)
+}
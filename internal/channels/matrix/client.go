@@ -0,0 +1,229 @@
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// maxRateLimitRetries bounds how often a rate-limited request is retried
+const maxRateLimitRetries = 3
+
+// syncTimeout is how long a sync request waits for new events
+const syncTimeout = 30 * time.Second
+
+// syncFilter limits syncs to the events the bot handles
+const syncFilter = `{"presence":{"types":[]},"account_data":{"types":[]},` +
+	`"room":{"state":{"types":[]},"ephemeral":{"types":[]},"account_data":{"types":[]},` +
+	`"timeline":{"limit":50,"types":["m.room.message"]}}}`
+
+// Event is a room event
+type Event struct {
+	Type    string          `json:"type"`
+	EventID string          `json:"event_id"`
+	Sender  string          `json:"sender"`
+	Content json.RawMessage `json:"content"`
+}
+
+// messageContent is the content of an m.room.message event
+type messageContent struct {
+	MsgType    string          `json:"msgtype"`
+	Body       string          `json:"body"`
+	RelatesTo  *relatesTo      `json:"m.relates_to,omitempty"`
+	Mentions   *mentions       `json:"m.mentions,omitempty"`
+	NewContent *messageContent `json:"m.new_content,omitempty"`
+}
+
+type relatesTo struct {
+	RelType       string     `json:"rel_type,omitempty"`
+	EventID       string     `json:"event_id,omitempty"`
+	InReplyTo     *inReplyTo `json:"m.in_reply_to,omitempty"`
+	IsFallingBack bool       `json:"is_falling_back,omitempty"`
+}
+
+type inReplyTo struct {
+	EventID string `json:"event_id"`
+}
+
+type mentions struct {
+	UserIDs []string `json:"user_ids,omitempty"`
+}
+
+// syncResponse is the part of a sync response the bot uses
+type syncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []Event `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+		Invite map[string]json.RawMessage `json:"invite"`
+	} `json:"rooms"`
+}
+
+// client is a minimal client for the Matrix client-server API
+type client struct {
+	baseURL string
+	token   string
+	client  *http.Client
+	txn     atomic.Int64
+}
+
+func newClient(homeserver, token string) *client {
+	return &client{
+		baseURL: strings.TrimSuffix(homeserver, "/") + "/_matrix/client/v3",
+		token:   token,
+		client:  &http.Client{Timeout: syncTimeout + 30*time.Second},
+	}
+}
+
+// Whoami returns the user ID of the access token
+func (c *client) Whoami(ctx context.Context) (string, error) {
+	var resp struct {
+		UserID string `json:"user_id"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/account/whoami", nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.UserID, nil
+}
+
+// DisplayName returns a user's display name
+func (c *client) DisplayName(ctx context.Context, userID string) (string, error) {
+	var resp struct {
+		DisplayName string `json:"displayname"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/profile/"+url.PathEscape(userID)+"/displayname", nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.DisplayName, nil
+}
+
+// RoomName returns the name of a room
+func (c *client) RoomName(ctx context.Context, roomID string) (string, error) {
+	var resp struct {
+		Name string `json:"name"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/rooms/"+url.PathEscape(roomID)+"/state/m.room.name", nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.Name, nil
+}
+
+// Join joins a room by ID or alias
+func (c *client) Join(ctx context.Context, room string) error {
+	return c.do(ctx, http.MethodPost, "/join/"+url.PathEscape(room), struct{}{}, nil)
+}
+
+// Sync returns the events since a sync token, waiting up to timeout for new
+// ones
+func (c *client) Sync(ctx context.Context, since string, timeout time.Duration) (*syncResponse, error) {
+	query := url.Values{}
+	query.Set("filter", syncFilter)
+	query.Set("timeout", fmt.Sprint(timeout.Milliseconds()))
+	if since != "" {
+		query.Set("since", since)
+	}
+	var resp syncResponse
+	if err := c.do(ctx, http.MethodGet, "/sync?"+query.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SendMessage sends an m.room.message event and returns its ID
+func (c *client) SendMessage(ctx context.Context, roomID string, content *messageContent) (string, error) {
+	txnID := fmt.Sprintf("agent-%d-%d", time.Now().UnixNano(), c.txn.Add(1))
+	var resp struct {
+		EventID string `json:"event_id"`
+	}
+	path := "/rooms/" + url.PathEscape(roomID) + "/send/m.room.message/" + txnID
+	if err := c.do(ctx, http.MethodPut, path, content, &resp); err != nil {
+		return "", err
+	}
+	return resp.EventID, nil
+}
+
+// Typing shows or hides the bot's typing notification in a room
+func (c *client) Typing(ctx context.Context, roomID, userID string, typing bool) error {
+	body := map[string]interface{}{"typing": typing}
+	if typing {
+		body["timeout"] = 30000
+	}
+	return c.do(ctx, http.MethodPut, "/rooms/"+url.PathEscape(roomID)+"/typing/"+url.PathEscape(userID), body, nil)
+}
+
+// do sends an API request and decodes the response into out. Rate-limited
+// requests are retried after the wait the homeserver asks for.
+func (c *client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("matrix request failed: %w", err)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read matrix response: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRateLimitRetries {
+			var limit struct {
+				RetryAfterMs int64 `json:"retry_after_ms"`
+			}
+			_ = json.Unmarshal(data, &limit)
+			wait := time.Duration(limit.RetryAfterMs) * time.Millisecond
+			if wait <= 0 {
+				wait = time.Second
+			}
+			select {
+			case <-time.After(wait):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			var matrixErr struct {
+				ErrCode string `json:"errcode"`
+				Error   string `json:"error"`
+			}
+			if json.Unmarshal(data, &matrixErr) == nil && matrixErr.ErrCode != "" {
+				return fmt.Errorf("matrix %s %s returned %d: %s: %s", method, strings.SplitN(path, "?", 2)[0], resp.StatusCode, matrixErr.ErrCode, matrixErr.Error)
+			}
+			return fmt.Errorf("matrix %s %s returned %d", method, strings.SplitN(path, "?", 2)[0], resp.StatusCode)
+		}
+		if out != nil && len(data) > 0 {
+			if err := json.Unmarshal(data, out); err != nil {
+				return fmt.Errorf("failed to decode matrix response: %w", err)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,103 @@
+package matrix
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// msgTypeNotice marks bot messages, which other bots do not answer
+const msgTypeNotice = "m.notice"
+
+// streamedReply shows a reply that grows while it is generated. It is sent
+// as a reply to the user's message, in the user's thread, and replaced by
+// edits as text arrives.
+type streamedReply struct {
+	client   *client
+	roomID   string
+	replyTo  string
+	threadID string // root event of the thread, if any
+	interval time.Duration
+
+	eventID   string // the reply event, once sent
+	sent      string
+	text      string
+	lastFlush time.Time
+}
+
+func newStreamedReply(c *client, roomID, replyTo, threadID string, interval time.Duration) *streamedReply {
+	return &streamedReply{client: c, roomID: roomID, replyTo: replyTo, threadID: threadID, interval: interval}
+}
+
+// Update shows text as the reply. Updates that are not final are skipped
+// while the last one is more recent than the edit interval.
+func (r *streamedReply) Update(ctx context.Context, text string, final bool) error {
+	r.text = text
+	return r.show(ctx, text, final)
+}
+
+// Status shows what the agent is doing until the reply is ready
+func (r *streamedReply) Status(ctx context.Context, tool string) error {
+	if tool == "" {
+		return r.show(ctx, "Thinking…", true)
+	}
+	return r.show(ctx, fmt.Sprintf("Using %s…", tool), false)
+}
+
+// fail appends a notice to whatever part of the reply was shown
+func (r *streamedReply) fail(ctx context.Context, notice string) error {
+	text := strings.TrimSpace(r.text)
+	if text != "" {
+		text += "\n\n"
+	}
+	return r.Update(ctx, text+"⚠️ "+notice, true)
+}
+
+// show sends the reply or edits it to show text
+func (r *streamedReply) show(ctx context.Context, text string, final bool) error {
+	if !final && time.Since(r.lastFlush) < r.interval {
+		return nil
+	}
+	if strings.TrimSpace(text) == "" {
+		if !final {
+			return nil
+		}
+		text = "(empty reply)"
+	}
+	if text == r.sent {
+		return nil
+	}
+	r.lastFlush = time.Now()
+
+	if r.eventID == "" {
+		content := &messageContent{
+			MsgType:   msgTypeNotice,
+			Body:      text,
+			RelatesTo: &relatesTo{InReplyTo: &inReplyTo{EventID: r.replyTo}},
+		}
+		if r.threadID != "" {
+			content.RelatesTo.RelType = "m.thread"
+			content.RelatesTo.EventID = r.threadID
+			content.RelatesTo.IsFallingBack = true
+		}
+		eventID, err := r.client.SendMessage(ctx, r.roomID, content)
+		if err != nil {
+			return err
+		}
+		r.eventID, r.sent = eventID, text
+		return nil
+	}
+
+	_, err := r.client.SendMessage(ctx, r.roomID, &messageContent{
+		MsgType:    msgTypeNotice,
+		Body:       "* " + text,
+		NewContent: &messageContent{MsgType: msgTypeNotice, Body: text},
+		RelatesTo:  &relatesTo{RelType: "m.replace", EventID: r.eventID},
+	})
+	if err != nil {
+		return err
+	}
+	r.sent = text
+	return nil
+}
@@ -0,0 +1,114 @@
+package channels
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status reports whether a channel is connected
+type Status struct {
+	Name      string     `json:"name"`
+	Running   bool       `json:"running"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// Registry runs the configured channels
+type Registry struct {
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	channels map[string]Channel
+	status   map[string]*Status
+}
+
+// NewRegistry creates an empty channel registry
+func NewRegistry(logger *slog.Logger) *Registry {
+	return &Registry{
+		logger:   logger,
+		channels: make(map[string]Channel),
+		status:   make(map[string]*Status),
+	}
+}
+
+// Register adds a channel, replacing one of the same name
+func (r *Registry) Register(channel Channel) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.channels[channel.Name()] = channel
+	r.status[channel.Name()] = &Status{Name: channel.Name()}
+}
+
+// Get returns a channel by name
+func (r *Registry) Get(name string) (Channel, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	channel, ok := r.channels[name]
+	return channel, ok
+}
+
+// Start starts every channel. A channel that fails to start is logged and
+// reported in its status; the others keep running.
+func (r *Registry) Start(ctx context.Context) {
+	for _, name := range r.names() {
+		channel, _ := r.Get(name)
+		err := channel.Start(ctx)
+
+		r.mu.Lock()
+		status := r.status[name]
+		if err != nil {
+			status.Error = err.Error()
+		} else {
+			now := time.Now().UTC()
+			status.Running, status.StartedAt, status.Error = true, &now, ""
+		}
+		r.mu.Unlock()
+
+		if err != nil {
+			r.logger.Error("Failed to start channel", "channel", name, "error", err)
+		} else {
+			r.logger.Info("Channel started", "channel", name)
+		}
+	}
+}
+
+// Stop stops the running channels
+func (r *Registry) Stop() {
+	for _, name := range r.names() {
+		r.mu.Lock()
+		channel, status := r.channels[name], r.status[name]
+		running := status.Running
+		status.Running = false
+		r.mu.Unlock()
+
+		if running {
+			channel.Stop()
+		}
+	}
+}
+
+// Status returns the status of every channel, sorted by name
+func (r *Registry) Status() []Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	statuses := make([]Status, 0, len(r.status))
+	for _, status := range r.status {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+func (r *Registry) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.channels))
+	for name := range r.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
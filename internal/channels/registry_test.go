@@ -0,0 +1,45 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeChannel struct {
+	name     string
+	startErr error
+	stopped  bool
+}
+
+func (c *fakeChannel) Name() string                    { return c.name }
+func (c *fakeChannel) Start(ctx context.Context) error { return c.startErr }
+func (c *fakeChannel) Stop()                           { c.stopped = true }
+
+func TestRegistry_StartStop(t *testing.T) {
+	registry := NewRegistry(slog.Default())
+	matrix := &fakeChannel{name: "matrix"}
+	discord := &fakeChannel{name: "discord", startErr: errors.New("invalid token")}
+	registry.Register(matrix)
+	registry.Register(discord)
+
+	registry.Start(context.Background())
+	statuses := registry.Status()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "discord", statuses[0].Name)
+	assert.False(t, statuses[0].Running)
+	assert.Equal(t, "invalid token", statuses[0].Error)
+	assert.Equal(t, "matrix", statuses[1].Name)
+	assert.True(t, statuses[1].Running)
+	assert.NotNil(t, statuses[1].StartedAt)
+
+	// Only channels that started are stopped
+	registry.Stop()
+	assert.True(t, matrix.stopped)
+	assert.False(t, discord.stopped)
+	assert.False(t, registry.Status()[1].Running)
+}
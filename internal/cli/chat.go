@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"agent-server/internal/models"
+)
+
+// exitCommands end an interactive chat
+var exitCommands = map[string]bool{"/exit": true, "/quit": true}
+
+func runChat(ctx context.Context, e *env, args []string) error {
+	fs := e.flagSet("chat")
+	agentID := fs.String("agent", "", "Agent ID (required unless --session is given)")
+	sessionID := fs.String("session", "", "Continue an existing session")
+	message := fs.String("message", "", "Send a single message and exit")
+	title := fs.String("title", "CLI chat", "Title of a newly created session")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *agentID == "" && *sessionID == "" {
+		return fmt.Errorf("--agent or --session is required")
+	}
+
+	client := NewClient(e.server)
+
+	if *sessionID == "" {
+		session, err := client.CreateSession(ctx, *agentID, &models.CreateSessionRequest{Title: *title})
+		if err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+		*sessionID = session.ID
+		fmt.Fprintf(e.stderr, "session %s\n", session.ID)
+	}
+
+	if *message != "" {
+		return e.streamReply(ctx, client, *sessionID, *message)
+	}
+
+	fmt.Fprintln(e.stderr, "Type a message and press enter. /exit or Ctrl-D quits.")
+	scanner := bufio.NewScanner(e.stdin)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for {
+		fmt.Fprint(e.stdout, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(e.stdout)
+			return scanner.Err()
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if exitCommands[line] {
+			return nil
+		}
+
+		if err := e.streamReply(ctx, client, *sessionID, line); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// Keep the REPL alive; the next message may well succeed
+			fmt.Fprintf(e.stderr, "error: %v\n", err)
+		}
+	}
+}
+
+// streamReply sends one message and prints the reply as it streams in
+func (e *env) streamReply(ctx context.Context, client *Client, sessionID, message string) error {
+	err := client.Stream(ctx, sessionID, message, func(chunk StreamChunk) {
+		fmt.Fprint(e.stdout, chunk.Content)
+	})
+	fmt.Fprintln(e.stdout)
+	return err
+}
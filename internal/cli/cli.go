@@ -0,0 +1,259 @@
+// Package cli implements the agent-server subcommands: client commands that
+// talk to a running server over its REST API, and local configuration and
+// diagnostics helpers.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"agent-server/internal/config"
+	"agent-server/internal/models"
+)
+
+// DefaultServerURL is used when neither --server nor AGENT_SERVER_URL is set
+const DefaultServerURL = "http://localhost:8080"
+
+// command is a CLI subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, env *env, args []string) error
+}
+
+// env carries the I/O streams and server address shared by all commands
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	server string
+}
+
+func commands() []command {
+	return []command{
+		{"chat", "Chat with an agent interactively (or send one --message)", runChat},
+		{"agents", "List or create agents (agents list | agents create)", runAgents},
+		{"sessions", "List sessions of an agent (sessions list --agent <id>)", runSessions},
+		{"tools", "Run a tool on the server (tools test <name> --args '{...}')", runTools},
+		{"config", "Print or check the merged configuration (config print-effective | validate)", runConfig},
+		{"loadtest", "Send synthetic chat traffic and report latencies (needs llm.mock)", runLoadTest},
+		{"doctor", "Check the database, disk, network and agents before starting", runDoctor},
+	}
+}
+
+// IsCommand reports whether name is a client subcommand
+func IsCommand(name string) bool {
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return true
+		}
+	}
+	return name == "help"
+}
+
+// Run executes the subcommand in args[0] and returns the process exit code
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" {
+		usage(stderr)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	e := &env{stdin: stdin, stdout: stdout, stderr: stderr, server: os.Getenv("AGENT_SERVER_URL")}
+	if e.server == "" {
+		e.server = DefaultServerURL
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name != args[0] {
+			continue
+		}
+		if err := cmd.run(ctx, e, args[1:]); err != nil {
+			if err == flag.ErrHelp {
+				return 2
+			}
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: agent-server [-config file] [flags]  start the server")
+	fmt.Fprintln(w, "       agent-server <command> [flags]        talk to a running server")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "The server address is taken from --server or AGENT_SERVER_URL (default %s).\n", DefaultServerURL)
+}
+
+// flagSet creates a flag set with the common --server flag
+func (e *env) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.StringVar(&e.server, "server", e.server, "Agent server base URL")
+	return fs
+}
+
+// printJSON writes v as indented JSON
+func (e *env) printJSON(v interface{}) error {
+	enc := json.NewEncoder(e.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func runAgents(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: agents list | agents create --name <name> --model <model> --prompt <prompt>")
+	}
+
+	switch args[0] {
+	case "list":
+		fs := e.flagSet("agents list")
+		pageSize := fs.Int("limit", 50, "Maximum number of agents")
+		asJSON := fs.Bool("json", false, "Print raw JSON")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		agents, err := NewClient(e.server).ListAgents(ctx, *pageSize)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return e.printJSON(agents)
+		}
+
+		w := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPROVIDER\tMODEL\tENABLED")
+		for _, agent := range agents {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\n", agent.ID, agent.Name, agent.Provider, agent.Model, agent.Enabled)
+		}
+		return w.Flush()
+
+	case "create":
+		fs := e.flagSet("agents create")
+		req := models.CreateAgentRequest{}
+		fs.StringVar(&req.Name, "name", "", "Agent name (required)")
+		fs.StringVar(&req.Description, "description", "", "Agent description")
+		fs.StringVar(&req.Provider, "provider", "ollama", "LLM provider")
+		fs.StringVar(&req.Model, "model", "", "Model name (required)")
+		fs.StringVar(&req.SystemPrompt, "prompt", "You are a helpful assistant.", "System prompt")
+		tags := fs.String("tags", "", "Comma-separated tags")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if req.Name == "" || req.Model == "" {
+			return fmt.Errorf("--name and --model are required")
+		}
+		if *tags != "" {
+			req.Tags = strings.Split(*tags, ",")
+		}
+
+		agent, err := NewClient(e.server).CreateAgent(ctx, &req)
+		if err != nil {
+			return err
+		}
+		return e.printJSON(agent)
+
+	default:
+		return fmt.Errorf("unknown agents subcommand %q", args[0])
+	}
+}
+
+func runSessions(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("usage: sessions list --agent <id>")
+	}
+
+	fs := e.flagSet("sessions list")
+	agentID := fs.String("agent", "", "Agent ID (required)")
+	pageSize := fs.Int("limit", 50, "Maximum number of sessions")
+	asJSON := fs.Bool("json", false, "Print raw JSON")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *agentID == "" {
+		return fmt.Errorf("--agent is required")
+	}
+
+	sessions, err := NewClient(e.server).ListSessions(ctx, *agentID, *pageSize)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return e.printJSON(sessions)
+	}
+
+	w := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTITLE\tSTRATEGY\tUPDATED")
+	for _, session := range sessions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", session.ID, session.Title, session.ContextStrategy, session.UpdatedAt.Format("2006-01-02 15:04"))
+	}
+	return w.Flush()
+}
+
+func runTools(ctx context.Context, e *env, args []string) error {
+	if len(args) < 2 || args[0] != "test" {
+		return fmt.Errorf("usage: tools test <name> [--args '{\"key\": \"value\"}']")
+	}
+
+	name := args[1]
+	fs := e.flagSet("tools test")
+	rawArgs := fs.String("args", "{}", "Tool arguments as a JSON object")
+	if err := fs.Parse(args[2:]); err != nil {
+		return err
+	}
+
+	var arguments map[string]interface{}
+	if err := json.Unmarshal([]byte(*rawArgs), &arguments); err != nil {
+		return fmt.Errorf("--args must be a JSON object: %w", err)
+	}
+
+	result, err := NewClient(e.server).TestTool(ctx, name, arguments)
+	if err != nil {
+		return err
+	}
+	return e.printJSON(result)
+}
+
+func runConfig(ctx context.Context, e *env, args []string) error {
+	if len(args) > 0 && args[0] == "validate" {
+		return runValidate(ctx, e, args[1:])
+	}
+	if len(args) == 0 || args[0] != "print-effective" {
+		return fmt.Errorf("usage: config print-effective | validate [-config file] [-profile name] [-set key=value ...]")
+	}
+
+	// Accepts the same flags as the server so the output matches what it would run with
+	fs := flag.NewFlagSet("config print-effective", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	flags := config.RegisterFlags(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	out, err := flags.PrintEffective()
+	if err != nil {
+		return err
+	}
+	_, err = e.stdout.Write(out)
+	return err
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/agents", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "5", r.URL.Query().Get("page_size"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"agents":[{"id":"a1","name":"helper","provider":"ollama","model":"llama3","enabled":true}]}`)
+	})
+	mux.HandleFunc("/api/v1/agents/a1/sessions", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"s1","agent_id":"a1"}`)
+	})
+	mux.HandleFunc("/api/v1/sessions/s1/stream", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, word := range strings.Fields(body["message"]) {
+			fmt.Fprintf(w, "data: {\"content\":%q,\"done\":false}\n\n", strings.ToUpper(word)+" ")
+		}
+		fmt.Fprint(w, "data: {\"content\":\"\",\"done\":true}\n\n")
+	})
+	mux.HandleFunc("/api/v1/tools/missing/test", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"type":"urn:agent-server:problem:not-found","title":"Tool not found","status":404,"detail":"missing","code":"NOT_FOUND"}`)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRun_AgentsList(t *testing.T) {
+	server := newTestServer(t)
+	var stdout, stderr bytes.Buffer
+
+	code := Run([]string{"agents", "list", "--server", server.URL, "--limit", "5"}, nil, &stdout, &stderr)
+
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "a1")
+	assert.Contains(t, stdout.String(), "llama3")
+}
+
+func TestRun_ChatREPL(t *testing.T) {
+	server := newTestServer(t)
+	var stdout, stderr bytes.Buffer
+	stdin := strings.NewReader("hello there\n\n/exit\nnever sent\n")
+
+	code := Run([]string{"chat", "--server", server.URL, "--agent", "a1"}, stdin, &stdout, &stderr)
+
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stderr.String(), "session s1")
+	assert.Contains(t, stdout.String(), "HELLO THERE")
+	assert.NotContains(t, stdout.String(), "NEVER")
+}
+
+func TestRun_APIError(t *testing.T) {
+	server := newTestServer(t)
+	var stdout, stderr bytes.Buffer
+
+	code := Run([]string{"tools", "test", "missing", "--server", server.URL}, nil, &stdout, &stderr)
+
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "Tool not found (HTTP 404, NOT_FOUND): missing")
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, Run([]string{"bogus"}, nil, &stdout, &stderr))
+	assert.False(t, IsCommand("bogus"))
+	assert.True(t, IsCommand("chat"))
+}
+
+func TestRun_ConfigValidate(t *testing.T) {
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/tags", r.URL.Path)
+		fmt.Fprint(w, `{"models":[{"name":"llama3"},{"name":"qwen2"}]}`)
+	}))
+	defer ollama.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("llm:\n  providers:\n    ollama:\n      base_url: http://127.0.0.1:1\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.dev.yaml"),
+		[]byte("llm:\n  providers:\n    ollama:\n      base_url: "+ollama.URL+"\n"), 0o600))
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"config", "validate", "-config", path, "-profile", "dev"}, nil, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "ok    configuration: valid (profiles: dev)")
+	assert.Contains(t, stdout.String(), "ok    provider ollama: "+ollama.URL+" answers with 2 models")
+
+	// The base file alone points at an unreachable provider
+	stdout.Reset()
+	code = Run([]string{"config", "validate", "-config", path}, nil, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout.String(), "FAIL  provider ollama")
+
+	stdout.Reset()
+	code = Run([]string{"config", "validate", "-config", path, "-set", "server.port=0"}, nil, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout.String(), "FAIL  configuration")
+}
+
+func TestRun_Doctor(t *testing.T) {
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"models":[{"name":"llama3:latest"}]}`)
+	}))
+	defer ollama.Close()
+
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "agents.db")
+	repo, err := sqlite.NewRepository(dbPath)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, repo.Agent().Create(ctx, &models.Agent{Name: "ready", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{}}))
+	require.NoError(t, repo.Agent().Create(ctx, &models.Agent{Name: "missing-model", Provider: "ollama", Model: "qwen2", Enabled: true, Config: models.JSON{}}))
+	require.NoError(t, repo.Close())
+
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(
+		"database:\n  path: %s\nllm:\n  providers:\n    ollama:\n      base_url: %s\n", dbPath, ollama.URL)), 0o600))
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"doctor", "-config", path}, nil, &stdout, &stderr)
+	assert.Equal(t, 1, code, stderr.String())
+	out := stdout.String()
+	assert.Contains(t, out, "ok    database: "+dbPath+" is readable")
+	assert.Contains(t, out, "ok    schema: current")
+	assert.Contains(t, out, "ok    egress: direct connections")
+	assert.Contains(t, out, "ok    agent ready: ollama/llama3")
+	assert.Contains(t, out, "FAIL  agent missing-model: ollama/qwen2: model qwen2 not found on provider")
+
+	// Offline, only the local checks run
+	stdout.Reset()
+	code = Run([]string{"doctor", "-config", path, "-offline"}, nil, &stdout, &stderr)
+	assert.Equal(t, 0, code, stdout.String())
+	assert.NotContains(t, stdout.String(), "agent ")
+}
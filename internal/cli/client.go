@@ -0,0 +1,239 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"agent-server/internal/models"
+)
+
+// Client is a minimal HTTP client for the agent server REST API
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a client for the server at baseURL
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		// No overall timeout: streams stay open as long as the model talks
+		httpClient: &http.Client{},
+	}
+}
+
+// APIError is a non-2xx response from the server, decoded from its
+// problem+json body
+type APIError struct {
+	StatusCode int
+	Code       string `json:"code"`
+	Title      string `json:"title"`
+	Detail     string `json:"detail"`
+	Fields     []struct {
+		Name   string `json:"name"`
+		Reason string `json:"reason"`
+	} `json:"fields"`
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s (HTTP %d", e.Title, e.StatusCode)
+	if e.Code != "" {
+		msg += ", " + e.Code
+	}
+	msg += ")"
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	for _, field := range e.Fields {
+		msg += fmt.Sprintf("\n  %s %s", field.Name, field.Reason)
+	}
+	return msg
+}
+
+// StreamChunk is one server-sent event of a streaming chat
+type StreamChunk struct {
+	Content   string                 `json:"content"`
+	Done      bool                   `json:"done"`
+	MessageID string                 `json:"message_id,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ListAgents returns one page of agents
+func (c *Client) ListAgents(ctx context.Context, pageSize int) ([]*models.Agent, error) {
+	var response struct {
+		Agents []*models.Agent `json:"agents"`
+	}
+	path := fmt.Sprintf("/api/v1/agents?page_size=%d", pageSize)
+	if err := c.do(ctx, http.MethodGet, path, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Agents, nil
+}
+
+// CreateAgent creates a new agent
+func (c *Client) CreateAgent(ctx context.Context, req *models.CreateAgentRequest) (*models.Agent, error) {
+	var agent models.Agent
+	if err := c.do(ctx, http.MethodPost, "/api/v1/agents", req, &agent); err != nil {
+		return nil, err
+	}
+	return &agent, nil
+}
+
+// DeleteAgent deletes an agent
+func (c *Client) DeleteAgent(ctx context.Context, agentID string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/agents/"+agentID, nil, nil)
+}
+
+// ListSessions returns one page of an agent's sessions
+func (c *Client) ListSessions(ctx context.Context, agentID string, pageSize int) ([]*models.ChatSession, error) {
+	var response struct {
+		Sessions []*models.ChatSession `json:"sessions"`
+	}
+	path := fmt.Sprintf("/api/v1/agents/%s/sessions?page_size=%d", agentID, pageSize)
+	if err := c.do(ctx, http.MethodGet, path, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Sessions, nil
+}
+
+// CreateSession starts a new session for an agent
+func (c *Client) CreateSession(ctx context.Context, agentID string, req *models.CreateSessionRequest) (*models.ChatSession, error) {
+	var session models.ChatSession
+	if err := c.do(ctx, http.MethodPost, "/api/v1/agents/"+agentID+"/sessions", req, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Chat sends a message to a session and returns the complete reply
+func (c *Client) Chat(ctx context.Context, sessionID, message string) (string, error) {
+	var response struct {
+		Response string `json:"response"`
+	}
+	req := map[string]interface{}{"message": message}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/sessions/"+sessionID+"/chat", req, &response); err != nil {
+		return "", err
+	}
+	return response.Response, nil
+}
+
+// ChatWithTools sends a message to a session, letting the agent call the
+// given tools, and returns the final reply
+func (c *Client) ChatWithTools(ctx context.Context, sessionID string, req *models.EnhancedChatRequest) (*models.EnhancedChatResponse, error) {
+	var response models.EnhancedChatResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/sessions/"+sessionID+"/chat/tools", req, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// TestTool runs a tool with the given arguments on the server
+func (c *Client) TestTool(ctx context.Context, name string, arguments map[string]interface{}) (json.RawMessage, error) {
+	req := models.ToolTestRequest{ToolName: name, Arguments: arguments}
+	var response json.RawMessage
+	if err := c.do(ctx, http.MethodPost, "/api/v1/tools/"+name+"/test", req, &response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Stream sends a message to a session and calls onChunk for every streamed
+// chunk until the reply is complete
+func (c *Client) Stream(ctx context.Context, sessionID, message string, onChunk func(StreamChunk)) error {
+	body, err := json.Marshal(map[string]interface{}{"message": message})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v1/sessions/"+sessionID+"/stream", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return decodeError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var chunk StreamChunk
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &chunk); err != nil {
+			return fmt.Errorf("invalid stream chunk: %w", err)
+		}
+		onChunk(chunk)
+		if chunk.Done {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// do sends a JSON request and decodes a JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return decodeError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// decodeError turns an error response into an APIError
+func decodeError(resp *http.Response) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err := json.Unmarshal(data, apiErr); err != nil || apiErr.Title == "" {
+		apiErr.Title = strings.TrimSpace(string(data))
+		if apiErr.Title == "" {
+			apiErr.Title = http.StatusText(resp.StatusCode)
+		}
+	}
+	return apiErr
+}
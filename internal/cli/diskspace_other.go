@@ -0,0 +1,10 @@
+//go:build !unix
+
+package cli
+
+import "errors"
+
+// freeSpace is not supported on this platform
+func freeSpace(dir string) (uint64, error) {
+	return 0, errors.New("free space is not reported on this platform")
+}
@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agent-server/internal/config"
+	"agent-server/internal/egress"
+	"agent-server/internal/llm"
+	"agent-server/internal/llm/mock"
+	"agent-server/internal/llm/ollama"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage"
+	"agent-server/internal/storage/sqlite"
+)
+
+// Free space below which the data directory is reported
+const (
+	diskWarnBytes = 1 << 30
+	diskFailBytes = 100 << 20
+)
+
+// proxyDialTimeout bounds the connection test to the egress proxy
+const proxyDialTimeout = 5 * time.Second
+
+// runDoctor checks what the server needs before it starts: the database and
+// its schema, disk space, the egress proxy, the providers and the model and
+// tools of every agent. It only reads the database.
+func runDoctor(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	flags := config.RegisterFlags(fs)
+	offline := fs.Bool("offline", false, "Skip the checks that need the network")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := flags.Load()
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		report(e.stdout, []check{{checkFail, "configuration", err.Error()}})
+		return fmt.Errorf("doctor found problems")
+	}
+	checks := []check{{checkOK, "configuration", "valid"}}
+
+	checks = append(checks, checkDataDir(cfg.Database.Path))
+	repo, dbChecks := checkDatabase(ctx, cfg.Database.Path)
+	checks = append(checks, dbChecks...)
+	if repo != nil {
+		defer repo.Close()
+	}
+
+	policy, err := egress.New(cfg.Egress)
+	if err != nil {
+		checks = append(checks, check{checkFail, "egress", err.Error()})
+	} else {
+		checks = append(checks, checkEgress(ctx, policy, *offline))
+	}
+
+	if !*offline && policy != nil {
+		checks = append(checks, checkProviders(ctx, cfg)...)
+		if repo != nil {
+			checks = append(checks, checkAgents(ctx, cfg, repo, policy)...)
+		}
+	}
+
+	if report(e.stdout, checks) {
+		return fmt.Errorf("doctor found problems")
+	}
+	return nil
+}
+
+// checkDataDir reports the free space of the directory holding the database
+func checkDataDir(dbPath string) check {
+	dir := filepath.Dir(dbPath)
+	if _, err := os.Stat(dir); err != nil {
+		return check{checkWarn, "data directory", dir + " does not exist yet; it is created at start"}
+	}
+	free, err := freeSpace(dir)
+	if err != nil {
+		return check{checkWarn, "data directory", err.Error()}
+	}
+	detail := fmt.Sprintf("%s has %s free", dir, formatBytes(free))
+	switch {
+	case free < diskFailBytes:
+		return check{checkFail, "data directory", detail}
+	case free < diskWarnBytes:
+		return check{checkWarn, "data directory", detail}
+	}
+	return check{checkOK, "data directory", detail}
+}
+
+// checkDatabase opens the database read-only and compares its schema with
+// the current one. The repository is nil when it cannot be opened.
+func checkDatabase(ctx context.Context, dbPath string) (storage.Repository, []check) {
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		return nil, []check{{checkWarn, "database", dbPath + " does not exist yet; it is created at start"}}
+	}
+
+	repo, err := sqlite.NewRepositoryWithOptions(dbPath, sqlite.Options{ReadOnly: true})
+	if err != nil {
+		return nil, []check{{checkFail, "database", err.Error()}}
+	}
+	if err := repo.Ping(ctx); err != nil {
+		repo.Close()
+		return nil, []check{{checkFail, "database", err.Error()}}
+	}
+	checks := []check{{checkOK, "database", dbPath + " is readable"}}
+
+	missing, err := sqlite.CheckSchema(repo)
+	switch {
+	case err != nil:
+		checks = append(checks, check{checkFail, "schema", err.Error()})
+	case len(missing) > 0:
+		checks = append(checks, check{checkWarn, "schema",
+			"behind; the next start adds " + strings.Join(missing, ", ")})
+	default:
+		checks = append(checks, check{checkOK, "schema", "current"})
+	}
+	return repo, checks
+}
+
+// checkEgress reports how outbound requests leave and whether the proxy
+// accepts connections
+func checkEgress(ctx context.Context, policy *egress.Policy, offline bool) check {
+	probe, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	proxy, err := policy.Proxy("")(probe)
+	if err != nil {
+		return check{checkFail, "egress", err.Error()}
+	}
+	if proxy == nil {
+		return check{checkOK, "egress", "direct connections"}
+	}
+
+	detail := "through proxy " + proxy.Redacted()
+	if policy.Forced() {
+		detail = "forced " + detail
+	}
+	if offline {
+		return check{checkOK, "egress", detail}
+	}
+
+	host := proxy.Host
+	if proxy.Port() == "" {
+		host = net.JoinHostPort(proxy.Hostname(), "80")
+	}
+	dialer := net.Dialer{Timeout: proxyDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return check{checkFail, "egress", detail + ": " + err.Error()}
+	}
+	conn.Close()
+	return check{checkOK, "egress", detail}
+}
+
+// checkAgents checks the provider, model and tools of every agent like
+// GET /agents/:id/status
+func checkAgents(ctx context.Context, cfg *config.Config, repo storage.Repository, policy *egress.Policy) []check {
+	registry := llm.NewRegistry()
+	if providerCfg, exists := cfg.LLM.Providers["ollama"]; exists {
+		provider := ollama.NewProvider(providerCfg.BaseURL)
+		provider.SetTransport(policy.Transport(""))
+		registry.Register(provider)
+	}
+	if cfg.LLM.Mock.Enabled {
+		registry.Register(mock.NewProvider(mock.Options{}))
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	toolService := services.NewToolService(repo, logger)
+	toolService.SetEgress(policy)
+	statuses := services.NewAgentStatusService(repo, registry, toolService, nil, logger)
+
+	var agents []*models.Agent
+	for {
+		page, _, err := repo.Agent().List(ctx, &models.AgentFilter{Limit: 100, Offset: len(agents)})
+		if err != nil {
+			return []check{{checkFail, "agents", err.Error()}}
+		}
+		agents = append(agents, page...)
+		if len(page) < 100 {
+			break
+		}
+	}
+	if len(agents) == 0 {
+		return []check{{checkOK, "agents", "none defined"}}
+	}
+
+	var checks []check
+	for _, agent := range agents {
+		name := "agent " + agent.Name
+		status, err := statuses.GetStatus(ctx, agent.ID)
+		if err != nil || status == nil {
+			checks = append(checks, check{checkFail, name, fmt.Sprint("status unavailable: ", err)})
+			continue
+		}
+
+		detail := agent.Provider + "/" + agent.Model
+		if len(status.Issues) > 0 {
+			detail += ": " + strings.Join(status.Issues, "; ")
+		}
+		switch status.Status {
+		case services.AgentStatusUnavailable:
+			checks = append(checks, check{checkFail, name, detail})
+		case services.AgentStatusDegraded:
+			checks = append(checks, check{checkWarn, name, detail})
+		case services.AgentStatusDisabled:
+			checks = append(checks, check{checkOK, name, detail + " (disabled)"})
+		default:
+			checks = append(checks, check{checkOK, name, detail})
+		}
+	}
+	return checks
+}
+
+// formatBytes renders a size in binary units
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,388 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"agent-server/internal/models"
+)
+
+// Request kinds of a load test
+const (
+	kindChat   = "chat"
+	kindStream = "stream"
+	kindTools  = "tools"
+)
+
+// loadTestToolMessage makes the mock provider call the calculator
+const loadTestToolMessage = "What is 17 * 23?\n/tool calculator {\"expression\": \"17 * 23\"}"
+
+// loadTestOptions configure a load test
+type loadTestOptions struct {
+	Duration        time.Duration
+	RPS             float64
+	Sessions        int
+	ToolProbability float64
+	StreamRatio     float64
+	AgentID         string
+	Keep            bool
+}
+
+// sample is the outcome of one request
+type sample struct {
+	kind    string
+	latency time.Duration
+	ttfb    time.Duration // time to the first streamed chunk
+	err     error
+}
+
+// LatencyStats summarizes the latencies of one kind of request in
+// milliseconds
+type LatencyStats struct {
+	Requests int     `json:"requests"`
+	Errors   int     `json:"errors"`
+	Mean     float64 `json:"mean_ms"`
+	P50      float64 `json:"p50_ms"`
+	P90      float64 `json:"p90_ms"`
+	P99      float64 `json:"p99_ms"`
+	Max      float64 `json:"max_ms"`
+	TTFBP50  float64 `json:"ttfb_p50_ms,omitempty"`
+}
+
+// LoadTestReport is the result of a load test
+type LoadTestReport struct {
+	Duration  float64                  `json:"duration_seconds"`
+	TargetRPS float64                  `json:"target_rps"`
+	RPS       float64                  `json:"rps"`
+	Requests  int                      `json:"requests"`
+	Errors    int                      `json:"errors"`
+	ErrorRate float64                  `json:"error_rate"`
+	Dropped   int                      `json:"dropped"` // not sent because every session was busy
+	Kinds     map[string]*LatencyStats `json:"kinds"`
+	Total     *LatencyStats            `json:"total"`
+	Failures  map[string]int           `json:"failures,omitempty"` // by cause
+}
+
+func runLoadTest(ctx context.Context, e *env, args []string) error {
+	fs := e.flagSet("loadtest")
+	opts := loadTestOptions{}
+	fs.DurationVar(&opts.Duration, "duration", 30*time.Second, "How long to send requests")
+	fs.Float64Var(&opts.RPS, "rps", 5, "Requests per second")
+	fs.IntVar(&opts.Sessions, "sessions", 10, "Concurrent synthetic sessions; each has at most one request in flight")
+	fs.Float64Var(&opts.ToolProbability, "tool-prob", 0.2, "Share of non-streaming requests that make the agent call a tool")
+	fs.Float64Var(&opts.StreamRatio, "stream-ratio", 0.3, "Share of requests that stream the reply")
+	fs.StringVar(&opts.AgentID, "agent", "", "Agent to test (default: create a temporary agent using the mock provider)")
+	fs.BoolVar(&opts.Keep, "keep", false, "Keep the temporary agent and its sessions")
+	maxErrorRate := fs.Float64("max-error-rate", 0.01, "Fail when more than this share of requests fails")
+	maxP99 := fs.Duration("max-p99", 0, "Fail when the p99 latency exceeds this (0 disables)")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch {
+	case opts.Duration <= 0 || opts.RPS <= 0 || opts.Sessions <= 0:
+		return fmt.Errorf("--duration, --rps and --sessions must be positive")
+	case opts.ToolProbability < 0 || opts.ToolProbability > 1 || opts.StreamRatio < 0 || opts.StreamRatio > 1:
+		return fmt.Errorf("--tool-prob and --stream-ratio must be between 0 and 1")
+	}
+
+	report, err := loadTest(ctx, NewClient(e.server), opts, e)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if err := e.printJSON(report); err != nil {
+			return err
+		}
+	} else if err := printLoadTestReport(e, report); err != nil {
+		return err
+	}
+
+	if report.ErrorRate > *maxErrorRate {
+		return fmt.Errorf("error rate %.2f%% exceeds --max-error-rate %.2f%%", report.ErrorRate*100, *maxErrorRate*100)
+	}
+	if *maxP99 > 0 && report.Total.P99 > float64(*maxP99)/float64(time.Millisecond) {
+		return fmt.Errorf("p99 latency %.0fms exceeds --max-p99 %s", report.Total.P99, *maxP99)
+	}
+	return nil
+}
+
+// loadTest sets up the agent and sessions, sends requests at the target
+// rate until the duration is over and reports the results
+func loadTest(ctx context.Context, client *Client, opts loadTestOptions, e *env) (*LoadTestReport, error) {
+	agentID := opts.AgentID
+	if agentID == "" {
+		agent, err := client.CreateAgent(ctx, &models.CreateAgentRequest{
+			Name:         fmt.Sprintf("loadtest-%d", time.Now().Unix()),
+			Description:  "Temporary agent of a load test",
+			Provider:     "mock",
+			Model:        "mock",
+			SystemPrompt: "You are a synthetic agent answering load test requests.",
+			Config:       map[string]interface{}{"tools": []string{"calculator"}},
+			Tags:         []string{"loadtest"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create load test agent: %w", err)
+		}
+		agentID = agent.ID
+		if !opts.Keep {
+			defer func() {
+				if err := client.DeleteAgent(context.WithoutCancel(ctx), agentID); err != nil {
+					fmt.Fprintf(e.stderr, "warning: failed to delete load test agent %s: %v\n", agentID, err)
+				}
+			}()
+		}
+	}
+
+	sessions := make(chan string, opts.Sessions)
+	for i := 0; i < opts.Sessions; i++ {
+		session, err := client.CreateSession(ctx, agentID, &models.CreateSessionRequest{Title: fmt.Sprintf("Load test session %d", i+1)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create session: %w", err)
+		}
+		sessions <- session.ID
+	}
+
+	// A failing warm-up request usually means the server has no mock provider
+	warmup := <-sessions
+	if _, err := client.Chat(ctx, warmup, "Warm-up"); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Code == "PROVIDER_UNAVAILABLE" && opts.AgentID == "" {
+			return nil, fmt.Errorf("warm-up request failed; enable llm.mock on the server: %w", err)
+		}
+		return nil, fmt.Errorf("warm-up request failed: %w", err)
+	}
+	sessions <- warmup
+
+	fmt.Fprintf(e.stderr, "Running load test: %.1f req/s for %s over %d sessions\n", opts.RPS, opts.Duration, opts.Sessions)
+
+	var (
+		mu      sync.Mutex
+		samples []sample
+		dropped int
+		wg      sync.WaitGroup
+	)
+	runCtx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.RPS))
+	defer ticker.Stop()
+
+	start := time.Now()
+loop:
+	for {
+		select {
+		case <-runCtx.Done():
+			break loop
+		case <-ticker.C:
+		}
+
+		var sessionID string
+		select {
+		case sessionID = <-sessions:
+		default:
+			mu.Lock()
+			dropped++
+			mu.Unlock()
+			continue
+		}
+
+		kind := pickKind(opts)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { sessions <- sessionID }()
+			s := sendRequest(ctx, client, sessionID, kind)
+			// Requests cut off by an interrupt say nothing about the server
+			if errors.Is(s.err, context.Canceled) {
+				return
+			}
+			mu.Lock()
+			samples = append(samples, s)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return newLoadTestReport(samples, dropped, time.Since(start), opts.RPS), nil
+}
+
+// pickKind chooses the kind of the next request
+func pickKind(opts loadTestOptions) string {
+	if rand.Float64() < opts.StreamRatio {
+		return kindStream
+	}
+	if rand.Float64() < opts.ToolProbability {
+		return kindTools
+	}
+	return kindChat
+}
+
+// sendRequest sends one request of the given kind and measures it
+func sendRequest(ctx context.Context, client *Client, sessionID, kind string) sample {
+	s := sample{kind: kind}
+	start := time.Now()
+	switch kind {
+	case kindStream:
+		s.err = client.Stream(ctx, sessionID, "Tell me something about load testing.", func(chunk StreamChunk) {
+			if s.ttfb == 0 {
+				s.ttfb = time.Since(start)
+			}
+		})
+	case kindTools:
+		_, s.err = client.ChatWithTools(ctx, sessionID, &models.EnhancedChatRequest{
+			Message:    loadTestToolMessage,
+			Tools:      []string{"calculator"},
+			ToolChoice: "auto",
+		})
+	default:
+		_, s.err = client.Chat(ctx, sessionID, "Hello, how are you?")
+	}
+	s.latency = time.Since(start)
+	return s
+}
+
+// newLoadTestReport summarizes the samples of a load test
+func newLoadTestReport(samples []sample, dropped int, elapsed time.Duration, targetRPS float64) *LoadTestReport {
+	report := &LoadTestReport{
+		Duration:  elapsed.Seconds(),
+		TargetRPS: targetRPS,
+		Requests:  len(samples),
+		Dropped:   dropped,
+		Kinds:     make(map[string]*LatencyStats),
+		Failures:  make(map[string]int),
+	}
+	if elapsed > 0 {
+		report.RPS = float64(len(samples)) / elapsed.Seconds()
+	}
+
+	byKind := make(map[string][]sample)
+	for _, s := range samples {
+		byKind[s.kind] = append(byKind[s.kind], s)
+		if s.err != nil {
+			report.Errors++
+			report.Failures[failureCause(s.err)]++
+		}
+	}
+	for kind, kindSamples := range byKind {
+		report.Kinds[kind] = latencyStats(kindSamples)
+	}
+	report.Total = latencyStats(samples)
+	if report.Requests > 0 {
+		report.ErrorRate = float64(report.Errors) / float64(report.Requests)
+	}
+	return report
+}
+
+// latencyStats computes the latency percentiles of successful requests
+func latencyStats(samples []sample) *LatencyStats {
+	stats := &LatencyStats{Requests: len(samples)}
+	var latencies, ttfbs []float64
+	for _, s := range samples {
+		if s.err != nil {
+			stats.Errors++
+			continue
+		}
+		latencies = append(latencies, milliseconds(s.latency))
+		if s.ttfb > 0 {
+			ttfbs = append(ttfbs, milliseconds(s.ttfb))
+		}
+	}
+	if len(latencies) == 0 {
+		return stats
+	}
+
+	sort.Float64s(latencies)
+	sum := 0.0
+	for _, l := range latencies {
+		sum += l
+	}
+	stats.Mean = sum / float64(len(latencies))
+	stats.P50 = percentile(latencies, 0.50)
+	stats.P90 = percentile(latencies, 0.90)
+	stats.P99 = percentile(latencies, 0.99)
+	stats.Max = latencies[len(latencies)-1]
+	if len(ttfbs) > 0 {
+		sort.Float64s(ttfbs)
+		stats.TTFBP50 = percentile(ttfbs, 0.50)
+	}
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// failureCause groups errors by status code and problem code
+func failureCause(err error) string {
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr):
+		if apiErr.Code != "" {
+			return fmt.Sprintf("HTTP %d %s", apiErr.StatusCode, apiErr.Code)
+		}
+		return fmt.Sprintf("HTTP %d", apiErr.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "transport error"
+	}
+}
+
+// printLoadTestReport prints the report as a table
+func printLoadTestReport(e *env, report *LoadTestReport) error {
+	fmt.Fprintf(e.stdout, "%d requests in %.1fs: %.2f req/s (target %.2f), %d errors (%.2f%%), %d dropped\n\n",
+		report.Requests, report.Duration, report.RPS, report.TargetRPS, report.Errors, report.ErrorRate*100, report.Dropped)
+
+	w := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tREQUESTS\tERRORS\tMEAN\tP50\tP90\tP99\tMAX\tTTFB P50\t")
+	row := func(name string, stats *LatencyStats) {
+		ttfb := "-"
+		if stats.TTFBP50 > 0 {
+			ttfb = fmt.Sprintf("%.0fms", stats.TTFBP50)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.0fms\t%.0fms\t%.0fms\t%.0fms\t%.0fms\t%s\t\n",
+			name, stats.Requests, stats.Errors, stats.Mean, stats.P50, stats.P90, stats.P99, stats.Max, ttfb)
+	}
+	for _, kind := range []string{kindChat, kindStream, kindTools} {
+		if stats, ok := report.Kinds[kind]; ok {
+			row(kind, stats)
+		}
+	}
+	row("total", report.Total)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.Failures) > 0 {
+		causes := make([]string, 0, len(report.Failures))
+		for cause := range report.Failures {
+			causes = append(causes, cause)
+		}
+		sort.Strings(causes)
+		fmt.Fprintln(e.stdout, "\nFailures:")
+		for _, cause := range causes {
+			fmt.Fprintf(e.stdout, "  %-40s %d\n", strings.TrimSpace(cause), report.Failures[cause])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_LoadTest(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+	deleted := false
+	count := func(kind string) {
+		mu.Lock()
+		requests[kind]++
+		mu.Unlock()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/agents", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "mock", body["provider"])
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"lt","provider":"mock","model":"mock"}`)
+	})
+	mux.HandleFunc("/api/v1/agents/lt", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		mu.Lock()
+		deleted = true
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	sessions := 0
+	mux.HandleFunc("/api/v1/agents/lt/sessions", func(w http.ResponseWriter, r *http.Request) {
+		sessions++
+		fmt.Fprintf(w, `{"id":"s%d","agent_id":"lt"}`, sessions)
+	})
+	mux.HandleFunc("/api/v1/sessions/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/chat/tools"):
+			count(kindTools)
+			// Tool calls fail, to show up in the report
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, `{"title":"Chat failed","status":502,"code":"PROVIDER_ERROR"}`)
+		case strings.HasSuffix(r.URL.Path, "/stream"):
+			count(kindStream)
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"content\":\"Mock\",\"done\":false}\n\n")
+			fmt.Fprint(w, "data: {\"content\":\"\",\"done\":true}\n\n")
+		default:
+			count(kindChat)
+			fmt.Fprint(w, `{"response":"Mock reply"}`)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"loadtest", "--server", server.URL, "--duration", "400ms", "--rps", "100",
+		"--sessions", "4", "--stream-ratio", "0.5", "--tool-prob", "0.5", "--max-error-rate", "1", "--json"}, nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	var report LoadTestReport
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.Equal(t, 4, sessions)
+	assert.True(t, deleted)
+	assert.Greater(t, report.Requests, 10)
+	assert.Equal(t, report.Kinds[kindTools].Requests, report.Errors)
+	assert.Equal(t, report.Errors, report.Failures["HTTP 502 PROVIDER_ERROR"])
+	assert.Zero(t, report.Kinds[kindStream].Errors)
+	assert.Greater(t, report.Kinds[kindStream].TTFBP50, 0.0)
+	assert.Equal(t, report.Requests, report.Total.Requests)
+
+	// The run fails when the error rate is above the limit
+	stdout.Reset()
+	code = Run([]string{"loadtest", "--server", server.URL, "--duration", "200ms", "--rps", "50",
+		"--sessions", "2", "--stream-ratio", "0", "--tool-prob", "1"}, nil, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "exceeds --max-error-rate")
+	assert.Contains(t, stdout.String(), "HTTP 502 PROVIDER_ERROR")
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	assert.Equal(t, 5.0, percentile(values, 0.5))
+	assert.Equal(t, 9.0, percentile(values, 0.9))
+	assert.Equal(t, 10.0, percentile(values, 0.99))
+	assert.Equal(t, 1.0, percentile(values[:1], 0.99))
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"agent-server/internal/config"
+	"agent-server/internal/egress"
+	"agent-server/internal/llm/ollama"
+)
+
+// providerTimeout bounds each provider connectivity check
+const providerTimeout = 10 * time.Second
+
+// Check outcomes of configuration and diagnostics reports
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "FAIL"
+)
+
+// check is one line of a report
+type check struct {
+	status string
+	name   string
+	detail string
+}
+
+// report prints checks and reports whether any failed
+func report(w io.Writer, checks []check) bool {
+	failed := false
+	for _, c := range checks {
+		fmt.Fprintf(w, "%-5s %s: %s\n", c.status, c.name, c.detail)
+		failed = failed || c.status == checkFail
+	}
+	return failed
+}
+
+// runValidate loads the configuration like the server would, validates it
+// and checks that the configured providers answer
+func runValidate(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	flags := config.RegisterFlags(fs)
+	offline := fs.Bool("offline", false, "Skip the provider connectivity checks")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := flags.Load()
+	if err != nil {
+		return err
+	}
+	profiles := "none"
+	if names := flags.Profiles(); len(names) > 0 {
+		profiles = strings.Join(names, ", ")
+	}
+	if err := cfg.Validate(); err != nil {
+		report(e.stdout, []check{{checkFail, "configuration", err.Error()}})
+		return fmt.Errorf("configuration is invalid")
+	}
+	checks := []check{{checkOK, "configuration", "valid (profiles: " + profiles + ")"}}
+
+	if !*offline {
+		checks = append(checks, checkProviders(ctx, cfg)...)
+	}
+	if report(e.stdout, checks) {
+		return fmt.Errorf("configuration checks failed")
+	}
+	return nil
+}
+
+// checkProviders checks that each configured provider answers, going
+// through the egress policy like the server
+func checkProviders(ctx context.Context, cfg *config.Config) []check {
+	policy, err := egress.New(cfg.Egress)
+	if err != nil {
+		return []check{{checkFail, "egress", err.Error()}}
+	}
+
+	names := make([]string, 0, len(cfg.LLM.Providers))
+	for name := range cfg.LLM.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var checks []check
+	for _, name := range names {
+		if name != "ollama" {
+			checks = append(checks, check{checkWarn, "provider " + name, "not supported by this server, ignored"})
+			continue
+		}
+		baseURL := cfg.LLM.Providers[name].BaseURL
+		provider := ollama.NewProvider(baseURL)
+		provider.SetTransport(policy.Transport(""))
+
+		checkCtx, cancel := context.WithTimeout(ctx, providerTimeout)
+		models, err := provider.Models(checkCtx)
+		cancel()
+		if err != nil {
+			checks = append(checks, check{checkFail, "provider " + name, err.Error()})
+			continue
+		}
+		checks = append(checks, check{checkOK, "provider " + name, fmt.Sprintf("%s answers with %d models", baseURL, len(models))})
+	}
+	if cfg.LLM.Mock.Enabled {
+		checks = append(checks, check{checkWarn, "provider mock", "enabled; agents using it do not call a real model"})
+	}
+	if len(checks) == 0 {
+		checks = append(checks, check{checkWarn, "providers", "none configured"})
+	}
+	return checks
+}
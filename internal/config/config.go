@@ -1,9 +1,15 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
+	"os"
+	"regexp"
 	"strings"
 
+	"agent-server/internal/postprocess"
+	"agent-server/internal/secrets"
+
 	"github.com/spf13/viper"
 )
 
@@ -14,23 +20,151 @@ type Config struct {
 	LLM      LLMConfig             `mapstructure:"llm"`
 	Logging  LoggingConfig         `mapstructure:"logging"`
 	Context  ContextConfig         `mapstructure:"context"`
+	Storage  StorageConfig         `mapstructure:"storage"`
+	Jobs     JobsConfig            `mapstructure:"jobs"`
+	Tools    ToolsConfig           `mapstructure:"tools"`
+	Egress   EgressConfig          `mapstructure:"egress"`
+	Redaction RedactionConfig      `mapstructure:"redaction"`
+	Pricing  PricingConfig         `mapstructure:"pricing"`
+	Channels ChannelsConfig        `mapstructure:"channels"`
+	Moderation ModerationConfig    `mapstructure:"moderation"`
+	PII        PIIConfig           `mapstructure:"pii"`
+	Sharing    SharingConfig       `mapstructure:"sharing"`
+	Redis       RedisConfig        `mapstructure:"redis"`
+	Distributed DistributedConfig  `mapstructure:"distributed"`
+	Events      EventsConfig       `mapstructure:"events"`
+	Alerts      AlertsConfig       `mapstructure:"alerts"`
+	Features    FeaturesConfig     `mapstructure:"features"`
 }
 
 // ServerConfig holds server-related configuration
 type ServerConfig struct {
-	Host string `mapstructure:"host"`
-	Port int    `mapstructure:"port"`
+	Host            string     `mapstructure:"host"`
+	Port            int        `mapstructure:"port"`
+	TLS             TLSConfig  `mapstructure:"tls"`
+	CORS            CORSConfig `mapstructure:"cors"`
+	StreamKeepAlive int        `mapstructure:"stream_keepalive"` // seconds between keep-alive comments on idle SSE streams, 0 disables
+}
+
+// TLSConfig holds HTTPS and client certificate configuration
+type TLSConfig struct {
+	Enabled        bool       `mapstructure:"enabled"`
+	CertFile       string     `mapstructure:"cert_file"`
+	KeyFile        string     `mapstructure:"key_file"`
+	ClientCAFile   string     `mapstructure:"client_ca_file"`
+	ClientAuth     string     `mapstructure:"client_auth"`     // none, optional or require
+	MinVersion     string     `mapstructure:"min_version"`     // 1.2 or 1.3
+	ReloadInterval int        `mapstructure:"reload_interval"` // seconds between checks for rotated files
+	HSTS           HSTSConfig `mapstructure:"hsts"`
+}
+
+// HSTSConfig controls the Strict-Transport-Security header sent over HTTPS
+type HSTSConfig struct {
+	MaxAge            int  `mapstructure:"max_age"` // seconds, 0 disables the header
+	IncludeSubdomains bool `mapstructure:"include_subdomains"`
+	Preload           bool `mapstructure:"preload"`
+}
+
+// CORSConfig holds the cross-origin resource sharing policy
+type CORSConfig struct {
+	AllowedOrigins   []string `mapstructure:"allowed_origins"` // "*" or patterns like https://*.example.com
+	AllowedMethods   []string `mapstructure:"allowed_methods"`
+	AllowedHeaders   []string `mapstructure:"allowed_headers"` // empty allows any requested header
+	ExposedHeaders   []string `mapstructure:"exposed_headers"`
+	AllowCredentials bool     `mapstructure:"allow_credentials"`
+	MaxAge           int      `mapstructure:"max_age"` // seconds browsers may cache preflight results
 }
 
 // DatabaseConfig holds database configuration
 type DatabaseConfig struct {
-	Type string `mapstructure:"type"`
-	Path string `mapstructure:"path"`
+	Type            string `mapstructure:"type"`
+	Path            string `mapstructure:"path"`
+	JournalMode     string `mapstructure:"journal_mode"` // WAL, DELETE, TRUNCATE, PERSIST, MEMORY, OFF
+	BusyTimeout     int    `mapstructure:"busy_timeout"` // milliseconds
+	Synchronous     string `mapstructure:"synchronous"`  // OFF, NORMAL, FULL, EXTRA
+	MaxOpenConns    int    `mapstructure:"max_open_conns"`
+	MaxIdleConns    int    `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime int    `mapstructure:"conn_max_lifetime"` // seconds
+	SerializeWrites bool   `mapstructure:"serialize_writes"`
+
+	Encryption DatabaseEncryptionConfig `mapstructure:"encryption"`
+}
+
+// DatabaseEncryptionConfig enables AES-256-GCM encryption of message
+// contents, tool arguments and results, memories and archives at rest. Keys
+// are base64-encoded 32-byte values, typically secret references.
+type DatabaseEncryptionConfig struct {
+	Key          string   `mapstructure:"key"`           // empty stores data in plain text
+	PreviousKeys []string `mapstructure:"previous_keys"` // retired keys that still decrypt older values
+}
+
+// Keys decodes the encryption keys, the current one first. It returns nil
+// when encryption is disabled.
+func (e DatabaseEncryptionConfig) Keys() ([][]byte, error) {
+	if e.Key == "" {
+		if len(e.PreviousKeys) > 0 {
+			return nil, fmt.Errorf("database encryption previous_keys require a key")
+		}
+		return nil, nil
+	}
+	var keys [][]byte
+	for i, encoded := range append([]string{e.Key}, e.PreviousKeys...) {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("database encryption key %d is not valid base64", i+1)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("database encryption key %d must be 32 bytes, got %d", i+1, len(key))
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
 }
 
 // LLMConfig holds LLM provider configurations
 type LLMConfig struct {
 	Providers map[string]ProviderConfig `mapstructure:"providers"`
+	Mock      MockProviderConfig        `mapstructure:"mock"`
+	HTTP      ProviderHTTPConfig        `mapstructure:"http"`
+	// MaxResponseLength cuts off replies longer than this many characters,
+	// ending streams early; 0 disables the limit
+	MaxResponseLength int `mapstructure:"max_response_length"`
+	// PostProcess lists the post-processing steps applied to replies, in
+	// order: strip_artifacts, normalize_markdown and extract_code
+	PostProcess []string `mapstructure:"postprocess"`
+	// AutoPull starts downloading a model that is missing on its provider
+	// when a chat fails on it, for providers that can pull models (Ollama)
+	AutoPull bool `mapstructure:"auto_pull"`
+	// ContextWindows sets the context window in tokens per "provider/model"
+	// or model name, for models whose provider cannot report it
+	ContextWindows map[string]int `mapstructure:"context_windows"`
+	// Concurrency bounds the replies an agent generates at once
+	Concurrency ConcurrencyConfig `mapstructure:"concurrency"`
+}
+
+// ConcurrencyConfig limits concurrent generations per agent, so that one
+// busy agent cannot take all of a provider's capacity. Chats beyond an
+// agent's limit wait in a queue of up to max_queue chats for at most
+// queue_timeout seconds before they are turned away.
+type ConcurrencyConfig struct {
+	// DefaultLimit applies to agents without max_concurrency; 0 means
+	// unlimited
+	DefaultLimit int `mapstructure:"default_limit"`
+	QueueTimeout int `mapstructure:"queue_timeout"` // seconds
+	MaxQueue     int `mapstructure:"max_queue"`     // waiting chats per agent
+}
+
+// ProviderHTTPConfig tunes the HTTP clients of LLM providers. Streaming
+// requests have no overall timeout; request_timeout only bounds
+// non-streaming ones.
+type ProviderHTTPConfig struct {
+	DialTimeout           int `mapstructure:"dial_timeout"`            // seconds
+	KeepAlive             int `mapstructure:"keep_alive"`              // seconds between TCP keep-alive probes
+	TLSHandshakeTimeout   int `mapstructure:"tls_handshake_timeout"`   // seconds
+	ResponseHeaderTimeout int `mapstructure:"response_header_timeout"` // seconds until the provider starts answering
+	IdleConnTimeout       int `mapstructure:"idle_conn_timeout"`       // seconds a pooled connection stays open
+	MaxIdleConnsPerHost   int `mapstructure:"max_idle_conns_per_host"`
+	RequestTimeout        int `mapstructure:"request_timeout"` // seconds
 }
 
 // ProviderConfig holds configuration for a specific LLM provider
@@ -39,6 +173,19 @@ type ProviderConfig struct {
 	BaseURL string `mapstructure:"base_url"`
 }
 
+// MockProviderConfig configures the "mock" provider, which answers without a
+// model for load tests and development. Its replies take latency plus up to
+// jitter milliseconds; streamed replies pause stream_delay milliseconds
+// between words.
+type MockProviderConfig struct {
+	Enabled     bool    `mapstructure:"enabled"`
+	Latency     int     `mapstructure:"latency"`      // milliseconds
+	Jitter      int     `mapstructure:"jitter"`       // milliseconds
+	StreamDelay int     `mapstructure:"stream_delay"` // milliseconds between streamed words
+	ReplyWords  int     `mapstructure:"reply_words"`
+	ErrorRate   float64 `mapstructure:"error_rate"` // share of requests that fail, 0 to 1
+}
+
 // LoggingConfig holds logging configuration
 type LoggingConfig struct {
 	Level  string `mapstructure:"level"`
@@ -48,63 +195,810 @@ type LoggingConfig struct {
 // ContextConfig holds context strategy configurations
 type ContextConfig struct {
 	Strategies map[string]map[string]interface{} `mapstructure:"strategies"`
+	Trace      bool                              `mapstructure:"trace"` // store which messages each turn's context included and why
+}
+
+// StorageConfig holds object storage configuration
+type StorageConfig struct {
+	Blob    BlobConfig    `mapstructure:"blob"`
+	Archive ArchiveConfig `mapstructure:"archive"`
+}
+
+// ArchiveConfig holds session archival configuration
+type ArchiveConfig struct {
+	IdleDays      int `mapstructure:"idle_days"`      // default inactivity before bulk archival
+	BlobThreshold int `mapstructure:"blob_threshold"` // compressed bytes above which archives go to blob storage
+}
+
+// BlobConfig selects and configures the blob storage backend used for media,
+// exports and large tool outputs
+type BlobConfig struct {
+	Backend             string          `mapstructure:"backend"`               // local or s3
+	SignedURLExpiry     int             `mapstructure:"signed_url_expiry"`     // seconds
+	ToolOutputThreshold int             `mapstructure:"tool_output_threshold"` // bytes, 0 disables offloading
+	Local               LocalBlobConfig `mapstructure:"local"`
+	S3                  S3BlobConfig    `mapstructure:"s3"`
+}
+
+// LocalBlobConfig holds filesystem blob storage configuration
+type LocalBlobConfig struct {
+	Path       string `mapstructure:"path"`
+	BaseURL    string `mapstructure:"base_url"`
+	SigningKey string `mapstructure:"signing_key"`
+}
+
+// S3BlobConfig holds S3-compatible blob storage configuration
+type S3BlobConfig struct {
+	Endpoint     string `mapstructure:"endpoint"`
+	Region       string `mapstructure:"region"`
+	Bucket       string `mapstructure:"bucket"`
+	AccessKey    string `mapstructure:"access_key"`
+	SecretKey    string `mapstructure:"secret_key"`
+	UsePathStyle bool   `mapstructure:"use_path_style"`
+	Prefix       string `mapstructure:"prefix"`
+}
+
+// JobsConfig holds background job runner configuration
+type JobsConfig struct {
+	Enabled      bool `mapstructure:"enabled"`
+	Workers      int  `mapstructure:"workers"`
+	PollInterval int  `mapstructure:"poll_interval"` // milliseconds
+	BackoffBase  int  `mapstructure:"backoff_base"`  // seconds
+	BackoffMax   int  `mapstructure:"backoff_max"`   // seconds
+	JobTimeout   int  `mapstructure:"job_timeout"`   // seconds
+
+	// Instances sharing the database elect one leader through Redis to
+	// release the jobs of lost workers
+	SweepInterval  int    `mapstructure:"sweep_interval"`  // seconds between releases of lost jobs
+	LeaderElection bool   `mapstructure:"leader_election"` // requires redis.address
+	LeaderKey      string `mapstructure:"leader_key"`      // Redis key of the leader lease
+}
+
+// ToolsConfig holds tool execution configuration
+type ToolsConfig struct {
+	Audit    ToolAuditConfig    `mapstructure:"audit"`
+	Timeouts ToolTimeoutsConfig `mapstructure:"timeouts"`
+
+	// Retries sets the retry policy per tool name
+	Retries map[string]ToolRetryConfig `mapstructure:"retries"`
+
+	// StrictOutput fails tool results that do not match the tool's output
+	// schema instead of only flagging them
+	StrictOutput bool `mapstructure:"strict_output"`
+
+	Async ToolAsyncConfig `mapstructure:"async"`
+
+	Approval ToolApprovalConfig `mapstructure:"approval"`
+
+	Injection ToolInjectionConfig `mapstructure:"injection"`
+
+	HTTP ToolHTTPConfig `mapstructure:"http"`
+
+	// Disabled tools are turned off across the deployment, whatever the
+	// agents' settings. The admin API can disable and enable tools at
+	// runtime; those states are reloaded every features.refresh_interval.
+	Disabled []string `mapstructure:"disabled"`
+
+	// Credentials are named secrets that agent tool presets inject into tool
+	// input as credential://<name>, so they never pass through the LLM.
+	// Values may be secret references.
+	Credentials map[string]string `mapstructure:"credentials"`
+
+	// OAuth2 are named OAuth2 clients whose access tokens tool presets
+	// inject as credential://<name>. Tokens are fetched when first needed
+	// and replaced before they expire.
+	OAuth2 map[string]OAuth2CredentialConfig `mapstructure:"oauth2"`
+
+	Quotas ToolQuotasConfig `mapstructure:"quotas"`
+
+	// Email configures the SMTP server of the send_email tool, which also
+	// sends the replies of the email channel
+	Email EmailToolConfig `mapstructure:"email"`
+
+	// Sandbox configures the run_code tool, which runs code in short-lived
+	// containers
+	Sandbox ToolSandboxConfig `mapstructure:"sandbox"`
+}
+
+// OAuth2CredentialConfig configures an OAuth2 client of the tools. With a
+// refresh token it uses the refresh-token grant, else client credentials.
+type OAuth2CredentialConfig struct {
+	TokenURL     string   `mapstructure:"token_url"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	Scopes       []string `mapstructure:"scopes"`
+	Audience     string   `mapstructure:"audience"`
+	RefreshToken string   `mapstructure:"refresh_token"`
+	// AuthStyle sends the client credentials as HTTP basic authentication
+	// ("header", the default) or as form fields ("body")
+	AuthStyle string `mapstructure:"auth_style"`
+}
+
+// EmailToolConfig configures outgoing email. The send_email tool is only
+// registered when a host is set.
+type EmailToolConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// TLS is "starttls", "tls" (implicit TLS, usually port 465) or "none"
+	TLS  string `mapstructure:"tls"`
+	From string `mapstructure:"from"`
+	// AllowedRecipients limits whom the tool may write to: addresses, or
+	// domains written as "@example.com". Empty allows any recipient.
+	AllowedRecipients []string `mapstructure:"allowed_recipients"`
+}
+
+// ToolSandboxConfig configures the containers the run_code tool runs code in
+type ToolSandboxConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Runtime is the container CLI, "docker" or "podman", or a path to one
+	Runtime string `mapstructure:"runtime"`
+	// Images maps the languages python, go and javascript to the images
+	// their code runs in; a language with an empty image is not offered
+	Images map[string]string `mapstructure:"images"`
+
+	CPUs      float64 `mapstructure:"cpus"`
+	Memory    int     `mapstructure:"memory"`     // megabytes, also the size of /tmp
+	PidsLimit int     `mapstructure:"pids_limit"` // processes per container
+	Timeout   int     `mapstructure:"timeout"`    // seconds per run
+	// Network gives containers network access; without it they have none
+	Network bool `mapstructure:"network"`
+
+	MaxOutput   int `mapstructure:"max_output"`    // bytes of stdout and of stderr returned
+	MaxFiles    int `mapstructure:"max_files"`     // generated files kept per run
+	MaxFileSize int `mapstructure:"max_file_size"` // bytes; larger generated files are skipped
+}
+
+// ToolQuotasConfig limits how many tool calls an agent may make; 0 leaves a
+// limit off
+type ToolQuotasConfig struct {
+	PerTurn     int `mapstructure:"per_turn"`      // calls per chat turn
+	PerSession  int `mapstructure:"per_session"`   // calls per session
+	PerAgentDay int `mapstructure:"per_agent_day"` // calls per agent per UTC day
+
+	// Tools caps the calls of individual tools, on top of the overall limits
+	Tools map[string]ToolQuotaConfig `mapstructure:"tools"`
+}
+
+// ToolQuotaConfig caps the calls of one tool
+type ToolQuotaConfig struct {
+	PerTurn     int `mapstructure:"per_turn"`
+	PerSession  int `mapstructure:"per_session"`
+	PerAgentDay int `mapstructure:"per_agent_day"`
+}
+
+// ToolAsyncConfig selects long-running tools whose calls run in the
+// background job runner; it requires jobs.enabled
+type ToolAsyncConfig struct {
+	Tools []string `mapstructure:"tools"`
+}
+
+// ToolApprovalConfig selects tools whose calls wait for a person's approval.
+// Of tools such as issue_tracker only the calls that change external state
+// wait.
+type ToolApprovalConfig struct {
+	Tools []string `mapstructure:"tools"`
+}
+
+// ToolHTTPConfig configures how the HTTP requests of tools follow redirects
+type ToolHTTPConfig struct {
+	// MaxRedirects caps the redirects of a request; 0 uses the default of 10
+	MaxRedirects int `mapstructure:"max_redirects"`
+
+	// CrossOriginCredentials forwards request headers and bodies, with the
+	// credentials of tool presets, on redirects to another origin. Off, only
+	// the origin of the first request receives them.
+	CrossOriginCredentials bool `mapstructure:"cross_origin_credentials"`
+}
+
+// ToolInjectionConfig configures the defense against instructions planted
+// in tool results, such as scraped web pages or MCP responses
+type ToolInjectionConfig struct {
+	// Sanitize strips markup that carries instructions from results: HTML
+	// comments and scripts, chat template tokens, role tags and invisible
+	// characters
+	Sanitize bool `mapstructure:"sanitize"`
+
+	// Frame quotes results between markers the LLM is told to treat as
+	// data
+	Frame bool `mapstructure:"frame"`
+
+	Classifier ToolInjectionClassifierConfig `mapstructure:"classifier"`
+}
+
+// ToolInjectionClassifierConfig configures the classifier that scores tool
+// results for injected instructions. Detections are recorded per tool call.
+type ToolInjectionClassifierConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+
+	// Patterns are regular expressions, matched ignoring case, that the
+	// classifier scores results by; empty uses built-in ones
+	Patterns []string `mapstructure:"patterns"`
+
+	// Threshold is the score, 0 to 1, at which a result counts as an
+	// injection; Action is what happens to it then, flag or block
+	Threshold float64 `mapstructure:"threshold"`
+	Action    string  `mapstructure:"action"`
+}
+
+// ToolRetryConfig retries transient failures of a tool
+type ToolRetryConfig struct {
+	MaxAttempts int      `mapstructure:"max_attempts"` // including the first attempt
+	Backoff     int      `mapstructure:"backoff"`      // milliseconds before the first retry, doubled per retry
+	MaxBackoff  int      `mapstructure:"max_backoff"`  // milliseconds
+	RetryOn     []string `mapstructure:"retry_on"`     // error codes; empty retries REQUEST_FAILED and TIMEOUT
+}
+
+// ToolTimeoutsConfig holds tool execution timeouts in seconds
+type ToolTimeoutsConfig struct {
+	Default int `mapstructure:"default"`
+	// Max bounds per-call and per-session overrides; 0 leaves them unbounded
+	Max int `mapstructure:"max"`
+	// Tools sets the default timeout per tool name
+	Tools map[string]int `mapstructure:"tools"`
+}
+
+// ToolAuditConfig controls what the tool execution log records
+type ToolAuditConfig struct {
+	// CaptureHTTP records sanitized details of the outbound requests made by
+	// HTTP tools (http_get, http_post, web_scraper, mcp_proxy, openmcp_proxy)
+	CaptureHTTP bool `mapstructure:"capture_http"`
+}
+
+// EgressConfig routes outbound tool, provider and webhook traffic through
+// HTTP proxies. When no proxy is configured the standard HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY environment variables apply.
+type EgressConfig struct {
+	HTTPProxy  string `mapstructure:"http_proxy"`
+	HTTPSProxy string `mapstructure:"https_proxy"`
+	NoProxy    string `mapstructure:"no_proxy"` // comma-separated hosts, domains and CIDRs
+
+	// ForceProxy sends every outbound request through this proxy, ignoring
+	// no_proxy, per-tool settings and the environment
+	ForceProxy string `mapstructure:"force_proxy"`
+
+	// Tools overrides the proxy settings per tool name
+	Tools map[string]ProxyConfig `mapstructure:"tools"`
+}
+
+// ProxyConfig holds proxy settings for one tool
+type ProxyConfig struct {
+	HTTPProxy  string `mapstructure:"http_proxy"`
+	HTTPSProxy string `mapstructure:"https_proxy"`
+	NoProxy    string `mapstructure:"no_proxy"`
+}
+
+// PricingConfig prices LLM usage and tool calls and sets spending budgets.
+// Amounts are in one currency of your choice, e.g. USD.
+type PricingConfig struct {
+	// Models prices tokens per "provider/model" or model name
+	Models map[string]ModelPriceConfig `mapstructure:"models"`
+
+	// Tools sets a per-call cost for tools that do not report their own
+	Tools map[string]float64 `mapstructure:"tools"`
+
+	Budgets BudgetsConfig `mapstructure:"budgets"`
+}
+
+// ModelPriceConfig holds token prices per million tokens
+type ModelPriceConfig struct {
+	Prompt     float64 `mapstructure:"prompt"`
+	Completion float64 `mapstructure:"completion"`
+}
+
+// BudgetsConfig caps spending on LLM tokens and paid tools; 0 is unlimited
+type BudgetsConfig struct {
+	PerSession  float64 `mapstructure:"per_session"`
+	PerAgentDay float64 `mapstructure:"per_agent_day"` // per UTC day
+}
+
+// ModerationConfig configures the content moderation of agents that enable
+// it. The OpenAI moderation endpoint is used when an API key is set; the
+// keyword classifier answers when it is not or when the endpoint fails.
+type ModerationConfig struct {
+	OpenAI ModerationOpenAIConfig `mapstructure:"openai"`
+
+	// Keywords lists phrases per category for the local classifier; empty
+	// uses built-in lists
+	Keywords map[string][]string `mapstructure:"keywords"`
+
+	// Flag and Block are the category scores, 0 to 1, at which a message is
+	// flagged or blocked; Categories overrides them per category
+	Flag       float64                              `mapstructure:"flag"`
+	Block      float64                              `mapstructure:"block"`
+	Categories map[string]ModerationThresholdConfig `mapstructure:"categories"`
+}
+
+// ModerationOpenAIConfig configures the OpenAI moderation endpoint
+type ModerationOpenAIConfig struct {
+	APIKey  string `mapstructure:"api_key"`
+	BaseURL string `mapstructure:"base_url"`
+	Model   string `mapstructure:"model"`
+	Timeout int    `mapstructure:"timeout"` // seconds
+}
+
+// ModerationThresholdConfig holds the thresholds of one category; 0 keeps
+// the overall threshold
+type ModerationThresholdConfig struct {
+	Flag  float64 `mapstructure:"flag"`
+	Block float64 `mapstructure:"block"`
+}
+
+// PIIConfig configures the detection of personal data that agents scrub
+// from what they store. Emails, phone numbers, payment card and social
+// security numbers are found by pattern; a named entity recognition
+// endpoint can add names, addresses and other entities.
+type PIIConfig struct {
+	NER PIINERConfig `mapstructure:"ner"`
+}
+
+// PIINERConfig configures the named entity recognition endpoint. It receives
+// {"text": ...} and answers {"entities": [{"type", "start", "end"}]} with
+// offsets counted in characters.
+type PIINERConfig struct {
+	URL     string `mapstructure:"url"`     // empty detects by pattern only
+	Timeout int    `mapstructure:"timeout"` // seconds; pattern matches are scrubbed when the endpoint fails
+}
+
+// SharingConfig configures the signed links that grant read-only access to
+// a session's transcript
+type SharingConfig struct {
+	SigningKey string `mapstructure:"signing_key"` // set to keep share links valid across restarts
+	BaseURL    string `mapstructure:"base_url"`    // prefix of the public transcript pages
+	Expiry     int    `mapstructure:"expiry"`      // seconds a link is valid by default
+	MaxExpiry  int    `mapstructure:"max_expiry"`  // seconds, upper bound of requested expiries
+}
+
+// RedisConfig holds the connection to the Redis server shared by all
+// instances
+type RedisConfig struct {
+	Address  string `mapstructure:"address"` // host:port, empty disables Redis
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+}
+
+// Distributed roles. API instances publish chat work to the queue, worker
+// instances generate the replies; an instance with both roles does both.
+const (
+	RoleAPI    = "api"
+	RoleWorker = "worker"
+	RoleAll    = "all"
+)
+
+// DistributedConfig spreads chat generation over worker instances fed by a
+// Redis stream. All instances must share the database.
+type DistributedConfig struct {
+	Role          string `mapstructure:"role"`           // empty runs chats in process; api, worker or all
+	Stream        string `mapstructure:"stream"`         // Redis stream of chat tasks
+	Group         string `mapstructure:"group"`          // consumer group of the workers
+	Consumer      string `mapstructure:"consumer"`       // name of this worker, defaults to the host name
+	Workers       int    `mapstructure:"workers"`        // chats a worker instance runs concurrently
+	AcceptTimeout int    `mapstructure:"accept_timeout"` // seconds a chat waits for a worker before failing
+	ResultTTL     int    `mapstructure:"result_ttl"`     // seconds results are kept for the API instance
+}
+
+// Dispatches reports whether this instance publishes chats to workers
+func (c DistributedConfig) Dispatches() bool {
+	return c.Role == RoleAPI || c.Role == RoleAll
+}
+
+// Works reports whether this instance runs chats from the queue
+func (c DistributedConfig) Works() bool {
+	return c.Role == RoleWorker || c.Role == RoleAll
+}
+
+// Event bus backends. The memory bus keeps events in process; the redis bus
+// shares them between instances.
+const (
+	EventsMemory = "memory"
+	EventsRedis  = "redis"
+)
+
+// EventsConfig selects the bus that carries events, such as WebSocket
+// presence, to the clients of other instances
+type EventsConfig struct {
+	Backend string `mapstructure:"backend"` // memory or redis
+	Prefix  string `mapstructure:"prefix"`  // prefix of the Redis channels
+}
+
+// Alert rule types
+const (
+	AlertErrorRate           = "error_rate"           // share of failed chats in the window
+	AlertProviderUnavailable = "provider_unavailable" // minutes a provider has been unavailable
+	AlertDailyTokens         = "daily_tokens"         // tokens used today (UTC)
+	AlertDailyCost           = "daily_cost"           // cost spent today (UTC)
+	AlertToolFailures        = "tool_failures"        // share of failed calls of a tool in the window
+)
+
+// AlertsConfig configures the monitor that checks alert rules in the
+// background and notifies when they fire and resolve
+type AlertsConfig struct {
+	Enabled  bool                 `mapstructure:"enabled"`
+	Interval int                  `mapstructure:"interval"` // seconds between checks
+	Webhooks []AlertWebhookConfig `mapstructure:"webhooks"` // delivered as webhook jobs
+	Email    []string             `mapstructure:"email"`    // recipients, sent through tools.email
+	Rules    []AlertRuleConfig    `mapstructure:"rules"`
+}
+
+// AlertWebhookConfig is an endpoint receiving alert notifications
+type AlertWebhookConfig struct {
+	URL    string `mapstructure:"url"`
+	Secret string `mapstructure:"secret"` // signs the body in X-Signature-256
+}
+
+// AlertRuleConfig is a condition that fires an alert when its value exceeds
+// the threshold
+type AlertRuleConfig struct {
+	Name      string  `mapstructure:"name"`
+	Type      string  `mapstructure:"type"`
+	Threshold float64 `mapstructure:"threshold"`  // rate between 0 and 1, minutes, tokens or cost
+	Window    int     `mapstructure:"window"`     // seconds of history for error_rate and tool_failures
+	MinEvents int     `mapstructure:"min_events"` // fewer chats or tool calls in the window never fire
+	Provider  string  `mapstructure:"provider"`   // provider_unavailable: one provider instead of those agents use
+	Tool      string  `mapstructure:"tool"`       // tool_failures: one tool instead of each
+	AgentID   string  `mapstructure:"agent_id"`   // daily_tokens and daily_cost: one agent instead of all
+}
+
+// FeaturesConfig holds the flags of experimental capabilities. Flags set
+// through the admin API override these.
+type FeaturesConfig struct {
+	RefreshInterval int                 `mapstructure:"refresh_interval"` // seconds between reloads of flags set at runtime
+	Flags           []FeatureFlagConfig `mapstructure:"flags"`
+}
+
+// FeatureFlagConfig turns a capability on for everyone, for some agents or
+// for a percentage of sessions
+type FeatureFlagConfig struct {
+	Name        string   `mapstructure:"name"`
+	Description string   `mapstructure:"description"`
+	Enabled     bool     `mapstructure:"enabled"` // on everywhere
+	Agents      []string `mapstructure:"agents"`  // agent IDs it is on for
+	Rollout     int      `mapstructure:"rollout"` // percent of sessions it is on for
+	Tools       []string `mapstructure:"tools"`   // tools only offered where it is on
+}
+
+// ChannelsConfig connects agents to external chat platforms
+type ChannelsConfig struct {
+	Discord DiscordConfig `mapstructure:"discord"`
+	Email   EmailConfig   `mapstructure:"email"`
+	Matrix  MatrixConfig  `mapstructure:"matrix"`
+}
+
+// DiscordConfig configures the Discord bot gateway
+type DiscordConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	BotToken string `mapstructure:"bot_token"`
+
+	// AgentID answers in channels without their own agent
+	AgentID string `mapstructure:"agent_id"`
+	// Tools is the tool allowlist of channels without their own; empty
+	// disables tools and streams replies token by token
+	Tools []string `mapstructure:"tools"`
+	// RequireMention only answers messages that mention the bot
+	RequireMention bool `mapstructure:"require_mention"`
+	// EditInterval is the minimum time between edits of a streamed reply in
+	// milliseconds; Discord rate-limits message edits
+	EditInterval int `mapstructure:"edit_interval"`
+
+	// Channels configures channels by ID; threads use their parent channel's
+	// settings. When empty, the bot answers in every channel it can read.
+	Channels map[string]DiscordChannelConfig `mapstructure:"channels"`
+}
+
+// DiscordChannelConfig overrides the agent and tools of one channel
+type DiscordChannelConfig struct {
+	AgentID string   `mapstructure:"agent_id"`
+	Tools   []string `mapstructure:"tools"`
+}
+
+// MatrixConfig configures the Matrix bot
+type MatrixConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Homeserver is the client-server API base URL, e.g.
+	// https://matrix.example.org
+	Homeserver  string `mapstructure:"homeserver"`
+	AccessToken string `mapstructure:"access_token"`
+
+	// AgentID answers in rooms without their own agent
+	AgentID string   `mapstructure:"agent_id"`
+	Tools   []string `mapstructure:"tools"`
+	// RequireMention only answers messages that mention the bot
+	RequireMention bool `mapstructure:"require_mention"`
+	// AutoJoin accepts room invitations; when rooms are configured, only
+	// invitations to those rooms
+	AutoJoin bool `mapstructure:"auto_join"`
+	// EditInterval is the minimum time between edits of a streamed reply in
+	// milliseconds
+	EditInterval int `mapstructure:"edit_interval"`
+
+	// Rooms configures rooms by ID; the bot joins them on start. When empty,
+	// the bot answers in every room it is in.
+	Rooms map[string]MatrixRoomConfig `mapstructure:"rooms"`
+}
+
+// MatrixRoomConfig overrides the agent and tools of one room
+type MatrixRoomConfig struct {
+	AgentID string   `mapstructure:"agent_id"`
+	Tools   []string `mapstructure:"tools"`
+}
+
+// EmailConfig configures the email channel, which turns emails delivered to
+// the inbound webhook into chat turns and answers them by email
+type EmailConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Address receives the emails and sends the replies, e.g.
+	// "Support Agent <agent@example.com>"
+	Address string `mapstructure:"address"`
+	// WebhookSecret authenticates the mail provider calling the webhook
+	WebhookSecret string `mapstructure:"webhook_secret"`
+
+	// AgentID answers emails to addresses without their own agent
+	AgentID string   `mapstructure:"agent_id"`
+	Tools   []string `mapstructure:"tools"`
+	// AllowedSenders limits who may write to the agent: addresses, or
+	// domains written as "@example.com". Empty allows any sender.
+	AllowedSenders []string `mapstructure:"allowed_senders"`
+
+	// Addresses configures recipient addresses, e.g. aliases of the mailbox,
+	// with their own agent and tools
+	Addresses map[string]EmailAddressConfig `mapstructure:"addresses"`
+}
+
+// EmailAddressConfig overrides the agent and tools of one recipient address
+type EmailAddressConfig struct {
+	AgentID string   `mapstructure:"agent_id"`
+	Tools   []string `mapstructure:"tools"`
+}
+
+// RedactionConfig controls the masking of credentials in logs and in stored
+// tool arguments, tool results and message metadata
+type RedactionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+
+	// KeyPatterns are regular expressions matched case-insensitively against
+	// field, header and parameter names; empty selects the built-in list
+	KeyPatterns []string `mapstructure:"key_patterns"`
+
+	// ValuePatterns are regular expressions for credentials inside string
+	// values, such as bearer tokens; empty selects the built-in list
+	ValuePatterns []string `mapstructure:"value_patterns"`
 }
 
 // Load loads configuration from file and environment variables
 func Load(configPath string) (*Config, error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	
+	return LoadWithOverrides(configPath, nil)
+}
+
+// Default returns the default configuration, without reading a config
+// file or the environment
+func Default() *Config {
+	v := viper.New()
+	setDefaults(v)
+
+	var config Config
+	if err := v.Unmarshal(&config); err != nil {
+		panic(fmt.Sprintf("invalid default configuration: %v", err))
+	}
+	return &config
+}
+
+// LoadWithOverrides loads configuration with the documented precedence:
+// overrides (command-line flags) > AGENT_SERVER_* environment variables >
+// profile overlays > config file > defaults. Profiles are taken from
+// AGENT_SERVER_PROFILE.
+func LoadWithOverrides(configPath string, overrides map[string]string) (*Config, error) {
+	return load(configPath, ParseProfiles(os.Getenv(ProfileEnv)), overrides)
+}
+
+// load reads the configuration with the overlays of profiles merged over
+// the config file
+func load(configPath string, profiles []string, overrides map[string]string) (*Config, error) {
+	v, err := newViper(configPath, profiles, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	if err := resolveSecrets(&config, secrets.NewResolver()); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+
+	return &config, nil
+}
+
+// newViper builds a viper instance holding every configuration layer
+func newViper(configPath string, profiles []string, overrides map[string]string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigName("config")
+	v.SetConfigType("yaml")
+
 	if configPath != "" {
-		viper.SetConfigFile(configPath)
+		v.SetConfigFile(configPath)
 	} else {
-		viper.AddConfigPath("./configs")
-		viper.AddConfigPath(".")
+		v.AddConfigPath("./configs")
+		v.AddConfigPath(".")
 	}
 
-	// Enable environment variable support
-	viper.AutomaticEnv()
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	// Unprefixed variables such as SERVER_PORT keep working for known keys
+	v.AutomaticEnv()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
 	// Set defaults
-	setDefaults()
+	setDefaults(v)
 
 	// Read the config file
-	if err := viper.ReadInConfig(); err != nil {
+	if err := v.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 			return nil, fmt.Errorf("failed to read config file: %w", err)
 		}
 	}
 
-	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	// Environment overlays such as config.prod.yaml refine the base file
+	if err := mergeProfiles(v, profiles); err != nil {
+		return nil, err
 	}
 
-	return &config, nil
+	// Explicit values beat the file; flags are applied last so they win
+	applyEnv(v, os.Environ())
+	for key, value := range overrides {
+		v.Set(key, value)
+	}
+
+	return v, nil
 }
 
 // setDefaults sets default configuration values
-func setDefaults() {
+func setDefaults(v *viper.Viper) {
 	// Server defaults
-	viper.SetDefault("server.host", "0.0.0.0")
-	viper.SetDefault("server.port", 8080)
+	v.SetDefault("server.host", "0.0.0.0")
+	v.SetDefault("server.port", 8080)
+	v.SetDefault("server.stream_keepalive", 15)
+	v.SetDefault("server.tls.client_auth", "none")
+	v.SetDefault("server.tls.min_version", "1.2")
+	v.SetDefault("server.tls.reload_interval", 60)
+	v.SetDefault("server.tls.hsts.max_age", 31536000)
+	v.SetDefault("server.cors.allowed_origins", []string{"*"})
+	v.SetDefault("server.cors.allowed_methods", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"})
+	v.SetDefault("server.cors.allowed_headers", []string{"Content-Type", "Authorization"})
+	v.SetDefault("server.cors.max_age", 600)
 
 	// Database defaults
-	viper.SetDefault("database.type", "sqlite")
-	viper.SetDefault("database.path", "./data/agents.db")
+	v.SetDefault("database.type", "sqlite")
+	v.SetDefault("database.path", "./data/agents.db")
+	v.SetDefault("database.journal_mode", "WAL")
+	v.SetDefault("database.busy_timeout", 5000)
+	v.SetDefault("database.synchronous", "NORMAL")
+	v.SetDefault("database.max_open_conns", 8)
+	v.SetDefault("database.max_idle_conns", 4)
+	v.SetDefault("database.conn_max_lifetime", 3600)
+	v.SetDefault("database.serialize_writes", true)
+
+	// Mock provider defaults
+	v.SetDefault("llm.mock.latency", 200)
+	v.SetDefault("llm.mock.jitter", 100)
+	v.SetDefault("llm.mock.stream_delay", 20)
+	v.SetDefault("llm.mock.reply_words", 40)
+
+	v.SetDefault("llm.max_response_length", 100000)
+	v.SetDefault("llm.postprocess", []string{postprocess.StepStripArtifacts})
+	v.SetDefault("llm.auto_pull", false)
+
+	// Provider HTTP client defaults
+	v.SetDefault("llm.http.dial_timeout", 10)
+	v.SetDefault("llm.http.keep_alive", 30)
+	v.SetDefault("llm.http.tls_handshake_timeout", 10)
+	v.SetDefault("llm.http.response_header_timeout", 120)
+	v.SetDefault("llm.http.idle_conn_timeout", 90)
+	v.SetDefault("llm.http.max_idle_conns_per_host", 16)
+	v.SetDefault("llm.http.request_timeout", 120)
+	v.SetDefault("llm.concurrency.default_limit", 0)
+	v.SetDefault("llm.concurrency.queue_timeout", 30)
+	v.SetDefault("llm.concurrency.max_queue", 50)
 
 	// Logging defaults
-	viper.SetDefault("logging.level", "info")
-	viper.SetDefault("logging.format", "json")
+	v.SetDefault("logging.level", "info")
+	v.SetDefault("logging.format", "json")
 
 	// Context strategy defaults
-	viper.SetDefault("context.strategies.last_n.default_count", 10)
-	viper.SetDefault("context.strategies.sliding_window.window_size", 5)
-	viper.SetDefault("context.strategies.sliding_window.overlap", 2)
-	viper.SetDefault("context.strategies.summarize.max_context_length", 20)
-	viper.SetDefault("context.strategies.summarize.keep_recent", 5)
-	viper.SetDefault("context.strategies.summarize.summary_model", "gpt-3.5-turbo")
+	v.SetDefault("context.strategies.last_n.default_count", 10)
+	v.SetDefault("context.strategies.sliding_window.window_size", 5)
+	v.SetDefault("context.strategies.sliding_window.overlap", 2)
+	v.SetDefault("context.strategies.summarize.max_context_length", 20)
+	v.SetDefault("context.strategies.summarize.keep_recent", 5)
+	v.SetDefault("context.strategies.summarize.summary_model", "gpt-3.5-turbo")
+	v.SetDefault("context.trace", true)
+
+	// Job runner defaults
+	v.SetDefault("jobs.enabled", true)
+	v.SetDefault("jobs.workers", 2)
+	v.SetDefault("jobs.poll_interval", 1000)
+	v.SetDefault("jobs.backoff_base", 5)
+	v.SetDefault("jobs.backoff_max", 600)
+	v.SetDefault("jobs.job_timeout", 300)
+	v.SetDefault("jobs.sweep_interval", 60)
+	v.SetDefault("jobs.leader_key", "agent-server:jobs:leader")
+
+	// Tool defaults
+	v.SetDefault("tools.audit.capture_http", false)
+	v.SetDefault("tools.timeouts.default", 60)
+	v.SetDefault("tools.timeouts.max", 300)
+	v.SetDefault("tools.strict_output", false)
+	v.SetDefault("tools.http.max_redirects", 10)
+	v.SetDefault("tools.http.cross_origin_credentials", false)
+	v.SetDefault("tools.injection.sanitize", true)
+	v.SetDefault("tools.injection.frame", false)
+	v.SetDefault("tools.injection.classifier.enabled", false)
+	v.SetDefault("tools.injection.classifier.threshold", 0.5)
+	v.SetDefault("tools.injection.classifier.action", "flag")
+	v.SetDefault("tools.email.port", 587)
+	v.SetDefault("tools.sandbox.enabled", false)
+	v.SetDefault("tools.sandbox.runtime", "docker")
+	v.SetDefault("tools.sandbox.images.python", "python:3.12-slim")
+	v.SetDefault("tools.sandbox.images.go", "golang:1.22-alpine")
+	v.SetDefault("tools.sandbox.images.javascript", "node:20-alpine")
+	v.SetDefault("tools.sandbox.cpus", 1.0)
+	v.SetDefault("tools.sandbox.memory", 512)
+	v.SetDefault("tools.sandbox.pids_limit", 128)
+	v.SetDefault("tools.sandbox.timeout", 30)
+	v.SetDefault("tools.sandbox.network", false)
+	v.SetDefault("tools.sandbox.max_output", 65536)
+	v.SetDefault("tools.sandbox.max_files", 10)
+	v.SetDefault("tools.sandbox.max_file_size", 10485760)
+	v.SetDefault("tools.email.tls", "starttls")
+
+	// Redaction defaults
+	v.SetDefault("redaction.enabled", true)
+
+	// Moderation defaults
+	v.SetDefault("moderation.openai.base_url", "https://api.openai.com/v1")
+	v.SetDefault("moderation.openai.model", "omni-moderation-latest")
+	v.SetDefault("moderation.openai.timeout", 10)
+	v.SetDefault("moderation.flag", 0.5)
+	v.SetDefault("moderation.block", 0.8)
+
+	// PII defaults
+	v.SetDefault("pii.ner.timeout", 5)
+
+	// Share link defaults
+	v.SetDefault("sharing.base_url", "/share")
+	v.SetDefault("sharing.expiry", 604800)
+	v.SetDefault("sharing.max_expiry", 2592000)
+
+	// Distributed mode defaults
+	v.SetDefault("distributed.stream", "agent-server:chats")
+	v.SetDefault("distributed.group", "workers")
+	v.SetDefault("distributed.workers", 4)
+	v.SetDefault("distributed.accept_timeout", 30)
+	v.SetDefault("distributed.result_ttl", 300)
+
+	// Event bus defaults
+	v.SetDefault("events.backend", EventsMemory)
+	v.SetDefault("events.prefix", "agent-server:events")
+
+	// Alert defaults
+	v.SetDefault("alerts.interval", 60)
+	v.SetDefault("features.refresh_interval", 30)
+
+	// Channel defaults
+	v.SetDefault("channels.discord.edit_interval", 1000)
+	v.SetDefault("channels.matrix.edit_interval", 1000)
+
+	// Blob storage defaults
+	v.SetDefault("storage.blob.backend", "local")
+	v.SetDefault("storage.blob.signed_url_expiry", 3600)
+	v.SetDefault("storage.blob.tool_output_threshold", 65536)
+	v.SetDefault("storage.blob.local.path", "./data/blobs")
+	v.SetDefault("storage.blob.local.base_url", "/api/v1/blobs")
+	v.SetDefault("storage.blob.s3.region", "us-east-1")
+
+	// Archive defaults
+	v.SetDefault("storage.archive.idle_days", 90)
+	v.SetDefault("storage.archive.blob_threshold", 1048576)
 }
 
 // GetAddress returns the server address
@@ -118,6 +1012,18 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid server port: %d", c.Server.Port)
 	}
 
+	if c.Server.StreamKeepAlive < 0 {
+		return fmt.Errorf("server stream_keepalive cannot be negative")
+	}
+
+	if err := c.Server.TLS.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Server.CORS.validate(); err != nil {
+		return err
+	}
+
 	if c.Database.Type != "sqlite" {
 		return fmt.Errorf("unsupported database type: %s", c.Database.Type)
 	}
@@ -126,5 +1032,380 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("database path cannot be empty")
 	}
 
+	switch strings.ToUpper(c.Database.JournalMode) {
+	case "", "WAL", "DELETE", "TRUNCATE", "PERSIST", "MEMORY", "OFF":
+	default:
+		return fmt.Errorf("invalid database journal_mode: %s", c.Database.JournalMode)
+	}
+
+	switch strings.ToUpper(c.Database.Synchronous) {
+	case "", "OFF", "NORMAL", "FULL", "EXTRA":
+	default:
+		return fmt.Errorf("invalid database synchronous mode: %s", c.Database.Synchronous)
+	}
+
+	if c.Database.BusyTimeout < 0 || c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 || c.Database.ConnMaxLifetime < 0 {
+		return fmt.Errorf("database timeouts and pool limits cannot be negative")
+	}
+
+	if c.LLM.Mock.ErrorRate < 0 || c.LLM.Mock.ErrorRate > 1 {
+		return fmt.Errorf("mock provider error_rate must be between 0 and 1")
+	}
+
+	httpCfg := c.LLM.HTTP
+	if httpCfg.DialTimeout < 0 || httpCfg.KeepAlive < 0 || httpCfg.TLSHandshakeTimeout < 0 || httpCfg.ResponseHeaderTimeout < 0 ||
+		httpCfg.IdleConnTimeout < 0 || httpCfg.MaxIdleConnsPerHost < 0 || httpCfg.RequestTimeout < 0 {
+		return fmt.Errorf("llm http timeouts and pool limits cannot be negative")
+	}
+
+	if c.LLM.MaxResponseLength < 0 {
+		return fmt.Errorf("llm max_response_length cannot be negative")
+	}
+	if _, err := postprocess.New(c.LLM.PostProcess); err != nil {
+		return fmt.Errorf("llm postprocess: %w", err)
+	}
+	for model, tokens := range c.LLM.ContextWindows {
+		if tokens <= 0 {
+			return fmt.Errorf("llm context window of %s must be positive", model)
+		}
+	}
+	concurrency := c.LLM.Concurrency
+	if concurrency.DefaultLimit < 0 || concurrency.QueueTimeout < 0 || concurrency.MaxQueue < 0 {
+		return fmt.Errorf("llm concurrency settings cannot be negative")
+	}
+
+	if c.Channels.Discord.Enabled {
+		if c.Channels.Discord.BotToken == "" {
+			return fmt.Errorf("discord channel requires a bot_token")
+		}
+		if c.Channels.Discord.AgentID == "" && len(c.Channels.Discord.Channels) == 0 {
+			return fmt.Errorf("discord channel requires an agent_id or configured channels")
+		}
+		for id, channel := range c.Channels.Discord.Channels {
+			if channel.AgentID == "" && c.Channels.Discord.AgentID == "" {
+				return fmt.Errorf("discord channel %s has no agent_id and there is no default", id)
+			}
+		}
+	}
+
+	if c.Channels.Matrix.Enabled {
+		if c.Channels.Matrix.Homeserver == "" || c.Channels.Matrix.AccessToken == "" {
+			return fmt.Errorf("matrix channel requires a homeserver and an access_token")
+		}
+		if c.Channels.Matrix.AgentID == "" && len(c.Channels.Matrix.Rooms) == 0 {
+			return fmt.Errorf("matrix channel requires an agent_id or configured rooms")
+		}
+		for id, room := range c.Channels.Matrix.Rooms {
+			if room.AgentID == "" && c.Channels.Matrix.AgentID == "" {
+				return fmt.Errorf("matrix room %s has no agent_id and there is no default", id)
+			}
+		}
+	}
+
+	switch c.Tools.Email.TLS {
+	case "", "starttls", "tls", "none":
+	default:
+		return fmt.Errorf("invalid email tls mode: %s", c.Tools.Email.TLS)
+	}
+
+	if c.Channels.Email.Enabled {
+		if c.Tools.Email.Host == "" {
+			return fmt.Errorf("email channel requires tools.email.host to send replies")
+		}
+		if c.Channels.Email.Address == "" {
+			return fmt.Errorf("email channel requires an address")
+		}
+		if c.Channels.Email.WebhookSecret == "" {
+			return fmt.Errorf("email channel requires a webhook_secret")
+		}
+		if c.Channels.Email.AgentID == "" && len(c.Channels.Email.Addresses) == 0 {
+			return fmt.Errorf("email channel requires an agent_id or configured addresses")
+		}
+		for address, cfg := range c.Channels.Email.Addresses {
+			if cfg.AgentID == "" && c.Channels.Email.AgentID == "" {
+				return fmt.Errorf("email address %s has no agent_id and there is no default", address)
+			}
+		}
+	}
+
+	switch c.Storage.Blob.Backend {
+	case "", "local":
+	case "s3":
+		if c.Storage.Blob.S3.Bucket == "" {
+			return fmt.Errorf("s3 blob storage requires a bucket")
+		}
+	default:
+		return fmt.Errorf("unsupported blob storage backend: %s", c.Storage.Blob.Backend)
+	}
+
+	if err := c.Moderation.validate(); err != nil {
+		return err
+	}
+
+	if _, err := c.Database.Encryption.Keys(); err != nil {
+		return err
+	}
+
+	if c.Sharing.Expiry <= 0 || c.Sharing.MaxExpiry < c.Sharing.Expiry {
+		return fmt.Errorf("sharing expiry must be positive and at most max_expiry")
+	}
+
+	switch c.Distributed.Role {
+	case "":
+	case RoleAPI, RoleWorker, RoleAll:
+		if c.Redis.Address == "" {
+			return fmt.Errorf("distributed role %s requires redis.address", c.Distributed.Role)
+		}
+		if c.Distributed.Workers <= 0 || c.Distributed.AcceptTimeout <= 0 || c.Distributed.ResultTTL <= 0 {
+			return fmt.Errorf("distributed workers, accept_timeout and result_ttl must be positive")
+		}
+	default:
+		return fmt.Errorf("invalid distributed role: %s", c.Distributed.Role)
+	}
+
+	if err := c.Alerts.validate(); err != nil {
+		return err
+	}
+	if c.Alerts.Enabled && len(c.Alerts.Email) > 0 && c.Tools.Email.Host == "" {
+		return fmt.Errorf("alert email requires tools.email.host")
+	}
+
+	if err := c.Features.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Tools.validateOAuth2(); err != nil {
+		return err
+	}
+
+	if err := c.Tools.Injection.validate(); err != nil {
+		return err
+	}
+
+	if c.Tools.HTTP.MaxRedirects < 0 {
+		return fmt.Errorf("tools http max_redirects cannot be negative")
+	}
+
+	if err := c.Tools.Sandbox.validate(); err != nil {
+		return err
+	}
+
+	if c.Jobs.LeaderElection && c.Redis.Address == "" {
+		return fmt.Errorf("jobs leader_election requires redis.address")
+	}
+
+	switch c.Events.Backend {
+	case EventsMemory:
+	case EventsRedis:
+		if c.Redis.Address == "" {
+			return fmt.Errorf("events backend redis requires redis.address")
+		}
+	default:
+		return fmt.Errorf("invalid events backend: %s", c.Events.Backend)
+	}
+
+	return nil
+}
+
+// validate checks the moderation thresholds
+func (m *ModerationConfig) validate() error {
+	check := func(name string, flag, block float64) error {
+		if flag < 0 || flag > 1 || block < 0 || block > 1 {
+			return fmt.Errorf("%s thresholds must be between 0 and 1", name)
+		}
+		if flag > 0 && block > 0 && flag > block {
+			return fmt.Errorf("%s flag threshold cannot exceed its block threshold", name)
+		}
+		return nil
+	}
+	if err := check("moderation", m.Flag, m.Block); err != nil {
+		return err
+	}
+	for category, thresholds := range m.Categories {
+		if err := check("moderation category "+category, thresholds.Flag, thresholds.Block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TLSConfig) validate() error {
+	if !t.Enabled {
+		return nil
+	}
+
+	if t.CertFile == "" || t.KeyFile == "" {
+		return fmt.Errorf("tls requires cert_file and key_file")
+	}
+
+	switch t.ClientAuth {
+	case "", "none":
+	case "optional", "require":
+		if t.ClientCAFile == "" {
+			return fmt.Errorf("tls client_auth %q requires client_ca_file", t.ClientAuth)
+		}
+	default:
+		return fmt.Errorf("invalid tls client_auth: %s", t.ClientAuth)
+	}
+
+	switch t.MinVersion {
+	case "", "1.2", "1.3":
+	default:
+		return fmt.Errorf("invalid tls min_version: %s", t.MinVersion)
+	}
+
+	if t.ReloadInterval < 0 || t.HSTS.MaxAge < 0 {
+		return fmt.Errorf("tls reload_interval and hsts max_age cannot be negative")
+	}
+
+	return nil
+}
+
+// validate rejects credentials for any origin, which would let every site
+// make requests with the user's cookies
+func (c *CORSConfig) validate() error {
+	if !c.AllowCredentials {
+		return nil
+	}
+	for _, origin := range c.AllowedOrigins {
+		if strings.TrimSpace(origin) == "*" {
+			return fmt.Errorf("cors allow_credentials requires listed allowed_origins, not \"*\"")
+		}
+	}
+	return nil
+}
+
+// validate checks the rules of an enabled monitor
+func (c *AlertsConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("alerts interval must be positive")
+	}
+	for _, webhook := range c.Webhooks {
+		if webhook.URL == "" {
+			return fmt.Errorf("alert webhooks require a url")
+		}
+	}
+	names := make(map[string]bool, len(c.Rules))
+	for _, rule := range c.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("alert rules require a name")
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate alert rule: %s", rule.Name)
+		}
+		names[rule.Name] = true
+
+		switch rule.Type {
+		case AlertErrorRate, AlertToolFailures:
+			if rule.Threshold < 0 || rule.Threshold > 1 {
+				return fmt.Errorf("alert rule %s: threshold must be a rate between 0 and 1", rule.Name)
+			}
+		case AlertProviderUnavailable, AlertDailyTokens, AlertDailyCost:
+			if rule.Threshold < 0 {
+				return fmt.Errorf("alert rule %s: threshold cannot be negative", rule.Name)
+			}
+		default:
+			return fmt.Errorf("alert rule %s: invalid type %q", rule.Name, rule.Type)
+		}
+		if rule.Window < 0 || rule.MinEvents < 0 {
+			return fmt.Errorf("alert rule %s: window and min_events cannot be negative", rule.Name)
+		}
+	}
+	return nil
+}
+
+// validate checks that flags are named once and roll out to a percentage
+func (c *FeaturesConfig) validate() error {
+	if c.RefreshInterval <= 0 {
+		return fmt.Errorf("features refresh_interval must be positive")
+	}
+	names := make(map[string]bool, len(c.Flags))
+	for _, flag := range c.Flags {
+		if flag.Name == "" {
+			return fmt.Errorf("feature flags require a name")
+		}
+		if names[flag.Name] {
+			return fmt.Errorf("duplicate feature flag: %s", flag.Name)
+		}
+		names[flag.Name] = true
+		if flag.Rollout < 0 || flag.Rollout > 100 {
+			return fmt.Errorf("feature flag %s: rollout must be between 0 and 100", flag.Name)
+		}
+	}
+	return nil
+}
+
+// validate checks the injection classifier's threshold, action and
+// patterns
+func (c *ToolInjectionConfig) validate() error {
+	classifier := c.Classifier
+	if !classifier.Enabled {
+		return nil
+	}
+	if classifier.Threshold <= 0 || classifier.Threshold > 1 {
+		return fmt.Errorf("tools injection classifier threshold must be above 0 and at most 1")
+	}
+	switch classifier.Action {
+	case "flag", "block":
+	default:
+		return fmt.Errorf("invalid tools injection classifier action: %s", classifier.Action)
+	}
+	for _, pattern := range classifier.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid tools injection classifier pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// validate checks the limits of sandbox containers and that their
+// languages are known
+func (c *ToolSandboxConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Runtime == "" {
+		return fmt.Errorf("tools sandbox requires a runtime")
+	}
+	if c.CPUs <= 0 || c.Memory <= 0 || c.PidsLimit <= 0 || c.Timeout <= 0 {
+		return fmt.Errorf("tools sandbox cpus, memory, pids_limit and timeout must be positive")
+	}
+	if c.MaxOutput < 0 || c.MaxFiles < 0 || c.MaxFileSize < 0 {
+		return fmt.Errorf("tools sandbox max_output, max_files and max_file_size cannot be negative")
+	}
+	offered := false
+	for language, image := range c.Images {
+		switch language {
+		case "python", "go", "javascript":
+		default:
+			return fmt.Errorf("unsupported tools sandbox language: %s", language)
+		}
+		offered = offered || image != ""
+	}
+	if !offered {
+		return fmt.Errorf("tools sandbox requires an image for at least one language")
+	}
+	return nil
+}
+
+// validateOAuth2 checks that OAuth2 clients can request tokens and do not
+// shadow static credentials
+func (c *ToolsConfig) validateOAuth2() error {
+	for name, client := range c.OAuth2 {
+		if _, ok := c.Credentials[name]; ok {
+			return fmt.Errorf("tools credential %s is configured both as a secret and as an OAuth2 client", name)
+		}
+		if client.TokenURL == "" || client.ClientID == "" {
+			return fmt.Errorf("tools oauth2 %s requires a token_url and a client_id", name)
+		}
+		switch client.AuthStyle {
+		case "", "header", "body":
+		default:
+			return fmt.Errorf("tools oauth2 %s: invalid auth_style %s", name, client.AuthStyle)
+		}
+	}
 	return nil
-}
\ No newline at end of file
+}
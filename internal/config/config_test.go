@@ -0,0 +1,19 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCORS(t *testing.T) {
+	cfg := Default()
+	cfg.Server.CORS.AllowedOrigins = []string{"https://app.example.com", "*"}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Server.CORS.AllowCredentials = true
+	assert.ErrorContains(t, cfg.Validate(), "allow_credentials")
+
+	cfg.Server.CORS.AllowedOrigins = []string{"https://app.example.com", "https://*.example.com"}
+	assert.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix prefixes every environment variable that overrides a setting.
+// AGENT_SERVER_DATABASE_PATH sets database.path; underscores inside a key may
+// be kept or dropped, so AGENT_SERVER_LLM_PROVIDERS_OLLAMA_BASE_URL and
+// AGENT_SERVER_LLM_PROVIDERS_OLLAMA_BASEURL both set
+// llm.providers.ollama.base_url.
+const EnvPrefix = "AGENT_SERVER_"
+
+// wildcard stands for a map key (such as a provider name) in a key pattern
+const wildcard = "*"
+
+// keyPatterns lists every setting of Config as a dotted path, with map keys
+// replaced by wildcards
+func keyPatterns() [][]string {
+	var patterns [][]string
+	var walk func(t reflect.Type, prefix []string)
+	walk = func(t reflect.Type, prefix []string) {
+		switch t.Kind() {
+		case reflect.Struct:
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				name := field.Tag.Get("mapstructure")
+				if name == "" || name == "-" {
+					continue
+				}
+				walk(field.Type, append(append([]string{}, prefix...), name))
+			}
+		case reflect.Map:
+			walk(t.Elem(), append(append([]string{}, prefix...), wildcard))
+		default:
+			patterns = append(patterns, prefix)
+		}
+	}
+	walk(reflect.TypeOf(Config{}), nil)
+	return patterns
+}
+
+// applyEnv sets every AGENT_SERVER_* variable in environ that names a known
+// setting. Unknown variables are ignored.
+func applyEnv(v *viper.Viper, environ []string) {
+	patterns := keyPatterns()
+	for _, entry := range environ {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		if key := envKey(strings.TrimPrefix(name, EnvPrefix), patterns); key != "" {
+			v.Set(key, value)
+		}
+	}
+}
+
+// envKey resolves the part of a variable name after the prefix to a dotted
+// setting key, or returns "" if it matches no setting
+func envKey(name string, patterns [][]string) string {
+	tokens := strings.Split(strings.ToLower(name), "_")
+	for _, pattern := range patterns {
+		if key := matchTokens(pattern, tokens); key != nil {
+			return strings.Join(key, ".")
+		}
+	}
+	return ""
+}
+
+// matchTokens matches underscore-separated tokens against a key pattern. A
+// fixed segment consumes the tokens that spell it with or without its own
+// underscores; a wildcard consumes one or more tokens as the map key.
+func matchTokens(pattern, tokens []string) []string {
+	if len(pattern) == 0 {
+		if len(tokens) == 0 {
+			return []string{}
+		}
+		return nil
+	}
+
+	segment := pattern[0]
+	for end := 1; end <= len(tokens); end++ {
+		var name string
+		if segment == wildcard {
+			name = strings.Join(tokens[:end], "_")
+		} else if strings.Join(tokens[:end], "") == strings.ReplaceAll(segment, "_", "") {
+			name = segment
+		} else {
+			continue
+		}
+		if rest := matchTokens(pattern[1:], tokens[end:]); rest != nil {
+			return append([]string{name}, rest...)
+		}
+	}
+	return nil
+}
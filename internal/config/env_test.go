@@ -0,0 +1,113 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvKey(t *testing.T) {
+	patterns := keyPatterns()
+	tests := map[string]string{
+		"DATABASE_PATH":                 "database.path",
+		"DATABASE_JOURNAL_MODE":         "database.journal_mode",
+		"DATABASE_JOURNALMODE":          "database.journal_mode",
+		"LLM_PROVIDERS_OLLAMA_BASEURL":  "llm.providers.ollama.base_url",
+		"LLM_PROVIDERS_OLLAMA_BASE_URL": "llm.providers.ollama.base_url",
+		"LLM_PROVIDERS_OPEN_AI_API_KEY": "llm.providers.open_ai.api_key",
+		"STORAGE_BLOB_S3_SECRET_KEY":    "storage.blob.s3.secret_key",
+		"URL":                           "",
+		"DATABASE":                      "",
+	}
+	for name, want := range tests {
+		assert.Equal(t, want, envKey(name, patterns), name)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+server:
+  port: 9000
+  host: 127.0.0.1
+database:
+  path: /from/file.db
+llm:
+  providers:
+    openai:
+      api_key: sk-file
+`), 0o600))
+
+	t.Setenv("AGENT_SERVER_SERVER_PORT", "9100")
+	t.Setenv("AGENT_SERVER_DATABASE_PATH", "/from/env.db")
+	t.Setenv("AGENT_SERVER_LLM_PROVIDERS_OLLAMA_BASEURL", "http://ollama:11434")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	require.NoError(t, fs.Parse([]string{"-config", path, "-port", "9200", "-set", "jobs.workers=7"}))
+
+	cfg, err := flags.Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, 9200, cfg.Server.Port, "flag beats env and file")
+	assert.Equal(t, "/from/env.db", cfg.Database.Path, "env beats file")
+	assert.Equal(t, "127.0.0.1", cfg.Server.Host, "file beats default")
+	assert.Equal(t, "WAL", cfg.Database.JournalMode, "default")
+	assert.Equal(t, 7, cfg.Jobs.Workers)
+	assert.Equal(t, "http://ollama:11434", cfg.LLM.Providers["ollama"].BaseURL)
+	assert.Equal(t, "sk-file", cfg.LLM.Providers["openai"].APIKey)
+
+	out, err := flags.PrintEffective()
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "sk-file")
+	assert.Contains(t, string(out), redactedValue)
+	assert.Contains(t, string(out), "/from/env.db")
+}
+
+func TestLoadResolvesSecrets(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "openai")
+	require.NoError(t, os.WriteFile(keyFile, []byte("sk-from-file\n"), 0o600))
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+llm:
+  providers:
+    openai:
+      api_key: file://`+keyFile+`
+    anthropic:
+      api_key: ${ANTHROPIC_TEST_KEY}
+tools:
+  credentials:
+    internal_api: sk-internal
+    crm: ${ANTHROPIC_TEST_KEY}
+database:
+  encryption:
+    key: ${DB_TEST_KEY}
+    previous_keys: [sk-old-key, "${DB_TEST_KEY}"]
+`), 0o600))
+	t.Setenv("ANTHROPIC_TEST_KEY", "sk-ant")
+	t.Setenv("DB_TEST_KEY", "sk-db")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	require.NoError(t, fs.Parse([]string{"-config", path}))
+
+	cfg, err := flags.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "sk-from-file", cfg.LLM.Providers["openai"].APIKey)
+	assert.Equal(t, "sk-ant", cfg.LLM.Providers["anthropic"].APIKey)
+	assert.Equal(t, map[string]string{"internal_api": "sk-internal", "crm": "sk-ant"}, cfg.Tools.Credentials)
+	assert.Equal(t, "sk-db", cfg.Database.Encryption.Key)
+	assert.Equal(t, []string{"sk-old-key", "sk-db"}, cfg.Database.Encryption.PreviousKeys)
+
+	out, err := flags.PrintEffective()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "file://"+keyFile)
+	assert.Contains(t, string(out), "${ANTHROPIC_TEST_KEY}")
+	assert.NotContains(t, string(out), "sk-")
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"agent-server/internal/secrets"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Flags holds the configuration file path and the settings given on the
+// command line, which take precedence over every other source
+type Flags struct {
+	Path      string
+	Profile   string // comma-separated profiles; AGENT_SERVER_PROFILE when empty
+	Demo      bool   // in-memory database, mock provider and sample data
+	overrides map[string]string
+}
+
+// demoSettings are the settings of demo mode, which need no database file
+// or model. Flags given alongside -demo take precedence.
+var demoSettings = map[string]string{
+	"database.path":    ":memory:",
+	"llm.mock.enabled": "true",
+}
+
+// RegisterFlags defines the configuration flags on fs
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{overrides: make(map[string]string)}
+	fs.StringVar(&f.Path, "config", "", "Path to configuration file")
+	fs.StringVar(&f.Profile, "profile", "", "Comma-separated profiles whose overlays, such as config.prod.yaml, refine the config file")
+	fs.BoolVar(&f.Demo, "demo", false, "Start with an in-memory database, the mock LLM provider and a sample agent and session")
+	fs.Func("host", "Server host (server.host)", f.setter("server.host"))
+	fs.Func("port", "Server port (server.port)", f.setter("server.port"))
+	fs.Func("db", "Database path (database.path)", f.setter("database.path"))
+	fs.Func("log-level", "Log level (logging.level)", f.setter("logging.level"))
+	fs.Func("set", "Override any setting as key=value, e.g. -set jobs.workers=4 (repeatable)", func(s string) error {
+		key, value, ok := strings.Cut(s, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("expected key=value, got %q", s)
+		}
+		f.overrides[strings.ToLower(strings.TrimSpace(key))] = value
+		return nil
+	})
+	return f
+}
+
+func (f *Flags) setter(key string) func(string) error {
+	return func(value string) error {
+		f.overrides[key] = value
+		return nil
+	}
+}
+
+// Profiles returns the selected profiles, from the flag or else from
+// AGENT_SERVER_PROFILE
+func (f *Flags) Profiles() []string {
+	if f.Profile != "" {
+		return ParseProfiles(f.Profile)
+	}
+	return ParseProfiles(os.Getenv(ProfileEnv))
+}
+
+// Load loads the configuration with the flags applied
+func (f *Flags) Load() (*Config, error) {
+	return load(f.Path, f.Profiles(), f.settings())
+}
+
+// settings returns the settings given on the command line, with those of
+// demo mode beneath them
+func (f *Flags) settings() map[string]string {
+	if !f.Demo {
+		return f.overrides
+	}
+	settings := make(map[string]string, len(demoSettings)+len(f.overrides))
+	for key, value := range demoSettings {
+		settings[key] = value
+	}
+	for key, value := range f.overrides {
+		settings[key] = value
+	}
+	return settings
+}
+
+// PrintEffective renders the merged configuration as YAML with secrets
+// redacted. Secret references are printed as written, unresolved.
+func (f *Flags) PrintEffective() ([]byte, error) {
+	v, err := newViper(f.Path, f.Profiles(), f.settings())
+	if err != nil {
+		return nil, err
+	}
+
+	settings := v.AllSettings()
+	redact(settings)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(settings); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// secretKeys are setting names whose values are never printed
+var secretKeys = map[string]bool{
+	"api_key":     true,
+	"access_key":  true,
+	"secret_key":  true,
+	"signing_key": true,
+	"password":    true,
+
+	// OAuth2 client secrets of the tools
+	"client_secret": true,
+	"refresh_token": true,
+
+	// database encryption keys
+	"key":           true,
+	"previous_keys": true,
+}
+
+// secretMaps are settings whose entries are all secrets, such as the named
+// tool credentials
+var secretMaps = map[string]bool{
+	"credentials": true,
+}
+
+// redactedValue replaces non-empty secrets in printed configuration
+const redactedValue = "[REDACTED]"
+
+// redact replaces secret values in nested settings in place
+func redact(settings map[string]interface{}) {
+	for key, value := range settings {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if secretMaps[key] {
+				for name, entry := range value {
+					if s, ok := entry.(string); ok && secrets.IsReference(s) {
+						continue
+					}
+					value[name] = redactedValue
+				}
+				continue
+			}
+			redact(value)
+		case []interface{}:
+			if secretKeys[key] {
+				for i, entry := range value {
+					if s, ok := entry.(string); !ok || !secrets.IsReference(s) {
+						value[i] = redactedValue
+					}
+				}
+			}
+		default:
+			// References are safe to show and tell where the value comes from
+			if s, ok := value.(string); ok && secrets.IsReference(s) {
+				continue
+			}
+			if secretKeys[key] && fmt.Sprint(value) != "" {
+				settings[key] = redactedValue
+			}
+		}
+	}
+}
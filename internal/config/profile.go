@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ProfileEnv selects profiles when no -profile flag is given, e.g.
+// AGENT_SERVER_PROFILE=prod
+const ProfileEnv = "AGENT_SERVER_PROFILE"
+
+// ParseProfiles splits a comma-separated list of profile names. Later
+// profiles override earlier ones.
+func ParseProfiles(list string) []string {
+	var profiles []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			profiles = append(profiles, name)
+		}
+	}
+	return profiles
+}
+
+// overlayPath names the overlay of a profile next to the base file, such as
+// configs/config.prod.yaml for configs/config.yaml
+func overlayPath(base, profile string) string {
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "." + profile + ext
+}
+
+// mergeProfiles merges the overlay file of each profile over the settings
+// read so far. A selected profile without an overlay file is an error.
+func mergeProfiles(v *viper.Viper, profiles []string) error {
+	for _, profile := range profiles {
+		if strings.ContainsAny(profile, `/\`) || strings.HasPrefix(profile, ".") {
+			return fmt.Errorf("invalid config profile %q", profile)
+		}
+
+		// Without a base file, overlays are looked up where it would be
+		candidates := []string{
+			overlayPath(filepath.Join("configs", "config.yaml"), profile),
+			overlayPath("config.yaml", profile),
+		}
+		if base := v.ConfigFileUsed(); base != "" {
+			candidates = []string{overlayPath(base, profile)}
+		}
+
+		path := ""
+		for _, candidate := range candidates {
+			if _, err := os.Stat(candidate); err == nil {
+				path = candidate
+				break
+			}
+		}
+		if path == "" {
+			return fmt.Errorf("config profile %q: %s not found", profile, candidates[0])
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("config profile %q: %w", profile, err)
+		}
+		err = v.MergeConfig(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("config profile %q: failed to read %s: %w", profile, path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProfiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+server:
+  port: 9000
+  host: 127.0.0.1
+jobs:
+  workers: 2
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.prod.yaml"), []byte(`
+server:
+  port: 80
+jobs:
+  workers: 8
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.eu.yaml"), []byte(`
+server:
+  host: 10.0.0.1
+`), 0o600))
+
+	// Later profiles refine earlier ones and keep the base file's other keys
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	require.NoError(t, fs.Parse([]string{"-config", path, "-profile", "prod, eu", "-set", "jobs.workers=3"}))
+	cfg, err := flags.Load()
+	require.NoError(t, err)
+	assert.Equal(t, 80, cfg.Server.Port)
+	assert.Equal(t, "10.0.0.1", cfg.Server.Host)
+	assert.Equal(t, 3, cfg.Jobs.Workers, "flags beat overlays")
+
+	// The environment selects profiles when the flag is not given
+	t.Setenv(ProfileEnv, "prod")
+	cfg, err = Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 80, cfg.Server.Port)
+	assert.Equal(t, "127.0.0.1", cfg.Server.Host)
+
+	t.Setenv(ProfileEnv, "staging")
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "config.staging.yaml not found")
+	t.Setenv(ProfileEnv, "../prod")
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "invalid config profile")
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"agent-server/internal/secrets"
+)
+
+// secretsTimeout bounds how long loading may wait on remote secret stores
+const secretsTimeout = 30 * time.Second
+
+// resolveSecrets replaces every secret reference in the configuration with
+// its value
+func resolveSecrets(c *Config, resolver *secrets.Resolver) error {
+	ctx, cancel := context.WithTimeout(context.Background(), secretsTimeout)
+	defer cancel()
+	return resolveValue(ctx, resolver, reflect.ValueOf(c).Elem(), "")
+}
+
+// resolveValue walks v and resolves string values in place. Map values are
+// not addressable, so they are copied, resolved and stored back.
+func resolveValue(ctx context.Context, resolver *secrets.Resolver, v reflect.Value, key string) error {
+	switch v.Kind() {
+	case reflect.String:
+		if !secrets.IsReference(v.String()) {
+			return nil
+		}
+		value, err := resolver.Resolve(ctx, v.String())
+		if err != nil {
+			// The error names the reference only, never a resolved value
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		v.SetString(value)
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := t.Field(i).Tag.Get("mapstructure")
+			if name == "" {
+				continue
+			}
+			if err := resolveValue(ctx, resolver, v.Field(i), joinKey(key, name)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := resolveValue(ctx, resolver, v.Index(i), fmt.Sprintf("%s[%d]", key, i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			value := iter.Value()
+			if value.Kind() == reflect.Interface {
+				// Unwrap free-form values such as strategy settings
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			elem := reflect.New(value.Type()).Elem()
+			elem.Set(value)
+			if err := resolveValue(ctx, resolver, elem, joinKey(key, iter.Key().String())); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	}
+	return nil
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
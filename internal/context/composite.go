@@ -0,0 +1,151 @@
+package context
+
+import (
+	"context"
+	"fmt"
+
+	"agent-server/internal/models"
+)
+
+// CompositeStrategy runs a session's history through an ordered pipeline of
+// strategies, such as summarize followed by last_n. Each stage gets the
+// messages the stage before kept, including the summaries it added, and has
+// its own config block:
+//
+//	{"stages": [
+//	  {"strategy": "summarize", "config": {"max_context_length": 40}},
+//	  {"strategy": "last_n", "config": {"count": 20}}
+//	]}
+type CompositeStrategy struct {
+	registry *StrategyRegistry
+}
+
+// NewCompositeStrategy creates a composite strategy whose stages are looked
+// up in registry, so strategies registered later can be chained too
+func NewCompositeStrategy(registry *StrategyRegistry) *CompositeStrategy {
+	return &CompositeStrategy{registry: registry}
+}
+
+func (s *CompositeStrategy) Name() string {
+	return "composite"
+}
+
+func (s *CompositeStrategy) DefaultConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"stages": []interface{}{
+			map[string]interface{}{"strategy": "summarize", "config": map[string]interface{}{}},
+			map[string]interface{}{"strategy": "last_n", "config": map[string]interface{}{}},
+		},
+	}
+}
+
+func (s *CompositeStrategy) BuildContext(ctx context.Context, systemPrompt, agentPrompt string, messages []*models.Message, config map[string]interface{}) ([]*models.Message, error) {
+	contextMessages, _, err := s.BuildContextTrace(ctx, systemPrompt, agentPrompt, messages, config)
+	return contextMessages, err
+}
+
+// compositeStage is one strategy of the pipeline with its config
+type compositeStage struct {
+	strategy ContextStrategy
+	config   map[string]interface{}
+}
+
+// BuildContextTrace runs the stages and records each history message with
+// the decision of the stage that left it out or summarized it, or of the
+// last stage when it made it through
+func (s *CompositeStrategy) BuildContextTrace(ctx context.Context, systemPrompt, agentPrompt string, messages []*models.Message, config map[string]interface{}) ([]*models.Message, *models.ContextTrace, error) {
+	stages, err := s.stages(config)
+	if err != nil {
+		return []*models.Message{}, nil, err
+	}
+
+	decisions := make(map[*models.Message]models.ContextDecision, len(messages))
+	settings := make([]interface{}, 0, len(stages))
+	input := messages
+	var contextMessages []*models.Message
+	for i, stage := range stages {
+		out, trace, err := BuildContextTrace(ctx, stage.strategy, systemPrompt, agentPrompt, input, stage.config)
+		if err != nil {
+			return []*models.Message{}, nil, fmt.Errorf("stage %d (%s): %w", i+1, stage.strategy.Name(), err)
+		}
+		settings = append(settings, map[string]interface{}{"strategy": trace.Strategy, "settings": trace.Settings})
+
+		// Strategies record their decisions in the order of the messages
+		last := i == len(stages)-1
+		for j, decision := range trace.Decisions {
+			if j >= len(input) {
+				break
+			}
+			if _, decided := decisions[input[j]]; decided || (decision.Decision == models.ContextIncluded && !last) {
+				continue
+			}
+			decision.Reason = fmt.Sprintf("stage %d (%s): %s", i+1, stage.strategy.Name(), decision.Reason)
+			decisions[input[j]] = decision
+		}
+
+		if last {
+			contextMessages = out
+			break
+		}
+		// Only the last stage's system message goes to the LLM
+		if len(out) > 0 && out[0].Role == "system" && out[0].ID == "" {
+			out = out[1:]
+		}
+		input = out
+	}
+
+	trace := models.NewContextTrace(s.Name(), models.JSON{"stages": settings})
+	for _, message := range messages {
+		decision, ok := decisions[message]
+		if !ok {
+			decision = models.ContextDecision{Decision: models.ContextExcluded, Reason: "left out by the pipeline"}
+		}
+		trace.Record(message, decision.Decision, decision.Reason)
+	}
+	return contextMessages, trace, nil
+}
+
+// stages reads the pipeline from the config
+func (s *CompositeStrategy) stages(config map[string]interface{}) ([]compositeStage, error) {
+	var blocks []map[string]interface{}
+	switch raw := config["stages"].(type) {
+	case []map[string]interface{}:
+		blocks = raw
+	case []interface{}:
+		for i, item := range raw {
+			block, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("stage %d must be an object", i+1)
+			}
+			blocks = append(blocks, block)
+		}
+	case nil:
+	default:
+		return nil, fmt.Errorf("stages must be a list")
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("composite strategy needs at least one stage")
+	}
+
+	stages := make([]compositeStage, 0, len(blocks))
+	for i, block := range blocks {
+		name, _ := block["strategy"].(string)
+		if name == s.Name() {
+			return nil, fmt.Errorf("stage %d: composite strategies cannot be nested", i+1)
+		}
+		strategy, exists := s.registry.Get(name)
+		if !exists {
+			return nil, fmt.Errorf("stage %d: unknown context strategy %q", i+1, name)
+		}
+		stageConfig := map[string]interface{}{}
+		switch raw := block["config"].(type) {
+		case map[string]interface{}:
+			stageConfig = raw
+		case nil:
+		default:
+			return nil, fmt.Errorf("stage %d: config must be an object", i+1)
+		}
+		stages = append(stages, compositeStage{strategy: strategy, config: stageConfig})
+	}
+	return stages, nil
+}
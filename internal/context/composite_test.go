@@ -0,0 +1,72 @@
+package context
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"agent-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompositeStrategy_BuildContextTrace(t *testing.T) {
+	registry := NewStrategyRegistry()
+	strategy, ok := registry.Get("composite")
+	require.True(t, ok)
+
+	var messages []*models.Message
+	for i := 1; i <= 6; i++ {
+		messages = append(messages, &models.Message{ID: fmt.Sprintf("m%d", i), Role: "user", Content: fmt.Sprintf("Message %d", i)})
+	}
+	config := map[string]interface{}{
+		"stages": []interface{}{
+			map[string]interface{}{"strategy": "summarize", "config": map[string]interface{}{"max_context_length": 4, "keep_recent": 3}},
+			map[string]interface{}{"strategy": "last_n", "config": map[string]interface{}{"count": 2}},
+		},
+	}
+
+	contextMessages, trace, err := BuildContextTrace(context.Background(), strategy, "You are helpful", "", messages, config)
+	require.NoError(t, err)
+
+	// One system message, then the last two of the summary and recent messages
+	require.Len(t, contextMessages, 3)
+	assert.Equal(t, "You are helpful", contextMessages[0].Content)
+	assert.Equal(t, []*models.Message{messages[4], messages[5]}, contextMessages[1:])
+
+	assert.Equal(t, "composite", trace.Strategy)
+	assert.Equal(t, 6, trace.Messages)
+	assert.Equal(t, 3, trace.Summarized)
+	assert.Equal(t, 1, trace.Excluded)
+	assert.Equal(t, 2, trace.Included)
+	assert.Equal(t, models.ContextSummarized, trace.Decisions[0].Decision)
+	assert.Contains(t, trace.Decisions[0].Reason, "stage 1 (summarize): ")
+	assert.Equal(t, models.ContextExcluded, trace.Decisions[3].Decision)
+	assert.Equal(t, "stage 2 (last_n): older than the last 2 messages", trace.Decisions[3].Reason)
+	assert.Equal(t, "stage 2 (last_n): within the last 2 messages", trace.Decisions[5].Reason)
+}
+
+func TestCompositeStrategy_InvalidStages(t *testing.T) {
+	strategy := NewCompositeStrategy(NewStrategyRegistry())
+	messages := []*models.Message{{Role: "user", Content: "Message 1"}}
+
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		err    string
+	}{
+		{"no stages", map[string]interface{}{}, "at least one stage"},
+		{"unknown strategy", map[string]interface{}{"stages": []interface{}{map[string]interface{}{"strategy": "pinned"}}}, `unknown context strategy "pinned"`},
+		{"nested", map[string]interface{}{"stages": []interface{}{map[string]interface{}{"strategy": "composite"}}}, "cannot be nested"},
+		{"stage error", map[string]interface{}{"stages": []interface{}{map[string]interface{}{"strategy": "last_n", "config": map[string]interface{}{"count": 0}}}}, "stage 1 (last_n): count must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := strategy.BuildContext(context.Background(), "", "", messages, tt.config)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
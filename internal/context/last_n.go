@@ -20,6 +20,13 @@ func (s *LastNStrategy) DefaultConfig() map[string]interface{} {
 }
 
 func (s *LastNStrategy) BuildContext(ctx context.Context, systemPrompt, agentPrompt string, messages []*models.Message, config map[string]interface{}) ([]*models.Message, error) {
+	contextMessages, _, err := s.BuildContextTrace(ctx, systemPrompt, agentPrompt, messages, config)
+	return contextMessages, err
+}
+
+// BuildContextTrace builds the context and records which messages are older
+// than the last N
+func (s *LastNStrategy) BuildContextTrace(ctx context.Context, systemPrompt, agentPrompt string, messages []*models.Message, config map[string]interface{}) ([]*models.Message, *models.ContextTrace, error) {
 	// Get count from config
 	count := 10
 	if c, ok := config["count"]; ok {
@@ -31,7 +38,7 @@ func (s *LastNStrategy) BuildContext(ctx context.Context, systemPrompt, agentPro
 	}
 
 	if count <= 0 {
-		return []*models.Message{}, fmt.Errorf("count must be positive")
+		return []*models.Message{}, nil, fmt.Errorf("count must be positive")
 	}
 
 	// Create system message
@@ -51,7 +58,16 @@ func (s *LastNStrategy) BuildContext(ctx context.Context, systemPrompt, agentPro
 	// Append the last N messages
 	contextMessages = append(contextMessages, messages[startIndex:]...)
 
-	return contextMessages, nil
+	trace := models.NewContextTrace(s.Name(), models.JSON{"count": count})
+	for i, message := range messages {
+		if i < startIndex {
+			trace.Record(message, models.ContextExcluded, fmt.Sprintf("older than the last %d messages", count))
+		} else {
+			trace.Record(message, models.ContextIncluded, fmt.Sprintf("within the last %d messages", count))
+		}
+	}
+
+	return contextMessages, trace, nil
 }
 
 // buildSystemMessage combines system prompt and agent prompt
@@ -189,4 +189,52 @@ func TestBuildSystemMessage(t *testing.T) {
 			assert.Equal(t, tt.expected, result)
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestLastNStrategy_BuildContextTrace(t *testing.T) {
+	strategy := &LastNStrategy{}
+	messages := []*models.Message{
+		{ID: "m1", Role: "user", Content: "Message 1"},
+		{ID: "m2", Role: "assistant", Content: "Response 1"},
+		{ID: "m3", Role: "user", Content: "Message 2"},
+	}
+
+	contextMessages, trace, err := strategy.BuildContextTrace(context.Background(), "", "", messages, map[string]interface{}{"count": 2})
+	require.NoError(t, err)
+	assert.Len(t, contextMessages, 3)
+
+	assert.Equal(t, "last_n", trace.Strategy)
+	assert.Equal(t, 2, trace.Settings["count"])
+	assert.Equal(t, 3, trace.Messages)
+	assert.Equal(t, 2, trace.Included)
+	assert.Equal(t, 1, trace.Excluded)
+	require.Len(t, trace.Decisions, 3)
+	assert.Equal(t, "m1", trace.Decisions[0].MessageID)
+	assert.Equal(t, models.ContextExcluded, trace.Decisions[0].Decision)
+	assert.Equal(t, "older than the last 2 messages", trace.Decisions[0].Reason)
+	assert.Equal(t, models.ContextIncluded, trace.Decisions[2].Decision)
+}
+
+// untracedStrategy keeps the last message without tracing itself
+type untracedStrategy struct{}
+
+func (s *untracedStrategy) Name() string                          { return "untraced" }
+func (s *untracedStrategy) DefaultConfig() map[string]interface{} { return nil }
+
+func (s *untracedStrategy) BuildContext(ctx context.Context, systemPrompt, agentPrompt string, messages []*models.Message, config map[string]interface{}) ([]*models.Message, error) {
+	return messages[len(messages)-1:], nil
+}
+
+func TestBuildContextTrace_Untraced(t *testing.T) {
+	messages := []*models.Message{
+		{ID: "m1", Role: "user", Content: "Message 1"},
+		{ID: "m2", Role: "assistant", Content: "Response 1"},
+	}
+
+	var strategy ContextStrategy = &untracedStrategy{}
+	_, trace, err := BuildContextTrace(context.Background(), strategy, "", "", messages, nil)
+	require.NoError(t, err)
+	require.Len(t, trace.Decisions, 2)
+	assert.Equal(t, models.ContextExcluded, trace.Decisions[0].Decision)
+	assert.Equal(t, models.ContextIncluded, trace.Decisions[1].Decision)
+}
@@ -21,6 +21,13 @@ func (s *SlidingWindowStrategy) DefaultConfig() map[string]interface{} {
 }
 
 func (s *SlidingWindowStrategy) BuildContext(ctx context.Context, systemPrompt, agentPrompt string, messages []*models.Message, config map[string]interface{}) ([]*models.Message, error) {
+	contextMessages, _, err := s.BuildContextTrace(ctx, systemPrompt, agentPrompt, messages, config)
+	return contextMessages, err
+}
+
+// BuildContextTrace builds the context and records which messages fall
+// before the window and its overlap
+func (s *SlidingWindowStrategy) BuildContextTrace(ctx context.Context, systemPrompt, agentPrompt string, messages []*models.Message, config map[string]interface{}) ([]*models.Message, *models.ContextTrace, error) {
 	// Get configuration
 	windowSize := 5
 	overlap := 2
@@ -42,11 +49,12 @@ func (s *SlidingWindowStrategy) BuildContext(ctx context.Context, systemPrompt,
 	}
 
 	if windowSize <= 0 {
-		return []*models.Message{}, fmt.Errorf("window_size must be positive")
+		return []*models.Message{}, nil, fmt.Errorf("window_size must be positive")
 	}
 	if overlap < 0 || overlap >= windowSize {
-		return []*models.Message{}, fmt.Errorf("overlap must be between 0 and window_size-1")
+		return []*models.Message{}, nil, fmt.Errorf("overlap must be between 0 and window_size-1")
 	}
+	trace := models.NewContextTrace(s.Name(), models.JSON{"window_size": windowSize, "overlap": overlap})
 
 	// Create system message
 	contextMessages := []*models.Message{
@@ -59,12 +67,16 @@ func (s *SlidingWindowStrategy) BuildContext(ctx context.Context, systemPrompt,
 	// If we have fewer messages than window size, return all
 	if len(messages) <= windowSize {
 		contextMessages = append(contextMessages, messages...)
-		return contextMessages, nil
+		for _, message := range messages {
+			trace.Record(message, models.ContextIncluded, fmt.Sprintf("history fits the window of %d messages", windowSize))
+		}
+		return contextMessages, trace, nil
 	}
 
 	// Calculate the start of the sliding window
 	// We want to keep the most recent messages, so we slide from the end
 	start := len(messages) - windowSize
+	window := fmt.Sprintf("the window of %d messages and %d of overlap", windowSize, overlap)
 	
 	// Include overlap from previous window if available
 	if start > overlap {
@@ -78,5 +90,13 @@ func (s *SlidingWindowStrategy) BuildContext(ctx context.Context, systemPrompt,
 	// Take messages from the sliding window
 	contextMessages = append(contextMessages, messages[start:start+windowSize]...)
 
-	return contextMessages, nil
+	for i, message := range messages {
+		if i < start {
+			trace.Record(message, models.ContextExcluded, "before "+window)
+		} else {
+			trace.Record(message, models.ContextIncluded, "within "+window)
+		}
+	}
+
+	return contextMessages, trace, nil
 }
\ No newline at end of file
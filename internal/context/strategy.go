@@ -27,6 +27,7 @@ func NewStrategyRegistry() *StrategyRegistry {
 	registry.Register(&LastNStrategy{})
 	registry.Register(&SlidingWindowStrategy{})
 	registry.Register(&SummarizeStrategy{})
+	registry.Register(NewCompositeStrategy(registry))
 
 	return registry
 }
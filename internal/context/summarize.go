@@ -23,6 +23,13 @@ func (s *SummarizeStrategy) DefaultConfig() map[string]interface{} {
 }
 
 func (s *SummarizeStrategy) BuildContext(ctx context.Context, systemPrompt, agentPrompt string, messages []*models.Message, config map[string]interface{}) ([]*models.Message, error) {
+	contextMessages, _, err := s.BuildContextTrace(ctx, systemPrompt, agentPrompt, messages, config)
+	return contextMessages, err
+}
+
+// BuildContextTrace builds the context and records which messages were
+// folded into the summary
+func (s *SummarizeStrategy) BuildContextTrace(ctx context.Context, systemPrompt, agentPrompt string, messages []*models.Message, config map[string]interface{}) ([]*models.Message, *models.ContextTrace, error) {
 	// Get configuration
 	maxContextLength := 20
 	keepRecent := 5
@@ -44,7 +51,13 @@ func (s *SummarizeStrategy) BuildContext(ctx context.Context, systemPrompt, agen
 	}
 
 	if maxContextLength <= 0 || keepRecent <= 0 {
-		return []*models.Message{}, fmt.Errorf("max_context_length and keep_recent must be positive")
+		return []*models.Message{}, nil, fmt.Errorf("max_context_length and keep_recent must be positive")
+	}
+	trace := models.NewContextTrace(s.Name(), models.JSON{"max_context_length": maxContextLength, "keep_recent": keepRecent})
+	includeAll := func(reason string) {
+		for _, message := range messages {
+			trace.Record(message, models.ContextIncluded, reason)
+		}
 	}
 
 	// Create system message
@@ -58,14 +71,16 @@ func (s *SummarizeStrategy) BuildContext(ctx context.Context, systemPrompt, agen
 	// If we have fewer messages than max context length, return all
 	if len(messages) <= maxContextLength {
 		contextMessages = append(contextMessages, messages...)
-		return contextMessages, nil
+		includeAll(fmt.Sprintf("history within max_context_length of %d messages", maxContextLength))
+		return contextMessages, trace, nil
 	}
 
 	// Calculate how many messages to summarize
 	messagesToSummarize := len(messages) - keepRecent
 	if messagesToSummarize <= 0 {
 		contextMessages = append(contextMessages, messages...)
-		return contextMessages, nil
+		includeAll(fmt.Sprintf("history within keep_recent of %d messages", keepRecent))
+		return contextMessages, trace, nil
 	}
 
 	// Get messages to summarize
@@ -86,7 +101,14 @@ func (s *SummarizeStrategy) BuildContext(ctx context.Context, systemPrompt, agen
 	// Add recent messages
 	contextMessages = append(contextMessages, recentMessages...)
 
-	return contextMessages, nil
+	for _, message := range oldMessages {
+		trace.Record(message, models.ContextSummarized, fmt.Sprintf("older than the %d most recent messages of a history over max_context_length of %d", keepRecent, maxContextLength))
+	}
+	for _, message := range recentMessages {
+		trace.Record(message, models.ContextIncluded, fmt.Sprintf("among the %d most recent messages", keepRecent))
+	}
+
+	return contextMessages, trace, nil
 }
 
 // createSimpleSummary creates a basic summary of messages
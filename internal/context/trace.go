@@ -0,0 +1,50 @@
+package context
+
+import (
+	"context"
+
+	"agent-server/internal/models"
+)
+
+// TracingStrategy is a strategy that records why it included, excluded or
+// summarized each history message
+type TracingStrategy interface {
+	ContextStrategy
+	BuildContextTrace(ctx context.Context, systemPrompt, agentPrompt string, messages []*models.Message, config map[string]interface{}) ([]*models.Message, *models.ContextTrace, error)
+}
+
+// BuildContextTrace builds a context with strategy and traces its decisions.
+// Strategies that do not trace themselves are traced by comparing the
+// context with the history: messages missing from it count as excluded.
+func BuildContextTrace(ctx context.Context, strategy ContextStrategy, systemPrompt, agentPrompt string, messages []*models.Message, config map[string]interface{}) ([]*models.Message, *models.ContextTrace, error) {
+	if tracing, ok := strategy.(TracingStrategy); ok {
+		return tracing.BuildContextTrace(ctx, systemPrompt, agentPrompt, messages, config)
+	}
+
+	contextMessages, err := strategy.BuildContext(ctx, systemPrompt, agentPrompt, messages, config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kept := make(map[*models.Message]bool, len(contextMessages))
+	keptIDs := make(map[string]bool, len(contextMessages))
+	for _, message := range contextMessages {
+		kept[message] = true
+		if message.ID != "" {
+			keptIDs[message.ID] = true
+		}
+	}
+	settings := make(models.JSON, len(config))
+	for key, value := range config {
+		settings[key] = value
+	}
+	trace := models.NewContextTrace(strategy.Name(), settings)
+	for _, message := range messages {
+		if kept[message] || (message.ID != "" && keptIDs[message.ID]) {
+			trace.Record(message, models.ContextIncluded, "kept by the strategy")
+		} else {
+			trace.Record(message, models.ContextExcluded, "left out by the strategy")
+		}
+	}
+	return contextMessages, trace, nil
+}
@@ -0,0 +1,259 @@
+// Package credentials obtains the access tokens of outbound OAuth2 clients,
+// which tools present to the APIs they call. Tokens are fetched on first use,
+// cached, and fetched again shortly before they expire; refresh tokens that
+// the authorization server rotates are kept for the next refresh.
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth2 grant types
+const (
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// Client authentication styles at the token endpoint
+const (
+	AuthStyleHeader = "header" // HTTP basic authentication
+	AuthStyleBody   = "body"   // client_id and client_secret form fields
+)
+
+// expiryMargin is how long before its expiry a token is replaced, so that it
+// does not lapse during a tool call
+const expiryMargin = time.Minute
+
+// defaultLifetime applies to tokens issued without expires_in
+const defaultLifetime = 10 * time.Minute
+
+// maxTokenResponse bounds the token endpoint responses read
+const maxTokenResponse = 1 << 20
+
+// ErrUnknownCredential is returned for names with no configured client
+var ErrUnknownCredential = errors.New("OAuth2 credential is not configured")
+
+// OAuth2Client configures an OAuth2 client. With a refresh token it uses the
+// refresh-token grant, else client credentials.
+type OAuth2Client struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Audience     string
+	RefreshToken string
+	AuthStyle    string // AuthStyleHeader (default) or AuthStyleBody
+}
+
+// Grant returns the grant type the client uses
+func (c OAuth2Client) Grant() string {
+	if c.RefreshToken != "" {
+		return GrantRefreshToken
+	}
+	return GrantClientCredentials
+}
+
+// TokenStatus describes the cached token of a credential, without the token
+type TokenStatus struct {
+	Name      string     `json:"name"`
+	Grant     string     `json:"grant"`
+	TokenURL  string     `json:"token_url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	FetchedAt *time.Time `json:"fetched_at,omitempty"`
+	Fetches   int        `json:"fetches"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// Manager hands out the access tokens of named OAuth2 clients
+type Manager struct {
+	httpClient *http.Client
+	now        func() time.Time
+	sources    map[string]*tokenSource
+}
+
+// tokenSource caches the token of one client. Its mutex is held while a
+// token is fetched, so concurrent tool calls wait for one request.
+type tokenSource struct {
+	client OAuth2Client
+
+	mu           sync.Mutex
+	accessToken  string
+	refreshToken string
+	expiresAt    time.Time
+	fetchedAt    time.Time
+	fetches      int
+	lastError    string
+}
+
+// NewManager creates a manager for the given clients, which requests tokens
+// through httpClient; nil uses http.DefaultClient
+func NewManager(clients map[string]OAuth2Client, httpClient *http.Client) *Manager {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	sources := make(map[string]*tokenSource, len(clients))
+	for name, client := range clients {
+		sources[name] = &tokenSource{client: client, refreshToken: client.RefreshToken}
+	}
+	return &Manager{httpClient: httpClient, now: time.Now, sources: sources}
+}
+
+// Has reports whether a client of that name is configured
+func (m *Manager) Has(name string) bool {
+	if m == nil {
+		return false
+	}
+	_, ok := m.sources[name]
+	return ok
+}
+
+// Token returns a valid access token of the named client, fetching a new one
+// when the cached token is missing or about to expire
+func (m *Manager) Token(ctx context.Context, name string) (string, error) {
+	if !m.Has(name) {
+		return "", fmt.Errorf("%w: %s", ErrUnknownCredential, name)
+	}
+	source := m.sources[name]
+	source.mu.Lock()
+	defer source.mu.Unlock()
+
+	now := m.now()
+	if source.accessToken != "" && now.Add(expiryMargin).Before(source.expiresAt) {
+		return source.accessToken, nil
+	}
+	if err := m.fetch(ctx, source, now); err != nil {
+		source.lastError = err.Error()
+		return "", fmt.Errorf("OAuth2 credential %s: %w", name, err)
+	}
+	return source.accessToken, nil
+}
+
+// Invalidate drops the cached token of a client, for instance after an API
+// rejected it, so that the next call fetches a new one
+func (m *Manager) Invalidate(name string) {
+	if !m.Has(name) {
+		return
+	}
+	source := m.sources[name]
+	source.mu.Lock()
+	source.accessToken = ""
+	source.mu.Unlock()
+}
+
+// Status lists the configured clients and their cached tokens by name
+func (m *Manager) Status() []TokenStatus {
+	if m == nil {
+		return nil
+	}
+	statuses := make([]TokenStatus, 0, len(m.sources))
+	for name, source := range m.sources {
+		source.mu.Lock()
+		status := TokenStatus{
+			Name:      name,
+			Grant:     source.client.Grant(),
+			TokenURL:  source.client.TokenURL,
+			Fetches:   source.fetches,
+			LastError: source.lastError,
+		}
+		if source.accessToken != "" {
+			expiresAt, fetchedAt := source.expiresAt, source.fetchedAt
+			status.ExpiresAt, status.FetchedAt = &expiresAt, &fetchedAt
+		}
+		source.mu.Unlock()
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// tokenResponse is the successful response of a token endpoint (RFC 6749
+// section 5.1) or its error response (section 5.2)
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// fetch requests a new token for source; source.mu must be held
+func (m *Manager) fetch(ctx context.Context, source *tokenSource, now time.Time) error {
+	client := source.client
+	form := url.Values{"grant_type": {client.Grant()}}
+	if client.Grant() == GrantRefreshToken {
+		form.Set("refresh_token", source.refreshToken)
+	}
+	if len(client.Scopes) > 0 {
+		form.Set("scope", strings.Join(client.Scopes, " "))
+	}
+	if client.Audience != "" {
+		form.Set("audience", client.Audience)
+	}
+	if client.AuthStyle == AuthStyleBody {
+		form.Set("client_id", client.ClientID)
+		if client.ClientSecret != "" {
+			form.Set("client_secret", client.ClientSecret)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if client.AuthStyle != AuthStyleBody {
+		req.SetBasicAuth(url.QueryEscape(client.ClientID), url.QueryEscape(client.ClientSecret))
+	}
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenResponse))
+	if err != nil {
+		return fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	var token tokenResponse
+	decodeErr := json.Unmarshal(body, &token)
+	if resp.StatusCode != http.StatusOK {
+		if decodeErr == nil && token.Error != "" {
+			return fmt.Errorf("token endpoint returned %d: %s %s", resp.StatusCode, token.Error, token.ErrorDescription)
+		}
+		return fmt.Errorf("token endpoint returned %d", resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("invalid token response: %w", decodeErr)
+	}
+	if token.AccessToken == "" {
+		return errors.New("token response has no access_token")
+	}
+
+	lifetime := defaultLifetime
+	if token.ExpiresIn > 0 {
+		lifetime = time.Duration(token.ExpiresIn) * time.Second
+	}
+	source.accessToken = token.AccessToken
+	source.expiresAt = now.Add(lifetime)
+	source.fetchedAt = now
+	source.fetches++
+	source.lastError = ""
+	if token.RefreshToken != "" {
+		// Servers that rotate refresh tokens invalidate the old one
+		source.refreshToken = token.RefreshToken
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_ClientCredentials(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		user, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "tools", user)
+		assert.Equal(t, "s3cret", password)
+		assert.Equal(t, GrantClientCredentials, r.PostForm.Get("grant_type"))
+		assert.Equal(t, "read write", r.PostForm.Get("scope"))
+
+		mu.Lock()
+		requests++
+		token := map[string]interface{}{"access_token": fmt.Sprintf("token-%d", requests), "expires_in": 3600}
+		mu.Unlock()
+		require.NoError(t, json.NewEncoder(w).Encode(token))
+	}))
+	defer server.Close()
+
+	manager := NewManager(map[string]OAuth2Client{
+		"crm": {TokenURL: server.URL, ClientID: "tools", ClientSecret: "s3cret", Scopes: []string{"read", "write"}},
+	}, server.Client())
+	now := time.Now()
+	manager.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Concurrent calls share one token request
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := manager.Token(ctx, "crm")
+			assert.NoError(t, err)
+			assert.Equal(t, "token-1", token)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, requests)
+
+	// The token is replaced shortly before it expires
+	now = now.Add(time.Hour - 30*time.Second)
+	token, err := manager.Token(ctx, "crm")
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token)
+
+	status := manager.Status()
+	require.Len(t, status, 1)
+	assert.Equal(t, 2, status[0].Fetches)
+	assert.Equal(t, now.Add(time.Hour), *status[0].ExpiresAt)
+
+	// Invalidated tokens are fetched again
+	manager.Invalidate("crm")
+	token, err = manager.Token(ctx, "crm")
+	require.NoError(t, err)
+	assert.Equal(t, "token-3", token)
+
+	_, err = manager.Token(ctx, "billing")
+	assert.ErrorIs(t, err, ErrUnknownCredential)
+}
+
+func TestManager_RefreshTokenRotation(t *testing.T) {
+	var refreshTokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, GrantRefreshToken, r.PostForm.Get("grant_type"))
+		assert.Equal(t, "tools", r.PostForm.Get("client_id"))
+		refreshTokens = append(refreshTokens, r.PostForm.Get("refresh_token"))
+		if len(refreshTokens) == 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"refresh token revoked"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "access",
+			"refresh_token": "rotated-" + r.PostForm.Get("refresh_token"),
+		})
+	}))
+	defer server.Close()
+
+	manager := NewManager(map[string]OAuth2Client{
+		"mail": {TokenURL: server.URL, ClientID: "tools", RefreshToken: "initial", AuthStyle: AuthStyleBody},
+	}, server.Client())
+	ctx := context.Background()
+
+	_, err := manager.Token(ctx, "mail")
+	require.NoError(t, err)
+	manager.Invalidate("mail")
+	_, err = manager.Token(ctx, "mail")
+	require.NoError(t, err)
+
+	// Each refresh uses the token the previous one returned
+	assert.Equal(t, []string{"initial", "rotated-initial"}, refreshTokens)
+
+	// Errors of the token endpoint are reported and kept for the status
+	manager.Invalidate("mail")
+	_, err = manager.Token(ctx, "mail")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid_grant refresh token revoked")
+	assert.Contains(t, manager.Status()[0].LastError, "invalid_grant")
+	assert.Equal(t, GrantRefreshToken, manager.Status()[0].Grant)
+}
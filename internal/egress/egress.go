@@ -0,0 +1,136 @@
+// Package egress decides which proxy outbound tool, provider and webhook
+// requests go through.
+package egress
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"agent-server/internal/config"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Policy resolves the proxy for outbound requests
+type Policy struct {
+	global config.ProxyConfig
+	tools  map[string]config.ProxyConfig
+	force  *url.URL
+}
+
+// New validates the egress configuration and returns its policy
+func New(cfg config.EgressConfig) (*Policy, error) {
+	policy := &Policy{
+		global: config.ProxyConfig{
+			HTTPProxy:  cfg.HTTPProxy,
+			HTTPSProxy: cfg.HTTPSProxy,
+			NoProxy:    cfg.NoProxy,
+		},
+		tools: make(map[string]config.ProxyConfig, len(cfg.Tools)),
+	}
+
+	if err := validateProxies("egress", policy.global); err != nil {
+		return nil, err
+	}
+	for name, proxy := range cfg.Tools {
+		if err := validateProxies("egress.tools."+name, proxy); err != nil {
+			return nil, err
+		}
+		policy.tools[name] = proxy
+	}
+
+	if cfg.ForceProxy != "" {
+		force, err := parseProxyURL(cfg.ForceProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid egress.force_proxy: %w", err)
+		}
+		policy.force = force
+	}
+
+	return policy, nil
+}
+
+// Forced reports whether all traffic goes through a single forced proxy
+func (p *Policy) Forced() bool {
+	return p.force != nil
+}
+
+// Proxy returns the proxy function for requests made by the named tool. An
+// empty name selects the global settings used for providers and webhooks.
+func (p *Policy) Proxy(tool string) func(*http.Request) (*url.URL, error) {
+	if p.force != nil {
+		force := p.force
+		return func(*http.Request) (*url.URL, error) {
+			return force, nil
+		}
+	}
+
+	settings := p.global
+	if override, ok := p.tools[tool]; ok {
+		if override.HTTPProxy != "" {
+			settings.HTTPProxy = override.HTTPProxy
+		}
+		if override.HTTPSProxy != "" {
+			settings.HTTPSProxy = override.HTTPSProxy
+		}
+		if override.NoProxy != "" {
+			settings.NoProxy = override.NoProxy
+		}
+	}
+
+	if settings == (config.ProxyConfig{}) {
+		return http.ProxyFromEnvironment
+	}
+
+	proxyFunc := (&httpproxy.Config{
+		HTTPProxy:  settings.HTTPProxy,
+		HTTPSProxy: settings.HTTPSProxy,
+		NoProxy:    settings.NoProxy,
+	}).ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
+// Transport returns a transport with the default settings that routes
+// requests of the named tool according to the policy
+func (p *Policy) Transport(tool string) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = p.Proxy(tool)
+	return transport
+}
+
+func validateProxies(key string, proxy config.ProxyConfig) error {
+	for name, value := range map[string]string{"http_proxy": proxy.HTTPProxy, "https_proxy": proxy.HTTPSProxy} {
+		if value == "" {
+			continue
+		}
+		if _, err := parseProxyURL(value); err != nil {
+			return fmt.Errorf("invalid %s.%s: %w", key, name, err)
+		}
+	}
+	return nil
+}
+
+// parseProxyURL parses a proxy address, defaulting to http:// when the
+// scheme is omitted as the standard proxy variables allow
+func parseProxyURL(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	proxy, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch proxy.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme)
+	}
+	if proxy.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", raw)
+	}
+	return proxy, nil
+}
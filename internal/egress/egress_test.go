@@ -0,0 +1,73 @@
+package egress
+
+import (
+	"net/http"
+	"testing"
+
+	"agent-server/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func proxyFor(t *testing.T, policy *Policy, tool, target string) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	require.NoError(t, err)
+	proxy, err := policy.Proxy(tool)(req)
+	require.NoError(t, err)
+	if proxy == nil {
+		return ""
+	}
+	return proxy.String()
+}
+
+func TestPolicy_Proxy(t *testing.T) {
+	policy, err := New(config.EgressConfig{
+		HTTPProxy:  "http://proxy.internal:3128",
+		HTTPSProxy: "proxy.internal:3129",
+		NoProxy:    "internal.example.com,10.0.0.0/8",
+		Tools: map[string]config.ProxyConfig{
+			"web_scraper": {HTTPSProxy: "http://scraper-proxy:8080"},
+		},
+	})
+	require.NoError(t, err)
+	assert.False(t, policy.Forced())
+
+	assert.Equal(t, "http://proxy.internal:3128", proxyFor(t, policy, "", "http://api.example.com/data"))
+	assert.Equal(t, "http://proxy.internal:3129", proxyFor(t, policy, "http_get", "https://api.example.com/data"))
+	assert.Empty(t, proxyFor(t, policy, "http_get", "https://internal.example.com/data"))
+	assert.Empty(t, proxyFor(t, policy, "http_get", "http://10.1.2.3/data"))
+
+	// Per-tool settings override the global ones field by field
+	assert.Equal(t, "http://scraper-proxy:8080", proxyFor(t, policy, "web_scraper", "https://news.example.com"))
+	assert.Equal(t, "http://proxy.internal:3128", proxyFor(t, policy, "web_scraper", "http://news.example.com"))
+	assert.Empty(t, proxyFor(t, policy, "web_scraper", "https://internal.example.com"))
+}
+
+func TestPolicy_ForceProxy(t *testing.T) {
+	policy, err := New(config.EgressConfig{
+		NoProxy:    "internal.example.com",
+		ForceProxy: "http://egress.internal:3128",
+		Tools: map[string]config.ProxyConfig{
+			"http_get": {HTTPProxy: "http://other:8080"},
+		},
+	})
+	require.NoError(t, err)
+	assert.True(t, policy.Forced())
+
+	for _, target := range []string{"https://internal.example.com", "http://localhost:11434/api/chat", "http://api.example.com"} {
+		assert.Equal(t, "http://egress.internal:3128", proxyFor(t, policy, "http_get", target), target)
+		assert.Equal(t, "http://egress.internal:3128", proxyFor(t, policy, "", target), target)
+	}
+}
+
+func TestNew_RejectsInvalidProxy(t *testing.T) {
+	_, err := New(config.EgressConfig{ForceProxy: "ftp://proxy:21"})
+	assert.ErrorContains(t, err, "egress.force_proxy")
+
+	_, err = New(config.EgressConfig{Tools: map[string]config.ProxyConfig{
+		"http_get": {HTTPProxy: "http://"},
+	}})
+	assert.ErrorContains(t, err, "egress.tools.http_get.http_proxy")
+}
@@ -0,0 +1,109 @@
+package injection
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"agent-server/internal/config"
+)
+
+// Actions taken on a result the classifier detects an injection in
+const (
+	ActionFlag  = "flag"  // the result is passed on with a warning
+	ActionBlock = "block" // the result is withheld from the LLM
+)
+
+// Classifier scores how likely a text is to carry instructions aimed at
+// the LLM
+type Classifier interface {
+	Name() string
+	Classify(ctx context.Context, text string) (*Result, error)
+}
+
+// Result is a classifier's score of a text, from 0 to 1, with the passages
+// that raised it
+type Result struct {
+	Classifier string
+	Score      float64
+	Matches    []string
+}
+
+// DefaultPatterns are the expressions of the pattern classifier unless
+// configured otherwise
+var DefaultPatterns = []string{
+	`ignore\s+(?:all\s+)?(?:the\s+|your\s+)?(?:previous|prior|above|earlier|preceding)\s+(?:instructions|prompts?|messages|rules)`,
+	`disregard\s+(?:all\s+|any\s+)?(?:the\s+|your\s+)?(?:previous\s+|prior\s+|above\s+|earlier\s+)?(?:instructions|rules|guidelines|prompt)`,
+	`forget\s+(?:all\s+|everything\s+)?(?:you\s+were\s+told|your\s+instructions|previous\s+instructions)`,
+	`override\s+(?:your|the|all)\s+(?:instructions|rules|safety|guidelines)`,
+	`you\s+are\s+now\s+(?:a|an|in)\b`,
+	`new\s+(?:system\s+)?instructions\s*:`,
+	`(?:reveal|print|show|repeat|output)\s+(?:your|the)\s+(?:system\s+prompt|instructions|hidden\s+prompt)`,
+	`do\s+not\s+(?:tell|inform|alert)\s+the\s+user`,
+	`(?:send|forward|post|email|upload)\b.{0,60}\b(?:passwords?|api\s+keys?|access\s+tokens?|credentials|secrets)\b`,
+	`(?:call|use|invoke)\s+the\s+\w+\s+tool\s+(?:to|and)\b`,
+}
+
+// Scores of the pattern classifier. With the default threshold, one
+// matching expression counts as an injection.
+const (
+	patternBaseScore = 0.6
+	patternHitScore  = 0.2
+)
+
+// Patterns is a local classifier that scores a text by the phrasings of
+// injected instructions it contains
+type Patterns struct {
+	patterns []*regexp.Regexp
+}
+
+// NewPatterns creates a classifier from regular expressions, matched
+// ignoring case
+func NewPatterns(expressions []string) (*Patterns, error) {
+	p := &Patterns{patterns: make([]*regexp.Regexp, 0, len(expressions))}
+	for _, expression := range expressions {
+		pattern, err := regexp.Compile(`(?i)` + expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid injection pattern %q: %w", expression, err)
+		}
+		p.patterns = append(p.patterns, pattern)
+	}
+	return p, nil
+}
+
+// Name returns the classifier name
+func (p *Patterns) Name() string {
+	return "patterns"
+}
+
+// Classify scores the text by its matches
+func (p *Patterns) Classify(ctx context.Context, text string) (*Result, error) {
+	result := &Result{Classifier: p.Name()}
+	for _, pattern := range p.patterns {
+		result.Matches = append(result.Matches, pattern.FindAllString(text, -1)...)
+	}
+	if hits := len(result.Matches); hits > 0 {
+		result.Score = patternBaseScore + patternHitScore*float64(hits-1)
+		if result.Score > 1 {
+			result.Score = 1
+		}
+	}
+	return result, nil
+}
+
+// NewFromConfig returns the classifier of the configuration, or nil when
+// classification is disabled
+func NewFromConfig(cfg config.ToolInjectionClassifierConfig) (Classifier, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	expressions := cfg.Patterns
+	if len(expressions) == 0 {
+		expressions = DefaultPatterns
+	}
+	patterns, err := NewPatterns(expressions)
+	if err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"agent-server/internal/redis"
+)
+
+// Elector chooses the one instance that maintains the shared job outbox.
+// Jobs themselves are claimed atomically by any instance; only maintenance,
+// such as releasing the jobs of lost workers, is left to the leader.
+type Elector interface {
+	// Elect tries to become or stay the leader and reports whether this
+	// instance leads
+	Elect(ctx context.Context) (bool, error)
+	// Resign gives up leadership, so that another instance takes over
+	// without waiting for the lease to expire
+	Resign(ctx context.Context) error
+}
+
+// electScript renews the lease held by this instance or takes a free one
+const electScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 1
+end
+return 0`
+
+// resignScript releases the lease if this instance still holds it
+const resignScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`
+
+// RedisLease elects a leader with a Redis key that holds the leader's ID
+// and expires unless the leader renews it
+type RedisLease struct {
+	client *redis.Client
+	key    string
+	id     string
+	ttl    time.Duration
+}
+
+// NewRedisLease creates an elector competing for key as id. The leader must
+// renew the lease within ttl.
+func NewRedisLease(client *redis.Client, key, id string, ttl time.Duration) *RedisLease {
+	return &RedisLease{client: client, key: key, id: id, ttl: ttl}
+}
+
+// Elect takes the lease if it is free or renews it if this instance holds it
+func (l *RedisLease) Elect(ctx context.Context) (bool, error) {
+	reply, err := l.client.Do(ctx, "EVAL", electScript, 1, l.key, l.id, l.ttl.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+	return reply == int64(1), nil
+}
+
+// Resign releases the lease if this instance holds it
+func (l *RedisLease) Resign(ctx context.Context) error {
+	_, err := l.client.Do(ctx, "EVAL", resignScript, 1, l.key, l.id)
+	return err
+}
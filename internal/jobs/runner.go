@@ -0,0 +1,311 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+)
+
+// Handler processes a single job. Returning an error schedules a retry until
+// the job's MaxAttempts is reached, after which it is dead-lettered.
+type Handler func(ctx context.Context, job *models.Job) error
+
+// Options configures the runner
+type Options struct {
+	Workers      int
+	PollInterval time.Duration
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+	JobTimeout   time.Duration
+
+	// SweepInterval is how often jobs of lost workers are released
+	SweepInterval time.Duration
+}
+
+// DefaultOptions returns sensible runner defaults
+func DefaultOptions() Options {
+	return Options{
+		Workers:      2,
+		PollInterval: time.Second,
+		BackoffBase:  5 * time.Second,
+		BackoffMax:   10 * time.Minute,
+		JobTimeout:   5 * time.Minute,
+
+		SweepInterval: time.Minute,
+	}
+}
+
+// Runner polls the job outbox and executes jobs with a pool of workers
+type Runner struct {
+	repo     storage.JobRepository
+	opts     Options
+	logger   *slog.Logger
+	handlers map[string]Handler
+	mu       sync.RWMutex
+	elector  Elector
+
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running atomic.Bool
+	leader  atomic.Bool
+}
+
+// NewRunner creates a new job runner
+func NewRunner(repo storage.JobRepository, opts Options, logger *slog.Logger) *Runner {
+	defaults := DefaultOptions()
+	if opts.Workers <= 0 {
+		opts.Workers = defaults.Workers
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaults.PollInterval
+	}
+	if opts.BackoffBase <= 0 {
+		opts.BackoffBase = defaults.BackoffBase
+	}
+	if opts.BackoffMax <= 0 {
+		opts.BackoffMax = defaults.BackoffMax
+	}
+	if opts.JobTimeout <= 0 {
+		opts.JobTimeout = defaults.JobTimeout
+	}
+	if opts.SweepInterval <= 0 {
+		opts.SweepInterval = defaults.SweepInterval
+	}
+
+	return &Runner{
+		repo:     repo,
+		opts:     opts,
+		logger:   logger,
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Register adds a handler for a job type
+func (r *Runner) Register(jobType string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[jobType] = handler
+}
+
+// SetElector leaves the maintenance of the outbox to the elected instance
+// when several instances share it. Without an elector every instance
+// maintains it.
+func (r *Runner) SetElector(elector Elector) {
+	r.elector = elector
+}
+
+// Enqueue stores a new job for asynchronous execution
+func (r *Runner) Enqueue(ctx context.Context, jobType string, payload map[string]interface{}) (*models.Job, error) {
+	job := models.NewJob(jobType, payload)
+	if err := r.repo.Create(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	return job, nil
+}
+
+// Start launches the worker pool. Jobs left running by a lost worker, such
+// as a previous process, are released back to the queue first and then
+// periodically.
+func (r *Runner) Start(ctx context.Context) {
+	r.sweep(ctx)
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.wg.Add(1)
+	go r.maintain(ctx)
+	for i := 0; i < r.opts.Workers; i++ {
+		r.wg.Add(1)
+		go r.work(ctx)
+	}
+	r.running.Store(true)
+
+	r.logger.Info("Job runner started", "workers", r.opts.Workers)
+}
+
+// Stop signals workers to exit and waits for in-flight jobs to finish. A
+// leader resigns, so that another instance takes over.
+func (r *Runner) Stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	r.wg.Wait()
+	r.running.Store(false)
+
+	if r.elector != nil && r.leader.Swap(false) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := r.elector.Resign(ctx); err != nil {
+			r.logger.Warn("Failed to resign job leadership", "error", err)
+		}
+	}
+}
+
+// Running reports whether the worker pool has been started and not stopped
+func (r *Runner) Running() bool {
+	return r.running.Load()
+}
+
+// Leader reports whether this instance maintains the outbox, which it
+// always does without an elector
+func (r *Runner) Leader() bool {
+	return r.elector == nil || r.leader.Load()
+}
+
+// maintain sweeps the outbox periodically until ctx is done
+func (r *Runner) maintain(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.opts.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.sweep(ctx)
+		}
+	}
+}
+
+// sweep releases the jobs of lost workers if this instance is the leader
+func (r *Runner) sweep(ctx context.Context) {
+	if r.elector != nil {
+		elected, err := r.elector.Elect(ctx)
+		if err != nil {
+			r.logger.Error("Failed to elect job leader", "error", err)
+		}
+		if was := r.leader.Swap(elected); was != elected {
+			if elected {
+				r.logger.Info("Elected job leader")
+			} else {
+				r.logger.Warn("Lost job leadership")
+			}
+		}
+		if !elected {
+			return
+		}
+	}
+
+	if released, err := r.repo.ReleaseStale(ctx, time.Now().Add(-r.opts.JobTimeout)); err != nil {
+		r.logger.Error("Failed to release stale jobs", "error", err)
+	} else if released > 0 {
+		r.logger.Warn("Released stale jobs", "count", released)
+	}
+}
+
+// work is the worker loop
+func (r *Runner) work(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain all due jobs before sleeping again
+		for ctx.Err() == nil && r.RunOnce(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce claims and executes a single due job. It reports whether a job was
+// processed.
+func (r *Runner) RunOnce(ctx context.Context) bool {
+	job, err := r.repo.ClaimNext(ctx, time.Now())
+	if err != nil {
+		r.logger.Error("Failed to claim job", "error", err)
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	r.execute(ctx, job)
+	return true
+}
+
+// execute runs the handler for a claimed job and records the outcome
+func (r *Runner) execute(ctx context.Context, job *models.Job) {
+	r.mu.RLock()
+	handler, exists := r.handlers[job.Type]
+	r.mu.RUnlock()
+
+	// Outcomes are recorded even if the runner is shutting down
+	storeCtx := context.WithoutCancel(ctx)
+
+	if !exists {
+		r.logger.Error("No handler registered for job type", "job_id", job.ID, "type", job.Type)
+		if err := r.repo.MarkDead(storeCtx, job.ID, fmt.Sprintf("no handler registered for job type %q", job.Type)); err != nil {
+			r.logger.Error("Failed to dead-letter job", "job_id", job.ID, "error", err)
+		}
+		return
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, r.opts.JobTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := r.safeRun(jobCtx, handler, job)
+	duration := time.Since(start)
+
+	if err == nil {
+		if err := r.repo.MarkSucceeded(storeCtx, job.ID); err != nil {
+			r.logger.Error("Failed to mark job succeeded", "job_id", job.ID, "error", err)
+		}
+		r.logger.Info("Job succeeded", "job_id", job.ID, "type", job.Type, "duration_ms", duration.Milliseconds())
+		return
+	}
+
+	if job.Attempts >= job.MaxAttempts {
+		if markErr := r.repo.MarkDead(storeCtx, job.ID, err.Error()); markErr != nil {
+			r.logger.Error("Failed to dead-letter job", "job_id", job.ID, "error", markErr)
+		}
+		r.logger.Error("Job dead-lettered", "job_id", job.ID, "type", job.Type, "attempts", job.Attempts, "error", err)
+		return
+	}
+
+	retryAt := time.Now().Add(r.backoff(job.Attempts))
+	if markErr := r.repo.MarkFailed(storeCtx, job.ID, err.Error(), retryAt); markErr != nil {
+		r.logger.Error("Failed to mark job failed", "job_id", job.ID, "error", markErr)
+	}
+	r.logger.Warn("Job failed, retry scheduled",
+		"job_id", job.ID,
+		"type", job.Type,
+		"attempts", job.Attempts,
+		"retry_at", retryAt,
+		"error", err)
+}
+
+// safeRun invokes the handler, converting panics into errors
+func (r *Runner) safeRun(ctx context.Context, handler Handler, job *models.Job) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("job handler panicked: %v", rec)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// backoff returns the exponential retry delay after the given attempt
+func (r *Runner) backoff(attempt int) time.Duration {
+	delay := r.opts.BackoffBase
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= r.opts.BackoffMax {
+			return r.opts.BackoffMax
+		}
+	}
+	return delay
+}
@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRepo(t *testing.T) storage.Repository {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+func TestRunner_RetriesThenDeadLetters(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+
+	runner := NewRunner(repo.Job(), Options{BackoffBase: time.Millisecond, BackoffMax: time.Millisecond}, slog.Default())
+	calls := 0
+	runner.Register("flaky", func(ctx context.Context, job *models.Job) error {
+		calls++
+		return errors.New("boom")
+	})
+
+	job := models.NewJob("flaky", map[string]interface{}{"n": 1})
+	job.MaxAttempts = 2
+	require.NoError(t, repo.Job().Create(ctx, job))
+
+	require.True(t, runner.RunOnce(ctx))
+	stored, err := repo.Job().GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusFailed, stored.Status)
+	assert.Equal(t, "boom", stored.LastError)
+
+	time.Sleep(5 * time.Millisecond)
+	require.True(t, runner.RunOnce(ctx))
+	stored, err = repo.Job().GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusDead, stored.Status)
+	assert.Equal(t, 2, stored.Attempts)
+	assert.Equal(t, 2, calls)
+
+	// Nothing left to run until the job is requeued
+	assert.False(t, runner.RunOnce(ctx))
+	require.NoError(t, repo.Job().Requeue(ctx, job.ID))
+	stored, err = repo.Job().GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusPending, stored.Status)
+	assert.Equal(t, 0, stored.Attempts)
+}
+
+func TestRunner_UnknownTypeIsDeadLettered(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	runner := NewRunner(repo.Job(), Options{}, slog.Default())
+
+	job, err := runner.Enqueue(ctx, "missing", nil)
+	require.NoError(t, err)
+
+	require.True(t, runner.RunOnce(ctx))
+	stored, err := repo.Job().GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusDead, stored.Status)
+}
+
+func TestRunner_RecoversHandlerPanic(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	runner := NewRunner(repo.Job(), Options{}, slog.Default())
+	runner.Register("panics", func(ctx context.Context, job *models.Job) error {
+		panic("unexpected")
+	})
+
+	job, err := runner.Enqueue(ctx, "panics", nil)
+	require.NoError(t, err)
+
+	require.True(t, runner.RunOnce(ctx))
+	stored, err := repo.Job().GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusFailed, stored.Status)
+	assert.Contains(t, stored.LastError, "panicked")
+}
+
+func TestWebhookHandler(t *testing.T) {
+	var gotSignature, gotJobID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSignature = r.Header.Get("X-Signature-256")
+		gotJobID = r.Header.Get("X-Job-ID")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	runner := NewRunner(repo.Job(), Options{}, slog.Default())
+	runner.Register(JobTypeWebhook, NewWebhookHandler(server.Client()))
+
+	job, err := runner.Enqueue(ctx, JobTypeWebhook, WebhookPayload(server.URL, map[string]interface{}{"event": "test"}, "secret"))
+	require.NoError(t, err)
+
+	require.True(t, runner.RunOnce(ctx))
+	stored, err := repo.Job().GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusSucceeded, stored.Status)
+	assert.Equal(t, job.ID, gotJobID)
+	assert.Contains(t, gotSignature, "sha256=")
+}
+
+func TestRunner_Backoff(t *testing.T) {
+	runner := NewRunner(nil, Options{BackoffBase: time.Second, BackoffMax: 5 * time.Second}, slog.Default())
+	assert.Equal(t, time.Second, runner.backoff(1))
+	assert.Equal(t, 2*time.Second, runner.backoff(2))
+	assert.Equal(t, 4*time.Second, runner.backoff(3))
+	assert.Equal(t, 5*time.Second, runner.backoff(4))
+}
+
+// fixedElector always reports the same election outcome
+type fixedElector struct {
+	leads    bool
+	resigned bool
+}
+
+func (e *fixedElector) Elect(ctx context.Context) (bool, error) { return e.leads, nil }
+
+func (e *fixedElector) Resign(ctx context.Context) error {
+	e.resigned = true
+	return nil
+}
+
+func TestRunner_OnlyLeaderReleasesLostJobs(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+
+	// A worker claimed the job long ago and was lost
+	job := models.NewJob("slow", nil)
+	job.RunAt = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, repo.Job().Create(ctx, job))
+	claimed, err := repo.Job().ClaimNext(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+
+	follower := NewRunner(repo.Job(), Options{JobTimeout: time.Minute}, slog.Default())
+	follower.SetElector(&fixedElector{})
+	follower.sweep(ctx)
+	assert.False(t, follower.Leader())
+	stored, err := repo.Job().GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusRunning, stored.Status)
+
+	elector := &fixedElector{leads: true}
+	leader := NewRunner(repo.Job(), Options{JobTimeout: time.Minute}, slog.Default())
+	leader.SetElector(elector)
+	leader.Start(ctx)
+	assert.True(t, leader.Leader())
+	stored, err = repo.Job().GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusFailed, stored.Status)
+
+	// Stopping hands leadership to another instance
+	leader.Stop()
+	assert.True(t, elector.resigned)
+	assert.False(t, leader.Leader())
+}
@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"agent-server/internal/models"
+)
+
+// JobTypeWebhook delivers an HTTP callback
+const JobTypeWebhook = "webhook"
+
+// WebhookPayload builds the payload for a webhook job. When secret is set the
+// body is signed with HMAC-SHA256 in the X-Signature-256 header.
+func WebhookPayload(url string, body interface{}, secret string) map[string]interface{} {
+	payload := map[string]interface{}{
+		"url":  url,
+		"body": body,
+	}
+	if secret != "" {
+		payload["secret"] = secret
+	}
+	return payload
+}
+
+// NewWebhookHandler returns a handler that POSTs the job body as JSON to the
+// job URL; any non-2xx response is treated as a failure and retried.
+func NewWebhookHandler(client *http.Client) Handler {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	return func(ctx context.Context, job *models.Job) error {
+		url, _ := job.Payload["url"].(string)
+		if url == "" {
+			return fmt.Errorf("webhook job has no url")
+		}
+
+		body, err := json.Marshal(job.Payload["body"])
+		if err != nil {
+			return fmt.Errorf("failed to encode webhook body: %w", err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to create webhook request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "agent-server/1.0")
+		req.Header.Set("X-Job-ID", job.ID)
+
+		if headers, ok := job.Payload["headers"].(map[string]interface{}); ok {
+			for key, value := range headers {
+				if s, ok := value.(string); ok {
+					req.Header.Set(key, s)
+				}
+			}
+		}
+
+		if secret, ok := job.Payload["secret"].(string); ok && secret != "" {
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write(body)
+			req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("webhook request failed: %w", err)
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+		}
+
+		return nil
+	}
+}
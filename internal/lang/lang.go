@@ -0,0 +1,157 @@
+// Package lang detects the language of chat messages and normalizes the
+// language tags of sessions, agents and requests.
+package lang
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// minHits is the number of common words a message needs before a language
+// written in Latin script is recognized
+const minHits = 2
+
+// commonWords are frequent short words of languages written in Latin script.
+// Words shared by several languages count for each of them.
+var commonWords = map[string][]string{
+	"en": {"the", "and", "is", "are", "you", "what", "how", "this", "that", "with", "for", "have", "not", "can", "of", "to", "it", "my", "i", "do", "does", "please", "your", "was", "be", "will"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "du", "sie", "wie", "was", "mit", "ein", "eine", "auf", "für", "bitte", "mein", "kann", "zu", "den", "dem", "es", "sind", "haben", "wir", "auch", "noch"},
+	"fr": {"le", "la", "les", "et", "est", "je", "vous", "tu", "un", "une", "des", "pas", "que", "qui", "pour", "dans", "avec", "ce", "comment", "mon", "sont", "il", "elle", "sur", "ne", "merci", "bonjour", "du", "au"},
+	"es": {"el", "la", "los", "las", "y", "es", "que", "de", "en", "un", "una", "por", "para", "con", "no", "yo", "cómo", "qué", "mi", "está", "son", "del", "al", "gracias", "hola", "se", "lo", "pero"},
+	"it": {"il", "lo", "la", "gli", "le", "e", "è", "che", "di", "un", "una", "per", "non", "io", "come", "sono", "mi", "con", "del", "della", "ciao", "grazie", "cosa", "questo", "ho"},
+	"pt": {"o", "a", "os", "as", "e", "é", "que", "de", "um", "uma", "não", "eu", "você", "como", "para", "com", "do", "da", "em", "obrigado", "olá", "meu", "são", "está"},
+	"nl": {"de", "het", "een", "en", "is", "niet", "ik", "je", "jij", "wat", "hoe", "van", "met", "voor", "op", "dat", "zijn", "mijn", "alsjeblieft", "bedankt", "ook", "er"},
+}
+
+// wordLanguages maps each common word to the languages it belongs to
+var wordLanguages = func() map[string][]string {
+	words := make(map[string][]string)
+	for code, list := range commonWords {
+		for _, word := range list {
+			words[word] = append(words[word], code)
+		}
+	}
+	return words
+}()
+
+// scripts are the writing systems that identify a language on their own
+var scripts = []struct {
+	table *unicode.RangeTable
+	code  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+}
+
+// Detect returns the ISO 639-1 code of the language text is written in, or ""
+// when the text is too short or too mixed to tell
+func Detect(text string) string {
+	letters, latin := 0, 0
+	counts := make(map[string]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, script := range scripts {
+			if unicode.Is(script.table, r) {
+				counts[script.code]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	// Japanese mixes kana with Han characters
+	if counts["ja"] > 0 {
+		counts["ja"] += counts["zh"]
+		delete(counts, "zh")
+	}
+	for code, n := range counts {
+		if n*2 > letters {
+			return code
+		}
+	}
+	if latin*2 <= letters {
+		return ""
+	}
+	return detectLatin(text)
+}
+
+// detectLatin scores text by the common words of each language
+func detectLatin(text string) string {
+	scores := make(map[string]int)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	for _, word := range words {
+		for _, code := range wordLanguages[word] {
+			scores[code]++
+		}
+	}
+
+	best, bestScore, runnerUp := "", 0, 0
+	for code, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, runnerUp = code, score, bestScore
+		case score > runnerUp:
+			runnerUp = score
+		}
+	}
+	if bestScore < minHits || bestScore == runnerUp {
+		return ""
+	}
+	return best
+}
+
+// Normalize returns the canonical form of a BCP 47 language tag, such as
+// "pt-BR" for "pt_br", or "" when tag is not a valid tag
+func Normalize(tag string) string {
+	parsed, err := language.Parse(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if err != nil {
+		return ""
+	}
+	return parsed.String()
+}
+
+// Base returns the language of a tag without its region or script, such as
+// "pt" for "pt-BR"
+func Base(tag string) string {
+	parsed, err := language.Parse(tag)
+	if err != nil {
+		return ""
+	}
+	base, _ := parsed.Base()
+	return base.String()
+}
+
+// Name returns the English name of a language tag, such as "Brazilian
+// Portuguese" for "pt-BR", falling back to the tag itself
+func Name(tag string) string {
+	parsed, err := language.Parse(tag)
+	if err != nil {
+		return tag
+	}
+	if name := display.English.Tags().Name(parsed); name != "" {
+		return name
+	}
+	return tag
+}
@@ -0,0 +1,41 @@
+package lang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"What is the weather like in Berlin today?", "en"},
+		{"Wie ist das Wetter heute in Berlin?", "de"},
+		{"Quel temps fait-il à Paris, et est-ce que je dois prendre un parapluie ?", "fr"},
+		{"¿Cómo está el tiempo en Madrid? Gracias por la ayuda.", "es"},
+		{"Ciao, come stai? Ho una domanda per te.", "it"},
+		{"Olá, você pode me ajudar com uma pergunta? Obrigado.", "pt"},
+		{"Hoe laat is het en wat is het weer in Amsterdam?", "nl"},
+		{"Какая сегодня погода в Москве?", "ru"},
+		{"今日の天気はどうですか？", "ja"},
+		{"今天天气怎么样？", "zh"},
+		{"오늘 날씨 어때요?", "ko"},
+		{"ok", ""},
+		{"12345 !!!", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Detect(tt.text), tt.text)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "pt-BR", Normalize("pt_br"))
+	assert.Equal(t, "de", Normalize(" DE "))
+	assert.Equal(t, "", Normalize("not a language"))
+	assert.Equal(t, "pt", Base("pt-BR"))
+	assert.Equal(t, "German", Name("de"))
+	assert.Equal(t, "Brazilian Portuguese", Name("pt-BR"))
+}
@@ -0,0 +1,81 @@
+package llm
+
+import "context"
+
+// PullProgress reports the state of a model download. Total and Completed
+// are byte counts of the layer being downloaded, zero while the provider
+// resolves the model.
+type PullProgress struct {
+	Status    string
+	Completed int64
+	Total     int64
+}
+
+// Puller is implemented by providers that can download models on request,
+// e.g. Ollama
+type Puller interface {
+	// Pull downloads model, reporting progress until it is ready
+	Pull(ctx context.Context, model string, progress func(PullProgress)) error
+}
+
+// ContextWindower is implemented by providers that can look up the context
+// window of a model
+type ContextWindower interface {
+	// ContextWindow returns the number of tokens the model accepts
+	ContextWindow(ctx context.Context, model string) (int, error)
+}
+
+// ToolChoiceForcer is implemented by providers whose API can make the model
+// call a tool. They accept ForcedToolChoice values as the "tool_choice"
+// option.
+type ToolChoiceForcer interface {
+	ForcesToolChoice() bool
+}
+
+// ForcesToolChoice reports whether the provider can force tool calls
+// natively; for other providers the caller has to prompt for the call and
+// check that it was made
+func ForcesToolChoice(provider Provider) bool {
+	forcer, ok := unwrapAs[ToolChoiceForcer](provider)
+	return ok && forcer.ForcesToolChoice()
+}
+
+// ForcedToolChoice returns the "tool_choice" option that forces a call of
+// tool, or of any tool when tool is empty, in the OpenAI format
+func ForcedToolChoice(tool string) interface{} {
+	if tool == "" {
+		return "required"
+	}
+	return map[string]interface{}{
+		"type":     "function",
+		"function": map[string]interface{}{"name": tool},
+	}
+}
+
+// AsPuller returns the provider as a Puller, looking through wrappers such
+// as the instrumented provider
+func AsPuller(provider Provider) (Puller, bool) {
+	return unwrapAs[Puller](provider)
+}
+
+// AsContextWindower returns the provider as a ContextWindower, looking
+// through wrappers
+func AsContextWindower(provider Provider) (ContextWindower, bool) {
+	return unwrapAs[ContextWindower](provider)
+}
+
+// unwrapAs returns the first of provider and the providers it wraps that
+// implements T
+func unwrapAs[T any](provider Provider) (T, bool) {
+	for {
+		if t, ok := provider.(T); ok {
+			return t, true
+		}
+		wrapper, ok := provider.(interface{ Unwrap() Provider })
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		provider = wrapper.Unwrap()
+	}
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Error is a failed provider request, classified by one of the Err
+// sentinels so that callers can branch with errors.Is
+type Error struct {
+	Provider   string
+	StatusCode int           // HTTP status of the provider's reply, 0 if there was none
+	Kind       error         // ErrRateLimited, ErrTimeout, ...; nil if unclassified
+	RetryAfter time.Duration // how long the provider asked callers to wait, 0 if it did not
+	Message    string
+	Err        error // the transport error, if the request was not answered
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s API error %d: %s", e.Provider, e.StatusCode, e.Message)
+	}
+	if e.Kind != nil {
+		return fmt.Sprintf("%s: %v: %s", e.Provider, e.Kind, e.Message)
+	}
+	return e.Provider + ": " + e.Message
+}
+
+// Unwrap returns the kind and the transport error
+func (e *Error) Unwrap() []error {
+	var errs []error
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// StatusKind classifies an HTTP status returned by a provider. Statuses that
+// need the reply body to tell apart, such as 404, return nil.
+func StatusKind(status int) error {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrAuthFailed
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return ErrTimeout
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// TransportError classifies a request to provider that got no reply: a
+// timeout or an unreachable provider
+func TransportError(provider string, err error) *Error {
+	kind := ErrUnavailable
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		kind = ErrTimeout
+	}
+	return &Error{Provider: provider, Kind: kind, Message: "failed to send request", Err: err}
+}
+
+// ParseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date; it returns 0 when the header is missing or invalid
+func ParseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// Retryable reports whether a request that failed with err may succeed if
+// repeated, possibly on another provider
+func Retryable(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrUnavailable) || errors.Is(err, ErrTimeout)
+}
+
+// RetryAfter returns how long the provider behind err asked callers to wait
+func RetryAfter(err error) time.Duration {
+	var providerErr *Error
+	if errors.As(err, &providerErr) {
+		return providerErr.RetryAfter
+	}
+	return 0
+}
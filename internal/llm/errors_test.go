@@ -0,0 +1,45 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError_Classification(t *testing.T) {
+	err := fmt.Errorf("LLM request failed: %w", &Error{
+		Provider:   "ollama",
+		StatusCode: http.StatusTooManyRequests,
+		Kind:       StatusKind(http.StatusTooManyRequests),
+		RetryAfter: ParseRetryAfter("30"),
+		Message:    "slow down",
+	})
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.True(t, Retryable(err))
+	assert.Equal(t, 30*time.Second, RetryAfter(err))
+	assert.Contains(t, err.Error(), "ollama API error 429: slow down")
+
+	timeout := TransportError("ollama", fmt.Errorf("read: %w", context.DeadlineExceeded))
+	assert.ErrorIs(t, timeout, ErrTimeout)
+	assert.ErrorIs(t, timeout, context.DeadlineExceeded)
+	refused := TransportError("ollama", errors.New("connection refused"))
+	assert.ErrorIs(t, refused, ErrUnavailable)
+	assert.Equal(t, "ollama: provider unavailable: failed to send request", refused.Error())
+
+	assert.ErrorIs(t, &Error{Kind: StatusKind(http.StatusUnauthorized)}, ErrAuthFailed)
+	assert.False(t, Retryable(&Error{Kind: ErrModelNotFound}))
+	assert.Nil(t, StatusKind(http.StatusNotFound))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, 2*time.Second, ParseRetryAfter("2"))
+	assert.Zero(t, ParseRetryAfter(""))
+	assert.Zero(t, ParseRetryAfter("soon"))
+	wait := ParseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.InDelta(t, time.Minute.Seconds(), wait.Seconds(), 2)
+}
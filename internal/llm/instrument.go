@@ -0,0 +1,176 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"agent-server/internal/metrics"
+)
+
+// Metadata keys the instrumented provider adds to responses. The chat service
+// persists response metadata with the assistant message.
+const (
+	MetadataRequestBytes  = "request_bytes"
+	MetadataResponseBytes = "response_bytes"
+	MetadataTTFTMs        = "ttft_ms"
+)
+
+// Metrics are the provider histograms, labelled by provider and model
+type Metrics struct {
+	requestBytes  *metrics.Histogram
+	responseBytes *metrics.Histogram
+	ttft          *metrics.Histogram
+	duration      *metrics.Histogram
+}
+
+// NewMetrics registers the provider histograms in registry
+func NewMetrics(registry *metrics.Registry) *Metrics {
+	sizes := metrics.ExponentialBuckets(256, 4, 9) // 256 B to 16 MiB
+	latencies := metrics.ExponentialBuckets(0.05, 2, 12)
+	return &Metrics{
+		requestBytes: registry.NewHistogram("agent_server_llm_request_bytes",
+			"Size of LLM requests, including context messages and tool definitions.", sizes, "provider", "model"),
+		responseBytes: registry.NewHistogram("agent_server_llm_response_bytes",
+			"Size of LLM responses: generated content and tool calls.", sizes, "provider", "model"),
+		ttft: registry.NewHistogram("agent_server_llm_time_to_first_token_seconds",
+			"Time from a streaming request to its first content.", latencies, "provider", "model"),
+		duration: registry.NewHistogram("agent_server_llm_request_duration_seconds",
+			"Total duration of LLM requests.", latencies, "provider", "model", "outcome"),
+	}
+}
+
+// Instrument wraps a provider so that the size and latency of its requests
+// are recorded in m and added to the response metadata
+func Instrument(provider Provider, m *Metrics) Provider {
+	return &instrumented{Provider: provider, metrics: m}
+}
+
+type instrumented struct {
+	Provider
+	metrics *Metrics
+}
+
+// SupportsTools reports whether the wrapped provider supports tools
+func (p *instrumented) SupportsTools() bool {
+	return SupportsTools(p.Provider)
+}
+
+// Unwrap returns the wrapped provider
+func (p *instrumented) Unwrap() Provider {
+	return p.Provider
+}
+
+func (p *instrumented) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	requestBytes := payloadSize(req)
+	start := time.Now()
+	response, err := p.Provider.Chat(ctx, req)
+	elapsed := time.Since(start)
+	if err != nil {
+		p.observe(req.Model, requestBytes, -1, 0, elapsed, err)
+		return nil, err
+	}
+
+	responseBytes := len(response.Content)
+	if calls, ok := response.Metadata["tool_calls"]; ok {
+		responseBytes += payloadSize(calls)
+	}
+	p.observe(req.Model, requestBytes, responseBytes, 0, elapsed, nil)
+
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata[MetadataRequestBytes] = requestBytes
+	response.Metadata[MetadataResponseBytes] = responseBytes
+	return response, nil
+}
+
+func (p *instrumented) Stream(ctx context.Context, req *ChatRequest) (<-chan StreamChunk, error) {
+	requestBytes := payloadSize(req)
+	start := time.Now()
+	chunks, err := p.Provider.Stream(ctx, req)
+	if err != nil {
+		p.observe(req.Model, requestBytes, -1, 0, time.Since(start), err)
+		return nil, err
+	}
+
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		var ttft time.Duration
+		responseBytes := 0
+		done := false
+		for chunk := range chunks {
+			if chunk.Content != "" && ttft == 0 {
+				ttft = time.Since(start)
+			}
+			responseBytes += len(chunk.Content)
+			if chunk.Done && !done {
+				done = true
+				p.observe(req.Model, requestBytes, responseBytes, ttft, time.Since(start), nil)
+				chunk.Metadata = withStreamMetadata(chunk.Metadata, requestBytes, responseBytes, ttft)
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				// Let the provider finish writing to its channel
+				for range chunks {
+				}
+				if !done {
+					p.observe(req.Model, requestBytes, -1, ttft, time.Since(start), ctx.Err())
+				}
+				return
+			}
+		}
+		if !done {
+			p.observe(req.Model, requestBytes, -1, ttft, time.Since(start), errStreamIncomplete)
+		}
+	}()
+	return out, nil
+}
+
+// errStreamIncomplete marks streams that ended without their final chunk
+var errStreamIncomplete = errors.New("stream ended before completion")
+
+// observe records one request; a negative response size means there was no
+// response and a zero ttft that the request did not stream content
+func (p *instrumented) observe(model string, requestBytes, responseBytes int, ttft, elapsed time.Duration, err error) {
+	name := p.Provider.Name()
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	p.metrics.requestBytes.Observe(float64(requestBytes), name, model)
+	if responseBytes >= 0 {
+		p.metrics.responseBytes.Observe(float64(responseBytes), name, model)
+	}
+	if ttft > 0 {
+		p.metrics.ttft.Observe(ttft.Seconds(), name, model)
+	}
+	p.metrics.duration.Observe(elapsed.Seconds(), name, model, outcome)
+}
+
+// withStreamMetadata returns a copy of the final chunk's metadata with the
+// request measurements added
+func withStreamMetadata(metadata map[string]interface{}, requestBytes, responseBytes int, ttft time.Duration) map[string]interface{} {
+	result := make(map[string]interface{}, len(metadata)+3)
+	for k, v := range metadata {
+		result[k] = v
+	}
+	result[MetadataRequestBytes] = requestBytes
+	result[MetadataResponseBytes] = responseBytes
+	if ttft > 0 {
+		result[MetadataTTFTMs] = ttft.Milliseconds()
+	}
+	return result
+}
+
+// payloadSize is the JSON size of v, or 0 when it cannot be encoded
+func payloadSize(v interface{}) int {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
@@ -0,0 +1,77 @@
+package llm_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/llm/mock"
+	"agent-server/internal/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrument_Chat(t *testing.T) {
+	registry := metrics.NewRegistry()
+	provider := llm.Instrument(mock.NewProvider(mock.Options{ReplyWords: 5}), llm.NewMetrics(registry))
+	assert.True(t, llm.SupportsTools(provider))
+
+	req := &llm.ChatRequest{Model: "m1", Messages: []llm.ChatMessage{{Role: "user", Content: "Hello"}}}
+	response, err := provider.Chat(context.Background(), req)
+	require.NoError(t, err)
+	assert.Greater(t, response.Metadata[llm.MetadataRequestBytes], 0)
+	assert.Equal(t, len(response.Content), response.Metadata[llm.MetadataResponseBytes])
+	assert.Equal(t, true, response.Metadata["mock"])
+
+	out := exposition(t, registry)
+	assert.Contains(t, out, `agent_server_llm_request_duration_seconds_count{provider="mock",model="m1",outcome="success"} 1`)
+	assert.Contains(t, out, `agent_server_llm_response_bytes_sum{provider="mock",model="m1"} 28`)
+	assert.NotContains(t, out, "agent_server_llm_time_to_first_token_seconds_count")
+}
+
+func TestInstrument_ChatError(t *testing.T) {
+	registry := metrics.NewRegistry()
+	provider := llm.Instrument(mock.NewProvider(mock.Options{ErrorRate: 1}), llm.NewMetrics(registry))
+
+	_, err := provider.Chat(context.Background(), &llm.ChatRequest{Model: "m1"})
+	assert.True(t, errors.Is(err, llm.ErrUnavailable))
+
+	out := exposition(t, registry)
+	assert.Contains(t, out, `agent_server_llm_request_duration_seconds_count{provider="mock",model="m1",outcome="error"} 1`)
+	assert.NotContains(t, out, "agent_server_llm_response_bytes_count")
+}
+
+func TestInstrument_Stream(t *testing.T) {
+	registry := metrics.NewRegistry()
+	provider := llm.Instrument(mock.NewProvider(mock.Options{ReplyWords: 5}), llm.NewMetrics(registry))
+
+	req := &llm.ChatRequest{Model: "m1", Messages: []llm.ChatMessage{{Role: "user", Content: "Hello"}}}
+	chunks, err := provider.Stream(context.Background(), req)
+	require.NoError(t, err)
+
+	var content string
+	var final llm.StreamChunk
+	for chunk := range chunks {
+		content += chunk.Content
+		if chunk.Done {
+			final = chunk
+		}
+	}
+	require.True(t, final.Done)
+	assert.Equal(t, len(content), final.Metadata[llm.MetadataResponseBytes])
+	assert.Greater(t, final.Metadata[llm.MetadataRequestBytes], 0)
+
+	out := exposition(t, registry)
+	assert.Contains(t, out, `agent_server_llm_time_to_first_token_seconds_count{provider="mock",model="m1"} 1`)
+	assert.Contains(t, out, `agent_server_llm_request_duration_seconds_count{provider="mock",model="m1",outcome="success"} 1`)
+}
+
+func exposition(t *testing.T, registry *metrics.Registry) string {
+	var buf bytes.Buffer
+	_, err := registry.WriteTo(&buf)
+	require.NoError(t, err)
+	return buf.String()
+}
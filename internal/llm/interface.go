@@ -2,10 +2,27 @@ package llm
 
 import (
 	"context"
+	"errors"
 
 	"agent-server/internal/models"
 )
 
+// Errors providers wrap so callers can tell failure causes apart
+var (
+	// ErrUnavailable means the provider could not be reached or is overloaded
+	ErrUnavailable = errors.New("provider unavailable")
+	// ErrContextOverflow means the prompt exceeds the model's context window
+	ErrContextOverflow = errors.New("context window exceeded")
+	// ErrRateLimited means the provider refused the request until later
+	ErrRateLimited = errors.New("provider rate limit exceeded")
+	// ErrModelNotFound means the provider does not have the requested model
+	ErrModelNotFound = errors.New("model not found")
+	// ErrAuthFailed means the provider rejected the credentials
+	ErrAuthFailed = errors.New("provider authentication failed")
+	// ErrTimeout means the provider did not answer in time
+	ErrTimeout = errors.New("provider timed out")
+)
+
 // ChatMessage represents a message in the LLM chat format
 type ChatMessage struct {
 	Role    string `json:"role"`
@@ -19,6 +36,7 @@ type ChatRequest struct {
 	Temperature float32       `json:"temperature,omitempty"`
 	MaxTokens   int           `json:"max_tokens,omitempty"`
 	Stream      bool          `json:"stream,omitempty"`
+	Stop        []string      `json:"stop,omitempty"` // Sequences that end generation
 	Options     map[string]interface{} `json:"options,omitempty"`
 }
 
@@ -38,6 +56,16 @@ type Usage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
+// Add accumulates other into u; a nil other is ignored
+func (u *Usage) Add(other *Usage) {
+	if other == nil {
+		return
+	}
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+}
+
 // StreamChunk represents a chunk of streaming response
 type StreamChunk struct {
 	Content      string                 `json:"content"`
@@ -45,6 +73,7 @@ type StreamChunk struct {
 	Model        string                 `json:"model,omitempty"`
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
 	FinishReason string                 `json:"finish_reason,omitempty"`
+	Usage        *Usage                 `json:"usage,omitempty"` // Usually only set on the final chunk
 }
 
 // Provider defines the interface for LLM providers
@@ -68,6 +97,18 @@ type Provider interface {
 	IsAvailable(ctx context.Context) bool
 }
 
+// ToolCaller is implemented by providers that pass tool definitions to the
+// model and return its tool calls (function calling)
+type ToolCaller interface {
+	SupportsTools() bool
+}
+
+// SupportsTools reports whether the provider implements function calling
+func SupportsTools(provider Provider) bool {
+	caller, ok := provider.(ToolCaller)
+	return ok && caller.SupportsTools()
+}
+
 // Registry manages LLM providers
 type Registry struct {
 	providers map[string]Provider
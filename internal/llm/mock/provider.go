@@ -0,0 +1,265 @@
+// Package mock implements an LLM provider that answers without a model. Its
+// replies are generated text with configurable latency and failure rate, so
+// the server can be load-tested and developed against without GPU or API
+// costs.
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+)
+
+// Name is the provider name agents use to select the mock provider
+const Name = "mock"
+
+// ToolDirective starts a line of a user message that makes the mock call a
+// tool, e.g. `/tool calculator {"expression": "2+2"}`. The tool must be
+// offered to the model in the request.
+const ToolDirective = "/tool"
+
+// words are the filler of generated replies
+var words = strings.Fields(`the agent server answers this request with a
+generated reply so that sessions messages tools and streaming can be measured
+under load without calling a real language model`)
+
+// Options tune the simulated model
+type Options struct {
+	Latency     time.Duration // before the reply, or its first streamed word
+	Jitter      time.Duration // random extra latency, up to this much
+	StreamDelay time.Duration // between streamed words
+	ReplyWords  int           // length of generated replies
+	ErrorRate   float64       // share of requests that fail with llm.ErrUnavailable
+}
+
+// Provider is the mock LLM provider
+type Provider struct {
+	opts Options
+}
+
+// NewProvider creates a mock provider
+func NewProvider(opts Options) *Provider {
+	if opts.ReplyWords <= 0 {
+		opts.ReplyWords = 40
+	}
+	return &Provider{opts: opts}
+}
+
+// Name returns the provider name
+func (p *Provider) Name() string {
+	return Name
+}
+
+// Chat answers after the configured latency. A user message with a tool
+// directive is answered with a call of that tool; a tool result is answered
+// with a reply that quotes it. Replies end before the first stop sequence.
+func (p *Provider) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	response := &llm.ChatResponse{
+		Model:        req.Model,
+		FinishReason: "stop",
+		Metadata:     map[string]interface{}{"mock": true},
+	}
+	if call := toolCall(req); call != nil {
+		response.Metadata["tool_calls"] = []map[string]interface{}{call}
+		response.FinishReason = "tool_calls"
+		response.Usage = usage(req.Messages, 0)
+		return response, nil
+	}
+
+	reply := cutAtStop(p.reply(req.Messages), req.Stop)
+	response.Content = strings.Join(reply, " ")
+	response.Usage = usage(req.Messages, len(reply))
+	return response, nil
+}
+
+// Stream answers like Chat, one word per chunk
+func (p *Provider) Stream(ctx context.Context, req *llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	reply := cutAtStop(p.reply(req.Messages), req.Stop)
+	chunks := make(chan llm.StreamChunk)
+	go func() {
+		defer close(chunks)
+		for i, word := range reply {
+			if i > 0 {
+				word = " " + word
+				if !sleep(ctx, p.opts.StreamDelay) {
+					return
+				}
+			}
+			select {
+			case chunks <- llm.StreamChunk{Content: word, Model: req.Model}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case chunks <- llm.StreamChunk{Done: true, Model: req.Model, FinishReason: "stop", Usage: usage(req.Messages, len(reply))}:
+		case <-ctx.Done():
+		}
+	}()
+	return chunks, nil
+}
+
+// Models returns the model names the mock answers to; any name works
+func (p *Provider) Models(ctx context.Context) ([]string, error) {
+	return []string{"mock"}, nil
+}
+
+// ValidateConfig accepts any agent configuration
+func (p *Provider) ValidateConfig(config map[string]interface{}) error {
+	return nil
+}
+
+// SupportsTools reports that the mock calls tools on request
+func (p *Provider) SupportsTools() bool {
+	return true
+}
+
+// ForcesToolChoice reports that the mock honors a forced tool_choice
+func (p *Provider) ForcesToolChoice() bool {
+	return true
+}
+
+// IsAvailable reports that the mock is always available
+func (p *Provider) IsAvailable(ctx context.Context) bool {
+	return true
+}
+
+// wait simulates the model's latency and fails the configured share of
+// requests
+func (p *Provider) wait(ctx context.Context) error {
+	delay := p.opts.Latency
+	if p.opts.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(p.opts.Jitter)))
+	}
+	if !sleep(ctx, delay) {
+		return ctx.Err()
+	}
+	if p.opts.ErrorRate > 0 && rand.Float64() < p.opts.ErrorRate {
+		return fmt.Errorf("%w: simulated mock failure", llm.ErrUnavailable)
+	}
+	return nil
+}
+
+// reply generates the words of a reply to the last message
+func (p *Provider) reply(messages []llm.ChatMessage) []string {
+	reply := []string{"Mock", "reply:"}
+	if n := len(messages); n > 0 && messages[n-1].Role == "tool" {
+		reply = append(reply, "the", "tool", "returned", truncate(messages[n-1].Content, 80))
+	}
+	for i := 0; len(reply) < p.opts.ReplyWords; i++ {
+		reply = append(reply, words[i%len(words)])
+	}
+	return reply
+}
+
+// cutAtStop ends a reply before the first of the stop sequences
+func cutAtStop(reply, stop []string) []string {
+	text := strings.Join(reply, " ")
+	end := len(text)
+	for _, sequence := range stop {
+		if i := strings.Index(text, sequence); i >= 0 && i < end {
+			end = i
+		}
+	}
+	if end == len(text) {
+		return reply
+	}
+	return strings.Fields(text[:end])
+}
+
+// toolCall returns the call a tool directive in the last user message asks
+// for, in the format the chat service parses, or nil. Without a directive, a
+// forced tool_choice makes it call the forced tool, or the first one offered,
+// without arguments.
+func toolCall(req *llm.ChatRequest) map[string]interface{} {
+	tools, _ := req.Options["tools"].([]models.ToolDefinition)
+	n := len(req.Messages)
+	if len(tools) == 0 || n == 0 || req.Messages[n-1].Role != "user" {
+		return nil
+	}
+
+	for _, line := range strings.Split(req.Messages[n-1].Content, "\n") {
+		rest, ok := strings.CutPrefix(strings.TrimSpace(line), ToolDirective+" ")
+		if !ok {
+			continue
+		}
+		name, args, _ := strings.Cut(strings.TrimSpace(rest), " ")
+		arguments := map[string]interface{}{}
+		if strings.TrimSpace(args) != "" && json.Unmarshal([]byte(args), &arguments) != nil {
+			continue
+		}
+		for _, tool := range tools {
+			if tool.Function.Name == name {
+				return map[string]interface{}{
+					"function": map[string]interface{}{"name": name, "arguments": arguments},
+				}
+			}
+		}
+	}
+
+	forced := ""
+	switch choice := req.Options["tool_choice"].(type) {
+	case string:
+		if choice != "required" {
+			return nil
+		}
+		forced = tools[0].Function.Name
+	case map[string]interface{}:
+		function, _ := choice["function"].(map[string]interface{})
+		forced, _ = function["name"].(string)
+	}
+	for _, tool := range tools {
+		if forced != "" && tool.Function.Name == forced {
+			return map[string]interface{}{
+				"function": map[string]interface{}{"name": forced, "arguments": map[string]interface{}{}},
+			}
+		}
+	}
+	return nil
+}
+
+// usage counts words as tokens
+func usage(messages []llm.ChatMessage, completion int) *llm.Usage {
+	prompt := 0
+	for _, message := range messages {
+		prompt += len(strings.Fields(message.Content))
+	}
+	return &llm.Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+}
+
+func truncate(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > max {
+		return s[:max] + "…"
+	}
+	return s
+}
+
+// sleep waits for d and reports false when ctx ends first
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,114 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider_Chat(t *testing.T) {
+	provider := NewProvider(Options{ReplyWords: 8})
+	tools := []models.ToolDefinition{{Type: "function", Function: models.ToolFunctionDefinition{Name: "calculator"}}}
+
+	// A plain message gets a generated reply
+	response, err := provider.Chat(context.Background(), &llm.ChatRequest{
+		Model:    "mock",
+		Messages: []llm.ChatMessage{{Role: "user", Content: "hello there"}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, strings.Fields(response.Content), 8)
+	assert.Equal(t, &llm.Usage{PromptTokens: 2, CompletionTokens: 8, TotalTokens: 10}, response.Usage)
+
+	// A tool directive is answered with a tool call when the tool is offered
+	request := &llm.ChatRequest{
+		Messages: []llm.ChatMessage{{Role: "user", Content: "Compute this\n/tool calculator {\"expression\": \"2+2\"}"}},
+		Options:  map[string]interface{}{"tools": tools},
+	}
+	response, err = provider.Chat(context.Background(), request)
+	require.NoError(t, err)
+	assert.Equal(t, "tool_calls", response.FinishReason)
+	calls := response.Metadata["tool_calls"].([]map[string]interface{})
+	require.Len(t, calls, 1)
+	assert.Equal(t, map[string]interface{}{
+		"name":      "calculator",
+		"arguments": map[string]interface{}{"expression": "2+2"},
+	}, calls[0]["function"])
+
+	// The tool result ends the turn
+	request.Messages = append(request.Messages,
+		llm.ChatMessage{Role: "assistant"},
+		llm.ChatMessage{Role: "tool", Content: `{"result": 4}`})
+	response, err = provider.Chat(context.Background(), request)
+	require.NoError(t, err)
+	assert.Nil(t, response.Metadata["tool_calls"])
+	assert.Contains(t, response.Content, `{"result": 4}`)
+
+	// Without the tool on offer the directive is ignored
+	request.Messages = request.Messages[:1]
+	request.Options = nil
+	response, err = provider.Chat(context.Background(), request)
+	require.NoError(t, err)
+	assert.Nil(t, response.Metadata["tool_calls"])
+
+	// A forced tool choice is called without a directive
+	request.Messages = []llm.ChatMessage{{Role: "user", Content: "hello there"}}
+	request.Options = map[string]interface{}{"tools": tools, "tool_choice": llm.ForcedToolChoice("calculator")}
+	response, err = provider.Chat(context.Background(), request)
+	require.NoError(t, err)
+	calls = response.Metadata["tool_calls"].([]map[string]interface{})
+	require.Len(t, calls, 1)
+	assert.Equal(t, "calculator", calls[0]["function"].(map[string]interface{})["name"])
+	assert.True(t, llm.ForcesToolChoice(provider))
+}
+
+func TestProvider_Stream(t *testing.T) {
+	provider := NewProvider(Options{ReplyWords: 5})
+	chunks, err := provider.Stream(context.Background(), &llm.ChatRequest{
+		Messages: []llm.ChatMessage{{Role: "user", Content: "hi"}},
+	})
+	require.NoError(t, err)
+
+	var text strings.Builder
+	var last llm.StreamChunk
+	for chunk := range chunks {
+		text.WriteString(chunk.Content)
+		last = chunk
+	}
+	assert.True(t, last.Done)
+	assert.Equal(t, 5, last.Usage.CompletionTokens)
+	assert.Len(t, strings.Fields(text.String()), 5)
+}
+
+func TestProvider_Stop(t *testing.T) {
+	provider := NewProvider(Options{ReplyWords: 10})
+	request := &llm.ChatRequest{
+		Messages: []llm.ChatMessage{{Role: "user", Content: "hi"}},
+		Stop:     []string{"answers", "server"},
+	}
+
+	response, err := provider.Chat(context.Background(), request)
+	require.NoError(t, err)
+	assert.Equal(t, "Mock reply: the agent", response.Content)
+	assert.Equal(t, "stop", response.FinishReason)
+
+	chunks, err := provider.Stream(context.Background(), request)
+	require.NoError(t, err)
+	var text strings.Builder
+	for chunk := range chunks {
+		text.WriteString(chunk.Content)
+	}
+	assert.Equal(t, "Mock reply: the agent", text.String())
+}
+
+func TestProvider_ErrorRate(t *testing.T) {
+	provider := NewProvider(Options{ErrorRate: 1})
+	_, err := provider.Chat(context.Background(), &llm.ChatRequest{})
+	assert.True(t, errors.Is(err, llm.ErrUnavailable))
+}
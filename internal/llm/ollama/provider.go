@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,8 +20,9 @@ import (
 
 // Provider implements the LLM provider interface for Ollama
 type Provider struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL        string
+	httpClient     *http.Client
+	requestTimeout time.Duration // non-streaming requests only
 }
 
 // NewProvider creates a new Ollama provider
@@ -29,14 +31,35 @@ func NewProvider(baseURL string) *Provider {
 		baseURL = "http://localhost:11434"
 	}
 
+	opts := llm.DefaultHTTPOptions()
 	return &Provider{
-		baseURL: strings.TrimSuffix(baseURL, "/"),
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second,
-		},
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		httpClient:     llm.NewHTTPClient(opts),
+		requestTimeout: opts.RequestTimeout,
 	}
 }
 
+// SetTransport sets the transport used for requests to the Ollama API, e.g.
+// to route them through an egress proxy
+func (p *Provider) SetTransport(transport http.RoundTripper) {
+	p.httpClient.Transport = transport
+}
+
+// SetRequestTimeout bounds the total time of non-streaming requests; streams
+// run until the model finishes or the caller cancels. Zero disables the
+// timeout.
+func (p *Provider) SetRequestTimeout(timeout time.Duration) {
+	p.requestTimeout = timeout
+}
+
+// withRequestTimeout applies the request timeout to ctx
+func (p *Provider) withRequestTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.requestTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.requestTimeout)
+}
+
 // Name returns the provider name
 func (p *Provider) Name() string {
 	return "ollama"
@@ -52,13 +75,37 @@ type ollamaChatRequest struct {
 	ToolChoice interface{}          `json:"tool_choice,omitempty"`
 }
 
-// ollamaChatResponse represents the response format from Ollama chat API
+// ollamaChatResponse represents the response format from Ollama chat API.
+// Token counts are only reported on the final (done) response.
 type ollamaChatResponse struct {
-	Model     string            `json:"model"`
-	Message   ollamaMessage     `json:"message"`
-	Done      bool              `json:"done"`
-	CreatedAt time.Time         `json:"created_at"`
-	Usage     *ollamaUsage      `json:"usage,omitempty"`
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason,omitempty"` // "stop", "length", ...
+	CreatedAt       time.Time     `json:"created_at"`
+	PromptEvalCount int           `json:"prompt_eval_count,omitempty"`
+	EvalCount       int           `json:"eval_count,omitempty"`
+	Usage           *ollamaUsage  `json:"usage,omitempty"`
+}
+
+// usage maps Ollama's native token counters to llm.Usage, falling back to
+// an OpenAI-style usage object as returned by compatible proxies
+func (r *ollamaChatResponse) usage() *llm.Usage {
+	if r.PromptEvalCount > 0 || r.EvalCount > 0 {
+		return &llm.Usage{
+			PromptTokens:     r.PromptEvalCount,
+			CompletionTokens: r.EvalCount,
+			TotalTokens:      r.PromptEvalCount + r.EvalCount,
+		}
+	}
+	if r.Usage != nil {
+		return &llm.Usage{
+			PromptTokens:     r.Usage.PromptTokens,
+			CompletionTokens: r.Usage.CompletionTokens,
+			TotalTokens:      r.Usage.TotalTokens,
+		}
+	}
+	return nil
 }
 
 // ollamaMessage represents a message in Ollama format
@@ -127,6 +174,9 @@ func (p *Provider) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatRes
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
+	ctx, cancel := p.withRequestTimeout(ctx)
+	defer cancel()
+
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/chat", bytes.NewReader(reqBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -136,13 +186,13 @@ func (p *Provider) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatRes
 
 	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, llm.TransportError(p.Name(), err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama API error %d: %s", resp.StatusCode, string(body))
+		return nil, apiError(resp, body)
 	}
 
 	var ollamaResp ollamaChatResponse
@@ -151,8 +201,9 @@ func (p *Provider) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatRes
 	}
 
 	response := &llm.ChatResponse{
-		Content: ollamaResp.Message.Content,
-		Model:   ollamaResp.Model,
+		Content:      ollamaResp.Message.Content,
+		Model:        ollamaResp.Model,
+		FinishReason: ollamaResp.DoneReason,
 		Metadata: map[string]interface{}{
 			"created_at": ollamaResp.CreatedAt,
 		},
@@ -172,13 +223,7 @@ func (p *Provider) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatRes
 		response.Metadata["tool_calls"] = toolCalls
 	}
 
-	if ollamaResp.Usage != nil {
-		response.Usage = &llm.Usage{
-			PromptTokens:     ollamaResp.Usage.PromptTokens,
-			CompletionTokens: ollamaResp.Usage.CompletionTokens,
-			TotalTokens:      ollamaResp.Usage.TotalTokens,
-		}
-	}
+	response.Usage = ollamaResp.usage()
 
 	return response, nil
 }
@@ -221,13 +266,13 @@ func (p *Provider) Stream(ctx context.Context, req *llm.ChatRequest) (<-chan llm
 
 	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, llm.TransportError(p.Name(), err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama API error %d: %s", resp.StatusCode, string(body))
+		resp.Body.Close()
+		return nil, apiError(resp, body)
 	}
 
 	chunks := make(chan llm.StreamChunk, 10)
@@ -257,6 +302,10 @@ func (p *Provider) Stream(ctx context.Context, req *llm.ChatRequest) (<-chan llm
 					"created_at": ollamaResp.CreatedAt,
 				},
 			}
+			if ollamaResp.Done {
+				chunk.Usage = ollamaResp.usage()
+				chunk.FinishReason = ollamaResp.DoneReason
+			}
 
 			select {
 			case chunks <- chunk:
@@ -279,6 +328,9 @@ func (p *Provider) Stream(ctx context.Context, req *llm.ChatRequest) (<-chan llm
 
 // Models returns the list of available models from Ollama
 func (p *Provider) Models(ctx context.Context) ([]string, error) {
+	ctx, cancel := p.withRequestTimeout(ctx)
+	defer cancel()
+
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/api/tags", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -286,13 +338,13 @@ func (p *Provider) Models(ctx context.Context) ([]string, error) {
 
 	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, llm.TransportError(p.Name(), err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama API error %d: %s", resp.StatusCode, string(body))
+		return nil, apiError(resp, body)
 	}
 
 	var modelsResp ollamaModelsResponse
@@ -308,6 +360,126 @@ func (p *Provider) Models(ctx context.Context) ([]string, error) {
 	return models, nil
 }
 
+// ollamaPullResponse is a progress line of the pull API
+type ollamaPullResponse struct {
+	Status    string `json:"status"`
+	Completed int64  `json:"completed,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Pull downloads model from the Ollama library, reporting each progress
+// line. Downloads are not bounded by the request timeout.
+func (p *Provider) Pull(ctx context.Context, model string, progress func(llm.PullProgress)) error {
+	reqBody, err := json.Marshal(map[string]interface{}{"model": model, "stream": true})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/pull", bytes.NewReader(reqBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return llm.TransportError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return apiError(resp, body)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var update ollamaPullResponse
+		if err := json.Unmarshal(line, &update); err != nil {
+			return fmt.Errorf("failed to decode pull progress: %w", err)
+		}
+		if update.Error != "" {
+			return &llm.Error{Provider: p.Name(), Message: update.Error}
+		}
+		if progress != nil {
+			progress(llm.PullProgress{Status: update.Status, Completed: update.Completed, Total: update.Total})
+		}
+		if update.Status == "success" {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return llm.TransportError(p.Name(), err)
+	}
+	return fmt.Errorf("pull of %s ended before completion", model)
+}
+
+// ollamaShowResponse is the part of the show API's reply that describes the
+// model's context window
+type ollamaShowResponse struct {
+	Parameters string                 `json:"parameters"`
+	ModelInfo  map[string]interface{} `json:"model_info"`
+}
+
+// ContextWindow looks up the context window of model: the num_ctx
+// parameter of its Modelfile if set, otherwise the context length it was
+// trained with
+func (p *Provider) ContextWindow(ctx context.Context, model string) (int, error) {
+	ctx, cancel := p.withRequestTimeout(ctx)
+	defer cancel()
+
+	reqBody, err := json.Marshal(map[string]string{"model": model})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/show", bytes.NewReader(reqBody))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, llm.TransportError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, apiError(resp, body)
+	}
+
+	var show ollamaShowResponse
+	if err := json.NewDecoder(resp.Body).Decode(&show); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	// Parameters are Modelfile lines such as "num_ctx 8192"
+	for _, line := range strings.Split(show.Parameters, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "num_ctx" {
+			if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+				return n, nil
+			}
+		}
+	}
+	// Model info keys are prefixed with the architecture, e.g. "llama.context_length"
+	for key, value := range show.ModelInfo {
+		if strings.HasSuffix(key, ".context_length") {
+			if n, ok := value.(float64); ok && n > 0 {
+				return int(n), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("ollama does not report a context window for %s", model)
+}
+
 // ValidateConfig validates Ollama-specific configuration
 func (p *Provider) ValidateConfig(config map[string]interface{}) error {
 	// Ollama doesn't require API keys, so just validate structure
@@ -335,8 +507,17 @@ func (p *Provider) ValidateConfig(config map[string]interface{}) error {
 	return nil
 }
 
+// SupportsTools reports that Ollama accepts tool definitions; whether the
+// model uses them depends on the model
+func (p *Provider) SupportsTools() bool {
+	return true
+}
+
 // IsAvailable checks if Ollama is available
 func (p *Provider) IsAvailable(ctx context.Context) bool {
+	ctx, cancel := p.withRequestTimeout(ctx)
+	defer cancel()
+
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/api/tags", nil)
 	if err != nil {
 		return false
@@ -363,10 +544,36 @@ func (p *Provider) buildOptions(req *llm.ChatRequest) map[string]interface{} {
 		options["num_predict"] = req.MaxTokens
 	}
 
+	if len(req.Stop) > 0 {
+		options["stop"] = req.Stop
+	}
+
 	// Add any additional options from the request
 	for k, v := range req.Options {
 		options[k] = v
 	}
 
 	return options
-}
\ No newline at end of file
+}
+
+// apiError turns a non-200 Ollama response into a classified provider error,
+// so callers can report rate limits, missing models and context-length
+// failures precisely
+func apiError(resp *http.Response, body []byte) error {
+	err := &llm.Error{
+		Provider:   "ollama",
+		StatusCode: resp.StatusCode,
+		Kind:       llm.StatusKind(resp.StatusCode),
+		RetryAfter: llm.ParseRetryAfter(resp.Header.Get("Retry-After")),
+		Message:    string(body),
+	}
+	message := strings.ToLower(string(body))
+	switch {
+	case strings.Contains(message, "context length"), strings.Contains(message, "context window"):
+		err.Kind = llm.ErrContextOverflow
+	case resp.StatusCode == http.StatusNotFound && strings.Contains(message, "model"):
+		// e.g. model "llama3" not found, try pulling it first
+		err.Kind = llm.ErrModelNotFound
+	}
+	return err
+}
@@ -2,12 +2,14 @@ package ollama
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"agent-server/internal/llm"
+	"agent-server/internal/metrics"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -151,6 +153,15 @@ func TestProvider_BuildOptions(t *testing.T) {
 				"top_p":       0.9,
 			},
 		},
+		{
+			name: "request with stop sequences",
+			request: &llm.ChatRequest{
+				Stop: []string{"\nUser:", "###"},
+			},
+			expected: map[string]interface{}{
+				"stop": []string{"\nUser:", "###"},
+			},
+		},
 		{
 			name: "empty request",
 			request: &llm.ChatRequest{},
@@ -217,6 +228,31 @@ func TestProvider_Chat(t *testing.T) {
 	assert.Equal(t, 18, response.Usage.TotalTokens)
 }
 
+func TestProvider_Chat_NativeUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"model": "llama3",
+			"message": {"role": "assistant", "content": "Hi"},
+			"done": true,
+			"done_reason": "length",
+			"prompt_eval_count": 42,
+			"eval_count": 7
+		}`))
+	}))
+	defer server.Close()
+
+	provider := NewProvider(server.URL)
+	response, err := provider.Chat(context.Background(), &llm.ChatRequest{
+		Model:    "llama3",
+		Messages: []llm.ChatMessage{{Role: "user", Content: "Hello"}},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, &llm.Usage{PromptTokens: 42, CompletionTokens: 7, TotalTokens: 49}, response.Usage)
+	assert.Equal(t, "length", response.FinishReason)
+}
+
 func TestProvider_Chat_Error(t *testing.T) {
 	// Create a mock server that returns an error
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -241,6 +277,39 @@ func TestProvider_Chat_Error(t *testing.T) {
 	assert.Contains(t, err.Error(), "ollama API error 500")
 }
 
+func TestProvider_ClassifiesErrors(t *testing.T) {
+	// reply starts a server answering every request with status and body
+	reply := func(status int, header, body string) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if header != "" {
+				w.Header().Set("Retry-After", header)
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}))
+		t.Cleanup(server.Close)
+		return server.URL
+	}
+	request := &llm.ChatRequest{Model: "llama9", Messages: []llm.ChatMessage{{Role: "user", Content: "Hi"}}}
+	ctx := context.Background()
+
+	_, err := NewProvider(reply(http.StatusNotFound, "", `{"error":"model \"llama9\" not found, try pulling it first"}`)).Chat(ctx, request)
+	assert.ErrorIs(t, err, llm.ErrModelNotFound)
+
+	_, err = NewProvider(reply(http.StatusTooManyRequests, "7", "")).Stream(ctx, request)
+	assert.ErrorIs(t, err, llm.ErrRateLimited)
+	assert.Equal(t, 7*time.Second, llm.RetryAfter(err))
+
+	_, err = NewProvider(reply(http.StatusUnauthorized, "", "")).Chat(ctx, request)
+	assert.ErrorIs(t, err, llm.ErrAuthFailed)
+
+	// Nothing listens on a closed server
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	_, err = NewProvider(closed.URL).Chat(ctx, request)
+	assert.ErrorIs(t, err, llm.ErrUnavailable)
+}
+
 func TestProvider_Models(t *testing.T) {
 	// Create a mock server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -279,6 +348,70 @@ func TestProvider_Models(t *testing.T) {
 	assert.Contains(t, models, "codellama")
 }
 
+func TestProvider_Pull(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/pull", r.URL.Path)
+		var req map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req["model"] == "missing" {
+			w.Write([]byte(`{"status":"pulling manifest"}` + "\n" + `{"error":"pull model manifest: file does not exist"}` + "\n"))
+			return
+		}
+		w.Write([]byte(`{"status":"pulling manifest"}` + "\n" +
+			`{"status":"pulling 6a0746a1ec1a","digest":"sha256:6a07","total":100,"completed":40}` + "\n" +
+			`{"status":"success"}` + "\n"))
+	}))
+	defer server.Close()
+
+	provider := NewProvider(server.URL)
+	var updates []llm.PullProgress
+	err := provider.Pull(context.Background(), "llama3", func(p llm.PullProgress) {
+		updates = append(updates, p)
+	})
+	require.NoError(t, err)
+	require.Len(t, updates, 3)
+	assert.Equal(t, llm.PullProgress{Status: "pulling 6a0746a1ec1a", Completed: 40, Total: 100}, updates[1])
+
+	err = provider.Pull(context.Background(), "missing", nil)
+	assert.ErrorContains(t, err, "file does not exist")
+
+	// The instrumented wrapper still exposes pulls
+	_, ok := llm.AsPuller(llm.Instrument(provider, llm.NewMetrics(metrics.NewRegistry())))
+	assert.True(t, ok)
+}
+
+func TestProvider_ContextWindow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/show", r.URL.Path)
+		var req map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch req["model"] {
+		case "tuned":
+			w.Write([]byte(`{"parameters":"stop \"<|eot_id|>\"\nnum_ctx 16384","model_info":{"llama.context_length":131072}}`))
+		case "llama3":
+			w.Write([]byte(`{"model_info":{"general.architecture":"llama","llama.context_length":8192}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"model '` + req["model"] + `' not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := NewProvider(server.URL)
+	ctx := context.Background()
+
+	window, err := provider.ContextWindow(ctx, "tuned")
+	require.NoError(t, err)
+	assert.Equal(t, 16384, window)
+
+	window, err = provider.ContextWindow(ctx, "llama3")
+	require.NoError(t, err)
+	assert.Equal(t, 8192, window)
+
+	_, err = provider.ContextWindow(ctx, "missing")
+	assert.ErrorIs(t, err, llm.ErrModelNotFound)
+}
+
 func TestProvider_IsAvailable(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -330,7 +463,7 @@ func TestProvider_Stream(t *testing.T) {
 		responses := []string{
 			`{"model":"llama2","message":{"role":"assistant","content":"Hello"},"done":false,"created_at":"2023-01-01T00:00:00Z"}`,
 			`{"model":"llama2","message":{"role":"assistant","content":" there"},"done":false,"created_at":"2023-01-01T00:00:00Z"}`,
-			`{"model":"llama2","message":{"role":"assistant","content":"!"},"done":true,"created_at":"2023-01-01T00:00:00Z"}`,
+			`{"model":"llama2","message":{"role":"assistant","content":"!"},"done":true,"done_reason":"stop","created_at":"2023-01-01T00:00:00Z","prompt_eval_count":26,"eval_count":3}`,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -374,4 +507,45 @@ func TestProvider_Stream(t *testing.T) {
 	assert.Equal(t, " there", receivedChunks[1].Content)
 	assert.Equal(t, "!", receivedChunks[2].Content)
 	assert.True(t, receivedChunks[2].Done)
-}
\ No newline at end of file
+	assert.Nil(t, receivedChunks[0].Usage)
+	assert.Equal(t, &llm.Usage{PromptTokens: 26, CompletionTokens: 3, TotalTokens: 29}, receivedChunks[2].Usage)
+	assert.Equal(t, "stop", receivedChunks[2].FinishReason)
+}
+func TestProvider_RequestTimeoutSparesStreams(t *testing.T) {
+	// Answers take longer than the request timeout
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if !req.Stream {
+			time.Sleep(100 * time.Millisecond)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		for _, content := range []string{"slow", " reply"} {
+			w.Write([]byte(`{"model":"llama2","message":{"role":"assistant","content":"` + content + `"},"done":false}` + "\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.Write([]byte(`{"model":"llama2","message":{"role":"assistant","content":""},"done":true}` + "\n"))
+	}))
+	defer server.Close()
+
+	provider := NewProvider(server.URL)
+	provider.SetRequestTimeout(50 * time.Millisecond)
+	request := &llm.ChatRequest{Model: "llama2", Messages: []llm.ChatMessage{{Role: "user", Content: "Hello"}}}
+
+	_, err := provider.Chat(context.Background(), request)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	chunks, err := provider.Stream(context.Background(), request)
+	require.NoError(t, err)
+	var content string
+	done := false
+	for chunk := range chunks {
+		content += chunk.Content
+		done = done || chunk.Done
+	}
+	assert.Equal(t, "slow reply", content)
+	assert.True(t, done)
+}
@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// HTTPOptions tune the HTTP transport of provider clients. There is no
+// overall client timeout: streams last as long as the model generates, and
+// non-streaming requests are bounded by RequestTimeout through their context.
+type HTTPOptions struct {
+	DialTimeout           time.Duration
+	KeepAlive             time.Duration // TCP keep-alive probe interval
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration // until the provider starts answering
+	IdleConnTimeout       time.Duration // pooled connections are closed after this
+	MaxIdleConnsPerHost   int
+	RequestTimeout        time.Duration // total time of non-streaming requests
+}
+
+// DefaultHTTPOptions returns the options of provider clients that are not
+// configured otherwise
+func DefaultHTTPOptions() HTTPOptions {
+	return HTTPOptions{
+		DialTimeout:           10 * time.Second,
+		KeepAlive:             30 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 120 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   16,
+		RequestTimeout:        120 * time.Second,
+	}
+}
+
+// TuneTransport applies opts to transport and returns it. Connections are
+// kept alive and pooled per host, HTTP/2 is negotiated over TLS and TLS
+// sessions are resumed across connections. Zero options keep the
+// transport's setting.
+func TuneTransport(transport *http.Transport, opts HTTPOptions) *http.Transport {
+	if opts.DialTimeout > 0 || opts.KeepAlive > 0 {
+		dialer := &net.Dialer{Timeout: opts.DialTimeout, KeepAlive: opts.KeepAlive}
+		transport.DialContext = dialer.DialContext
+	}
+	if opts.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = opts.TLSHandshakeTimeout
+	}
+	if opts.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+	}
+	if opts.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = opts.IdleConnTimeout
+	}
+	if opts.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+		if transport.MaxIdleConns > 0 && transport.MaxIdleConns < opts.MaxIdleConnsPerHost {
+			transport.MaxIdleConns = opts.MaxIdleConnsPerHost
+		}
+	}
+	transport.DisableKeepAlives = false
+	// A custom dialer disables HTTP/2 unless it is forced
+	transport.ForceAttemptHTTP2 = true
+
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	} else {
+		transport.TLSClientConfig = transport.TLSClientConfig.Clone()
+	}
+	if transport.TLSClientConfig.ClientSessionCache == nil {
+		transport.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(64)
+	}
+	return transport
+}
+
+// NewHTTPClient returns a client for provider APIs with a tuned transport
+// and no overall timeout
+func NewHTTPClient(opts HTTPOptions) *http.Client {
+	return &http.Client{
+		Transport: TuneTransport(http.DefaultTransport.(*http.Transport).Clone(), opts),
+	}
+}
@@ -0,0 +1,35 @@
+package llm
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTuneTransport(t *testing.T) {
+	transport := TuneTransport(&http.Transport{DisableKeepAlives: true, MaxIdleConns: 4}, HTTPOptions{
+		DialTimeout:           time.Second,
+		ResponseHeaderTimeout: 2 * time.Second,
+		MaxIdleConnsPerHost:   8,
+	})
+
+	assert.NotNil(t, transport.DialContext)
+	assert.Equal(t, 2*time.Second, transport.ResponseHeaderTimeout)
+	assert.Equal(t, 8, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 8, transport.MaxIdleConns)
+	assert.False(t, transport.DisableKeepAlives)
+	assert.True(t, transport.ForceAttemptHTTP2)
+	require.NotNil(t, transport.TLSClientConfig)
+	assert.NotNil(t, transport.TLSClientConfig.ClientSessionCache)
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	client := NewHTTPClient(DefaultHTTPOptions())
+	assert.Zero(t, client.Timeout, "streams must not be cut off")
+	transport, ok := client.Transport.(*http.Transport)
+	require.True(t, ok)
+	assert.Equal(t, 120*time.Second, transport.ResponseHeaderTimeout)
+}
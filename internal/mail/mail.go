@@ -0,0 +1,269 @@
+// Package mail sends email over SMTP and parses the emails the email channel
+// receives.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent-server/internal/config"
+
+	"github.com/google/uuid"
+)
+
+// Message is an outgoing plain-text email
+type Message struct {
+	From    string // defaults to the sender's configured address
+	To      []string
+	Cc      []string
+	Subject string
+	Body    string
+
+	// MessageID is generated when empty
+	MessageID  string
+	InReplyTo  string
+	References []string
+	// Headers are added as they are, e.g. Auto-Submitted
+	Headers map[string]string
+}
+
+// Sender sends email through an SMTP server
+type Sender struct {
+	cfg     config.EmailToolConfig
+	timeout time.Duration
+}
+
+// NewSender creates a sender for the configured SMTP server
+func NewSender(cfg config.EmailToolConfig) *Sender {
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	if cfg.TLS == "" {
+		cfg.TLS = "starttls"
+	}
+	return &Sender{cfg: cfg, timeout: 30 * time.Second}
+}
+
+// From returns the configured sender address
+func (s *Sender) From() string {
+	return s.cfg.From
+}
+
+// Send delivers msg to its recipients. The generated Message-ID is set on
+// msg so that replies can be threaded.
+func (s *Sender) Send(ctx context.Context, msg *Message) error {
+	if msg.From == "" {
+		msg.From = s.cfg.From
+	}
+	from, err := netmail.ParseAddress(msg.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address %q: %w", msg.From, err)
+	}
+	recipients, err := ParseAddressList(append(append([]string{}, msg.To...), msg.Cc...))
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return errors.New("email has no recipients")
+	}
+	if msg.MessageID == "" {
+		msg.MessageID = NewMessageID(from.Address)
+	}
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	client, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp MAIL FROM failed: %w", err)
+	}
+	for _, rcpt := range recipients {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s failed: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA failed: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp server rejected email: %w", err)
+	}
+	return client.Quit()
+}
+
+// dial connects and authenticates to the SMTP server
+func (s *Sender) dial(ctx context.Context) (*smtp.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	tlsConfig := &tls.Config{ServerName: s.cfg.Host}
+	if s.cfg.TLS == "tls" {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp handshake failed: %w", err)
+	}
+
+	if s.cfg.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp STARTTLS failed: %w", err)
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp authentication failed: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// Bytes renders the message in RFC 5322 format
+func (m *Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+
+	from, err := netmail.ParseAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %w", m.From, err)
+	}
+	header("From", from.String())
+	for _, field := range []struct {
+		name string
+		list []string
+	}{{"To", m.To}, {"Cc", m.Cc}} {
+		if len(field.list) == 0 {
+			continue
+		}
+		addresses, err := netmail.ParseAddressList(strings.Join(field.list, ", "))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s address: %w", field.name, err)
+		}
+		formatted := make([]string, len(addresses))
+		for i, address := range addresses {
+			formatted[i] = address.String()
+		}
+		header(field.name, strings.Join(formatted, ", "))
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	if m.MessageID != "" {
+		header("Message-ID", m.MessageID)
+	}
+	if m.InReplyTo != "" {
+		header("In-Reply-To", m.InReplyTo)
+	}
+	if len(m.References) > 0 {
+		header("References", strings.Join(m.References, " "))
+	}
+	names := make([]string, 0, len(m.Headers))
+	for name := range m.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		header(name, m.Headers[name])
+	}
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+
+	body := strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n")
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewMessageID generates a Message-ID in the domain of address
+func NewMessageID(address string) string {
+	domain := "agent-server.local"
+	if i := strings.LastIndex(address, "@"); i >= 0 && i < len(address)-1 {
+		domain = address[i+1:]
+	}
+	return "<" + uuid.New().String() + "@" + domain + ">"
+}
+
+// ParseAddressList parses addresses, each of which may be a list, into bare
+// email addresses
+func ParseAddressList(lists []string) ([]string, error) {
+	var addresses []string
+	for _, list := range lists {
+		if strings.TrimSpace(list) == "" {
+			continue
+		}
+		parsed, err := netmail.ParseAddressList(list)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", list, err)
+		}
+		for _, address := range parsed {
+			addresses = append(addresses, address.Address)
+		}
+	}
+	return addresses, nil
+}
+
+// MatchAddress reports whether address is in allowed, which lists addresses
+// and domains written as "@example.com". An empty list allows any address.
+func MatchAddress(address string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	address = strings.ToLower(address)
+	for _, entry := range allowed {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if strings.HasPrefix(entry, "@") {
+			if strings.HasSuffix(address, entry) {
+				return true
+			}
+		} else if address == entry {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"agent-server/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const multipartReply = "From: =?utf-8?q?J=C3=B6rg?= <Joerg@Example.com>\r\n" +
+	"To: Agent <agent@example.org>\r\n" +
+	"Subject: =?utf-8?q?Re:_Gr=C3=BC=C3=9Fe?=\r\n" +
+	"Message-ID: <reply-2@example.com>\r\n" +
+	"In-Reply-To: <answer-1@example.org>\r\n" +
+	"References: <question-1@example.com>\r\n <answer-1@example.org>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"b1\"\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Danke, und wie sp=C3=A4t ist es?\r\n" +
+	"\r\n" +
+	"On Mon, 1 Jan 2024 at 10:00, Agent <agent@example.org> wrote:\r\n" +
+	"> It is noon.\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>Danke</p>\r\n" +
+	"--b1--\r\n"
+
+func TestParse(t *testing.T) {
+	in, err := Parse(strings.NewReader(multipartReply))
+	require.NoError(t, err)
+
+	assert.Equal(t, "joerg@example.com", in.From)
+	assert.Equal(t, "Jörg", in.FromName)
+	assert.Equal(t, []string{"agent@example.org"}, in.To)
+	assert.Equal(t, "Re: Grüße", in.Subject)
+	assert.Equal(t, "<reply-2@example.com>", in.MessageID)
+	assert.Equal(t, "Danke, und wie spät ist es?", in.Text)
+	assert.Equal(t, []string{"<answer-1@example.org>", "<question-1@example.com>"}, in.Thread())
+	assert.False(t, in.AutoGenerated)
+	assert.Equal(t, "joerg@example.com", in.ReplyAddress())
+
+	html := "From: a@example.com\r\nAuto-Submitted: auto-replied\r\nContent-Type: text/html\r\n\r\n" +
+		"<html><head><style>p {}</style></head><body><p>Out of office</p><p>Back &amp; soon</p></body></html>"
+	in, err = Parse(strings.NewReader(html))
+	require.NoError(t, err)
+	assert.Equal(t, "Out of office\nBack & soon", in.Text)
+	assert.True(t, in.AutoGenerated)
+
+	_, err = Parse(strings.NewReader("not an email"))
+	assert.Error(t, err)
+}
+
+func TestMessageBytes(t *testing.T) {
+	msg := &Message{
+		From:       "Agent <agent@example.org>",
+		To:         []string{"joerg@example.com"},
+		Subject:    "Re: Grüße",
+		Body:       "It is noon.\nBye",
+		MessageID:  "<answer-1@example.org>",
+		InReplyTo:  "<question-1@example.com>",
+		References: []string{"<question-1@example.com>"},
+		Headers:    map[string]string{"Auto-Submitted": "auto-replied"},
+	}
+	data, err := msg.Bytes()
+	require.NoError(t, err)
+
+	// The rendered message parses back to what was sent
+	in, err := Parse(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	assert.Equal(t, "agent@example.org", in.From)
+	assert.Equal(t, "Re: Grüße", in.Subject)
+	assert.Equal(t, "It is noon.\nBye", in.Text)
+	assert.Equal(t, "<answer-1@example.org>", in.MessageID)
+	assert.Equal(t, []string{"<question-1@example.com>"}, in.Thread())
+	assert.True(t, in.AutoGenerated)
+}
+
+func TestSender_Send(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan []string, 1)
+	go serveSMTP(listener, received)
+
+	sender := NewSender(config.EmailToolConfig{
+		Host: "127.0.0.1",
+		Port: listener.Addr().(*net.TCPAddr).Port,
+		TLS:  "none",
+		From: "agent@example.org",
+	})
+
+	msg := &Message{To: []string{"Alice <alice@example.com>"}, Cc: []string{"bob@example.com"}, Subject: "Hi", Body: "Hello"}
+	require.NoError(t, sender.Send(context.Background(), msg))
+	assert.True(t, strings.HasSuffix(msg.MessageID, "@example.org>"))
+
+	lines := <-received
+	assert.Contains(t, lines, "MAIL FROM:<agent@example.org>")
+	assert.Contains(t, lines, "RCPT TO:<alice@example.com>")
+	assert.Contains(t, lines, "RCPT TO:<bob@example.com>")
+	assert.Contains(t, lines, "Message-ID: "+msg.MessageID)
+
+	assert.Error(t, sender.Send(context.Background(), &Message{Subject: "No recipients"}))
+}
+
+// serveSMTP answers one SMTP session and reports the lines it received
+func serveSMTP(listener net.Listener, received chan<- []string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var lines []string
+	reader := bufio.NewReader(conn)
+	write := func(reply string) { conn.Write([]byte(reply + "\r\n")) }
+	write("220 test ESMTP")
+	inData := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		switch {
+		case inData:
+			if line == "." {
+				inData = false
+				write("250 queued")
+			}
+		case strings.HasPrefix(line, "EHLO"):
+			write("250 test")
+		case line == "DATA":
+			inData = true
+			write("354 go ahead")
+		case line == "QUIT":
+			write("221 bye")
+			received <- lines
+			return
+		default:
+			write("250 ok")
+		}
+	}
+	received <- lines
+}
@@ -0,0 +1,290 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"regexp"
+	"strings"
+)
+
+// maxPartDepth bounds the nesting of multipart bodies
+const maxPartDepth = 10
+
+// Inbound is a received email
+type Inbound struct {
+	MessageID  string
+	InReplyTo  string
+	References []string
+
+	From     string // bare address
+	FromName string
+	ReplyTo  string // bare address; empty when the header is missing
+	To       []string
+	Cc       []string
+	Subject  string
+	// Text is the plain-text body without quoted replies and signature
+	Text string
+
+	// AutoGenerated marks bounces, auto-replies and list mail, which are
+	// never answered
+	AutoGenerated bool
+}
+
+// ReplyAddress returns the address a reply goes to
+func (in *Inbound) ReplyAddress() string {
+	if in.ReplyTo != "" {
+		return in.ReplyTo
+	}
+	return in.From
+}
+
+// Thread returns the Message-IDs the email replies to, most recent first
+func (in *Inbound) Thread() []string {
+	var ids []string
+	seen := make(map[string]bool)
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	add(in.InReplyTo)
+	for i := len(in.References) - 1; i >= 0; i-- {
+		add(in.References[i])
+	}
+	return ids
+}
+
+var decoder = mime.WordDecoder{CharsetReader: charsetReader}
+
+// Parse reads an RFC 5322 email
+func Parse(r io.Reader) (*Inbound, error) {
+	msg, err := netmail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email: %w", err)
+	}
+
+	in := &Inbound{
+		MessageID:  firstMessageID(msg.Header.Get("Message-ID")),
+		InReplyTo:  firstMessageID(msg.Header.Get("In-Reply-To")),
+		References: messageIDs(msg.Header.Get("References")),
+	}
+
+	addressParser := netmail.AddressParser{WordDecoder: &decoder}
+	from, err := addressParser.Parse(msg.Header.Get("From"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid From address: %w", err)
+	}
+	in.From, in.FromName = strings.ToLower(from.Address), from.Name
+	if replyTo, err := addressParser.Parse(msg.Header.Get("Reply-To")); err == nil {
+		in.ReplyTo = strings.ToLower(replyTo.Address)
+	}
+	in.To = addressList(&addressParser, msg.Header.Get("To"))
+	in.Cc = addressList(&addressParser, msg.Header.Get("Cc"))
+
+	if subject, err := decoder.DecodeHeader(msg.Header.Get("Subject")); err == nil {
+		in.Subject = strings.TrimSpace(subject)
+	} else {
+		in.Subject = strings.TrimSpace(msg.Header.Get("Subject"))
+	}
+
+	autoSubmitted := strings.ToLower(msg.Header.Get("Auto-Submitted"))
+	precedence := strings.ToLower(msg.Header.Get("Precedence"))
+	in.AutoGenerated = (autoSubmitted != "" && autoSubmitted != "no") ||
+		precedence == "bulk" || precedence == "list" || precedence == "junk" ||
+		msg.Header.Get("List-Id") != "" ||
+		strings.HasPrefix(strings.ToLower(from.Address), "mailer-daemon@")
+
+	plain, htmlText, err := readBody(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body, 0)
+	if err != nil {
+		return nil, err
+	}
+	if plain == "" && htmlText != "" {
+		plain = htmlToText(htmlText)
+	}
+	in.Text = stripReply(plain)
+	return in, nil
+}
+
+// readBody returns the first text/plain and text/html parts of a body
+func readBody(contentType, encoding string, body io.Reader, depth int) (plain, htmlText string, err error) {
+	if depth > maxPartDepth {
+		return "", "", errors.New("email parts are nested too deeply")
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return plain, htmlText, fmt.Errorf("invalid multipart email: %w", err)
+			}
+			if disposition, _, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition")); disposition == "attachment" {
+				continue
+			}
+			p, h, err := readBody(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part, depth+1)
+			if err != nil {
+				return plain, htmlText, err
+			}
+			if plain == "" {
+				plain = p
+			}
+			if htmlText == "" {
+				htmlText = h
+			}
+		}
+		return plain, htmlText, nil
+	}
+
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return "", "", nil
+	}
+	data, err := io.ReadAll(decodeTransfer(body, encoding))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode email body: %w", err)
+	}
+	text, err := decodeCharset(data, params["charset"])
+	if err != nil {
+		return "", "", err
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if mediaType == "text/html" {
+		return "", text, nil
+	}
+	return text, "", nil
+}
+
+func decodeTransfer(body io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	}
+	return body
+}
+
+// decodeCharset converts text in the charsets mail clients commonly use to
+// UTF-8
+func decodeCharset(data []byte, charset string) (string, error) {
+	reader, err := charsetReader(charset, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	decoded, err := io.ReadAll(reader)
+	return string(decoded), err
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+		// windows-1252 differs from Latin-1 only in punctuation, which is
+		// decoded as its Latin-1 control codes
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return strings.NewReader(string(runes)), nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", charset)
+}
+
+var (
+	htmlBreaks  = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/tr|/h[1-6])[^>]*>`)
+	htmlDrop    = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>`)
+	htmlTags    = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLines  = regexp.MustCompile(`\n{3,}`)
+	attribution = regexp.MustCompile(`(?i)^(on .+ wrote:|am .+ schrieb .+:|-+ ?original message ?-+)$`)
+)
+
+// htmlToText reduces an HTML body to its text
+func htmlToText(s string) string {
+	s = htmlDrop.ReplaceAllString(s, "")
+	s = htmlBreaks.ReplaceAllString(s, "\n")
+	s = htmlTags.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// stripReply removes the quoted previous messages and the signature from a
+// reply, keeping only what the sender wrote
+func stripReply(text string) string {
+	lines := strings.Split(text, "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if line == "-- " || attribution.MatchString(trimmed) {
+			break
+		}
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		kept = append(kept, strings.TrimRight(line, " \t"))
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(kept, "\n"), "\n\n"))
+}
+
+// firstMessageID returns the first Message-ID in a header
+func firstMessageID(header string) string {
+	if ids := messageIDs(header); len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
+}
+
+// messageIDs returns the <...> Message-IDs in a header
+func messageIDs(header string) []string {
+	var ids []string
+	for {
+		start := strings.Index(header, "<")
+		if start < 0 {
+			return ids
+		}
+		end := strings.Index(header[start:], ">")
+		if end < 0 {
+			return ids
+		}
+		ids = append(ids, header[start:start+end+1])
+		header = header[start+end+1:]
+	}
+}
+
+func addressList(parser *netmail.AddressParser, header string) []string {
+	if header == "" {
+		return nil
+	}
+	parsed, err := parser.ParseList(header)
+	if err != nil {
+		return nil
+	}
+	addresses := make([]string, len(parsed))
+	for i, address := range parsed {
+		addresses[i] = strings.ToLower(address.Address)
+	}
+	return addresses
+}
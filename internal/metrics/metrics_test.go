@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	registry := NewRegistry()
+	h := registry.NewHistogram("test_duration_seconds", "Test durations.", []float64{1, 0.5}, "model")
+	h.Observe(0.2, "a")
+	h.Observe(0.5, "a")
+	h.Observe(3, "a")
+	h.Observe(0.7, `say "hi"\`)
+	h.Observe(1, "missing", "label") // Wrong label count is ignored
+
+	var buf bytes.Buffer
+	n, err := registry.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.Equal(t, `# HELP test_duration_seconds Test durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{model="a",le="0.5"} 2
+test_duration_seconds_bucket{model="a",le="1"} 2
+test_duration_seconds_bucket{model="a",le="+Inf"} 3
+test_duration_seconds_sum{model="a"} 3.7
+test_duration_seconds_count{model="a"} 3
+test_duration_seconds_bucket{model="say \"hi\"\\",le="0.5"} 0
+test_duration_seconds_bucket{model="say \"hi\"\\",le="1"} 1
+test_duration_seconds_bucket{model="say \"hi\"\\",le="+Inf"} 1
+test_duration_seconds_sum{model="say \"hi\"\\"} 0.7
+test_duration_seconds_count{model="say \"hi\"\\"} 1
+`, buf.String())
+}
+
+func TestExponentialBuckets(t *testing.T) {
+	assert.Equal(t, []float64{1, 4, 16}, ExponentialBuckets(1, 4, 3))
+}
+
+func TestGauge(t *testing.T) {
+	registry := NewRegistry()
+	g := registry.NewGauge("test_in_flight", "Test requests in flight.", "agent")
+	g.Add(1, "a")
+	g.Add(1, "a")
+	g.Add(-1, "a")
+	g.Add(2, "b")
+	g.Add(1) // Wrong label count is ignored
+	assert.Equal(t, float64(1), g.Value("a"))
+
+	var buf bytes.Buffer
+	_, err := registry.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, `# HELP test_in_flight Test requests in flight.
+# TYPE test_in_flight gauge
+test_in_flight{agent="a"} 1
+test_in_flight{agent="b"} 2
+`, buf.String())
+}
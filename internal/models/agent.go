@@ -2,8 +2,14 @@ package models
 
 import (
 	"database/sql/driver"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	"agent-server/internal/lang"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -28,19 +34,292 @@ func (j *JSON) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, j)
 }
 
+// StringList is a list of strings stored as a JSON array
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (l *StringList) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = StringList{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil
+	}
+	if len(data) == 0 {
+		*l = StringList{}
+		return nil
+	}
+	return json.Unmarshal(data, l)
+}
+
+// StringMap is a map of strings stored as a JSON object
+type StringMap map[string]string
+
+func (m StringMap) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (m *StringMap) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*m = StringMap{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil
+	}
+	if len(data) == 0 {
+		*m = StringMap{}
+		return nil
+	}
+	return json.Unmarshal(data, m)
+}
+
+// AgentModeration enables content moderation for an agent. Input checks
+// user messages before generation, Output checks replies after it; the
+// thresholds override the configured ones when set.
+type AgentModeration struct {
+	Input          bool    `json:"input"`
+	Output         bool    `json:"output"`
+	FlagThreshold  float64 `json:"flag_threshold,omitempty" validate:"min=0,max=1"`
+	BlockThreshold float64 `json:"block_threshold,omitempty" validate:"min=0,max=1"`
+}
+
+// Value stores the moderation settings as JSON
+func (m AgentModeration) Value() (driver.Value, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads moderation settings stored as JSON
+func (m *AgentModeration) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil
+	}
+	return json.Unmarshal(data, m)
+}
+
+// AgentPII selects the personal data an agent scrubs before storing it.
+// Scrubbed copies hold placeholders such as [EMAIL]; the LLM sees the
+// original text of the current turn only.
+type AgentPII struct {
+	Messages      bool     `json:"messages"`        // user, assistant and tool messages
+	ToolArguments bool     `json:"tool_arguments"`  // tool calls and execution logs
+	Memories      bool     `json:"memories"`        // topics and contents stored by the memory tool
+	Types         []string `json:"types,omitempty"` // entity types to scrub; empty scrubs all
+}
+
+// Value stores the data-handling policy as JSON
+func (p AgentPII) Value() (driver.Value, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads a data-handling policy stored as JSON
+func (p *AgentPII) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil
+	}
+	return json.Unmarshal(data, p)
+}
+
+// Tool prompt modes
+const (
+	ToolPromptFull    = "full"    // a usage block per tool in the system prompt
+	ToolPromptCompact = "compact" // one line per tool; parameters only in the native tool definitions
+)
+
+// Tool selectors, which pick the tools of a turn when their number is capped
+const (
+	ToolSelectorKeywords = "keywords" // words shared by the message and the tools' descriptions
+	ToolSelectorModel    = "model"    // a call to a small model before the main one
+)
+
+// AgentToolPrompt controls how the tools on offer are described to the
+// model. Agents with many tools spend much of each prompt on usage blocks;
+// the compact mode and the relevance filter cut that down.
+type AgentToolPrompt struct {
+	Mode             string `json:"mode,omitempty" validate:"omitempty,oneof=full compact"`                                           // empty is full
+	MaxTools         int    `json:"max_tools,omitempty" validate:"min=0,max=100"`                                                     // offer only the tools most relevant to the message; 0 offers all
+	Selector         string `json:"selector,omitempty" validate:"omitempty,oneof=keywords model"`                                     // how the relevant tools are picked; empty is keywords
+	SelectorProvider string `json:"selector_provider,omitempty" validate:"omitempty,oneof=openai anthropic mistral grok ollama mock"` // provider of the selector model; empty is the agent's
+	SelectorModel    string `json:"selector_model,omitempty" validate:"max=200"`                                                      // a small, fast model; empty is the agent's
+}
+
+// Value stores a tool prompt policy as JSON
+func (p AgentToolPrompt) Value() (driver.Value, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads a tool prompt policy stored as JSON
+func (p *AgentToolPrompt) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil
+	}
+	return json.Unmarshal(data, p)
+}
+
+// AgentMemoryRanking weighs the signals that order the memories the memory
+// tool recalls: importance, recency, how often a memory was recalled before
+// and its similarity to the user's latest message. A weight of 0 leaves a
+// signal out.
+type AgentMemoryRanking struct {
+	Importance float64 `json:"importance" validate:"min=0,max=10"`
+	Recency    float64 `json:"recency" validate:"min=0,max=10"`
+	Frequency  float64 `json:"frequency" validate:"min=0,max=10"`
+	Similarity float64 `json:"similarity" validate:"min=0,max=10"`
+	HalfLife   int     `json:"half_life_hours,omitempty" validate:"min=0,max=87600"` // hours after which recency counts half; 0 is a week
+}
+
+// DefaultMemoryRanking ranks the recalled memories of agents without a
+// ranking of their own
+var DefaultMemoryRanking = AgentMemoryRanking{Importance: 1, Recency: 1, Frequency: 0.5, Similarity: 1, HalfLife: 7 * 24}
+
+// Value stores a memory ranking as JSON
+func (r AgentMemoryRanking) Value() (driver.Value, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads a memory ranking stored as JSON
+func (r *AgentMemoryRanking) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil
+	}
+	return json.Unmarshal(data, r)
+}
+
+// AgentReask asks the model once more when it replies with nothing or a
+// canned refusal, which flaky local models occasionally do. The retry gets
+// the same context plus an instruction to answer.
+type AgentReask struct {
+	Empty       bool   `json:"empty"`                                     // retry blank replies
+	Refusals    bool   `json:"refusals"`                                  // retry canned refusals such as "I'm sorry, but I can't"
+	Instruction string `json:"instruction,omitempty" validate:"max=2000"` // added to the retry; empty uses a default
+}
+
+// Value stores the re-ask policy as JSON
+func (r AgentReask) Value() (driver.Value, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads a re-ask policy stored as JSON
+func (r *AgentReask) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil
+	}
+	return json.Unmarshal(data, r)
+}
+
 // Agent represents an AI agent configuration
 type Agent struct {
-	ID           string    `json:"id" gorm:"primaryKey"`
-	Name         string    `json:"name" gorm:"not null" validate:"required,min=1,max=100"`
-	Description  string    `json:"description" gorm:"type:text"`
-	Provider     string    `json:"provider" gorm:"not null" validate:"required,oneof=openai anthropic mistral grok ollama"`
-	Model        string    `json:"model" gorm:"not null" validate:"required"`
-	SystemPrompt string    `json:"system_prompt" gorm:"type:text;not null" validate:"required"`
-	Temperature  float32   `json:"temperature" gorm:"default:0.7" validate:"min=0,max=2"`
-	MaxTokens    int       `json:"max_tokens" gorm:"default:1000" validate:"min=1,max=100000"`
-	Config       JSON      `json:"config" gorm:"type:json"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                 string              `json:"id" gorm:"primaryKey"`
+	Name               string              `json:"name" gorm:"not null" validate:"required,min=1,max=100"`
+	Description        string              `json:"description" gorm:"type:text"`
+	Provider           string              `json:"provider" gorm:"not null" validate:"required,oneof=openai anthropic mistral grok ollama mock"`
+	Model              string              `json:"model" gorm:"not null" validate:"required"`
+	SystemPrompt       string              `json:"system_prompt" gorm:"type:text;not null" validate:"required"`
+	LocalizedPrompts   StringMap           `json:"localized_system_prompts,omitempty" gorm:"type:json"` // system prompts by language tag
+	Temperature        float32             `json:"temperature" gorm:"default:0.7" validate:"min=0,max=2"`
+	MaxTokens          int                 `json:"max_tokens" gorm:"default:1000" validate:"min=1,max=100000"`
+	Config             JSON                `json:"config" gorm:"type:json"`
+	Tags               StringList          `json:"tags" gorm:"type:json"`
+	Enabled            bool                `json:"enabled" gorm:"not null;index"`                                                           // stored as given; requests enable new agents by default
+	Grounded           bool                `json:"grounded" gorm:"not null;default:false"`                                                  // answers must cite tool results
+	MaxConcurrency     int                 `json:"max_concurrency,omitempty" gorm:"not null;default:0" validate:"min=0,max=1000"`           // concurrent replies; 0 uses the server default
+	StreamRate         int                 `json:"stream_tokens_per_second,omitempty" gorm:"not null;default:0" validate:"min=0,max=10000"` // streamed output pace; 0 is unthrottled
+	Moderation         *AgentModeration    `json:"moderation,omitempty" gorm:"type:json"`
+	PII                *AgentPII           `json:"pii,omitempty" gorm:"type:json"`
+	Reask              *AgentReask         `json:"reask,omitempty" gorm:"type:json"`
+	ToolPrompt         *AgentToolPrompt    `json:"tool_prompt,omitempty" gorm:"type:json"`
+	MemoryRanking      *AgentMemoryRanking `json:"memory_ranking,omitempty" gorm:"type:json"`
+	MaintenanceMessage string              `json:"maintenance_message,omitempty" gorm:"type:text"`
+	CreatedAt          time.Time           `json:"created_at"`
+	UpdatedAt          time.Time           `json:"updated_at"`
 
 	// Relationships
 	Sessions []ChatSession `json:"sessions,omitempty" gorm:"foreignKey:AgentID"`
@@ -56,40 +335,74 @@ func (a *Agent) BeforeCreate(tx *gorm.DB) error {
 
 // CreateAgentRequest represents the request payload for creating an agent
 type CreateAgentRequest struct {
-	Name         string                 `json:"name" validate:"required,min=1,max=100"`
-	Description  string                 `json:"description"`
-	Provider     string                 `json:"provider" validate:"required,oneof=openai anthropic mistral grok ollama"`
-	Model        string                 `json:"model" validate:"required"`
-	SystemPrompt string                 `json:"system_prompt" validate:"required"`
-	Temperature  *float32               `json:"temperature,omitempty" validate:"omitempty,min=0,max=2"`
-	MaxTokens    *int                   `json:"max_tokens,omitempty" validate:"omitempty,min=1,max=100000"`
-	Config       map[string]interface{} `json:"config,omitempty"`
+	Name             string                 `json:"name" validate:"required,min=1,max=100"`
+	Description      string                 `json:"description"`
+	Provider         string                 `json:"provider" validate:"required,oneof=openai anthropic mistral grok ollama mock"`
+	Model            string                 `json:"model" validate:"required"`
+	SystemPrompt     string                 `json:"system_prompt" validate:"required"`
+	LocalizedPrompts map[string]string      `json:"localized_system_prompts,omitempty" validate:"omitempty,max=50,dive,keys,bcp47_language_tag,endkeys,required"`
+	Temperature      *float32               `json:"temperature,omitempty" validate:"omitempty,min=0,max=2"`
+	MaxTokens        *int                   `json:"max_tokens,omitempty" validate:"omitempty,min=1,max=100000"`
+	Config           map[string]interface{} `json:"config,omitempty"`
+	Tags             []string               `json:"tags,omitempty" validate:"omitempty,max=20,dive,min=1,max=50"`
+	Enabled          *bool                  `json:"enabled,omitempty"`
+	Grounded         bool                   `json:"grounded,omitempty"`
+	MaxConcurrency   int                    `json:"max_concurrency,omitempty" validate:"min=0,max=1000"`
+	StreamRate       int                    `json:"stream_tokens_per_second,omitempty" validate:"min=0,max=10000"`
+	Moderation       *AgentModeration       `json:"moderation,omitempty"`
+	PII              *AgentPII              `json:"pii,omitempty"`
+	Reask            *AgentReask            `json:"reask,omitempty"`
+	ToolPrompt       *AgentToolPrompt       `json:"tool_prompt,omitempty"`
+	MemoryRanking    *AgentMemoryRanking    `json:"memory_ranking,omitempty"`
 }
 
 // UpdateAgentRequest represents the request payload for updating an agent
 type UpdateAgentRequest struct {
-	Name         *string                `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
-	Description  *string                `json:"description,omitempty"`
-	Provider     *string                `json:"provider,omitempty" validate:"omitempty,oneof=openai anthropic mistral grok ollama"`
-	Model        *string                `json:"model,omitempty"`
-	SystemPrompt *string                `json:"system_prompt,omitempty"`
-	Temperature  *float32               `json:"temperature,omitempty" validate:"omitempty,min=0,max=2"`
-	MaxTokens    *int                   `json:"max_tokens,omitempty" validate:"omitempty,min=1,max=100000"`
-	Config       map[string]interface{} `json:"config,omitempty"`
+	Name               *string                `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Description        *string                `json:"description,omitempty"`
+	Provider           *string                `json:"provider,omitempty" validate:"omitempty,oneof=openai anthropic mistral grok ollama mock"`
+	Model              *string                `json:"model,omitempty"`
+	SystemPrompt       *string                `json:"system_prompt,omitempty"`
+	LocalizedPrompts   map[string]string      `json:"localized_system_prompts,omitempty" validate:"omitempty,max=50,dive,keys,bcp47_language_tag,endkeys,required"`
+	Temperature        *float32               `json:"temperature,omitempty" validate:"omitempty,min=0,max=2"`
+	MaxTokens          *int                   `json:"max_tokens,omitempty" validate:"omitempty,min=1,max=100000"`
+	Config             map[string]interface{} `json:"config,omitempty"`
+	Tags               []string               `json:"tags,omitempty" validate:"omitempty,max=20,dive,min=1,max=50"`
+	Enabled            *bool                  `json:"enabled,omitempty"`
+	Grounded           *bool                  `json:"grounded,omitempty"`
+	MaxConcurrency     *int                   `json:"max_concurrency,omitempty" validate:"omitempty,min=0,max=1000"`
+	StreamRate         *int                   `json:"stream_tokens_per_second,omitempty" validate:"omitempty,min=0,max=10000"`
+	Moderation         *AgentModeration       `json:"moderation,omitempty"`
+	PII                *AgentPII              `json:"pii,omitempty"`
+	Reask              *AgentReask            `json:"reask,omitempty"`
+	ToolPrompt         *AgentToolPrompt       `json:"tool_prompt,omitempty"`
+	MemoryRanking      *AgentMemoryRanking    `json:"memory_ranking,omitempty"`
+	MaintenanceMessage *string                `json:"maintenance_message,omitempty" validate:"omitempty,max=1000"`
 }
 
 // ToAgent converts CreateAgentRequest to Agent
 func (r *CreateAgentRequest) ToAgent() *Agent {
 	agent := &Agent{
-		Name:         r.Name,
-		Description:  r.Description,
-		Provider:     r.Provider,
-		Model:        r.Model,
-		SystemPrompt: r.SystemPrompt,
-		Temperature:  0.7,
-		MaxTokens:    1000,
-		Config:       make(JSON),
+		Name:           r.Name,
+		Description:    r.Description,
+		Provider:       r.Provider,
+		Model:          r.Model,
+		SystemPrompt:   r.SystemPrompt,
+		Temperature:    0.7,
+		MaxTokens:      1000,
+		Config:         make(JSON),
+		Tags:           NormalizeTags(r.Tags),
+		Enabled:        true,
+		Grounded:       r.Grounded,
+		MaxConcurrency: r.MaxConcurrency,
+		StreamRate:     r.StreamRate,
+		Moderation:     r.Moderation,
+		PII:            r.PII,
+		Reask:          r.Reask,
+		ToolPrompt:     r.ToolPrompt,
+		MemoryRanking:  r.MemoryRanking,
 	}
+	agent.LocalizedPrompts = NormalizePrompts(r.LocalizedPrompts)
 
 	if r.Temperature != nil {
 		agent.Temperature = *r.Temperature
@@ -100,6 +413,9 @@ func (r *CreateAgentRequest) ToAgent() *Agent {
 	if r.Config != nil {
 		agent.Config = JSON(r.Config)
 	}
+	if r.Enabled != nil {
+		agent.Enabled = *r.Enabled
+	}
 
 	return agent
 }
@@ -121,6 +437,9 @@ func (a *Agent) UpdateFromRequest(req *UpdateAgentRequest) {
 	if req.SystemPrompt != nil {
 		a.SystemPrompt = *req.SystemPrompt
 	}
+	if req.LocalizedPrompts != nil {
+		a.LocalizedPrompts = NormalizePrompts(req.LocalizedPrompts)
+	}
 	if req.Temperature != nil {
 		a.Temperature = *req.Temperature
 	}
@@ -130,4 +449,272 @@ func (a *Agent) UpdateFromRequest(req *UpdateAgentRequest) {
 	if req.Config != nil {
 		a.Config = JSON(req.Config)
 	}
-}
\ No newline at end of file
+	if req.Tags != nil {
+		a.Tags = NormalizeTags(req.Tags)
+	}
+	if req.Enabled != nil {
+		a.Enabled = *req.Enabled
+		if a.Enabled && req.MaintenanceMessage == nil {
+			a.MaintenanceMessage = ""
+		}
+	}
+	if req.MaintenanceMessage != nil {
+		a.MaintenanceMessage = *req.MaintenanceMessage
+	}
+	if req.Grounded != nil {
+		a.Grounded = *req.Grounded
+	}
+	if req.MaxConcurrency != nil {
+		a.MaxConcurrency = *req.MaxConcurrency
+	}
+	if req.StreamRate != nil {
+		a.StreamRate = *req.StreamRate
+	}
+	if req.Moderation != nil {
+		a.Moderation = req.Moderation
+	}
+	if req.PII != nil {
+		a.PII = req.PII
+	}
+	if req.Reask != nil {
+		a.Reask = req.Reask
+	}
+	if req.ToolPrompt != nil {
+		a.ToolPrompt = req.ToolPrompt
+	}
+	if req.MemoryRanking != nil {
+		a.MemoryRanking = req.MemoryRanking
+	}
+}
+
+// DisableAgentRequest represents the request payload for taking an agent offline
+type DisableAgentRequest struct {
+	MaintenanceMessage string `json:"maintenance_message" validate:"max=1000"`
+}
+
+// AllowedTools returns the tool allowlist from the agent's "tools" config
+// entry, or nil when the agent does not restrict tools
+func (a *Agent) AllowedTools() []string {
+	var names []string
+	switch tools := a.Config["tools"].(type) {
+	case []string:
+		names = append(names, tools...)
+	case []interface{}:
+		for _, tool := range tools {
+			if name, ok := tool.(string); ok && name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// PostProcessSteps returns the reply post-processing steps of the agent's
+// "postprocess" config entry and whether the entry is set; an empty list
+// turns post-processing off for the agent
+func (a *Agent) PostProcessSteps() ([]string, bool) {
+	var steps []string
+	switch configured := a.Config["postprocess"].(type) {
+	case []string:
+		steps = append(steps, configured...)
+	case []interface{}:
+		for _, step := range configured {
+			if name, ok := step.(string); ok && name != "" {
+				steps = append(steps, name)
+			}
+		}
+	default:
+		return nil, false
+	}
+	return steps, true
+}
+
+// ToolPresets returns the parameters preset for a tool in the agent's
+// "tool_presets" config entry, keyed by tool name. They are merged into the
+// tool input server-side; string values of the form credential://<name>
+// refer to credentials configured under tools.credentials. An
+// "allowed_hosts" entry lists the hosts those credentials may be sent to.
+func (a *Agent) ToolPresets(toolName string) map[string]interface{} {
+	presets, _ := a.Config["tool_presets"].(map[string]interface{})
+	preset, _ := presets[toolName].(map[string]interface{})
+	return preset
+}
+
+// SystemPromptFor returns the system prompt for a session in a language: the
+// variant for the language tag, else the variant for its base language, else
+// the default prompt
+func (a *Agent) SystemPromptFor(language string) string {
+	if language == "" || len(a.LocalizedPrompts) == 0 {
+		return a.SystemPrompt
+	}
+	if prompt, ok := a.LocalizedPrompts[lang.Normalize(language)]; ok {
+		return prompt
+	}
+	if prompt, ok := a.LocalizedPrompts[lang.Base(language)]; ok {
+		return prompt
+	}
+	return a.SystemPrompt
+}
+
+// NormalizePrompts keys localized prompts by canonical language tag and
+// drops entries with invalid tags
+func NormalizePrompts(prompts map[string]string) StringMap {
+	if prompts == nil {
+		return nil
+	}
+	normalized := make(StringMap, len(prompts))
+	for tag, prompt := range prompts {
+		if tag = lang.Normalize(tag); tag != "" {
+			normalized[tag] = prompt
+		}
+	}
+	return normalized
+}
+
+// CloneAgentRequest represents the request payload for cloning an agent
+type CloneAgentRequest struct {
+	Name         *string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	CopyMemories bool    `json:"copy_memories"`
+}
+
+// Clone returns a copy of the agent's configuration under a new name. The
+// copy has no ID, timestamps or sessions; an empty name derives one from the
+// original.
+func (a *Agent) Clone(name string) *Agent {
+	if name == "" {
+		name = a.Name + " (copy)"
+		// Names are limited to 100 characters, not bytes
+		if utf8.RuneCountInString(name) > 100 {
+			name = string([]rune(name)[:100])
+		}
+	}
+
+	clone := &Agent{
+		Name:           name,
+		Description:    a.Description,
+		Provider:       a.Provider,
+		Model:          a.Model,
+		SystemPrompt:   a.SystemPrompt,
+		Temperature:    a.Temperature,
+		MaxTokens:      a.MaxTokens,
+		Config:         make(JSON),
+		Tags:           append(StringList{}, a.Tags...),
+		Enabled:        true,
+		Grounded:       a.Grounded,
+		MaxConcurrency: a.MaxConcurrency,
+		StreamRate:     a.StreamRate,
+	}
+	if a.Moderation != nil {
+		moderation := *a.Moderation
+		clone.Moderation = &moderation
+	}
+	if a.PII != nil {
+		policy := *a.PII
+		policy.Types = append([]string(nil), a.PII.Types...)
+		clone.PII = &policy
+	}
+	if a.Reask != nil {
+		reask := *a.Reask
+		clone.Reask = &reask
+	}
+	if a.ToolPrompt != nil {
+		toolPrompt := *a.ToolPrompt
+		clone.ToolPrompt = &toolPrompt
+	}
+	if a.MemoryRanking != nil {
+		ranking := *a.MemoryRanking
+		clone.MemoryRanking = &ranking
+	}
+	if a.LocalizedPrompts != nil {
+		clone.LocalizedPrompts = make(StringMap, len(a.LocalizedPrompts))
+		for tag, prompt := range a.LocalizedPrompts {
+			clone.LocalizedPrompts[tag] = prompt
+		}
+	}
+
+	// Deep copy so nested tool and context settings are not shared
+	if data, err := json.Marshal(a.Config); err == nil {
+		_ = json.Unmarshal(data, &clone.Config)
+	}
+
+	return clone
+}
+
+// NormalizeTags trims and lowercases tags and drops empty and duplicate entries
+func NormalizeTags(tags []string) StringList {
+	normalized := make(StringList, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// Agent list sort fields
+const (
+	AgentSortCreatedAt = "created_at"
+	AgentSortUpdatedAt = "updated_at"
+	AgentSortName      = "name"
+)
+
+// AgentFilter holds the filtering, sorting and pagination options for
+// listing agents. When Cursor is set it takes precedence over Offset.
+type AgentFilter struct {
+	Provider string
+	Model    string
+	Name     string // case-insensitive substring match
+	Tag      string
+	SortBy   string
+	Desc     bool
+	Cursor   *AgentCursor
+	Limit    int
+	Offset   int
+}
+
+// AgentCursor marks the position of the last agent on a page for keyset
+// pagination
+type AgentCursor struct {
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// NewAgentCursor builds the cursor pointing after the given agent
+func NewAgentCursor(agent *Agent, sortBy string) *AgentCursor {
+	cursor := &AgentCursor{ID: agent.ID}
+	switch sortBy {
+	case AgentSortName:
+		cursor.Value = agent.Name
+	case AgentSortUpdatedAt:
+		cursor.Value = agent.UpdatedAt.Format(time.RFC3339Nano)
+	default:
+		cursor.Value = agent.CreatedAt.Format(time.RFC3339Nano)
+	}
+	return cursor
+}
+
+// Encode returns the opaque string form of the cursor
+func (c *AgentCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeAgentCursor parses a cursor produced by Encode
+func DecodeAgentCursor(s string) (*AgentCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	var cursor AgentCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if cursor.ID == "" {
+		return nil, fmt.Errorf("invalid cursor: missing id")
+	}
+	return &cursor, nil
+}
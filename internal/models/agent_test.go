@@ -1,7 +1,9 @@
 package models
 
 import (
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -187,4 +189,44 @@ func TestJSON_ScanNil(t *testing.T) {
 	err := j.Scan(nil)
 	require.NoError(t, err)
 	assert.Equal(t, JSON{}, j)
-}
\ No newline at end of file
+}
+func TestNormalizeTags(t *testing.T) {
+	tags := NormalizeTags([]string{" Prod ", "support", "", "prod", "SUPPORT"})
+	assert.Equal(t, StringList{"prod", "support"}, tags)
+}
+
+func TestAgentCursor_RoundTrip(t *testing.T) {
+	cursor := &AgentCursor{Value: "Support Bot", ID: "agent-1"}
+
+	decoded, err := DecodeAgentCursor(cursor.Encode())
+	require.NoError(t, err)
+	assert.Equal(t, cursor, decoded)
+
+	_, err = DecodeAgentCursor("not a cursor")
+	assert.Error(t, err)
+}
+
+func TestAgent_SystemPromptFor(t *testing.T) {
+	agent := &Agent{
+		SystemPrompt:     "You are helpful.",
+		LocalizedPrompts: NormalizePrompts(map[string]string{"DE": "Du bist hilfsbereit.", "pt_br": "Você é prestativo.", "??": "dropped"}),
+	}
+	assert.Equal(t, StringMap{"de": "Du bist hilfsbereit.", "pt-BR": "Você é prestativo."}, agent.LocalizedPrompts)
+
+	assert.Equal(t, "Du bist hilfsbereit.", agent.SystemPromptFor("de"))
+	assert.Equal(t, "Du bist hilfsbereit.", agent.SystemPromptFor("de-AT"))
+	assert.Equal(t, "Você é prestativo.", agent.SystemPromptFor("pt-BR"))
+	assert.Equal(t, "You are helpful.", agent.SystemPromptFor("pt"))
+	assert.Equal(t, "You are helpful.", agent.SystemPromptFor("fr"))
+	assert.Equal(t, "You are helpful.", agent.SystemPromptFor(""))
+}
+
+func TestAgent_CloneTruncatesName(t *testing.T) {
+	source := &Agent{Name: strings.Repeat("ü", 95)}
+
+	clone := source.Clone("")
+	assert.True(t, utf8.ValidString(clone.Name))
+	assert.Equal(t, 100, utf8.RuneCountInString(clone.Name))
+	assert.Equal(t, strings.Repeat("ü", 95)+" (cop", clone.Name)
+	assert.Equal(t, "Bot (copy)", (&Agent{Name: "Bot"}).Clone("").Name)
+}
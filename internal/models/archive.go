@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MessageArchive holds a gzip-compressed batch of messages moved out of the
+// hot messages table. The payload is stored inline in Data or, for large
+// archives, in blob storage under BlobKey.
+type MessageArchive struct {
+	ID             string    `json:"id" gorm:"primaryKey"`
+	SessionID      string    `json:"session_id" gorm:"not null;index"`
+	MessageCount   int       `json:"message_count"`
+	SizeBytes      int64     `json:"size_bytes"`
+	FirstSequence  int64     `json:"first_sequence"`
+	LastSequence   int64     `json:"last_sequence"`
+	Data           []byte    `json:"-" gorm:"type:blob"`
+	BlobKey        string    `json:"blob_key,omitempty"`
+	Compacted      bool      `json:"compacted" gorm:"not null;default:false"` // originals a summary message replaced; unarchiving leaves them
+	FirstMessageAt time.Time `json:"first_message_at"`
+	LastMessageAt  time.Time `json:"last_message_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (a *MessageArchive) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ArchivePayload is the JSON document stored (compressed) in an archive
+type ArchivePayload struct {
+	Messages  []*Message  `json:"messages"`
+	ToolCalls []*ToolCall `json:"tool_calls,omitempty"`
+}
@@ -0,0 +1,16 @@
+package models
+
+// Types of reply attachments
+const (
+	AttachmentCode = "code"
+)
+
+// Attachment is structured content extracted from an assistant reply, such
+// as a fenced code block. The reply keeps the content; attachments spare
+// clients from parsing it.
+type Attachment struct {
+	Type     string `json:"type"`
+	Index    int    `json:"index"` // position among the reply's attachments
+	Language string `json:"language,omitempty"`
+	Content  string `json:"content"`
+}
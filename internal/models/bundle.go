@@ -0,0 +1,182 @@
+package models
+
+import "time"
+
+// Agent bundle format identifier and the version this server reads and writes
+const (
+	AgentBundleFormat  = "agent-server/agent-bundle"
+	AgentBundleVersion = 1
+)
+
+// AgentBundle is a portable agent definition in one JSON or YAML file: the
+// agent's configuration, its prompt templates, tool presets and seed
+// memories. Bundles carry no IDs, so they install as new agents on any
+// server. Credentials appear only as credential://<name> references, which
+// the importing server resolves against its own configuration.
+type AgentBundle struct {
+	Format   string              `json:"format" validate:"required,eq=agent-server/agent-bundle"`
+	Version  int                 `json:"version" validate:"required,min=1,max=1"`
+	Metadata AgentBundleMetadata `json:"metadata,omitempty"`
+	Agent    CreateAgentRequest  `json:"agent" validate:"-"` // validated as AgentRequest()
+	Prompts  *AgentBundlePrompts `json:"prompts,omitempty"`
+	Presets  map[string]JSON     `json:"tool_presets,omitempty" validate:"omitempty,max=100"`
+	Memories []AgentBundleMemory `json:"memories,omitempty" validate:"omitempty,max=1000,dive"`
+}
+
+// AgentBundleMetadata describes a bundle for people browsing shared agents
+type AgentBundleMetadata struct {
+	Author     string     `json:"author,omitempty" validate:"max=200"`
+	License    string     `json:"license,omitempty" validate:"max=100"`
+	Homepage   string     `json:"homepage,omitempty" validate:"omitempty,url,max=500"`
+	ExportedAt *time.Time `json:"exported_at,omitempty"`
+}
+
+// AgentBundlePrompts are the agent's system prompt templates. They may use
+// {{name}} placeholders of session variables.
+type AgentBundlePrompts struct {
+	System    string            `json:"system,omitempty" validate:"max=50000"`
+	Localized map[string]string `json:"localized,omitempty" validate:"omitempty,max=50,dive,keys,bcp47_language_tag,endkeys,required"`
+}
+
+// AgentBundleMemory is a memory the agent starts with
+type AgentBundleMemory struct {
+	Topic      string   `json:"topic" validate:"required,max=255"`
+	Content    string   `json:"content" validate:"required,max=10000"`
+	MemoryType string   `json:"memory_type" validate:"omitempty,oneof=preference fact conversation behavior"`
+	Importance int      `json:"importance,omitempty" validate:"min=0,max=10"`
+	Tags       []string `json:"tags,omitempty" validate:"omitempty,max=20,dive,max=100"`
+}
+
+// NewAgentBundle exports an agent with the given memories. Prompts and tool
+// presets move out of the agent's configuration into their own sections;
+// presets should be sanitized by the caller.
+func NewAgentBundle(agent *Agent, memories []*Memory, metadata AgentBundleMetadata) *AgentBundle {
+	config := make(JSON, len(agent.Config))
+	for key, value := range agent.Config {
+		if key != "tool_presets" {
+			config[key] = value
+		}
+	}
+	bundle := &AgentBundle{
+		Format:   AgentBundleFormat,
+		Version:  AgentBundleVersion,
+		Metadata: metadata,
+		Agent: CreateAgentRequest{
+			Name:           agent.Name,
+			Description:    agent.Description,
+			Provider:       agent.Provider,
+			Model:          agent.Model,
+			Temperature:    &agent.Temperature,
+			MaxTokens:      &agent.MaxTokens,
+			Config:         config,
+			Tags:           agent.Tags,
+			Grounded:       agent.Grounded,
+			MaxConcurrency: agent.MaxConcurrency,
+			StreamRate:     agent.StreamRate,
+			Moderation:     agent.Moderation,
+			PII:            agent.PII,
+			Reask:          agent.Reask,
+			ToolPrompt:     agent.ToolPrompt,
+			MemoryRanking:  agent.MemoryRanking,
+		},
+	}
+	if agent.SystemPrompt != "" || len(agent.LocalizedPrompts) > 0 {
+		bundle.Prompts = &AgentBundlePrompts{System: agent.SystemPrompt, Localized: agent.LocalizedPrompts}
+	}
+	if presets, ok := agent.Config["tool_presets"].(map[string]interface{}); ok && len(presets) > 0 {
+		bundle.Presets = make(map[string]JSON, len(presets))
+		for tool, preset := range presets {
+			if values, ok := preset.(map[string]interface{}); ok {
+				bundle.Presets[tool] = values
+			}
+		}
+	}
+	for _, memory := range memories {
+		seed := AgentBundleMemory{
+			Topic:      memory.Topic,
+			Content:    memory.Content,
+			MemoryType: memory.MemoryType,
+			Importance: memory.Importance,
+		}
+		switch tags := memory.Tags["tags"].(type) {
+		case []string:
+			seed.Tags = tags
+		case []interface{}:
+			for _, tag := range tags {
+				if s, ok := tag.(string); ok {
+					seed.Tags = append(seed.Tags, s)
+				}
+			}
+		}
+		bundle.Memories = append(bundle.Memories, seed)
+	}
+	return bundle
+}
+
+// AgentRequest returns the request that creates the bundle's agent, with its
+// prompts and tool presets folded back into the agent's configuration
+func (b *AgentBundle) AgentRequest() *CreateAgentRequest {
+	req := b.Agent
+	if b.Prompts != nil {
+		if b.Prompts.System != "" {
+			req.SystemPrompt = b.Prompts.System
+		}
+		if len(b.Prompts.Localized) > 0 {
+			req.LocalizedPrompts = b.Prompts.Localized
+		}
+	}
+	if len(b.Presets) > 0 {
+		config := make(map[string]interface{}, len(req.Config)+1)
+		for key, value := range req.Config {
+			config[key] = value
+		}
+		presets := make(map[string]interface{}, len(b.Presets))
+		if existing, ok := config["tool_presets"].(map[string]interface{}); ok {
+			for tool, preset := range existing {
+				presets[tool] = preset
+			}
+		}
+		for tool, preset := range b.Presets {
+			presets[tool] = map[string]interface{}(preset)
+		}
+		config["tool_presets"] = presets
+		req.Config = config
+	}
+	return &req
+}
+
+// SeedMemories returns the memories the bundle gives the agent
+func (b *AgentBundle) SeedMemories(agentID string) []*Memory {
+	memories := make([]*Memory, len(b.Memories))
+	for i, seed := range b.Memories {
+		memoryType := seed.MemoryType
+		if memoryType == "" {
+			memoryType = "fact"
+		}
+		importance := seed.Importance
+		if importance == 0 {
+			importance = 5
+		}
+		tags := seed.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		memories[i] = &Memory{
+			AgentID:    agentID,
+			Topic:      seed.Topic,
+			Content:    seed.Content,
+			MemoryType: memoryType,
+			Importance: importance,
+			Tags:       JSON{"tags": tags},
+			Metadata:   JSON{"source": "bundle"},
+		}
+	}
+	return memories
+}
+
+// AgentImportResponse reports an installed agent bundle
+type AgentImportResponse struct {
+	Agent            *Agent        `json:"agent" validate:"-"` // validated as AgentRequest()
+	MemoriesImported int           `json:"memories_imported"`
+	Warnings         []LintWarning `json:"warnings,omitempty"`
+}
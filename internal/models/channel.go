@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ChannelBinding maps a conversation on an external chat platform, e.g. a
+// Discord channel or thread, to the session that continues it
+type ChannelBinding struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	Platform   string    `json:"platform" gorm:"not null;uniqueIndex:idx_channel_bindings_external"`
+	ExternalID string    `json:"external_id" gorm:"not null;uniqueIndex:idx_channel_bindings_external"`
+	SessionID  string    `json:"session_id" gorm:"not null;index"`
+	AgentID    string    `json:"agent_id" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (b *ChannelBinding) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == "" {
+		b.ID = uuid.New().String()
+	}
+	return nil
+}
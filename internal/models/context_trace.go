@@ -0,0 +1,138 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// What a context strategy did with a history message
+const (
+	ContextIncluded   = "included"
+	ContextExcluded   = "excluded"
+	ContextSummarized = "summarized"
+)
+
+// ContextTrace records how the context of a turn's reply was built from the
+// session history: which messages were included, excluded or summarized,
+// and why
+type ContextTrace struct {
+	TurnID     string           `json:"turn_id" gorm:"primaryKey"`
+	SessionID  string           `json:"session_id" gorm:"not null;index"`
+	Strategy   string           `json:"strategy" gorm:"not null"`
+	Settings   JSON             `json:"settings" gorm:"type:json"` // the thresholds the strategy applied
+	Messages   int              `json:"messages"`                  // history messages considered
+	Included   int              `json:"included"`
+	Excluded   int              `json:"excluded"`
+	Summarized int              `json:"summarized"`
+	Decisions  ContextDecisions `json:"decisions" gorm:"type:json"`
+	Tokens     *PromptBreakdown `json:"tokens,omitempty" gorm:"type:json"` // estimated prompt tokens by part
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+// PromptBreakdown splits the prompt tokens of a reply by what they were
+// spent on. Tokens are estimated from the length of each part, so they are
+// comparable between parts and turns rather than exact.
+type PromptBreakdown struct {
+	SystemPrompt int64 `json:"system_prompt"` // the agent's prompt and instructions
+	ToolSchemas  int64 `json:"tool_schemas"`  // the generated tool prompt and native tool definitions
+	History      int64 `json:"history"`       // earlier messages, summaries and tool results
+	UserMessage  int64 `json:"user_message"`
+	Total        int64 `json:"total"`
+}
+
+func (b PromptBreakdown) Value() (driver.Value, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (b *PromptBreakdown) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into PromptBreakdown", value)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, b)
+}
+
+// ContextDecision is what happened to one history message
+type ContextDecision struct {
+	MessageID string `json:"message_id,omitempty"` // empty for messages not stored yet, such as tool results
+	Role      string `json:"role"`
+	Sequence  int64  `json:"sequence,omitempty"`
+	Decision  string `json:"decision"`
+	Reason    string `json:"reason"`
+}
+
+// NewContextTrace starts the trace of a strategy with the settings it
+// applies
+func NewContextTrace(strategy string, settings JSON) *ContextTrace {
+	return &ContextTrace{Strategy: strategy, Settings: settings, Decisions: ContextDecisions{}}
+}
+
+// Record adds the decision about a message
+func (t *ContextTrace) Record(message *Message, decision, reason string) {
+	t.Decisions = append(t.Decisions, ContextDecision{
+		MessageID: message.ID,
+		Role:      message.Role,
+		Sequence:  message.Sequence,
+		Decision:  decision,
+		Reason:    reason,
+	})
+	t.Messages++
+	switch decision {
+	case ContextIncluded:
+		t.Included++
+	case ContextExcluded:
+		t.Excluded++
+	case ContextSummarized:
+		t.Summarized++
+	}
+}
+
+// ContextDecisions is stored as a JSON array
+type ContextDecisions []ContextDecision
+
+func (d ContextDecisions) Value() (driver.Value, error) {
+	if d == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (d *ContextDecisions) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*d = ContextDecisions{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ContextDecisions", value)
+	}
+	if len(data) == 0 {
+		*d = ContextDecisions{}
+		return nil
+	}
+	return json.Unmarshal(data, d)
+}
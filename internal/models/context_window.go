@@ -0,0 +1,28 @@
+package models
+
+// Sources of a context window
+const (
+	ContextWindowAgent    = "agent"    // the agent's num_ctx option
+	ContextWindowConfig   = "config"   // llm.context_windows
+	ContextWindowProvider = "provider" // looked up on the provider, e.g. Ollama /api/show
+)
+
+// ContextWindowReport describes the context window of an agent's model and
+// how much of it the agent's recent prompts used. Utilization is the share
+// of the window a prompt filled, 0 to 1 or above for prompts that
+// overflowed it.
+type ContextWindowReport struct {
+	Tokens             int     `json:"tokens,omitempty"` // 0 when unknown
+	Source             string  `json:"source,omitempty"`
+	RecentTurns        int     `json:"recent_turns"`
+	AverageUtilization float64 `json:"average_utilization"`
+	PeakUtilization    float64 `json:"peak_utilization"`
+	TurnsOverThreshold int     `json:"turns_over_threshold"` // turns above 80% of the window
+	Warning            string  `json:"warning,omitempty"`
+}
+
+// AgentWithContext is an agent with the report on its context window
+type AgentWithContext struct {
+	*Agent
+	ContextWindow *ContextWindowReport `json:"context_window,omitempty"`
+}
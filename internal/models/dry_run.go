@@ -0,0 +1,64 @@
+package models
+
+// DryRunRequest runs an agent on a message outside of any session, so that
+// prompts and tool descriptions can be tried out. The model is offered only
+// the stubbed tools, and their calls are answered with the canned responses;
+// no real tool runs and nothing is saved.
+type DryRunRequest struct {
+	Message string          `json:"message" validate:"required"`
+	History []DryRunMessage `json:"history,omitempty" validate:"max=100,dive"` // earlier turns, oldest first
+	// SystemPrompt replaces the agent's system prompt for this run
+	SystemPrompt string     `json:"system_prompt,omitempty" validate:"max=50000"`
+	Tools        []ToolStub `json:"tools,omitempty" validate:"max=32,dive"`
+	ToolChoice   string     `json:"tool_choice,omitempty"` // "auto", "none", "required" or "tool:<name>"
+	Temperature  *float32   `json:"temperature,omitempty" validate:"omitempty,min=0,max=2"`
+	// MaxIterations caps the model calls; it defaults to 5
+	MaxIterations int `json:"max_iterations,omitempty" validate:"omitempty,min=1,max=10"`
+}
+
+// DryRunMessage is an earlier turn of a dry run's conversation
+type DryRunMessage struct {
+	Role    string `json:"role" validate:"required,oneof=system user assistant"`
+	Content string `json:"content" validate:"required"`
+}
+
+// ToolStub offers a tool to the model in a dry run and answers every call
+// of it with Response, or fails it with Error. Registered tools keep their
+// schema unless the stub overrides it; other tools need Parameters.
+type ToolStub struct {
+	Name        string                 `json:"name" validate:"required,max=100"`
+	Description string                 `json:"description,omitempty" validate:"max=2000"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"` // JSON Schema of the arguments
+	Response    interface{}            `json:"response,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+}
+
+// DryRunToolCall is a tool call the model made in a dry run and the stubbed
+// answer it got
+type DryRunToolCall struct {
+	Iteration int                    `json:"iteration"`
+	ID        string                 `json:"id"`
+	ToolName  string                 `json:"tool_name"`
+	Arguments map[string]interface{} `json:"arguments"`
+	Success   bool                   `json:"success"`
+	Result    interface{}            `json:"result,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// DryRunUsage sums the token usage of a dry run's model calls
+type DryRunUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// DryRunResult is the answer of a dry run with the tool calls that led to it
+type DryRunResult struct {
+	AgentID      string           `json:"agent_id"`
+	Response     string           `json:"response"`
+	FinishReason string           `json:"finish_reason"`
+	Iterations   int              `json:"iterations"`
+	SystemPrompt string           `json:"system_prompt"` // as sent to the model, with the tool descriptions
+	ToolCalls    []DryRunToolCall `json:"tool_calls"`
+	Usage        DryRunUsage      `json:"usage"`
+}
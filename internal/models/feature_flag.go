@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Where a feature flag is defined
+const (
+	FeatureFlagSourceConfig  = "config"
+	FeatureFlagSourceRuntime = "runtime" // set through the admin API, overriding the config
+)
+
+// FeatureFlag controls an experimental capability. It is on for a session
+// when it is enabled, lists the session's agent, or the session falls into
+// its rollout percentage. Tools listed by a flag are only offered where it
+// is on.
+type FeatureFlag struct {
+	Name        string     `json:"name" gorm:"primaryKey"`
+	Description string     `json:"description,omitempty"`
+	Enabled     bool       `json:"enabled" gorm:"not null;default:false"`
+	Agents      StringList `json:"agents" gorm:"type:json"`
+	Rollout     int        `json:"rollout" gorm:"not null;default:0"` // percent of sessions
+	Tools       StringList `json:"tools" gorm:"type:json"`
+	Source      string     `json:"source" gorm:"-"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// SetFeatureFlagRequest represents the request payload for setting a flag at
+// runtime
+type SetFeatureFlagRequest struct {
+	Description string   `json:"description,omitempty" validate:"max=500"`
+	Enabled     bool     `json:"enabled"`
+	Agents      []string `json:"agents,omitempty" validate:"max=1000"`
+	Rollout     int      `json:"rollout" validate:"min=0,max=100"`
+	Tools       []string `json:"tools,omitempty" validate:"max=100,dive,min=1"`
+}
+
+// ToFeatureFlag converts the request into the named flag
+func (r *SetFeatureFlagRequest) ToFeatureFlag(name string) *FeatureFlag {
+	return &FeatureFlag{
+		Name:        name,
+		Description: r.Description,
+		Enabled:     r.Enabled,
+		Agents:      StringList(r.Agents),
+		Rollout:     r.Rollout,
+		Tools:       StringList(r.Tools),
+		Source:      FeatureFlagSourceRuntime,
+	}
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Job statuses
+const (
+	JobStatusPending   = "pending"   // waiting for run_at
+	JobStatusRunning   = "running"   // claimed by a worker
+	JobStatusSucceeded = "succeeded" // finished successfully
+	JobStatusFailed    = "failed"    // last attempt failed, retry scheduled
+	JobStatusDead      = "dead"      // retries exhausted, needs manual requeue
+)
+
+// Job represents an asynchronous unit of work in the outbox table. Jobs can be
+// enqueued inside the same transaction as the data change that caused them.
+type Job struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	Type        string     `json:"type" gorm:"not null;index"`
+	Payload     JSON       `json:"payload" gorm:"type:json"`
+	Status      string     `json:"status" gorm:"not null;default:pending;index"`
+	Attempts    int        `json:"attempts" gorm:"default:0"`
+	MaxAttempts int        `json:"max_attempts" gorm:"default:5"`
+	RunAt       time.Time  `json:"run_at" gorm:"index"`
+	LastError   string     `json:"last_error,omitempty" gorm:"type:text"`
+	LockedAt    *time.Time `json:"locked_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID and schedule the job
+func (j *Job) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == "" {
+		j.ID = uuid.New().String()
+	}
+	if j.Status == "" {
+		j.Status = JobStatusPending
+	}
+	if j.RunAt.IsZero() {
+		j.RunAt = time.Now()
+	}
+	return nil
+}
+
+// NewJob creates a pending job of the given type
+func NewJob(jobType string, payload map[string]interface{}) *Job {
+	return &Job{
+		Type:    jobType,
+		Payload: JSON(payload),
+		Status:  JobStatusPending,
+		RunAt:   time.Now(),
+	}
+}
+
+// JobList represents a paginated list of jobs
+type JobList struct {
+	Jobs       []*Job `json:"jobs"`
+	TotalCount int64  `json:"total_count"`
+	Page       int    `json:"page"`
+	PageSize   int    `json:"page_size"`
+	HasMore    bool   `json:"has_more"`
+}
@@ -0,0 +1,33 @@
+package models
+
+// Lint warning codes
+const (
+	LintPromptEmpty               = "PROMPT_EMPTY"
+	LintPromptTooLong             = "PROMPT_TOO_LONG"
+	LintContradiction             = "CONTRADICTORY_INSTRUCTIONS"
+	LintToolDescriptionMissing    = "TOOL_DESCRIPTION_MISSING"
+	LintToolDescriptionShort      = "TOOL_DESCRIPTION_SHORT"
+	LintToolParameterUndocumented = "TOOL_PARAMETER_UNDOCUMENTED"
+	LintDuplicateTool             = "DUPLICATE_TOOL"
+	LintToolNotEnabled            = "TOOL_NOT_ENABLED"
+)
+
+// LintWarning is an issue found in an agent's prompt or tools. Warnings do
+// not stop an agent from being saved.
+type LintWarning struct {
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"` // e.g. "system_prompt" or "config.tools[2]"
+	Message string `json:"message"`
+}
+
+// LintResult lists the warnings for an agent
+type LintResult struct {
+	Warnings []LintWarning `json:"warnings"`
+}
+
+// AgentWithWarnings is a saved agent with the warnings for its prompt and
+// tools
+type AgentWithWarnings struct {
+	*Agent
+	Warnings []LintWarning `json:"warnings,omitempty"`
+}
@@ -20,6 +20,8 @@ type Memory struct {
 	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty" gorm:"index"`                 // optional expiration
+	AccessCount    int        `json:"access_count" gorm:"not null;default:0"` // recalls that returned the memory
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
 }
 
 // BeforeCreate hook to generate UUID if not provided
@@ -7,13 +7,26 @@ import (
 	"gorm.io/gorm"
 )
 
+// Message statuses. A user message that starts a tool-calling turn stays
+// pending until the turn's final answer has been persisted; turns interrupted
+// by an error or a crash are marked incomplete and can be retried by clients.
+const (
+	MessageStatusComplete   = "complete"
+	MessageStatusPending    = "pending"
+	MessageStatusIncomplete = "incomplete"
+)
+
 // Message represents a single message in a chat session
 type Message struct {
 	ID        string    `json:"id" gorm:"primaryKey"`
-	SessionID string    `json:"session_id" gorm:"not null" validate:"required"`
+	SessionID string    `json:"session_id" gorm:"not null;uniqueIndex:idx_messages_session_sequence,priority:1" validate:"required"`
+	Sequence  int64     `json:"sequence" gorm:"not null;default:0;uniqueIndex:idx_messages_session_sequence,priority:2"` // Per-session position, assigned by the repository
 	Role      string    `json:"role" gorm:"not null" validate:"required,oneof=user assistant system"`
 	Content   string    `json:"content" gorm:"type:text;not null" validate:"required"`
 	Metadata  JSON      `json:"metadata" gorm:"type:json"`
+	Status    string    `json:"status" gorm:"default:complete;index"`
+	TurnID    string    `json:"turn_id,omitempty" gorm:"index"` // ID of the user message that started the turn, see Turn
+	Hidden    bool      `json:"hidden,omitempty" gorm:"not null;default:false"` // In the model's context but not in transcripts, see CreateNoteRequest
 	CreatedAt time.Time `json:"created_at"`
 
 	// Relationships
@@ -25,6 +38,13 @@ func (m *Message) BeforeCreate(tx *gorm.DB) error {
 	if m.ID == "" {
 		m.ID = uuid.New().String()
 	}
+	// A user message starts a new turn
+	if m.TurnID == "" && m.Role == "user" {
+		m.TurnID = m.ID
+	}
+	if m.Status == "" {
+		m.Status = MessageStatusComplete
+	}
 	return nil
 }
 
@@ -33,6 +53,20 @@ type CreateMessageRequest struct {
 	Role     string                 `json:"role" validate:"required,oneof=user assistant system"`
 	Content  string                 `json:"content" validate:"required"`
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// Generate asks the agent to reply to a user message. Without it the
+	// message is only appended, e.g. to queue multi-part input that a later
+	// message or chat request answers.
+	Generate bool `json:"generate,omitempty"`
+}
+
+// CreateNoteRequest is the payload for appending a system note to a session.
+// Notes let operators and integrations steer a live conversation ("the user
+// has upgraded to premium"): the model sees them like any other message, but
+// they are hidden from transcripts unless visible is set.
+type CreateNoteRequest struct {
+	Content  string                 `json:"content" validate:"required"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Visible  bool                   `json:"visible,omitempty"`
 }
 
 // ChatRequest represents a request to chat with an agent
@@ -79,4 +113,29 @@ type MessageList struct {
 	Page       int       `json:"page"`
 	PageSize   int       `json:"page_size"`
 	HasMore    bool      `json:"has_more"`
+	NextCursor int64     `json:"next_cursor,omitempty"` // Sequence to pass as ?after= for the next page
+}
+
+// SequenceGap is a run of sequence numbers missing from a session's messages
+type SequenceGap struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// SequenceGaps returns the runs of missing sequence numbers in messages,
+// which must be ordered by sequence. Messages without a sequence (from before
+// sequences were introduced) are ignored.
+func SequenceGaps(messages []*Message) []SequenceGap {
+	var gaps []SequenceGap
+	var last int64
+	for _, message := range messages {
+		if message.Sequence == 0 {
+			continue
+		}
+		if last > 0 && message.Sequence > last+1 {
+			gaps = append(gaps, SequenceGap{From: last + 1, To: message.Sequence - 1})
+		}
+		last = message.Sequence
+	}
+	return gaps
 }
\ No newline at end of file
@@ -202,4 +202,17 @@ func TestMessageList_Structure(t *testing.T) {
 	assert.Equal(t, 1, messageList.Page)
 	assert.Equal(t, 10, messageList.PageSize)
 	assert.False(t, messageList.HasMore)
-}
\ No newline at end of file
+}
+func TestSequenceGaps(t *testing.T) {
+	messages := func(sequences ...int64) []*Message {
+		result := make([]*Message, len(sequences))
+		for i, sequence := range sequences {
+			result[i] = &Message{Sequence: sequence}
+		}
+		return result
+	}
+
+	assert.Empty(t, SequenceGaps(messages(1, 2, 3)))
+	assert.Empty(t, SequenceGaps(messages(0, 0, 4, 5)))
+	assert.Equal(t, []SequenceGap{{From: 3, To: 3}, {From: 6, To: 8}}, SequenceGaps(messages(1, 2, 4, 5, 9)))
+}
@@ -7,15 +7,33 @@ import (
 	"gorm.io/gorm"
 )
 
+// Session statuses
+const (
+	SessionStatusActive   = "active"
+	SessionStatusArchived = "archived" // messages moved to message_archives
+)
+
 // ChatSession represents a conversation session with an agent
 type ChatSession struct {
-	ID              string    `json:"id" gorm:"primaryKey"`
-	AgentID         string    `json:"agent_id" gorm:"not null" validate:"required"`
-	Title           string    `json:"title"`
-	ContextStrategy string    `json:"context_strategy" gorm:"default:last_n" validate:"oneof=last_n summarize sliding_window"`
-	ContextConfig   JSON      `json:"context_config" gorm:"type:json"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID              string             `json:"id" gorm:"primaryKey"`
+	AgentID         string             `json:"agent_id" gorm:"not null" validate:"required"`
+	Title           string             `json:"title"`
+	ContextStrategy string             `json:"context_strategy" gorm:"default:last_n" validate:"oneof=last_n summarize sliding_window composite"`
+	ContextConfig   JSON               `json:"context_config" gorm:"type:json"`
+	ToolConfig      *SessionToolConfig `json:"tool_config,omitempty" gorm:"type:json"`
+	Metadata        JSON               `json:"metadata" gorm:"type:json"`
+	Variables       JSON               `json:"variables,omitempty" gorm:"type:json"` // structured context for the prompt and the session_vars tool
+	Tags            StringList         `json:"tags" gorm:"type:json"`
+	Starred         bool               `json:"starred" gorm:"not null;default:false;index"`
+	Language        string             `json:"language,omitempty"` // detected from the latest user message
+	Status          string             `json:"status" gorm:"default:active;index"`
+	ArchivedAt      *time.Time         `json:"archived_at,omitempty"`
+	LastMessageAt   *time.Time         `json:"last_message_at,omitempty" gorm:"index"`
+	LastMessageRole string             `json:"last_message_role,omitempty"` // user or assistant
+	UnreadCount     int                `json:"unread_count" gorm:"not null;default:0"`
+	LastReadAt      *time.Time         `json:"last_read_at,omitempty"`
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
 
 	// Relationships
 	Agent    Agent     `json:"agent,omitempty" gorm:"foreignKey:AgentID"`
@@ -27,21 +45,96 @@ func (s *ChatSession) BeforeCreate(tx *gorm.DB) error {
 	if s.ID == "" {
 		s.ID = uuid.New().String()
 	}
+	if s.Status == "" {
+		s.Status = SessionStatusActive
+	}
 	return nil
 }
 
+// ActivityColumns are the columns of a session's activity summary and its
+// detected language. They are maintained by RecordActivity, MarkRead and
+// SetLanguage, never by whole-session updates.
+var ActivityColumns = []string{"last_message_at", "last_message_role", "unread_count", "last_read_at", "language"}
+
+// IsArchived reports whether the session's messages are in cold storage
+func (s *ChatSession) IsArchived() bool {
+	return s.Status == SessionStatusArchived
+}
+
 // CreateSessionRequest represents the request payload for creating a session
 type CreateSessionRequest struct {
 	Title           string                 `json:"title"`
-	ContextStrategy string                 `json:"context_strategy,omitempty" validate:"omitempty,oneof=last_n summarize sliding_window"`
+	ContextStrategy string                 `json:"context_strategy,omitempty" validate:"omitempty,oneof=last_n summarize sliding_window composite"`
 	ContextConfig   map[string]interface{} `json:"context_config,omitempty"`
+	ToolConfig      *SessionToolConfig     `json:"tool_config,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+	Variables       map[string]interface{} `json:"variables,omitempty" validate:"omitempty,max=50,dive,keys,min=1,max=64,endkeys"`
+	Tags            []string               `json:"tags,omitempty" validate:"omitempty,max=20,dive,min=1,max=50"`
+	Starred         bool                   `json:"starred,omitempty"`
 }
 
 // UpdateSessionRequest represents the request payload for updating a session
 type UpdateSessionRequest struct {
 	Title           *string                `json:"title,omitempty"`
-	ContextStrategy *string                `json:"context_strategy,omitempty" validate:"omitempty,oneof=last_n summarize sliding_window"`
+	ContextStrategy *string                `json:"context_strategy,omitempty" validate:"omitempty,oneof=last_n summarize sliding_window composite"`
 	ContextConfig   map[string]interface{} `json:"context_config,omitempty"`
+	ToolConfig      *SessionToolConfig     `json:"tool_config,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+	Variables       map[string]interface{} `json:"variables,omitempty" validate:"omitempty,max=50,dive,keys,min=1,max=64,endkeys"` // replaces all variables
+	Tags            []string               `json:"tags,omitempty" validate:"omitempty,max=20,dive,min=1,max=50"`
+	Starred         *bool                  `json:"starred,omitempty"`
+}
+
+// MaxSessionVariables bounds the variables of a session
+const MaxSessionVariables = 50
+
+// PatchSessionVariablesRequest merges variables into those of a session; a
+// null value removes the variable
+type PatchSessionVariablesRequest struct {
+	Variables map[string]interface{} `json:"variables" validate:"required,max=50,dive,keys,min=1,max=64,endkeys"`
+}
+
+// MergeVariables sets the given variables, removing those set to nil
+func (s *ChatSession) MergeVariables(variables map[string]interface{}) {
+	if s.Variables == nil {
+		s.Variables = make(JSON)
+	}
+	for name, value := range variables {
+		if value == nil {
+			delete(s.Variables, name)
+			continue
+		}
+		s.Variables[name] = value
+	}
+}
+
+// TransferSessionRequest represents the request payload for handing a session
+// over to another agent. With summarize, the outgoing agent writes a handoff
+// summary for the incoming one.
+type TransferSessionRequest struct {
+	AgentID   string `json:"agent_id" validate:"required"`
+	Summarize bool   `json:"summarize,omitempty"`
+	Reason    string `json:"reason,omitempty" validate:"max=500"`
+}
+
+// TransferSessionResponse is the session after a handoff together with the
+// note recording it
+type TransferSessionResponse struct {
+	Session *ChatSession `json:"session"`
+	Handoff *Message     `json:"handoff"`
+}
+
+// SessionFilter holds the filtering and pagination options for listing an
+// agent's sessions. An empty Status matches all sessions; Metadata matches
+// sessions whose metadata has all the given values.
+type SessionFilter struct {
+	AgentID  string
+	Status   string
+	Tag      string
+	Starred  *bool
+	Metadata map[string]string
+	Limit    int
+	Offset   int
 }
 
 // ToSession converts CreateSessionRequest to ChatSession
@@ -51,6 +144,9 @@ func (r *CreateSessionRequest) ToSession(agentID string) *ChatSession {
 		Title:           r.Title,
 		ContextStrategy: "last_n",
 		ContextConfig:   make(JSON),
+		Metadata:        make(JSON),
+		Tags:            NormalizeTags(r.Tags),
+		Starred:         r.Starred,
 	}
 
 	if r.ContextStrategy != "" {
@@ -59,6 +155,13 @@ func (r *CreateSessionRequest) ToSession(agentID string) *ChatSession {
 	if r.ContextConfig != nil {
 		session.ContextConfig = JSON(r.ContextConfig)
 	}
+	if r.Metadata != nil {
+		session.Metadata = JSON(r.Metadata)
+	}
+	if r.Variables != nil {
+		session.Variables = JSON(r.Variables)
+	}
+	session.ToolConfig = r.ToolConfig
 
 	return session
 }
@@ -74,4 +177,19 @@ func (s *ChatSession) UpdateFromRequest(req *UpdateSessionRequest) {
 	if req.ContextConfig != nil {
 		s.ContextConfig = JSON(req.ContextConfig)
 	}
-}
\ No newline at end of file
+	if req.ToolConfig != nil {
+		s.ToolConfig = req.ToolConfig
+	}
+	if req.Metadata != nil {
+		s.Metadata = JSON(req.Metadata)
+	}
+	if req.Variables != nil {
+		s.Variables = JSON(req.Variables)
+	}
+	if req.Tags != nil {
+		s.Tags = NormalizeTags(req.Tags)
+	}
+	if req.Starred != nil {
+		s.Starred = *req.Starred
+	}
+}
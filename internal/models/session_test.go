@@ -2,6 +2,7 @@ package models
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -147,4 +148,19 @@ func TestChatSession_UpdateFromRequest(t *testing.T) {
 			tt.check(t, &testSession)
 		})
 	}
-}
\ No newline at end of file
+}
+func TestSessionToolConfig_ValueScan(t *testing.T) {
+	timeout := 15
+	config := SessionToolConfig{EnabledTools: []string{"calculator"}, ToolTimeout: &timeout}
+
+	value, err := config.Value()
+	assert.NoError(t, err)
+
+	var scanned SessionToolConfig
+	assert.NoError(t, scanned.Scan(value))
+	assert.Equal(t, config, scanned)
+	assert.Equal(t, 15*time.Second, scanned.Timeout())
+
+	var unset *SessionToolConfig
+	assert.Zero(t, unset.Timeout())
+}
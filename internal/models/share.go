@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// CreateShareRequest represents the request payload for sharing a session's
+// transcript through a signed read-only link
+type CreateShareRequest struct {
+	ExpiresIn    int  `json:"expires_in,omitempty" validate:"omitempty,min=60"` // seconds, defaults to the configured expiry
+	ExcludeTools bool `json:"exclude_tools,omitempty"`                          // leave tool calls and results out of the transcript
+}
+
+// ShareLink is a signed link to a session's transcript. The token is the
+// only credential; anyone holding it can read the transcript until it
+// expires.
+type ShareLink struct {
+	Token        string    `json:"token"`
+	URL          string    `json:"url"`
+	IncludeTools bool      `json:"include_tools"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// SharedTranscript is the read-only view of a session served to holders of a
+// share link. It leaves out hidden messages, message metadata and, unless the
+// link includes them, tool details.
+type SharedTranscript struct {
+	Title     string          `json:"title,omitempty"`
+	AgentName string          `json:"agent_name"`
+	Messages  []SharedMessage `json:"messages"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// SharedMessage is a message of a shared transcript
+type SharedMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []SharedToolCall `json:"tool_calls,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// SharedToolCall is a tool call of a shared transcript
+type SharedToolCall struct {
+	ToolName  string `json:"tool_name"`
+	Arguments JSON   `json:"arguments,omitempty"`
+	Result    *JSON  `json:"result,omitempty"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+}
@@ -0,0 +1,44 @@
+package models
+
+// Simulation statuses
+const (
+	SimulationStatusCompleted  = "completed"  // every turn was answered
+	SimulationStatusIncomplete = "incomplete" // a turn failed; the turns before it are kept
+)
+
+// MaxSimulationTurns bounds the length of a simulated conversation
+const MaxSimulationTurns = 50
+
+// SimulationRequest represents a request to let two agents talk to each other
+type SimulationRequest struct {
+	FirstAgentID   string `json:"first_agent_id" validate:"required"`
+	SecondAgentID  string `json:"second_agent_id" validate:"required"`
+	OpeningMessage string `json:"opening_message" validate:"required"`
+	// Turns is the number of replies, alternating between the agents and
+	// starting with the first; it defaults to 6
+	Turns int    `json:"turns,omitempty" validate:"omitempty,min=1,max=50"`
+	Title string `json:"title,omitempty" validate:"omitempty,max=200"`
+}
+
+// SimulationTurn is one reply of a simulated conversation
+type SimulationTurn struct {
+	Turn      int    `json:"turn"`
+	AgentID   string `json:"agent_id"`
+	AgentName string `json:"agent_name"`
+	SessionID string `json:"session_id"`
+	MessageID string `json:"message_id"`
+	Content   string `json:"content"`
+}
+
+// SimulationResult is the dialogue of a simulation. Each agent keeps its
+// side of the conversation in a session of its own, where the other agent's
+// replies are the user messages.
+type SimulationResult struct {
+	ID              string           `json:"id"`
+	Status          string           `json:"status"`
+	FirstSessionID  string           `json:"first_session_id"`
+	SecondSessionID string           `json:"second_session_id"`
+	OpeningMessage  string           `json:"opening_message"`
+	Turns           []SimulationTurn `json:"turns"`
+	Error           string           `json:"error,omitempty"`
+}
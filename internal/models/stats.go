@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// AdminStats aggregates usage data for monitoring dashboards
+type AdminStats struct {
+	Since            time.Time            `json:"since"`
+	GeneratedAt      time.Time            `json:"generated_at"`
+	Totals           StatsTotals          `json:"totals"`
+	ChatsPerDay      []DailyChatStats     `json:"chats_per_day"`
+	TokensByProvider []ProviderTokenStats `json:"tokens_by_provider"`
+	Tools            []ToolUsageStats     `json:"tools"`
+	Latency          LatencyStats         `json:"latency"`
+	PromptTokens     PromptTokenStats     `json:"prompt_tokens"`
+}
+
+// StatsTotals holds point-in-time counts across the whole database
+type StatsTotals struct {
+	Agents            int64 `json:"agents"`
+	Sessions          int64 `json:"sessions"`
+	ActiveSessions    int64 `json:"active_sessions"`
+	ArchivedSessions  int64 `json:"archived_sessions"`
+	Messages          int64 `json:"messages"`
+	ToolExecutions    int64 `json:"tool_executions"`
+	DatabaseSizeBytes int64 `json:"database_size_bytes"`
+}
+
+// DailyChatStats counts chat turns started on a UTC day
+type DailyChatStats struct {
+	Date      string  `json:"date"`
+	Chats     int64   `json:"chats"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// ProviderTokenStats sums token usage of assistant replies per provider
+type ProviderTokenStats struct {
+	Provider         string `json:"provider"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+	Responses        int64  `json:"responses"`
+}
+
+// LatencyStats holds LLM response latency percentiles
+type LatencyStats struct {
+	Samples int   `json:"samples"`
+	P50MS   int64 `json:"p50_ms"`
+	P95MS   int64 `json:"p95_ms"`
+	MaxMS   int64 `json:"max_ms"`
+}
+
+// ToolDuration is a single tool execution time sample
+type ToolDuration struct {
+	ToolName   string
+	DurationMS int64
+}
+
+// CostFilter selects the replies and tool calls summed into a CostSummary
+// or PromptTokenStats
+type CostFilter struct {
+	SessionID string
+	AgentID   string
+	Since     time.Time
+}
+
+// CostSummary totals the priced usage of a session or agent
+type CostSummary struct {
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	LLMCost          float64 `json:"llm_cost"`
+	ToolCost         float64 `json:"tool_cost"`
+	TotalCost        float64 `json:"total_cost"`
+}
+
+// PromptTokenStats sums the prompt breakdowns of replies, to show which
+// part of the prompt the tokens go to
+type PromptTokenStats struct {
+	Replies  int64           `json:"replies"` // replies with a breakdown
+	Total    PromptBreakdown `json:"total"`
+	PerReply PromptBreakdown `json:"per_reply"` // average, rounded down
+}
+
+// UsageReport is the spending of a session or an agent against its budget
+type UsageReport struct {
+	AgentID   string           `json:"agent_id"`
+	SessionID string           `json:"session_id,omitempty"`
+	Since     *time.Time       `json:"since,omitempty"`
+	Usage     CostSummary      `json:"usage"`
+	Prompt    PromptTokenStats `json:"prompt"`
+
+	// Budget is the applicable spending limit, 0 when unlimited
+	Budget    float64  `json:"budget"`
+	Remaining *float64 `json:"remaining,omitempty"`
+}
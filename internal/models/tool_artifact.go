@@ -0,0 +1,49 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// ToolArtifact references a binary file a tool returned, such as an image
+// or a PDF, kept in media storage. Tools that consume artifacts take its ID.
+type ToolArtifact struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url,omitempty"` // time-limited download URL
+	ToolCallID  string `json:"tool_call_id,omitempty"`
+	ToolName    string `json:"tool_name,omitempty"`
+}
+
+// ToolArtifacts are the artifacts of a tool call
+type ToolArtifacts []ToolArtifact
+
+// Value stores the artifacts as JSON
+func (a ToolArtifacts) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads artifacts stored as JSON
+func (a *ToolArtifacts) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil
+	}
+	return json.Unmarshal(data, a)
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tool choices of chat requests
+const (
+	ToolChoiceAuto     = "auto"     // the model decides whether to call tools
+	ToolChoiceNone     = "none"     // no tools are offered
+	ToolChoiceRequired = "required" // the model must call at least one tool
+	// ToolChoiceToolPrefix forces a specific tool, as in "tool:calculator"
+	ToolChoiceToolPrefix = "tool:"
+)
+
+// ToolChoice is a parsed tool_choice
+type ToolChoice struct {
+	Mode string // ToolChoiceAuto, ToolChoiceNone or ToolChoiceRequired
+	Tool string // the tool that must be called, if a specific one
+}
+
+// ParseToolChoice parses a tool_choice. An empty choice means auto; a bare
+// tool name is read as "tool:<name>", as earlier clients sent it.
+func ParseToolChoice(choice string) (ToolChoice, error) {
+	switch choice {
+	case "", ToolChoiceAuto:
+		return ToolChoice{Mode: ToolChoiceAuto}, nil
+	case ToolChoiceNone, ToolChoiceRequired:
+		return ToolChoice{Mode: choice}, nil
+	}
+	name := strings.TrimSpace(strings.TrimPrefix(choice, ToolChoiceToolPrefix))
+	if name == "" {
+		return ToolChoice{}, fmt.Errorf("tool_choice %q names no tool", choice)
+	}
+	return ToolChoice{Mode: ToolChoiceRequired, Tool: name}, nil
+}
+
+// Forces reports whether the model must call a tool
+func (c ToolChoice) Forces() bool {
+	return c.Mode == ToolChoiceRequired
+}
+
+// String returns the choice in request form
+func (c ToolChoice) String() string {
+	if c.Tool != "" {
+		return ToolChoiceToolPrefix + c.Tool
+	}
+	return c.Mode
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// ToolInjectionReport records what the prompt injection defense found in a
+// tool result
+type ToolInjectionReport struct {
+	// Stripped names the sanitization rules that removed markup, such as
+	// html_comment or role_tag
+	Stripped []string `json:"stripped,omitempty"`
+
+	// Classifier, Score and Matches are set when a classifier scored the
+	// result; Action when the score reached the threshold
+	Classifier string   `json:"classifier,omitempty"`
+	Score      float64  `json:"score,omitempty"`
+	Matches    []string `json:"matches,omitempty"`
+	Action     string   `json:"action,omitempty"`
+
+	// Error is set when the classifier failed
+	Error string `json:"error,omitempty"`
+}
+
+// Detected reports whether the classifier took action on the result
+func (r *ToolInjectionReport) Detected() bool {
+	return r != nil && r.Action != ""
+}
+
+// Value stores the report as JSON
+func (r ToolInjectionReport) Value() (driver.Value, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads a report stored as JSON
+func (r *ToolInjectionReport) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil
+	}
+	return json.Unmarshal(data, r)
+}
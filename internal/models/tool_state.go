@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Where a tool's state is defined
+const (
+	ToolStateSourceDefault = "default"
+	ToolStateSourceConfig  = "config"
+	ToolStateSourceRuntime = "runtime" // set through the admin API, overriding the config
+)
+
+// ToolState is whether a tool is disabled across the deployment. Disabled
+// tools are not offered to any agent, whatever its settings, and their calls
+// fail with TOOL_DISABLED. Only states set at runtime are stored.
+type ToolState struct {
+	Name      string    `json:"name" gorm:"primaryKey"`
+	Disabled  bool      `json:"disabled" gorm:"not null;default:false"`
+	Reason    string    `json:"reason,omitempty"`
+	Source    string    `json:"source" gorm:"-"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// SetToolStateRequest represents the request payload for disabling or
+// enabling a tool at runtime
+type SetToolStateRequest struct {
+	Disabled *bool  `json:"disabled" validate:"required"`
+	Reason   string `json:"reason,omitempty" validate:"max=500"`
+}
+
+// ToolStateList lists the state of every registered tool
+type ToolStateList struct {
+	Tools []*ToolState `json:"tools"`
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,7 +23,7 @@ type ToolCall struct {
 	UpdatedAt time.Time `json:"updated_at"`
 
 	// Relationships
-	Message Message `json:"message,omitempty" gorm:"foreignKey:MessageID"`
+	Message Message `json:"-" gorm:"foreignKey:MessageID"`
 }
 
 // BeforeCreate hook to generate UUID
@@ -46,17 +48,44 @@ type ToolCallResult struct {
 	Result   interface{}            `json:"result,omitempty"`
 	Error    string                 `json:"error,omitempty"`
 	Duration int64                  `json:"duration_ms"`
+
+	// ErrorCode is the machine-readable reason of a failed call
+	ErrorCode string `json:"error_code,omitempty"`
+
+	// Cost is what the call cost, reported by the tool or configured
+	Cost float64 `json:"cost,omitempty"`
+
+	// OutputErrors lists where the result breaks the tool's output schema
+	OutputErrors []ValidationError `json:"output_errors,omitempty"`
+
+	// Injection records what the prompt injection defense found in the
+	// result
+	Injection *ToolInjectionReport `json:"injection,omitempty"`
+
+	// Artifacts reference the binary files the tool returned
+	Artifacts ToolArtifacts `json:"artifacts,omitempty"`
 }
 
+// Priority classes of chats. Interactive chats get generation slots and
+// workers ahead of batch ones; chats without a priority are interactive.
+const (
+	PriorityInteractive = "interactive"
+	PriorityBatch       = "batch"
+)
+
 // EnhancedChatRequest extends ChatRequest with tool calling capabilities
 type EnhancedChatRequest struct {
-	Message     string                 `json:"message" validate:"required"`
-	Tools       []string               `json:"tools,omitempty"`       // Available tool names
-	ToolChoice  string                 `json:"tool_choice,omitempty"` // "auto", "none", or specific tool name
-	Metadata    map[string]interface{} `json:"metadata,omitempty"`
-	Stream      bool                   `json:"stream,omitempty"`
-	MaxTokens   *int                   `json:"max_tokens,omitempty"`
-	Temperature *float32               `json:"temperature,omitempty"`
+	Message          string                 `json:"message" validate:"required"`
+	Tools            []string               `json:"tools,omitempty"`       // Available tool names
+	ToolChoice       string                 `json:"tool_choice,omitempty"` // "auto", "none", "required" or "tool:<name>"
+	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+	Stream           bool                   `json:"stream,omitempty"`
+	MaxTokens        *int                   `json:"max_tokens,omitempty"`
+	Temperature      *float32               `json:"temperature,omitempty"`
+	Stop             []string               `json:"stop,omitempty" validate:"max=4,dive,required"`                       // Sequences that end the reply
+	ResponseLanguage string                 `json:"response_language,omitempty" validate:"omitempty,bcp47_language_tag"` // Overrides the session's language
+	Priority         string                 `json:"priority,omitempty" validate:"omitempty,oneof=interactive batch"`     // Scheduling class, interactive by default
+	Accept           string                 `json:"accept,omitempty"`                                                    // Formats of the reply, e.g. "text/html, text/plain;q=0.5"
 }
 
 // EnhancedChatResponse extends ChatResponse with tool calling information
@@ -64,9 +93,26 @@ type EnhancedChatResponse struct {
 	UserMessageID      string           `json:"user_message_id"`
 	AssistantMessageID string           `json:"assistant_message_id"`
 	Response           string           `json:"response"`
+	Format             string           `json:"format,omitempty"` // media type of Response
+	Attachments        []Attachment     `json:"attachments,omitempty"`
 	ToolCalls          []ToolCallResult `json:"tool_calls,omitempty"`
 	Metadata           map[string]interface{} `json:"metadata,omitempty"`
 	FinishReason       string           `json:"finish_reason,omitempty"` // "stop", "length", "tool_calls"
+	Citations          []Citation       `json:"citations,omitempty"`
+	Artifacts          []ToolArtifact   `json:"artifacts,omitempty"` // returned by the turn's tool calls, with download URLs
+}
+
+// Citation maps a segment of a response to the tool result it cites. Start
+// and End are byte offsets of Text in the response; the marker follows it.
+type Citation struct {
+	Marker     string `json:"marker"` // e.g. "[1]"
+	Source     int    `json:"source"`
+	Text       string `json:"text"`
+	Start      int    `json:"start"`
+	End        int    `json:"end"`
+	ToolCallID string `json:"tool_call_id"`
+	ToolName   string `json:"tool_name"`
+	URL        string `json:"url,omitempty"`
 }
 
 // ToolDefinition represents a tool schema for LLM providers
@@ -118,6 +164,39 @@ type SessionToolConfig struct {
 	ParallelToolCalls bool                  `json:"parallel_tool_calls,omitempty"`
 }
 
+// Value stores the session tool configuration as JSON
+func (c SessionToolConfig) Value() (driver.Value, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads a session tool configuration stored as JSON
+func (c *SessionToolConfig) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil
+	}
+	return json.Unmarshal(data, c)
+}
+
+// Timeout returns the session's tool timeout, or zero when unset
+func (c *SessionToolConfig) Timeout() time.Duration {
+	if c == nil || c.ToolTimeout == nil || *c.ToolTimeout <= 0 {
+		return 0
+	}
+	return time.Duration(*c.ToolTimeout) * time.Second
+}
+
 // ToolExecutionLog represents a log entry for tool execution
 type ToolExecutionLog struct {
 	ID          string    `json:"id" gorm:"primaryKey"`
@@ -128,12 +207,65 @@ type ToolExecutionLog struct {
 	Result      *JSON     `json:"result,omitempty" gorm:"type:json"`
 	Success     bool      `json:"success"`
 	Error       string    `json:"error,omitempty"`
+	ErrorCode   string    `json:"error_code,omitempty"`
 	Duration    int64     `json:"duration_ms"`
+	Cost        float64   `json:"cost,omitempty"`
 	ExecutedAt  time.Time `json:"executed_at"`
+
+	// Audit holds sanitized details of the outbound HTTP requests the tool
+	// made, under "http_requests", when HTTP capture is enabled
+	Audit *JSON `json:"audit,omitempty" gorm:"type:json"`
+
+	// Injection records what the prompt injection defense found in the
+	// result
+	Injection *ToolInjectionReport `json:"injection,omitempty" gorm:"type:json"`
+
+	// Artifacts reference the binary files the tool returned
+	Artifacts ToolArtifacts `json:"artifacts,omitempty" gorm:"type:json"`
 	
 	// Relationships
-	Session  ChatSession `json:"session,omitempty" gorm:"foreignKey:SessionID"`
-	ToolCall ToolCall    `json:"tool_call,omitempty" gorm:"foreignKey:ToolCallID"`
+	Session  ChatSession `json:"-" gorm:"foreignKey:SessionID"`
+	ToolCall ToolCall    `json:"-" gorm:"foreignKey:ToolCallID"`
+}
+
+// ToolExecutionLogList represents a paginated list of tool execution logs
+type ToolExecutionLogList struct {
+	Logs       []*ToolExecutionLog `json:"logs"`
+	TotalCount int64               `json:"total_count"`
+	Page       int                 `json:"page"`
+	PageSize   int                 `json:"page_size"`
+	HasMore    bool                `json:"has_more"`
+}
+
+// ToolCallCountFilter selects the logged tool calls counted against quotas
+type ToolCallCountFilter struct {
+	SessionID string
+	AgentID   string
+	ToolName  string
+	Since     time.Time
+
+	// ExcludeErrorCode leaves out calls that failed with this code
+	ExcludeErrorCode string
+}
+
+// ToolQuotaUsage reports tool call counters against the configured quotas
+type ToolQuotaUsage struct {
+	AgentID   string `json:"agent_id"`
+	SessionID string `json:"session_id,omitempty"`
+	Day       string `json:"day"` // UTC date the daily counters cover
+
+	Total ToolQuotaCounters            `json:"total"`
+	Tools map[string]ToolQuotaCounters `json:"tools,omitempty"`
+}
+
+// ToolQuotaCounters holds call counts and their limits; a limit of 0 is
+// unlimited
+type ToolQuotaCounters struct {
+	TurnLimit     int   `json:"turn_limit"`
+	SessionCalls  int64 `json:"session_calls"`
+	SessionLimit  int   `json:"session_limit"`
+	AgentDayCalls int64 `json:"agent_day_calls"`
+	AgentDayLimit int   `json:"agent_day_limit"`
 }
 
 // BeforeCreate hook to generate UUID
@@ -144,6 +276,55 @@ func (tel *ToolExecutionLog) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// Tool execution statuses of long-running tool calls run in the background
+// and of calls held for approval
+const (
+	ToolExecutionPending          = "pending"           // queued for a worker
+	ToolExecutionAwaitingApproval = "awaiting_approval" // held until a person approves it
+	ToolExecutionRunning          = "running"           // the tool is executing
+	ToolExecutionSucceeded        = "succeeded"         // the tool returned a result
+	ToolExecutionFailed           = "failed"            // the tool returned an error
+	ToolExecutionRejected         = "rejected"          // a person rejected the call
+)
+
+// ToolExecution tracks a long-running tool call executed by the job runner,
+// or a call held until a person approves it. The LLM receives its ID in
+// place of a result and polls for completion.
+type ToolExecution struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	SessionID   string     `json:"session_id" gorm:"not null;index"`
+	AgentID     string     `json:"agent_id"`
+	ToolCallID  string     `json:"tool_call_id"`
+	ToolName    string     `json:"tool_name" gorm:"not null"`
+	Arguments   JSON       `json:"arguments" gorm:"type:json"`
+	Status      string     `json:"status" gorm:"not null;default:pending;index"`
+	Result      *JSON      `json:"result,omitempty" gorm:"type:json"`
+	Error       string     `json:"error,omitempty" gorm:"type:text"`
+	ErrorCode   string     `json:"error_code,omitempty"`
+	Duration    int64      `json:"duration_ms"`
+	JobID       string     `json:"job_id,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (te *ToolExecution) BeforeCreate(tx *gorm.DB) error {
+	if te.ID == "" {
+		te.ID = uuid.New().String()
+	}
+	if te.Status == "" {
+		te.Status = ToolExecutionPending
+	}
+	return nil
+}
+
+// Done reports whether the execution has finished
+func (te *ToolExecution) Done() bool {
+	return te.Status == ToolExecutionSucceeded || te.Status == ToolExecutionFailed || te.Status == ToolExecutionRejected
+}
+
 // ToolUsageStats represents usage statistics for tools
 type ToolUsageStats struct {
 	ToolName        string    `json:"tool_name"`
@@ -151,6 +332,8 @@ type ToolUsageStats struct {
 	SuccessfulCalls int64     `json:"successful_calls"`
 	FailedCalls     int64     `json:"failed_calls"`
 	AvgDuration     float64   `json:"avg_duration_ms"`
+	P95Duration     int64     `json:"p95_duration_ms"`
+	ErrorRate       float64   `json:"error_rate"`
 	LastUsed        time.Time `json:"last_used"`
 }
 
@@ -169,6 +352,8 @@ type ToolInfo struct {
 	Category    string                 `json:"category,omitempty"`
 	Version     string                 `json:"version,omitempty"`
 	Examples    []ToolExampleInfo      `json:"examples,omitempty"`
+	// OutputSchema is the JSON Schema of the tool's result, when declared
+	OutputSchema map[string]interface{} `json:"output_schema,omitempty"`
 }
 
 // ToolParameterInfo represents information about a tool parameter
@@ -200,8 +385,11 @@ type ToolTestResponse struct {
 	Success    bool                   `json:"success"`
 	Result     interface{}            `json:"result,omitempty"`
 	Error      string                 `json:"error,omitempty"`
+	ErrorCode  string                 `json:"error_code,omitempty"`
 	Duration   int64                  `json:"duration_ms"`
 	Validation []ValidationError      `json:"validation_errors,omitempty"`
+	// OutputErrors lists where the result breaks the tool's output schema
+	OutputErrors []ValidationError `json:"output_errors,omitempty"`
 }
 
 // ValidationError represents a parameter validation error
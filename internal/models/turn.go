@@ -0,0 +1,90 @@
+package models
+
+import "time"
+
+// Turn groups the messages of one chat invocation: the user message, the
+// intermediate assistant and tool messages of the tool-calling loop, and the
+// final assistant reply. Turns are derived from the turn_id of messages.
+type Turn struct {
+	ID           string      `json:"id"`
+	SessionID    string      `json:"session_id"`
+	Status       string      `json:"status"`
+	UserMessage  *Message    `json:"user_message,omitempty"`
+	Steps        []*Message  `json:"steps"`
+	FinalMessage *Message    `json:"final_message,omitempty"`
+	ToolCalls    []*ToolCall `json:"tool_calls,omitempty"`
+	StartedAt    time.Time   `json:"started_at"`
+	EndedAt      time.Time   `json:"ended_at"`
+}
+
+// TurnList represents a paginated list of turns
+type TurnList struct {
+	Turns      []*Turn `json:"turns"`
+	TotalCount int     `json:"total_count"`
+	Page       int     `json:"page"`
+	PageSize   int     `json:"page_size"`
+	HasMore    bool    `json:"has_more"`
+}
+
+// GroupTurns groups messages, ordered by sequence, into turns. Tool calls are
+// attached to the turn of the assistant message that made them. Messages
+// without a turn_id, e.g. from before turns were recorded, join the turn of
+// the preceding user message.
+func GroupTurns(messages []*Message, toolCalls []*ToolCall) []*Turn {
+	var turns []*Turn
+	byID := make(map[string]*Turn)
+	turnOfMessage := make(map[string]*Turn)
+	var current *Turn
+
+	for _, message := range messages {
+		turnID := message.TurnID
+		if turnID == "" && (message.Role == "user" || current == nil) {
+			turnID = message.ID
+		}
+
+		turn := current
+		if turnID != "" {
+			turn = byID[turnID]
+			if turn == nil {
+				turn = &Turn{
+					ID:        turnID,
+					SessionID: message.SessionID,
+					Status:    message.Status,
+					Steps:     []*Message{},
+					StartedAt: message.CreatedAt,
+				}
+				byID[turnID] = turn
+				turns = append(turns, turn)
+			}
+		}
+		current = turn
+
+		if message.ID == turn.ID && message.Role == "user" {
+			turn.UserMessage = message
+			turn.Status = message.Status
+		} else {
+			turn.Steps = append(turn.Steps, message)
+			if turn.UserMessage == nil {
+				turn.Status = message.Status
+			}
+		}
+		turn.EndedAt = message.CreatedAt
+		turnOfMessage[message.ID] = turn
+	}
+
+	for _, turn := range turns {
+		// A turn ending in an assistant message has its final reply
+		if n := len(turn.Steps); n > 0 && turn.Steps[n-1].Role == "assistant" && turn.Status == MessageStatusComplete {
+			turn.FinalMessage = turn.Steps[n-1]
+			turn.Steps = turn.Steps[:n-1]
+		}
+	}
+
+	for _, toolCall := range toolCalls {
+		if turn := turnOfMessage[toolCall.MessageID]; turn != nil {
+			turn.ToolCalls = append(turn.ToolCalls, toolCall)
+		}
+	}
+
+	return turns
+}
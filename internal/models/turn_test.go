@@ -0,0 +1,50 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupTurns(t *testing.T) {
+	complete := MessageStatusComplete
+	messages := []*Message{
+		// Turn recorded before turn IDs existed
+		{ID: "u1", Role: "user", Status: complete, Sequence: 1},
+		{ID: "a1", Role: "assistant", Status: complete, Sequence: 2},
+		// Tool-calling turn
+		{ID: "u2", Role: "user", Status: complete, TurnID: "u2", Sequence: 3},
+		{ID: "a2", Role: "assistant", Status: complete, TurnID: "u2", Sequence: 4},
+		{ID: "t2", Role: "tool", Status: complete, TurnID: "u2", Sequence: 5},
+		{ID: "f2", Role: "assistant", Status: complete, TurnID: "u2", Sequence: 6},
+		// Interrupted after the tool call
+		{ID: "u3", Role: "user", Status: MessageStatusIncomplete, TurnID: "u3", Sequence: 7},
+		{ID: "a3", Role: "assistant", Status: MessageStatusIncomplete, TurnID: "u3", Sequence: 8},
+	}
+	toolCalls := []*ToolCall{
+		{ID: "c2", MessageID: "a2", ToolName: "calculator"},
+		{ID: "c3", MessageID: "a3", ToolName: "http_request"},
+	}
+
+	turns := GroupTurns(messages, toolCalls)
+	require.Len(t, turns, 3)
+
+	assert.Equal(t, "u1", turns[0].ID)
+	assert.Equal(t, "u1", turns[0].UserMessage.ID)
+	assert.Empty(t, turns[0].Steps)
+	assert.Equal(t, "a1", turns[0].FinalMessage.ID)
+
+	assert.Equal(t, "u2", turns[1].UserMessage.ID)
+	require.Len(t, turns[1].Steps, 2)
+	assert.Equal(t, "a2", turns[1].Steps[0].ID)
+	assert.Equal(t, "t2", turns[1].Steps[1].ID)
+	assert.Equal(t, "f2", turns[1].FinalMessage.ID)
+	require.Len(t, turns[1].ToolCalls, 1)
+	assert.Equal(t, "c2", turns[1].ToolCalls[0].ID)
+
+	assert.Equal(t, MessageStatusIncomplete, turns[2].Status)
+	assert.Nil(t, turns[2].FinalMessage)
+	require.Len(t, turns[2].Steps, 1)
+	assert.Len(t, turns[2].ToolCalls, 1)
+}
@@ -0,0 +1,74 @@
+package moderation
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// DefaultKeywords are the phrases of the keyword classifier unless
+// configured otherwise. Categories use the names of the OpenAI endpoint.
+var DefaultKeywords = map[string][]string{
+	"self-harm":  {"kill myself", "end my life", "suicide", "self harm", "hurt myself"},
+	"violence":   {"kill you", "murder", "shoot you", "stab you", "blow up"},
+	"harassment": {"idiot", "moron", "worthless", "shut up"},
+	"illicit":    {"make a bomb", "buy drugs", "credit card fraud", "launder money"},
+}
+
+// Scores of the keyword classifier. With the default thresholds, one
+// matching phrase flags a message and a second one blocks it.
+const (
+	keywordBaseScore = 0.6
+	keywordHitScore  = 0.2
+)
+
+// Keywords is a local classifier that scores categories by the phrases of
+// theirs a text contains, for when no moderation endpoint is available
+type Keywords struct {
+	patterns map[string][]*regexp.Regexp
+}
+
+// NewKeywords creates a classifier from phrases per category. Phrases match
+// whole words, ignoring case.
+func NewKeywords(keywords map[string][]string) *Keywords {
+	k := &Keywords{patterns: make(map[string][]*regexp.Regexp, len(keywords))}
+	for category, phrases := range keywords {
+		for _, phrase := range phrases {
+			words := strings.Fields(phrase)
+			if len(words) == 0 {
+				continue
+			}
+			for i, word := range words {
+				words[i] = regexp.QuoteMeta(word)
+			}
+			pattern := regexp.MustCompile(`(?i)\b` + strings.Join(words, `\s+`) + `\b`)
+			k.patterns[category] = append(k.patterns[category], pattern)
+		}
+	}
+	return k
+}
+
+// Name returns the moderator name
+func (k *Keywords) Name() string {
+	return "keywords"
+}
+
+// Moderate scores each category by its matching phrases
+func (k *Keywords) Moderate(ctx context.Context, text string) (*Result, error) {
+	scores := make(map[string]float64, len(k.patterns))
+	for category, patterns := range k.patterns {
+		hits := 0
+		for _, pattern := range patterns {
+			hits += len(pattern.FindAllStringIndex(text, -1))
+		}
+		score := 0.0
+		if hits > 0 {
+			score = keywordBaseScore + keywordHitScore*float64(hits-1)
+			if score > 1 {
+				score = 1
+			}
+		}
+		scores[category] = score
+	}
+	return &Result{Provider: k.Name(), Scores: scores}, nil
+}
@@ -0,0 +1,169 @@
+// Package moderation scores chat messages by content category, such as
+// harassment, self-harm or violence, and decides whether a message is
+// allowed, flagged or blocked.
+package moderation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"agent-server/internal/config"
+)
+
+// Actions a policy takes on a message
+const (
+	ActionAllow = "allow"
+	ActionFlag  = "flag"
+	ActionBlock = "block"
+)
+
+// Moderator scores text by content category
+type Moderator interface {
+	Name() string
+	Moderate(ctx context.Context, text string) (*Result, error)
+}
+
+// Result holds the category scores of a text, each from 0 to 1
+type Result struct {
+	Provider string
+	Scores   map[string]float64
+}
+
+// Verdict is a policy's decision on a message, as persisted in message
+// metadata
+type Verdict struct {
+	Action     string             `json:"action"`
+	Provider   string             `json:"provider,omitempty"`
+	Categories []string           `json:"categories,omitempty"` // categories that reached the action's threshold
+	Scores     map[string]float64 `json:"scores,omitempty"`
+	Error      string             `json:"error,omitempty"` // set when no moderator could score the message
+}
+
+// Thresholds are the scores at which a category flags or blocks a message;
+// 0 disables the action
+type Thresholds struct {
+	Flag  float64
+	Block float64
+}
+
+// Policy decides on messages by their category scores
+type Policy struct {
+	Thresholds
+	// Categories overrides the thresholds per category; zero fields keep
+	// the overall threshold
+	Categories map[string]Thresholds
+}
+
+// PolicyFromConfig returns the policy of the moderation configuration
+func PolicyFromConfig(cfg config.ModerationConfig) Policy {
+	policy := Policy{
+		Thresholds: Thresholds{Flag: cfg.Flag, Block: cfg.Block},
+		Categories: make(map[string]Thresholds, len(cfg.Categories)),
+	}
+	for category, thresholds := range cfg.Categories {
+		policy.Categories[category] = Thresholds{Flag: thresholds.Flag, Block: thresholds.Block}
+	}
+	return policy
+}
+
+// WithThresholds returns a copy of the policy with other overall thresholds;
+// zero arguments keep the policy's
+func (p Policy) WithThresholds(flag, block float64) Policy {
+	if flag > 0 {
+		p.Flag = flag
+	}
+	if block > 0 {
+		p.Block = block
+	}
+	return p
+}
+
+// thresholds returns the thresholds of a category
+func (p Policy) thresholds(category string) Thresholds {
+	t := p.Thresholds
+	if override, ok := p.Categories[category]; ok {
+		if override.Flag > 0 {
+			t.Flag = override.Flag
+		}
+		if override.Block > 0 {
+			t.Block = override.Block
+		}
+	}
+	return t
+}
+
+// Decide blocks a message when any category reaches its block threshold,
+// flags it when any reaches its flag threshold and allows it otherwise
+func (p Policy) Decide(result *Result) *Verdict {
+	var flagged, blocked []string
+	for category, score := range result.Scores {
+		t := p.thresholds(category)
+		switch {
+		case t.Block > 0 && score >= t.Block:
+			blocked = append(blocked, category)
+		case t.Flag > 0 && score >= t.Flag:
+			flagged = append(flagged, category)
+		}
+	}
+
+	verdict := &Verdict{Action: ActionAllow, Provider: result.Provider, Scores: result.Scores}
+	switch {
+	case len(blocked) > 0:
+		verdict.Action, verdict.Categories = ActionBlock, blocked
+	case len(flagged) > 0:
+		verdict.Action, verdict.Categories = ActionFlag, flagged
+	}
+	sort.Strings(verdict.Categories)
+	return verdict
+}
+
+// Chain asks its moderators in order until one answers, so that a local
+// classifier can stand in for an unreachable endpoint
+type Chain []Moderator
+
+// Name returns the name of the first moderator
+func (c Chain) Name() string {
+	if len(c) == 0 {
+		return ""
+	}
+	return c[0].Name()
+}
+
+// Moderate returns the result of the first moderator that answers, or the
+// errors of all of them
+func (c Chain) Moderate(ctx context.Context, text string) (*Result, error) {
+	var errs []error
+	for _, moderator := range c {
+		result, err := moderator.Moderate(ctx, text)
+		if err == nil {
+			return result, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no moderator configured")
+	}
+	return nil, errors.Join(errs...)
+}
+
+// NewFromConfig returns the moderators of the configuration: the OpenAI
+// endpoint if it has an API key, followed by the keyword classifier.
+// transport carries the endpoint's requests.
+func NewFromConfig(cfg config.ModerationConfig, transport http.RoundTripper) Chain {
+	var chain Chain
+	if cfg.OpenAI.APIKey != "" {
+		client := &http.Client{
+			Timeout:   time.Duration(cfg.OpenAI.Timeout) * time.Second,
+			Transport: transport,
+		}
+		chain = append(chain, NewOpenAI(cfg.OpenAI.APIKey, cfg.OpenAI.BaseURL, cfg.OpenAI.Model, client))
+	}
+	keywords := cfg.Keywords
+	if len(keywords) == 0 {
+		keywords = DefaultKeywords
+	}
+	return append(chain, NewKeywords(keywords))
+}
@@ -0,0 +1,98 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Decide(t *testing.T) {
+	policy := Policy{
+		Thresholds: Thresholds{Flag: 0.5, Block: 0.8},
+		Categories: map[string]Thresholds{"self-harm": {Block: 0.4}},
+	}
+
+	verdict := policy.Decide(&Result{Provider: "test", Scores: map[string]float64{"violence": 0.1}})
+	assert.Equal(t, ActionAllow, verdict.Action)
+	assert.Empty(t, verdict.Categories)
+
+	verdict = policy.Decide(&Result{Scores: map[string]float64{"violence": 0.6, "harassment": 0.5}})
+	assert.Equal(t, ActionFlag, verdict.Action)
+	assert.Equal(t, []string{"harassment", "violence"}, verdict.Categories)
+
+	verdict = policy.Decide(&Result{Scores: map[string]float64{"violence": 0.6, "self-harm": 0.45}})
+	assert.Equal(t, ActionBlock, verdict.Action)
+	assert.Equal(t, []string{"self-harm"}, verdict.Categories)
+
+	// Agents can tighten the overall thresholds
+	verdict = policy.WithThresholds(0, 0.55).Decide(&Result{Scores: map[string]float64{"violence": 0.6}})
+	assert.Equal(t, ActionBlock, verdict.Action)
+}
+
+func TestKeywords_Moderate(t *testing.T) {
+	keywords := NewKeywords(map[string][]string{"violence": {"blow up", "murder"}})
+	policy := Policy{Thresholds: Thresholds{Flag: 0.5, Block: 0.8}}
+
+	result, err := keywords.Moderate(context.Background(), "How do I murder a process in Linux?")
+	require.NoError(t, err)
+	assert.Equal(t, "keywords", result.Provider)
+	assert.Equal(t, ActionFlag, policy.Decide(result).Action)
+
+	result, err = keywords.Moderate(context.Background(), "I will MURDER them and blow   up the house")
+	require.NoError(t, err)
+	assert.Equal(t, ActionBlock, policy.Decide(result).Action)
+
+	// Phrases match whole words only
+	result, err = keywords.Moderate(context.Background(), "Murderous tomatoes blowup")
+	require.NoError(t, err)
+	assert.Zero(t, result.Scores["violence"])
+}
+
+func TestOpenAI_Moderate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/moderations" {
+			http.NotFound(w, r)
+			return
+		}
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "hello", body["input"])
+		assert.Equal(t, "omni-moderation-latest", body["model"])
+		w.Write([]byte(`{"results":[{"flagged":false,"category_scores":{"harassment":0.02,"violence":0.7}}]}`))
+	}))
+	defer server.Close()
+
+	result, err := NewOpenAI("key", server.URL+"/v1/", "omni-moderation-latest", nil).Moderate(context.Background(), "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "openai", result.Provider)
+	assert.Equal(t, 0.7, result.Scores["violence"])
+
+	_, err = NewOpenAI("key", server.URL+"/missing", "", nil).Moderate(context.Background(), "hello")
+	assert.Error(t, err)
+}
+
+type failingModerator struct{}
+
+func (failingModerator) Name() string { return "failing" }
+
+func (failingModerator) Moderate(context.Context, string) (*Result, error) {
+	return nil, errors.New("unreachable")
+}
+
+func TestChain_FallsBack(t *testing.T) {
+	chain := Chain{failingModerator{}, NewKeywords(DefaultKeywords)}
+	result, err := chain.Moderate(context.Background(), "shut up")
+	require.NoError(t, err)
+	assert.Equal(t, "keywords", result.Provider)
+	assert.Greater(t, result.Scores["harassment"], 0.0)
+
+	_, err = Chain{failingModerator{}}.Moderate(context.Background(), "hi")
+	assert.Error(t, err)
+}
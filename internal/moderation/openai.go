@@ -0,0 +1,78 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpenAI scores text with the OpenAI moderation endpoint
+type OpenAI struct {
+	apiKey  string
+	baseURL string
+	model   string
+	client  *http.Client
+}
+
+// NewOpenAI creates a moderator for the moderation endpoint under baseURL,
+// e.g. https://api.openai.com/v1
+func NewOpenAI(apiKey, baseURL, model string, client *http.Client) *OpenAI {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &OpenAI{
+		apiKey:  apiKey,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		client:  client,
+	}
+}
+
+// Name returns the moderator name
+func (o *OpenAI) Name() string {
+	return "openai"
+}
+
+// Moderate returns the category scores of the endpoint
+func (o *OpenAI) Moderate(ctx context.Context, text string) (*Result, error) {
+	body, err := json.Marshal(struct {
+		Input string `json:"input"`
+		Model string `json:"model,omitempty"`
+	}{text, o.model})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/moderations", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+o.apiKey)
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("openai moderation: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("openai moderation: status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var response struct {
+		Results []struct {
+			CategoryScores map[string]float64 `json:"category_scores"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("openai moderation: decoding response: %w", err)
+	}
+	if len(response.Results) == 0 {
+		return nil, fmt.Errorf("openai moderation: empty response")
+	}
+	return &Result{Provider: o.Name(), Scores: response.Results[0].CategoryScores}, nil
+}
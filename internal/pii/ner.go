@@ -0,0 +1,87 @@
+package pii
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// NER detects entities such as names and addresses with a named entity
+// recognition endpoint
+type NER struct {
+	url    string
+	client *http.Client
+}
+
+// NewNER creates a detector for the endpoint at url
+func NewNER(url string, client *http.Client) *NER {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &NER{url: url, client: client}
+}
+
+// Detect posts text to the endpoint and returns its entities. Entity types
+// are lower-cased with spaces replaced by underscores, e.g. "person".
+func (n *NER) Detect(ctx context.Context, text string) ([]Entity, error) {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ner: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("ner: status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var response struct {
+		Entities []struct {
+			Type  string `json:"type"`
+			Start int    `json:"start"`
+			End   int    `json:"end"`
+		} `json:"entities"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("ner: decoding response: %w", err)
+	}
+
+	// The endpoint counts characters; entities are located by bytes
+	offsets := byteOffsets(text)
+	entities := make([]Entity, 0, len(response.Entities))
+	for _, entity := range response.Entities {
+		if entity.Type == "" || entity.Start < 0 || entity.End >= len(offsets) || entity.Start >= entity.End {
+			continue
+		}
+		entities = append(entities, Entity{
+			Type:  strings.ReplaceAll(strings.ToLower(strings.TrimSpace(entity.Type)), " ", "_"),
+			Start: offsets[entity.Start],
+			End:   offsets[entity.End],
+		})
+	}
+	return entities, nil
+}
+
+// byteOffsets maps the character offsets of text, including the end, to
+// byte offsets
+func byteOffsets(text string) []int {
+	offsets := make([]int, 0, utf8.RuneCountInString(text)+1)
+	for i := range text {
+		offsets = append(offsets, i)
+	}
+	return append(offsets, len(text))
+}
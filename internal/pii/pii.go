@@ -0,0 +1,299 @@
+// Package pii detects personal data such as email addresses, phone numbers,
+// payment card and social security numbers in text and replaces it with
+// placeholders like [EMAIL].
+package pii
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"agent-server/internal/config"
+)
+
+// Entity types found by pattern
+const (
+	TypeEmail      = "email"
+	TypePhone      = "phone"
+	TypeCreditCard = "credit_card"
+	TypeSSN        = "ssn"
+)
+
+// Entity is personal data found in a text, located by byte offsets
+type Entity struct {
+	Type  string
+	Start int
+	End   int
+}
+
+// Detector finds entities in text
+type Detector interface {
+	Detect(ctx context.Context, text string) ([]Entity, error)
+}
+
+var (
+	emailPattern = regexp.MustCompile(`(?i)\b[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}\b`)
+	cardPattern  = regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`)
+	ssnPattern   = regexp.MustCompile(`\b(\d{3})-(\d{2})-(\d{4})\b`)
+
+	// Phone numbers are international numbers with a leading + or North
+	// American style numbers, so that dates and amounts are left alone
+	phonePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\+\d{1,3}[ .\-]?(?:\(\d{1,4}\)[ .\-]?)?\d{1,4}(?:[ .\-]?\d{2,4}){1,4}\b`),
+		regexp.MustCompile(`(?:\(\d{3}\)\s?|\b\d{3}[ .\-])\d{3}[.\-]\d{4}\b`),
+	}
+)
+
+// Patterns detects emails, phone numbers, payment card numbers passing the
+// Luhn check and social security numbers
+type Patterns struct{}
+
+// Detect returns the pattern matches in text
+func (Patterns) Detect(ctx context.Context, text string) ([]Entity, error) {
+	var entities []Entity
+	add := func(entityType string, locations [][]int, valid func(string) bool) {
+		for _, loc := range locations {
+			if valid == nil || valid(text[loc[0]:loc[1]]) {
+				entities = append(entities, Entity{Type: entityType, Start: loc[0], End: loc[1]})
+			}
+		}
+	}
+	add(TypeEmail, emailPattern.FindAllStringIndex(text, -1), nil)
+	add(TypeCreditCard, cardPattern.FindAllStringIndex(text, -1), luhn)
+	add(TypeSSN, ssnPattern.FindAllStringIndex(text, -1), validSSN)
+	for _, pattern := range phonePatterns {
+		add(TypePhone, pattern.FindAllStringIndex(text, -1), validPhone)
+	}
+	return entities, nil
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum of payment
+// card numbers
+func luhn(s string) bool {
+	sum, count := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		digit := int(s[i] - '0')
+		if count%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		count++
+	}
+	return sum%10 == 0
+}
+
+// validSSN rejects numbers that are never issued
+func validSSN(s string) bool {
+	parts := ssnPattern.FindStringSubmatch(s)
+	area, group, serial := parts[1], parts[2], parts[3]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+// validPhone requires the digit count of a phone number
+func validPhone(s string) bool {
+	digits := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits >= 7 && digits <= 15
+}
+
+// Scrubber replaces the entities its detectors find with placeholders. A nil
+// Scrubber leaves data unchanged.
+type Scrubber struct {
+	detectors []Detector
+}
+
+// New creates a scrubber from detectors; pattern detection is always
+// included first
+func New(detectors ...Detector) *Scrubber {
+	return &Scrubber{detectors: append([]Detector{Patterns{}}, detectors...)}
+}
+
+// NewFromConfig creates the scrubber of the configuration, adding the named
+// entity recognition endpoint when one is set. transport carries its
+// requests.
+func NewFromConfig(cfg config.PIIConfig, transport http.RoundTripper) *Scrubber {
+	if cfg.NER.URL == "" {
+		return New()
+	}
+	client := &http.Client{
+		Timeout:   time.Duration(cfg.NER.Timeout) * time.Second,
+		Transport: transport,
+	}
+	return New(NewNER(cfg.NER.URL, client))
+}
+
+// Placeholder returns the text that replaces an entity of the given type
+func Placeholder(entityType string) string {
+	return "[" + strings.ToUpper(entityType) + "]"
+}
+
+// String replaces the entities of the given types in text; no types selects
+// all. When a detector fails, the entities of the others are still replaced
+// and the first error is returned with the result.
+func (s *Scrubber) String(ctx context.Context, text string, types []string) (string, error) {
+	if s == nil || text == "" {
+		return text, nil
+	}
+
+	var entities []Entity
+	var firstErr error
+	for _, detector := range s.detectors {
+		found, err := detector.Detect(ctx, text)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for _, entity := range found {
+			if entity.Start >= 0 && entity.End <= len(text) && entity.Start < entity.End && selected(entity.Type, types) {
+				entities = append(entities, entity)
+			}
+		}
+	}
+	if len(entities) == 0 {
+		return text, firstErr
+	}
+
+	// Of overlapping entities, the one starting first and then the longest
+	// is replaced
+	sort.SliceStable(entities, func(i, j int) bool {
+		if entities[i].Start != entities[j].Start {
+			return entities[i].Start < entities[j].Start
+		}
+		return entities[i].End > entities[j].End
+	})
+	var scrubbed strings.Builder
+	last := 0
+	for _, entity := range entities {
+		if entity.Start < last {
+			continue
+		}
+		scrubbed.WriteString(text[last:entity.Start])
+		scrubbed.WriteString(Placeholder(entity.Type))
+		last = entity.End
+	}
+	scrubbed.WriteString(text[last:])
+	return scrubbed.String(), firstErr
+}
+
+// Value returns a copy of v with the strings at any depth of maps and
+// slices scrubbed. Errors are handled as by String.
+func (s *Scrubber) Value(ctx context.Context, v interface{}, types []string) (interface{}, error) {
+	if s == nil || v == nil {
+		return v, nil
+	}
+
+	var firstErr error
+	record := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	switch value := v.(type) {
+	case string:
+		return s.String(ctx, value, types)
+	case map[string]interface{}:
+		scrubbed := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			var err error
+			scrubbed[key], err = s.Value(ctx, item, types)
+			record(err)
+		}
+		return scrubbed, firstErr
+	case []interface{}:
+		scrubbed := make([]interface{}, len(value))
+		for i, item := range value {
+			var err error
+			scrubbed[i], err = s.Value(ctx, item, types)
+			record(err)
+		}
+		return scrubbed, firstErr
+	case []string:
+		scrubbed := make([]string, len(value))
+		for i, item := range value {
+			var err error
+			scrubbed[i], err = s.String(ctx, item, types)
+			record(err)
+		}
+		return scrubbed, firstErr
+	case []map[string]interface{}:
+		scrubbed := make([]map[string]interface{}, len(value))
+		for i, item := range value {
+			var err error
+			scrubbed[i], err = s.Map(ctx, item, types)
+			record(err)
+		}
+		return scrubbed, firstErr
+	}
+
+	// Other composite values are scrubbed in their JSON form
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Ptr:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return v, nil
+		}
+		var generic interface{}
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return v, nil
+		}
+		return s.Value(ctx, generic, types)
+	}
+	return v, nil
+}
+
+// JSON scrubs the strings of a JSON document, such as tool call arguments.
+// Text that is not JSON is scrubbed as a plain string.
+func (s *Scrubber) JSON(ctx context.Context, document string, types []string) (string, error) {
+	if s == nil {
+		return document, nil
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(document), &parsed); err != nil {
+		return s.String(ctx, document, types)
+	}
+	scrubbed, scrubErr := s.Value(ctx, parsed, types)
+	data, err := json.Marshal(scrubbed)
+	if err != nil {
+		return s.String(ctx, document, types)
+	}
+	return string(data), scrubErr
+}
+
+// Map returns a copy of m with its strings scrubbed, as by Value
+func (s *Scrubber) Map(ctx context.Context, m map[string]interface{}, types []string) (map[string]interface{}, error) {
+	if s == nil || m == nil {
+		return m, nil
+	}
+	scrubbed, err := s.Value(ctx, m, types)
+	return scrubbed.(map[string]interface{}), err
+}
+
+// selected reports whether entityType is one of types; no types selects all
+func selected(entityType string, types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if strings.EqualFold(t, entityType) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package pii
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrubber_Patterns(t *testing.T) {
+	scrubber := New()
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"email", "Mail jane.doe+news@example.co.uk today", "Mail [EMAIL] today"},
+		{"international phone", "Call +49 30 1234 5678.", "Call [PHONE]."},
+		{"north american phone", "Call (555) 123-4567 or 555.123.4567", "Call [PHONE] or [PHONE]"},
+		{"credit card", "Card 4111 1111 1111 1111 expires soon", "Card [CREDIT_CARD] expires soon"},
+		{"ssn", "SSN 123-45-6789", "SSN [SSN]"},
+		{"failed luhn check", "Order 4111 1111 1111 1112", "Order 4111 1111 1111 1112"},
+		{"never issued ssn", "Ref 000-12-3456", "Ref 000-12-3456"},
+		{"dates and amounts", "On 2024-01-15 we paid 1,250.00 for 3 items", "On 2024-01-15 we paid 1,250.00 for 3 items"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scrubbed, err := scrubber.String(ctx, tt.text, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, scrubbed)
+		})
+	}
+
+	// Only the selected types are replaced
+	scrubbed, err := scrubber.String(ctx, "jane@example.com, 123-45-6789", []string{"ssn"})
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com, [SSN]", scrubbed)
+}
+
+func TestScrubber_Value(t *testing.T) {
+	value, err := New().Value(context.Background(), map[string]interface{}{
+		"to":    []interface{}{"jane@example.com", 42.0},
+		"body":  map[string]interface{}{"text": "SSN 123-45-6789"},
+		"count": 3,
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"to":    []interface{}{"[EMAIL]", 42.0},
+		"body":  map[string]interface{}{"text": "SSN [SSN]"},
+		"count": 3,
+	}, value)
+
+	var nilScrubber *Scrubber
+	text, err := nilScrubber.String(context.Background(), "jane@example.com", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", text)
+}
+
+func TestNER_Detect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "Grüße von Jürgen Müller, jm@example.de", body["text"])
+		// Offsets count characters, not bytes
+		w.Write([]byte(`{"entities":[{"type":"PERSON","start":10,"end":23},{"type":"email","start":25,"end":38}]}`))
+	}))
+	defer server.Close()
+
+	scrubbed, err := New(NewNER(server.URL, nil)).String(context.Background(), "Grüße von Jürgen Müller, jm@example.de", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Grüße von [PERSON], [EMAIL]", scrubbed)
+}
+
+type failingDetector struct{}
+
+func (failingDetector) Detect(context.Context, string) ([]Entity, error) {
+	return nil, errors.New("unreachable")
+}
+
+func TestScrubber_DetectorFailure(t *testing.T) {
+	scrubbed, err := New(failingDetector{}).String(context.Background(), "Mail jane@example.com", nil)
+	assert.Error(t, err)
+	assert.Equal(t, "Mail [EMAIL]", scrubbed)
+}
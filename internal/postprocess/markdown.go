@@ -0,0 +1,127 @@
+package postprocess
+
+import (
+	"regexp"
+	"strings"
+
+	"agent-server/internal/models"
+)
+
+var (
+	// reasoningBlock matches the reasoning some models emit before a reply
+	reasoningBlock = regexp.MustCompile(`(?s)<(think|thinking|reasoning)>.*?</(?:think|thinking|reasoning)>`)
+
+	// specialToken matches chat template tokens that leak into replies
+	specialToken = regexp.MustCompile(`<\|(?:im_start|im_end|im_sep|endoftext|end_of_text|begin_of_text|eot_id|start_header_id|end_header_id|eom_id|assistant|user|system)\|>|</?s>|\[/?INST\]|<</?SYS>>|<(?:start|end)_of_turn>`)
+
+	// rolePrefix matches a speaker label that opens a reply
+	rolePrefix = regexp.MustCompile(`^(?i:assistant)\s*:\s*`)
+
+	// headingWithoutSpace matches subheadings missing the space after their
+	// marks; a single # is left alone, as in #hashtag
+	headingWithoutSpace = regexp.MustCompile(`^(#{2,6})([^#\s])`)
+
+	// bullet matches list items marked with typographic bullets
+	bullet = regexp.MustCompile(`^(\s*)[•●▪‣◦]\s*`)
+
+	// fence matches the opening or closing line of a fenced code block
+	fence = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})\\s*([^`\\s]*)")
+)
+
+// StripArtifacts removes what providers leave in replies besides the
+// answer: reasoning blocks, chat template tokens and a leading speaker
+// label
+func StripArtifacts(content string) string {
+	content = reasoningBlock.ReplaceAllString(content, "")
+	content = specialToken.ReplaceAllString(content, "")
+	content = strings.TrimSpace(content)
+	return strings.TrimSpace(rolePrefix.ReplaceAllString(content, ""))
+}
+
+// NormalizeMarkdown unifies line endings, drops trailing whitespace and
+// runs of blank lines, adds the space ATX headings need, turns typographic
+// bullets into dashes and closes an unclosed code fence. Code blocks are
+// kept as they are.
+func NormalizeMarkdown(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+
+	var out []string
+	var open string // marker of the open fence
+	blank := 0
+	for _, line := range strings.Split(content, "\n") {
+		if open != "" {
+			out = append(out, line)
+			if closesFence(line, open) {
+				open = ""
+			}
+			continue
+		}
+		if m := fence.FindStringSubmatch(line); m != nil {
+			open = m[1]
+		}
+
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			blank++
+			if blank > 1 {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		line = headingWithoutSpace.ReplaceAllString(line, "$1 $2")
+		line = bullet.ReplaceAllString(line, "$1- ")
+		out = append(out, line)
+	}
+	if open != "" {
+		out = append(out, open)
+	}
+	return strings.Trim(strings.Join(out, "\n"), "\n")
+}
+
+// ExtractCode returns the fenced code blocks of a reply as attachments, in
+// order. An unclosed block runs to the end of the reply.
+func ExtractCode(content string) []models.Attachment {
+	var attachments []models.Attachment
+	var open, language string
+	var code []string
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		if open == "" {
+			if m := fence.FindStringSubmatch(line); m != nil {
+				open, language, code = m[1], m[2], nil
+			}
+			continue
+		}
+		if closesFence(line, open) {
+			attachments = append(attachments, codeAttachment(len(attachments), language, code))
+			open = ""
+			continue
+		}
+		code = append(code, line)
+	}
+	if open != "" {
+		attachments = append(attachments, codeAttachment(len(attachments), language, code))
+	}
+	return attachments
+}
+
+// codeAttachment returns the attachment of a code block
+func codeAttachment(index int, language string, code []string) models.Attachment {
+	return models.Attachment{
+		Type:     models.AttachmentCode,
+		Index:    index,
+		Language: strings.ToLower(language),
+		Content:  strings.Join(code, "\n"),
+	}
+}
+
+// closesFence reports whether a line closes the fence opened by marker: the
+// same character, at least as many times, and nothing else
+func closesFence(line, marker string) bool {
+	trimmed := strings.TrimSpace(line)
+	if len(line)-len(strings.TrimLeft(line, " ")) > 3 || len(trimmed) < len(marker) {
+		return false
+	}
+	return strings.Trim(trimmed, marker[:1]) == ""
+}
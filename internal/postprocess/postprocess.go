@@ -0,0 +1,73 @@
+// Package postprocess cleans up assistant replies before they are saved and
+// returned: it strips provider artifacts, normalizes markdown, extracts
+// fenced code blocks as attachments and renders replies as plain text or
+// HTML for clients that ask for them.
+package postprocess
+
+import (
+	"fmt"
+
+	"agent-server/internal/models"
+)
+
+// Steps of a pipeline, applied in the order configured
+const (
+	StepStripArtifacts    = "strip_artifacts"    // reasoning blocks, special tokens and role prefixes
+	StepNormalizeMarkdown = "normalize_markdown" // line endings, spacing, bullets and unclosed fences
+	StepExtractCode       = "extract_code"       // fenced code blocks as attachments; the reply keeps them
+)
+
+// Steps lists the known steps
+var Steps = []string{StepStripArtifacts, StepNormalizeMarkdown, StepExtractCode}
+
+// Result is a processed reply
+type Result struct {
+	Content     string
+	Attachments []models.Attachment
+}
+
+// Pipeline applies post-processing steps to replies
+type Pipeline struct {
+	steps []string
+}
+
+// New returns the pipeline of the named steps
+func New(steps []string) (*Pipeline, error) {
+	for _, step := range steps {
+		if !isStep(step) {
+			return nil, fmt.Errorf("unknown post-processing step %q", step)
+		}
+	}
+	return &Pipeline{steps: steps}, nil
+}
+
+// Steps returns the names of the pipeline's steps
+func (p *Pipeline) Steps() []string {
+	return p.steps
+}
+
+// Process applies the steps to a reply
+func (p *Pipeline) Process(content string) Result {
+	result := Result{Content: content}
+	for _, step := range p.steps {
+		switch step {
+		case StepStripArtifacts:
+			result.Content = StripArtifacts(result.Content)
+		case StepNormalizeMarkdown:
+			result.Content = NormalizeMarkdown(result.Content)
+		case StepExtractCode:
+			result.Attachments = ExtractCode(result.Content)
+		}
+	}
+	return result
+}
+
+// isStep reports whether a step is known
+func isStep(name string) bool {
+	for _, step := range Steps {
+		if step == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package postprocess
+
+import (
+	"errors"
+	"testing"
+
+	"agent-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripArtifacts(t *testing.T) {
+	assert.Equal(t, "The answer is 42.",
+		StripArtifacts("<think>The user wants a number.</think>\nAssistant: The answer is 42.<|im_end|>"))
+	assert.Equal(t, "Hello", StripArtifacts("<s>[INST]Hello[/INST]</s>"))
+
+	// A speaker label is stripped only where it opens the reply
+	assert.Equal(t, "Ask the assistant: it knows.", StripArtifacts("Ask the assistant: it knows."))
+}
+
+func TestNormalizeMarkdown(t *testing.T) {
+	in := "##Title  \r\n\r\n\r\n\r\n• one\n  ● two\n#hashtag\n```go\n##kept   \n\n\n```\n```\nunclosed"
+	want := "## Title\n\n- one\n  - two\n#hashtag\n```go\n##kept   \n\n\n```\n```\nunclosed\n```"
+	assert.Equal(t, want, NormalizeMarkdown(in))
+}
+
+func TestExtractCode(t *testing.T) {
+	content := "Run this:\n\n```Go\nfmt.Println(1)\n```\n\nthen\n\n~~~~\n```\nnested\n```\n~~~~\n\n```sh\nmake"
+	assert.Equal(t, []models.Attachment{
+		{Type: models.AttachmentCode, Index: 0, Language: "go", Content: "fmt.Println(1)"},
+		{Type: models.AttachmentCode, Index: 1, Content: "```\nnested\n```"},
+		{Type: models.AttachmentCode, Index: 2, Language: "sh", Content: "make"},
+	}, ExtractCode(content))
+
+	assert.Empty(t, ExtractCode("No code, just `inline` code."))
+}
+
+func TestPipeline(t *testing.T) {
+	_, err := New([]string{StepStripArtifacts, "spellcheck"})
+	assert.Error(t, err)
+
+	pipeline, err := New(Steps)
+	require.NoError(t, err)
+	result := pipeline.Process("<think>plan</think>Assistant: Use:\r\n```py\r\nprint(1)\r\n")
+	assert.Equal(t, "Use:\n```py\nprint(1)\n```", result.Content)
+	assert.Equal(t, []models.Attachment{{Type: models.AttachmentCode, Language: "py", Content: "print(1)"}}, result.Attachments)
+
+	// Steps run in the order configured
+	pipeline, err = New([]string{StepExtractCode, StepStripArtifacts})
+	require.NoError(t, err)
+	result = pipeline.Process("```\n<|eot_id|>\n```")
+	assert.Equal(t, "```\n\n```", result.Content)
+	assert.Equal(t, "<|eot_id|>", result.Attachments[0].Content)
+
+	empty, err := New(nil)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Content: "<think>x</think>"}, empty.Process("<think>x</think>"))
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept    string
+		supported []string
+		want      string
+	}{
+		{"", nil, FormatMarkdown},
+		{"*/*", nil, FormatMarkdown},
+		{"text/html", nil, FormatHTML},
+		{"text/plain;q=0.5, text/html", nil, FormatHTML},
+		{"TEXT/PLAIN, text/html;q=0.9", nil, FormatText},
+		{"application/json, text/*;q=0.1", nil, FormatMarkdown},
+		{"text/markdown;q=0, text/plain;q=0.2", nil, FormatText},
+		{"text/html, */*;q=0.1", []string{FormatMarkdown}, FormatMarkdown},
+	}
+	for _, tt := range tests {
+		got, err := Negotiate(tt.accept, tt.supported...)
+		require.NoError(t, err, tt.accept)
+		assert.Equal(t, tt.want, got, tt.accept)
+	}
+
+	for _, accept := range []string{"application/json", "text/markdown;q=0", "text/html;q=2"} {
+		_, err := Negotiate(accept)
+		assert.True(t, errors.Is(err, ErrNotAcceptable), accept)
+	}
+	_, err := Negotiate("text/html", FormatMarkdown)
+	assert.True(t, errors.Is(err, ErrNotAcceptable))
+}
+
+func TestToHTML(t *testing.T) {
+	content := "# Title\n\nSome **bold**, *em*, ~~old~~ and `<code>` with a [link](https://example.com) " +
+		"and a [trap](javascript:void).\n\n" +
+		"- one\n- two <b>\n\n1. first\n2. second\n\n> quoted\n\n" +
+		"| a | b |\n|---|---|\n| 1 | 2 |\n\n---\n\n```html\n<p>raw</p>\n```"
+	want := "<h1>Title</h1>\n" +
+		"<p>Some <strong>bold</strong>, <em>em</em>, <del>old</del> and <code>&lt;code&gt;</code> with a " +
+		"<a href=\"https://example.com\">link</a> and a trap.</p>\n" +
+		"<ul>\n<li>one</li>\n<li>two &lt;b&gt;</li>\n</ul>\n" +
+		"<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n" +
+		"<blockquote><p>quoted</p></blockquote>\n" +
+		"<table>\n<thead><tr><th>a</th><th>b</th></tr></thead>\n<tbody>\n<tr><td>1</td><td>2</td></tr>\n</tbody>\n</table>\n" +
+		"<hr>\n" +
+		"<pre><code class=\"language-html\">&lt;p&gt;raw&lt;/p&gt;</code></pre>"
+	assert.Equal(t, want, Render(content, FormatHTML))
+
+	// Underscores inside words are not emphasis
+	assert.Equal(t, "<p>snake_case_name and <em>this</em></p>", ToHTML("snake_case_name and _this_"))
+}
+
+func TestToText(t *testing.T) {
+	content := "## Steps\n\n1. Open **settings**\n2. See [docs](https://example.com/docs)\n\n" +
+		"```sh\nmake build\n```\n\n| key | value |\n| --- | --- |\n| `a` | _b_ |"
+	want := "Steps\n\n1. Open settings\n2. See docs (https://example.com/docs)\n\nmake build\n\nkey | value\na | b"
+	assert.Equal(t, want, Render(content, FormatText))
+
+	assert.Equal(t, "as is", Render("as is", FormatMarkdown))
+}
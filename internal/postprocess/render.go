@@ -0,0 +1,383 @@
+package postprocess
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Formats replies are rendered in, as media types
+const (
+	FormatMarkdown = "text/markdown"
+	FormatText     = "text/plain"
+	FormatHTML     = "text/html"
+)
+
+// formats are the supported formats, most preferred first
+var formats = []string{FormatMarkdown, FormatHTML, FormatText}
+
+// ErrNotAcceptable is returned when none of the formats a client accepts
+// is supported
+var ErrNotAcceptable = errors.New("no acceptable response format")
+
+// Negotiate picks the format of a reply from an Accept-style list of media
+// ranges with optional q values, e.g. "text/html, text/plain;q=0.5", among
+// the supported formats, all by default. Wildcards and an empty list select
+// the first supported format, markdown unless restricted.
+func Negotiate(accept string, supported ...string) (string, error) {
+	if len(supported) == 0 {
+		supported = formats
+	}
+	if strings.TrimSpace(accept) == "" {
+		return supported[0], nil
+	}
+
+	type candidate struct {
+		format string
+		q      float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, _ := strings.Cut(part, ";")
+		mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(name) != "q" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				return "", fmt.Errorf("%w: invalid q value in %q", ErrNotAcceptable, strings.TrimSpace(part))
+			}
+			q = parsed
+		}
+		if q == 0 {
+			continue
+		}
+		switch mediaRange {
+		case "*/*", "text/*":
+			candidates = append(candidates, candidate{supported[0], q})
+		default:
+			for _, format := range supported {
+				if mediaRange == format {
+					candidates = append(candidates, candidate{format, q})
+				}
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("%w: %s; supported are %s", ErrNotAcceptable, accept, strings.Join(supported, ", "))
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	return candidates[0].format, nil
+}
+
+// Render returns a markdown reply in a format. Markdown is returned as is.
+func Render(content, format string) string {
+	switch format {
+	case FormatHTML:
+		return ToHTML(content)
+	case FormatText:
+		return ToText(content)
+	}
+	return content
+}
+
+// Kinds of markdown blocks
+const (
+	blockParagraph = iota
+	blockHeading
+	blockCode
+	blockQuote
+	blockList
+	blockTable
+	blockRule
+)
+
+// block is a markdown block: a paragraph, heading, code block, quote, list,
+// table or horizontal rule
+type block struct {
+	kind     int
+	level    int // of headings
+	ordered  bool
+	language string
+	lines    []string // the items of lists, the rows of tables
+}
+
+var (
+	headingLine   = regexp.MustCompile(`^ {0,3}(#{1,6})\s+(.*?)\s*#*\s*$`)
+	ruleLine      = regexp.MustCompile(`^ {0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	quoteLine     = regexp.MustCompile(`^ {0,3}>\s?(.*)$`)
+	bulletItem    = regexp.MustCompile(`^\s{0,3}[-*+]\s+(.*)$`)
+	orderedItem   = regexp.MustCompile(`^\s{0,3}\d{1,9}[.)]\s+(.*)$`)
+	tableRow      = regexp.MustCompile(`^\s*\|.*\|\s*$`)
+	tableDivider  = regexp.MustCompile(`^\s*\|?\s*:?-{3,}:?\s*(?:\|\s*:?-{3,}:?\s*)*\|?\s*$`)
+	continuation  = regexp.MustCompile(`^\s{2,}\S`)
+	inlineCode    = regexp.MustCompile("`+([^`]+)`+")
+	image         = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	link          = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	strong        = regexp.MustCompile(`\*\*([^*\n]+)\*\*|__([^_\n]+)__`)
+	emphasis      = regexp.MustCompile(`\*([^*\s][^*\n]*)\*|(?:^|\b)_([^_\s][^_\n]*)_(?:\b|$)`)
+	strikethrough = regexp.MustCompile(`~~([^~\n]+)~~`)
+)
+
+// parseBlocks splits markdown into blocks
+func parseBlocks(content string) []block {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	var blocks []block
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case fence.MatchString(line):
+			m := fence.FindStringSubmatch(line)
+			b := block{kind: blockCode, language: strings.ToLower(m[2])}
+			for i++; i < len(lines) && !closesFence(lines[i], m[1]); i++ {
+				b.lines = append(b.lines, lines[i])
+			}
+			i++
+			blocks = append(blocks, b)
+		case headingLine.MatchString(line):
+			m := headingLine.FindStringSubmatch(line)
+			blocks = append(blocks, block{kind: blockHeading, level: len(m[1]), lines: []string{m[2]}})
+			i++
+		case ruleLine.MatchString(line):
+			blocks = append(blocks, block{kind: blockRule})
+			i++
+		case quoteLine.MatchString(line):
+			b := block{kind: blockQuote}
+			for ; i < len(lines) && quoteLine.MatchString(lines[i]); i++ {
+				b.lines = append(b.lines, quoteLine.FindStringSubmatch(lines[i])[1])
+			}
+			blocks = append(blocks, b)
+		case bulletItem.MatchString(line), orderedItem.MatchString(line):
+			ordered := !bulletItem.MatchString(line)
+			item := bulletItem
+			if ordered {
+				item = orderedItem
+			}
+			b := block{kind: blockList, ordered: ordered}
+			for ; i < len(lines); i++ {
+				if m := item.FindStringSubmatch(lines[i]); m != nil {
+					b.lines = append(b.lines, m[1])
+				} else if continuation.MatchString(lines[i]) {
+					b.lines[len(b.lines)-1] += "\n" + strings.TrimSpace(lines[i])
+				} else {
+					break
+				}
+			}
+			blocks = append(blocks, b)
+		case tableRow.MatchString(line) && i+1 < len(lines) && tableDivider.MatchString(lines[i+1]):
+			b := block{kind: blockTable, lines: []string{line}}
+			for i += 2; i < len(lines) && tableRow.MatchString(lines[i]); i++ {
+				b.lines = append(b.lines, lines[i])
+			}
+			blocks = append(blocks, b)
+		default:
+			b := block{kind: blockParagraph}
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != "" && !startsBlock(lines[i]); i++ {
+				b.lines = append(b.lines, strings.TrimSpace(lines[i]))
+			}
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks
+}
+
+// startsBlock reports whether a line ends a paragraph by starting another
+// block
+func startsBlock(line string) bool {
+	return fence.MatchString(line) || headingLine.MatchString(line) || ruleLine.MatchString(line) ||
+		quoteLine.MatchString(line) || bulletItem.MatchString(line) || orderedItem.MatchString(line)
+}
+
+// tableCells splits a table row into its cells
+func tableCells(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|")
+	cells := strings.Split(row, "|")
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(cell)
+	}
+	return cells
+}
+
+// ToHTML renders markdown as an HTML fragment. Raw HTML in the reply is
+// escaped, and links keep only http, https and mailto targets.
+func ToHTML(content string) string {
+	var out strings.Builder
+	for _, b := range parseBlocks(content) {
+		switch b.kind {
+		case blockHeading:
+			fmt.Fprintf(&out, "<h%d>%s</h%d>\n", b.level, inlineHTML(b.lines[0]), b.level)
+		case blockCode:
+			class := ""
+			if b.language != "" {
+				class = fmt.Sprintf(` class="language-%s"`, html.EscapeString(b.language))
+			}
+			fmt.Fprintf(&out, "<pre><code%s>%s</code></pre>\n", class, html.EscapeString(strings.Join(b.lines, "\n")))
+		case blockQuote:
+			fmt.Fprintf(&out, "<blockquote><p>%s</p></blockquote>\n", inlineHTML(strings.Join(b.lines, "\n")))
+		case blockList:
+			tag := "ul"
+			if b.ordered {
+				tag = "ol"
+			}
+			fmt.Fprintf(&out, "<%s>\n", tag)
+			for _, item := range b.lines {
+				fmt.Fprintf(&out, "<li>%s</li>\n", inlineHTML(item))
+			}
+			fmt.Fprintf(&out, "</%s>\n", tag)
+		case blockTable:
+			out.WriteString("<table>\n<thead><tr>")
+			for _, cell := range tableCells(b.lines[0]) {
+				fmt.Fprintf(&out, "<th>%s</th>", inlineHTML(cell))
+			}
+			out.WriteString("</tr></thead>\n<tbody>\n")
+			for _, row := range b.lines[1:] {
+				out.WriteString("<tr>")
+				for _, cell := range tableCells(row) {
+					fmt.Fprintf(&out, "<td>%s</td>", inlineHTML(cell))
+				}
+				out.WriteString("</tr>\n")
+			}
+			out.WriteString("</tbody>\n</table>\n")
+		case blockRule:
+			out.WriteString("<hr>\n")
+		default:
+			fmt.Fprintf(&out, "<p>%s</p>\n", inlineHTML(strings.Join(b.lines, "\n")))
+		}
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// inlineHTML renders the inline markup of a text: code spans, images,
+// links, strong and emphasized text and strikethrough
+func inlineHTML(text string) string {
+	// Code spans are rendered first and kept from further markup
+	var spans []string
+	text = inlineCode.ReplaceAllStringFunc(text, func(span string) string {
+		spans = append(spans, "<code>"+html.EscapeString(inlineCode.FindStringSubmatch(span)[1])+"</code>")
+		return fmt.Sprintf("\x00%d\x00", len(spans)-1)
+	})
+
+	text = html.EscapeString(text)
+	text = image.ReplaceAllStringFunc(text, func(match string) string {
+		m := image.FindStringSubmatch(match)
+		if !safeURL(m[2]) {
+			return m[1]
+		}
+		return fmt.Sprintf(`<img src="%s" alt="%s">`, m[2], m[1])
+	})
+	text = link.ReplaceAllStringFunc(text, func(match string) string {
+		m := link.FindStringSubmatch(match)
+		if !safeURL(m[2]) {
+			return m[1]
+		}
+		return fmt.Sprintf(`<a href="%s">%s</a>`, m[2], m[1])
+	})
+	text = strong.ReplaceAllString(text, "<strong>$1$2</strong>")
+	text = emphasis.ReplaceAllStringFunc(text, func(match string) string {
+		m := emphasis.FindStringSubmatch(match)
+		if m[1] != "" {
+			return "<em>" + m[1] + "</em>"
+		}
+		prefix, suffix := match[:strings.Index(match, "_")], match[strings.LastIndex(match, "_")+1:]
+		return prefix + "<em>" + m[2] + "</em>" + suffix
+	})
+	text = strikethrough.ReplaceAllString(text, "<del>$1</del>")
+
+	for i, span := range spans {
+		text = strings.Replace(text, fmt.Sprintf("\x00%d\x00", i), span, 1)
+	}
+	return text
+}
+
+// safeURL reports whether a link target may be rendered: http, https and
+// mailto URLs and relative ones. The target is HTML-escaped already.
+func safeURL(target string) bool {
+	scheme, _, found := strings.Cut(target, ":")
+	if !found || strings.ContainsAny(scheme, "/?#") {
+		return true
+	}
+	switch strings.ToLower(scheme) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// ToText renders markdown as plain text: markup is dropped, links keep
+// their target in parentheses and code blocks their content
+func ToText(content string) string {
+	var parts []string
+	for _, b := range parseBlocks(content) {
+		switch b.kind {
+		case blockHeading:
+			parts = append(parts, inlineText(b.lines[0]))
+		case blockCode:
+			parts = append(parts, strings.Join(b.lines, "\n"))
+		case blockQuote, blockParagraph:
+			parts = append(parts, inlineText(strings.Join(b.lines, "\n")))
+		case blockList:
+			items := make([]string, len(b.lines))
+			for i, item := range b.lines {
+				marker := "- "
+				if b.ordered {
+					marker = strconv.Itoa(i+1) + ". "
+				}
+				items[i] = marker + inlineText(item)
+			}
+			parts = append(parts, strings.Join(items, "\n"))
+		case blockTable:
+			rows := make([]string, len(b.lines))
+			for i, row := range b.lines {
+				cells := tableCells(row)
+				for j, cell := range cells {
+					cells[j] = inlineText(cell)
+				}
+				rows[i] = strings.Join(cells, " | ")
+			}
+			parts = append(parts, strings.Join(rows, "\n"))
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// inlineText drops the inline markup of a text
+func inlineText(text string) string {
+	var spans []string
+	text = inlineCode.ReplaceAllStringFunc(text, func(span string) string {
+		spans = append(spans, inlineCode.FindStringSubmatch(span)[1])
+		return fmt.Sprintf("\x00%d\x00", len(spans)-1)
+	})
+	text = image.ReplaceAllString(text, "$1")
+	text = link.ReplaceAllStringFunc(text, func(match string) string {
+		m := link.FindStringSubmatch(match)
+		if m[1] == m[2] {
+			return m[1]
+		}
+		return fmt.Sprintf("%s (%s)", m[1], m[2])
+	})
+	text = strong.ReplaceAllString(text, "$1$2")
+	text = emphasis.ReplaceAllStringFunc(text, func(match string) string {
+		m := emphasis.FindStringSubmatch(match)
+		if m[1] != "" {
+			return m[1]
+		}
+		return strings.Replace(strings.Replace(match, "_", "", 1), "_", "", 1)
+	})
+	text = strikethrough.ReplaceAllString(text, "$1")
+	for i, span := range spans {
+		text = strings.Replace(text, fmt.Sprintf("\x00%d\x00", i), span, 1)
+	}
+	return text
+}
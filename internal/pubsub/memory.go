@@ -0,0 +1,56 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// subscriberBuffer is the number of events queued for a slow subscriber
+const subscriberBuffer = 64
+
+// Memory is an in-process bus, for single instances and tests
+type Memory struct {
+	mu     sync.Mutex
+	topics map[string]map[chan []byte]bool
+}
+
+// NewMemory creates an in-process bus
+func NewMemory() *Memory {
+	return &Memory{topics: make(map[string]map[chan []byte]bool)}
+}
+
+// Publish queues data for the subscribers of topic, dropping it for those
+// whose queue is full
+func (m *Memory) Publish(ctx context.Context, topic string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for subscriber := range m.topics[topic] {
+		select {
+		case subscriber <- data:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe returns the data published on topic until ctx is done
+func (m *Memory) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	subscriber := make(chan []byte, subscriberBuffer)
+	m.mu.Lock()
+	if m.topics[topic] == nil {
+		m.topics[topic] = make(map[chan []byte]bool)
+	}
+	m.topics[topic][subscriber] = true
+	m.mu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.topics[topic], subscriber)
+		if len(m.topics[topic]) == 0 {
+			delete(m.topics, topic)
+		}
+		close(subscriber)
+	})
+	return subscriber, nil
+}
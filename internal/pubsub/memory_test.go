@@ -0,0 +1,33 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory_PublishesToSubscribers(t *testing.T) {
+	bus := NewMemory()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	first, err := bus.Subscribe(ctx, "presence")
+	require.NoError(t, err)
+	second, err := bus.Subscribe(context.Background(), "presence")
+	require.NoError(t, err)
+	other, err := bus.Subscribe(context.Background(), "other")
+	require.NoError(t, err)
+
+	require.NoError(t, bus.Publish(ctx, "presence", []byte("typing")))
+	assert.Equal(t, []byte("typing"), <-first)
+	assert.Equal(t, []byte("typing"), <-second)
+	assert.Empty(t, other)
+
+	// Cancelling ends a subscription
+	cancel()
+	_, ok := <-first
+	assert.False(t, ok)
+	require.NoError(t, bus.Publish(context.Background(), "presence", []byte("done")))
+	assert.Equal(t, []byte("done"), <-second)
+}
@@ -0,0 +1,14 @@
+// Package pubsub carries events between the instances of the server, such as
+// the presence events of session WebSockets. Delivery is best effort: events
+// published while a subscriber is slow or disconnected are lost.
+package pubsub
+
+import "context"
+
+// Bus publishes events on named topics to the subscribers of all instances
+type Bus interface {
+	// Publish sends data to the current subscribers of topic
+	Publish(ctx context.Context, topic string, data []byte) error
+	// Subscribe returns the data published on topic until ctx is done
+	Subscribe(ctx context.Context, topic string) (<-chan []byte, error)
+}
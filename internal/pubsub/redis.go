@@ -0,0 +1,75 @@
+package pubsub
+
+import (
+	"context"
+	"time"
+
+	"agent-server/internal/redis"
+)
+
+// Backoff between attempts to resubscribe after the connection failed
+const (
+	minRetry = time.Second
+	maxRetry = 30 * time.Second
+)
+
+// Redis is a bus on Redis pub/sub channels named "<prefix>:<topic>", shared
+// by every instance connected to the server
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedis creates a bus on client
+func NewRedis(client *redis.Client, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+// Publish sends data to the subscribers of topic on all instances
+func (r *Redis) Publish(ctx context.Context, topic string, data []byte) error {
+	_, err := r.client.Do(ctx, "PUBLISH", r.channel(topic), data)
+	return err
+}
+
+// Subscribe returns the data published on topic until ctx is done. Failing
+// to subscribe is returned; a connection lost later is reestablished with
+// backoff.
+func (r *Redis) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	channel := r.channel(topic)
+	messages, err := r.client.Subscribe(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan []byte, subscriberBuffer)
+	go func() {
+		defer close(events)
+		retry := minRetry
+		for {
+			for message := range messages {
+				retry = minRetry
+				select {
+				case events <- []byte(message.Payload):
+				default:
+				}
+			}
+
+			for {
+				select {
+				case <-time.After(retry):
+				case <-ctx.Done():
+					return
+				}
+				retry = min(retry*2, maxRetry)
+				if messages, err = r.client.Subscribe(ctx, channel); err == nil {
+					break
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (r *Redis) channel(topic string) string {
+	return r.prefix + ":" + topic
+}
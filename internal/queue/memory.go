@@ -0,0 +1,133 @@
+package queue
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is an in-process broker, for single instances and tests
+type Memory struct {
+	tasks chan *Task
+	batch chan *Task
+
+	mu     sync.Mutex
+	events map[string]*eventLog
+}
+
+// eventLog buffers the events of a task until they are read
+type eventLog struct {
+	events  []Event
+	changed chan struct{} // closed and replaced when an event is added
+}
+
+// NewMemory creates an in-process broker holding up to capacity queued tasks
+// of each priority class
+func NewMemory(capacity int) *Memory {
+	return &Memory{
+		tasks:  make(chan *Task, capacity),
+		batch:  make(chan *Task, capacity),
+		events: make(map[string]*eventLog),
+	}
+}
+
+// Publish queues a task, waiting while the queue is full
+func (m *Memory) Publish(ctx context.Context, task *Task) error {
+	tasks := m.tasks
+	if task.Batch {
+		tasks = m.batch
+	}
+	select {
+	case tasks <- task:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Consume passes queued tasks to handler until ctx is done, interactive
+// tasks first
+func (m *Memory) Consume(ctx context.Context, handler Handler) error {
+	for {
+		select {
+		case task := <-m.tasks:
+			handler(ctx, task)
+			continue
+		default:
+		}
+		select {
+		case task := <-m.tasks:
+			handler(ctx, task)
+		case task := <-m.batch:
+			handler(ctx, task)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Emit buffers an event for the subscriber of the task
+func (m *Memory) Emit(ctx context.Context, taskID string, event Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	log := m.log(taskID)
+	log.events = append(log.events, event)
+	close(log.changed)
+	log.changed = make(chan struct{})
+	return nil
+}
+
+// Subscribe returns the events of a task, including those emitted before
+func (m *Memory) Subscribe(ctx context.Context, taskID string) (<-chan Event, error) {
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer func() {
+			m.mu.Lock()
+			delete(m.events, taskID)
+			m.mu.Unlock()
+		}()
+
+		next := 0
+		for {
+			m.mu.Lock()
+			log := m.log(taskID)
+			pending := log.events[next:]
+			changed := log.changed
+			m.mu.Unlock()
+
+			for _, event := range pending {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+				if event.Final {
+					return
+				}
+			}
+			next += len(pending)
+
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// Ping always succeeds
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+// log returns the event log of a task; m.mu must be held
+func (m *Memory) log(taskID string) *eventLog {
+	log, ok := m.events[taskID]
+	if !ok {
+		log = &eventLog{changed: make(chan struct{})}
+		m.events[taskID] = log
+	}
+	return log
+}
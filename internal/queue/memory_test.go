@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory_RelaysTasksAndEvents(t *testing.T) {
+	broker := NewMemory(10)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Events emitted before the subscription are kept
+	require.NoError(t, broker.Emit(ctx, "t1", Event{Type: "accepted"}))
+	events, err := broker.Subscribe(ctx, "t1")
+	require.NoError(t, err)
+
+	require.NoError(t, broker.Publish(ctx, &Task{ID: "t1", Kind: "chat"}))
+	go broker.Consume(ctx, func(ctx context.Context, task *Task) {
+		broker.Emit(ctx, task.ID, Event{Type: "chunk", Data: []byte(`"hi"`)})
+		broker.Emit(ctx, task.ID, Event{Type: "result", Final: true})
+	})
+
+	var types []string
+	for event := range events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{"accepted", "chunk", "result"}, types)
+}
+
+func TestMemory_InteractiveTasksFirst(t *testing.T) {
+	broker := NewMemory(10)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.NoError(t, broker.Publish(ctx, &Task{ID: "b1", Batch: true}))
+	require.NoError(t, broker.Publish(ctx, &Task{ID: "i1"}))
+	require.NoError(t, broker.Publish(ctx, &Task{ID: "b2", Batch: true}))
+	require.NoError(t, broker.Publish(ctx, &Task{ID: "i2"}))
+
+	var order []string
+	consumeCtx, stop := context.WithCancel(ctx)
+	broker.Consume(consumeCtx, func(ctx context.Context, task *Task) {
+		order = append(order, task.ID)
+		if len(order) == 4 {
+			stop()
+		}
+	})
+	assert.Equal(t, []string{"i1", "i2", "b1", "b2"}, order)
+}
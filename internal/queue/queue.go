@@ -0,0 +1,47 @@
+// Package queue carries work from API instances to worker instances and the
+// events of its progress back. Tasks are consumed by one worker each; the
+// events of a task are buffered until the instance that published it reads
+// them, so it may subscribe before or after the worker starts emitting.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Task is a unit of work
+type Task struct {
+	ID       string          `json:"id"`
+	Kind     string          `json:"kind"`
+	Payload  json.RawMessage `json:"payload"`
+	Deadline time.Time       `json:"deadline"`        // workers drop tasks picked up later, when the publisher has given up
+	Batch    bool            `json:"batch,omitempty"` // consumed after the interactive tasks queued
+}
+
+// Event reports the progress of a task. The final event ends its stream.
+type Event struct {
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Final bool            `json:"final,omitempty"`
+}
+
+// Handler processes a task
+type Handler func(ctx context.Context, task *Task)
+
+// Broker publishes tasks and relays their events
+type Broker interface {
+	// Publish queues a task for one worker
+	Publish(ctx context.Context, task *Task) error
+	// Consume passes tasks to handler, one at a time, until ctx is done.
+	// Queued interactive tasks are passed before batch tasks.
+	Consume(ctx context.Context, handler Handler) error
+	// Emit reports an event of a task
+	Emit(ctx context.Context, taskID string, event Event) error
+	// Subscribe returns the events of a task. The channel is closed after
+	// the final event, when ctx is done or when the broker fails; a channel
+	// closed without a final event means the outcome is unknown.
+	Subscribe(ctx context.Context, taskID string) (<-chan Event, error)
+	// Ping checks that the broker is reachable
+	Ping(ctx context.Context) error
+}
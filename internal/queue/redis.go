@@ -0,0 +1,203 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"agent-server/internal/redis"
+)
+
+// blockTimeout bounds blocking reads, so that consumers notice a closed
+// connection or a cancelled context
+const blockTimeout = 5 * time.Second
+
+// RedisOptions configures a Redis broker
+type RedisOptions struct {
+	Stream    string        // stream of tasks, batch tasks go to "<stream>:batch"; events go to "<stream>:events:<task id>"
+	Group     string        // consumer group of the workers
+	Consumer  string        // name of this worker in the group
+	ResultTTL time.Duration // how long unread events are kept
+	MaxLen    int           // approximate number of tasks the stream keeps
+}
+
+// Redis is a broker on Redis streams. Tasks are appended to a stream per
+// priority class read by a consumer group, so each is delivered to one
+// worker; they are acknowledged on delivery and not redelivered when a
+// worker fails, since a half-run chat must not run twice. Each task's
+// events go to a stream of their own that expires after the result TTL.
+type Redis struct {
+	client *redis.Client
+	opts   RedisOptions
+}
+
+// NewRedis creates a broker on client
+func NewRedis(client *redis.Client, opts RedisOptions) *Redis {
+	if opts.ResultTTL <= 0 {
+		opts.ResultTTL = 5 * time.Minute
+	}
+	if opts.MaxLen <= 0 {
+		opts.MaxLen = 10000
+	}
+	return &Redis{client: client, opts: opts}
+}
+
+// Publish appends a task to the task stream of its priority class
+func (r *Redis) Publish(ctx context.Context, task *Task) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	stream := r.opts.Stream
+	if task.Batch {
+		stream = r.batchStream()
+	}
+	_, err = r.client.Do(ctx, "XADD", stream, "MAXLEN", "~", r.opts.MaxLen, "*", "task", data)
+	return err
+}
+
+// Consume reads tasks as a member of the consumer group until ctx is done.
+// Batch tasks are only read when no interactive task is queued.
+func (r *Redis) Consume(ctx context.Context, handler Handler) error {
+	for _, stream := range []string{r.opts.Stream, r.batchStream()} {
+		_, err := r.client.Do(ctx, "XGROUP", "CREATE", stream, r.opts.Group, "$", "MKSTREAM")
+		if err != nil && !strings.HasPrefix(err.Error(), "redis: BUSYGROUP") {
+			return fmt.Errorf("failed to create consumer group: %w", err)
+		}
+	}
+
+	for {
+		// Queued interactive tasks first, then whichever class comes next
+		reply, err := r.client.Do(ctx, "XREADGROUP", "GROUP", r.opts.Group, r.opts.Consumer,
+			"COUNT", 1, "STREAMS", r.opts.Stream, ">")
+		if err == nil && len(streamEntries(reply)) == 0 {
+			reply, err = r.client.Do(ctx, "XREADGROUP", "GROUP", r.opts.Group, r.opts.Consumer,
+				"COUNT", 1, "BLOCK", blockTimeout.Milliseconds(), "STREAMS", r.opts.Stream, r.batchStream(), ">", ">")
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+
+		entries := streamEntries(reply)
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].stream == r.opts.Stream && entries[j].stream != r.opts.Stream
+		})
+		for _, entry := range entries {
+			if _, err := r.client.Do(ctx, "XACK", entry.stream, r.opts.Group, entry.id); err != nil {
+				return err
+			}
+			var task Task
+			if err := json.Unmarshal([]byte(entry.fields["task"]), &task); err != nil {
+				continue
+			}
+			handler(ctx, &task)
+		}
+	}
+}
+
+// Emit appends an event to the task's event stream
+func (r *Redis) Emit(ctx context.Context, taskID string, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	key := r.eventsKey(taskID)
+	if _, err := r.client.Do(ctx, "XADD", key, "*", "event", data); err != nil {
+		return err
+	}
+	_, err = r.client.Do(ctx, "PEXPIRE", key, r.opts.ResultTTL.Milliseconds())
+	return err
+}
+
+// Subscribe reads the task's event stream from its start. The stream is
+// deleted once the final event has been read.
+func (r *Redis) Subscribe(ctx context.Context, taskID string) (<-chan Event, error) {
+	key := r.eventsKey(taskID)
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+
+		last := "0"
+		for {
+			reply, err := r.client.Do(ctx, "XREAD", "COUNT", 100, "BLOCK", blockTimeout.Milliseconds(), "STREAMS", key, last)
+			if err != nil {
+				return
+			}
+			for _, entry := range streamEntries(reply) {
+				last = entry.id
+				var event Event
+				if err := json.Unmarshal([]byte(entry.fields["event"]), &event); err != nil {
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+				if event.Final {
+					r.client.Do(context.WithoutCancel(ctx), "DEL", key)
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// Ping checks the connection to Redis
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx)
+}
+
+// batchStream is the stream of batch tasks
+func (r *Redis) batchStream() string {
+	return r.opts.Stream + ":batch"
+}
+
+func (r *Redis) eventsKey(taskID string) string {
+	return r.opts.Stream + ":events:" + taskID
+}
+
+// streamEntry is an entry of a stream with its fields
+type streamEntry struct {
+	stream string
+	id     string
+	fields map[string]string
+}
+
+// streamEntries decodes the entries of an XREAD or XREADGROUP reply; a nil
+// reply, returned when the block timed out, has none
+func streamEntries(reply interface{}) []streamEntry {
+	var entries []streamEntry
+	streams, _ := reply.([]interface{})
+	for _, stream := range streams {
+		parts, ok := stream.([]interface{})
+		if !ok || len(parts) != 2 {
+			continue
+		}
+		name, _ := parts[0].(string)
+		items, _ := parts[1].([]interface{})
+		for _, item := range items {
+			pair, ok := item.([]interface{})
+			if !ok || len(pair) != 2 {
+				continue
+			}
+			id, _ := pair[0].(string)
+			values, _ := pair[1].([]interface{})
+			entry := streamEntry{stream: name, id: id, fields: make(map[string]string, len(values)/2)}
+			for i := 0; i+1 < len(values); i += 2 {
+				name, _ := values[i].(string)
+				value, _ := values[i+1].(string)
+				entry.fields[name] = value
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
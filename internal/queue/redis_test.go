@@ -0,0 +1,24 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamEntries(t *testing.T) {
+	reply := []interface{}{
+		[]interface{}{"tasks", []interface{}{
+			[]interface{}{"1-0", []interface{}{"task", `{"id":"a"}`}},
+			[]interface{}{"2-0", []interface{}{"task", `{"id":"b"}`, "extra", "x"}},
+		}},
+	}
+	entries := streamEntries(reply)
+	assert.Equal(t, []streamEntry{
+		{stream: "tasks", id: "1-0", fields: map[string]string{"task": `{"id":"a"}`}},
+		{stream: "tasks", id: "2-0", fields: map[string]string{"task": `{"id":"b"}`, "extra": "x"}},
+	}, entries)
+
+	// Blocked reads that timed out return nil
+	assert.Empty(t, streamEntries(nil))
+}
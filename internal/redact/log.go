@@ -0,0 +1,106 @@
+package redact
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sirupsen/logrus"
+)
+
+// slogHandler masks attributes before passing records to the next handler
+type slogHandler struct {
+	next     slog.Handler
+	redactor *Redactor
+}
+
+// NewSlogHandler wraps next so that sensitive attributes and credentials in
+// messages are masked
+func NewSlogHandler(next slog.Handler, r *Redactor) slog.Handler {
+	if r == nil {
+		return next
+	}
+	return &slogHandler{next: next, redactor: r}
+}
+
+func (h *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *slogHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, h.redactor.String(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.attr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.attr(attr)
+	}
+	return &slogHandler{next: h.next.WithAttrs(redacted), redactor: h.redactor}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	return &slogHandler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
+
+func (h *slogHandler) attr(attr slog.Attr) slog.Attr {
+	if h.redactor.IsSensitiveKey(attr.Key) {
+		return slog.String(attr.Key, Mask)
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, h.redactor.String(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, member := range group {
+			redacted[i] = h.attr(member)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, h.redactor.String(err.Error()))
+		}
+		return slog.Any(attr.Key, h.redactor.Value(value.Any()))
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+// LogrusHook masks sensitive fields and credentials in logrus entries
+type LogrusHook struct {
+	redactor *Redactor
+}
+
+// NewLogrusHook creates a hook masking entries with r
+func NewLogrusHook(r *Redactor) *LogrusHook {
+	return &LogrusHook{redactor: r}
+}
+
+// Levels implements logrus.Hook
+func (h *LogrusHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook
+func (h *LogrusHook) Fire(entry *logrus.Entry) error {
+	entry.Message = h.redactor.String(entry.Message)
+	for key, value := range entry.Data {
+		switch {
+		case h.redactor.IsSensitiveKey(key):
+			entry.Data[key] = Mask
+		case key == logrus.ErrorKey:
+			if err, ok := value.(error); ok {
+				entry.Data[key] = h.redactor.String(err.Error())
+			}
+		default:
+			entry.Data[key] = h.redactor.Value(value)
+		}
+	}
+	return nil
+}
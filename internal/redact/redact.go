@@ -0,0 +1,196 @@
+// Package redact masks credentials such as authorization headers, API keys
+// and bearer tokens before data is logged or persisted.
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"agent-server/internal/config"
+)
+
+// Mask replaces redacted values
+const Mask = "REDACTED"
+
+// DefaultKeyPatterns match the names of fields, headers and parameters whose
+// values are credentials. They deliberately miss counters such as
+// "total_tokens".
+var DefaultKeyPatterns = []string{
+	`authorization`,
+	`cookie`,
+	`(^|[-_])token$`,
+	`secret`,
+	`passw(or)?d`,
+	`api[-_]?key`,
+	`private[-_]?key`,
+	`credential`,
+}
+
+// DefaultValuePatterns match credentials embedded in free-form strings
+var DefaultValuePatterns = []string{
+	`\bbearer\s+[A-Za-z0-9\-._~+/]+=*`,
+	`\bbasic\s+[A-Za-z0-9+/]+=*`,
+}
+
+// Redactor masks sensitive values. A nil Redactor leaves data unchanged.
+type Redactor struct {
+	keys   []*regexp.Regexp
+	values []*regexp.Regexp
+}
+
+// New compiles key and value patterns, matched case-insensitively
+func New(keyPatterns, valuePatterns []string) (*Redactor, error) {
+	keys, err := compile(keyPatterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key pattern: %w", err)
+	}
+	values, err := compile(valuePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value pattern: %w", err)
+	}
+	return &Redactor{keys: keys, values: values}, nil
+}
+
+// NewFromConfig creates the redactor selected by configuration. It returns
+// nil when redaction is disabled; empty pattern lists select the defaults.
+func NewFromConfig(cfg config.RedactionConfig) (*Redactor, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	keyPatterns := cfg.KeyPatterns
+	if len(keyPatterns) == 0 {
+		keyPatterns = DefaultKeyPatterns
+	}
+	valuePatterns := cfg.ValuePatterns
+	if len(valuePatterns) == 0 {
+		valuePatterns = DefaultValuePatterns
+	}
+	return New(keyPatterns, valuePatterns)
+}
+
+// Default returns a redactor using the default patterns
+func Default() *Redactor {
+	r, err := New(DefaultKeyPatterns, DefaultValuePatterns)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func compile(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// IsSensitiveKey reports whether values stored under key must be masked
+func (r *Redactor) IsSensitiveKey(key string) bool {
+	if r == nil {
+		return false
+	}
+	for _, re := range r.keys {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// String masks credentials embedded in s
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, re := range r.values {
+		s = re.ReplaceAllString(s, Mask)
+	}
+	return s
+}
+
+// Map returns a copy of m with sensitive keys masked at any depth
+func (r *Redactor) Map(m map[string]interface{}) map[string]interface{} {
+	if r == nil || m == nil {
+		return m
+	}
+	redacted := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		if r.IsSensitiveKey(key) {
+			redacted[key] = Mask
+		} else {
+			redacted[key] = r.Value(value)
+		}
+	}
+	return redacted
+}
+
+// Value returns a copy of v with sensitive keys and embedded credentials
+// masked. Values of other composite types are converted to their JSON form.
+func (r *Redactor) Value(v interface{}) interface{} {
+	if r == nil || v == nil {
+		return v
+	}
+
+	switch value := v.(type) {
+	case string:
+		return r.String(value)
+	case map[string]interface{}:
+		return r.Map(value)
+	case []interface{}:
+		redacted := make([]interface{}, len(value))
+		for i, item := range value {
+			redacted[i] = r.Value(item)
+		}
+		return redacted
+	case map[string]string:
+		redacted := make(map[string]string, len(value))
+		for key, item := range value {
+			if r.IsSensitiveKey(key) {
+				redacted[key] = Mask
+			} else {
+				redacted[key] = r.String(item)
+			}
+		}
+		return redacted
+	case json.RawMessage:
+		return json.RawMessage(r.JSON(string(value)))
+	}
+
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Ptr:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return v
+		}
+		var generic interface{}
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return v
+		}
+		return r.Value(generic)
+	}
+	return v
+}
+
+// JSON masks a JSON document, e.g. a serialized tool result. Text that is
+// not JSON is masked as a plain string.
+func (r *Redactor) JSON(document string) string {
+	if r == nil {
+		return document
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(document), &parsed); err != nil {
+		return r.String(document)
+	}
+	data, err := json.Marshal(r.Value(parsed))
+	if err != nil {
+		return r.String(document)
+	}
+	return string(data)
+}
@@ -0,0 +1,106 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"agent-server/internal/config"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor_Map(t *testing.T) {
+	r := Default()
+
+	input := map[string]interface{}{
+		"url": "https://api.example.com",
+		"headers": map[string]interface{}{
+			"Authorization": "Bearer abc.def",
+			"X-Api-Key":     "k-123",
+			"Accept":        "application/json",
+		},
+		"note":  "call with Bearer abc.def please",
+		"usage": map[string]interface{}{"total_tokens": 42, "prompt_tokens": 10},
+		"items": []interface{}{map[string]interface{}{"access_token": "t-1", "id": 1}},
+		"calls": []map[string]interface{}{{"password": "hunter2"}},
+	}
+
+	redacted := r.Map(input)
+
+	headers := redacted["headers"].(map[string]interface{})
+	assert.Equal(t, Mask, headers["Authorization"])
+	assert.Equal(t, Mask, headers["X-Api-Key"])
+	assert.Equal(t, "application/json", headers["Accept"])
+	assert.Equal(t, "call with REDACTED please", redacted["note"])
+	assert.Equal(t, map[string]interface{}{"total_tokens": 42, "prompt_tokens": 10}, redacted["usage"])
+	assert.Equal(t, Mask, redacted["items"].([]interface{})[0].(map[string]interface{})["access_token"])
+	assert.Equal(t, Mask, redacted["calls"].([]interface{})[0].(map[string]interface{})["password"])
+
+	// The input is left untouched
+	assert.Equal(t, "Bearer abc.def", input["headers"].(map[string]interface{})["Authorization"])
+}
+
+func TestRedactor_JSON(t *testing.T) {
+	r := Default()
+
+	var parsed map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(r.JSON(`{"data":{"client_secret":"s","name":"n"}}`)), &parsed))
+	assert.Equal(t, Mask, parsed["data"].(map[string]interface{})["client_secret"])
+	assert.Equal(t, "n", parsed["data"].(map[string]interface{})["name"])
+
+	assert.Equal(t, "Authorization: REDACTED", r.JSON("Authorization: Basic dXNlcjpwYXNz"))
+}
+
+func TestNewFromConfig(t *testing.T) {
+	r, err := NewFromConfig(config.RedactionConfig{Enabled: false})
+	require.NoError(t, err)
+	assert.Nil(t, r)
+	assert.Equal(t, map[string]interface{}{"password": "x"}, r.Map(map[string]interface{}{"password": "x"}))
+
+	r, err = NewFromConfig(config.RedactionConfig{Enabled: true, KeyPatterns: []string{`^session_pin$`}, ValuePatterns: []string{`pin-\d+`}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"session_pin": Mask, "password": "x", "note": "use REDACTED"},
+		r.Map(map[string]interface{}{"session_pin": "1234", "password": "x", "note": "use pin-1234"}))
+
+	_, err = NewFromConfig(config.RedactionConfig{Enabled: true, KeyPatterns: []string{`(`}})
+	assert.Error(t, err)
+}
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewSlogHandler(slog.NewJSONHandler(&buf, nil), Default()))
+
+	logger.With("api_key", "k-1").Info("calling Bearer abc",
+		"headers", map[string]string{"Authorization": "Bearer abc"},
+		"error", errors.New("denied for Bearer abc"),
+		"total_tokens", 12)
+
+	out := buf.String()
+	assert.NotContains(t, out, "abc")
+	assert.NotContains(t, out, "k-1")
+	assert.Contains(t, out, `"total_tokens":12`)
+}
+
+func TestLogrusHook(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(NewLogrusHook(Default()))
+
+	logger.WithError(errors.New("token Bearer abc rejected")).
+		WithField("authorization", "Basic dXNlcjpwYXNz").
+		WithField("arguments", map[string]interface{}{"secret": "s-1"}).
+		Error("tool failed")
+
+	out := buf.String()
+	assert.NotContains(t, out, "abc")
+	assert.NotContains(t, out, "dXNlcjpwYXNz")
+	assert.NotContains(t, out, "s-1")
+	assert.Contains(t, out, "tool failed")
+}
@@ -0,0 +1,356 @@
+// Package redis is a small Redis client speaking RESP2 over pooled
+// connections. It covers the commands the server needs for queues, caches,
+// counters and pub/sub; replies are returned as plain Go values.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by commands sent after Close
+var ErrClosed = errors.New("redis: client is closed")
+
+// Error is an error reply of the server, such as a wrong type or an unknown
+// command. The connection stays usable after it.
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// Options configures a client
+type Options struct {
+	Address     string // host:port
+	Password    string
+	DB          int
+	DialTimeout time.Duration
+	MaxIdle     int // idle connections kept for reuse
+}
+
+// Client sends commands to a Redis server. It is safe for concurrent use;
+// every command takes a connection from the pool for its duration, so
+// blocking commands do not hold up others.
+type Client struct {
+	opts Options
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+// New creates a client. Connections are opened on first use.
+func New(opts Options) *Client {
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	if opts.MaxIdle <= 0 {
+		opts.MaxIdle = 8
+	}
+	return &Client{opts: opts}
+}
+
+// Do sends a command and returns its reply: a string for simple and bulk
+// strings, an int64 for integers, a []interface{} for arrays and nil for nil
+// replies. Error replies are returned as Error. Arguments are strings, byte
+// slices or integers.
+func (c *Client) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.do(ctx, args)
+	var replyErr Error
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection is in an unknown state after I/O errors
+		cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return reply, err
+}
+
+// Ping checks that the server answers
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// Message is a message received on a subscribed channel
+type Message struct {
+	Channel string
+	Payload string
+}
+
+// Subscribe listens on channels over a connection of its own. Messages are
+// delivered until ctx is done or the connection fails; then the returned
+// channel is closed.
+func (c *Client) Subscribe(ctx context.Context, channels ...string) (<-chan Message, error) {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	cn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	if err := cn.SetDeadline(deadline); err != nil {
+		cn.Close()
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		cn.Close()
+	})
+
+	args := []interface{}{"SUBSCRIBE"}
+	for _, channel := range channels {
+		args = append(args, channel)
+	}
+	err = writeCommand(cn.writer, args)
+	if err == nil {
+		err = cn.writer.Flush()
+	}
+	// The server confirms each channel
+	for range channels {
+		if err != nil {
+			break
+		}
+		_, err = readReply(cn.reader)
+	}
+	if err != nil {
+		stop()
+		cn.Close()
+		var replyErr Error
+		if errors.As(err, &replyErr) {
+			return nil, err
+		}
+		return nil, contextError(ctx, err)
+	}
+
+	messages := make(chan Message)
+	go func() {
+		defer close(messages)
+		defer cn.Close()
+		defer stop()
+		for {
+			reply, err := readReply(cn.reader)
+			if err != nil {
+				return
+			}
+			parts, ok := reply.([]interface{})
+			if !ok || len(parts) != 3 || parts[0] != "message" {
+				continue
+			}
+			channel, _ := parts[1].(string)
+			payload, _ := parts[2].(string)
+			select {
+			case messages <- Message{Channel: channel, Payload: payload}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return messages, nil
+}
+
+// Close closes the idle connections; connections in use are closed when
+// their command returns
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for _, cn := range c.idle {
+		cn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+// get returns an idle connection or dials a new one
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+	return c.dial(ctx)
+}
+
+// put returns a connection to the pool
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || len(c.idle) >= c.opts.MaxIdle {
+		cn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+// dial opens an authenticated connection to the configured database
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	dialer := net.Dialer{Timeout: c.opts.DialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.opts.Address)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	cn := &conn{
+		Conn:   netConn,
+		reader: bufio.NewReader(netConn),
+		writer: bufio.NewWriter(netConn),
+	}
+	if c.opts.Password != "" {
+		if _, err := cn.do(ctx, []interface{}{"AUTH", c.opts.Password}); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := cn.do(ctx, []interface{}{"SELECT", c.opts.DB}); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// conn is a connection to the server
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// do writes a command and reads its reply. Cancelling ctx interrupts a
+// blocked read.
+func (cn *conn) do(ctx context.Context, args []interface{}) (interface{}, error) {
+	deadline, _ := ctx.Deadline()
+	if err := cn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		cn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if err := writeCommand(cn.writer, args); err != nil {
+		return nil, contextError(ctx, err)
+	}
+	if err := cn.writer.Flush(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+	reply, err := readReply(cn.reader)
+	var replyErr Error
+	if err != nil && !errors.As(err, &replyErr) {
+		return nil, contextError(ctx, err)
+	}
+	return reply, err
+}
+
+// contextError prefers the context's error over the I/O error it caused
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return fmt.Errorf("redis: %w", err)
+}
+
+// writeCommand encodes a command as an array of bulk strings
+func writeCommand(w *bufio.Writer, args []interface{}) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var value string
+		switch v := arg.(type) {
+		case string:
+			value = v
+		case []byte:
+			value = string(v)
+		case int:
+			value = strconv.Itoa(v)
+		case int64:
+			value = strconv.FormatInt(v, 10)
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return fmt.Errorf("unsupported argument type %T", arg)
+		}
+		fmt.Fprintf(w, "$%d\r\n", len(value))
+		w.WriteString(value)
+		w.WriteString("\r\n")
+	}
+	return nil
+}
+
+// readReply decodes one reply
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, Error(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("malformed bulk length %q", payload)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("malformed array length %q", payload)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			items[i], err = readReply(r)
+			var replyErr Error
+			if err != nil && !errors.As(err, &replyErr) {
+				return nil, err
+			}
+			// Error replies inside arrays, e.g. of transactions, are kept
+			// as items
+			if err != nil {
+				items[i] = err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unknown reply type %q", kind)
+}
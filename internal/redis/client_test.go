@@ -0,0 +1,144 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer answers commands with the replies of respond, encoded in RESP
+func fakeServer(t *testing.T, respond func(args []string) string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					command, err := readReply(reader)
+					if err != nil {
+						return
+					}
+					var args []string
+					for _, arg := range command.([]interface{}) {
+						args = append(args, arg.(string))
+					}
+					if _, err := conn.Write([]byte(respond(args))); err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestClient_Do(t *testing.T) {
+	var mu sync.Mutex
+	var commands []string
+	address := fakeServer(t, func(args []string) string {
+		mu.Lock()
+		commands = append(commands, strings.Join(args, " "))
+		mu.Unlock()
+		switch strings.ToUpper(args[0]) {
+		case "AUTH", "SELECT", "PING":
+			return "+OK\r\n"
+		case "GET":
+			if args[1] == "missing" {
+				return "$-1\r\n"
+			}
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(args[1]), args[1])
+		case "INCR":
+			return ":42\r\n"
+		case "XRANGE":
+			return "*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$4\r\ntask\r\n$2\r\n{}\r\n"
+		case "BLPOP":
+			time.Sleep(time.Second)
+			return "*-1\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	})
+
+	client := New(Options{Address: address, Password: "secret", DB: 2})
+	defer client.Close()
+	ctx := context.Background()
+
+	require.NoError(t, client.Ping(ctx))
+	mu.Lock()
+	assert.Equal(t, []string{"AUTH secret", "SELECT 2", "PING"}, commands)
+	mu.Unlock()
+
+	reply, err := client.Do(ctx, "GET", "key")
+	require.NoError(t, err)
+	assert.Equal(t, "key", reply)
+	reply, err = client.Do(ctx, "GET", "missing")
+	require.NoError(t, err)
+	assert.Nil(t, reply)
+
+	reply, err = client.Do(ctx, "INCR", "counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), reply)
+
+	reply, err = client.Do(ctx, "XRANGE", "stream", "-", "+")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{[]interface{}{"1-0", []interface{}{"task", "{}"}}}, reply)
+
+	// Error replies keep the connection
+	_, err = client.Do(ctx, "NOPE")
+	assert.Equal(t, Error("ERR unknown command"), err)
+	assert.Len(t, client.idle, 1)
+
+	// Cancellation interrupts blocked commands
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Do(timeout, "BLPOP", "list", "0")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	address := fakeServer(t, func(args []string) string {
+		if strings.ToUpper(args[0]) != "SUBSCRIBE" {
+			return "-ERR unknown command\r\n"
+		}
+		// The confirmation is followed by a published message
+		return "*3\r\n$9\r\nsubscribe\r\n$4\r\nroom\r\n:1\r\n" +
+			"*3\r\n$7\r\nmessage\r\n$4\r\nroom\r\n$5\r\nhello\r\n"
+	})
+
+	client := New(Options{Address: address})
+	defer client.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	messages, err := client.Subscribe(ctx, "room")
+	require.NoError(t, err)
+	select {
+	case message := <-messages:
+		assert.Equal(t, Message{Channel: "room", Payload: "hello"}, message)
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+
+	// Cancelling ends the subscription
+	cancel()
+	select {
+	case _, ok := <-messages:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("subscription not closed")
+	}
+}
@@ -0,0 +1,363 @@
+// Package sandbox runs untrusted code in short-lived containers through the
+// docker or podman CLI. Containers are limited in CPU, memory, processes and
+// time, run without network access unless configured otherwise, and see
+// nothing of the host but their working directory.
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent-server/internal/config"
+
+	"github.com/google/uuid"
+)
+
+// Languages code can be run in
+const (
+	Python     = "python"
+	Go         = "go"
+	JavaScript = "javascript"
+)
+
+// language is how the code of a language is run
+type language struct {
+	file    string // the code is written to, in the working directory
+	command []string
+	env     []string
+}
+
+var languages = map[string]language{
+	Python: {file: "main.py", command: []string{"python3", "-u", "main.py"}},
+	Go: {
+		file:    "main.go",
+		command: []string{"go", "run", "main.go"},
+		env:     []string{"GOCACHE=/tmp/go-cache", "GOPATH=/tmp/go", "GOTOOLCHAIN=local", "CGO_ENABLED=0"},
+	},
+	JavaScript: {file: "main.js", command: []string{"node", "main.js"}},
+}
+
+// aliases are other names of languages
+var aliases = map[string]string{
+	"py":      Python,
+	"python3": Python,
+	"golang":  Go,
+	"js":      JavaScript,
+	"node":    JavaScript,
+	"nodejs":  JavaScript,
+}
+
+// Normalize returns the language of a name or alias
+func Normalize(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if language, ok := aliases[name]; ok {
+		return language
+	}
+	return name
+}
+
+// workDir is where the working directory is mounted in containers
+const workDir = "/work"
+
+// runtimeFailure is the exit code of docker and podman when they could not
+// run the container, e.g. because the image is missing
+const runtimeFailure = 125
+
+var (
+	// ErrUnsupportedLanguage is returned for languages without an image
+	ErrUnsupportedLanguage = errors.New("unsupported language")
+	// ErrInvalidFileName is returned for input files named outside the
+	// working directory
+	ErrInvalidFileName = errors.New("invalid file name")
+)
+
+// Request is code to run
+type Request struct {
+	Language string
+	Code     string
+	// Files are written to the working directory before the code runs,
+	// keyed by their path in it
+	Files map[string][]byte
+}
+
+// File is a file the code wrote to its working directory
+type File struct {
+	Name string // path in the working directory
+	Data []byte
+}
+
+// Result is the outcome of a run
+type Result struct {
+	Language  string
+	Stdout    string
+	Stderr    string
+	ExitCode  int // -1 when the run timed out
+	TimedOut  bool
+	Truncated bool // stdout or stderr was cut at the output limit
+	Duration  time.Duration
+
+	// Files are the files the code created or changed. Skipped names those
+	// over the size or count limit.
+	Files   []File
+	Skipped []string
+}
+
+// Runner runs code in containers
+type Runner struct {
+	cfg     config.ToolSandboxConfig
+	runtime string // path of the container CLI
+	user    string // uid:gid of containers
+	keepID  bool   // map the user into rootless podman containers
+}
+
+// New returns the runner of the configured sandbox. It fails when the
+// container CLI cannot be found.
+func New(cfg config.ToolSandboxConfig) (*Runner, error) {
+	runtime, err := exec.LookPath(cfg.Runtime)
+	if err != nil {
+		return nil, fmt.Errorf("container runtime %q not found: %w", cfg.Runtime, err)
+	}
+
+	// Containers run as the server's user, so it can remove what they
+	// write, and never as root
+	r := &Runner{cfg: cfg, runtime: runtime, user: "65534:65534"}
+	if uid := os.Getuid(); uid > 0 {
+		r.user = fmt.Sprintf("%d:%d", uid, os.Getgid())
+		r.keepID = strings.Contains(filepath.Base(runtime), "podman")
+	}
+	return r, nil
+}
+
+// Languages returns the languages that have an image, sorted
+func (r *Runner) Languages() []string {
+	var names []string
+	for name, image := range r.cfg.Images {
+		if _, ok := languages[name]; ok && image != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Network reports whether containers have network access
+func (r *Runner) Network() bool {
+	return r.cfg.Network
+}
+
+// Run runs code in a new container, which is removed afterwards. Code that
+// fails or runs out of time is reported in the result; errors mean the
+// container could not be run.
+func (r *Runner) Run(ctx context.Context, req Request) (*Result, error) {
+	name := Normalize(req.Language)
+	spec, ok := languages[name]
+	image := r.cfg.Images[name]
+	if !ok || image == "" {
+		return nil, fmt.Errorf("%w: %s; supported are %s", ErrUnsupportedLanguage, req.Language, strings.Join(r.Languages(), ", "))
+	}
+
+	inputs := map[string][]byte{spec.file: []byte(req.Code)}
+	for file, data := range req.Files {
+		if !validName(file) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFileName, file)
+		}
+		if file == spec.file {
+			return nil, fmt.Errorf("%w: %s holds the code", ErrInvalidFileName, file)
+		}
+		inputs[file] = data
+	}
+
+	dir, err := os.MkdirTemp("", "sandbox-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create working directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := writeInputs(dir, inputs); err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, time.Duration(r.cfg.Timeout)*time.Second)
+	defer cancel()
+	container := "agent-sandbox-" + uuid.New().String()
+	stdout := &limitedBuffer{limit: r.cfg.MaxOutput}
+	stderr := &limitedBuffer{limit: r.cfg.MaxOutput}
+	cmd := exec.CommandContext(runCtx, r.runtime, r.args(container, dir, image, spec)...)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	start := time.Now()
+	err = cmd.Run()
+	result := &Result{
+		Language:  name,
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Truncated: stdout.truncated || stderr.truncated,
+		Duration:  time.Since(start),
+	}
+	if runCtx.Err() != nil {
+		// Killing the CLI leaves the container running
+		r.remove(container)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		result.TimedOut = true
+		result.ExitCode = -1
+	} else if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("failed to run container: %w", err)
+		}
+		if exitErr.ExitCode() == runtimeFailure {
+			return nil, fmt.Errorf("container runtime failed: %s", strings.TrimSpace(stderr.String()))
+		}
+		result.ExitCode = exitErr.ExitCode()
+	}
+
+	result.Files, result.Skipped, err = r.collect(dir, inputs)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// args returns the arguments of the CLI to run code in a container
+func (r *Runner) args(container, dir, image string, spec language) []string {
+	memory := fmt.Sprintf("%dm", r.cfg.Memory)
+	args := []string{
+		"run", "--rm", "--name", container,
+		"--cpus", strconv.FormatFloat(r.cfg.CPUs, 'f', -1, 64),
+		"--memory", memory,
+		"--memory-swap", memory,
+		"--pids-limit", strconv.Itoa(r.cfg.PidsLimit),
+		"--read-only",
+		"--tmpfs", "/tmp:rw,exec,nosuid,size=" + memory,
+		"--cap-drop", "ALL",
+		"--security-opt", "no-new-privileges",
+		"--user", r.user,
+		"--volume", dir + ":" + workDir,
+		"--workdir", workDir,
+		"--env", "HOME=/tmp",
+	}
+	if !r.cfg.Network {
+		args = append(args, "--network", "none")
+	}
+	if r.keepID {
+		args = append(args, "--userns", "keep-id")
+	}
+	for _, env := range spec.env {
+		args = append(args, "--env", env)
+	}
+	args = append(args, image)
+	return append(args, spec.command...)
+}
+
+// remove force-removes a container
+func (r *Runner) remove(container string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = exec.CommandContext(ctx, r.runtime, "rm", "--force", container).Run()
+}
+
+// collect returns the files of the working directory the code created or
+// changed. Symbolic links are not followed.
+func (r *Runner) collect(dir string, inputs map[string][]byte) ([]File, []string, error) {
+	var files []File
+	var skipped []string
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		input, isInput := inputs[name]
+		if isInput && info.Size() != int64(len(input)) {
+			isInput = false
+		}
+		if !isInput && ((r.cfg.MaxFileSize > 0 && info.Size() > int64(r.cfg.MaxFileSize)) ||
+			(r.cfg.MaxFiles > 0 && len(files) >= r.cfg.MaxFiles)) {
+			skipped = append(skipped, name)
+			return nil
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if isInput && bytes.Equal(data, input) {
+			return nil
+		}
+		files = append(files, File{Name: name, Data: data})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read generated files: %w", err)
+	}
+	return files, skipped, nil
+}
+
+// writeInputs writes the code and input files to the working directory,
+// which containers must be able to write to whichever user they run as
+func writeInputs(dir string, inputs map[string][]byte) error {
+	if err := os.Chmod(dir, 0o777); err != nil {
+		return fmt.Errorf("failed to prepare working directory: %w", err)
+	}
+	for name, data := range inputs {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o777); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if err := os.WriteFile(file, data, 0o666); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// validName reports whether a file name is a clean relative path within the
+// working directory
+func validName(name string) bool {
+	if name == "" || strings.Contains(name, "\\") || path.IsAbs(name) || path.Clean(name) != name {
+		return false
+	}
+	return name != "." && name != ".." && !strings.HasPrefix(name, "../")
+}
+
+// limitedBuffer keeps the first limit bytes written to it; 0 keeps all
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 && b.buf.Len()+len(p) > b.limit {
+		b.buf.Write(p[:b.limit-b.buf.Len()])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
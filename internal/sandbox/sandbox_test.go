@@ -0,0 +1,129 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent-server/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRuntime stands in for docker: it runs script in the mounted working
+// directory, and records the arguments of each call in calls.log
+const fakeRuntime = `#!/bin/sh
+echo "$@" >> "$(dirname "$0")/calls.log"
+[ "$1" = rm ] && exit 0
+while [ $# -gt 0 ]; do
+	case "$1" in
+	--volume) dir="${2%%:*}"; shift ;;
+	esac
+	shift
+done
+cd "$dir" || exit 125
+`
+
+func newTestRunner(t *testing.T, script string) (*Runner, string) {
+	t.Helper()
+	bin := t.TempDir()
+	runtime := filepath.Join(bin, "docker")
+	require.NoError(t, os.WriteFile(runtime, []byte(fakeRuntime+script), 0o755))
+
+	runner, err := New(config.ToolSandboxConfig{
+		Runtime:   runtime,
+		Images:    map[string]string{Python: "python:3.12-slim", Go: "golang:1.22-alpine", JavaScript: ""},
+		CPUs:      0.5,
+		Memory:    256,
+		PidsLimit: 64,
+		Timeout:   1,
+		MaxOutput: 16,
+		MaxFiles:  2,
+	})
+	require.NoError(t, err)
+	return runner, filepath.Join(bin, "calls.log")
+}
+
+func TestRunner_Run(t *testing.T) {
+	runner, calls := newTestRunner(t, `
+cp main.py copy.py
+echo changed >> data/changed.csv
+printf 'x' > skipped.txt
+echo "hello from the sandbox"
+echo oops >&2
+exit 3
+`)
+	assert.Equal(t, []string{Go, Python}, runner.Languages())
+
+	result, err := runner.Run(context.Background(), Request{
+		Language: "py",
+		Code:     "print(1)",
+		Files: map[string][]byte{
+			"data/input.csv":   []byte("a,b\n"),
+			"data/changed.csv": []byte("a\n"),
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Python, result.Language)
+	assert.Equal(t, 3, result.ExitCode)
+	assert.Equal(t, "hello from the s", result.Stdout)
+	assert.Equal(t, "oops\n", result.Stderr)
+	assert.True(t, result.Truncated)
+	assert.False(t, result.TimedOut)
+
+	// Unchanged inputs are not returned, files past max_files are skipped
+	require.Len(t, result.Files, 2)
+	assert.Equal(t, File{Name: "copy.py", Data: []byte("print(1)")}, result.Files[0])
+	assert.Equal(t, File{Name: "data/changed.csv", Data: []byte("a\nchanged\n")}, result.Files[1])
+	assert.Equal(t, []string{"skipped.txt"}, result.Skipped)
+
+	log, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	args := string(log)
+	for _, want := range []string{"run --rm --name agent-sandbox-", "--cpus 0.5", "--memory 256m", "--memory-swap 256m",
+		"--pids-limit 64", "--read-only", "--cap-drop ALL", "--network none", "python:3.12-slim python3 -u main.py"} {
+		assert.Contains(t, args, want)
+	}
+}
+
+func TestRunner_RunTimeout(t *testing.T) {
+	runner, calls := newTestRunner(t, "echo started\nexec sleep 5\n")
+
+	result, err := runner.Run(context.Background(), Request{Language: Go, Code: "package main"})
+	require.NoError(t, err)
+	assert.True(t, result.TimedOut)
+	assert.Equal(t, -1, result.ExitCode)
+	assert.Equal(t, "started\n", result.Stdout)
+
+	// The container is removed, as killing the CLI leaves it running
+	log, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(log)), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[1], "rm --force agent-sandbox-"), lines[1])
+}
+
+func TestRunner_RunErrors(t *testing.T) {
+	runner, _ := newTestRunner(t, "echo 'image missing' >&2\nexit 125\n")
+
+	_, err := runner.Run(context.Background(), Request{Language: Python, Code: "print(1)"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "image missing")
+
+	_, err = runner.Run(context.Background(), Request{Language: "javascript", Code: "1"})
+	assert.True(t, errors.Is(err, ErrUnsupportedLanguage))
+	_, err = runner.Run(context.Background(), Request{Language: "rust", Code: "fn main() {}"})
+	assert.True(t, errors.Is(err, ErrUnsupportedLanguage))
+
+	for _, name := range []string{"../escape.txt", "/etc/passwd", "a/../../b", "main.py", ""} {
+		_, err = runner.Run(context.Background(), Request{Language: Python, Code: "1", Files: map[string][]byte{name: nil}})
+		assert.True(t, errors.Is(err, ErrInvalidFileName), name)
+	}
+
+	_, err = New(config.ToolSandboxConfig{Runtime: "no-such-container-runtime"})
+	assert.Error(t, err)
+}
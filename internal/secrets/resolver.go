@@ -0,0 +1,114 @@
+// Package secrets resolves secret references in configuration values so that
+// API keys and credentials do not have to be stored in plain text.
+//
+// Supported references:
+//
+//	${NAME}                             environment variable NAME, empty if unset
+//	env://NAME                          environment variable NAME, which must be set
+//	file:///run/secrets/openai          trimmed contents of a file
+//	secret://vault/<mount>/<path>#key   field of a Vault KV v2 secret
+//
+// Any other value is returned unchanged. Errors name the reference but never
+// the resolved value.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Backend resolves secret:// references for one secret store
+type Backend interface {
+	// Lookup returns the value stored at path (everything after the backend
+	// name), optionally selecting a field
+	Lookup(ctx context.Context, path, field string) (string, error)
+}
+
+// Resolver turns secret references into their values
+type Resolver struct {
+	backends map[string]Backend
+	getenv   func(string) string
+	readFile func(string) ([]byte, error)
+}
+
+// NewResolver creates a resolver for environment and file references. Vault
+// is enabled when VAULT_ADDR is set.
+func NewResolver() *Resolver {
+	r := &Resolver{
+		backends: make(map[string]Backend),
+		getenv:   os.Getenv,
+		readFile: os.ReadFile,
+	}
+	if vault := NewVaultFromEnv(); vault != nil {
+		r.Register("vault", vault)
+	}
+	return r
+}
+
+// Register adds a backend for secret://<name>/... references
+func (r *Resolver) Register(name string, backend Backend) {
+	r.backends[name] = backend
+}
+
+var envPlaceholder = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// IsReference reports whether value is a secret reference rather than a
+// literal value
+func IsReference(value string) bool {
+	return envPlaceholder.MatchString(value) ||
+		strings.HasPrefix(value, "env://") ||
+		strings.HasPrefix(value, "file://") ||
+		strings.HasPrefix(value, "secret://")
+}
+
+// Resolve returns the value a reference points to, or value itself if it is
+// not a reference
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	if m := envPlaceholder.FindStringSubmatch(value); m != nil {
+		// Shell-style placeholders are optional, like in the sample config
+		return r.getenv(m[1]), nil
+	}
+
+	switch {
+	case strings.HasPrefix(value, "env://"):
+		return r.env(strings.TrimPrefix(value, "env://"))
+
+	case strings.HasPrefix(value, "file://"):
+		path := strings.TrimPrefix(value, "file://")
+		data, err := r.readFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file %s: %w", path, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+
+	case strings.HasPrefix(value, "secret://"):
+		ref := strings.TrimPrefix(value, "secret://")
+		ref, field, _ := strings.Cut(ref, "#")
+		name, path, _ := strings.Cut(ref, "/")
+		backend, ok := r.backends[name]
+		if !ok {
+			return "", fmt.Errorf("secret backend %q is not configured (in %s)", name, value)
+		}
+		if path == "" {
+			return "", fmt.Errorf("secret reference %s has no path", value)
+		}
+		secret, err := backend.Lookup(ctx, path, field)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", value, err)
+		}
+		return secret, nil
+	}
+
+	return value, nil
+}
+
+func (r *Resolver) env(name string) (string, error) {
+	value := r.getenv(name)
+	if value == "" {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
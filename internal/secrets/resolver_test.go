@@ -0,0 +1,71 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "openai")
+	require.NoError(t, os.WriteFile(keyFile, []byte("sk-from-file\n"), 0o600))
+
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/data/providers/openai":
+			w.Write([]byte(`{"data":{"data":{"api_key":"sk-from-vault","org":"acme"}}}`))
+		case "/v1/kv/data/single":
+			w.Write([]byte(`{"data":{"data":{"token":"only"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+
+	r := NewResolver()
+	r.getenv = func(name string) string {
+		return map[string]string{"OPENAI_API_KEY": "sk-from-env"}[name]
+	}
+	r.Register("vault", NewVault(vault.URL, "root", ""))
+	ctx := context.Background()
+
+	tests := map[string]string{
+		"plain-value":          "plain-value",
+		"${OPENAI_API_KEY}":    "sk-from-env",
+		"${UNSET_KEY}":         "",
+		"env://OPENAI_API_KEY": "sk-from-env",
+		"file://" + keyFile:    "sk-from-file",
+		"secret://vault/kv/providers/openai#api_key": "sk-from-vault",
+		"secret://vault/kv/single":                   "only",
+	}
+	for ref, want := range tests {
+		got, err := r.Resolve(ctx, ref)
+		require.NoError(t, err, ref)
+		assert.Equal(t, want, got, ref)
+	}
+
+	for _, ref := range []string{
+		"env://UNSET_KEY",
+		"file://" + filepath.Join(dir, "missing"),
+		"secret://vault/kv/providers/openai",
+		"secret://vault/kv/missing#key",
+		"secret://aws/prod/openai",
+	} {
+		_, err := r.Resolve(ctx, ref)
+		assert.Error(t, err, ref)
+		if err != nil {
+			assert.NotContains(t, err.Error(), "sk-from", "errors must not leak secret values")
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Vault reads secrets from a HashiCorp Vault KV version 2 engine
+type Vault struct {
+	address    string
+	token      string
+	namespace  string
+	httpClient *http.Client
+}
+
+// NewVault creates a Vault backend
+func NewVault(address, token, namespace string) *Vault {
+	return &Vault{
+		address:    strings.TrimRight(address, "/"),
+		token:      token,
+		namespace:  namespace,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewVaultFromEnv creates a Vault backend from the standard VAULT_ADDR,
+// VAULT_TOKEN and VAULT_NAMESPACE variables, or returns nil if VAULT_ADDR is
+// not set
+func NewVaultFromEnv() *Vault {
+	address := os.Getenv("VAULT_ADDR")
+	if address == "" {
+		return nil
+	}
+	return NewVault(address, os.Getenv("VAULT_TOKEN"), os.Getenv("VAULT_NAMESPACE"))
+}
+
+// Lookup reads <mount>/<path> and returns field. Without a field the secret
+// must hold exactly one key.
+func (v *Vault) Lookup(ctx context.Context, path, field string) (string, error) {
+	mount, secretPath, ok := strings.Cut(path, "/")
+	if !ok || secretPath == "" {
+		return "", fmt.Errorf("vault reference must be <mount>/<path>")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.address+"/v1/"+mount+"/data/"+secretPath, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned HTTP %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid vault response: %w", err)
+	}
+	data := body.Data.Data
+
+	if field == "" {
+		if len(data) != 1 {
+			keys := make([]string, 0, len(data))
+			for key := range data {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			return "", fmt.Errorf("secret has fields %v; select one with #field", keys)
+		}
+		for key := range data {
+			field = key
+		}
+	}
+
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("secret has no string field %q", field)
+	}
+	return value, nil
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+	"agent-server/internal/tools"
+)
+
+// BudgetExceededErrorCode marks tool calls rejected because the budget is
+// spent
+const BudgetExceededErrorCode = "BUDGET_EXCEEDED"
+
+// ErrBudgetExceeded is returned when a session or agent has spent its budget
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// ModelPrice holds token prices per million tokens
+type ModelPrice struct {
+	Prompt     float64
+	Completion float64
+}
+
+// Budget caps spending; a zero field is unlimited
+type Budget struct {
+	PerSession  float64
+	PerAgentDay float64 // per UTC day
+}
+
+// Accounting prices LLM token usage and tool calls and enforces spending
+// budgets. A nil Accounting prices nothing and enforces nothing.
+type Accounting struct {
+	repo        storage.Repository
+	modelPrices map[string]ModelPrice
+	toolCosts   map[string]float64
+	budget      Budget
+}
+
+// NewAccounting creates an accounting without prices or budgets
+func NewAccounting(repo storage.Repository) *Accounting {
+	return &Accounting{repo: repo}
+}
+
+// SetModelPrices sets token prices keyed by "provider/model" or model name
+func (a *Accounting) SetModelPrices(prices map[string]ModelPrice) {
+	a.modelPrices = prices
+}
+
+// SetToolCosts sets the per-call cost of tools that do not report their own
+func (a *Accounting) SetToolCosts(costs map[string]float64) {
+	a.toolCosts = costs
+}
+
+// SetBudget sets the spending limits
+func (a *Accounting) SetBudget(budget Budget) {
+	a.budget = budget
+}
+
+// LLMCost prices token usage of the agent's model. It reports false when the
+// model has no price.
+func (a *Accounting) LLMCost(agent *models.Agent, usage *llm.Usage) (float64, bool) {
+	if a == nil || usage == nil {
+		return 0, false
+	}
+	price, ok := a.modelPrices[agent.Provider+"/"+agent.Model]
+	if !ok {
+		price, ok = a.modelPrices[agent.Model]
+	}
+	if !ok {
+		return 0, false
+	}
+	cost := (float64(usage.PromptTokens)*price.Prompt + float64(usage.CompletionTokens)*price.Completion) / 1e6
+	return cost, true
+}
+
+// ToolCost returns the cost the tool reported, or its configured per-call
+// cost
+func (a *Accounting) ToolCost(toolName string, result *tools.Result) float64 {
+	if cost, ok := result.Cost(); ok {
+		return cost
+	}
+	if a == nil {
+		return 0
+	}
+	return a.toolCosts[toolName]
+}
+
+// CheckBudget returns ErrBudgetExceeded when the session or its agent has
+// spent its budget
+func (a *Accounting) CheckBudget(ctx context.Context, session *models.ChatSession) error {
+	if a == nil {
+		return nil
+	}
+	checks := []struct {
+		limit  float64
+		filter models.CostFilter
+		scope  string
+	}{
+		{a.budget.PerSession, models.CostFilter{SessionID: session.ID}, "session"},
+		{a.budget.PerAgentDay, models.CostFilter{AgentID: session.AgentID, Since: startOfDay(time.Now())}, "agent's daily"},
+	}
+	for _, check := range checks {
+		if check.limit <= 0 {
+			continue
+		}
+		costs, err := a.repo.Stats().Costs(ctx, check.filter)
+		if err != nil {
+			return fmt.Errorf("failed to sum costs: %w", err)
+		}
+		if costs.TotalCost >= check.limit {
+			return fmt.Errorf("%w: spent %.4f of the %s budget of %.4f", ErrBudgetExceeded, costs.TotalCost, check.scope, check.limit)
+		}
+	}
+	return nil
+}
+
+// SessionUsage reports what a session has spent against the session budget
+func (a *Accounting) SessionUsage(ctx context.Context, sessionID string) (*models.UsageReport, error) {
+	session, err := a.repo.Session().GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
+
+	filter := models.CostFilter{SessionID: sessionID}
+	costs, err := a.repo.Stats().Costs(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum costs: %w", err)
+	}
+	prompt, err := a.repo.Stats().PromptTokens(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum prompt tokens: %w", err)
+	}
+	return newUsageReport(session.AgentID, sessionID, nil, costs, prompt, a.budget.PerSession), nil
+}
+
+// AgentUsage reports what an agent has spent over the last days UTC days,
+// including today. The daily budget applies when days is 1.
+func (a *Accounting) AgentUsage(ctx context.Context, agentID string, days int) (*models.UsageReport, error) {
+	agent, err := a.repo.Agent().GetByID(ctx, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent: %w", err)
+	}
+	if agent == nil {
+		return nil, ErrAgentNotFound
+	}
+
+	since := startOfDay(time.Now()).AddDate(0, 0, 1-days)
+	filter := models.CostFilter{AgentID: agentID, Since: since}
+	costs, err := a.repo.Stats().Costs(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum costs: %w", err)
+	}
+	prompt, err := a.repo.Stats().PromptTokens(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum prompt tokens: %w", err)
+	}
+
+	var budget float64
+	if days == 1 {
+		budget = a.budget.PerAgentDay
+	}
+	return newUsageReport(agentID, "", &since, costs, prompt, budget), nil
+}
+
+func newUsageReport(agentID, sessionID string, since *time.Time, costs *models.CostSummary, prompt *models.PromptTokenStats, budget float64) *models.UsageReport {
+	report := &models.UsageReport{
+		AgentID:   agentID,
+		SessionID: sessionID,
+		Since:     since,
+		Usage:     *costs,
+		Prompt:    *prompt,
+		Budget:    budget,
+	}
+	if budget > 0 {
+		remaining := budget - costs.TotalCost
+		if remaining < 0 {
+			remaining = 0
+		}
+		report.Remaining = &remaining
+	}
+	return report
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+)
+
+// Agent status levels, from best to worst
+const (
+	AgentStatusHealthy     = "healthy"
+	AgentStatusDegraded    = "degraded"
+	AgentStatusUnavailable = "unavailable"
+	AgentStatusDisabled    = "disabled"
+)
+
+// Defaults for agent status checks
+const (
+	DefaultStatusErrorWindow   = time.Hour
+	DefaultStatusErrorRateWarn = 0.25
+	statusCheckTimeout         = 5 * time.Second
+)
+
+// AgentStatus aggregates the health signals of a single agent
+type AgentStatus struct {
+	AgentID              string            `json:"agent_id"`
+	Status               string            `json:"status"`
+	Enabled              bool              `json:"enabled"`
+	Provider             ProviderStatus    `json:"provider"`
+	Model                ModelStatus       `json:"model"`
+	Tools                []AgentToolStatus `json:"tools"`
+	LastSuccessfulChatAt *time.Time        `json:"last_successful_chat_at,omitempty"`
+	RecentChats          ChatStats         `json:"recent_chats"`
+	ErrorWindow          string            `json:"error_window"`
+	Issues               []string          `json:"issues,omitempty"`
+	CheckedAt            time.Time         `json:"checked_at"`
+}
+
+// ProviderStatus reports whether the agent's LLM provider is reachable
+type ProviderStatus struct {
+	Name       string `json:"name"`
+	Registered bool   `json:"registered"`
+	Available  bool   `json:"available"`
+}
+
+// ModelStatus reports whether the configured model exists on the provider.
+// Available is nil when the provider's model list could not be fetched.
+type ModelStatus struct {
+	Name      string `json:"name"`
+	Available *bool  `json:"available"`
+	Error     string `json:"error,omitempty"`
+}
+
+// AgentToolStatus reports the availability of one allowlisted tool
+type AgentToolStatus struct {
+	Name       string `json:"name"`
+	Registered bool   `json:"registered"`
+	Available  bool   `json:"available"`
+}
+
+// AgentStatusService builds status reports for agents
+type AgentStatusService struct {
+	repo          storage.Repository
+	llmRegistry   *llm.Registry
+	toolService   *ToolService
+	chatService   *ChatService
+	errorWindow   time.Duration
+	errorRateWarn float64
+	logger        *slog.Logger
+}
+
+// NewAgentStatusService creates a new agent status service
+func NewAgentStatusService(
+	repo storage.Repository,
+	llmRegistry *llm.Registry,
+	toolService *ToolService,
+	chatService *ChatService,
+	logger *slog.Logger,
+) *AgentStatusService {
+	return &AgentStatusService{
+		repo:          repo,
+		llmRegistry:   llmRegistry,
+		toolService:   toolService,
+		chatService:   chatService,
+		errorWindow:   DefaultStatusErrorWindow,
+		errorRateWarn: DefaultStatusErrorRateWarn,
+		logger:        logger,
+	}
+}
+
+// GetStatus checks the provider, model and tools of an agent and combines
+// them with its recent chat history. It returns nil if the agent does not exist.
+func (s *AgentStatusService) GetStatus(ctx context.Context, agentID string) (*AgentStatus, error) {
+	agent, err := s.repo.Agent().GetByID(ctx, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent: %w", err)
+	}
+	if agent == nil {
+		return nil, nil
+	}
+
+	status := &AgentStatus{
+		AgentID:     agent.ID,
+		Enabled:     agent.Enabled,
+		Provider:    ProviderStatus{Name: agent.Provider},
+		Model:       ModelStatus{Name: agent.Model},
+		Tools:       []AgentToolStatus{},
+		ErrorWindow: s.errorWindow.String(),
+		CheckedAt:   time.Now(),
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, statusCheckTimeout)
+	defer cancel()
+
+	s.checkProvider(checkCtx, agent, status)
+	s.checkTools(checkCtx, agent, status)
+
+	if s.chatService != nil {
+		status.RecentChats = s.chatService.AgentChatStats(agent.ID, s.errorWindow)
+		status.LastSuccessfulChatAt = status.RecentChats.LastSuccessAt
+	}
+
+	// In-memory stats are empty after a restart; fall back to stored replies
+	if status.LastSuccessfulChatAt == nil {
+		message, err := s.repo.Message().LastAssistantByAgent(ctx, agent.ID)
+		if err != nil {
+			s.logger.Warn("Failed to look up last assistant message", "agent_id", agent.ID, "error", err)
+		} else if message != nil {
+			status.LastSuccessfulChatAt = &message.CreatedAt
+		}
+	}
+
+	status.Status = s.summarize(agent, status)
+	return status, nil
+}
+
+// checkProvider fills in provider and model availability
+func (s *AgentStatusService) checkProvider(ctx context.Context, agent *models.Agent, status *AgentStatus) {
+	provider, exists := s.llmRegistry.Get(agent.Provider)
+	if !exists {
+		status.Model.Error = "provider not registered"
+		return
+	}
+	status.Provider.Registered = true
+	status.Provider.Available = provider.IsAvailable(ctx)
+
+	if !status.Provider.Available {
+		status.Model.Error = "provider not available"
+		return
+	}
+
+	names, err := provider.Models(ctx)
+	if err != nil {
+		status.Model.Error = err.Error()
+		return
+	}
+	found := modelListed(names, agent.Model)
+	status.Model.Available = &found
+}
+
+// checkTools reports availability for each tool the agent may use
+func (s *AgentStatusService) checkTools(ctx context.Context, agent *models.Agent, status *AgentStatus) {
+	if s.toolService == nil {
+		return
+	}
+	registry := s.toolService.GetRegistry()
+
+	names := agent.AllowedTools()
+	if len(names) == 0 {
+		names = registry.List()
+		sort.Strings(names)
+	}
+
+	for _, name := range names {
+		toolStatus := AgentToolStatus{Name: name}
+		if tool, exists := registry.Get(name); exists {
+			toolStatus.Registered = true
+			toolStatus.Available = tool.IsAvailable(ctx)
+		}
+		status.Tools = append(status.Tools, toolStatus)
+	}
+}
+
+// summarize derives the overall status and records the issues behind it
+func (s *AgentStatusService) summarize(agent *models.Agent, status *AgentStatus) string {
+	var unavailable, degraded []string
+
+	if !status.Provider.Registered {
+		unavailable = append(unavailable, fmt.Sprintf("provider %s is not registered", agent.Provider))
+	} else if !status.Provider.Available {
+		unavailable = append(unavailable, fmt.Sprintf("provider %s is not available", agent.Provider))
+	}
+
+	if status.Model.Available != nil && !*status.Model.Available {
+		unavailable = append(unavailable, fmt.Sprintf("model %s not found on provider", agent.Model))
+	} else if status.Model.Available == nil && status.Provider.Available {
+		degraded = append(degraded, "could not verify model: "+status.Model.Error)
+	}
+
+	for _, tool := range status.Tools {
+		switch {
+		case !tool.Registered:
+			degraded = append(degraded, fmt.Sprintf("tool %s is not registered", tool.Name))
+		case !tool.Available:
+			degraded = append(degraded, fmt.Sprintf("tool %s is not available", tool.Name))
+		}
+	}
+
+	if status.RecentChats.Requests > 0 && status.RecentChats.ErrorRate >= s.errorRateWarn {
+		degraded = append(degraded, fmt.Sprintf("%.0f%% of chats failed in the last %s",
+			status.RecentChats.ErrorRate*100, s.errorWindow))
+	}
+
+	status.Issues = append(unavailable, degraded...)
+
+	switch {
+	case !agent.Enabled:
+		return AgentStatusDisabled
+	case len(unavailable) > 0:
+		return AgentStatusUnavailable
+	case len(degraded) > 0:
+		return AgentStatusDegraded
+	default:
+		return AgentStatusHealthy
+	}
+}
+
+// modelListed reports whether model is in names, treating an untagged name
+// as the provider's ":latest" tag
+func modelListed(names []string, model string) bool {
+	for _, name := range names {
+		if name == model {
+			return true
+		}
+		if !strings.Contains(model, ":") && name == model+":latest" {
+			return true
+		}
+	}
+	return false
+}
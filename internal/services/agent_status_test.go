@@ -0,0 +1,173 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusProvider is a minimal LLM provider with a fixed availability and model list
+type statusProvider struct {
+	available bool
+	models    []string
+	modelsErr error
+}
+
+func (p *statusProvider) Name() string { return "ollama" }
+
+func (p *statusProvider) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	return &llm.ChatResponse{Content: "ok", Model: req.Model}, nil
+}
+
+func (p *statusProvider) Stream(ctx context.Context, req *llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *statusProvider) Models(ctx context.Context) ([]string, error) {
+	return p.models, p.modelsErr
+}
+
+func (p *statusProvider) ValidateConfig(config map[string]interface{}) error { return nil }
+
+func (p *statusProvider) IsAvailable(ctx context.Context) bool { return p.available }
+
+func TestAgentStatusService_GetStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		provider       *statusProvider
+		config         models.JSON
+		enabled        bool
+		expectedStatus string
+		modelAvailable *bool
+	}{
+		{
+			name:           "healthy",
+			provider:       &statusProvider{available: true, models: []string{"llama3:latest"}},
+			config:         models.JSON{"tools": []interface{}{"http_get"}},
+			enabled:        true,
+			expectedStatus: services.AgentStatusHealthy,
+			modelAvailable: boolPtr(true),
+		},
+		{
+			name:           "model missing",
+			provider:       &statusProvider{available: true, models: []string{"mistral:latest"}},
+			enabled:        true,
+			expectedStatus: services.AgentStatusUnavailable,
+			modelAvailable: boolPtr(false),
+		},
+		{
+			name:           "provider down",
+			provider:       &statusProvider{available: false},
+			enabled:        true,
+			expectedStatus: services.AgentStatusUnavailable,
+		},
+		{
+			name:           "unknown tool in allowlist",
+			provider:       &statusProvider{available: true, models: []string{"llama3"}},
+			config:         models.JSON{"tools": []interface{}{"http_get", "teleport"}},
+			enabled:        true,
+			expectedStatus: services.AgentStatusDegraded,
+			modelAvailable: boolPtr(true),
+		},
+		{
+			name:           "disabled",
+			provider:       &statusProvider{available: true, models: []string{"llama3"}},
+			enabled:        false,
+			expectedStatus: services.AgentStatusDisabled,
+			modelAvailable: boolPtr(true),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, err := sqlite.NewRepository(":memory:")
+			require.NoError(t, err)
+			defer repo.Close()
+			ctx := context.Background()
+
+			agent := &models.Agent{Name: "status", Provider: "ollama", Model: "llama3", Enabled: tt.enabled, Config: tt.config}
+			require.NoError(t, repo.Agent().Create(ctx, agent))
+
+			registry := llm.NewRegistry()
+			registry.Register(tt.provider)
+			toolService := services.NewToolService(repo, slog.Default())
+			service := services.NewAgentStatusService(repo, registry, toolService, nil, slog.Default())
+
+			status, err := service.GetStatus(ctx, agent.ID)
+			require.NoError(t, err)
+			require.NotNil(t, status)
+
+			assert.Equal(t, tt.expectedStatus, status.Status, status.Issues)
+			assert.Equal(t, tt.modelAvailable, status.Model.Available)
+			if tt.config != nil {
+				assert.Len(t, status.Tools, len(agent.AllowedTools()))
+			}
+		})
+	}
+}
+
+func TestAgentStatusService_UnknownAgent(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+
+	service := services.NewAgentStatusService(repo, llm.NewRegistry(), nil, nil, slog.Default())
+	status, err := service.GetStatus(context.Background(), "missing")
+	require.NoError(t, err)
+	assert.Nil(t, status)
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// toolStatusProvider is a statusProvider with function calling
+type toolStatusProvider struct {
+	statusProvider
+}
+
+func (p *toolStatusProvider) SupportsTools() bool { return true }
+
+func TestAgentStatusService_ValidateTools(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	toolService := services.NewToolService(repo, slog.Default())
+	validate := func(provider llm.Provider, agent *models.Agent) []models.ValidationError {
+		registry := llm.NewRegistry()
+		registry.Register(provider)
+		return services.NewAgentStatusService(repo, registry, toolService, nil, slog.Default()).ValidateTools(ctx, agent)
+	}
+	agent := func(provider string, tools ...interface{}) *models.Agent {
+		return &models.Agent{Provider: provider, Model: "llama3", Config: models.JSON{"tools": tools}}
+	}
+	callingProvider := &toolStatusProvider{statusProvider{available: true}}
+
+	assert.Empty(t, validate(callingProvider, agent("ollama", "http_get", "calculator")))
+	assert.Empty(t, validate(&statusProvider{}, &models.Agent{Provider: "ollama", Model: "llama3"}), "agents without tools pass")
+
+	errs := validate(callingProvider, agent("ollama", "http_get", "teleport"))
+	require.Len(t, errs, 1)
+	assert.Equal(t, "config.tools[1]", errs[0].Parameter)
+	assert.Contains(t, errs[0].Message, "unknown tool teleport")
+
+	errs = validate(&statusProvider{available: true}, agent("ollama", "http_get"))
+	require.Len(t, errs, 1)
+	assert.Equal(t, "provider", errs[0].Parameter)
+	assert.Contains(t, errs[0].Message, "does not support function calling")
+
+	errs = validate(callingProvider, agent("openai", "http_get"))
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Message, "provider openai is not configured")
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+)
+
+// ValidateTools checks the tools an agent enables in its "tools" config, so a
+// misconfigured agent is rejected when it is saved rather than failing at chat
+// time. Each tool must be registered and available, and the agent's provider
+// must support function calling. An agent without a tool list passes.
+func (s *AgentStatusService) ValidateTools(ctx context.Context, agent *models.Agent) []models.ValidationError {
+	names := agent.AllowedTools()
+	if len(names) == 0 || s.toolService == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, statusCheckTimeout)
+	defer cancel()
+
+	var errs []models.ValidationError
+	provider, exists := s.llmRegistry.Get(agent.Provider)
+	switch {
+	case !exists:
+		errs = append(errs, models.ValidationError{
+			Parameter: "provider",
+			Message:   fmt.Sprintf("provider %s is not configured on this server; enable it or remove the tools", agent.Provider),
+			Value:     agent.Provider,
+		})
+	case !llm.SupportsTools(provider):
+		errs = append(errs, models.ValidationError{
+			Parameter: "provider",
+			Message:   fmt.Sprintf("provider %s does not support function calling; choose another provider or remove the tools", agent.Provider),
+			Value:     agent.Provider,
+		})
+	}
+
+	registry := s.toolService.GetRegistry()
+	for i, name := range names {
+		parameter := fmt.Sprintf("config.tools[%d]", i)
+		tool, exists := registry.Get(name)
+		if !exists {
+			errs = append(errs, models.ValidationError{
+				Parameter: parameter,
+				Message:   fmt.Sprintf("unknown tool %s; GET /api/v1/tools lists the available tools", name),
+				Value:     name,
+			})
+			continue
+		}
+		if !tool.IsAvailable(ctx) {
+			errs = append(errs, models.ValidationError{
+				Parameter: parameter,
+				Message:   fmt.Sprintf("tool %s is not available on this server; check its configuration", name),
+				Value:     name,
+			})
+		}
+	}
+	return errs
+}
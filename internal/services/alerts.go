@@ -0,0 +1,476 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"agent-server/internal/jobs"
+	"agent-server/internal/llm"
+	"agent-server/internal/mail"
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+)
+
+// Alert rule types
+const (
+	AlertErrorRate           = "error_rate"
+	AlertProviderUnavailable = "provider_unavailable"
+	AlertDailyTokens         = "daily_tokens"
+	AlertDailyCost           = "daily_cost"
+	AlertToolFailures        = "tool_failures"
+)
+
+// Alert states
+const (
+	AlertOK     = "ok"
+	AlertFiring = "firing"
+)
+
+// defaultAlertWindow is the history of rate rules without a window
+const defaultAlertWindow = 5 * time.Minute
+
+// providerCheckTimeout bounds each provider availability check
+const providerCheckTimeout = 10 * time.Second
+
+// AlertRule is a condition that fires an alert when its value exceeds the
+// threshold
+type AlertRule struct {
+	Name      string
+	Type      string
+	Threshold float64       // rate between 0 and 1, minutes, tokens or cost
+	Window    time.Duration // history of error_rate and tool_failures
+	MinEvents int           // fewer chats or tool calls in the window never fire
+	Provider  string        // provider_unavailable: one provider instead of those agents use
+	Tool      string        // tool_failures: one tool instead of each
+	AgentID   string        // daily_tokens and daily_cost: one agent instead of all
+}
+
+// Alert is the state of a rule for one subject, such as a provider or a
+// tool
+type Alert struct {
+	Rule       string     `json:"rule"`
+	Type       string     `json:"type"`
+	Subject    string     `json:"subject,omitempty"` // the provider or tool the alert is about
+	State      string     `json:"state"`
+	Value      float64    `json:"value"`
+	Threshold  float64    `json:"threshold"`
+	Message    string     `json:"message"`
+	Since      time.Time  `json:"since"` // when the state last changed
+	CheckedAt  time.Time  `json:"checked_at"`
+	NotifiedAt *time.Time `json:"notified_at,omitempty"`
+	NotifyErr  string     `json:"notify_error,omitempty"` // why the last notification failed
+}
+
+// AlertNotifier delivers an alert that fired or resolved
+type AlertNotifier func(ctx context.Context, alert Alert) error
+
+// alertReading is a rule's value for one subject
+type alertReading struct {
+	subject string
+	value   float64
+	events  int64 // chats or tool calls behind a rate
+	down    bool  // the provider is unavailable
+	message string
+}
+
+// AlertMonitor checks alert rules in the background against the stored
+// chats, tool calls and spending and the availability of providers, and
+// notifies when an alert fires or resolves
+type AlertMonitor struct {
+	repo      storage.Repository
+	providers *llm.Registry
+	rules     []AlertRule
+	interval  time.Duration
+	notifiers []AlertNotifier
+	leader    func() bool
+	logger    *slog.Logger
+
+	mu       sync.Mutex
+	alerts   map[string]*Alert    // by rule and subject
+	down     map[string]time.Time // when providers were first found unavailable
+	lastScan time.Time
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewAlertMonitor creates a monitor checking rules every interval
+func NewAlertMonitor(repo storage.Repository, providers *llm.Registry, rules []AlertRule, interval time.Duration, logger *slog.Logger) *AlertMonitor {
+	return &AlertMonitor{
+		repo:      repo,
+		providers: providers,
+		rules:     rules,
+		interval:  interval,
+		logger:    logger,
+		alerts:    make(map[string]*Alert),
+		down:      make(map[string]time.Time),
+	}
+}
+
+// AddNotifier delivers alerts that fire or resolve through notifier
+func (m *AlertMonitor) AddNotifier(notifier AlertNotifier) {
+	m.notifiers = append(m.notifiers, notifier)
+}
+
+// SetLeader leaves notifying to the instance for which leader reports true,
+// so that instances sharing the database do not notify several times.
+// Other instances still check the rules and report their state.
+func (m *AlertMonitor) SetLeader(leader func() bool) {
+	m.leader = leader
+}
+
+// Start checks the rules at once and then every interval until Stop
+func (m *AlertMonitor) Start(ctx context.Context) {
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			m.Check(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	m.logger.Info("Alert monitor started", "rules", len(m.rules), "interval", m.interval)
+}
+
+// Stop stops the checks Start started
+func (m *AlertMonitor) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+		m.wg.Wait()
+		m.cancel = nil
+	}
+}
+
+// Check evaluates every rule once and notifies about alerts that changed
+// state. A rule that cannot be evaluated keeps its alerts as they were.
+func (m *AlertMonitor) Check(ctx context.Context) {
+	now := time.Now()
+	available := make(map[string]bool)
+	var changed []Alert
+	for _, rule := range m.rules {
+		readings, err := m.evaluate(ctx, rule, now, available)
+		if err != nil {
+			m.logger.Error("Failed to evaluate alert rule", "rule", rule.Name, "error", err)
+			continue
+		}
+		changed = append(changed, m.update(rule, readings, now)...)
+	}
+
+	m.mu.Lock()
+	m.lastScan = now
+	m.mu.Unlock()
+
+	if m.leader != nil && !m.leader() {
+		return
+	}
+	for _, alert := range changed {
+		m.notify(ctx, alert)
+	}
+}
+
+// Alerts returns the state of every alert, firing ones first
+func (m *AlertMonitor) Alerts() []Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	alerts := make([]Alert, 0, len(m.alerts))
+	for _, alert := range m.alerts {
+		alerts = append(alerts, *alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].State != alerts[j].State {
+			return alerts[i].State == AlertFiring
+		}
+		if alerts[i].Rule != alerts[j].Rule {
+			return alerts[i].Rule < alerts[j].Rule
+		}
+		return alerts[i].Subject < alerts[j].Subject
+	})
+	return alerts
+}
+
+// Rules returns the rules the monitor checks
+func (m *AlertMonitor) Rules() []AlertRule {
+	return m.rules
+}
+
+// LastCheck returns when the rules were last checked, zero before the first
+// check
+func (m *AlertMonitor) LastCheck() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastScan
+}
+
+// evaluate reads the rule's current values. Provider availability is
+// checked once per check and shared by the rules through available.
+func (m *AlertMonitor) evaluate(ctx context.Context, rule AlertRule, now time.Time, available map[string]bool) ([]alertReading, error) {
+	window := rule.Window
+	if window <= 0 {
+		window = defaultAlertWindow
+	}
+	stats := m.repo.Stats()
+
+	switch rule.Type {
+	case AlertErrorRate:
+		days, err := stats.ChatsPerDay(ctx, now.Add(-window))
+		if err != nil {
+			return nil, fmt.Errorf("failed to count chats: %w", err)
+		}
+		var chats, errors int64
+		for _, day := range days {
+			chats += day.Chats
+			errors += day.Errors
+		}
+		reading := alertReading{events: chats}
+		if chats > 0 {
+			reading.value = float64(errors) / float64(chats)
+		}
+		reading.message = fmt.Sprintf("%d of %d chats failed in the last %s", errors, chats, window)
+		return []alertReading{reading}, nil
+
+	case AlertToolFailures:
+		tools, err := stats.ToolUsage(ctx, now.Add(-window))
+		if err != nil {
+			return nil, fmt.Errorf("failed to aggregate tool usage: %w", err)
+		}
+		var readings []alertReading
+		for _, tool := range tools {
+			if rule.Tool != "" && tool.ToolName != rule.Tool {
+				continue
+			}
+			readings = append(readings, alertReading{
+				subject: tool.ToolName,
+				value:   float64(tool.FailedCalls) / float64(tool.TotalCalls),
+				events:  tool.TotalCalls,
+				message: fmt.Sprintf("%d of %d calls of %s failed in the last %s", tool.FailedCalls, tool.TotalCalls, tool.ToolName, window),
+			})
+		}
+		return readings, nil
+
+	case AlertDailyTokens, AlertDailyCost:
+		costs, err := stats.Costs(ctx, models.CostFilter{AgentID: rule.AgentID, Since: startOfDay(now)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to sum costs: %w", err)
+		}
+		reading := alertReading{subject: rule.AgentID}
+		if rule.Type == AlertDailyTokens {
+			reading.value = float64(costs.PromptTokens + costs.CompletionTokens)
+			reading.message = fmt.Sprintf("%.0f tokens used today", reading.value)
+		} else {
+			reading.value = costs.TotalCost
+			reading.message = fmt.Sprintf("%.4f spent today", reading.value)
+		}
+		return []alertReading{reading}, nil
+
+	case AlertProviderUnavailable:
+		names, err := m.watchedProviders(ctx, rule)
+		if err != nil {
+			return nil, err
+		}
+		readings := make([]alertReading, 0, len(names))
+		for _, name := range names {
+			up, checked := available[name]
+			if !checked {
+				up = m.providerAvailable(ctx, name)
+				available[name] = up
+			}
+			reading := alertReading{subject: name, down: !up, message: fmt.Sprintf("provider %s is available", name)}
+			m.mu.Lock()
+			if up {
+				delete(m.down, name)
+			} else {
+				if _, ok := m.down[name]; !ok {
+					m.down[name] = now
+				}
+				reading.value = now.Sub(m.down[name]).Minutes()
+				reading.message = fmt.Sprintf("provider %s has been unavailable since %s", name, m.down[name].UTC().Format(time.RFC3339))
+			}
+			m.mu.Unlock()
+			readings = append(readings, reading)
+		}
+		return readings, nil
+	}
+	return nil, fmt.Errorf("unknown alert rule type %q", rule.Type)
+}
+
+// watchedProviders returns the rule's provider, or the providers enabled
+// agents use
+func (m *AlertMonitor) watchedProviders(ctx context.Context, rule AlertRule) ([]string, error) {
+	if rule.Provider != "" {
+		return []string{rule.Provider}, nil
+	}
+	agents, _, err := m.repo.Agent().List(ctx, &models.AgentFilter{Limit: -1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, agent := range agents {
+		if agent.Enabled && !seen[agent.Provider] {
+			seen[agent.Provider] = true
+			names = append(names, agent.Provider)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// providerAvailable reports whether the provider is registered and answers
+func (m *AlertMonitor) providerAvailable(ctx context.Context, name string) bool {
+	provider, ok := m.providers.Get(name)
+	if !ok {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, providerCheckTimeout)
+	defer cancel()
+	return provider.IsAvailable(ctx)
+}
+
+// update records the readings of a rule and returns the alerts that fired
+// or resolved. Alerts of subjects without a reading, such as a tool that
+// was not called in the window, resolve.
+func (m *AlertMonitor) update(rule AlertRule, readings []alertReading, now time.Time) []Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var changed []Alert
+	seen := make(map[string]bool, len(readings))
+	for _, reading := range readings {
+		key := rule.Name + "\x00" + reading.subject
+		seen[key] = true
+
+		state := AlertOK
+		if exceeds(rule, reading) {
+			state = AlertFiring
+		}
+		alert, exists := m.alerts[key]
+		if !exists {
+			alert = &Alert{Rule: rule.Name, Type: rule.Type, Subject: reading.subject, State: AlertOK, Since: now}
+			m.alerts[key] = alert
+		}
+		alert.Value = reading.value
+		alert.Threshold = rule.Threshold
+		alert.Message = reading.message
+		alert.CheckedAt = now
+		if alert.State != state {
+			alert.State = state
+			alert.Since = now
+			changed = append(changed, *alert)
+		}
+	}
+
+	for key, alert := range m.alerts {
+		if alert.Rule != rule.Name || seen[key] {
+			continue
+		}
+		if alert.State == AlertFiring {
+			alert.State = AlertOK
+			alert.Since = now
+			alert.Value = 0
+			alert.Message = "no recent activity"
+			alert.CheckedAt = now
+			changed = append(changed, *alert)
+		} else {
+			delete(m.alerts, key)
+		}
+	}
+	return changed
+}
+
+// exceeds reports whether a reading fires its rule. Rates need at least
+// MinEvents chats or tool calls; unavailable providers fire once they have
+// been down for the threshold in minutes.
+func exceeds(rule AlertRule, reading alertReading) bool {
+	switch rule.Type {
+	case AlertErrorRate, AlertToolFailures:
+		return reading.events > 0 && reading.events >= int64(rule.MinEvents) && reading.value > rule.Threshold
+	case AlertProviderUnavailable:
+		return reading.down && reading.value >= rule.Threshold
+	}
+	return reading.value > rule.Threshold
+}
+
+// notify delivers an alert through every notifier and records the outcome
+func (m *AlertMonitor) notify(ctx context.Context, alert Alert) {
+	var failures []string
+	for _, notifier := range m.notifiers {
+		if err := notifier(ctx, alert); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		m.logger.Error("Failed to deliver alert", "rule", alert.Rule, "subject", alert.Subject, "state", alert.State,
+			"error", strings.Join(failures, "; "))
+	} else {
+		m.logger.Warn("Alert "+alert.State, "rule", alert.Rule, "subject", alert.Subject, "value", alert.Value,
+			"threshold", alert.Threshold)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if current, ok := m.alerts[alert.Rule+"\x00"+alert.Subject]; ok {
+		now := time.Now()
+		current.NotifiedAt = &now
+		current.NotifyErr = strings.Join(failures, "; ")
+	}
+}
+
+// alertEvent names the notification of an alert: alert.firing or
+// alert.resolved
+func alertEvent(alert Alert) string {
+	if alert.State == AlertFiring {
+		return "alert.firing"
+	}
+	return "alert.resolved"
+}
+
+// WebhookAlertNotifier delivers alerts to url as webhook jobs, which the job
+// runner retries until the endpoint accepts them
+func WebhookAlertNotifier(runner *jobs.Runner, url, secret string) AlertNotifier {
+	return func(ctx context.Context, alert Alert) error {
+		body := map[string]interface{}{
+			"event": alertEvent(alert),
+			"alert": alert,
+		}
+		_, err := runner.Enqueue(ctx, jobs.JobTypeWebhook, jobs.WebhookPayload(url, body, secret))
+		return err
+	}
+}
+
+// AlertMailer sends alert emails
+type AlertMailer interface {
+	Send(ctx context.Context, msg *mail.Message) error
+}
+
+// EmailAlertNotifier emails alerts to the recipients
+func EmailAlertNotifier(sender AlertMailer, to []string) AlertNotifier {
+	return func(ctx context.Context, alert Alert) error {
+		subject := alert.Rule
+		if alert.Subject != "" {
+			subject += " (" + alert.Subject + ")"
+		}
+		status := "FIRING"
+		if alert.State != AlertFiring {
+			status = "RESOLVED"
+		}
+		return sender.Send(ctx, &mail.Message{
+			To:      to,
+			Subject: fmt.Sprintf("[%s] %s", status, subject),
+			Body: fmt.Sprintf("%s\n\nRule: %s (%s)\nValue: %g\nThreshold: %g\nSince: %s\n",
+				alert.Message, alert.Rule, alert.Type, alert.Value, alert.Threshold, alert.Since.UTC().Format(time.RFC3339)),
+			Headers: map[string]string{"Auto-Submitted": "auto-generated"},
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertMonitor_Check(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "watched", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{}}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	// Two of four chats failed, and one of two calls of a tool
+	var userMessages []*models.Message
+	for _, status := range []string{"", models.MessageStatusIncomplete, "", models.MessageStatusIncomplete} {
+		message := &models.Message{SessionID: session.ID, Role: "user", Content: "hi", Status: status}
+		require.NoError(t, repo.Message().Create(ctx, message))
+		userMessages = append(userMessages, message)
+	}
+	require.NoError(t, repo.ToolCall().Create(ctx, &models.ToolCall{MessageID: userMessages[0].ID, ToolName: "http_request", Success: true}))
+	require.NoError(t, repo.ToolCall().Create(ctx, &models.ToolCall{MessageID: userMessages[1].ID, ToolName: "http_request"}))
+
+	provider := &statusProvider{available: false}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+
+	monitor := services.NewAlertMonitor(repo, registry, []services.AlertRule{
+		{Name: "errors", Type: services.AlertErrorRate, Threshold: 0.25, Window: time.Hour, MinEvents: 4},
+		{Name: "quiet-errors", Type: services.AlertErrorRate, Threshold: 0.25, Window: time.Hour, MinEvents: 10},
+		{Name: "tools", Type: services.AlertToolFailures, Threshold: 0.4, Window: time.Hour},
+		{Name: "provider", Type: services.AlertProviderUnavailable},
+		{Name: "spend", Type: services.AlertDailyTokens, Threshold: 1000},
+	}, time.Minute, slog.Default())
+	var notified []services.Alert
+	monitor.AddNotifier(func(ctx context.Context, alert services.Alert) error {
+		notified = append(notified, alert)
+		return nil
+	})
+
+	monitor.Check(ctx)
+
+	states := func() map[string]string {
+		states := make(map[string]string)
+		for _, alert := range monitor.Alerts() {
+			states[alert.Rule+"/"+alert.Subject] = alert.State
+		}
+		return states
+	}
+	assert.Equal(t, map[string]string{
+		"errors/":            services.AlertFiring,
+		"quiet-errors/":      services.AlertOK,
+		"tools/http_request": services.AlertFiring,
+		"provider/ollama":    services.AlertFiring,
+		"spend/":             services.AlertOK,
+	}, states())
+	require.Len(t, notified, 3)
+	for _, alert := range notified {
+		assert.Equal(t, services.AlertFiring, alert.State)
+	}
+	assert.Equal(t, services.AlertFiring, monitor.Alerts()[0].State)
+	assert.False(t, monitor.LastCheck().IsZero())
+
+	// Alerts are notified once, and again when they resolve
+	notified = nil
+	monitor.Check(ctx)
+	assert.Empty(t, notified)
+
+	provider.available = true
+	monitor.Check(ctx)
+	require.Len(t, notified, 1)
+	assert.Equal(t, "provider", notified[0].Rule)
+	assert.Equal(t, services.AlertOK, notified[0].State)
+	assert.Equal(t, services.AlertOK, states()["provider/ollama"])
+
+	// Instances that do not lead check the rules without notifying
+	notified = nil
+	provider.available = false
+	monitor.SetLeader(func() bool { return false })
+	monitor.Check(ctx)
+	assert.Empty(t, notified)
+	assert.Equal(t, services.AlertFiring, states()["provider/ollama"])
+}
@@ -0,0 +1,511 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"time"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+	"agent-server/internal/storage/blob"
+
+	"github.com/google/uuid"
+)
+
+// Archive errors
+var (
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrSessionArchived    = errors.New("session is archived")
+	ErrSessionNotArchived = errors.New("session is not archived")
+	ErrNothingToCompact   = errors.New("not enough messages to compact")
+	ErrArchiveNotFound    = errors.New("archive not found")
+)
+
+// DefaultCompactKeepRecent is how many recent messages compaction leaves
+// alone unless asked otherwise
+const DefaultCompactKeepRecent = 20
+
+// minCompactMessages is the shortest stretch worth replacing by a summary
+const minCompactMessages = 2
+
+// Summarizer writes the summary that replaces a session's compacted
+// messages. The metadata it returns, such as usage, is stored with the
+// summary.
+type Summarizer func(ctx context.Context, session *models.ChatSession, messages []*models.Message) (string, map[string]interface{}, error)
+
+// JobTypeArchiveSession archives a single session in the background
+const JobTypeArchiveSession = "archive_session"
+
+// JobTypeCompactSession summarizes a session's older messages in the
+// background
+const JobTypeCompactSession = "compact_session"
+
+// ArchiveService moves session messages between the hot messages table and
+// compressed archive rows
+type ArchiveService struct {
+	repo          storage.Repository
+	blobStore     blob.Store
+	blobThreshold int
+	summarize     Summarizer
+	logger        *slog.Logger
+}
+
+// NewArchiveService creates a new archive service
+func NewArchiveService(repo storage.Repository, logger *slog.Logger) *ArchiveService {
+	return &ArchiveService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// SetBlobStore stores archives whose compressed size is at least threshold
+// bytes in blob storage instead of the database
+func (s *ArchiveService) SetBlobStore(store blob.Store, threshold int) {
+	s.blobStore = store
+	s.blobThreshold = threshold
+}
+
+// SetSummarizer enables compaction, with summarize writing the summaries
+func (s *ArchiveService) SetSummarizer(summarize Summarizer) {
+	s.summarize = summarize
+}
+
+// Archive compresses all messages of a session into an archive row, removes
+// them from the messages table and marks the session archived
+func (s *ArchiveService) Archive(ctx context.Context, sessionID string) (*models.MessageArchive, error) {
+	session, err := s.repo.Session().GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
+	if session.IsArchived() {
+		return nil, ErrSessionArchived
+	}
+
+	now := time.Now()
+	session.Status = models.SessionStatusArchived
+	session.ArchivedAt = &now
+
+	// The messages are read and deleted in one transaction, under the writer
+	// lock, so none written in between stays behind in the archived session
+	var archive *models.MessageArchive
+	var messages []*models.Message
+	var toolCalls []*models.ToolCall
+	err = s.repo.WithTx(ctx, func(tx storage.Repository) error {
+		var err error
+		messages, _, err = tx.Message().ListBySessionID(ctx, sessionID, -1, -1)
+		if err != nil {
+			return fmt.Errorf("failed to list messages: %w", err)
+		}
+		toolCalls, err = tx.ToolCall().ListBySessionID(ctx, sessionID)
+		if err != nil {
+			return fmt.Errorf("failed to list tool calls: %w", err)
+		}
+
+		if gaps := models.SequenceGaps(messages); len(gaps) > 0 {
+			s.logger.Warn("Archiving session with missing messages",
+				"session_id", sessionID,
+				"gaps", gaps)
+		}
+
+		if len(messages) > 0 {
+			archive, err = s.buildArchive(ctx, sessionID, messages, toolCalls)
+			if err != nil {
+				return err
+			}
+			if err := tx.Archive().Create(ctx, archive); err != nil {
+				return fmt.Errorf("failed to save archive: %w", err)
+			}
+			if err := tx.Message().DeleteThrough(ctx, sessionID, archive.LastSequence); err != nil {
+				return fmt.Errorf("failed to delete messages: %w", err)
+			}
+		}
+		return tx.Session().Update(ctx, session)
+	})
+	if err != nil {
+		// Don't leave an orphaned blob behind
+		if archive != nil && archive.BlobKey != "" {
+			_ = s.blobStore.Delete(context.WithoutCancel(ctx), archive.BlobKey)
+		}
+		return nil, err
+	}
+
+	s.logger.Info("Session archived",
+		"session_id", sessionID,
+		"messages", len(messages),
+		"tool_calls", len(toolCalls))
+
+	return archive, nil
+}
+
+// UnarchiveResult describes a completed restore
+type UnarchiveResult struct {
+	Restored int
+	// MissingSequences lists messages absent from the archives, e.g. deleted
+	// before archiving or lost with a damaged archive
+	MissingSequences []models.SequenceGap
+}
+
+// Unarchive restores archived messages into the messages table and marks the
+// session active again
+func (s *ArchiveService) Unarchive(ctx context.Context, sessionID string) (*UnarchiveResult, error) {
+	session, err := s.repo.Session().GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
+	if !session.IsArchived() {
+		return nil, ErrSessionNotArchived
+	}
+
+	stored, err := s.repo.Archive().ListBySessionID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archives: %w", err)
+	}
+
+	// Originals of compactions stay archived behind their summaries
+	var archives []*models.MessageArchive
+	for _, archive := range stored {
+		if !archive.Compacted {
+			archives = append(archives, archive)
+		}
+	}
+
+	payloads := make([]*models.ArchivePayload, 0, len(archives))
+	var messages []*models.Message
+	for _, archive := range archives {
+		payload, err := s.readArchive(ctx, archive)
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, payload)
+		messages = append(messages, payload.Messages...)
+	}
+
+	// Restoring keeps the original sequence numbers, so they must be in order
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].Sequence < messages[j].Sequence })
+	result := &UnarchiveResult{
+		MissingSequences: models.SequenceGaps(messages),
+	}
+	if len(result.MissingSequences) > 0 {
+		s.logger.Warn("Archived session has missing messages",
+			"session_id", sessionID,
+			"gaps", result.MissingSequences)
+	}
+
+	session.Status = models.SessionStatusActive
+	session.ArchivedAt = nil
+
+	err = s.repo.WithTx(ctx, func(tx storage.Repository) error {
+		// Messages already in the table, e.g. from an interrupted restore,
+		// are kept as they are
+		present, _, err := tx.Message().ListBySessionID(ctx, sessionID, -1, -1)
+		if err != nil {
+			return fmt.Errorf("failed to list messages: %w", err)
+		}
+		presentIDs := make(map[string]bool, len(present))
+		for _, message := range present {
+			presentIDs[message.ID] = true
+		}
+		restored := make(map[string]bool, len(messages))
+		for _, message := range messages {
+			if presentIDs[message.ID] {
+				continue
+			}
+			if err := tx.Message().Restore(ctx, message); err != nil {
+				return fmt.Errorf("failed to restore message: %w", err)
+			}
+			restored[message.ID] = true
+		}
+		result.Restored = len(restored)
+		for _, payload := range payloads {
+			for _, toolCall := range payload.ToolCalls {
+				if !restored[toolCall.MessageID] {
+					continue
+				}
+				if err := tx.ToolCall().Create(ctx, toolCall); err != nil {
+					return fmt.Errorf("failed to restore tool call: %w", err)
+				}
+			}
+		}
+		for _, archive := range archives {
+			if err := tx.Archive().Delete(ctx, archive.ID); err != nil {
+				return fmt.Errorf("failed to delete archives: %w", err)
+			}
+		}
+		return tx.Session().Update(ctx, session)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Blobs are only removed once the restore has committed
+	for _, archive := range archives {
+		if archive.BlobKey != "" && s.blobStore != nil {
+			if err := s.blobStore.Delete(ctx, archive.BlobKey); err != nil {
+				s.logger.Warn("Failed to delete archive blob", "key", archive.BlobKey, "error", err)
+			}
+		}
+	}
+
+	s.logger.Info("Session unarchived", "session_id", sessionID, "messages", result.Restored)
+
+	return result, nil
+}
+
+// CompactResult describes a completed compaction
+type CompactResult struct {
+	Summary *models.Message
+	Archive *models.MessageArchive
+}
+
+// Compact replaces a session's messages older than the keepRecent most
+// recent ones with a summary message. The replaced messages and their tool
+// calls are archived, and can be read back with CompactedMessages. The
+// recent messages start at a user message, so that no turn is split.
+func (s *ArchiveService) Compact(ctx context.Context, sessionID string, keepRecent int) (*CompactResult, error) {
+	if s.summarize == nil {
+		return nil, fmt.Errorf("compaction is not enabled")
+	}
+	session, err := s.repo.Session().GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
+	if session.IsArchived() {
+		return nil, ErrSessionArchived
+	}
+	if keepRecent <= 0 {
+		keepRecent = DefaultCompactKeepRecent
+	}
+
+	messages, _, err := s.repo.Message().ListBySessionID(ctx, sessionID, -1, -1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	cut := len(messages) - keepRecent
+	for cut > 0 && messages[cut].Role != "user" {
+		cut--
+	}
+	if cut < minCompactMessages {
+		return nil, ErrNothingToCompact
+	}
+	compacted := messages[:cut]
+	last := compacted[len(compacted)-1]
+
+	toolCalls, err := s.repo.ToolCall().ListBySessionID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tool calls: %w", err)
+	}
+	ids := make(map[string]bool, len(compacted))
+	for _, message := range compacted {
+		ids[message.ID] = true
+	}
+	var compactedCalls []*models.ToolCall
+	for _, toolCall := range toolCalls {
+		if ids[toolCall.MessageID] {
+			compactedCalls = append(compactedCalls, toolCall)
+		}
+	}
+
+	text, metadata, err := s.summarize(ctx, session, compacted)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := s.buildArchive(ctx, sessionID, compacted, compactedCalls)
+	if err != nil {
+		return nil, err
+	}
+	if archive.ID == "" {
+		archive.ID = uuid.New().String()
+	}
+	archive.Compacted = true
+
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+	metadata["compaction"] = map[string]interface{}{
+		"archive_id":     archive.ID,
+		"messages":       len(compacted),
+		"first_sequence": archive.FirstSequence,
+		"last_sequence":  archive.LastSequence,
+	}
+	summary := &models.Message{
+		Role:      "system",
+		Content:   "Summary of the earlier conversation: " + text,
+		Metadata:  models.JSON(metadata),
+		CreatedAt: last.CreatedAt,
+	}
+
+	err = s.repo.WithTx(ctx, func(tx storage.Repository) error {
+		if err := tx.Archive().Create(ctx, archive); err != nil {
+			return fmt.Errorf("failed to save archive: %w", err)
+		}
+		if err := tx.Message().Compact(ctx, sessionID, last.Sequence, summary); err != nil {
+			return fmt.Errorf("failed to replace messages: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		if archive.BlobKey != "" {
+			_ = s.blobStore.Delete(context.WithoutCancel(ctx), archive.BlobKey)
+		}
+		return nil, err
+	}
+
+	s.logger.Info("Session compacted",
+		"session_id", sessionID,
+		"messages", len(compacted),
+		"tool_calls", len(compactedCalls),
+		"archive_id", archive.ID)
+
+	return &CompactResult{Summary: summary, Archive: archive}, nil
+}
+
+// Compactions lists the archives of a session's compactions, oldest first
+func (s *ArchiveService) Compactions(ctx context.Context, sessionID string) ([]*models.MessageArchive, error) {
+	archives, err := s.repo.Archive().ListBySessionID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archives: %w", err)
+	}
+	compactions := make([]*models.MessageArchive, 0, len(archives))
+	for _, archive := range archives {
+		if archive.Compacted {
+			compactions = append(compactions, archive)
+		}
+	}
+	return compactions, nil
+}
+
+// CompactedMessages reads back the messages and tool calls a compaction of
+// the session replaced
+func (s *ArchiveService) CompactedMessages(ctx context.Context, sessionID, archiveID string) (*models.ArchivePayload, error) {
+	archive, err := s.repo.Archive().GetByID(ctx, archiveID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archive: %w", err)
+	}
+	if archive == nil || archive.SessionID != sessionID || !archive.Compacted {
+		return nil, ErrArchiveNotFound
+	}
+	return s.readArchive(ctx, archive)
+}
+
+// ListIdleSessions returns active sessions not updated within the given duration
+func (s *ArchiveService) ListIdleSessions(ctx context.Context, idle time.Duration, limit int) ([]*models.ChatSession, error) {
+	return s.repo.Session().ListIdle(ctx, time.Now().Add(-idle), limit)
+}
+
+// HandleArchiveJob is the job handler for JobTypeArchiveSession
+func (s *ArchiveService) HandleArchiveJob(ctx context.Context, job *models.Job) error {
+	sessionID, _ := job.Payload["session_id"].(string)
+	if sessionID == "" {
+		return fmt.Errorf("archive job has no session_id")
+	}
+
+	_, err := s.Archive(ctx, sessionID)
+	// Sessions deleted or archived in the meantime need no retry
+	if errors.Is(err, ErrSessionNotFound) || errors.Is(err, ErrSessionArchived) {
+		return nil
+	}
+	return err
+}
+
+// HandleCompactJob is the job handler for JobTypeCompactSession. The payload
+// holds the session_id and, optionally, keep_recent.
+func (s *ArchiveService) HandleCompactJob(ctx context.Context, job *models.Job) error {
+	sessionID, _ := job.Payload["session_id"].(string)
+	if sessionID == "" {
+		return fmt.Errorf("compact job has no session_id")
+	}
+	keepRecent, _ := job.Payload["keep_recent"].(float64)
+
+	_, err := s.Compact(ctx, sessionID, int(keepRecent))
+	// Sessions deleted, archived or compacted in the meantime need no retry
+	if errors.Is(err, ErrSessionNotFound) || errors.Is(err, ErrSessionArchived) || errors.Is(err, ErrNothingToCompact) {
+		return nil
+	}
+	return err
+}
+
+// buildArchive compresses messages into an archive, offloading large payloads
+func (s *ArchiveService) buildArchive(ctx context.Context, sessionID string, messages []*models.Message, toolCalls []*models.ToolCall) (*models.MessageArchive, error) {
+	raw, err := json.Marshal(models.ArchivePayload{Messages: messages, ToolCalls: toolCalls})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode archive: %w", err)
+	}
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(raw); err != nil {
+		return nil, fmt.Errorf("failed to compress archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress archive: %w", err)
+	}
+
+	archive := &models.MessageArchive{
+		SessionID:      sessionID,
+		MessageCount:   len(messages),
+		SizeBytes:      int64(compressed.Len()),
+		FirstSequence:  messages[0].Sequence,
+		LastSequence:   messages[len(messages)-1].Sequence,
+		FirstMessageAt: messages[0].CreatedAt,
+		LastMessageAt:  messages[len(messages)-1].CreatedAt,
+		CreatedAt:      time.Now(),
+	}
+
+	if s.blobStore != nil && compressed.Len() >= s.blobThreshold {
+		archive.ID = uuid.New().String()
+		archive.BlobKey = fmt.Sprintf("archives/%s/%s.json.gz", sessionID, archive.ID)
+		if err := s.blobStore.Put(ctx, archive.BlobKey, bytes.NewReader(compressed.Bytes()), int64(compressed.Len()), "application/gzip"); err != nil {
+			return nil, fmt.Errorf("failed to store archive blob: %w", err)
+		}
+	} else {
+		archive.Data = compressed.Bytes()
+	}
+
+	return archive, nil
+}
+
+// readArchive loads and decompresses an archive payload
+func (s *ArchiveService) readArchive(ctx context.Context, archive *models.MessageArchive) (*models.ArchivePayload, error) {
+	var compressed io.Reader = bytes.NewReader(archive.Data)
+	if archive.BlobKey != "" {
+		if s.blobStore == nil {
+			return nil, fmt.Errorf("archive %s is in blob storage but no blob store is configured", archive.ID)
+		}
+		reader, _, err := s.blobStore.Get(ctx, archive.BlobKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive blob: %w", err)
+		}
+		defer reader.Close()
+		compressed = reader
+	}
+
+	gz, err := gzip.NewReader(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress archive: %w", err)
+	}
+	defer gz.Close()
+
+	var payload models.ArchivePayload
+	if err := json.NewDecoder(gz).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode archive: %w", err)
+	}
+
+	return &payload, nil
+}
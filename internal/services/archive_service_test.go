@@ -0,0 +1,273 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage"
+	"agent-server/internal/storage/blob"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedArchiveSession(t *testing.T, repo storage.Repository) *models.ChatSession {
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "archivist", Provider: "ollama", Model: "llama3"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	for _, content := range []string{"first", "second", "third"} {
+		require.NoError(t, repo.Message().Create(ctx, &models.Message{SessionID: session.ID, Role: "user", Content: content}))
+	}
+	messages, _, err := repo.Message().ListBySessionID(ctx, session.ID, 10, 0)
+	require.NoError(t, err)
+	require.NoError(t, repo.ToolCall().Create(ctx, &models.ToolCall{MessageID: messages[0].ID, ToolName: "calculator"}))
+
+	return session
+}
+
+func TestArchiveService_RoundTrip(t *testing.T) {
+	store, err := blob.NewLocalStore(t.TempDir(), "", "secret")
+	require.NoError(t, err)
+
+	for name, configure := range map[string]func(*services.ArchiveService){
+		"inline": func(*services.ArchiveService) {},
+		"blob":   func(s *services.ArchiveService) { s.SetBlobStore(store, 0) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo, err := sqlite.NewRepository(":memory:")
+			require.NoError(t, err)
+			defer repo.Close()
+			ctx := context.Background()
+
+			session := seedArchiveSession(t, repo)
+			service := services.NewArchiveService(repo, slog.Default())
+			configure(service)
+
+			archive, err := service.Archive(ctx, session.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 3, archive.MessageCount)
+			assert.Equal(t, name == "blob", archive.BlobKey != "")
+
+			_, total, err := repo.Message().ListBySessionID(ctx, session.ID, 10, 0)
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), total)
+
+			archived, err := repo.Session().GetByID(ctx, session.ID)
+			require.NoError(t, err)
+			assert.True(t, archived.IsArchived())
+			assert.NotNil(t, archived.ArchivedAt)
+
+			_, err = service.Archive(ctx, session.ID)
+			assert.ErrorIs(t, err, services.ErrSessionArchived)
+
+			result, err := service.Unarchive(ctx, session.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 3, result.Restored)
+			assert.Empty(t, result.MissingSequences)
+
+			messages, _, err := repo.Message().ListBySessionID(ctx, session.ID, 10, 0)
+			require.NoError(t, err)
+			require.Len(t, messages, 3)
+			assert.Equal(t, "first", messages[0].Content)
+			assert.Equal(t, []int64{1, 2, 3}, []int64{messages[0].Sequence, messages[1].Sequence, messages[2].Sequence})
+
+			toolCalls, err := repo.ToolCall().ListByMessageID(ctx, messages[0].ID)
+			require.NoError(t, err)
+			assert.Len(t, toolCalls, 1)
+
+			active, err := repo.Session().GetByID(ctx, session.ID)
+			require.NoError(t, err)
+			assert.False(t, active.IsArchived())
+
+			archives, err := repo.Archive().ListBySessionID(ctx, session.ID)
+			require.NoError(t, err)
+			assert.Empty(t, archives)
+		})
+	}
+}
+
+func TestArchiveService_Errors(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	service := services.NewArchiveService(repo, slog.Default())
+
+	_, err = service.Archive(ctx, "missing")
+	assert.ErrorIs(t, err, services.ErrSessionNotFound)
+
+	session := seedArchiveSession(t, repo)
+	_, err = service.Unarchive(ctx, session.ID)
+	assert.ErrorIs(t, err, services.ErrSessionNotArchived)
+}
+
+func TestArchiveService_Compact(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "archivist", Provider: "ollama", Model: "llama3"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID}
+	require.NoError(t, repo.Session().Create(ctx, session))
+	for _, message := range []struct{ role, content string }{
+		{"user", "one"}, {"assistant", "1"}, {"user", "two"}, {"assistant", "2"}, {"user", "three"}, {"assistant", "3"},
+	} {
+		require.NoError(t, repo.Message().Create(ctx, &models.Message{SessionID: session.ID, Role: message.role, Content: message.content}))
+	}
+	messages, _, err := repo.Message().ListBySessionID(ctx, session.ID, -1, -1)
+	require.NoError(t, err)
+	require.NoError(t, repo.ToolCall().Create(ctx, &models.ToolCall{MessageID: messages[1].ID, ToolName: "calculator"}))
+
+	service := services.NewArchiveService(repo, slog.Default())
+	var summarized []*models.Message
+	service.SetSummarizer(func(ctx context.Context, session *models.ChatSession, messages []*models.Message) (string, map[string]interface{}, error) {
+		summarized = messages
+		return "counted to two", map[string]interface{}{"model": "llama3"}, nil
+	})
+
+	// Keeping three messages would split the last turn, so it keeps four
+	result, err := service.Compact(ctx, session.ID, 3)
+	require.NoError(t, err)
+	require.Len(t, summarized, 2)
+	assert.Equal(t, 2, result.Archive.MessageCount)
+	assert.True(t, result.Archive.Compacted)
+
+	hot, _, err := repo.Message().ListBySessionID(ctx, session.ID, -1, -1)
+	require.NoError(t, err)
+	require.Len(t, hot, 5)
+	assert.Equal(t, "system", hot[0].Role)
+	assert.Equal(t, "Summary of the earlier conversation: counted to two", hot[0].Content)
+	assert.EqualValues(t, 2, hot[0].Sequence)
+	assert.Equal(t, result.Archive.ID, hot[0].Metadata["compaction"].(map[string]interface{})["archive_id"])
+	assert.Equal(t, "two", hot[1].Content)
+	toolCalls, err := repo.ToolCall().ListBySessionID(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Empty(t, toolCalls)
+
+	// The originals stay retrievable
+	compactions, err := service.Compactions(ctx, session.ID)
+	require.NoError(t, err)
+	require.Len(t, compactions, 1)
+	payload, err := service.CompactedMessages(ctx, session.ID, compactions[0].ID)
+	require.NoError(t, err)
+	require.Len(t, payload.Messages, 2)
+	assert.Equal(t, "one", payload.Messages[0].Content)
+	assert.Len(t, payload.ToolCalls, 1)
+	_, err = service.CompactedMessages(ctx, "other", compactions[0].ID)
+	assert.ErrorIs(t, err, services.ErrArchiveNotFound)
+
+	// New messages follow the kept ones
+	require.NoError(t, repo.Message().Create(ctx, &models.Message{SessionID: session.ID, Role: "user", Content: "four"}))
+	hot, _, err = repo.Message().ListBySessionID(ctx, session.ID, -1, -1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 7, hot[len(hot)-1].Sequence)
+
+	// Unarchiving restores the session's archive, not the compacted messages
+	_, err = service.Archive(ctx, session.ID)
+	require.NoError(t, err)
+	restored, err := service.Unarchive(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 6, restored.Restored)
+	compactions, err = service.Compactions(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Len(t, compactions, 1)
+
+	_, err = service.Compact(ctx, session.ID, 20)
+	assert.ErrorIs(t, err, services.ErrNothingToCompact)
+
+	// Compact jobs read keep_recent as decoded from the outbox, and need no
+	// retry once there is nothing left to compact
+	job := models.NewJob(services.JobTypeCompactSession, map[string]interface{}{"session_id": session.ID, "keep_recent": float64(1)})
+	require.NoError(t, service.HandleCompactJob(ctx, job))
+	compactions, err = service.Compactions(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Len(t, compactions, 2)
+	assert.NoError(t, service.HandleCompactJob(ctx, job))
+	assert.Error(t, service.HandleCompactJob(ctx, models.NewJob(services.JobTypeCompactSession, map[string]interface{}{})))
+}
+
+// lateWriteRepository writes a message just before each transaction, as a
+// concurrent chat could while the session is being archived
+type lateWriteRepository struct {
+	storage.Repository
+	sessionID string
+}
+
+func (r *lateWriteRepository) WithTx(ctx context.Context, fn func(tx storage.Repository) error) error {
+	if err := r.Repository.Message().Create(ctx, &models.Message{SessionID: r.sessionID, Role: "user", Content: "late"}); err != nil {
+		return err
+	}
+	return r.Repository.WithTx(ctx, fn)
+}
+
+func TestArchiveService_ArchivesLateMessages(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	session := seedArchiveSession(t, repo)
+	service := services.NewArchiveService(&lateWriteRepository{Repository: repo, sessionID: session.ID}, slog.Default())
+
+	archive, err := service.Archive(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 4, archive.MessageCount)
+	assert.Equal(t, int64(4), archive.LastSequence)
+
+	// No message stays behind in the archived session
+	messages, _, err := repo.Message().ListBySessionID(ctx, session.ID, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+
+	result, err := services.NewArchiveService(repo, slog.Default()).Unarchive(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.Restored)
+	messages, _, err = repo.Message().ListBySessionID(ctx, session.ID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+	assert.Equal(t, "late", messages[3].Content)
+}
+
+func TestArchiveService_UnarchiveKeepsPresentMessages(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	session := seedArchiveSession(t, repo)
+	originals, _, err := repo.Message().ListBySessionID(ctx, session.ID, 10, 0)
+	require.NoError(t, err)
+	service := services.NewArchiveService(repo, slog.Default())
+	_, err = service.Archive(ctx, session.ID)
+	require.NoError(t, err)
+
+	// An archived message is back already, next to one written later
+	require.NoError(t, repo.Message().Create(ctx, originals[2]))
+	require.NoError(t, repo.Message().Create(ctx, &models.Message{SessionID: session.ID, Role: "user", Content: "late", Sequence: 4}))
+
+	result, err := service.Unarchive(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Restored)
+
+	messages, _, err := repo.Message().ListBySessionID(ctx, session.ID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+	for i, content := range []string{"first", "second", "third", "late"} {
+		assert.Equal(t, content, messages[i].Content)
+		assert.Equal(t, int64(i+1), messages[i].Sequence)
+	}
+	toolCalls, err := repo.ToolCall().ListBySessionID(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Len(t, toolCalls, 1)
+}
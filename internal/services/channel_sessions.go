@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+)
+
+// ChannelSessions maps conversations on external chat platforms, e.g. Discord
+// channels and threads or email threads, to agent sessions
+type ChannelSessions struct {
+	repo storage.Repository
+}
+
+// NewChannelSessions creates a channel session mapper
+func NewChannelSessions(repo storage.Repository) *ChannelSessions {
+	return &ChannelSessions{repo: repo}
+}
+
+// Resolve returns the session bound to a platform conversation. A new session
+// with the agent is created and bound when there is none yet, or when the
+// bound session was deleted, archived or belongs to another agent.
+func (c *ChannelSessions) Resolve(ctx context.Context, platform, externalID, agentID, title string) (*models.ChatSession, error) {
+	session, err := c.Find(ctx, platform, externalID, agentID)
+	if err != nil || session != nil {
+		return session, err
+	}
+
+	agent, err := c.repo.Agent().GetByID(ctx, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent: %w", err)
+	}
+	if agent == nil {
+		return nil, ErrAgentNotFound
+	}
+
+	session = &models.ChatSession{
+		AgentID:         agentID,
+		Title:           title,
+		ContextStrategy: "last_n",
+		ContextConfig:   make(models.JSON),
+	}
+	if err := c.repo.Session().Create(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	if err := c.Bind(ctx, platform, externalID, session); err != nil {
+		return nil, err
+	}
+
+	session.Agent = *agent
+	return session, nil
+}
+
+// Find returns the session bound to a platform conversation, or nil when
+// there is none that can continue it with the agent
+func (c *ChannelSessions) Find(ctx context.Context, platform, externalID, agentID string) (*models.ChatSession, error) {
+	binding, err := c.repo.ChannelBinding().Get(ctx, platform, externalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel binding: %w", err)
+	}
+	if binding == nil || binding.AgentID != agentID {
+		return nil, nil
+	}
+
+	session, err := c.repo.Session().GetByID(ctx, binding.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil || session.IsArchived() {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// Bind binds a platform conversation to a session, replacing its previous
+// binding
+func (c *ChannelSessions) Bind(ctx context.Context, platform, externalID string, session *models.ChatSession) error {
+	binding, err := c.repo.ChannelBinding().Get(ctx, platform, externalID)
+	if err != nil {
+		return fmt.Errorf("failed to get channel binding: %w", err)
+	}
+	if binding == nil {
+		binding = &models.ChannelBinding{Platform: platform, ExternalID: externalID}
+	}
+	binding.SessionID = session.ID
+	binding.AgentID = session.AgentID
+	if err := c.repo.ChannelBinding().Save(ctx, binding); err != nil {
+		return fmt.Errorf("failed to bind session: %w", err)
+	}
+	return nil
+}
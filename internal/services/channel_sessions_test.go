@@ -0,0 +1,57 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelSessions_Resolve(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	first := &models.Agent{Name: "first", Provider: "ollama", Model: "llama3"}
+	require.NoError(t, repo.Agent().Create(ctx, first))
+	second := &models.Agent{Name: "second", Provider: "ollama", Model: "llama3"}
+	require.NoError(t, repo.Agent().Create(ctx, second))
+
+	sessions := services.NewChannelSessions(repo)
+
+	session, err := sessions.Resolve(ctx, "discord", "channel-1", first.ID, "Discord #general")
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, session.AgentID)
+	assert.Equal(t, "Discord #general", session.Title)
+
+	// The same conversation continues its session; others get their own
+	again, err := sessions.Resolve(ctx, "discord", "channel-1", first.ID, "Discord #general")
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, again.ID)
+	other, err := sessions.Resolve(ctx, "discord", "channel-2", first.ID, "")
+	require.NoError(t, err)
+	assert.NotEqual(t, session.ID, other.ID)
+
+	// Switching the agent or deleting the session starts a new one
+	switched, err := sessions.Resolve(ctx, "discord", "channel-1", second.ID, "")
+	require.NoError(t, err)
+	assert.NotEqual(t, session.ID, switched.ID)
+	assert.Equal(t, second.ID, switched.AgentID)
+
+	require.NoError(t, repo.Session().Delete(ctx, switched.ID))
+	binding, err := repo.ChannelBinding().Get(ctx, "discord", "channel-1")
+	require.NoError(t, err)
+	assert.Nil(t, binding)
+	fresh, err := sessions.Resolve(ctx, "discord", "channel-1", second.ID, "")
+	require.NoError(t, err)
+	assert.NotEqual(t, switched.ID, fresh.ID)
+
+	_, err = sessions.Resolve(ctx, "discord", "channel-3", "missing", "")
+	assert.ErrorIs(t, err, services.ErrAgentNotFound)
+}
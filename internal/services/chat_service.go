@@ -3,18 +3,39 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	contextpkg "agent-server/internal/context"
 	"agent-server/internal/llm"
 	"agent-server/internal/models"
+	"agent-server/internal/moderation"
+	"agent-server/internal/pii"
+	"agent-server/internal/postprocess"
+	"agent-server/internal/queue"
+	"agent-server/internal/redact"
 	"agent-server/internal/storage"
 
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrAgentDisabled is returned when chatting with an agent taken offline
+	ErrAgentDisabled = errors.New("agent is disabled")
+	// ErrProviderUnavailable is returned when the agent's LLM provider is not
+	// registered or not reachable
+	ErrProviderUnavailable = errors.New("LLM provider is not available")
+	// ErrLLMRequest wraps errors returned by an LLM provider
+	ErrLLMRequest = errors.New("LLM request failed")
+	// ErrToolLoopExceeded is returned when the model keeps requesting tools
+	// beyond the iteration limit
+	ErrToolLoopExceeded = errors.New("exceeded maximum tool call iterations")
+)
+
 // ChatService handles chat operations with LLM integration and tool calling support
 type ChatService struct {
 	repo          storage.Repository
@@ -22,7 +43,47 @@ type ChatService struct {
 	ctxRegistry   *contextpkg.StrategyRegistry
 	toolService   *ToolService
 	promptService *PromptService
+	outcomes      *chatOutcomeTracker
+	redactor      *redact.Redactor
+	accounting    *Accounting
+	maxResponse   int // characters; 0 is unlimited
 	logger        *slog.Logger
+
+	moderator        moderation.Moderator
+	moderationPolicy moderation.Policy
+
+	// piiScrubber removes personal data from what agents with a
+	// data-handling policy store
+	piiScrubber *pii.Scrubber
+
+	// limiter bounds the replies each agent generates at once, see
+	// SetConcurrencyLimits
+	limiter *agentLimiter
+
+	// broker carries chats to worker instances, see SetBroker
+	broker        queue.Broker
+	acceptTimeout time.Duration
+
+	// modelPuller fetches models missing on their provider, see SetModelPuller
+	modelPuller *ModelPuller
+	autoPull    bool
+
+	// contextWindows resolves the context window of agents' models and
+	// contextUsage tracks how much of it their prompts use
+	contextWindows *ContextWindows
+	contextUsage   *contextUsageTracker
+
+	// traceContexts stores how the context of every turn was built, see
+	// SetContextTracing
+	traceContexts bool
+
+	// flags turns experimental capabilities on per session, see
+	// SetFeatureFlags
+	flags *FeatureFlags
+
+	// postProcessing cleans up replies before they are saved, see
+	// SetPostProcessing
+	postProcessing *postprocess.Pipeline
 }
 
 // NewChatService creates a new chat service with tool support
@@ -40,16 +101,87 @@ func NewChatService(
 		ctxRegistry:   ctxRegistry,
 		toolService:   toolService,
 		promptService: promptService,
+		outcomes:      newChatOutcomeTracker(),
+		redactor:      redact.Default(),
 		logger:        logger,
+
+		contextWindows: NewContextWindows(llmRegistry, nil),
+		contextUsage:   newContextUsageTracker(),
+		traceContexts:  true,
 	}
 }
 
+// SetRedactor sets the redactor applied to message metadata, tool calls and
+// tool results before they are persisted; nil disables redaction
+func (s *ChatService) SetRedactor(r *redact.Redactor) {
+	s.redactor = r
+}
+
+// SetAccounting prices the token usage of replies and rejects turns once the
+// budget is spent
+func (s *ChatService) SetAccounting(accounting *Accounting) {
+	s.accounting = accounting
+}
+
+// SetMaxResponseLength caps replies at maxChars characters. Longer replies
+// are cut off, streams are ended early, and the reply is saved with finish
+// reason "length". Zero disables the limit.
+func (s *ChatService) SetMaxResponseLength(maxChars int) {
+	s.maxResponse = maxChars
+}
+
+// SetModelPuller reports chats that fail on a model missing from its
+// provider as *ModelMissingError, and with autoPull starts downloading the
+// model right away
+func (s *ChatService) SetModelPuller(puller *ModelPuller, autoPull bool) {
+	s.modelPuller = puller
+	s.autoPull = autoPull
+}
+
+// SetFeatureFlags evaluates flags for every chat; the evaluations gate
+// tools and are recorded in the metadata of replies
+func (s *ChatService) SetFeatureFlags(flags *FeatureFlags) {
+	s.flags = flags
+}
+
+// providerError wraps an error returned by the provider of agent. A missing
+// model is reported with whether it can be pulled, and is pulled when
+// auto-pull is enabled.
+func (s *ChatService) providerError(agent *models.Agent, err error) error {
+	if s.modelPuller != nil && errors.Is(err, llm.ErrModelNotFound) {
+		missing := &ModelMissingError{
+			Provider: agent.Provider,
+			Model:    agent.Model,
+			CanPull:  s.modelPuller.CanPull(agent.Provider),
+			Err:      err,
+		}
+		if missing.CanPull && s.autoPull {
+			if pull, pullErr := s.modelPuller.Pull(agent.Provider, agent.Model); pullErr != nil {
+				s.logger.Warn("Failed to start model pull", "provider", agent.Provider, "model", agent.Model, "error", pullErr)
+			} else {
+				missing.Pull = &pull
+			}
+		}
+		err = missing
+	}
+	return fmt.Errorf("%w: %w", ErrLLMRequest, err)
+}
+
+// AgentChatStats returns the chat outcomes recorded for an agent within window
+func (s *ChatService) AgentChatStats(agentID string, window time.Duration) ChatStats {
+	return s.outcomes.stats(agentID, window)
+}
+
 // ChatRequest represents a chat request
 type ChatRequest struct {
-	SessionID string                 `json:"session_id"`
-	Message   string                 `json:"message"`
-	Metadata  map[string]interface{} `json:"metadata"`
-	Stream    bool                   `json:"stream"`
+	SessionID        string                 `json:"session_id"`
+	Message          string                 `json:"message"`
+	Metadata         map[string]interface{} `json:"metadata"`
+	Stream           bool                   `json:"stream"`
+	Stop             []string               `json:"stop,omitempty" validate:"max=4,dive,required"`                       // Sequences that end the reply
+	ResponseLanguage string                 `json:"response_language,omitempty" validate:"omitempty,bcp47_language_tag"` // Overrides the session's language
+	Priority         string                 `json:"priority,omitempty" validate:"omitempty,oneof=interactive batch"`     // Scheduling class, interactive by default
+	Accept           string                 `json:"accept,omitempty"`                                                    // Formats of the reply, e.g. "text/html, text/plain;q=0.5"
 }
 
 // ChatResponse represents a chat response
@@ -57,11 +189,21 @@ type ChatResponse struct {
 	UserMessageID      string                 `json:"user_message_id"`
 	AssistantMessageID string                 `json:"assistant_message_id"`
 	Response           string                 `json:"response"`
+	Format             string                 `json:"format,omitempty"` // media type of Response
+	Attachments        []models.Attachment    `json:"attachments,omitempty"`
 	Metadata           map[string]interface{} `json:"metadata"`
 }
 
 // Chat processes a chat request and returns a response
-func (s *ChatService) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+func (s *ChatService) Chat(ctx context.Context, req *ChatRequest) (_ *ChatResponse, err error) {
+	format, err := responseFormat(req.Accept)
+	if err != nil {
+		return nil, err
+	}
+	if s.dispatches(ctx) {
+		return s.remoteChat(ctx, req)
+	}
+
 	// Get session with agent info
 	session, err := s.repo.Session().GetByID(ctx, req.SessionID)
 	if err != nil {
@@ -69,55 +211,56 @@ func (s *ChatService) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse
 	}
 
 	if session == nil {
-		return nil, fmt.Errorf("session not found")
+		return nil, ErrSessionNotFound
 	}
 
-	// Get LLM provider
-	provider, exists := s.llmRegistry.Get(session.Agent.Provider)
-	if !exists {
-		return nil, fmt.Errorf("unsupported LLM provider: %s", session.Agent.Provider)
+	if err := checkSessionAvailable(session); err != nil {
+		return nil, err
 	}
 
-	// Check if provider is available
-	if !provider.IsAvailable(ctx) {
-		return nil, fmt.Errorf("LLM provider %s is not available", session.Agent.Provider)
+	if err := s.accounting.CheckBudget(ctx, session); err != nil {
+		return nil, err
 	}
+	ctx = s.withFeatureFlags(ctx, session)
 
-	// Save user message
-	userMessage := &models.Message{
-		SessionID: req.SessionID,
-		Role:      "user",
-		Content:   req.Message,
-		Metadata:  models.JSON(req.Metadata),
+	release, err := s.acquireSlot(ctx, &session.Agent, req.Priority)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
-	if err := s.repo.Message().Create(ctx, userMessage); err != nil {
-		return nil, fmt.Errorf("failed to save user message: %w", err)
-	}
+	defer func() {
+		s.outcomes.record(session.AgentID, err)
+	}()
 
-	// Get message history for context
-	messages, _, err := s.repo.Message().ListBySessionID(ctx, req.SessionID, 1000, 0)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get message history: %w", err)
+	// Get LLM provider
+	provider, exists := s.llmRegistry.Get(session.Agent.Provider)
+	if !exists {
+		return nil, fmt.Errorf("%w: unsupported provider %s", ErrProviderUnavailable, session.Agent.Provider)
 	}
 
-	// Build context using strategy
-	strategy, exists := s.ctxRegistry.Get(session.ContextStrategy)
-	if !exists {
-		return nil, fmt.Errorf("unknown context strategy: %s", session.ContextStrategy)
+	// Check if provider is available
+	if !provider.IsAvailable(ctx) {
+		return nil, fmt.Errorf("%w: %s", ErrProviderUnavailable, session.Agent.Provider)
 	}
 
-	contextMessages, err := strategy.BuildContext(
-		ctx,
-		session.Agent.SystemPrompt,
-		"", // No additional agent prompt for now
-		messages,
-		session.ContextConfig,
-	)
+	// Save the user message while the context is built
+	turn, err := s.startTurn(ctx, session, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build context: %w", err)
+		return nil, err
+	}
+	userMessage, contextMessages := turn.userMessage, turn.contextMessages
+	if err := turn.waitSaved(); err != nil {
+		return nil, err
 	}
 
+	// A turn without a saved reply is excluded from context and counted as failed
+	defer func() {
+		if err != nil {
+			s.markTurnIncomplete(ctx, userMessage.ID)
+		}
+	}()
+
 	// Prepare LLM request
 	llmRequest := &llm.ChatRequest{
 		Model:       session.Agent.Model,
@@ -125,14 +268,24 @@ func (s *ChatService) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse
 		Temperature: session.Agent.Temperature,
 		MaxTokens:   session.Agent.MaxTokens,
 		Stream:      req.Stream,
+		Stop:        req.Stop,
 		Options:     session.Agent.Config,
 	}
 
 	// Call LLM provider
+	start := time.Now()
 	llmResponse, err := provider.Chat(ctx, llmRequest)
 	if err != nil {
-		return nil, fmt.Errorf("LLM request failed: %w", err)
+		return nil, s.providerError(&session.Agent, err)
 	}
+	if reason := reaskReason(session.Agent.Reask, llmResponse.Content); reason != "" {
+		llmResponse, err = s.reask(ctx, provider, &session.Agent, llmRequest, llmResponse, reason)
+		if err != nil {
+			return nil, s.providerError(&session.Agent, err)
+		}
+	}
+	recordLatency(llmResponse, start)
+	s.limitResponse(llmResponse)
 
 	// Prepare metadata
 	metadata := map[string]interface{}{
@@ -140,33 +293,39 @@ func (s *ChatService) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse
 		"model":          session.Agent.Model,
 		"context_length": len(contextMessages),
 		"strategy":       session.ContextStrategy,
+		"finish_reason":  getFinishReason(llmResponse, false),
 	}
 
 	// Add usage info if available
 	if llmResponse.Usage != nil {
-		metadata["usage"] = map[string]interface{}{
-			"prompt_tokens":     llmResponse.Usage.PromptTokens,
-			"completion_tokens": llmResponse.Usage.CompletionTokens,
-			"total_tokens":      llmResponse.Usage.TotalTokens,
-		}
+		metadata["usage"] = usageMetadata(llmResponse.Usage)
 	}
+	s.recordCost(metadata, &session.Agent, llmResponse.Usage)
+	s.recordContextUsage(ctx, metadata, &session.Agent, llmResponse.Usage, llmRequest.Messages)
+	recordPromptBreakdown(metadata, &session.Agent, turn.promptTokens)
+	recordFeatureFlags(ctx, metadata)
 
 	// Add LLM metadata
 	for k, v := range llmResponse.Metadata {
 		metadata[k] = v
 	}
+	llmResponse.Content = s.moderateOutput(ctx, &session.Agent, llmResponse.Content, metadata)
+	var attachments []models.Attachment
+	llmResponse.Content, attachments = s.postProcess(&session.Agent, llmResponse.Content, metadata)
 
 	// Save assistant message
 	assistantMessage := &models.Message{
 		SessionID: req.SessionID,
 		Role:      "assistant",
-		Content:   llmResponse.Content,
-		Metadata:  models.JSON(metadata),
+		Content:   s.scrubMessage(ctx, &session.Agent, llmResponse.Content),
+		Metadata:  models.JSON(s.redactor.Map(metadata)),
+		TurnID:    userMessage.ID,
 	}
 
 	if err := s.repo.Message().Create(ctx, assistantMessage); err != nil {
 		return nil, fmt.Errorf("failed to save assistant message: %w", err)
 	}
+	s.RecordActivity(ctx, assistantMessage)
 
 	s.logger.Info("Chat completed successfully",
 		"session_id", req.SessionID,
@@ -175,24 +334,78 @@ func (s *ChatService) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse
 		"provider", session.Agent.Provider,
 		"model", session.Agent.Model)
 
+	if wantsContextTrace(ctx) {
+		metadata["context_trace"] = turn.trace
+	}
+
 	return &ChatResponse{
 		UserMessageID:      userMessage.ID,
 		AssistantMessageID: assistantMessage.ID,
-		Response:           llmResponse.Content,
+		Response:           postprocess.Render(llmResponse.Content, format),
+		Format:             format,
+		Attachments:        attachments,
 		Metadata:           metadata,
 	}, nil
 }
 
+// AddNote appends a system note to a session without generating a reply. The
+// note is part of the context of later replies; unless visible, it is hidden
+// from transcripts.
+func (s *ChatService) AddNote(ctx context.Context, sessionID string, req *models.CreateNoteRequest) (*models.Message, error) {
+	session, err := s.repo.Session().GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
+	if session.IsArchived() {
+		return nil, ErrSessionArchived
+	}
+
+	note := &models.Message{
+		SessionID: sessionID,
+		Role:      "system",
+		Content:   req.Content,
+		Metadata:  models.JSON(req.Metadata),
+		Hidden:    !req.Visible,
+	}
+	if note.Metadata == nil {
+		note.Metadata = models.JSON{}
+	}
+	note.Metadata["note"] = true
+
+	if err := s.repo.Message().Create(ctx, note); err != nil {
+		return nil, fmt.Errorf("failed to save note: %w", err)
+	}
+
+	s.logger.Info("Note added to session",
+		"session_id", sessionID,
+		"message_id", note.ID,
+		"hidden", note.Hidden)
+	return note, nil
+}
+
 // StreamChunk represents a streaming response chunk
 type StreamChunk struct {
 	Content      string                 `json:"content"`
 	Done         bool                   `json:"done"`
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
 	MessageID    string                 `json:"message_id,omitempty"`
+	FinishReason string                 `json:"finish_reason,omitempty"` // Set on the final chunk
+	Usage        *llm.Usage             `json:"usage,omitempty"`         // Set on the final chunk
 }
 
 // Stream processes a streaming chat request
-func (s *ChatService) Stream(ctx context.Context, req *ChatRequest) (<-chan StreamChunk, error) {
+func (s *ChatService) Stream(ctx context.Context, req *ChatRequest) (_ <-chan StreamChunk, err error) {
+	// Chunks are sent as generated, so streamed replies are markdown
+	if _, err := responseFormat(req.Accept, postprocess.FormatMarkdown); err != nil {
+		return nil, err
+	}
+	if s.dispatches(ctx) {
+		return s.remoteStream(ctx, req)
+	}
+
 	// Get session with agent info
 	session, err := s.repo.Session().GetByID(ctx, req.SessionID)
 	if err != nil {
@@ -200,54 +413,61 @@ func (s *ChatService) Stream(ctx context.Context, req *ChatRequest) (<-chan Stre
 	}
 
 	if session == nil {
-		return nil, fmt.Errorf("session not found")
+		return nil, ErrSessionNotFound
 	}
 
-	// Get LLM provider
-	provider, exists := s.llmRegistry.Get(session.Agent.Provider)
-	if !exists {
-		return nil, fmt.Errorf("unsupported LLM provider: %s", session.Agent.Provider)
+	if err := checkSessionAvailable(session); err != nil {
+		return nil, err
 	}
 
-	// Check if provider is available
-	if !provider.IsAvailable(ctx) {
-		return nil, fmt.Errorf("LLM provider %s is not available", session.Agent.Provider)
+	if err := s.accounting.CheckBudget(ctx, session); err != nil {
+		return nil, err
 	}
+	ctx = s.withFeatureFlags(ctx, session)
 
-	// Save user message
-	userMessage := &models.Message{
-		SessionID: req.SessionID,
-		Role:      "user",
-		Content:   req.Message,
-		Metadata:  models.JSON(req.Metadata),
+	// The slot is held until the stream has ended
+	release, err := s.acquireSlot(ctx, &session.Agent, req.Priority)
+	if err != nil {
+		return nil, err
 	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
 
-	if err := s.repo.Message().Create(ctx, userMessage); err != nil {
-		return nil, fmt.Errorf("failed to save user message: %w", err)
-	}
+	// Successful streams are recorded once the response has been saved
+	defer func() {
+		if err != nil {
+			s.outcomes.record(session.AgentID, err)
+		}
+	}()
 
-	// Get message history for context
-	messages, _, err := s.repo.Message().ListBySessionID(ctx, req.SessionID, 1000, 0)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get message history: %w", err)
+	// Get LLM provider
+	provider, exists := s.llmRegistry.Get(session.Agent.Provider)
+	if !exists {
+		return nil, fmt.Errorf("%w: unsupported provider %s", ErrProviderUnavailable, session.Agent.Provider)
 	}
 
-	// Build context using strategy
-	strategy, exists := s.ctxRegistry.Get(session.ContextStrategy)
-	if !exists {
-		return nil, fmt.Errorf("unknown context strategy: %s", session.ContextStrategy)
+	// Check if provider is available
+	if !provider.IsAvailable(ctx) {
+		return nil, fmt.Errorf("%w: %s", ErrProviderUnavailable, session.Agent.Provider)
 	}
 
-	contextMessages, err := strategy.BuildContext(
-		ctx,
-		session.Agent.SystemPrompt,
-		"",
-		messages,
-		session.ContextConfig,
-	)
+	// Save the user message while the context is built
+	turn, err := s.startTurn(ctx, session, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build context: %w", err)
+		return nil, err
 	}
+	userMessage, contextMessages := turn.userMessage, turn.contextMessages
+
+	// A turn without a saved reply is excluded from context and counted as
+	// failed; the save must have finished before the turn can be marked
+	defer func() {
+		if err != nil && turn.waitSaved() == nil {
+			s.markTurnIncomplete(ctx, userMessage.ID)
+		}
+	}()
 
 	// Prepare LLM request
 	llmRequest := &llm.ChatRequest{
@@ -256,13 +476,28 @@ func (s *ChatService) Stream(ctx context.Context, req *ChatRequest) (<-chan Stre
 		Temperature: session.Agent.Temperature,
 		MaxTokens:   session.Agent.MaxTokens,
 		Stream:      true,
+		Stop:        req.Stop,
 		Options:     session.Agent.Config,
 	}
 
-	// Start streaming from LLM provider
-	llmChunks, err := provider.Stream(ctx, llmRequest)
+	// Start streaming from LLM provider as soon as the context is ready,
+	// without waiting for the user message to be written
+	streamCtx, cancel := context.WithCancel(ctx)
+	start := time.Now()
+	llmChunks, err := provider.Stream(streamCtx, llmRequest)
 	if err != nil {
-		return nil, fmt.Errorf("LLM streaming failed: %w", err)
+		cancel()
+		return nil, s.providerError(&session.Agent, fmt.Errorf("streaming: %w", err))
+	}
+
+	// The reply is only forwarded for a saved user message
+	if err := turn.waitSaved(); err != nil {
+		cancel()
+		go func() {
+			for range llmChunks {
+			}
+		}()
+		return nil, err
 	}
 
 	// Create output channel
@@ -271,26 +506,77 @@ func (s *ChatService) Stream(ctx context.Context, req *ChatRequest) (<-chan Stre
 	// Process streaming response
 	go func() {
 		defer close(outputChunks)
+		defer release()
+		defer cancel()
 
 		var fullResponse strings.Builder
 		var assistantMessage *models.Message
+		var usage *llm.Usage
+		var finishReason string
+		length := 0
+		pacer := newStreamPacer(session.Agent.StreamRate)
+		var reasked interface{}
 
 		for chunk := range llmChunks {
-			// Forward chunk to client
-			outputChunk := StreamChunk{
-				Content:  chunk.Content,
-				Done:     chunk.Done,
-				Metadata: chunk.Metadata,
+			// A blank reply has shown nothing yet, so it can still be asked
+			// again; refusals have been streamed by the time they are seen
+			if chunk.Done && reaskReason(session.Agent.Reask, fullResponse.String()+chunk.Content) == ReaskEmpty {
+				retry, err := s.reask(ctx, provider, &session.Agent, llmRequest, &llm.ChatResponse{}, ReaskEmpty)
+				if err != nil {
+					s.logger.Warn("Failed to re-ask blank reply", "session_id", session.ID, "error", err)
+				} else {
+					if retry.Usage != nil {
+						retryUsage := *retry.Usage
+						retryUsage.Add(chunk.Usage)
+						chunk.Usage = &retryUsage
+					}
+					chunk.Content = retry.Content
+					reasked = retry.Metadata["reask"]
+				}
+			}
+
+			// A reply over the maximum length ends the stream early
+			truncated := false
+			if s.maxResponse > 0 {
+				chunk.Content, truncated = truncateChars(chunk.Content, s.maxResponse-length)
+				length += utf8.RuneCountInString(chunk.Content)
+			}
+			if truncated {
+				cancel()
+				chunk.Done = true
+				chunk.FinishReason = "length"
 			}
 
-			select {
-			case outputChunks <- outputChunk:
-			case <-ctx.Done():
-				return
+			// Forward chunk to client, at the agent's pace if it has one; the
+			// final chunk, sent once the reply has been saved, is the one
+			// marked done
+			for i, piece := range pacer.pieces(chunk.Content) {
+				if err := pacer.wait(ctx, piece); err != nil {
+					return
+				}
+				outputChunk := StreamChunk{Content: piece}
+				if i == 0 {
+					outputChunk.Metadata = chunk.Metadata
+				}
+
+				select {
+				case outputChunks <- outputChunk:
+				case <-ctx.Done():
+					return
+				}
 			}
 
-			// Accumulate response
+			// Accumulate response and usage
 			fullResponse.WriteString(chunk.Content)
+			if chunk.Usage != nil {
+				if usage == nil {
+					usage = &llm.Usage{}
+				}
+				usage.Add(chunk.Usage)
+			}
+			if chunk.FinishReason != "" {
+				finishReason = chunk.FinishReason
+			}
 
 			// Save final message when done
 			if chunk.Done {
@@ -300,38 +586,80 @@ func (s *ChatService) Stream(ctx context.Context, req *ChatRequest) (<-chan Stre
 					"context_length": len(contextMessages),
 					"strategy":       session.ContextStrategy,
 					"streamed":       true,
+					"latency_ms":     time.Since(start).Milliseconds(),
 				}
 
 				// Add chunk metadata
 				for k, v := range chunk.Metadata {
 					metadata[k] = v
 				}
+				if truncated {
+					metadata["truncated"] = true
+				}
+				if reasked != nil {
+					metadata["reask"] = reasked
+				}
+				if usage != nil {
+					metadata["usage"] = usageMetadata(usage)
+				}
+				s.recordCost(metadata, &session.Agent, usage)
+				s.recordContextUsage(ctx, metadata, &session.Agent, usage, llmRequest.Messages)
+				recordPromptBreakdown(metadata, &session.Agent, turn.promptTokens)
+				recordFeatureFlags(ctx, metadata)
+				if finishReason == "" {
+					finishReason = "stop"
+				}
+				metadata["finish_reason"] = finishReason
+
+				// The reply has been streamed already; a blocked one is
+				// withheld from the history and the final chunk says so
+				content := s.moderateOutput(ctx, &session.Agent, fullResponse.String(), metadata)
+				content, attachments := s.postProcess(&session.Agent, content, metadata)
 
 				assistantMessage = &models.Message{
 					SessionID: req.SessionID,
 					Role:      "assistant",
-					Content:   fullResponse.String(),
-					Metadata:  models.JSON(metadata),
+					Content:   s.scrubMessage(ctx, &session.Agent, content),
+					Metadata:  models.JSON(s.redactor.Map(metadata)),
+					TurnID:    userMessage.ID,
+				}
+
+				// Send final chunk with message ID, finish reason and usage
+				finalChunk := StreamChunk{
+					Content:      "",
+					Done:         true,
+					FinishReason: finishReason,
+					Usage:        usage,
+					Metadata: map[string]interface{}{
+						"user_message_id": userMessage.ID,
+					},
+				}
+				if verdict, ok := metadata[metadataModeration]; ok {
+					finalChunk.Metadata[metadataModeration] = verdict
+				}
+				if window, ok := metadata["context_window"]; ok {
+					finalChunk.Metadata["context_window"] = window
+				}
+				if len(attachments) > 0 {
+					finalChunk.Metadata["attachments"] = attachments
+				}
+				if wantsContextTrace(ctx) {
+					finalChunk.Metadata["context_trace"] = turn.trace
 				}
 
 				if err := s.repo.Message().Create(ctx, assistantMessage); err != nil {
 					s.logger.Error("Failed to save streamed assistant message", "error", err)
+					s.outcomes.record(session.AgentID, err)
 				} else {
-					// Send final chunk with message ID
-					finalChunk := StreamChunk{
-						Content:   "",
-						Done:      true,
-						MessageID: assistantMessage.ID,
-						Metadata: map[string]interface{}{
-							"user_message_id": userMessage.ID,
-						},
-					}
+					s.outcomes.record(session.AgentID, nil)
+					s.RecordActivity(ctx, assistantMessage)
+					finalChunk.MessageID = assistantMessage.ID
+				}
 
-					select {
-					case outputChunks <- finalChunk:
-					case <-ctx.Done():
-						return
-					}
+				select {
+				case outputChunks <- finalChunk:
+				case <-ctx.Done():
+					return
 				}
 
 				s.logger.Info("Streaming chat completed successfully",
@@ -345,13 +673,147 @@ func (s *ChatService) Stream(ctx context.Context, req *ChatRequest) (<-chan Stre
 				break
 			}
 		}
+
+		if assistantMessage == nil {
+			s.outcomes.record(session.AgentID, fmt.Errorf("stream ended before completion"))
+			s.markTurnIncomplete(ctx, userMessage.ID)
+		}
 	}()
 
 	return outputChunks, nil
 }
 
+// turnStart is a chat turn whose user message is being saved while the
+// context for the reply is built
+type turnStart struct {
+	userMessage     *models.Message
+	contextMessages []*models.Message
+	trace           *models.ContextTrace
+	promptTokens    *models.PromptBreakdown // read before the trace is shared
+	saved           chan struct{}
+	saveErr         error
+}
+
+// waitSaved blocks until the user message has been written and returns the
+// error of the write
+func (t *turnStart) waitSaved() error {
+	<-t.saved
+	if t.saveErr != nil {
+		return fmt.Errorf("failed to save user message: %w", t.saveErr)
+	}
+	return nil
+}
+
+// startTurn saves the user message of req and concurrently reads the session
+// history and builds the context for the reply, so that the LLM call does not
+// wait for the write. The context contains the user message whether or not
+// the history read already saw it.
+func (s *ChatService) startTurn(ctx context.Context, session *models.ChatSession, req *ChatRequest) (*turnStart, error) {
+	userMessage := &models.Message{
+		ID:        uuid.New().String(),
+		SessionID: req.SessionID,
+		Role:      "user",
+		Content:   req.Message,
+		Metadata:  models.JSON(req.Metadata),
+		Status:    models.MessageStatusComplete,
+		CreatedAt: time.Now(),
+	}
+	userMessage.TurnID = userMessage.ID
+	systemPrompt := s.localize(ctx, session, userMessage, req.ResponseLanguage)
+	if err := s.moderateInput(ctx, session, userMessage); err != nil {
+		return nil, err
+	}
+	// The context gets a copy, since saving assigns the sequence, and keeps
+	// the personal data scrubbed from the stored message
+	pending := *userMessage
+	userMessage.Content = s.scrubMessage(ctx, &session.Agent, userMessage.Content)
+
+	turn := &turnStart{userMessage: userMessage, saved: make(chan struct{})}
+	go func() {
+		defer close(turn.saved)
+		turn.saveErr = s.repo.Message().Create(ctx, userMessage)
+		if turn.saveErr == nil {
+			s.RecordActivity(ctx, userMessage)
+		}
+	}()
+
+	contextMessages, trace, err := s.buildTurnContext(ctx, session, systemPrompt, &pending)
+	if err != nil {
+		if turn.waitSaved() == nil {
+			s.markTurnIncomplete(ctx, userMessage.ID)
+		}
+		return nil, err
+	}
+	turn.contextMessages = contextMessages
+	trace.TurnID = userMessage.ID
+	trace.SessionID = session.ID
+	turn.trace = trace
+	turn.promptTokens = trace.Tokens
+	go func() {
+		if turn.waitSaved() == nil {
+			s.saveContextTrace(ctx, session, userMessage.ID, trace)
+		}
+	}()
+	return turn, nil
+}
+
+// buildTurnContext builds the LLM context from the system prompt and the
+// session history followed by the new user message, and traces it
+func (s *ChatService) buildTurnContext(ctx context.Context, session *models.ChatSession, systemPrompt string, userMessage *models.Message) ([]*models.Message, *models.ContextTrace, error) {
+	// Get message history for context
+	messages, _, err := s.repo.Message().ListBySessionID(ctx, session.ID, 1000, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get message history: %w", err)
+	}
+
+	history := make([]*models.Message, 0, len(messages)+1)
+	for _, message := range completedMessages(messages) {
+		if message.ID != userMessage.ID {
+			history = append(history, message)
+		}
+	}
+	history = append(history, userMessage)
+
+	// Build context using strategy
+	contextMessages, trace, err := s.buildContext(ctx, session, systemPrompt, history, messages)
+	if err != nil {
+		return nil, nil, err
+	}
+	trace.Tokens = promptBreakdown(llm.ConvertMessages(contextMessages), "", nil)
+	return contextMessages, trace, nil
+}
+
 // ChatWithTools processes a chat request with tool calling support
 func (s *ChatService) ChatWithTools(ctx context.Context, req *models.EnhancedChatRequest, sessionID string) (*models.EnhancedChatResponse, error) {
+	if s.dispatches(ctx) {
+		return s.remoteChatWithTools(ctx, req, sessionID)
+	}
+	turn, err := s.startToolTurn(ctx, req, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.finishToolTurn(ctx, turn, func(ToolChatEvent) {})
+}
+
+// toolTurn is a tool-calling chat whose user message has been saved
+type toolTurn struct {
+	session        *models.ChatSession
+	systemPrompt   string
+	userMessage    *models.Message
+	availableTools []string
+	flags          map[string]bool // feature flag evaluations of the session
+	req            *models.EnhancedChatRequest
+	format         string // of the final answer
+	release        func() // gives the agent's generation slot back
+}
+
+// startToolTurn checks that the session can chat and saves the user message
+func (s *ChatService) startToolTurn(ctx context.Context, req *models.EnhancedChatRequest, sessionID string) (_ *toolTurn, err error) {
+	format, err := responseFormat(req.Accept)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get session with agent info
 	session, err := s.repo.Session().GetByID(ctx, sessionID)
 	if err != nil {
@@ -359,31 +821,90 @@ func (s *ChatService) ChatWithTools(ctx context.Context, req *models.EnhancedCha
 	}
 
 	if session == nil {
-		return nil, fmt.Errorf("session not found")
+		return nil, ErrSessionNotFound
 	}
 
+	if err := checkSessionAvailable(session); err != nil {
+		return nil, err
+	}
+
+	// Get available tools
+	availableTools := req.Tools
+	if len(availableTools) == 0 {
+		availableTools = session.Agent.AllowedTools()
+	}
+	if len(availableTools) == 0 {
+		// If no tools specified, use all available tools
+		toolsList, err := s.toolService.ListTools(ctx)
+		if err == nil {
+			for _, tool := range toolsList.Tools {
+				if tool.Available {
+					availableTools = append(availableTools, tool.Name)
+				}
+			}
+		}
+	}
+
+	// Tools behind a flag that is off for the session are not offered
+	ctx = s.withFeatureFlags(ctx, session)
+	availableTools = s.gateTools(ctx, availableTools)
+
+	// A forced tool must be on offer; checked before anything is saved
+	if _, err := resolveToolChoice(req.ToolChoice, availableTools); err != nil {
+		return nil, err
+	}
+
+	if err := s.accounting.CheckBudget(ctx, session); err != nil {
+		return nil, err
+	}
+
+	// The slot is held until the tool loop has finished
+	release, err := s.acquireSlot(ctx, &session.Agent, req.Priority)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
+	// Successful turns are recorded once the loop has finished
+	defer func() {
+		if err != nil {
+			s.outcomes.record(session.AgentID, err)
+		}
+	}()
+
 	// Get LLM provider
 	provider, exists := s.llmRegistry.Get(session.Agent.Provider)
 	if !exists {
-		return nil, fmt.Errorf("unsupported LLM provider: %s", session.Agent.Provider)
+		return nil, fmt.Errorf("%w: unsupported provider %s", ErrProviderUnavailable, session.Agent.Provider)
 	}
 
 	// Check if provider is available
 	if !provider.IsAvailable(ctx) {
-		return nil, fmt.Errorf("LLM provider %s is not available", session.Agent.Provider)
+		return nil, fmt.Errorf("%w: %s", ErrProviderUnavailable, session.Agent.Provider)
 	}
 
-	// Save user message
+	// Save user message; it stays pending until the tool loop finishes
 	userMessage := &models.Message{
 		SessionID: sessionID,
 		Role:      "user",
 		Content:   req.Message,
 		Metadata:  models.JSON(req.Metadata),
+		Status:    models.MessageStatusPending,
+	}
+	systemPrompt := s.localize(ctx, session, userMessage, req.ResponseLanguage)
+	if err := s.moderateInput(ctx, session, userMessage); err != nil {
+		return nil, err
 	}
+	userMessage.Content = s.scrubMessage(ctx, &session.Agent, userMessage.Content)
 
 	if err := s.repo.Message().Create(ctx, userMessage); err != nil {
 		return nil, fmt.Errorf("failed to save user message: %w", err)
 	}
+	s.RecordActivity(ctx, userMessage)
 
 	s.logger.Info("Processing chat request with tools",
 		"session_id", sessionID,
@@ -391,23 +912,31 @@ func (s *ChatService) ChatWithTools(ctx context.Context, req *models.EnhancedCha
 		"tools_requested", len(req.Tools),
 		"tool_choice", req.ToolChoice)
 
-	// Get available tools
-	availableTools := req.Tools
-	if len(availableTools) == 0 {
-		// If no tools specified, use all available tools
-		toolsList, err := s.toolService.ListTools(ctx)
-		if err == nil {
-			for _, tool := range toolsList.Tools {
-				if tool.Available {
-					availableTools = append(availableTools, tool.Name)
-				}
-			}
-		}
-	}
+	return &toolTurn{
+		session:        session,
+		systemPrompt:   systemPrompt,
+		userMessage:    userMessage,
+		availableTools: availableTools,
+		flags:          featureFlags(ctx),
+		req:            req,
+		format:         format,
+		release:        release,
+	}, nil
+}
+
+// finishToolTurn runs the tool-calling loop of a started turn, reporting
+// progress to emit
+func (s *ChatService) finishToolTurn(ctx context.Context, turn *toolTurn, emit func(ToolChatEvent)) (_ *models.EnhancedChatResponse, err error) {
+	defer turn.release()
+	defer func() {
+		s.outcomes.record(turn.session.AgentID, err)
+	}()
 
 	// Process the conversation with potential tool calls
-	response, err := s.processWithToolCalls(ctx, session, userMessage, availableTools, req)
+	ctx = withFlagEvaluations(ctx, turn.flags)
+	response, err := s.processWithToolCalls(ctx, turn.session, turn.systemPrompt, turn.userMessage, turn.availableTools, turn.req, turn.format, emit)
 	if err != nil {
+		s.markTurnIncomplete(ctx, turn.userMessage.ID)
 		return nil, fmt.Errorf("failed to process chat with tools: %w", err)
 	}
 
@@ -418,12 +947,18 @@ func (s *ChatService) ChatWithTools(ctx context.Context, req *models.EnhancedCha
 func (s *ChatService) processWithToolCalls(
 	ctx context.Context,
 	session *models.ChatSession,
+	systemPrompt string,
 	userMessage *models.Message,
 	availableTools []string,
 	req *models.EnhancedChatRequest,
+	format string,
+	emit func(ToolChatEvent),
 ) (*models.EnhancedChatResponse, error) {
 	maxIterations := 5 // Prevent infinite loops
 	var allToolCalls []models.ToolCallResult
+
+	// Tool calls of all iterations count against the per-turn quota
+	ctx = withToolTurn(ctx)
 	var conversationMessages []*models.Message
 
 	// Get initial message history
@@ -432,42 +967,94 @@ func (s *ChatService) processWithToolCalls(
 		return nil, fmt.Errorf("failed to get message history: %w", err)
 	}
 
-	conversationMessages = messages
+	conversationMessages = completedMessages(messages)
+	if s.scrubsMessages(&session.Agent) {
+		// The LLM gets the user message as written, not as stored
+		for i, message := range conversationMessages {
+			if message.ID == userMessage.ID {
+				conversationMessages[i] = unscrubbed(message, req.Message)
+			}
+		}
+	}
+
+	// The trace is of the context the final iteration replied with
+	var trace *models.ContextTrace
+	defer func() {
+		s.saveContextTrace(ctx, session, userMessage.ID, trace)
+	}()
+	sources := newCitationSources(conversationMessages, session.Agent.Grounded)
+	groundingRetried := false
+	reasked := "" // why the answer was asked again, at most once
+
+	// Agents with many tools may offer only those relevant to the message
+	forced, _ := models.ParseToolChoice(req.ToolChoice)
+	availableTools, toolSelection := s.selectTools(ctx, &session.Agent, req.Message, availableTools, forced)
+
+	// A forced tool choice holds until the model has called a tool
+	choice, err := resolveToolChoice(req.ToolChoice, availableTools)
+	if err != nil {
+		return nil, err
+	}
+	choiceRetried := false
 
 	for iteration := 0; iteration < maxIterations; iteration++ {
 		s.logger.Debug("Tool conversation iteration",
 			"iteration", iteration,
 			"session_id", session.ID)
 
-		// Build context using strategy with dynamic prompt
-		strategy, exists := s.ctxRegistry.Get(session.ContextStrategy)
-		if !exists {
-			return nil, fmt.Errorf("unknown context strategy: %s", session.ContextStrategy)
+		// Replies and tools of earlier iterations may have used up the budget
+		if iteration > 0 {
+			if err := s.accounting.CheckBudget(ctx, session); err != nil {
+				return nil, err
+			}
 		}
 
-		// Generate dynamic system prompt with tool descriptions
-		enhancedSystemPrompt := s.promptService.BuildSystemPrompt(ctx, session.Agent.SystemPrompt, availableTools)
-
-		contextMessages, err := strategy.BuildContext(
-			ctx,
-			enhancedSystemPrompt,
-			"",
-			conversationMessages,
-			session.ContextConfig,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to build context: %w", err)
+		// Get LLM provider
+		provider, exists := s.llmRegistry.Get(session.Agent.Provider)
+		if !exists {
+			return nil, fmt.Errorf("%w: unsupported provider %s", ErrProviderUnavailable, session.Agent.Provider)
 		}
 
 		// Get tool definitions if tools are available
 		var toolDefinitions []models.ToolDefinition
-		if len(availableTools) > 0 && req.ToolChoice != "none" {
-			toolDefinitions, err = s.toolService.GetToolDefinitions(ctx, availableTools)
+		if len(availableTools) > 0 && choice.Mode != models.ToolChoiceNone {
+			toolDefinitions, err = s.toolService.GetAgentToolDefinitions(ctx, &session.Agent, availableTools)
 			if err != nil {
 				s.logger.Error("Failed to get tool definitions", "error", err)
 				// Continue without tools
 			}
 		}
+		var toolChoice interface{} = req.ToolChoice
+		choiceInstructions := ""
+		forced := choice.Forces() && len(allToolCalls) == 0
+		if forced {
+			toolChoice, toolDefinitions, choiceInstructions = forcedToolRequest(choice, llm.ForcesToolChoice(provider), toolDefinitions)
+		} else if choice.Forces() {
+			toolChoice = models.ToolChoiceAuto
+		}
+
+		// Generate dynamic system prompt with tool descriptions
+		toolPrompt := s.promptService.BuildToolPrompt(ctx, session.Agent.ToolPrompt, systemPrompt, availableTools)
+		enhancedSystemPrompt := toolPrompt + choiceInstructions
+		toolPrompt = strings.TrimPrefix(toolPrompt, systemPrompt)
+		if session.Agent.Grounded {
+			enhancedSystemPrompt += groundingInstructions
+		}
+
+		// Build context using strategy with dynamic prompt
+		contextMessages, iterationTrace, err := s.buildContext(ctx, session, enhancedSystemPrompt, conversationMessages, messages)
+		if err != nil {
+			return nil, err
+		}
+		trace = iterationTrace
+		trace.TurnID = userMessage.ID
+		trace.SessionID = session.ID
+
+		// Tools go into a copy, since they change between iterations
+		options := make(map[string]interface{}, len(session.Agent.Config)+2)
+		for k, v := range session.Agent.Config {
+			options[k] = v
+		}
 
 		// Prepare LLM request
 		llmRequest := &llm.ChatRequest{
@@ -476,7 +1063,7 @@ func (s *ChatService) processWithToolCalls(
 			Temperature: session.Agent.Temperature,
 			MaxTokens:   session.Agent.MaxTokens,
 			Stream:      req.Stream,
-			Options:     session.Agent.Config,
+			Options:     options,
 		}
 
 		// Override with request-specific parameters
@@ -486,29 +1073,31 @@ func (s *ChatService) processWithToolCalls(
 		if req.MaxTokens != nil {
 			llmRequest.MaxTokens = *req.MaxTokens
 		}
-
-		// Get LLM provider
-		provider, exists := s.llmRegistry.Get(session.Agent.Provider)
-		if !exists {
-			return nil, fmt.Errorf("unsupported LLM provider: %s", session.Agent.Provider)
-		}
+		llmRequest.Stop = req.Stop
 
 		// Check if provider is available
 		if !provider.IsAvailable(ctx) {
-			return nil, fmt.Errorf("LLM provider %s is not available", session.Agent.Provider)
+			return nil, fmt.Errorf("%w: %s", ErrProviderUnavailable, session.Agent.Provider)
 		}
 
 		// Add tools to LLM request
 		if len(toolDefinitions) > 0 {
 			llmRequest.Options["tools"] = toolDefinitions
-			llmRequest.Options["tool_choice"] = req.ToolChoice
+			llmRequest.Options["tool_choice"] = toolChoice
 		}
 
 		// Call LLM provider
+		emit(ToolChatEvent{Type: EventThinking, Iteration: iteration + 1})
+		start := time.Now()
 		llmResponse, err := provider.Chat(ctx, llmRequest)
 		if err != nil {
-			return nil, fmt.Errorf("LLM request failed: %w", err)
+			return nil, s.providerError(&session.Agent, err)
 		}
+		recordLatency(llmResponse, start)
+		s.recordCost(llmResponse.Metadata, &session.Agent, llmResponse.Usage)
+		s.recordContextUsage(ctx, llmResponse.Metadata, &session.Agent, llmResponse.Usage, llmRequest.Messages)
+		trace.Tokens = promptBreakdown(llmRequest.Messages, toolPrompt, toolDefinitions)
+		recordPromptBreakdown(llmResponse.Metadata, &session.Agent, trace.Tokens)
 
 		// Check if the response contains tool calls
 		toolCalls, err := s.parseToolCallsFromResponse(llmResponse.Content, llmResponse.Metadata)
@@ -517,68 +1106,211 @@ func (s *ChatService) processWithToolCalls(
 			toolCalls = nil // Continue without tool calls
 		}
 
+		// Ask once more when the model skipped a forced call; a second miss fails the turn
+		if forced && !toolChoiceMet(choice, toolCalls) {
+			if choiceRetried || iteration == maxIterations-1 {
+				return nil, fmt.Errorf("%w: tool_choice %s", ErrToolChoiceUnmet, choice)
+			}
+			choiceRetried = true
+			s.logger.Info("Reply without the forced tool call, retrying", "session_id", session.ID, "tool_choice", choice.String())
+			conversationMessages = append(conversationMessages,
+				&models.Message{SessionID: session.ID, Role: "assistant", Content: llmResponse.Content},
+				&models.Message{SessionID: session.ID, Role: "system", Content: toolChoiceCorrection(choice)})
+			continue
+		}
+
 		// If no tool calls, this is the final response
 		if len(toolCalls) == 0 {
-			// Save assistant message
-			assistantMessage, err := s.saveAssistantMessage(ctx, session.ID, llmResponse, len(contextMessages), session.ContextStrategy, len(toolDefinitions) > 0)
+			// A blank answer or canned refusal is asked once more
+			if reason := reaskReason(session.Agent.Reask, llmResponse.Content); reason != "" && reasked == "" && iteration < maxIterations-1 {
+				reasked = reason
+				s.logger.Info("Re-asking after unusable reply", "session_id", session.ID, "reason", reason)
+				conversationMessages = append(conversationMessages,
+					&models.Message{SessionID: session.ID, Role: "system", Content: reaskInstruction(session.Agent.Reask)})
+				continue
+			}
+			s.limitResponse(llmResponse)
+			grounding := ""
+			if session.Agent.Grounded {
+				var exempt bool
+				llmResponse.Content, exempt = cutGroundingExempt(llmResponse.Content)
+				switch {
+				case exempt:
+					grounding = GroundingNotNeeded
+				case len(sources.cite(llmResponse.Content)) > 0:
+					grounding = GroundingCited
+				case !groundingRetried && iteration < maxIterations-1:
+					// Ask once more, with the unsaved answer and a correction
+					groundingRetried = true
+					s.logger.Info("Answer without tool evidence, retrying", "session_id", session.ID)
+					conversationMessages = append(conversationMessages,
+						&models.Message{SessionID: session.ID, Role: "assistant", Content: llmResponse.Content},
+						&models.Message{SessionID: session.ID, Role: "system", Content: groundingCorrection})
+					continue
+				default:
+					grounding = GroundingUngrounded
+				}
+			}
+
+			if llmResponse.Metadata == nil {
+				llmResponse.Metadata = make(map[string]interface{})
+			}
+			llmResponse.Content = s.moderateOutput(ctx, &session.Agent, llmResponse.Content, llmResponse.Metadata)
+			var attachments []models.Attachment
+			llmResponse.Content, attachments = s.postProcess(&session.Agent, llmResponse.Content, llmResponse.Metadata)
+
+			citations := sources.cite(llmResponse.Content)
+			if len(citations) > 0 {
+				llmResponse.Metadata["citations"] = citations
+			}
+			if grounding != "" {
+				llmResponse.Metadata["grounding"] = grounding
+				if groundingRetried {
+					llmResponse.Metadata["grounding_retried"] = true
+				}
+			}
+			if reasked != "" {
+				llmResponse.Metadata["reask"] = reaskMetadata(session.Agent.Reask, reasked, llmResponse.Content)
+			}
+			if toolSelection != nil {
+				llmResponse.Metadata["tool_selection"] = toolSelection
+			}
+			recordFeatureFlags(ctx, llmResponse.Metadata)
+
+			// Save the final answer and close the turn atomically
+			stored := *llmResponse
+			stored.Content = s.scrubMessage(ctx, &session.Agent, llmResponse.Content)
+			var assistantMessage *models.Message
+			err := s.repo.WithTx(ctx, func(tx storage.Repository) error {
+				var err error
+				assistantMessage, err = s.saveAssistantMessage(ctx, tx, session.ID, userMessage.ID, &stored, len(contextMessages), session.ContextStrategy, len(toolDefinitions) > 0)
+				if err != nil {
+					return err
+				}
+				return tx.Message().UpdateTurnStatus(ctx, userMessage.ID, models.MessageStatusComplete)
+			})
 			if err != nil {
 				return nil, fmt.Errorf("failed to save assistant message: %w", err)
 			}
+			s.RecordActivity(ctx, assistantMessage)
 
 			// Prepare final response
+			responseMetadata := assistantMessage.Metadata
+			if wantsContextTrace(ctx) {
+				responseMetadata = withMetadata(responseMetadata, "context_trace", trace)
+			}
+			response := postprocess.Render(llmResponse.Content, format)
+			if format != postprocess.FormatMarkdown {
+				// Offsets of citations refer to the rendered response
+				citations = sources.cite(response)
+			}
 			return &models.EnhancedChatResponse{
 				UserMessageID:      userMessage.ID,
 				AssistantMessageID: assistantMessage.ID,
-				Response:           llmResponse.Content,
+				Response:           response,
+				Format:             format,
+				Attachments:        attachments,
 				ToolCalls:          allToolCalls,
-				Metadata:           assistantMessage.Metadata,
+				Metadata:           responseMetadata,
 				FinishReason:       getFinishReason(llmResponse, len(toolCalls) > 0),
+				Citations:          citations,
+				Artifacts:          turnArtifacts(allToolCalls),
 			}, nil
 		}
 
 		// Execute tool calls
 		s.logger.Info("Executing tool calls", "count", len(toolCalls), "session_id", session.ID)
-		toolResults, err := s.toolService.ExecuteToolCalls(ctx, session.ID, toolCalls)
-		if err != nil {
-			return nil, fmt.Errorf("failed to execute tool calls: %w", err)
+		toolResults := make([]models.ToolCallResult, 0, len(toolCalls))
+		for _, toolCall := range toolCalls {
+			emit(ToolChatEvent{
+				Type:       EventToolCallStarted,
+				Iteration:  iteration + 1,
+				ToolCallID: toolCall.ID,
+				ToolName:   toolCall.Function.Name,
+			})
+			results, err := s.toolService.ExecuteToolCalls(ctx, session.ID, []models.LLMToolCall{toolCall})
+			if err != nil {
+				return nil, fmt.Errorf("failed to execute tool calls: %w", err)
+			}
+			emit(toolCallFinishedEvent(iteration+1, results[0]))
+			toolResults = append(toolResults, results[0])
 		}
 
 		// Add tool results to the conversation
 		allToolCalls = append(allToolCalls, toolResults...)
 
-		// Save assistant message with tool calls
-		assistantMessage, err := s.saveAssistantMessageWithToolCalls(ctx, session.ID, llmResponse, toolCalls, toolResults, len(contextMessages), session.ContextStrategy)
-		if err != nil {
-			return nil, fmt.Errorf("failed to save assistant message with tool calls: %w", err)
+		// Number the citable results, so that the answer can cite them
+		toolResultMessages := s.toolService.CreateToolResultMessages(toolResults)
+		sourceNumbers := make([]int, len(toolResultMessages))
+		for i := range toolResultMessages {
+			sourceNumbers[i] = sources.add(toolResults[i], s.redactor.String(toolCallURL(toolCalls[i])))
+			if sourceNumbers[i] > 0 {
+				toolResultMessages[i].Content = withSource(toolResultMessages[i].Content, sourceNumbers[i])
+			}
 		}
 
-		// Add assistant message to conversation
-		conversationMessages = append(conversationMessages, assistantMessage)
-
-		// Create tool result messages and add them to conversation
-		toolMessages := s.toolService.CreateToolResultMessages(toolResults)
-		for _, toolMsg := range toolMessages {
-			toolMessage := &models.Message{
-				SessionID: session.ID,
-				Role:      "tool",
-				Content:   toolMsg.Content,
-				Metadata: models.JSON(map[string]interface{}{
-					"tool_call_id": toolMsg.ToolCallID,
-					"tool_result":  true,
-				}),
+		// Persist the assistant message, its tool calls and the tool results in
+		// one transaction so a crash never leaves tool calls without results
+		storedResponse, storedCalls, storedResults := s.storedToolCalls(ctx, &session.Agent, llmResponse, toolCalls, toolResults)
+		// Scrubbing may call out to a PII service, so it is done before the
+		// transaction, which blocks other writes while it runs
+		redactedContents := make([]string, len(toolResultMessages))
+		storedContents := make([]string, len(toolResultMessages))
+		for i, toolMsg := range toolResultMessages {
+			redactedContents[i] = s.redactor.JSON(toolMsg.Content)
+			storedContents[i] = s.scrubMessage(ctx, &session.Agent, redactedContents[i])
+		}
+		var assistantMessage *models.Message
+		var savedToolMessages []*models.Message
+		err = s.repo.WithTx(ctx, func(tx storage.Repository) error {
+			var err error
+			assistantMessage, err = s.saveAssistantMessageWithToolCalls(ctx, tx, session.ID, userMessage.ID, storedResponse, storedCalls, storedResults, len(contextMessages), session.ContextStrategy)
+			if err != nil {
+				return err
 			}
 
-			if err := s.repo.Message().Create(ctx, toolMessage); err != nil {
-				s.logger.Error("Failed to save tool message", "error", err)
-				// Continue anyway
-			} else {
-				conversationMessages = append(conversationMessages, toolMessage)
+			savedToolMessages = savedToolMessages[:0]
+			for i, toolMsg := range toolResultMessages {
+				toolMessage := &models.Message{
+					SessionID: session.ID,
+					Role:      "tool",
+					Content:   storedContents[i],
+					Status:    models.MessageStatusPending,
+					TurnID:    userMessage.ID,
+					Metadata: models.JSON(map[string]interface{}{
+						"tool_call_id": toolMsg.ToolCallID,
+						"tool_result":  true,
+					}),
+				}
+				if number := sourceNumbers[i]; number > 0 {
+					toolMessage.Metadata[metadataSource] = number
+					toolMessage.Metadata[metadataSourceTool] = toolResults[i].ToolName
+					if url := sources.byNumber[number].url; url != "" {
+						toolMessage.Metadata[metadataSourceURL] = url
+					}
+				}
+
+				if err := tx.Message().Create(ctx, toolMessage); err != nil {
+					return fmt.Errorf("failed to save tool message: %w", err)
+				}
+				savedToolMessages = append(savedToolMessages, toolMessage)
 			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to save tool call iteration: %w", err)
+		}
+
+		// Add assistant and tool messages to conversation, with the personal
+		// data their stored copies lack
+		conversationMessages = append(conversationMessages, unscrubbed(assistantMessage, llmResponse.Content))
+		for i, toolMessage := range savedToolMessages {
+			conversationMessages = append(conversationMessages, unscrubbed(toolMessage, redactedContents[i]))
 		}
 	}
 
 	// If we exit the loop, return the last response
-	return nil, fmt.Errorf("exceeded maximum tool call iterations")
+	return nil, ErrToolLoopExceeded
 }
 
 // parseToolCallsFromResponse parses tool calls from LLM response
@@ -633,7 +1365,9 @@ func (s *ChatService) parseToolCallsFromResponse(content string, metadata map[st
 // saveAssistantMessage saves the assistant's message to the database
 func (s *ChatService) saveAssistantMessage(
 	ctx context.Context,
+	repo storage.Repository,
 	sessionID string,
+	turnID string,
 	llmResponse *llm.ChatResponse,
 	contextLength int,
 	strategy string,
@@ -650,11 +1384,7 @@ func (s *ChatService) saveAssistantMessage(
 
 	// Add usage info if available
 	if llmResponse.Usage != nil {
-		metadata["usage"] = map[string]interface{}{
-			"prompt_tokens":     llmResponse.Usage.PromptTokens,
-			"completion_tokens": llmResponse.Usage.CompletionTokens,
-			"total_tokens":      llmResponse.Usage.TotalTokens,
-		}
+		metadata["usage"] = usageMetadata(llmResponse.Usage)
 	}
 
 	// Add LLM metadata
@@ -666,10 +1396,11 @@ func (s *ChatService) saveAssistantMessage(
 		SessionID: sessionID,
 		Role:      "assistant",
 		Content:   llmResponse.Content,
-		Metadata:  models.JSON(metadata),
+		Metadata:  models.JSON(s.redactor.Map(metadata)),
+		TurnID:    turnID,
 	}
 
-	if err := s.repo.Message().Create(ctx, assistantMessage); err != nil {
+	if err := repo.Message().Create(ctx, assistantMessage); err != nil {
 		return nil, err
 	}
 
@@ -679,7 +1410,9 @@ func (s *ChatService) saveAssistantMessage(
 // saveAssistantMessageWithToolCalls saves assistant message with tool call information
 func (s *ChatService) saveAssistantMessageWithToolCalls(
 	ctx context.Context,
+	repo storage.Repository,
 	sessionID string,
+	turnID string,
 	llmResponse *llm.ChatResponse,
 	toolCalls []models.LLMToolCall,
 	toolResults []models.ToolCallResult,
@@ -697,11 +1430,7 @@ func (s *ChatService) saveAssistantMessageWithToolCalls(
 
 	// Add usage info if available
 	if llmResponse.Usage != nil {
-		metadata["usage"] = map[string]interface{}{
-			"prompt_tokens":     llmResponse.Usage.PromptTokens,
-			"completion_tokens": llmResponse.Usage.CompletionTokens,
-			"total_tokens":      llmResponse.Usage.TotalTokens,
-		}
+		metadata["usage"] = usageMetadata(llmResponse.Usage)
 	}
 
 	// Add tool call metadata
@@ -728,10 +1457,12 @@ func (s *ChatService) saveAssistantMessageWithToolCalls(
 		SessionID: sessionID,
 		Role:      "assistant",
 		Content:   llmResponse.Content,
-		Metadata:  models.JSON(metadata),
+		Metadata:  models.JSON(s.redactor.Map(metadata)),
+		Status:    models.MessageStatusPending,
+		TurnID:    turnID,
 	}
 
-	if err := s.repo.Message().Create(ctx, assistantMessage); err != nil {
+	if err := repo.Message().Create(ctx, assistantMessage); err != nil {
 		return nil, err
 	}
 
@@ -740,7 +1471,7 @@ func (s *ChatService) saveAssistantMessageWithToolCalls(
 		toolCall := &models.ToolCall{
 			MessageID: assistantMessage.ID,
 			ToolName:  call.Function.Name,
-			Arguments: models.JSON(map[string]interface{}{"raw": call.Function.Arguments}),
+			Arguments: models.JSON(map[string]interface{}{"raw": s.redactor.String(call.Function.Arguments)}),
 			Success:   false,
 			Duration:  0,
 		}
@@ -748,7 +1479,7 @@ func (s *ChatService) saveAssistantMessageWithToolCalls(
 		// Parse arguments
 		var args map[string]interface{}
 		if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err == nil {
-			toolCall.Arguments = models.JSON(args)
+			toolCall.Arguments = models.JSON(s.redactor.Map(args))
 		}
 
 		// Add results if available
@@ -757,16 +1488,18 @@ func (s *ChatService) saveAssistantMessageWithToolCalls(
 			toolCall.Success = result.Success
 			toolCall.Duration = result.Duration
 			if result.Error != "" {
-				toolCall.Error = result.Error
+				toolCall.Error = s.redactor.String(result.Error)
 			}
 			if result.Result != nil {
-				resultJSON := models.JSON(map[string]interface{}{"data": result.Result})
+				resultJSON := models.JSON(map[string]interface{}{"data": s.redactor.Value(result.Result)})
 				toolCall.Result = &resultJSON
 			}
 		}
 
-		// Save tool call (this would require extending the repository interface)
-		// For now, just log it
+		if err := repo.ToolCall().Create(ctx, toolCall); err != nil {
+			return nil, fmt.Errorf("failed to save tool call: %w", err)
+		}
+
 		s.logger.Info("Tool call saved",
 			"message_id", assistantMessage.ID,
 			"tool_name", call.Function.Name,
@@ -776,6 +1509,147 @@ func (s *ChatService) saveAssistantMessageWithToolCalls(
 	return assistantMessage, nil
 }
 
+// usageMetadata formats token usage for message metadata
+func usageMetadata(usage *llm.Usage) map[string]interface{} {
+	return map[string]interface{}{
+		"prompt_tokens":     usage.PromptTokens,
+		"completion_tokens": usage.CompletionTokens,
+		"total_tokens":      usage.TotalTokens,
+	}
+}
+
+// recordCost stores the priced token usage of a reply in its metadata
+func (s *ChatService) recordCost(metadata map[string]interface{}, agent *models.Agent, usage *llm.Usage) {
+	if cost, ok := s.accounting.LLMCost(agent, usage); ok {
+		metadata["cost"] = cost
+	}
+}
+
+// limitResponse cuts a reply longer than the maximum response length and
+// marks it finished by length
+func (s *ChatService) limitResponse(response *llm.ChatResponse) {
+	if s.maxResponse <= 0 {
+		return
+	}
+	content, truncated := truncateChars(response.Content, s.maxResponse)
+	if !truncated {
+		return
+	}
+	response.Content = content
+	response.FinishReason = "length"
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["truncated"] = true
+}
+
+// truncateChars returns the first max characters of s and whether s was
+// longer
+func truncateChars(s string, max int) (string, bool) {
+	if max < 0 {
+		max = 0
+	}
+	if len(s) <= max {
+		return s, false
+	}
+	for i := range s {
+		if max == 0 {
+			return s[:i], true
+		}
+		max--
+	}
+	return s, false
+}
+
+// recordLatency stores the duration of an LLM call in the response metadata
+// so it is persisted with the assistant message
+func recordLatency(response *llm.ChatResponse, start time.Time) {
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["latency_ms"] = time.Since(start).Milliseconds()
+}
+
+// RecordActivity updates the activity summary of a message's session: when
+// and by whom it was last answered or written to, and the number of unread
+// replies. Only visible user and assistant messages count. Failures are
+// logged, since the message itself is already saved.
+func (s *ChatService) RecordActivity(ctx context.Context, message *models.Message) {
+	if message.Hidden || (message.Role != "user" && message.Role != "assistant") {
+		return
+	}
+	at := message.CreatedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	// A user message means the user has seen the session
+	unread := message.Role == "assistant"
+	if err := s.repo.Session().RecordActivity(context.WithoutCancel(ctx), message.SessionID, message.Role, at, unread); err != nil {
+		s.logger.Warn("Failed to record session activity", "session_id", message.SessionID, "error", err)
+	}
+}
+
+// markTurnIncomplete flags a failed turn so clients can retry it
+func (s *ChatService) markTurnIncomplete(ctx context.Context, turnID string) {
+	// The request context may already be cancelled at this point
+	if err := s.repo.Message().UpdateTurnStatus(context.WithoutCancel(ctx), turnID, models.MessageStatusIncomplete); err != nil {
+		s.logger.Error("Failed to mark turn incomplete", "turn_id", turnID, "error", err)
+	}
+}
+
+// RecoverIncompleteTurns marks tool-calling turns left pending by a crash as
+// incomplete. It is meant to run once at startup, before requests are served.
+func RecoverIncompleteTurns(ctx context.Context, repo storage.Repository) (int, error) {
+	pending, err := repo.Message().ListByStatus(ctx, models.MessageStatusPending)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pending messages: %w", err)
+	}
+
+	turns := make(map[string]bool)
+	for _, message := range pending {
+		turnID := message.TurnID
+		if turnID == "" {
+			turnID = message.ID
+		}
+		if turns[turnID] {
+			continue
+		}
+		turns[turnID] = true
+
+		if err := repo.Message().UpdateTurnStatus(ctx, turnID, models.MessageStatusIncomplete); err != nil {
+			return 0, fmt.Errorf("failed to mark turn %s incomplete: %w", turnID, err)
+		}
+	}
+
+	return len(turns), nil
+}
+
+// checkSessionAvailable rejects chats on archived sessions and disabled agents
+func checkSessionAvailable(session *models.ChatSession) error {
+	if session.IsArchived() {
+		return ErrSessionArchived
+	}
+	if !session.Agent.Enabled {
+		if session.Agent.MaintenanceMessage != "" {
+			return fmt.Errorf("%w: %s", ErrAgentDisabled, session.Agent.MaintenanceMessage)
+		}
+		return ErrAgentDisabled
+	}
+	return nil
+}
+
+// completedMessages drops messages from incomplete turns so they don't leak
+// dangling tool calls into the LLM context
+func completedMessages(messages []*models.Message) []*models.Message {
+	filtered := make([]*models.Message, 0, len(messages))
+	for _, message := range messages {
+		if message.Status != models.MessageStatusIncomplete {
+			filtered = append(filtered, message)
+		}
+	}
+	return filtered
+}
+
 // getFinishReason determines the finish reason for the response
 func getFinishReason(llmResponse *llm.ChatResponse, hasToolCalls bool) string {
 	if hasToolCalls {
@@ -785,4 +1659,15 @@ func getFinishReason(llmResponse *llm.ChatResponse, hasToolCalls bool) string {
 		return llmResponse.FinishReason
 	}
 	return "stop"
-}
\ No newline at end of file
+}
+
+// withMetadata returns a copy of metadata with key set to value, leaving
+// the original, which may be a request's, unchanged
+func withMetadata(metadata models.JSON, key string, value interface{}) models.JSON {
+	copied := make(models.JSON, len(metadata)+1)
+	for k, v := range metadata {
+		copied[k] = v
+	}
+	copied[key] = value
+	return copied
+}
@@ -0,0 +1,486 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/llm/mock"
+	"agent-server/internal/models"
+	"agent-server/internal/moderation"
+	"agent-server/internal/postprocess"
+	"agent-server/internal/services"
+	"agent-server/internal/storage"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverIncompleteTurns(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "recovery", Provider: "ollama", Model: "llama3"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	// A finished turn and a turn interrupted after its first tool iteration
+	done := &models.Message{SessionID: session.ID, Role: "user", Content: "hi"}
+	require.NoError(t, repo.Message().Create(ctx, done))
+
+	interrupted := &models.Message{SessionID: session.ID, Role: "user", Content: "weather?", Status: models.MessageStatusPending}
+	require.NoError(t, repo.Message().Create(ctx, interrupted))
+	toolMessage := &models.Message{SessionID: session.ID, Role: "tool", Content: "{}", Status: models.MessageStatusPending, TurnID: interrupted.ID}
+	require.NoError(t, repo.Message().Create(ctx, toolMessage))
+
+	recovered, err := services.RecoverIncompleteTurns(ctx, repo)
+	require.NoError(t, err)
+	assert.Equal(t, 1, recovered)
+
+	for id, want := range map[string]string{
+		done.ID:        models.MessageStatusComplete,
+		interrupted.ID: models.MessageStatusIncomplete,
+		toolMessage.ID: models.MessageStatusIncomplete,
+	} {
+		message, err := repo.Message().GetByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, want, message.Status)
+	}
+
+	// A second pass finds nothing left to recover
+	recovered, err = services.RecoverIncompleteTurns(ctx, repo)
+	require.NoError(t, err)
+	assert.Equal(t, 0, recovered)
+}
+
+func TestRepositoryWithTx_RollsBack(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "tx", Provider: "ollama", Model: "llama3"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	errAbort := errors.New("abort")
+	err = repo.WithTx(ctx, func(tx storage.Repository) error {
+		message := &models.Message{SessionID: session.ID, Role: "assistant", Content: "partial"}
+		if err := tx.Message().Create(ctx, message); err != nil {
+			return err
+		}
+		if err := tx.ToolCall().Create(ctx, &models.ToolCall{MessageID: message.ID, ToolName: "calculator"}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+
+	_, total, err := repo.Message().ListBySessionID(ctx, session.ID, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), total)
+}
+
+func TestChatService_RejectsDisabledAgent(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "offline", Provider: "ollama", Model: "llama3", Enabled: true}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	agent.Enabled = false
+	agent.MaintenanceMessage = "Back after the model upgrade"
+	require.NoError(t, repo.Agent().Update(ctx, agent))
+
+	service := services.NewChatService(repo, llm.NewRegistry(), nil, nil, nil, slog.Default())
+
+	_, err = service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "hello"})
+	assert.ErrorIs(t, err, services.ErrAgentDisabled)
+	assert.Contains(t, err.Error(), "Back after the model upgrade")
+
+	_, err = service.Stream(ctx, &services.ChatRequest{SessionID: session.ID, Message: "hello"})
+	assert.ErrorIs(t, err, services.ErrAgentDisabled)
+
+	// History stays readable
+	_, _, err = repo.Message().ListBySessionID(ctx, session.ID, 10, 0)
+	assert.NoError(t, err)
+}
+
+func TestChatService_StreamRecordsUsageAndFinishReason(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "stream", Provider: "ollama", Model: "llama3", Enabled: true}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	registry := llm.NewRegistry()
+	registry.Register(&scriptedProvider{chunks: []llm.StreamChunk{
+		{Content: "Hel"},
+		{Content: "lo", Done: true, FinishReason: "length", Usage: &llm.Usage{PromptTokens: 12, CompletionTokens: 2, TotalTokens: 14}},
+	}})
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+
+	chunks, err := service.Stream(ctx, &services.ChatRequest{SessionID: session.ID, Message: "hi"})
+	require.NoError(t, err)
+	var final services.StreamChunk
+	var done int
+	for chunk := range chunks {
+		if chunk.Done {
+			final = chunk
+			done++
+		}
+	}
+	assert.Equal(t, 1, done, "only the final chunk is marked done")
+	require.NotEmpty(t, final.MessageID)
+	assert.Equal(t, "length", final.FinishReason)
+	assert.Equal(t, 14, final.Usage.TotalTokens)
+	messageID := final.MessageID
+
+	message, err := repo.Message().GetByID(ctx, messageID)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", message.Content)
+	usage, ok := message.Metadata["usage"].(map[string]interface{})
+	require.True(t, ok)
+	assert.EqualValues(t, 12, usage["prompt_tokens"])
+	assert.EqualValues(t, 14, usage["total_tokens"])
+	assert.Equal(t, "length", message.Metadata["finish_reason"])
+}
+
+func TestChatService_StreamContextIncludesUserMessageOnce(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "stream", Provider: "ollama", Model: "llama3", Enabled: true, SystemPrompt: "Be brief"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{chunks: []llm.StreamChunk{{Content: "Hi", Done: true}}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+
+	for _, message := range []string{"first", "second"} {
+		chunks, err := service.Stream(ctx, &services.ChatRequest{SessionID: session.ID, Message: message})
+		require.NoError(t, err)
+		for range chunks {
+		}
+	}
+
+	require.Len(t, provider.requests, 2)
+	var roles, contents []string
+	for _, message := range provider.requests[1].Messages {
+		roles = append(roles, message.Role)
+		contents = append(contents, message.Content)
+	}
+	assert.Equal(t, []string{"system", "user", "assistant", "user"}, roles)
+	assert.Equal(t, []string{"Be brief", "first", "Hi", "second"}, contents)
+
+	// Every user message is saved ahead of its reply
+	messages, _, err := repo.Message().ListBySessionID(ctx, session.ID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+	for i, role := range []string{"user", "assistant", "user", "assistant"} {
+		assert.Equal(t, role, messages[i].Role)
+		assert.Equal(t, models.MessageStatusComplete, messages[i].Status)
+	}
+	assert.Equal(t, messages[2].ID, messages[3].TurnID)
+}
+
+func TestChatService_MaxResponseLength(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "chatty", Provider: mock.Name, Model: "mock", Enabled: true}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	registry := llm.NewRegistry()
+	registry.Register(mock.NewProvider(mock.Options{ReplyWords: 40}))
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+	service.SetMaxResponseLength(14)
+
+	// Streams end once the limit is reached
+	chunks, err := service.Stream(ctx, &services.ChatRequest{SessionID: session.ID, Message: "hi"})
+	require.NoError(t, err)
+	var content string
+	var final services.StreamChunk
+	for chunk := range chunks {
+		content += chunk.Content
+		if chunk.Done {
+			final = chunk
+		}
+	}
+	assert.Equal(t, "Mock reply: th", content)
+	assert.Equal(t, "length", final.FinishReason)
+	message, err := repo.Message().GetByID(ctx, final.MessageID)
+	require.NoError(t, err)
+	assert.Equal(t, "Mock reply: th", message.Content)
+	assert.Equal(t, true, message.Metadata["truncated"])
+	assert.Equal(t, "length", message.Metadata["finish_reason"])
+
+	response, err := service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "Mock reply: th", response.Response)
+	assert.Equal(t, "length", response.Metadata["finish_reason"])
+
+	// Stop sequences reach the provider
+	response, err = service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "hi", Stop: []string{"reply"}})
+	require.NoError(t, err)
+	assert.Equal(t, "Mock", response.Response)
+	assert.Equal(t, "stop", response.Metadata["finish_reason"])
+}
+
+func TestChatService_PostProcessing(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "coder", Provider: "ollama", Model: "llama3", Enabled: true}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+	raw := "<think>Print it.</think>Assistant: Use **this**:\n```go\nfmt.Println(1)\n```<|im_end|>"
+	provider := &scriptedProvider{
+		responses: []*llm.ChatResponse{{Content: raw}, {Content: raw}},
+		chunks:    []llm.StreamChunk{{Content: raw, Done: true}},
+	}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+	pipeline, err := postprocess.New(postprocess.Steps)
+	require.NoError(t, err)
+	service.SetPostProcessing(pipeline)
+
+	// The cleaned markdown is saved and returned in the accepted format
+	response, err := service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "hi", Accept: "text/markdown;q=0.5, text/html"})
+	require.NoError(t, err)
+	assert.Equal(t, postprocess.FormatHTML, response.Format)
+	assert.Equal(t, "<p>Use <strong>this</strong>:</p>\n<pre><code class=\"language-go\">fmt.Println(1)</code></pre>", response.Response)
+	code := []models.Attachment{{Type: models.AttachmentCode, Language: "go", Content: "fmt.Println(1)"}}
+	assert.Equal(t, code, response.Attachments)
+	message, err := repo.Message().GetByID(ctx, response.AssistantMessageID)
+	require.NoError(t, err)
+	assert.Equal(t, "Use **this**:\n```go\nfmt.Println(1)\n```", message.Content)
+	assert.NotEmpty(t, message.Metadata["attachments"])
+
+	// Formats that cannot be returned fail before the provider is asked
+	_, err = service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "hi", Accept: "application/json"})
+	assert.ErrorIs(t, err, postprocess.ErrNotAcceptable)
+	_, err = service.Stream(ctx, &services.ChatRequest{SessionID: session.ID, Message: "hi", Accept: "text/html"})
+	assert.ErrorIs(t, err, postprocess.ErrNotAcceptable)
+	assert.Len(t, provider.requests, 1)
+
+	// Streamed replies are saved processed; the final chunk carries the attachments
+	chunks, err := service.Stream(ctx, &services.ChatRequest{SessionID: session.ID, Message: "hi", Accept: "text/*"})
+	require.NoError(t, err)
+	var final services.StreamChunk
+	for chunk := range chunks {
+		if chunk.Done {
+			final = chunk
+		}
+	}
+	assert.Equal(t, code, final.Metadata["attachments"])
+	message, err = repo.Message().GetByID(ctx, final.MessageID)
+	require.NoError(t, err)
+	assert.Equal(t, "Use **this**:\n```go\nfmt.Println(1)\n```", message.Content)
+
+	// An empty "postprocess" entry turns post-processing off for the agent
+	agent.Config = models.JSON{"postprocess": []interface{}{}}
+	require.NoError(t, repo.Agent().Update(ctx, agent))
+	response, err = service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, raw, response.Response)
+	assert.Empty(t, response.Attachments)
+}
+
+func TestChatService_NotesReachTheModel(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "steered", Provider: "ollama", Model: "llama3", Enabled: true}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{responses: []*llm.ChatResponse{{Content: "Welcome to premium!"}}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+
+	note, err := service.AddNote(ctx, session.ID, &models.CreateNoteRequest{Content: "The user has upgraded to premium"})
+	require.NoError(t, err)
+	assert.True(t, note.Hidden)
+
+	_, err = service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "What changed?"})
+	require.NoError(t, err)
+	require.Len(t, provider.requests, 1)
+	messages := provider.requests[0].Messages
+	require.Len(t, messages, 3)
+	assert.Equal(t, llm.ChatMessage{Role: "system", Content: "The user has upgraded to premium"}, messages[1])
+
+	_, err = service.AddNote(ctx, "missing", &models.CreateNoteRequest{Content: "x"})
+	assert.ErrorIs(t, err, services.ErrSessionNotFound)
+}
+
+func TestChatService_RecordsSessionActivity(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "inbox", Provider: "ollama", Model: "llama3", Enabled: true}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{responses: []*llm.ChatResponse{{Content: "Hello!"}}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+
+	response, err := service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "Hi"})
+	require.NoError(t, err)
+	reply, err := repo.Message().GetByID(ctx, response.AssistantMessageID)
+	require.NoError(t, err)
+
+	saved, err := repo.Session().GetByID(ctx, session.ID)
+	require.NoError(t, err)
+	require.NotNil(t, saved.LastMessageAt)
+	assert.WithinDuration(t, reply.CreatedAt, *saved.LastMessageAt, time.Millisecond)
+	assert.Equal(t, "assistant", saved.LastMessageRole)
+	assert.Equal(t, 1, saved.UnreadCount)
+
+	// Hidden notes are not activity, user messages mark the session seen
+	_, err = service.AddNote(ctx, session.ID, &models.CreateNoteRequest{Content: "Be brief"})
+	require.NoError(t, err)
+	service.RecordActivity(ctx, &models.Message{SessionID: session.ID, Role: "user", Content: "Thanks"})
+	saved, err = repo.Session().GetByID(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "user", saved.LastMessageRole)
+	assert.Zero(t, saved.UnreadCount)
+}
+
+func TestChatService_Localization(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{
+		Name: "multilingual", Provider: "ollama", Model: "llama3", Enabled: true, SystemPrompt: "You are helpful.",
+		LocalizedPrompts: models.StringMap{"de": "Du bist hilfsbereit."},
+	}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{responses: []*llm.ChatResponse{{Content: "Gut."}, {Content: "Ja."}, {Content: "Bien."}}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+	systemPrompt := func(i int) string {
+		require.Greater(t, len(provider.requests), i)
+		return provider.requests[i].Messages[0].Content
+	}
+
+	// The detected language picks the prompt variant and sticks to the session
+	response, err := service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "Wie ist das Wetter heute?"})
+	require.NoError(t, err)
+	assert.Equal(t, "Du bist hilfsbereit.", systemPrompt(0))
+	user, err := repo.Message().GetByID(ctx, response.UserMessageID)
+	require.NoError(t, err)
+	assert.Equal(t, "de", user.Metadata["language"])
+	stored, err := repo.Session().GetByID(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "de", stored.Language)
+
+	// Messages too short to tell keep the session's language
+	_, err = service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "ok"})
+	require.NoError(t, err)
+	assert.Equal(t, "Du bist hilfsbereit.", systemPrompt(1))
+
+	// An explicit response language wins
+	_, err = service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "Und morgen?", ResponseLanguage: "fr"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(systemPrompt(2), "You are helpful."))
+	assert.Contains(t, systemPrompt(2), "Always respond in French")
+}
+
+func TestChatService_Moderation(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{
+		Name: "moderated", Provider: "ollama", Model: "llama3", Enabled: true, SystemPrompt: "You are helpful.",
+		Moderation: &models.AgentModeration{Input: true, Output: true},
+	}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{responses: []*llm.ChatResponse{{Content: "Oh, shut up."}, {Content: "Shut up, you idiot."}}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+	service.SetModeration(moderation.NewKeywords(moderation.DefaultKeywords), moderation.Policy{
+		Thresholds: moderation.Thresholds{Flag: 0.5, Block: 0.8},
+	})
+
+	// A blocked message is saved for the record, but never reaches the model
+	_, err = service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "I will murder them and blow up the house"})
+	require.ErrorIs(t, err, services.ErrContentBlocked)
+	assert.Empty(t, provider.requests)
+	messages, _, err := repo.Message().ListBySessionID(ctx, session.ID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, models.MessageStatusIncomplete, messages[0].Status)
+	verdict, ok := messages[0].Metadata["moderation"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, moderation.ActionBlock, verdict["action"])
+	assert.Equal(t, []interface{}{"violence"}, verdict["categories"])
+
+	// Flagged replies are kept, blocked ones withheld
+	response, err := service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "Tell me a joke"})
+	require.NoError(t, err)
+	assert.Equal(t, "Oh, shut up.", response.Response)
+	assert.Equal(t, moderation.ActionFlag, response.Metadata["moderation"].(*moderation.Verdict).Action)
+	require.Len(t, provider.requests, 1)
+	assert.Len(t, provider.requests[0].Messages, 2) // system prompt and this message
+
+	response, err = service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "Another one"})
+	require.NoError(t, err)
+	assert.Equal(t, "This reply was withheld by content moderation.", response.Response)
+	saved, err := repo.Message().GetByID(ctx, response.AssistantMessageID)
+	require.NoError(t, err)
+	assert.Equal(t, response.Response, saved.Content)
+	assert.Equal(t, moderation.ActionBlock, saved.Metadata["moderation"].(map[string]interface{})["action"])
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// maxTrackedOutcomes bounds the per-agent history kept for error rates
+const maxTrackedOutcomes = 200
+
+// ChatStats summarizes recent chat outcomes for an agent. Stats are kept in
+// memory and reset when the server restarts.
+type ChatStats struct {
+	Requests      int        `json:"requests"`
+	Errors        int        `json:"errors"`
+	ErrorRate     float64    `json:"error_rate"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+type chatOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+type agentOutcomes struct {
+	recent      []chatOutcome
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastError   string
+}
+
+// chatOutcomeTracker records the result of every chat per agent
+type chatOutcomeTracker struct {
+	mu      sync.Mutex
+	byAgent map[string]*agentOutcomes
+}
+
+func newChatOutcomeTracker() *chatOutcomeTracker {
+	return &chatOutcomeTracker{byAgent: make(map[string]*agentOutcomes)}
+}
+
+// record stores a chat result; a nil err counts as a success
+func (t *chatOutcomeTracker) record(agentID string, err error) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	outcomes, exists := t.byAgent[agentID]
+	if !exists {
+		outcomes = &agentOutcomes{}
+		t.byAgent[agentID] = outcomes
+	}
+
+	outcomes.recent = append(outcomes.recent, chatOutcome{at: now, failed: err != nil})
+	if len(outcomes.recent) > maxTrackedOutcomes {
+		outcomes.recent = outcomes.recent[len(outcomes.recent)-maxTrackedOutcomes:]
+	}
+
+	if err != nil {
+		outcomes.lastFailure = now
+		outcomes.lastError = err.Error()
+	} else {
+		outcomes.lastSuccess = now
+	}
+}
+
+// stats returns the outcomes recorded for an agent within the window
+func (t *chatOutcomeTracker) stats(agentID string, window time.Duration) ChatStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var stats ChatStats
+	outcomes, exists := t.byAgent[agentID]
+	if !exists {
+		return stats
+	}
+
+	since := time.Now().Add(-window)
+	for _, outcome := range outcomes.recent {
+		if outcome.at.Before(since) {
+			continue
+		}
+		stats.Requests++
+		if outcome.failed {
+			stats.Errors++
+		}
+	}
+	if stats.Requests > 0 {
+		stats.ErrorRate = float64(stats.Errors) / float64(stats.Requests)
+	}
+
+	if !outcomes.lastSuccess.IsZero() {
+		lastSuccess := outcomes.lastSuccess
+		stats.LastSuccessAt = &lastSuccess
+	}
+	if !outcomes.lastFailure.IsZero() {
+		lastFailure := outcomes.lastFailure
+		stats.LastErrorAt = &lastFailure
+		stats.LastError = outcomes.lastError
+	}
+
+	return stats
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"agent-server/internal/models"
+)
+
+// citableTools are the tools whose results answers can cite as sources
+var citableTools = map[string]bool{
+	"http_get":         true,
+	"web_scraper":      true,
+	"web_search":       true,
+	"knowledge_search": true,
+}
+
+// Metadata keys of tool messages with a citable result
+const (
+	metadataSource     = "source"
+	metadataSourceTool = "source_tool"
+	metadataSourceURL  = "source_url"
+)
+
+// citationMarker matches the markers models put after cited statements,
+// e.g. [1] or [1, 2]
+var citationMarker = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// citationSource is a tool result that answers can cite by its number
+type citationSource struct {
+	toolCallID string
+	toolName   string
+	url        string
+}
+
+// citationSources numbers the citable tool results of a session. Numbers
+// continue across turns, so that a reply cannot mix up the sources of
+// earlier turns with its own.
+type citationSources struct {
+	byNumber map[int]citationSource
+	last     int
+	all      bool // every tool result is citable, for grounded agents
+}
+
+// newCitationSources collects the numbered sources of a session's messages;
+// with all set, results of every tool can be cited
+func newCitationSources(messages []*models.Message, all bool) *citationSources {
+	sources := &citationSources{byNumber: make(map[int]citationSource), all: all}
+	for _, message := range messages {
+		if message.Role != "tool" {
+			continue
+		}
+		number, ok := sourceNumber(message.Metadata[metadataSource])
+		if !ok {
+			continue
+		}
+		toolCallID, _ := message.Metadata["tool_call_id"].(string)
+		toolName, _ := message.Metadata[metadataSourceTool].(string)
+		url, _ := message.Metadata[metadataSourceURL].(string)
+		sources.byNumber[number] = citationSource{toolCallID: toolCallID, toolName: toolName, url: url}
+		if number > sources.last {
+			sources.last = number
+		}
+	}
+	return sources
+}
+
+// sourceNumber reads a source number from message metadata, where it is a
+// float64 once loaded from the database
+func sourceNumber(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, n > 0
+	case float64:
+		return int(n), n > 0
+	}
+	return 0, false
+}
+
+// add numbers a successful result of a citable tool and returns its number,
+// or 0 when the result cannot be cited
+func (c *citationSources) add(result models.ToolCallResult, url string) int {
+	if !result.Success || !(c.all || citableTools[result.ToolName]) {
+		return 0
+	}
+	c.last++
+	c.byNumber[c.last] = citationSource{toolCallID: result.ID, toolName: result.ToolName, url: url}
+	return c.last
+}
+
+// cite maps the markers in a response to the sources they cite. Each marker
+// cites the sentence before it; markers of unknown sources are ignored.
+func (c *citationSources) cite(response string) []models.Citation {
+	var citations []models.Citation
+	prevEnd, segStart, segEnd := 0, 0, 0
+	for _, loc := range citationMarker.FindAllStringSubmatchIndex(response, -1) {
+		start, end := loc[0], loc[1]
+
+		// Adjacent markers like [1][2] share the segment before the first
+		text := strings.TrimRight(response[prevEnd:start], " \t.!?;:,")
+		if strings.TrimSpace(text) != "" {
+			from := sentenceStart(text)
+			from += len(text[from:]) - len(strings.TrimLeft(text[from:], " \t\n"))
+			segStart, segEnd = prevEnd+from, prevEnd+len(text)
+		}
+		prevEnd = end
+		if segEnd <= segStart {
+			continue
+		}
+
+		for _, field := range strings.Split(response[loc[2]:loc[3]], ",") {
+			number, _ := strconv.Atoi(strings.TrimSpace(field))
+			source, ok := c.byNumber[number]
+			if !ok {
+				continue
+			}
+			citations = append(citations, models.Citation{
+				Marker:     response[start:end],
+				Source:     number,
+				Text:       response[segStart:segEnd],
+				Start:      segStart,
+				End:        segEnd,
+				ToolCallID: source.toolCallID,
+				ToolName:   source.toolName,
+				URL:        source.url,
+			})
+		}
+	}
+	return citations
+}
+
+// sentenceStart returns the offset of the last sentence in s. Periods
+// only end sentences before whitespace, so that numbers like 3.5 stay whole.
+func sentenceStart(s string) int {
+	for i := len(s) - 1; i >= 0; i-- {
+		switch s[i] {
+		case '\n':
+			return i + 1
+		case '.', '!', '?':
+			if i+1 < len(s) && (s[i+1] == ' ' || s[i+1] == '\t' || s[i+1] == '\n') {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// toolCallURL returns the url argument of a tool call, if it has one
+func toolCallURL(call models.LLMToolCall) string {
+	var arguments struct {
+		URL string `json:"url"`
+	}
+	if json.Unmarshal([]byte(call.Function.Arguments), &arguments) != nil {
+		return ""
+	}
+	return arguments.URL
+}
+
+// withSource adds a source number to the JSON content of a tool message, so
+// that the model can cite it
+func withSource(content string, number int) string {
+	var fields map[string]interface{}
+	if json.Unmarshal([]byte(content), &fields) != nil {
+		return content
+	}
+	fields[metadataSource] = number
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return content
+	}
+	return string(data)
+}
+
+// Grounding outcomes, reported as "grounding" in the metadata of answers of
+// grounded agents
+const (
+	GroundingCited      = "cited"
+	GroundingNotNeeded  = "not_needed"
+	GroundingUngrounded = "ungrounded"
+)
+
+// groundingExempt starts answers that need no evidence
+const groundingExempt = "[no sources needed]"
+
+// groundingInstructions are added to the system prompt of grounded agents
+const groundingInstructions = `=== GROUNDING ===
+- Every tool result carries a "source" number
+- Answer factual questions only with facts from tool results, and put the source number in square brackets after each statement that uses them, e.g. "The order shipped on May 3 [1]."
+- Use your tools to look facts up instead of answering from memory
+- If the message needs no facts, e.g. a greeting, start your answer with ` + groundingExempt + `
+
+`
+
+// groundingCorrection asks for another answer when one had no evidence
+const groundingCorrection = `Your last answer did not cite any tool result. Look up the facts with your tools and cite the results by their source number, e.g. [1]. If the message needs no facts, start your answer with ` + groundingExempt + `.`
+
+// cutGroundingExempt removes the exemption marker from the start of an
+// answer and reports whether it was there
+func cutGroundingExempt(content string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(content), groundingExempt)
+	if !ok {
+		return content, false
+	}
+	return strings.TrimSpace(rest), true
+}
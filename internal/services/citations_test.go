@@ -0,0 +1,151 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatService_CitesToolResults(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Paris has 2.1 million inhabitants.")
+	}))
+	defer page.Close()
+
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "research", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{}}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	fetch := func(url string) *llm.ChatResponse {
+		return &llm.ChatResponse{Metadata: map[string]interface{}{"tool_calls": []map[string]interface{}{
+			{"function": map[string]interface{}{"name": "http_get", "arguments": map[string]interface{}{"url": url}}},
+		}}}
+	}
+	provider := &scriptedProvider{responses: []*llm.ChatResponse{
+		fetch(page.URL),
+		{Content: "Paris has about 2.1 million inhabitants [1]. It is the capital of France [9]."},
+		fetch(page.URL + "/again"),
+		{Content: "The population is 2.1 million.[2][1]"},
+	}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	toolService := services.NewToolService(repo, slog.Default())
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+
+	request := &models.EnhancedChatRequest{Message: "How many people live in Paris?", Tools: []string{"http_get"}}
+	response, err := service.ChatWithTools(ctx, request, session.ID)
+	require.NoError(t, err)
+
+	// The model sees the instructions and the numbered result
+	require.Len(t, provider.requests, 2)
+	answerRequest := provider.requests[1].Messages
+	assert.Contains(t, answerRequest[0].Content, "=== CITATIONS ===")
+	assert.Contains(t, answerRequest[len(answerRequest)-1].Content, `"source":1`)
+
+	// Only known sources are cited
+	require.Len(t, response.Citations, 1)
+	citation := response.Citations[0]
+	assert.Equal(t, "[1]", citation.Marker)
+	assert.Equal(t, 1, citation.Source)
+	assert.Equal(t, "Paris has about 2.1 million inhabitants", citation.Text)
+	assert.Equal(t, citation.Text, response.Response[citation.Start:citation.End])
+	assert.Equal(t, response.ToolCalls[0].ID, citation.ToolCallID)
+	assert.Equal(t, "http_get", citation.ToolName)
+	assert.Equal(t, page.URL, citation.URL)
+	assert.NotNil(t, response.Metadata["citations"])
+
+	// Numbers continue in the next turn, and earlier sources stay citable
+	response, err = service.ChatWithTools(ctx, request, session.ID)
+	require.NoError(t, err)
+	require.Len(t, response.Citations, 2)
+	assert.Equal(t, []int{2, 1}, []int{response.Citations[0].Source, response.Citations[1].Source})
+	assert.Equal(t, page.URL+"/again", response.Citations[0].URL)
+	assert.Equal(t, page.URL, response.Citations[1].URL)
+	for _, citation := range response.Citations {
+		assert.Equal(t, "The population is 2.1 million", citation.Text)
+	}
+	assert.True(t, strings.HasPrefix(response.Response, response.Citations[0].Text))
+}
+
+func TestChatService_GroundedAnswers(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "grounded", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{}, Grounded: true}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{responses: []*llm.ChatResponse{
+		{Content: "It is 5."},
+		{Metadata: map[string]interface{}{"tool_calls": []map[string]interface{}{
+			{"function": map[string]interface{}{"name": "calculator", "arguments": map[string]interface{}{"expression": "2+3"}}},
+		}}},
+		{Content: "2+3 is 5 [1]."},
+		{Content: "It is 7."},
+		{Content: "Still 7."},
+		{Content: "[no sources needed] Hello!"},
+	}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	toolService := services.NewToolService(repo, slog.Default())
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+	request := &models.EnhancedChatRequest{Message: "What is 2+3?", Tools: []string{"calculator"}}
+
+	// An answer without evidence is retried with a correction, and any
+	// tool result can be cited
+	response, err := service.ChatWithTools(ctx, request, session.ID)
+	require.NoError(t, err)
+	require.Len(t, provider.requests, 3)
+	assert.Contains(t, provider.requests[0].Messages[0].Content, "=== GROUNDING ===")
+	retry := provider.requests[1].Messages
+	assert.Equal(t, "It is 5.", retry[len(retry)-2].Content)
+	assert.Equal(t, "system", retry[len(retry)-1].Role)
+	assert.Equal(t, services.GroundingCited, response.Metadata["grounding"])
+	assert.Equal(t, true, response.Metadata["grounding_retried"])
+	require.Len(t, response.Citations, 1)
+	assert.Equal(t, "calculator", response.Citations[0].ToolName)
+
+	// The draft is not saved
+	messages, _, err := repo.Message().ListBySessionID(ctx, session.ID, 100, 0)
+	require.NoError(t, err)
+	for _, message := range messages {
+		assert.NotEqual(t, "It is 5.", message.Content)
+	}
+
+	// A second answer without evidence is flagged
+	response, err = service.ChatWithTools(ctx, request, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Still 7.", response.Response)
+	assert.Equal(t, services.GroundingUngrounded, response.Metadata["grounding"])
+
+	// Answers that need no facts are not retried
+	response, err = service.ChatWithTools(ctx, &models.EnhancedChatRequest{Message: "Hi", Tools: []string{"calculator"}}, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello!", response.Response)
+	assert.Equal(t, services.GroundingNotNeeded, response.Metadata["grounding"])
+	assert.Nil(t, response.Metadata["grounding_retried"])
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"agent-server/internal/metrics"
+	"agent-server/internal/models"
+)
+
+// ErrAgentBusy is returned when an agent is generating as many replies as
+// it may at once and the chat could not wait for a slot
+var ErrAgentBusy = errors.New("agent is at its concurrency limit")
+
+// AgentBusyError reports why a chat was turned away by an agent's
+// concurrency limit
+type AgentBusyError struct {
+	AgentID    string
+	Limit      int
+	Queued     int           // chats waiting when this one gave up
+	QueueFull  bool          // turned away at once rather than after waiting
+	Displaced  bool          // a batch chat that gave its place to an interactive one
+	RetryAfter time.Duration // a guess at when a slot is free
+}
+
+func (e *AgentBusyError) Error() string {
+	if e.Displaced {
+		return fmt.Sprintf("%v: batch chat displaced from the full queue by an interactive one", ErrAgentBusy)
+	}
+	if e.QueueFull {
+		return fmt.Sprintf("%v: %d replies in progress and %d chats queued", ErrAgentBusy, e.Limit, e.Queued)
+	}
+	return fmt.Sprintf("%v: no slot of %d became free in time (%d chats queued)", ErrAgentBusy, e.Limit, e.Queued)
+}
+
+func (e *AgentBusyError) Unwrap() error { return ErrAgentBusy }
+
+// ConcurrencyLimits configures the agent limiter
+type ConcurrencyLimits struct {
+	DefaultLimit int           // for agents without MaxConcurrency; 0 is unlimited
+	QueueTimeout time.Duration // longest wait for a slot
+	MaxQueue     int           // chats waiting per agent
+}
+
+// Concurrency metrics, labeled by agent
+var (
+	concurrencyMetricsOnce sync.Once
+	activeGenerations      *metrics.Gauge
+	queuedGenerations      *metrics.Gauge
+	queueWait              *metrics.Histogram
+)
+
+func initConcurrencyMetrics() {
+	concurrencyMetricsOnce.Do(func() {
+		activeGenerations = metrics.Default.NewGauge("agent_server_agent_active_generations",
+			"Replies an agent is generating.", "agent")
+		queuedGenerations = metrics.Default.NewGauge("agent_server_agent_queued_generations",
+			"Chats waiting for an agent's concurrency limit.", "agent")
+		queueWait = metrics.Default.NewHistogram("agent_server_agent_queue_wait_seconds",
+			"Time chats waited for a generation slot, by outcome (acquired, queue_full, displaced, timeout, canceled).",
+			metrics.ExponentialBuckets(0.005, 4, 9), "agent", "outcome")
+	})
+}
+
+// agentLimiter bounds the concurrent generations of each agent. Chats over
+// the limit wait in arrival order, interactive chats ahead of batch ones.
+type agentLimiter struct {
+	limits ConcurrencyLimits
+
+	mu     sync.Mutex
+	agents map[string]*agentSlots
+}
+
+// agentSlots are an agent's generations in progress and waiting chats
+type agentSlots struct {
+	limit       int // the agent's limit as of its latest chat
+	active      int
+	interactive []*slotWaiter
+	batch       []*slotWaiter
+}
+
+// slotWaiter is a chat waiting for a slot
+type slotWaiter struct {
+	ready     chan struct{} // closed when the waiter is given a slot or displaced
+	displaced bool          // set before ready is closed
+}
+
+// queued returns the number of waiting chats
+func (s *agentSlots) queued() int {
+	return len(s.interactive) + len(s.batch)
+}
+
+// next removes and returns the waiter to be given a slot next
+func (s *agentSlots) next() *slotWaiter {
+	if len(s.interactive) > 0 {
+		waiter := s.interactive[0]
+		s.interactive = s.interactive[1:]
+		return waiter
+	}
+	waiter := s.batch[0]
+	s.batch = s.batch[1:]
+	return waiter
+}
+
+// remove takes a waiter out of its line, reporting whether it was still
+// waiting
+func (s *agentSlots) remove(waiter *slotWaiter) bool {
+	for _, line := range []*[]*slotWaiter{&s.interactive, &s.batch} {
+		for i, w := range *line {
+			if w == waiter {
+				*line = append((*line)[:i], (*line)[i+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newAgentLimiter(limits ConcurrencyLimits) *agentLimiter {
+	initConcurrencyMetrics()
+	return &agentLimiter{limits: limits, agents: make(map[string]*agentSlots)}
+}
+
+// limitFor returns the agent's limit; 0 means unlimited
+func (l *agentLimiter) limitFor(agent *models.Agent) int {
+	if agent.MaxConcurrency > 0 {
+		return agent.MaxConcurrency
+	}
+	return l.limits.DefaultLimit
+}
+
+// acquire takes a generation slot of the agent, waiting for one up to the
+// queue timeout. A batch chat only takes a free slot when no chat waits,
+// and gives its place in a full queue to an interactive chat. The returned
+// function gives the slot back.
+func (l *agentLimiter) acquire(ctx context.Context, agent *models.Agent, priority string) (func(), error) {
+	limit := l.limitFor(agent)
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	start := time.Now()
+
+	l.mu.Lock()
+	slots, ok := l.agents[agent.ID]
+	if !ok {
+		slots = &agentSlots{}
+		l.agents[agent.ID] = slots
+	}
+	slots.limit = limit
+	batch := priority == models.PriorityBatch
+	ahead := len(slots.interactive)
+	if batch {
+		ahead = slots.queued()
+	}
+	if slots.active < limit && ahead == 0 {
+		slots.active++
+		l.mu.Unlock()
+		return l.granted(agent.ID, start), nil
+	}
+	if slots.queued() >= l.limits.MaxQueue {
+		if batch || len(slots.batch) == 0 {
+			queued := slots.queued()
+			l.mu.Unlock()
+			queueWait.Observe(0, agent.ID, "queue_full")
+			return nil, &AgentBusyError{AgentID: agent.ID, Limit: limit, Queued: queued, QueueFull: true, RetryAfter: l.retryAfter()}
+		}
+		// The batch chat that arrived last makes room
+		last := slots.batch[len(slots.batch)-1]
+		slots.batch = slots.batch[:len(slots.batch)-1]
+		last.displaced = true
+		close(last.ready)
+	}
+	waiter := &slotWaiter{ready: make(chan struct{})}
+	if batch {
+		slots.batch = append(slots.batch, waiter)
+	} else {
+		slots.interactive = append(slots.interactive, waiter)
+	}
+	queued := slots.queued()
+	l.mu.Unlock()
+	queuedGenerations.Add(1, agent.ID)
+	defer queuedGenerations.Add(-1, agent.ID)
+
+	timer := time.NewTimer(l.limits.QueueTimeout)
+	defer timer.Stop()
+	outcome := "timeout"
+	select {
+	case <-waiter.ready:
+		if waiter.displaced {
+			queueWait.Observe(time.Since(start).Seconds(), agent.ID, "displaced")
+			return nil, &AgentBusyError{AgentID: agent.ID, Limit: limit, Queued: queued, Displaced: true, RetryAfter: l.retryAfter()}
+		}
+		return l.granted(agent.ID, start), nil
+	case <-timer.C:
+	case <-ctx.Done():
+		outcome = "canceled"
+	}
+
+	l.mu.Lock()
+	if slots.remove(waiter) {
+		l.mu.Unlock()
+		queueWait.Observe(time.Since(start).Seconds(), agent.ID, outcome)
+		if outcome == "canceled" {
+			return nil, ctx.Err()
+		}
+		return nil, &AgentBusyError{AgentID: agent.ID, Limit: limit, Queued: queued, RetryAfter: l.retryAfter()}
+	}
+	l.mu.Unlock()
+	// The slot was handed over or the place taken while giving up
+	if waiter.displaced {
+		queueWait.Observe(time.Since(start).Seconds(), agent.ID, "displaced")
+		return nil, &AgentBusyError{AgentID: agent.ID, Limit: limit, Queued: queued, Displaced: true, RetryAfter: l.retryAfter()}
+	}
+	return l.granted(agent.ID, start), nil
+}
+
+// retryAfter is how long turned away chats are asked to wait: the queue
+// timeout, in which the chats ahead of them usually finish
+func (l *agentLimiter) retryAfter() time.Duration {
+	if l.limits.QueueTimeout < time.Second {
+		return time.Second
+	}
+	return l.limits.QueueTimeout
+}
+
+// granted records a taken slot and returns the function that gives it back
+func (l *agentLimiter) granted(agentID string, start time.Time) func() {
+	queueWait.Observe(time.Since(start).Seconds(), agentID, "acquired")
+	activeGenerations.Add(1, agentID)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			activeGenerations.Add(-1, agentID)
+			l.release(agentID)
+		})
+	}
+}
+
+// release gives a slot back, handing it to the longest waiting interactive
+// chat, or batch chat when none waits
+func (l *agentLimiter) release(agentID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots, ok := l.agents[agentID]
+	if !ok {
+		return
+	}
+	slots.active--
+	for slots.active < slots.limit && slots.queued() > 0 {
+		close(slots.next().ready)
+		slots.active++
+	}
+	if slots.active == 0 && slots.queued() == 0 {
+		delete(l.agents, agentID)
+	}
+}
+
+// SetConcurrencyLimits bounds the replies each agent generates at once.
+// Chats over an agent's limit wait for a slot and fail with ErrAgentBusy
+// when the queue is full or the wait times out.
+func (s *ChatService) SetConcurrencyLimits(limits ConcurrencyLimits) {
+	s.limiter = newAgentLimiter(limits)
+}
+
+// acquireSlot takes one of the agent's generation slots for a chat of the
+// priority class
+func (s *ChatService) acquireSlot(ctx context.Context, agent *models.Agent, priority string) (func(), error) {
+	if s.limiter == nil {
+		return func() {}, nil
+	}
+	return s.limiter.acquire(ctx, agent, priority)
+}
@@ -0,0 +1,156 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedProvider holds every reply until the test lets it through
+type gatedProvider struct {
+	scriptedProvider
+	started chan struct{}
+	gate    chan struct{}
+}
+
+func (p *gatedProvider) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.started <- struct{}{}
+	<-p.gate
+	return &llm.ChatResponse{Content: "done"}, nil
+}
+
+func TestChatService_ConcurrencyLimit(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "busy", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{}, MaxConcurrency: 1}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	var sessions []string
+	for i := 0; i < 4; i++ {
+		session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+		require.NoError(t, repo.Session().Create(ctx, session))
+		sessions = append(sessions, session.ID)
+	}
+
+	provider := &gatedProvider{started: make(chan struct{}, 4), gate: make(chan struct{})}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	toolService := services.NewToolService(repo, slog.Default())
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+	service.SetConcurrencyLimits(services.ConcurrencyLimits{QueueTimeout: 200 * time.Millisecond, MaxQueue: 1})
+
+	chat := func(sessionID string) <-chan error {
+		result := make(chan error, 1)
+		go func() {
+			_, err := service.Chat(ctx, &services.ChatRequest{SessionID: sessionID, Message: "hi"})
+			result <- err
+		}()
+		return result
+	}
+
+	// The first chat takes the only slot and the second one waits for it
+	first := chat(sessions[0])
+	<-provider.started
+	second := chat(sessions[1])
+	time.Sleep(20 * time.Millisecond)
+
+	// With the queue full, a third chat is turned away at once
+	_, err = service.Chat(ctx, &services.ChatRequest{SessionID: sessions[2], Message: "hi"})
+	var busy *services.AgentBusyError
+	require.True(t, errors.As(err, &busy))
+	assert.True(t, errors.Is(err, services.ErrAgentBusy))
+	assert.True(t, busy.QueueFull)
+	assert.Equal(t, 1, busy.Limit)
+
+	// The waiting chat gets the slot once the first one is done
+	provider.gate <- struct{}{}
+	require.NoError(t, <-first)
+	<-provider.started
+
+	// A chat that waits longer than the queue timeout gives up
+	fourth := chat(sessions[3])
+	err = <-fourth
+	require.True(t, errors.As(err, &busy))
+	assert.False(t, busy.QueueFull)
+	assert.Equal(t, time.Second, busy.RetryAfter)
+
+	provider.gate <- struct{}{}
+	require.NoError(t, <-second)
+}
+
+func TestChatService_ConcurrencyPriority(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "busy", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{}, MaxConcurrency: 1}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	var sessions []string
+	for i := 0; i < 4; i++ {
+		session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+		require.NoError(t, repo.Session().Create(ctx, session))
+		sessions = append(sessions, session.ID)
+	}
+
+	provider := &gatedProvider{started: make(chan struct{}, 4), gate: make(chan struct{})}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	toolService := services.NewToolService(repo, slog.Default())
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+	service.SetConcurrencyLimits(services.ConcurrencyLimits{QueueTimeout: 5 * time.Second, MaxQueue: 2})
+
+	chat := func(sessionID, priority string) <-chan error {
+		result := make(chan error, 1)
+		go func() {
+			_, err := service.Chat(ctx, &services.ChatRequest{SessionID: sessionID, Message: "hi", Priority: priority})
+			result <- err
+		}()
+		return result
+	}
+
+	// A live chat takes the only slot and two batch chats fill the queue
+	live := chat(sessions[0], "")
+	<-provider.started
+	firstBatch := chat(sessions[1], models.PriorityBatch)
+	time.Sleep(20 * time.Millisecond)
+	secondBatch := chat(sessions[2], models.PriorityBatch)
+	time.Sleep(20 * time.Millisecond)
+
+	// An interactive chat displaces the batch chat that came last
+	interactive := chat(sessions[3], models.PriorityInteractive)
+	var busy *services.AgentBusyError
+	require.True(t, errors.As(<-secondBatch, &busy))
+	assert.True(t, busy.Displaced)
+
+	// and gets the slot ahead of the batch chat that waited longer
+	provider.gate <- struct{}{}
+	require.NoError(t, <-live)
+	<-provider.started
+	provider.gate <- struct{}{}
+	require.NoError(t, <-interactive)
+	select {
+	case err := <-firstBatch:
+		t.Fatalf("batch chat finished before it was let through: %v", err)
+	default:
+	}
+
+	<-provider.started
+	provider.gate <- struct{}{}
+	require.NoError(t, <-firstBatch)
+}
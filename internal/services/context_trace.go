@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/models"
+)
+
+// contextTraceKey marks chats whose response includes the context trace
+type contextTraceKey struct{}
+
+// WithContextTrace asks for the context trace of the turn inline in the
+// chat response, as "context_trace" in its metadata. It is meant for
+// development; traces are stored either way.
+func WithContextTrace(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextTraceKey{}, true)
+}
+
+// wantsContextTrace reports whether the chat response includes the trace
+func wantsContextTrace(ctx context.Context) bool {
+	inline, _ := ctx.Value(contextTraceKey{}).(bool)
+	return inline
+}
+
+// SetContextTracing turns storing the context trace of every turn on or off
+func (s *ChatService) SetContextTracing(enabled bool) {
+	s.traceContexts = enabled
+}
+
+// buildContext builds the context of a turn with the session's strategy and
+// traces which of the session's messages made it in. Messages of incomplete
+// turns never reach the strategy and are traced as excluded.
+func (s *ChatService) buildContext(ctx context.Context, session *models.ChatSession, systemPrompt string, history, stored []*models.Message) ([]*models.Message, *models.ContextTrace, error) {
+	strategy, exists := s.ctxRegistry.Get(session.ContextStrategy)
+	if !exists {
+		return nil, nil, fmt.Errorf("unknown context strategy: %s", session.ContextStrategy)
+	}
+
+	contextMessages, trace, err := contextpkg.BuildContextTrace(ctx, strategy, systemPrompt, "", history, session.ContextConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build context: %w", err)
+	}
+
+	incomplete := false
+	for _, message := range stored {
+		if message.Status == models.MessageStatusIncomplete {
+			trace.Record(message, models.ContextExcluded, "turn did not complete")
+			incomplete = true
+		}
+	}
+	if incomplete {
+		// Messages not stored yet, such as the new user message, come last
+		sort.SliceStable(trace.Decisions, func(i, j int) bool {
+			a, b := trace.Decisions[i].Sequence, trace.Decisions[j].Sequence
+			return a != 0 && (b == 0 || a < b)
+		})
+	}
+	return contextMessages, trace, nil
+}
+
+// saveContextTrace stores the trace of a turn once its user message has been
+// saved. Failures are logged; they do not fail the turn. A copy is stored,
+// since the turn goes on reading the trace while it is saved.
+func (s *ChatService) saveContextTrace(ctx context.Context, session *models.ChatSession, turnID string, trace *models.ContextTrace) {
+	if !s.traceContexts || trace == nil {
+		return
+	}
+	stored := *trace
+	stored.TurnID = turnID
+	stored.SessionID = session.ID
+	if err := s.repo.ContextTrace().Save(context.WithoutCancel(ctx), &stored); err != nil {
+		s.logger.Warn("Failed to save context trace", "session_id", session.ID, "turn_id", turnID, "error", err)
+	}
+}
@@ -0,0 +1,131 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatService_ContextTrace(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "traced", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{}}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n", ContextConfig: models.JSON{"count": 2}}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	// One completed turn and one that failed
+	for _, message := range []*models.Message{
+		{SessionID: session.ID, Role: "user", Content: "first"},
+		{SessionID: session.ID, Role: "assistant", Content: "reply"},
+		{SessionID: session.ID, Role: "user", Content: "lost", Status: models.MessageStatusIncomplete},
+	} {
+		require.NoError(t, repo.Message().Create(ctx, message))
+	}
+
+	provider := &scriptedProvider{responses: []*llm.ChatResponse{{Content: "second reply"}}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	toolService := services.NewToolService(repo, slog.Default())
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+
+	response, err := service.Chat(services.WithContextTrace(ctx), &services.ChatRequest{SessionID: session.ID, Message: "second"})
+	require.NoError(t, err)
+
+	// The trace comes inline on request
+	inline, ok := response.Metadata["context_trace"].(*models.ContextTrace)
+	require.True(t, ok)
+	assert.Equal(t, "last_n", inline.Strategy)
+	assert.Equal(t, response.UserMessageID, inline.TurnID)
+
+	// and is stored for the turn either way
+	var trace *models.ContextTrace
+	require.Eventually(t, func() bool {
+		trace, err = repo.ContextTrace().GetByTurnID(ctx, response.UserMessageID)
+		return err == nil && trace != nil
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, session.ID, trace.SessionID)
+	assert.EqualValues(t, 2, trace.Settings["count"])
+	assert.Equal(t, 4, trace.Messages)
+	assert.Equal(t, 2, trace.Included)
+	assert.Equal(t, 2, trace.Excluded)
+
+	var reasons []string
+	for _, decision := range trace.Decisions {
+		reasons = append(reasons, decision.Decision+": "+decision.Reason)
+	}
+	assert.Equal(t, []string{
+		"excluded: older than the last 2 messages",
+		"included: within the last 2 messages",
+		"excluded: turn did not complete",
+		"included: within the last 2 messages",
+	}, reasons)
+	assert.Equal(t, response.UserMessageID, trace.Decisions[3].MessageID)
+
+	// Chats without the debug option leave the trace out of the response
+	provider.responses = []*llm.ChatResponse{{Content: "third reply"}}
+	response, err = service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "third"})
+	require.NoError(t, err)
+	assert.NotContains(t, response.Metadata, "context_trace")
+}
+
+// The trace of a turn is saved in the background while the turn reads it;
+// run with -race
+func TestChatService_ContextTraceSavedConcurrently(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := services.WithContextTrace(context.Background())
+
+	agent := &models.Agent{Name: "traced", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{}}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{chunks: []llm.StreamChunk{{Content: "streamed", Done: true}}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+
+	stored := func(turnID string) bool {
+		trace, err := repo.ContextTrace().GetByTurnID(ctx, turnID)
+		return err == nil && trace != nil
+	}
+	for i := 0; i < 5; i++ {
+		provider.responses = []*llm.ChatResponse{{Content: "reply"}}
+		response, err := service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "hello"})
+		require.NoError(t, err)
+		inline := response.Metadata["context_trace"].(*models.ContextTrace)
+		assert.Equal(t, response.UserMessageID, inline.TurnID)
+		assert.NotNil(t, inline.Tokens)
+		assert.Contains(t, response.Metadata, "prompt_breakdown")
+		require.Eventually(t, func() bool { return stored(response.UserMessageID) }, time.Second, 5*time.Millisecond)
+
+		chunks, err := service.Stream(ctx, &services.ChatRequest{SessionID: session.ID, Message: "hello"})
+		require.NoError(t, err)
+		var final services.StreamChunk
+		for chunk := range chunks {
+			if chunk.Done {
+				final = chunk
+			}
+		}
+		inline = final.Metadata["context_trace"].(*models.ContextTrace)
+		assert.NotEmpty(t, inline.TurnID)
+		require.Eventually(t, func() bool { return stored(inline.TurnID) }, time.Second, 5*time.Millisecond)
+	}
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+)
+
+const (
+	// contextWarnThreshold is the share of the context window above which a
+	// prompt counts as nearly full
+	contextWarnThreshold = 0.8
+	// contextWarnMinTurns is how many recent turns an agent needs before its
+	// prompts are judged
+	contextWarnMinTurns = 5
+	// contextUsageWindow is how far back turns count as recent
+	contextUsageWindow = 24 * time.Hour
+	// contextLookupRetry is how long a failed provider lookup is remembered
+	// before the provider is asked again
+	contextLookupRetry = time.Minute
+	// contextLookupTimeout bounds a provider lookup
+	contextLookupTimeout = 5 * time.Second
+)
+
+// ContextWindows resolves the context window of agents' models: the agent's
+// num_ctx option, then the configured windows keyed by "provider/model" or
+// model name, then the provider itself. Provider lookups are cached.
+type ContextWindows struct {
+	registry   *llm.Registry
+	configured map[string]int
+
+	mu      sync.Mutex
+	lookups map[string]windowLookup // by provider and model
+}
+
+// windowLookup is the answer of a provider; tokens is 0 if it had none
+type windowLookup struct {
+	tokens int
+	at     time.Time
+}
+
+// NewContextWindows creates a resolver with configured windows in tokens
+func NewContextWindows(registry *llm.Registry, configured map[string]int) *ContextWindows {
+	return &ContextWindows{
+		registry:   registry,
+		configured: configured,
+		lookups:    make(map[string]windowLookup),
+	}
+}
+
+// Resolve returns the context window of the agent's model in tokens and
+// where it was found; 0 tokens means it is unknown
+func (w *ContextWindows) Resolve(ctx context.Context, agent *models.Agent) (int, string) {
+	switch n := agent.Config["num_ctx"].(type) {
+	case float64:
+		if n > 0 {
+			return int(n), models.ContextWindowAgent
+		}
+	case int:
+		if n > 0 {
+			return n, models.ContextWindowAgent
+		}
+	}
+	if n, ok := w.configured[agent.Provider+"/"+agent.Model]; ok && n > 0 {
+		return n, models.ContextWindowConfig
+	}
+	if n, ok := w.configured[agent.Model]; ok && n > 0 {
+		return n, models.ContextWindowConfig
+	}
+	if n := w.lookup(ctx, agent.Provider, agent.Model); n > 0 {
+		return n, models.ContextWindowProvider
+	}
+	return 0, ""
+}
+
+// lookup asks the provider for the model's context window, unless it has
+// answered before
+func (w *ContextWindows) lookup(ctx context.Context, providerName, model string) int {
+	provider, ok := w.registry.Get(providerName)
+	if !ok {
+		return 0
+	}
+	windower, ok := llm.AsContextWindower(provider)
+	if !ok {
+		return 0
+	}
+
+	key := providerName + "/" + model
+	w.mu.Lock()
+	cached, ok := w.lookups[key]
+	w.mu.Unlock()
+	if ok && (cached.tokens > 0 || time.Since(cached.at) < contextLookupRetry) {
+		return cached.tokens
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, contextLookupTimeout)
+	defer cancel()
+	tokens, err := windower.ContextWindow(ctx, model)
+	if err != nil {
+		tokens = 0
+	}
+	w.mu.Lock()
+	w.lookups[key] = windowLookup{tokens: tokens, at: time.Now()}
+	w.mu.Unlock()
+	return tokens
+}
+
+// estimatePromptTokens approximates the tokens of messages at four
+// characters per token, for providers that do not report usage
+func estimatePromptTokens(messages []llm.ChatMessage) int {
+	chars := 0
+	for _, message := range messages {
+		chars += utf8.RuneCountInString(message.Content)
+	}
+	return (chars + 3) / 4
+}
+
+type contextSample struct {
+	at          time.Time
+	utilization float64
+}
+
+// contextUsageTracker records per agent how much of the context window each
+// prompt used. Samples are kept in memory and reset on restart.
+type contextUsageTracker struct {
+	mu      sync.Mutex
+	byAgent map[string][]contextSample
+}
+
+func newContextUsageTracker() *contextUsageTracker {
+	return &contextUsageTracker{byAgent: make(map[string][]contextSample)}
+}
+
+// record stores the utilization of one prompt
+func (t *contextUsageTracker) record(agentID string, utilization float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	samples := append(t.byAgent[agentID], contextSample{at: time.Now(), utilization: utilization})
+	if len(samples) > maxTrackedOutcomes {
+		samples = samples[len(samples)-maxTrackedOutcomes:]
+	}
+	t.byAgent[agentID] = samples
+}
+
+// report summarizes the agent's recent turns, warning when most of them
+// came close to filling the context window
+func (t *contextUsageTracker) report(agentID string) models.ContextWindowReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var report models.ContextWindowReport
+	cutoff := time.Now().Add(-contextUsageWindow)
+	total := 0.0
+	for _, sample := range t.byAgent[agentID] {
+		if sample.at.Before(cutoff) {
+			continue
+		}
+		report.RecentTurns++
+		total += sample.utilization
+		report.PeakUtilization = math.Max(report.PeakUtilization, sample.utilization)
+		if sample.utilization > contextWarnThreshold {
+			report.TurnsOverThreshold++
+		}
+	}
+	if report.RecentTurns == 0 {
+		return report
+	}
+	report.AverageUtilization = roundShare(total / float64(report.RecentTurns))
+	report.PeakUtilization = roundShare(report.PeakUtilization)
+	if report.RecentTurns >= contextWarnMinTurns && report.TurnsOverThreshold*2 >= report.RecentTurns {
+		report.Warning = fmt.Sprintf("%d of the last %d prompts used more than %.0f%% of the context window; "+
+			"consider the summarize or sliding_window strategy, a smaller last_n, or a model with a larger window",
+			report.TurnsOverThreshold, report.RecentTurns, contextWarnThreshold*100)
+	}
+	return report
+}
+
+// roundShare rounds a share to three decimals for reporting
+func roundShare(share float64) float64 {
+	return math.Round(share*1000) / 1000
+}
+
+// SetContextWindows sets how the context windows of agents' models are
+// resolved
+func (s *ChatService) SetContextWindows(windows *ContextWindows) {
+	s.contextWindows = windows
+}
+
+// ContextWindowReport returns the context window of the agent's model and
+// how much of it the agent's recent prompts used
+func (s *ChatService) ContextWindowReport(ctx context.Context, agent *models.Agent) *models.ContextWindowReport {
+	report := s.contextUsage.report(agent.ID)
+	report.Tokens, report.Source = s.contextWindows.Resolve(ctx, agent)
+	return &report
+}
+
+// recordContextUsage adds how much of the context window the prompt of a
+// turn used to metadata and tracks it for the agent. The prompt size is
+// estimated when the provider did not report usage.
+func (s *ChatService) recordContextUsage(ctx context.Context, metadata map[string]interface{}, agent *models.Agent, usage *llm.Usage, messages []llm.ChatMessage) {
+	tokens, source := s.contextWindows.Resolve(ctx, agent)
+	if tokens == 0 {
+		return
+	}
+
+	window := map[string]interface{}{
+		"tokens": tokens,
+		"source": source,
+	}
+	promptTokens := 0
+	if usage != nil && usage.PromptTokens > 0 {
+		promptTokens = usage.PromptTokens
+	} else {
+		promptTokens = estimatePromptTokens(messages)
+		window["estimated"] = true
+	}
+	utilization := float64(promptTokens) / float64(tokens)
+	s.contextUsage.record(agent.ID, utilization)
+
+	window["prompt_tokens"] = promptTokens
+	window["utilization"] = roundShare(utilization)
+	if report := s.contextUsage.report(agent.ID); report.Warning != "" {
+		window["warning"] = report.Warning
+	}
+	metadata["context_window"] = window
+}
@@ -0,0 +1,108 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+)
+
+// windowedProvider reports a context window for every model and counts
+// the lookups
+type windowedProvider struct {
+	scriptedProvider
+	window  int
+	lookups int
+}
+
+func (p *windowedProvider) ContextWindow(ctx context.Context, model string) (int, error) {
+	p.lookups++
+	return p.window, nil
+}
+
+func TestContextWindows_Resolve(t *testing.T) {
+	provider := &windowedProvider{window: 8192}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	windows := services.NewContextWindows(registry, map[string]int{"ollama/tuned": 4096, "shared": 2048})
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name   string
+		agent  *models.Agent
+		tokens int
+		source string
+	}{
+		{"agent option", &models.Agent{Provider: "ollama", Model: "tuned", Config: models.JSON{"num_ctx": float64(16384)}}, 16384, models.ContextWindowAgent},
+		{"provider and model", &models.Agent{Provider: "ollama", Model: "tuned"}, 4096, models.ContextWindowConfig},
+		{"model name", &models.Agent{Provider: "ollama", Model: "shared"}, 2048, models.ContextWindowConfig},
+		{"provider lookup", &models.Agent{Provider: "ollama", Model: "llama3"}, 8192, models.ContextWindowProvider},
+		{"unknown", &models.Agent{Provider: "openai", Model: "gpt-4o"}, 0, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, source := windows.Resolve(ctx, tt.agent)
+			assert.Equal(t, tt.tokens, tokens)
+			assert.Equal(t, tt.source, source)
+		})
+	}
+
+	// The provider is asked once per model
+	windows.Resolve(ctx, &models.Agent{Provider: "ollama", Model: "llama3"})
+	assert.Equal(t, 1, provider.lookups)
+}
+
+func TestChatService_ReportsContextUtilization(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "full", Provider: "ollama", Model: "llama3", Enabled: true, SystemPrompt: "p", Config: models.JSON{}}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &windowedProvider{window: 1000}
+	for i := 0; i < 5; i++ {
+		provider.responses = append(provider.responses, &llm.ChatResponse{
+			Content: "Reply",
+			Usage:   &llm.Usage{PromptTokens: 850, CompletionTokens: 10, TotalTokens: 860},
+		})
+	}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	chatService := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+
+	response, err := chatService.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "Hi"})
+	require.NoError(t, err)
+	window, ok := response.Metadata["context_window"].(map[string]interface{})
+	require.True(t, ok, "chat metadata has the context window")
+	assert.Equal(t, 1000, window["tokens"])
+	assert.Equal(t, models.ContextWindowProvider, window["source"])
+	assert.Equal(t, 850, window["prompt_tokens"])
+	assert.Equal(t, 0.85, window["utilization"])
+	assert.NotContains(t, window, "warning")
+
+	// Most recent prompts were nearly full
+	for i := 0; i < 4; i++ {
+		response, err = chatService.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "Again"})
+		require.NoError(t, err)
+	}
+	window = response.Metadata["context_window"].(map[string]interface{})
+	assert.Contains(t, window["warning"], "5 of the last 5 prompts used more than 80% of the context window")
+
+	report := chatService.ContextWindowReport(ctx, agent)
+	assert.Equal(t, 1000, report.Tokens)
+	assert.Equal(t, 5, report.RecentTurns)
+	assert.Equal(t, 5, report.TurnsOverThreshold)
+	assert.Equal(t, 0.85, report.AverageUtilization)
+	assert.NotEmpty(t, report.Warning)
+}
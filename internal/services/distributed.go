@@ -0,0 +1,471 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/postprocess"
+	"agent-server/internal/queue"
+
+	"github.com/google/uuid"
+)
+
+// ErrNoWorker is returned when no worker picked up a chat in time
+var ErrNoWorker = errors.New("no worker accepted the chat")
+
+// Kinds of chat tasks run by workers
+const (
+	taskChat        = "chat"
+	taskStream      = "stream"
+	taskChatTools   = "chat_tools"
+	taskStreamTools = "stream_tools"
+)
+
+// Types of the events of chat tasks
+const (
+	taskAccepted  = "accepted"   // the chat started; errors that prevent it come as taskFailed instead
+	taskChunk     = "chunk"      // a StreamChunk
+	taskToolEvent = "tool_event" // a ToolChatEvent
+	taskResult    = "result"     // the response of a chat
+	taskFailed    = "error"      // a taskError
+)
+
+// remoteErrors are the errors callers tell apart, with the codes that carry
+// them from workers
+var remoteErrors = []struct {
+	code string
+	err  error
+}{
+	{"session_not_found", ErrSessionNotFound},
+	{"session_archived", ErrSessionArchived},
+	{"agent_not_found", ErrAgentNotFound},
+	{"agent_disabled", ErrAgentDisabled},
+	{"context_overflow", llm.ErrContextOverflow},
+	{"tool_loop_exceeded", ErrToolLoopExceeded},
+	{"budget_exceeded", ErrBudgetExceeded},
+	{"agent_busy", ErrAgentBusy},
+	{"content_blocked", ErrContentBlocked},
+	{"not_acceptable", postprocess.ErrNotAcceptable},
+	{"provider_unavailable", ErrProviderUnavailable},
+	{"llm_unavailable", llm.ErrUnavailable},
+	{"llm_rate_limited", llm.ErrRateLimited},
+	{"llm_model_not_found", llm.ErrModelNotFound},
+	{"llm_auth_failed", llm.ErrAuthFailed},
+	{"llm_timeout", llm.ErrTimeout},
+	{"llm_request", ErrLLMRequest},
+	{"deadline_exceeded", context.DeadlineExceeded},
+}
+
+// taskError is an error crossing the queue
+type taskError struct {
+	Codes   []string `json:"codes,omitempty"`
+	Message string   `json:"message"`
+}
+
+// newTaskError encodes err with the codes of the remote errors it wraps
+func newTaskError(err error) taskError {
+	encoded := taskError{Message: err.Error()}
+	for _, remote := range remoteErrors {
+		if errors.Is(err, remote.err) {
+			encoded.Codes = append(encoded.Codes, remote.code)
+		}
+	}
+	return encoded
+}
+
+// remoteError is an error reported by a worker. It keeps the message and
+// wraps the errors of its codes, so it is handled like the original.
+type remoteError struct {
+	message string
+	wrapped []error
+}
+
+func (e *remoteError) Error() string   { return e.message }
+func (e *remoteError) Unwrap() []error { return e.wrapped }
+
+// err decodes the error
+func (e taskError) err() error {
+	decoded := &remoteError{message: e.Message}
+	for _, code := range e.Codes {
+		for _, remote := range remoteErrors {
+			if remote.code == code {
+				decoded.wrapped = append(decoded.wrapped, remote.err)
+			}
+		}
+	}
+	return decoded
+}
+
+// toolTaskPayload is the payload of tool-calling chat tasks
+type toolTaskPayload struct {
+	SessionID string                      `json:"session_id"`
+	Request   *models.EnhancedChatRequest `json:"request"`
+}
+
+// remoteToolEvent is a ToolChatEvent with the codes of its error
+type remoteToolEvent struct {
+	ToolChatEvent
+	ErrorCodes []string `json:"error_codes,omitempty"`
+}
+
+// localKey marks contexts in which chats run in process although a broker
+// is set, as they do on workers
+type localKey struct{}
+
+// SetBroker publishes chats to workers through broker instead of running
+// them in process. Chats not picked up by a worker within acceptTimeout fail
+// with ErrNoWorker.
+func (s *ChatService) SetBroker(broker queue.Broker, acceptTimeout time.Duration) {
+	s.broker = broker
+	s.acceptTimeout = acceptTimeout
+}
+
+// dispatches reports whether chats started with ctx go to workers
+func (s *ChatService) dispatches(ctx context.Context) bool {
+	return s.broker != nil && ctx.Value(localKey{}) == nil
+}
+
+// dispatch publishes a chat task of the priority class and waits until a
+// worker has started it.
+// Errors that prevent the chat from starting are returned; otherwise the
+// remaining events of the task follow on the returned channel, which the
+// caller must drain or cancel ctx for.
+func (s *ChatService) dispatch(ctx context.Context, kind, priority string, payload interface{}) (<-chan queue.Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	task := &queue.Task{
+		ID:       uuid.New().String(),
+		Kind:     kind,
+		Payload:  data,
+		Deadline: time.Now().Add(s.acceptTimeout),
+		Batch:    priority == models.PriorityBatch,
+	}
+
+	events, err := s.broker.Subscribe(ctx, task.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to chat events: %w", err)
+	}
+	if err := s.broker.Publish(ctx, task); err != nil {
+		return nil, fmt.Errorf("failed to publish chat: %w", err)
+	}
+
+	timer := time.NewTimer(s.acceptTimeout)
+	defer timer.Stop()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			return nil, errors.New("lost the events of the chat")
+		}
+		if event.Type == taskFailed {
+			return nil, decodeTaskError(event)
+		}
+		return events, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("%w within %s", ErrNoWorker, s.acceptTimeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// awaitResult returns the response of a dispatched chat
+func (s *ChatService) awaitResult(ctx context.Context, events <-chan queue.Event, response interface{}) error {
+	for event := range events {
+		switch event.Type {
+		case taskResult:
+			return json.Unmarshal(event.Data, response)
+		case taskFailed:
+			return decodeTaskError(event)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errors.New("lost the events of the chat")
+}
+
+// remoteChat runs Chat on a worker
+func (s *ChatService) remoteChat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, err := s.dispatch(ctx, taskChat, req.Priority, req)
+	if err != nil {
+		return nil, err
+	}
+	var response ChatResponse
+	if err := s.awaitResult(ctx, events, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// remoteChatWithTools runs ChatWithTools on a worker
+func (s *ChatService) remoteChatWithTools(ctx context.Context, req *models.EnhancedChatRequest, sessionID string) (*models.EnhancedChatResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, err := s.dispatch(ctx, taskChatTools, req.Priority, toolTaskPayload{SessionID: sessionID, Request: req})
+	if err != nil {
+		return nil, err
+	}
+	var response models.EnhancedChatResponse
+	if err := s.awaitResult(ctx, events, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// remoteStream runs Stream on a worker and relays its chunks
+func (s *ChatService) remoteStream(ctx context.Context, req *ChatRequest) (<-chan StreamChunk, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	events, err := s.dispatch(ctx, taskStream, req.Priority, req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	chunks := make(chan StreamChunk, 10)
+	go func() {
+		defer close(chunks)
+		defer cancel()
+		for event := range events {
+			if event.Type != taskChunk {
+				continue
+			}
+			var chunk StreamChunk
+			if err := json.Unmarshal(event.Data, &chunk); err != nil {
+				s.logger.Warn("Dropped malformed stream chunk", "error", err)
+				continue
+			}
+			select {
+			case chunks <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return chunks, nil
+}
+
+// remoteStreamWithTools runs StreamWithTools on a worker and relays its
+// events
+func (s *ChatService) remoteStreamWithTools(ctx context.Context, req *models.EnhancedChatRequest, sessionID string) (<-chan ToolChatEvent, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	events, err := s.dispatch(ctx, taskStreamTools, req.Priority, toolTaskPayload{SessionID: sessionID, Request: req})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	toolEvents := make(chan ToolChatEvent, 10)
+	go func() {
+		defer close(toolEvents)
+		defer cancel()
+		finished := false
+		for event := range events {
+			if event.Type != taskToolEvent {
+				continue
+			}
+			var remote remoteToolEvent
+			if err := json.Unmarshal(event.Data, &remote); err != nil {
+				s.logger.Warn("Dropped malformed tool chat event", "error", err)
+				continue
+			}
+			toolEvent := remote.ToolChatEvent
+			if toolEvent.Type == EventError {
+				toolEvent.Err = taskError{Codes: remote.ErrorCodes, Message: toolEvent.Error}.err()
+			}
+			finished = toolEvent.Type == EventDone || toolEvent.Type == EventError
+			select {
+			case toolEvents <- toolEvent:
+			case <-ctx.Done():
+				return
+			}
+		}
+		// The turn's end is reported even when its events were lost
+		if !finished && ctx.Err() == nil {
+			err := errors.New("lost the events of the chat")
+			toolEvents <- ToolChatEvent{Type: EventError, Error: err.Error(), Err: err}
+		}
+	}()
+	return toolEvents, nil
+}
+
+// decodeTaskError returns the error of a failed task event
+func decodeTaskError(event queue.Event) error {
+	var taskErr taskError
+	if err := json.Unmarshal(event.Data, &taskErr); err != nil {
+		return fmt.Errorf("chat failed on the worker: %s", event.Data)
+	}
+	return taskErr.err()
+}
+
+// ChatWorker runs the chats published by API instances
+type ChatWorker struct {
+	chat    *ChatService
+	broker  queue.Broker
+	workers int
+	logger  *slog.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewChatWorker creates a worker running up to workers chats at a time with
+// chat
+func NewChatWorker(chat *ChatService, broker queue.Broker, workers int, logger *slog.Logger) *ChatWorker {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &ChatWorker{
+		chat:    chat,
+		broker:  broker,
+		workers: workers,
+		logger:  logger,
+	}
+}
+
+// Start begins consuming chats. Consumers reconnect after broker failures.
+func (w *ChatWorker) Start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+	for i := 0; i < w.workers; i++ {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			backoff := time.Second
+			for ctx.Err() == nil {
+				err := w.broker.Consume(ctx, w.handle)
+				if ctx.Err() != nil {
+					return
+				}
+				w.logger.Error("Chat queue consumer failed, reconnecting", "error", err, "backoff", backoff)
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					return
+				}
+				if backoff < 30*time.Second {
+					backoff *= 2
+				}
+			}
+		}()
+	}
+	w.logger.Info("Chat worker started", "workers", w.workers)
+}
+
+// Stop stops consuming and waits for the running chats to finish
+func (w *ChatWorker) Stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	w.wg.Wait()
+}
+
+// handle runs one chat task and emits its events. Chats continue when the
+// API instance that published them goes away, so their replies are saved.
+func (w *ChatWorker) handle(ctx context.Context, task *queue.Task) {
+	if !task.Deadline.IsZero() && time.Now().After(task.Deadline) {
+		w.logger.Warn("Dropped chat picked up after its deadline", "task_id", task.ID, "kind", task.Kind)
+		return
+	}
+	emitCtx := context.WithoutCancel(ctx)
+	ctx = context.WithValue(context.WithoutCancel(ctx), localKey{}, true)
+
+	emit := func(eventType string, data interface{}, final bool) {
+		event := queue.Event{Type: eventType, Final: final}
+		if data != nil {
+			encoded, err := json.Marshal(data)
+			if err != nil {
+				w.logger.Error("Failed to encode chat event", "task_id", task.ID, "error", err)
+				return
+			}
+			event.Data = encoded
+		}
+		if err := w.broker.Emit(emitCtx, task.ID, event); err != nil {
+			w.logger.Error("Failed to emit chat event", "task_id", task.ID, "type", eventType, "error", err)
+		}
+	}
+	fail := func(err error) {
+		emit(taskFailed, newTaskError(err), true)
+	}
+
+	w.logger.Info("Running queued chat", "task_id", task.ID, "kind", task.Kind)
+	switch task.Kind {
+	case taskChat:
+		var req ChatRequest
+		if err := json.Unmarshal(task.Payload, &req); err != nil {
+			fail(err)
+			return
+		}
+		emit(taskAccepted, nil, false)
+		response, err := w.chat.Chat(ctx, &req)
+		if err != nil {
+			fail(err)
+			return
+		}
+		emit(taskResult, response, true)
+
+	case taskChatTools:
+		var payload toolTaskPayload
+		if err := json.Unmarshal(task.Payload, &payload); err != nil {
+			fail(err)
+			return
+		}
+		emit(taskAccepted, nil, false)
+		response, err := w.chat.ChatWithTools(ctx, payload.Request, payload.SessionID)
+		if err != nil {
+			fail(err)
+			return
+		}
+		emit(taskResult, response, true)
+
+	case taskStream:
+		var req ChatRequest
+		if err := json.Unmarshal(task.Payload, &req); err != nil {
+			fail(err)
+			return
+		}
+		chunks, err := w.chat.Stream(ctx, &req)
+		if err != nil {
+			fail(err)
+			return
+		}
+		emit(taskAccepted, nil, false)
+		for chunk := range chunks {
+			emit(taskChunk, chunk, false)
+		}
+		emit(taskResult, nil, true)
+
+	case taskStreamTools:
+		var payload toolTaskPayload
+		if err := json.Unmarshal(task.Payload, &payload); err != nil {
+			fail(err)
+			return
+		}
+		events, err := w.chat.StreamWithTools(ctx, payload.Request, payload.SessionID)
+		if err != nil {
+			fail(err)
+			return
+		}
+		emit(taskAccepted, nil, false)
+		for event := range events {
+			remote := remoteToolEvent{ToolChatEvent: event}
+			if event.Err != nil {
+				remote.ErrorCodes = newTaskError(event.Err).Codes
+			}
+			emit(taskToolEvent, remote, false)
+		}
+		emit(taskResult, nil, true)
+
+	default:
+		fail(fmt.Errorf("unknown chat task kind %q", task.Kind))
+	}
+}
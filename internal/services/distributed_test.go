@@ -0,0 +1,84 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/queue"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+)
+
+func TestChatService_DispatchesToWorkers(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "queued", Provider: "ollama", Model: "llama3", Enabled: true, SystemPrompt: "p", Config: models.JSON{}}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	// The API instance has no providers; only the worker calls the model
+	broker := queue.NewMemory(10)
+	api := services.NewChatService(repo, llm.NewRegistry(), contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+	api.SetBroker(broker, time.Second)
+
+	provider := &scriptedProvider{
+		responses: []*llm.ChatResponse{{Content: "Hello from the worker"}},
+		chunks:    []llm.StreamChunk{{Content: "Str"}, {Content: "eamed", Done: true}},
+	}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	worker := services.NewChatWorker(
+		services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default()),
+		broker, 2, slog.Default())
+	worker.Start(ctx)
+	defer worker.Stop()
+
+	response, err := api.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "Hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello from the worker", response.Response)
+	saved, err := repo.Message().GetByID(ctx, response.AssistantMessageID)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello from the worker", saved.Content)
+
+	chunks, err := api.Stream(ctx, &services.ChatRequest{SessionID: session.ID, Message: "Stream it"})
+	require.NoError(t, err)
+	var streamed string
+	var last services.StreamChunk
+	for chunk := range chunks {
+		streamed += chunk.Content
+		last = chunk
+	}
+	assert.Equal(t, "Streamed", streamed)
+	assert.True(t, last.Done)
+	assert.NotEmpty(t, last.MessageID)
+
+	// Errors keep their kind across the queue
+	_, err = api.Chat(ctx, &services.ChatRequest{SessionID: "missing", Message: "Hi"})
+	assert.ErrorIs(t, err, services.ErrSessionNotFound)
+	_, err = api.Stream(ctx, &services.ChatRequest{SessionID: "missing", Message: "Hi"})
+	assert.ErrorIs(t, err, services.ErrSessionNotFound)
+}
+
+func TestChatService_FailsWithoutWorkers(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+
+	api := services.NewChatService(repo, llm.NewRegistry(), contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+	api.SetBroker(queue.NewMemory(10), 50*time.Millisecond)
+
+	_, err = api.Chat(context.Background(), &services.ChatRequest{SessionID: "s", Message: "Hi"})
+	assert.ErrorIs(t, err, services.ErrNoWorker)
+}
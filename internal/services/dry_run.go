@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+)
+
+// defaultDryRunIterations caps the model calls of a dry run that sets no limit
+const defaultDryRunIterations = 5
+
+// DryRun runs the agent on a message without a session. The model is offered
+// the stubbed tools only and its calls are answered with the stubs' canned
+// responses, so runs depend on nothing but the model. Nothing is saved and
+// no budget is charged.
+func (s *ChatService) DryRun(ctx context.Context, agentID string, req *models.DryRunRequest) (*models.DryRunResult, error) {
+	agent, err := s.repo.Agent().GetByID(ctx, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent: %w", err)
+	}
+	if agent == nil {
+		return nil, ErrAgentNotFound
+	}
+
+	provider, exists := s.llmRegistry.Get(agent.Provider)
+	if !exists {
+		return nil, fmt.Errorf("%w: unsupported provider %s", ErrProviderUnavailable, agent.Provider)
+	}
+	if !provider.IsAvailable(ctx) {
+		return nil, fmt.Errorf("%w: %s", ErrProviderUnavailable, agent.Provider)
+	}
+
+	stubs := make(map[string]models.ToolStub, len(req.Tools))
+	names := make([]string, 0, len(req.Tools))
+	for _, stub := range req.Tools {
+		stubs[stub.Name] = stub
+		names = append(names, stub.Name)
+	}
+	choice, err := resolveToolChoice(req.ToolChoice, names)
+	if err != nil {
+		return nil, err
+	}
+	var definitions []models.ToolDefinition
+	if choice.Mode != models.ToolChoiceNone {
+		definitions = s.stubDefinitions(req.Tools)
+	}
+
+	basePrompt := req.SystemPrompt
+	if basePrompt == "" {
+		basePrompt = agent.SystemPrompt
+	}
+	result := &models.DryRunResult{
+		AgentID:      agent.ID,
+		SystemPrompt: stubbedSystemPrompt(basePrompt, definitions),
+		ToolCalls:    []models.DryRunToolCall{},
+	}
+
+	messages := []llm.ChatMessage{{Role: "system", Content: result.SystemPrompt}}
+	for _, message := range req.History {
+		messages = append(messages, llm.ChatMessage{Role: message.Role, Content: message.Content})
+	}
+	messages = append(messages, llm.ChatMessage{Role: "user", Content: req.Message})
+
+	maxIterations := req.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = defaultDryRunIterations
+	}
+	choiceRetried := false
+	for iteration := 1; iteration <= maxIterations; iteration++ {
+		// Tools go into a copy, so that the agent's config is left alone
+		options := make(map[string]interface{}, len(agent.Config)+2)
+		for k, v := range agent.Config {
+			options[k] = v
+		}
+		offered := definitions
+		var toolChoice interface{} = req.ToolChoice
+		forced := choice.Forces() && len(result.ToolCalls) == 0
+		requestMessages := messages
+		if forced {
+			var instructions string
+			toolChoice, offered, instructions = forcedToolRequest(choice, llm.ForcesToolChoice(provider), definitions)
+			if instructions != "" {
+				requestMessages = append([]llm.ChatMessage{{Role: "system", Content: result.SystemPrompt + instructions}}, messages[1:]...)
+			}
+		} else if choice.Forces() {
+			toolChoice = models.ToolChoiceAuto
+		}
+		if len(offered) > 0 {
+			options["tools"] = offered
+			options["tool_choice"] = toolChoice
+		}
+		llmRequest := &llm.ChatRequest{
+			Model:       agent.Model,
+			Messages:    requestMessages,
+			Temperature: agent.Temperature,
+			MaxTokens:   agent.MaxTokens,
+			Options:     options,
+		}
+		if req.Temperature != nil {
+			llmRequest.Temperature = *req.Temperature
+		}
+
+		response, err := provider.Chat(ctx, llmRequest)
+		if err != nil {
+			return nil, s.providerError(agent, err)
+		}
+		result.Iterations = iteration
+		if response.Usage != nil {
+			result.Usage.PromptTokens += response.Usage.PromptTokens
+			result.Usage.CompletionTokens += response.Usage.CompletionTokens
+			result.Usage.TotalTokens += response.Usage.TotalTokens
+		}
+
+		toolCalls, err := s.parseToolCallsFromResponse(response.Content, response.Metadata)
+		if err != nil {
+			toolCalls = nil
+		}
+		if forced && !toolChoiceMet(choice, toolCalls) {
+			if choiceRetried {
+				return nil, fmt.Errorf("%w: tool_choice %s", ErrToolChoiceUnmet, choice)
+			}
+			choiceRetried = true
+			messages = append(messages,
+				llm.ChatMessage{Role: "assistant", Content: response.Content},
+				llm.ChatMessage{Role: "system", Content: toolChoiceCorrection(choice)})
+			continue
+		}
+		if len(toolCalls) == 0 {
+			s.limitResponse(response)
+			result.Response = response.Content
+			result.FinishReason = getFinishReason(response, false)
+			return result, nil
+		}
+
+		messages = append(messages, llm.ChatMessage{Role: "assistant", Content: response.Content})
+		for _, call := range toolCalls {
+			answered := answerToolCall(stubs, call)
+			answered.Iteration = iteration
+			result.ToolCalls = append(result.ToolCalls, answered)
+
+			content := map[string]interface{}{"success": answered.Success}
+			if answered.Success {
+				content["result"] = answered.Result
+			} else {
+				content["error"] = answered.Error
+			}
+			data, _ := json.Marshal(content)
+			messages = append(messages, llm.ChatMessage{Role: "tool", Content: string(data)})
+		}
+	}
+
+	return nil, ErrToolLoopExceeded
+}
+
+// stubDefinitions returns the tool definitions offered for stubs. A stub of
+// a registered tool starts from its definition; its description and
+// parameters replace the registered ones when set.
+func (s *ChatService) stubDefinitions(stubs []models.ToolStub) []models.ToolDefinition {
+	definitions := make([]models.ToolDefinition, 0, len(stubs))
+	for _, stub := range stubs {
+		definition := models.ToolDefinition{
+			Type:     "function",
+			Function: models.ToolFunctionDefinition{Name: stub.Name},
+		}
+		// Unavailable tools can be stubbed too, so the registry is read directly
+		if s.toolService != nil {
+			if tool, ok := s.toolService.GetRegistry().Get(stub.Name); ok {
+				schema := tool.Schema()
+				definition.Function.Description = schema.Description
+				definition.Function.Parameters = convertSchemaToJSONSchema(schema)
+			}
+		}
+		if stub.Description != "" {
+			definition.Function.Description = stub.Description
+		}
+		if stub.Parameters != nil {
+			definition.Function.Parameters = stub.Parameters
+		}
+		if definition.Function.Parameters == nil {
+			definition.Function.Parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		definitions = append(definitions, definition)
+	}
+	return definitions
+}
+
+// answerToolCall answers a tool call with its stub; calls of tools without
+// a stub fail, since no real tool may run
+func answerToolCall(stubs map[string]models.ToolStub, call models.LLMToolCall) models.DryRunToolCall {
+	answered := models.DryRunToolCall{
+		ID:        call.ID,
+		ToolName:  call.Function.Name,
+		Arguments: map[string]interface{}{},
+	}
+	if call.Function.Arguments != "" {
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &answered.Arguments); err != nil {
+			answered.Arguments = map[string]interface{}{"raw": call.Function.Arguments}
+		}
+	}
+
+	stub, ok := stubs[call.Function.Name]
+	switch {
+	case !ok:
+		answered.Error = fmt.Sprintf("tool %s is not available", call.Function.Name)
+	case stub.Error != "":
+		answered.Error = stub.Error
+	default:
+		answered.Success = true
+		answered.Result = stub.Response
+	}
+	return answered
+}
+
+// stubbedSystemPrompt describes the offered tools below the base prompt, in
+// the layout of the prompt service
+func stubbedSystemPrompt(basePrompt string, definitions []models.ToolDefinition) string {
+	if len(definitions) == 0 {
+		return basePrompt
+	}
+	if basePrompt == "" {
+		basePrompt = SystemPrompts.ToolEnabled
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString(basePrompt)
+	prompt.WriteString("\n\n=== AVAILABLE TOOLS ===\n")
+	prompt.WriteString("You have access to the following tools. Use them whenever appropriate:\n\n")
+	for _, definition := range definitions {
+		prompt.WriteString(fmt.Sprintf("🔧 **%s**: %s\n", definition.Function.Name, definition.Function.Description))
+		properties, _ := definition.Function.Parameters["properties"].(map[string]interface{})
+		if len(properties) == 0 {
+			prompt.WriteString("\n")
+			continue
+		}
+		required := map[string]bool{}
+		switch names := definition.Function.Parameters["required"].(type) {
+		case []string:
+			for _, name := range names {
+				required[name] = true
+			}
+		case []interface{}:
+			for _, name := range names {
+				if name, ok := name.(string); ok {
+					required[name] = true
+				}
+			}
+		}
+		names := make([]string, 0, len(properties))
+		for name := range properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		prompt.WriteString("Parameters:\n")
+		for _, name := range names {
+			property, _ := properties[name].(map[string]interface{})
+			kind, _ := property["type"].(string)
+			description, _ := property["description"].(string)
+			requirement := "optional"
+			if required[name] {
+				requirement = "required"
+			}
+			prompt.WriteString(fmt.Sprintf("  - %s (%s, %s): %s\n", name, kind, requirement, description))
+		}
+		prompt.WriteString("\n")
+	}
+	prompt.WriteString("=== TOOL USAGE REMINDER ===\n")
+	prompt.WriteString("- ALWAYS use tools when they match the task requirements\n")
+	prompt.WriteString("- Don't perform manual work that tools can do\n")
+	prompt.WriteString("- Explain which tool you're using and why\n")
+	prompt.WriteString("- Use multiple tools if needed to complete complex tasks\n\n")
+	return prompt.String()
+}
@@ -0,0 +1,76 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/llm/mock"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+)
+
+func TestChatService_DryRunStubsTools(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "weather", Provider: mock.Name, Model: "mock", SystemPrompt: "You report the weather.", Config: models.JSON{}}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+
+	registry := llm.NewRegistry()
+	registry.Register(mock.NewProvider(mock.Options{ReplyWords: 12}))
+	chatService := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+
+	result, err := chatService.DryRun(ctx, agent.ID, &models.DryRunRequest{
+		Message:      "What is the weather?\n" + mock.ToolDirective + ` get_weather {"city": "Berlin"}`,
+		SystemPrompt: "You are terse.",
+		Tools: []models.ToolStub{{
+			Name:        "get_weather",
+			Description: "Current weather of a city",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"city": map[string]interface{}{"type": "string", "description": "City name"}},
+				"required":   []interface{}{"city"},
+			},
+			Response: map[string]interface{}{"temperature": 21},
+		}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Iterations)
+	require.Len(t, result.ToolCalls, 1)
+	call := result.ToolCalls[0]
+	assert.Equal(t, "get_weather", call.ToolName)
+	assert.Equal(t, map[string]interface{}{"city": "Berlin"}, call.Arguments)
+	assert.True(t, call.Success)
+	assert.Contains(t, result.Response, `"temperature":21`)
+	assert.Contains(t, result.SystemPrompt, "You are terse.")
+	assert.Contains(t, result.SystemPrompt, "get_weather**: Current weather of a city")
+	assert.Contains(t, result.SystemPrompt, "city (string, required): City name")
+	assert.Positive(t, result.Usage.TotalTokens)
+
+	// Stubs can fail calls, and nothing is saved
+	result, err = chatService.DryRun(ctx, agent.ID, &models.DryRunRequest{
+		Message: mock.ToolDirective + " get_weather",
+		Tools:   []models.ToolStub{{Name: "get_weather", Error: "service down"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.ToolCalls, 1)
+	assert.False(t, result.ToolCalls[0].Success)
+	assert.Equal(t, "service down", result.ToolCalls[0].Error)
+	assert.Contains(t, result.SystemPrompt, "You report the weather.")
+
+	_, total, err := repo.Session().List(ctx, &models.SessionFilter{AgentID: agent.ID, Status: "all", Limit: 10})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	_, err = chatService.DryRun(ctx, "missing", &models.DryRunRequest{Message: "Hi"})
+	assert.ErrorIs(t, err, services.ErrAgentNotFound)
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+)
+
+// FeatureFlags evaluates the flags of experimental capabilities for chats.
+// Flags come from the config and can be set at runtime; runtime flags are
+// stored, so that instances sharing the database pick them up within the
+// refresh interval.
+type FeatureFlags struct {
+	repo     storage.Repository
+	defaults map[string]*models.FeatureFlag
+	refresh  time.Duration
+	logger   *slog.Logger
+
+	mu       sync.RWMutex
+	flags    map[string]*models.FeatureFlag
+	loadedAt time.Time
+}
+
+// NewFeatureFlags creates the flags with the config's as defaults
+func NewFeatureFlags(repo storage.Repository, defaults []*models.FeatureFlag, refresh time.Duration, logger *slog.Logger) *FeatureFlags {
+	f := &FeatureFlags{
+		repo:     repo,
+		defaults: make(map[string]*models.FeatureFlag, len(defaults)),
+		refresh:  refresh,
+		logger:   logger,
+	}
+	for _, flag := range defaults {
+		flag.Source = models.FeatureFlagSourceConfig
+		f.defaults[flag.Name] = flag
+	}
+	f.flags = f.merge(nil)
+	return f
+}
+
+// Load reads the flags set at runtime
+func (f *FeatureFlags) Load(ctx context.Context) error {
+	stored, err := f.repo.FeatureFlag().List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list feature flags: %w", err)
+	}
+	flags := f.merge(stored)
+	f.mu.Lock()
+	f.flags = flags
+	f.loadedAt = time.Now()
+	f.mu.Unlock()
+	return nil
+}
+
+// merge lays runtime flags over the defaults
+func (f *FeatureFlags) merge(stored []*models.FeatureFlag) map[string]*models.FeatureFlag {
+	flags := make(map[string]*models.FeatureFlag, len(f.defaults)+len(stored))
+	for name, flag := range f.defaults {
+		flags[name] = flag
+	}
+	for _, flag := range stored {
+		flag.Source = models.FeatureFlagSourceRuntime
+		flags[flag.Name] = flag
+	}
+	return flags
+}
+
+// current returns the flags, reloading them once the refresh interval has
+// passed. A failed reload keeps the flags it has.
+func (f *FeatureFlags) current(ctx context.Context) map[string]*models.FeatureFlag {
+	f.mu.RLock()
+	stale := time.Since(f.loadedAt) >= f.refresh
+	f.mu.RUnlock()
+	if stale {
+		if err := f.Load(ctx); err != nil {
+			f.logger.Warn("Failed to reload feature flags", "error", err)
+			f.mu.Lock()
+			f.loadedAt = time.Now()
+			f.mu.Unlock()
+		}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.flags
+}
+
+// List returns every flag, by name
+func (f *FeatureFlags) List(ctx context.Context) []*models.FeatureFlag {
+	flags := f.current(ctx)
+	list := make([]*models.FeatureFlag, 0, len(flags))
+	for _, flag := range flags {
+		list = append(list, flag)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Set stores a flag at runtime, overriding the config's of the same name
+func (f *FeatureFlags) Set(ctx context.Context, flag *models.FeatureFlag) error {
+	if err := f.repo.FeatureFlag().Save(ctx, flag); err != nil {
+		return fmt.Errorf("failed to save feature flag: %w", err)
+	}
+	return f.Load(ctx)
+}
+
+// Reset removes a flag set at runtime. A flag from the config reverts to
+// it; it reports whether there was a flag to remove.
+func (f *FeatureFlags) Reset(ctx context.Context, name string) (bool, error) {
+	flag, exists := f.current(ctx)[name]
+	if !exists || flag.Source != models.FeatureFlagSourceRuntime {
+		return false, nil
+	}
+	if err := f.repo.FeatureFlag().Delete(ctx, name); err != nil {
+		return false, fmt.Errorf("failed to delete feature flag: %w", err)
+	}
+	return true, f.Load(ctx)
+}
+
+// Evaluate returns whether each flag is on for a session
+func (f *FeatureFlags) Evaluate(ctx context.Context, session *models.ChatSession) map[string]bool {
+	flags := f.current(ctx)
+	evaluations := make(map[string]bool, len(flags))
+	for name, flag := range flags {
+		evaluations[name] = flagOn(flag, session)
+	}
+	return evaluations
+}
+
+// FilterTools removes the tools of flags that are off from tools
+func (f *FeatureFlags) FilterTools(ctx context.Context, evaluations map[string]bool, tools []string) []string {
+	gated := make(map[string]bool)
+	for name, flag := range f.current(ctx) {
+		if on, evaluated := evaluations[name]; evaluated && !on {
+			for _, tool := range flag.Tools {
+				gated[tool] = true
+			}
+		}
+	}
+	if len(gated) == 0 {
+		return tools
+	}
+	offered := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		if !gated[tool] {
+			offered = append(offered, tool)
+		}
+	}
+	return offered
+}
+
+// flagOn reports whether a flag is on for a session. The rollout buckets
+// sessions by the flag and session ID, so a conversation keeps its flags.
+func flagOn(flag *models.FeatureFlag, session *models.ChatSession) bool {
+	if flag.Enabled {
+		return true
+	}
+	for _, agentID := range flag.Agents {
+		if agentID == session.AgentID {
+			return true
+		}
+	}
+	if flag.Rollout <= 0 {
+		return false
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(flag.Name + ":" + session.ID))
+	return int(hash.Sum32()%100) < flag.Rollout
+}
+
+// featureFlagsKey carries the flag evaluations of a chat
+type featureFlagsKey struct{}
+
+// withFeatureFlags evaluates the flags for a chat's session and carries
+// them in ctx
+func (s *ChatService) withFeatureFlags(ctx context.Context, session *models.ChatSession) context.Context {
+	if s.flags == nil {
+		return ctx
+	}
+	return withFlagEvaluations(ctx, s.flags.Evaluate(ctx, session))
+}
+
+// withFlagEvaluations carries flag evaluations in ctx
+func withFlagEvaluations(ctx context.Context, evaluations map[string]bool) context.Context {
+	if evaluations == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, featureFlagsKey{}, evaluations)
+}
+
+// gateTools removes the tools of flags that are off for the chat of ctx
+func (s *ChatService) gateTools(ctx context.Context, tools []string) []string {
+	if s.flags == nil {
+		return tools
+	}
+	return s.flags.FilterTools(ctx, featureFlags(ctx), tools)
+}
+
+// featureFlags returns the flag evaluations of a chat, nil without flags
+func featureFlags(ctx context.Context) map[string]bool {
+	evaluations, _ := ctx.Value(featureFlagsKey{}).(map[string]bool)
+	return evaluations
+}
+
+// FeatureEnabled reports whether a flag is on for the chat of ctx, for
+// capabilities behind a flag
+func FeatureEnabled(ctx context.Context, name string) bool {
+	return featureFlags(ctx)[name]
+}
+
+// recordFeatureFlags stores a chat's flag evaluations in the reply's
+// metadata, for comparing replies with and without a capability
+func recordFeatureFlags(ctx context.Context, metadata map[string]interface{}) {
+	if evaluations := featureFlags(ctx); len(evaluations) > 0 {
+		metadata["feature_flags"] = evaluations
+	}
+}
@@ -0,0 +1,106 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatureFlags_Evaluate(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	flags := services.NewFeatureFlags(repo, []*models.FeatureFlag{
+		{Name: "everyone", Enabled: true},
+		{Name: "pilot", Agents: models.StringList{"agent-1"}, Tools: models.StringList{"web_search"}},
+		{Name: "half", Rollout: 50},
+		{Name: "off"},
+	}, time.Minute, slog.Default())
+
+	pilot := flags.Evaluate(ctx, &models.ChatSession{ID: "s1", AgentID: "agent-1"})
+	other := flags.Evaluate(ctx, &models.ChatSession{ID: "s2", AgentID: "agent-2"})
+	assert.True(t, pilot["everyone"])
+	assert.True(t, pilot["pilot"])
+	assert.False(t, other["pilot"])
+	assert.False(t, pilot["off"])
+
+	// Tools of flags that are off are not offered
+	tools := []string{"calculator", "web_search"}
+	assert.Equal(t, tools, flags.FilterTools(ctx, pilot, tools))
+	assert.Equal(t, []string{"calculator"}, flags.FilterTools(ctx, other, tools))
+
+	// A session keeps its rollout bucket, and about half are in it
+	in := 0
+	for i := 0; i < 1000; i++ {
+		session := &models.ChatSession{ID: fmt.Sprintf("session-%d", i), AgentID: "agent-2"}
+		on := flags.Evaluate(ctx, session)["half"]
+		assert.Equal(t, on, flags.Evaluate(ctx, session)["half"])
+		if on {
+			in++
+		}
+	}
+	assert.InDelta(t, 500, in, 75)
+
+	// Runtime flags override the config's until they are reset
+	require.NoError(t, flags.Set(ctx, &models.FeatureFlag{Name: "off", Enabled: true}))
+	assert.True(t, flags.Evaluate(ctx, &models.ChatSession{ID: "s2"})["off"])
+	listed := flags.List(ctx)
+	require.Len(t, listed, 4)
+	assert.Equal(t, "off", listed[2].Name)
+	assert.Equal(t, models.FeatureFlagSourceRuntime, listed[2].Source)
+
+	removed, err := flags.Reset(ctx, "off")
+	require.NoError(t, err)
+	assert.True(t, removed)
+	assert.False(t, flags.Evaluate(ctx, &models.ChatSession{ID: "s2"})["off"])
+
+	// Flags from the config cannot be reset
+	removed, err = flags.Reset(ctx, "everyone")
+	require.NoError(t, err)
+	assert.False(t, removed)
+}
+
+func TestChatService_RecordsFeatureFlags(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "pilot", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{}}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{responses: []*llm.ChatResponse{{Content: "Hello."}}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	toolService := services.NewToolService(repo, slog.Default())
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+	service.SetFeatureFlags(services.NewFeatureFlags(repo, []*models.FeatureFlag{
+		{Name: "pilot", Agents: models.StringList{agent.ID}},
+		{Name: "off"},
+	}, time.Minute, slog.Default()))
+
+	response, err := service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "Hi"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"pilot": true, "off": false}, response.Metadata["feature_flags"])
+
+	// The evaluations are stored with the reply for analysis
+	message, err := repo.Message().GetByID(ctx, response.AssistantMessageID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"pilot": true, "off": false}, message.Metadata["feature_flags"])
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+)
+
+// ErrSameAgent is returned when a session is transferred to the agent it
+// already belongs to
+var ErrSameAgent = errors.New("session already belongs to this agent")
+
+// metadataHandoff marks the notes recording a handoff between agents
+const metadataHandoff = "handoff"
+
+// handoffInstruction asks the outgoing agent for a summary of the
+// conversation written for the agent taking over
+const handoffInstruction = `This conversation is being handed over to %s.%s Write a concise handoff summary for them: what the user wants, what has been established or done so far and what is still open. Address the colleague taking over, not the user, and do not answer the user.`
+
+// Transfer hands a session over to another agent, e.g. to escalate from a
+// triage agent to a specialist. The message history stays with the session
+// and memories stay with the agents that stored them. The handoff is recorded
+// as a visible system note, which with req.Summarize carries a summary
+// written by the outgoing agent for the incoming one.
+func (s *ChatService) Transfer(ctx context.Context, sessionID string, req *models.TransferSessionRequest) (*models.TransferSessionResponse, error) {
+	session, err := s.repo.Session().GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
+	if session.IsArchived() {
+		return nil, ErrSessionArchived
+	}
+	if session.AgentID == req.AgentID {
+		return nil, ErrSameAgent
+	}
+
+	target, err := s.repo.Agent().GetByID(ctx, req.AgentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent: %w", err)
+	}
+	if target == nil {
+		return nil, ErrAgentNotFound
+	}
+	if !target.Enabled {
+		return nil, ErrAgentDisabled
+	}
+
+	outgoing := session.Agent
+	handoff := models.JSON{
+		"from_agent_id":   outgoing.ID,
+		"from_agent_name": outgoing.Name,
+		"to_agent_id":     target.ID,
+		"to_agent_name":   target.Name,
+	}
+	content := fmt.Sprintf("Conversation transferred from %s to %s.", outgoing.Name, target.Name)
+	if req.Reason != "" {
+		handoff["reason"] = req.Reason
+		content += "\nReason: " + req.Reason
+	}
+	metadata := models.JSON{"note": true}
+
+	// The summary is written before anything changes, so a failed LLM call
+	// leaves the session with its agent
+	if req.Summarize {
+		summary, err := s.handoffSummary(ctx, session, target, req.Reason, metadata)
+		if err != nil {
+			return nil, err
+		}
+		content += "\n\nHandoff summary from " + outgoing.Name + ":\n" + summary
+	}
+	metadata[metadataHandoff] = handoff
+
+	note := &models.Message{
+		SessionID: sessionID,
+		Role:      "system",
+		Content:   s.scrubMessage(ctx, &outgoing, content),
+		Metadata:  models.JSON(s.redactor.Map(metadata)),
+	}
+	session.AgentID = target.ID
+	session.Agent = *target
+	err = s.repo.WithTx(ctx, func(tx storage.Repository) error {
+		if err := tx.Message().Create(ctx, note); err != nil {
+			return err
+		}
+		return tx.Session().Update(ctx, session)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to transfer session: %w", err)
+	}
+
+	s.logger.Info("Session transferred",
+		"session_id", sessionID,
+		"from_agent_id", outgoing.ID,
+		"to_agent_id", target.ID,
+		"summarized", req.Summarize)
+	return &models.TransferSessionResponse{Session: session, Handoff: note}, nil
+}
+
+// handoffSummary asks the session's current agent to summarize the
+// conversation for target. The usage of the call is added to metadata.
+func (s *ChatService) handoffSummary(ctx context.Context, session *models.ChatSession, target *models.Agent, reason string, metadata models.JSON) (string, error) {
+	agent := &session.Agent
+	if err := checkSessionAvailable(session); err != nil {
+		return "", err
+	}
+	if err := s.accounting.CheckBudget(ctx, session); err != nil {
+		return "", err
+	}
+
+	provider, exists := s.llmRegistry.Get(agent.Provider)
+	if !exists {
+		return "", fmt.Errorf("%w: unsupported provider %s", ErrProviderUnavailable, agent.Provider)
+	}
+	if !provider.IsAvailable(ctx) {
+		return "", fmt.Errorf("%w: %s", ErrProviderUnavailable, agent.Provider)
+	}
+
+	if reason != "" {
+		reason = " Reason: " + reason + "."
+	}
+	instruction := &models.Message{
+		SessionID: session.ID,
+		Role:      "user",
+		Content:   fmt.Sprintf(handoffInstruction, target.Name, reason),
+		CreatedAt: time.Now(),
+	}
+	contextMessages, _, err := s.buildTurnContext(ctx, session, renderVariables(agent.SystemPromptFor(session.Language), session.Variables), instruction)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := provider.Chat(ctx, &llm.ChatRequest{
+		Model:       agent.Model,
+		Messages:    llm.ConvertMessages(contextMessages),
+		Temperature: agent.Temperature,
+		MaxTokens:   agent.MaxTokens,
+		Options:     agent.Config,
+	})
+	if err != nil {
+		return "", s.providerError(agent, err)
+	}
+
+	metadata["provider"] = agent.Provider
+	metadata["model"] = agent.Model
+	if response.Usage != nil {
+		metadata["usage"] = usageMetadata(response.Usage)
+	}
+	s.recordCost(metadata, agent, response.Usage)
+	return strings.TrimSpace(response.Content), nil
+}
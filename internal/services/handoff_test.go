@@ -0,0 +1,81 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+)
+
+func TestChatService_Transfer(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	triage := &models.Agent{Name: "triage-bot", Provider: "ollama", Model: "llama3", Enabled: true, SystemPrompt: "You triage.", Config: models.JSON{}}
+	require.NoError(t, repo.Agent().Create(ctx, triage))
+	specialist := &models.Agent{Name: "specialist-bot", Provider: "ollama", Model: "llama3", Enabled: true, SystemPrompt: "You fix things.", Config: models.JSON{}}
+	require.NoError(t, repo.Agent().Create(ctx, specialist))
+	session := &models.ChatSession{AgentID: triage.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+	require.NoError(t, repo.Message().Create(ctx, &models.Message{SessionID: session.ID, Role: "user", Content: "My router keeps rebooting"}))
+	require.NoError(t, repo.Message().Create(ctx, &models.Message{SessionID: session.ID, Role: "assistant", Content: "Let me find someone who can help."}))
+
+	provider := &scriptedProvider{responses: []*llm.ChatResponse{
+		{Content: " The user's router reboots repeatedly; no troubleshooting done yet. "},
+	}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), nil, nil, slog.Default())
+
+	response, err := service.Transfer(ctx, session.ID, &models.TransferSessionRequest{
+		AgentID: specialist.ID, Summarize: true, Reason: "hardware issue",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, specialist.ID, response.Session.AgentID)
+
+	// The outgoing agent summarized the history
+	require.Len(t, provider.requests, 1)
+	messages := provider.requests[0].Messages
+	assert.Equal(t, "You triage.", messages[0].Content)
+	assert.Equal(t, "My router keeps rebooting", messages[1].Content)
+	assert.Contains(t, messages[len(messages)-1].Content, "handed over to specialist-bot. Reason: hardware issue.")
+
+	// History is kept and the handoff recorded
+	saved, err := repo.Session().GetByID(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, specialist.ID, saved.AgentID)
+	assert.Equal(t, "specialist-bot", saved.Agent.Name)
+	history, total, err := repo.Message().ListVisibleBySessionID(ctx, session.ID, -1, -1)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), total)
+	note := history[2]
+	assert.Equal(t, response.Handoff.ID, note.ID)
+	assert.Equal(t, "system", note.Role)
+	assert.Equal(t, "Conversation transferred from triage-bot to specialist-bot.\nReason: hardware issue\n\n"+
+		"Handoff summary from triage-bot:\nThe user's router reboots repeatedly; no troubleshooting done yet.", note.Content)
+	handoff := note.Metadata["handoff"].(map[string]interface{})
+	assert.Equal(t, triage.ID, handoff["from_agent_id"])
+	assert.Equal(t, specialist.ID, handoff["to_agent_id"])
+
+	// Transfers to the current agent or unknown agents are rejected
+	_, err = service.Transfer(ctx, session.ID, &models.TransferSessionRequest{AgentID: specialist.ID})
+	assert.ErrorIs(t, err, services.ErrSameAgent)
+	_, err = service.Transfer(ctx, session.ID, &models.TransferSessionRequest{AgentID: "missing"})
+	assert.ErrorIs(t, err, services.ErrAgentNotFound)
+
+	// Without a summary no LLM call is made
+	response, err = service.Transfer(ctx, session.ID, &models.TransferSessionRequest{AgentID: triage.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "Conversation transferred from specialist-bot to triage-bot.", response.Handoff.Content)
+	assert.Len(t, provider.requests, 1)
+}
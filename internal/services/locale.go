@@ -0,0 +1,36 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"agent-server/internal/lang"
+	"agent-server/internal/models"
+)
+
+// metadataLanguage is the metadata key of the language detected for a user
+// message
+const metadataLanguage = "language"
+
+// localize detects the language of a user message before it is saved and
+// keeps the session's language up to date. It returns the system prompt for
+// the reply: the agent's variant for responseLanguage if given, else for the
+// session's language, with the session's variables filled in.
+func (s *ChatService) localize(ctx context.Context, session *models.ChatSession, message *models.Message, responseLanguage string) string {
+	if detected := lang.Detect(message.Content); detected != "" {
+		message.Metadata = withMetadata(message.Metadata, metadataLanguage, detected)
+
+		if detected != session.Language {
+			session.Language = detected
+			if err := s.repo.Session().SetLanguage(ctx, session.ID, detected); err != nil {
+				s.logger.Warn("Failed to record session language", "session_id", session.ID, "error", err)
+			}
+		}
+	}
+
+	if responseLanguage == "" {
+		return renderVariables(session.Agent.SystemPromptFor(session.Language), session.Variables)
+	}
+	return renderVariables(session.Agent.SystemPromptFor(responseLanguage), session.Variables) +
+		fmt.Sprintf("\n\nAlways respond in %s, whatever the language of the user's messages.", lang.Name(responseLanguage))
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+
+	"agent-server/internal/models"
+	"agent-server/internal/tools/builtin"
+)
+
+// recallMessages is how many of the latest messages are searched for the
+// user message that memory recalls are compared with
+const recallMessages = 10
+
+// withRecallHints gives memory recalls the agent's ranking weights and the
+// user's latest message to rank by
+func (ts *ToolService) withRecallHints(ctx context.Context, session *models.ChatSession, toolName string, input map[string]interface{}) context.Context {
+	if toolName != "memory" {
+		return ctx
+	}
+	if action, _ := input["action"].(string); action != "recall" {
+		return ctx
+	}
+
+	hints := builtin.RecallHints{Ranking: session.Agent.MemoryRanking}
+	messages, err := ts.repository.Message().GetLastNMessages(ctx, session.ID, recallMessages)
+	if err != nil {
+		// Recalls are still ranked, only without similarity
+		ts.logger.Warn("Failed to read the latest user message for memory ranking",
+			"session_id", session.ID,
+			"error", err)
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			hints.Message = messages[i].Content
+			break
+		}
+	}
+	return builtin.WithRecallHints(ctx, hints)
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"agent-server/internal/llm"
+)
+
+// Model pull states
+const (
+	PullRunning   = "pulling"
+	PullCompleted = "completed"
+	PullFailed    = "failed"
+)
+
+// ErrPullUnsupported is returned when a provider cannot download models
+var ErrPullUnsupported = errors.New("provider cannot pull models")
+
+// ModelPull is a model download on a provider
+type ModelPull struct {
+	Provider   string     `json:"provider"`
+	Model      string     `json:"model"`
+	Status     string     `json:"status"`
+	Detail     string     `json:"detail,omitempty"` // the provider's last progress message
+	Completed  int64      `json:"completed_bytes"`
+	Total      int64      `json:"total_bytes"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ModelPuller downloads models onto providers in the background, one
+// download per model at a time, and keeps the last download of each model
+// so that its progress can be polled
+type ModelPuller struct {
+	registry *llm.Registry
+	logger   *slog.Logger
+
+	mu    sync.Mutex
+	pulls map[string]*ModelPull // by provider and model
+}
+
+// NewModelPuller creates a puller for the providers in registry
+func NewModelPuller(registry *llm.Registry, logger *slog.Logger) *ModelPuller {
+	return &ModelPuller{
+		registry: registry,
+		logger:   logger,
+		pulls:    make(map[string]*ModelPull),
+	}
+}
+
+// CanPull reports whether the named provider can download models
+func (p *ModelPuller) CanPull(providerName string) bool {
+	provider, ok := p.registry.Get(providerName)
+	if !ok {
+		return false
+	}
+	_, ok = llm.AsPuller(provider)
+	return ok
+}
+
+// Pull starts downloading model onto the named provider and returns its
+// progress. A download of the model that is still running is returned
+// instead of starting another.
+func (p *ModelPuller) Pull(providerName, model string) (ModelPull, error) {
+	provider, ok := p.registry.Get(providerName)
+	if !ok {
+		return ModelPull{}, fmt.Errorf("%w: %s", ErrProviderUnavailable, providerName)
+	}
+	puller, ok := llm.AsPuller(provider)
+	if !ok {
+		return ModelPull{}, fmt.Errorf("%w: %s", ErrPullUnsupported, providerName)
+	}
+
+	key := providerName + "/" + model
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pull, ok := p.pulls[key]; ok && pull.Status == PullRunning {
+		return *pull, nil
+	}
+	pull := &ModelPull{Provider: providerName, Model: model, Status: PullRunning, StartedAt: time.Now()}
+	p.pulls[key] = pull
+	go p.run(puller, pull)
+	return *pull, nil
+}
+
+// run downloads the model of pull, updating it as progress is reported
+func (p *ModelPuller) run(puller llm.Puller, pull *ModelPull) {
+	p.logger.Info("Pulling model", "provider", pull.Provider, "model", pull.Model)
+	err := puller.Pull(context.Background(), pull.Model, func(progress llm.PullProgress) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		pull.Detail = progress.Status
+		pull.Completed = progress.Completed
+		pull.Total = progress.Total
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	finished := time.Now()
+	pull.FinishedAt = &finished
+	if err != nil {
+		pull.Status = PullFailed
+		pull.Error = err.Error()
+		p.logger.Warn("Failed to pull model", "provider", pull.Provider, "model", pull.Model, "error", err)
+		return
+	}
+	pull.Status = PullCompleted
+	p.logger.Info("Pulled model", "provider", pull.Provider, "model", pull.Model,
+		"duration", finished.Sub(pull.StartedAt))
+}
+
+// List returns the downloads on the named provider, most recent first
+func (p *ModelPuller) List(providerName string) []ModelPull {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pulls := make([]ModelPull, 0)
+	for _, pull := range p.pulls {
+		if pull.Provider == providerName {
+			pulls = append(pulls, *pull)
+		}
+	}
+	sort.Slice(pulls, func(i, j int) bool {
+		return pulls[i].StartedAt.After(pulls[j].StartedAt)
+	})
+	return pulls
+}
+
+// ModelMissingError is a chat that failed because the agent's model is not
+// present on its provider
+type ModelMissingError struct {
+	Provider string
+	Model    string
+	CanPull  bool       // whether the provider can download the model
+	Pull     *ModelPull // the download started for the model, if auto-pull is enabled
+	Err      error
+}
+
+func (e *ModelMissingError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the provider error
+func (e *ModelMissingError) Unwrap() error {
+	return e.Err
+}
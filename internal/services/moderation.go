@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"agent-server/internal/models"
+	"agent-server/internal/moderation"
+)
+
+// ErrContentBlocked is returned when moderation blocks a user message
+var ErrContentBlocked = errors.New("message blocked by content moderation")
+
+// metadataModeration is the metadata key of a message's moderation verdict
+const metadataModeration = "moderation"
+
+// withheldReply replaces replies that moderation blocks
+const withheldReply = "This reply was withheld by content moderation."
+
+// SetModeration enables content moderation for agents that ask for it,
+// deciding on the moderator's scores by policy
+func (s *ChatService) SetModeration(moderator moderation.Moderator, policy moderation.Policy) {
+	s.moderator = moderator
+	s.moderationPolicy = policy
+}
+
+// moderate scores text when the agent moderates its input or, with output
+// set, its replies. It returns nil when the text is not moderated. Messages
+// are allowed when no moderator can score them.
+func (s *ChatService) moderate(ctx context.Context, agent *models.Agent, text string, output bool) *moderation.Verdict {
+	settings := agent.Moderation
+	if s.moderator == nil || settings == nil || (output && !settings.Output) || (!output && !settings.Input) {
+		return nil
+	}
+
+	result, err := s.moderator.Moderate(ctx, text)
+	if err != nil {
+		s.logger.Warn("Moderation failed, allowing message", "agent_id", agent.ID, "error", err)
+		return &moderation.Verdict{Action: moderation.ActionAllow, Error: err.Error()}
+	}
+	return s.moderationPolicy.WithThresholds(settings.FlagThreshold, settings.BlockThreshold).Decide(result)
+}
+
+// moderateInput records the verdict on a user message before it is saved.
+// A blocked message is saved as an incomplete turn, so it stays out of the
+// context, and ErrContentBlocked is returned.
+func (s *ChatService) moderateInput(ctx context.Context, session *models.ChatSession, message *models.Message) error {
+	verdict := s.moderate(ctx, &session.Agent, message.Content, false)
+	if verdict == nil {
+		return nil
+	}
+	message.Metadata = withMetadata(message.Metadata, metadataModeration, verdict)
+	if verdict.Action != moderation.ActionBlock {
+		return nil
+	}
+
+	message.Status = models.MessageStatusIncomplete
+	if err := s.repo.Message().Create(ctx, message); err != nil {
+		return fmt.Errorf("failed to save user message: %w", err)
+	}
+	s.logger.Warn("User message blocked by moderation",
+		"session_id", session.ID,
+		"message_id", message.ID,
+		"categories", verdict.Categories)
+	return fmt.Errorf("%w: %s", ErrContentBlocked, strings.Join(verdict.Categories, ", "))
+}
+
+// moderateOutput records the verdict on a reply in its metadata and returns
+// the content to save, which is withheldReply when the reply is blocked
+func (s *ChatService) moderateOutput(ctx context.Context, agent *models.Agent, content string, metadata map[string]interface{}) string {
+	verdict := s.moderate(ctx, agent, content, true)
+	if verdict == nil {
+		return content
+	}
+	metadata[metadataModeration] = verdict
+	if verdict.Action != moderation.ActionBlock {
+		return content
+	}
+	s.logger.Warn("Reply withheld by moderation", "agent_id", agent.ID, "categories", verdict.Categories)
+	return withheldReply
+}
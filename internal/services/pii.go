@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/pii"
+)
+
+// scrubPII replaces the personal data of the policy's types in v. When the
+// entity recognition endpoint fails, pattern matches are still replaced.
+func scrubPII(ctx context.Context, scrubber *pii.Scrubber, logger *slog.Logger, policy *models.AgentPII, v interface{}) interface{} {
+	scrubbed, err := scrubber.Value(ctx, v, policy.Types)
+	if err != nil {
+		logger.Warn("PII detection failed, scrubbing pattern matches only", "error", err)
+	}
+	return scrubbed
+}
+
+// scrubArgumentsJSON scrubs serialized tool call arguments
+func scrubArgumentsJSON(ctx context.Context, scrubber *pii.Scrubber, logger *slog.Logger, policy *models.AgentPII, arguments string) string {
+	scrubbed, err := scrubber.JSON(ctx, arguments, policy.Types)
+	if err != nil {
+		logger.Warn("PII detection failed, scrubbing pattern matches only", "error", err)
+	}
+	return scrubbed
+}
+
+// SetPIIScrubber enables scrubbing of personal data from the messages and
+// tool calls of agents with a data-handling policy
+func (s *ChatService) SetPIIScrubber(scrubber *pii.Scrubber) {
+	s.piiScrubber = scrubber
+}
+
+// scrubsMessages reports whether the agent's stored messages are scrubbed
+func (s *ChatService) scrubsMessages(agent *models.Agent) bool {
+	return s.piiScrubber != nil && agent.PII != nil && agent.PII.Messages
+}
+
+// scrubMessage returns the content of a message as the agent's policy
+// stores it
+func (s *ChatService) scrubMessage(ctx context.Context, agent *models.Agent, content string) string {
+	if !s.scrubsMessages(agent) {
+		return content
+	}
+	return scrubPII(ctx, s.piiScrubber, s.logger, agent.PII, content).(string)
+}
+
+// storedToolCalls returns copies of a reply requesting tools, its tool calls
+// and their results as the agent's policy stores them. The originals stay in
+// the conversation sent to the LLM.
+func (s *ChatService) storedToolCalls(
+	ctx context.Context,
+	agent *models.Agent,
+	response *llm.ChatResponse,
+	toolCalls []models.LLMToolCall,
+	toolResults []models.ToolCallResult,
+) (*llm.ChatResponse, []models.LLMToolCall, []models.ToolCallResult) {
+	policy := agent.PII
+	if s.piiScrubber == nil || policy == nil {
+		return response, toolCalls, toolResults
+	}
+
+	stored := *response
+	storedCalls := append([]models.LLMToolCall(nil), toolCalls...)
+	storedResults := append([]models.ToolCallResult(nil), toolResults...)
+	if policy.Messages {
+		stored.Content = s.scrubMessage(ctx, agent, response.Content)
+		for i := range storedResults {
+			storedResults[i].Result = scrubPII(ctx, s.piiScrubber, s.logger, policy, storedResults[i].Result)
+		}
+	}
+	if policy.ToolArguments {
+		for i := range storedCalls {
+			storedCalls[i].Function.Arguments = scrubArgumentsJSON(ctx, s.piiScrubber, s.logger, policy, storedCalls[i].Function.Arguments)
+		}
+		// Providers report the calls, with their arguments, in the metadata
+		if calls, ok := response.Metadata["tool_calls"]; ok {
+			stored.Metadata = withMetadata(response.Metadata, "tool_calls", scrubPII(ctx, s.piiScrubber, s.logger, policy, calls))
+		}
+	}
+	return &stored, storedCalls, storedResults
+}
+
+// unscrubbed returns an in-memory copy of a stored message with its
+// original content, for the conversation sent to the LLM
+func unscrubbed(message *models.Message, content string) *models.Message {
+	original := *message
+	original.Content = content
+	return &original
+}
+
+// SetPIIScrubber enables scrubbing of personal data from the tool arguments
+// and memories of agents with a data-handling policy
+func (ts *ToolService) SetPIIScrubber(scrubber *pii.Scrubber) {
+	ts.piiScrubber = scrubber
+}
+
+// scrubArguments returns tool arguments as the agent's policy stores them
+// in execution records
+func (ts *ToolService) scrubArguments(ctx context.Context, agent *models.Agent, arguments map[string]interface{}) map[string]interface{} {
+	if ts.piiScrubber == nil || agent == nil || agent.PII == nil || !agent.PII.ToolArguments {
+		return arguments
+	}
+	scrubbed, _ := scrubPII(ctx, ts.piiScrubber, ts.logger, agent.PII, arguments).(map[string]interface{})
+	return scrubbed
+}
+
+// scrubMemoryInput scrubs what the memory tool is about to store when the
+// agent's policy covers memories. Searches and recalls are left alone.
+func (ts *ToolService) scrubMemoryInput(ctx context.Context, agent *models.Agent, toolName string, input map[string]interface{}) map[string]interface{} {
+	if ts.piiScrubber == nil || agent.PII == nil || !agent.PII.Memories || toolName != "memory" {
+		return input
+	}
+	if action, _ := input["action"].(string); action != "store" && action != "update" {
+		return input
+	}
+
+	scrubbed := make(map[string]interface{}, len(input))
+	for key, value := range input {
+		scrubbed[key] = value
+	}
+	for _, key := range []string{"topic", "content", "tags"} {
+		if value, ok := input[key]; ok {
+			scrubbed[key] = scrubPII(ctx, ts.piiScrubber, ts.logger, agent.PII, value)
+		}
+	}
+	return scrubbed
+}
+
+// agentForSession returns the agent of a session, or nil when it cannot be
+// read
+func (ts *ToolService) agentForSession(ctx context.Context, sessionID string) *models.Agent {
+	if ts.piiScrubber == nil {
+		return nil
+	}
+	session, err := ts.getSession(ctx, sessionID)
+	if err != nil {
+		ts.logger.Warn("Failed to read data-handling policy", "session_id", sessionID, "error", err)
+		return nil
+	}
+	return &session.Agent
+}
@@ -0,0 +1,82 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/pii"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+)
+
+func TestChatService_ScrubsPII(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{
+		Name: "private", Provider: "ollama", Model: "llama3", Enabled: true, SystemPrompt: "You are helpful.", Config: models.JSON{},
+		PII: &models.AgentPII{Messages: true, ToolArguments: true, Memories: true},
+	}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{responses: []*llm.ChatResponse{
+		{Metadata: map[string]interface{}{"tool_calls": []map[string]interface{}{
+			{"function": map[string]interface{}{"name": "memory", "arguments": map[string]interface{}{
+				"action": "store", "topic": "contact", "content": "Email is jane@example.com",
+			}}},
+		}}},
+		{Content: "Noted, I will write to jane@example.com."},
+	}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	toolService := services.NewToolService(repo, slog.Default())
+	toolService.SetPIIScrubber(pii.New())
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+	service.SetPIIScrubber(pii.New())
+
+	response, err := service.ChatWithTools(ctx, &models.EnhancedChatRequest{
+		Message: "Remember my email jane@example.com and call me at +1 415 555 0100",
+	}, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Noted, I will write to jane@example.com.", response.Response)
+
+	// The model saw the original message in both iterations
+	require.Len(t, provider.requests, 2)
+	for _, request := range provider.requests {
+		assert.Contains(t, request.Messages[1].Content, "jane@example.com")
+	}
+
+	// Stored copies hold placeholders
+	user, err := repo.Message().GetByID(ctx, response.UserMessageID)
+	require.NoError(t, err)
+	assert.Equal(t, "Remember my email [EMAIL] and call me at [PHONE]", user.Content)
+	reply, err := repo.Message().GetByID(ctx, response.AssistantMessageID)
+	require.NoError(t, err)
+	assert.Equal(t, "Noted, I will write to [EMAIL].", reply.Content)
+
+	toolCalls, err := repo.ToolCall().ListBySessionID(ctx, session.ID)
+	require.NoError(t, err)
+	require.Len(t, toolCalls, 1)
+	assert.Equal(t, "Email is [EMAIL]", toolCalls[0].Arguments["content"])
+	logs, _, err := repo.ToolExecutionLog().ListBySessionID(ctx, session.ID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "Email is [EMAIL]", logs[0].Arguments["content"])
+
+	memories, err := repo.Memory().ListByAgent(ctx, agent.ID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, memories, 1)
+	assert.Equal(t, "Email is [EMAIL]", memories[0].Content)
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"fmt"
+
+	"agent-server/internal/models"
+	"agent-server/internal/postprocess"
+)
+
+// SetPostProcessing sets the pipeline replies pass before they are saved.
+// Agents choose their own steps with the "postprocess" config entry; nil
+// leaves replies as the provider returned them.
+func (s *ChatService) SetPostProcessing(pipeline *postprocess.Pipeline) {
+	s.postProcessing = pipeline
+}
+
+// pipelineFor returns the post-processing pipeline of an agent. Steps the
+// agent configures that are unknown fall back to the server's pipeline.
+func (s *ChatService) pipelineFor(agent *models.Agent) *postprocess.Pipeline {
+	steps, ok := agent.PostProcessSteps()
+	if !ok {
+		return s.postProcessing
+	}
+	pipeline, err := postprocess.New(steps)
+	if err != nil {
+		s.logger.Warn("Invalid agent post-processing, using the default", "agent_id", agent.ID, "error", err)
+		return s.postProcessing
+	}
+	return pipeline
+}
+
+// postProcess applies the agent's pipeline to a reply, recording extracted
+// attachments in metadata
+func (s *ChatService) postProcess(agent *models.Agent, content string, metadata map[string]interface{}) (string, []models.Attachment) {
+	pipeline := s.pipelineFor(agent)
+	if pipeline == nil {
+		return content, nil
+	}
+	result := pipeline.Process(content)
+	if len(result.Attachments) > 0 {
+		metadata["attachments"] = result.Attachments
+	}
+	return result.Content, result.Attachments
+}
+
+// responseFormat negotiates the format of a reply from the accept option of
+// a request
+func responseFormat(accept string, supported ...string) (string, error) {
+	format, err := postprocess.Negotiate(accept, supported...)
+	if err != nil {
+		return "", fmt.Errorf("invalid accept option: %w", err)
+	}
+	return format, nil
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"agent-server/internal/models"
+	"agent-server/internal/tools"
+)
+
+const (
+	// minToolDescription is the length below which a tool description
+	// rarely tells the model when to use the tool
+	minToolDescription = 20
+	// promptWindowShare is the share of the context window a prompt and its
+	// tool definitions may take before too little is left for the
+	// conversation
+	promptWindowShare = 0.5
+)
+
+var (
+	// sentenceEnd splits prompts into sentences and instruction lines
+	sentenceEnd = regexp.MustCompile(`[.!?;\n]+`)
+	// alwaysRule and neverRule find opposite rules about the same action
+	alwaysRule = regexp.MustCompile(`\b(?:always|must)\s+(\w+(?:\s+\w+)?)`)
+	neverRule  = regexp.MustCompile(`\b(?:never|do not|don't|must not|mustn't)\s+(\w+(?:\s+\w+)?)`)
+	// languageRule finds the language replies are asked for
+	languageRule = regexp.MustCompile(`\b(?:respond|reply|answer|write)\s+(?:only\s+)?in\s+(\w+)`)
+)
+
+// replyLanguages are the language names languageRule recognizes
+var replyLanguages = map[string]bool{
+	"english": true, "german": true, "french": true, "spanish": true, "italian": true,
+	"portuguese": true, "dutch": true, "polish": true, "swedish": true, "danish": true,
+	"norwegian": true, "finnish": true, "czech": true, "turkish": true, "russian": true,
+	"ukrainian": true, "chinese": true, "japanese": true, "korean": true, "arabic": true,
+}
+
+// replyLengths are opposite asks for the length of replies
+var replyLengths = [2][]string{
+	{"be concise", "be brief", "keep answers short", "keep responses short", "short answers", "succinct"},
+	{"be detailed", "be thorough", "be verbose", "detailed answers", "in-depth", "elaborate answers", "long answers"},
+}
+
+// Lint checks an agent's system prompts and tools for issues that make
+// agents behave badly without failing outright: instructions that
+// contradict each other, prompts that crowd out the conversation, tools the
+// model cannot tell how to use, and tools enabled twice under similar names.
+// Unlike ValidateTools it only warns.
+func (s *AgentStatusService) Lint(ctx context.Context, agent *models.Agent) []models.LintWarning {
+	warnings := []models.LintWarning{}
+
+	prompts := map[string]string{"system_prompt": agent.SystemPrompt}
+	for language, prompt := range agent.LocalizedPrompts {
+		prompts["localized_system_prompts."+language] = prompt
+	}
+	fields := make([]string, 0, len(prompts))
+	for field := range prompts {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		warnings = append(warnings, lintPrompt(field, prompts[field])...)
+	}
+
+	definitions := s.lintTools(agent, &warnings)
+	warnings = append(warnings, s.lintPromptLength(ctx, agent, definitions)...)
+	return warnings
+}
+
+// lintPrompt looks for an empty prompt and contradicting instructions
+func lintPrompt(field, prompt string) []models.LintWarning {
+	if strings.TrimSpace(prompt) == "" {
+		return []models.LintWarning{{
+			Code:    models.LintPromptEmpty,
+			Field:   field,
+			Message: "the system prompt is empty; the model gets no instructions",
+		}}
+	}
+
+	var warnings []models.LintWarning
+	contradiction := func(message string) {
+		warnings = append(warnings, models.LintWarning{Code: models.LintContradiction, Field: field, Message: message})
+	}
+
+	always := map[string]string{}
+	never := map[string]string{}
+	languages := map[string]bool{}
+	for _, sentence := range sentenceEnd.Split(strings.ToLower(prompt), -1) {
+		sentence = strings.TrimSpace(sentence)
+		for _, match := range alwaysRule.FindAllStringSubmatch(sentence, -1) {
+			if _, seen := always[match[1]]; !seen {
+				always[match[1]] = sentence
+			}
+		}
+		for _, match := range neverRule.FindAllStringSubmatch(sentence, -1) {
+			if _, seen := never[match[1]]; !seen {
+				never[match[1]] = sentence
+			}
+		}
+		for _, match := range languageRule.FindAllStringSubmatch(sentence, -1) {
+			if replyLanguages[match[1]] {
+				languages[match[1]] = true
+			}
+		}
+	}
+
+	actions := make([]string, 0, len(always))
+	for action := range always {
+		if _, ok := never[action]; ok {
+			actions = append(actions, action)
+		}
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		contradiction(fmt.Sprintf("%q contradicts %q", always[action], never[action]))
+	}
+
+	if len(languages) > 1 {
+		names := make([]string, 0, len(languages))
+		for name := range languages {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		contradiction(fmt.Sprintf("replies are asked for in several languages: %s", strings.Join(names, ", ")))
+	}
+
+	lower := strings.ToLower(prompt)
+	short, long := firstContained(lower, replyLengths[0]), firstContained(lower, replyLengths[1])
+	if short != "" && long != "" {
+		contradiction(fmt.Sprintf("the prompt asks for both %q and %q replies", short, long))
+	}
+	return warnings
+}
+
+// firstContained returns the first of phrases that s contains
+func firstContained(s string, phrases []string) string {
+	for _, phrase := range phrases {
+		if strings.Contains(s, phrase) {
+			return phrase
+		}
+	}
+	return ""
+}
+
+// lintTools checks the descriptions and names of the agent's tools and
+// returns the definitions the model will be given
+func (s *AgentStatusService) lintTools(agent *models.Agent, warnings *[]models.LintWarning) []models.ToolDefinition {
+	if s.toolService == nil {
+		return nil
+	}
+	registry := s.toolService.GetRegistry()
+	names := agent.AllowedTools()
+
+	var definitions []models.ToolDefinition
+	seen := map[string]int{}       // by name
+	normalized := map[string]int{} // by name without case and separators
+	for i, name := range names {
+		field := fmt.Sprintf("config.tools[%d]", i)
+		if first, ok := seen[name]; ok {
+			*warnings = append(*warnings, models.LintWarning{
+				Code:    models.LintDuplicateTool,
+				Field:   field,
+				Message: fmt.Sprintf("tool %s is already enabled as config.tools[%d]", name, first),
+			})
+			continue
+		}
+		seen[name] = i
+		key := normalizeToolName(name)
+		if first, ok := normalized[key]; ok {
+			*warnings = append(*warnings, models.LintWarning{
+				Code:    models.LintDuplicateTool,
+				Field:   field,
+				Message: fmt.Sprintf("tools %s and %s differ only in case or separators; the model is likely to confuse them", names[first], name),
+			})
+		} else {
+			normalized[key] = i
+		}
+
+		tool, exists := registry.Get(name)
+		if !exists {
+			continue // ValidateTools reports unknown tools
+		}
+		schema := tools.OmitPresetParameters(tool.Schema(), agent.ToolPresets(name))
+		switch description := strings.TrimSpace(schema.Description); {
+		case description == "":
+			*warnings = append(*warnings, models.LintWarning{
+				Code:    models.LintToolDescriptionMissing,
+				Field:   field,
+				Message: fmt.Sprintf("tool %s has no description; the model cannot tell when to use it", name),
+			})
+		case utf8.RuneCountInString(description) < minToolDescription:
+			*warnings = append(*warnings, models.LintWarning{
+				Code:    models.LintToolDescriptionShort,
+				Field:   field,
+				Message: fmt.Sprintf("the description of tool %s (%q) is too short to tell the model when to use it", name, description),
+			})
+		}
+		for _, parameter := range schema.Parameters {
+			if strings.TrimSpace(parameter.Description) == "" {
+				*warnings = append(*warnings, models.LintWarning{
+					Code:    models.LintToolParameterUndocumented,
+					Field:   field,
+					Message: fmt.Sprintf("parameter %s of tool %s has no description", parameter.Name, name),
+				})
+			}
+		}
+		definitions = append(definitions, models.ToolDefinition{
+			Type: "function",
+			Function: models.ToolFunctionDefinition{
+				Name:        schema.Name,
+				Description: schema.Description,
+				Parameters:  convertSchemaToJSONSchema(schema),
+			},
+		})
+	}
+
+	// A prompt that tells the model to use a tool the agent does not enable
+	// leads to refusals or invented results
+	if len(names) > 0 {
+		available := registry.List()
+		sort.Strings(available)
+		for _, name := range available {
+			if _, enabled := seen[name]; enabled {
+				continue
+			}
+			pattern := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`)
+			if pattern.MatchString(agent.SystemPrompt) {
+				*warnings = append(*warnings, models.LintWarning{
+					Code:    models.LintToolNotEnabled,
+					Field:   "system_prompt",
+					Message: fmt.Sprintf("the prompt mentions tool %s, which the agent does not enable", name),
+				})
+			}
+		}
+	}
+	return definitions
+}
+
+// normalizeToolName drops case and separators from a tool name
+func normalizeToolName(name string) string {
+	return strings.NewReplacer("_", "", "-", "", ".", "", " ", "").Replace(strings.ToLower(name))
+}
+
+// lintPromptLength warns when the system prompt and tool definitions take
+// so much of the context window that little is left for the conversation
+func (s *AgentStatusService) lintPromptLength(ctx context.Context, agent *models.Agent, definitions []models.ToolDefinition) []models.LintWarning {
+	if s.chatService == nil {
+		return nil
+	}
+	window, _ := s.chatService.contextWindows.Resolve(ctx, agent)
+	if window == 0 {
+		return nil
+	}
+
+	prompt := agent.SystemPrompt
+	for _, localized := range agent.LocalizedPrompts {
+		if len(localized) > len(prompt) {
+			prompt = localized
+		}
+	}
+	messages := []string{prompt}
+	if len(definitions) > 0 {
+		data, _ := json.Marshal(definitions)
+		messages = append(messages, string(data))
+	}
+	tokens := 0
+	for _, message := range messages {
+		tokens += (utf8.RuneCountInString(message) + 3) / 4
+	}
+	if float64(tokens) <= promptWindowShare*float64(window) {
+		return nil
+	}
+	return []models.LintWarning{{
+		Code:  models.LintPromptTooLong,
+		Field: "system_prompt",
+		Message: fmt.Sprintf("the system prompt and tool definitions take about %d of the model's %d context tokens (%.0f%%); "+
+			"little is left for the conversation", tokens, window, 100*float64(tokens)/float64(window)),
+	}}
+}
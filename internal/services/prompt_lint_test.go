@@ -0,0 +1,98 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+	"agent-server/internal/tools"
+)
+
+// bareTool is a tool with a terse description and an undocumented parameter
+type bareTool struct{}
+
+func (bareTool) Name() string { return "lookup" }
+
+func (bareTool) Schema() tools.Schema {
+	return tools.Schema{
+		Name:        "lookup",
+		Description: "Looks up",
+		Parameters:  []tools.Parameter{{Name: "key", Type: "string", Required: true}},
+	}
+}
+
+func (bareTool) Validate(input map[string]interface{}) error { return nil }
+
+func (bareTool) Execute(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
+	return &tools.Result{Success: true}
+}
+
+func (bareTool) IsAvailable(ctx context.Context) bool { return true }
+
+func TestAgentStatusService_Lint(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	registry := llm.NewRegistry()
+	registry.Register(&statusProvider{available: true})
+	toolService := services.NewToolService(repo, slog.Default())
+	require.NoError(t, toolService.GetRegistry().Register(bareTool{}))
+	chatService := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService, nil, slog.Default())
+	linter := services.NewAgentStatusService(repo, registry, toolService, chatService, slog.Default())
+
+	codes := func(warnings []models.LintWarning) map[string][]string {
+		byCode := map[string][]string{}
+		for _, warning := range warnings {
+			byCode[warning.Code] = append(byCode[warning.Code], warning.Field)
+		}
+		return byCode
+	}
+
+	clean := &models.Agent{
+		Provider:     "ollama",
+		Model:        "llama3",
+		SystemPrompt: "You are a helpful assistant. Always cite your sources.",
+		Config:       models.JSON{"tools": []interface{}{"http_get"}},
+	}
+	assert.Empty(t, linter.Lint(ctx, clean))
+
+	found := codes(linter.Lint(ctx, &models.Agent{
+		Provider: "ollama",
+		Model:    "llama3",
+		SystemPrompt: "Always answer in English. Never answer in English without checking calculator first.\n" +
+			"Respond in German. Be concise. Give detailed answers.",
+		LocalizedPrompts: models.StringMap{"de": "  "},
+		Config:           models.JSON{"tools": []interface{}{"lookup", "http_get", "http-get", "lookup"}},
+	}))
+	assert.Len(t, found[models.LintContradiction], 3, "always/never, languages and reply length")
+	assert.Equal(t, []string{"localized_system_prompts.de"}, found[models.LintPromptEmpty])
+	assert.Equal(t, []string{"config.tools[0]"}, found[models.LintToolDescriptionShort])
+	assert.Equal(t, []string{"config.tools[0]"}, found[models.LintToolParameterUndocumented])
+	assert.Equal(t, []string{"config.tools[2]", "config.tools[3]"}, found[models.LintDuplicateTool])
+	assert.Equal(t, []string{"system_prompt"}, found[models.LintToolNotEnabled])
+
+	// Presets hide parameters from the model, so they need no description
+	preset := *clean
+	preset.Config = models.JSON{
+		"tools":        []interface{}{"lookup"},
+		"tool_presets": map[string]interface{}{"lookup": map[string]interface{}{"key": "fixed"}},
+	}
+	assert.Empty(t, codes(linter.Lint(ctx, &preset))[models.LintToolParameterUndocumented])
+
+	long := *clean
+	long.Config = models.JSON{"num_ctx": 100}
+	long.SystemPrompt = strings.Repeat("Answer politely. ", 20)
+	found = codes(linter.Lint(ctx, &long))
+	assert.Equal(t, []string{"system_prompt"}, found[models.LintPromptTooLong])
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"agent-server/internal/tools"
@@ -121,16 +122,29 @@ var ToolUsagePrompts = map[string]string{
 
 	"text_processor": `TEXT PROCESSOR TOOL USAGE:
 - Use for text manipulation and analysis operations
-- Operations: uppercase, lowercase, word_count, char_count, reverse, extract_emails, extract_urls
+- Operations: uppercase, lowercase, word_count, char_count, reverse, extract_emails, extract_urls, regex_match, regex_extract, regex_replace, split, template, stats, chunk, diff
 - Always specify both "text" and "operation" parameters
+- Regex operations take an RE2 "pattern"; regex_replace also takes "replacement" ($1 or ${name} for groups)
+- template fills {{name}} placeholders in text from the "variables" object
+- chunk splits text for embedding by "chunk_by" (tokens or sentences) with "chunk_size" and "overlap"
+- diff returns a unified diff from text to "other_text"
 - Example: Count words in text using operation "word_count"`,
 
 	"json_processor": `JSON PROCESSOR TOOL USAGE:
 - Use for JSON data manipulation and analysis
-- Operations: validate, pretty_print, minify, extract_keys, get_value
-- For get_value operation, specify "path" parameter (e.g., "user.name")
+- Operations: validate, pretty_print, minify, extract_keys, get_value, set_value, delete_key, diff
+- For get_value, set_value and delete_key, specify "path" (e.g., "user.name", "items[0].id", "items[*].id", "$..id")
+- For set_value, pass the new value as JSON in "value"; diff compares against "other_json"
+- To check structure, pass a JSON Schema in "schema" with the validate operation
 - Example: Extract keys from JSON object or format JSON data`,
 
+	"encoder": `ENCODER TOOL USAGE:
+- Use instead of computing encodings, hashes or IDs yourself
+- Operations: base64_encode, base64_decode, url_encode, url_decode, hex_encode, hex_decode, sha256, md5, jwt_decode, uuid
+- Specify "operation" and the "input" text (uuid needs no input)
+- jwt_decode shows header and claims but does not verify the signature
+- Example: Hash a string with operation "sha256"`,
+
 	"mcp_proxy": `MCP PROXY TOOL USAGE:
 - Use to connect to Model Context Protocol servers
 - Actions: initialize, list_resources, get_resource, list_tools, call_tool
@@ -156,8 +170,29 @@ var ToolUsagePrompts = map[string]string{
 - ALWAYS use memory to learn user preferences and adapt your behavior
 - Store important facts and preferences to provide personalized responses
 - Example: Store user communication style preferences, recall conversation context`,
+
+	"session_vars": `SESSION VARIABLES TOOL USAGE:
+- Use to read context the application attached to this conversation, such as customer_id or order_id
+- Pass "name" to read one variable; omit it to list all variables
+- Prefer these values over asking the user for IDs the application already knows
+- Example: Read "order_id" before looking up an order`,
+
+	"issue_tracker": `ISSUE TRACKER TOOL USAGE:
+- Use "search" with a "query" to find issues, then "get" with the "issue" key (e.g. ENG-123) to read one with its comments
+- Use "create" with "title" and "description", "comment" with "comment", and "transition" with the target "state"
+- Search before creating an issue so you do not file duplicates
+- Write actions may wait for a person's approval; then tell the user the action awaits approval`,
 }
 
+// citationInstructions ask the model to mark statements that rely on tool
+// results, so that the server can map them to their sources
+const citationInstructions = `=== CITATIONS ===
+- Results of web and search tools carry a "source" number
+- When a statement in your answer relies on such a result, put its source number in square brackets right after the statement, e.g. "The API lists 42 items [3]."
+- Cite several sources as [1, 2]; only cite sources you actually used
+
+`
+
 // BuildSystemPrompt creates a comprehensive system prompt with dynamic tool descriptions
 func (ps *PromptService) BuildSystemPrompt(ctx context.Context, basePrompt string, availableTools []string) string {
 	var prompt strings.Builder
@@ -184,6 +219,10 @@ func (ps *PromptService) BuildSystemPrompt(ctx context.Context, basePrompt strin
 				// Add usage instructions if available
 				if usage, exists := ToolUsagePrompts[toolName]; exists {
 					prompt.WriteString(usage)
+					if returns := describeOutput(schema.Output); returns != "" {
+						prompt.WriteString("\n")
+						prompt.WriteString(strings.TrimSuffix(returns, "\n"))
+					}
 					prompt.WriteString("\n\n")
 				} else {
 					// Generate basic usage from schema
@@ -193,6 +232,13 @@ func (ps *PromptService) BuildSystemPrompt(ctx context.Context, basePrompt strin
 			}
 		}
 
+		for _, toolName := range availableTools {
+			if citableTools[toolName] {
+				prompt.WriteString(citationInstructions)
+				break
+			}
+		}
+
 		prompt.WriteString("=== TOOL USAGE REMINDER ===\n")
 		prompt.WriteString("- ALWAYS use tools when they match the task requirements\n")
 		prompt.WriteString("- Don't perform manual work that tools can do\n")
@@ -218,6 +264,8 @@ func (ps *PromptService) generateBasicUsage(schema tools.Schema) string {
 			param.Name, param.Type, required, param.Description))
 	}
 	
+	usage.WriteString(describeOutput(schema.Output))
+
 	if len(schema.Examples) > 0 {
 		usage.WriteString("Examples:\n")
 		for _, example := range schema.Examples {
@@ -228,6 +276,42 @@ func (ps *PromptService) generateBasicUsage(schema tools.Schema) string {
 	return usage.String()
 }
 
+// describeOutput tells the model what a tool returns, from its output
+// schema: the fields of an object result, or the type of any other
+func describeOutput(output map[string]interface{}) string {
+	if len(output) == 0 {
+		return ""
+	}
+	kind, _ := output["type"].(string)
+	properties, _ := output["properties"].(map[string]interface{})
+	if len(properties) == 0 {
+		description, _ := output["description"].(string)
+		if kind == "" && description == "" {
+			return ""
+		}
+		return strings.TrimSuffix(fmt.Sprintf("Returns: %s %s", kind, description), " ") + "\n"
+	}
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var returns strings.Builder
+	returns.WriteString("Returns:\n")
+	for _, name := range names {
+		property, _ := properties[name].(map[string]interface{})
+		propertyType, _ := property["type"].(string)
+		description, _ := property["description"].(string)
+		line := fmt.Sprintf("  - %s (%s)", name, propertyType)
+		if description != "" {
+			line += ": " + description
+		}
+		returns.WriteString(line + "\n")
+	}
+	return returns.String()
+}
+
 // BuildEnhancedSystemPrompt creates a system prompt optimized for specific agent types
 func (ps *PromptService) BuildEnhancedSystemPrompt(ctx context.Context, agentType string, availableTools []string, customPrompt string) string {
 	var basePrompt string
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/metrics"
+	"agent-server/internal/models"
+)
+
+// Prompt token metrics, labeled by agent and part of the prompt
+var (
+	promptMetricsOnce sync.Once
+	promptPartTokens  *metrics.Histogram
+)
+
+func initPromptMetrics() {
+	promptMetricsOnce.Do(func() {
+		promptPartTokens = metrics.Default.NewHistogram("agent_server_prompt_part_tokens",
+			"Estimated prompt tokens per reply, by part (system_prompt, tool_schemas, history, user_message).",
+			metrics.ExponentialBuckets(16, 4, 8), "agent", "part")
+	})
+}
+
+// estimateTokens approximates the tokens of text at four characters per
+// token, like estimatePromptTokens
+func estimateTokens(text string) int64 {
+	return int64(utf8.RuneCountInString(text)+3) / 4
+}
+
+// promptBreakdown estimates the tokens of a prompt by part. The leading
+// system message is the agent's prompt, less toolPrompt, the tool
+// descriptions generated into it, which count towards the tool schemas with
+// the definitions of tools offered natively. The last user message is the
+// turn's; everything else is history.
+func promptBreakdown(messages []llm.ChatMessage, toolPrompt string, tools []models.ToolDefinition) *models.PromptBreakdown {
+	breakdown := &models.PromptBreakdown{}
+	if toolPrompt != "" {
+		breakdown.ToolSchemas = estimateTokens(toolPrompt)
+	}
+	if len(tools) > 0 {
+		if data, err := json.Marshal(tools); err == nil {
+			breakdown.ToolSchemas += estimateTokens(string(data))
+		}
+	}
+
+	last := -1
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			last = i
+			break
+		}
+	}
+	for i, message := range messages {
+		tokens := estimateTokens(message.Content)
+		switch {
+		case i == 0 && message.Role == "system":
+			if toolPrompt != "" {
+				tokens = estimateTokens(strings.Replace(message.Content, toolPrompt, "", 1))
+			}
+			breakdown.SystemPrompt = tokens
+		case i == last:
+			breakdown.UserMessage = tokens
+		default:
+			breakdown.History += tokens
+		}
+	}
+	breakdown.Total = breakdown.SystemPrompt + breakdown.ToolSchemas + breakdown.History + breakdown.UserMessage
+	return breakdown
+}
+
+// recordPromptBreakdown adds the breakdown of a reply's prompt to metadata
+// and the metrics
+func recordPromptBreakdown(metadata map[string]interface{}, agent *models.Agent, breakdown *models.PromptBreakdown) {
+	if breakdown == nil {
+		return
+	}
+	initPromptMetrics()
+	promptPartTokens.Observe(float64(breakdown.SystemPrompt), agent.ID, "system_prompt")
+	promptPartTokens.Observe(float64(breakdown.ToolSchemas), agent.ID, "tool_schemas")
+	promptPartTokens.Observe(float64(breakdown.History), agent.ID, "history")
+	promptPartTokens.Observe(float64(breakdown.UserMessage), agent.ID, "user_message")
+	metadata["prompt_breakdown"] = breakdown
+}
@@ -0,0 +1,76 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatService_PromptBreakdown(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "guide", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{},
+		SystemPrompt: "You answer questions about France."}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{responses: []*llm.ChatResponse{{Content: "Paris."}, {Content: "Lyon."}, {Content: "4"}}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	toolService := services.NewToolService(repo, slog.Default())
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+
+	// The first turn has no history; 32 characters are 8 tokens
+	response, err := service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "What is the capital of France?!"})
+	require.NoError(t, err)
+	first, ok := response.Metadata["prompt_breakdown"].(*models.PromptBreakdown)
+	require.True(t, ok)
+	assert.Positive(t, first.SystemPrompt)
+	assert.Zero(t, first.ToolSchemas)
+	assert.Zero(t, first.History)
+	assert.Equal(t, int64(8), first.UserMessage)
+	assert.Equal(t, first.SystemPrompt+first.UserMessage, first.Total)
+
+	// The turn's trace carries the breakdown too
+	assert.Eventually(t, func() bool {
+		trace, err := repo.ContextTrace().GetByTurnID(ctx, response.UserMessageID)
+		return err == nil && trace != nil && trace.Tokens != nil && *trace.Tokens == *first
+	}, time.Second, 10*time.Millisecond)
+
+	// Earlier turns count as history
+	response, err = service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "And the second city?"})
+	require.NoError(t, err)
+	second := response.Metadata["prompt_breakdown"].(*models.PromptBreakdown)
+	assert.Equal(t, first.SystemPrompt, second.SystemPrompt)
+	assert.Equal(t, first.UserMessage+2, second.History) // "Paris." is 2 tokens
+
+	// The generated tool prompt counts towards the tool schemas, not the
+	// agent's prompt
+	tooled, err := service.ChatWithTools(ctx, &models.EnhancedChatRequest{Message: "2+2?", Tools: []string{"calculator"}}, session.ID)
+	require.NoError(t, err)
+	third := tooled.Metadata["prompt_breakdown"].(map[string]interface{})
+	assert.Equal(t, float64(first.SystemPrompt), third["system_prompt"])
+	assert.Greater(t, third["tool_schemas"], float64(50))
+
+	// Usage sums the breakdowns of the session's replies
+	report, err := services.NewAccounting(repo).SessionUsage(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), report.Prompt.Replies)
+	assert.Equal(t, first.Total+second.Total+int64(third["total"].(float64)), report.Prompt.Total.Total)
+	assert.Equal(t, report.Prompt.Total.ToolSchemas/3, report.Prompt.PerReply.ToolSchemas)
+}
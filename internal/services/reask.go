@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+)
+
+// Why a reply was asked again
+const (
+	ReaskEmpty   = "empty"
+	ReaskRefusal = "refusal"
+)
+
+// defaultReaskInstruction is added to a retry when the agent has none
+const defaultReaskInstruction = "Your previous reply was empty or declined the request. " +
+	"Answer the user's last message directly and helpfully."
+
+// maxRefusalLength bounds the replies taken for canned refusals; longer ones
+// explain themselves and are left alone
+const maxRefusalLength = 300
+
+// refusalPrefixes open canned refusals, lowercased
+var refusalPrefixes = []string{
+	"i'm sorry, but i can't",
+	"i'm sorry, but i cannot",
+	"i am sorry, but i cannot",
+	"sorry, i can't",
+	"sorry, but i can't",
+	"i can't help with",
+	"i cannot help with",
+	"i can't assist with",
+	"i cannot assist with",
+	"i'm unable to",
+	"i am unable to",
+	"i'm not able to",
+	"as an ai language model",
+}
+
+// reaskReason returns why the agent's policy asks a reply again: ReaskEmpty,
+// ReaskRefusal, or "" to keep it
+func reaskReason(policy *models.AgentReask, content string) string {
+	if policy == nil {
+		return ""
+	}
+	switch {
+	case policy.Empty && strings.TrimSpace(content) == "":
+		return ReaskEmpty
+	case policy.Refusals && isRefusal(content):
+		return ReaskRefusal
+	}
+	return ""
+}
+
+// isRefusal reports whether a reply is a short canned refusal
+func isRefusal(content string) bool {
+	content = strings.TrimSpace(content)
+	if content == "" || len(content) > maxRefusalLength {
+		return false
+	}
+	normalized := strings.ToLower(strings.ReplaceAll(content, "’", "'"))
+	for _, prefix := range refusalPrefixes {
+		if strings.HasPrefix(normalized, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// reaskInstruction returns the instruction a retry adds to the context
+func reaskInstruction(policy *models.AgentReask) string {
+	if policy.Instruction != "" {
+		return policy.Instruction
+	}
+	return defaultReaskInstruction
+}
+
+// reaskMetadata records a retry in the reply's metadata: why it was asked
+// again and whether the retry gave a usable reply
+func reaskMetadata(policy *models.AgentReask, reason, content string) map[string]interface{} {
+	return map[string]interface{}{
+		"reason":   reason,
+		"resolved": reaskReason(policy, content) == "",
+	}
+}
+
+// reask asks the provider once more with the agent's instruction appended to
+// the request. The returned reply carries the usage of both calls and the
+// retry in its metadata.
+func (s *ChatService) reask(ctx context.Context, provider llm.Provider, agent *models.Agent, request *llm.ChatRequest, first *llm.ChatResponse, reason string) (*llm.ChatResponse, error) {
+	s.logger.Info("Re-asking after unusable reply", "agent_id", agent.ID, "reason", reason)
+
+	retry := *request
+	retry.Stream = false
+	retry.Messages = append(append([]llm.ChatMessage(nil), request.Messages...),
+		llm.ChatMessage{Role: "system", Content: reaskInstruction(agent.Reask)})
+	response, err := provider.Chat(ctx, &retry)
+	if err != nil {
+		return nil, err
+	}
+
+	if first.Usage != nil {
+		usage := *first.Usage
+		usage.Add(response.Usage)
+		response.Usage = &usage
+	}
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["reask"] = reaskMetadata(agent.Reask, reason, response.Content)
+	return response, nil
+}
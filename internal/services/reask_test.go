@@ -0,0 +1,132 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatService_Reask(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "flaky", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{},
+		Reask: &models.AgentReask{Empty: true, Refusals: true, Instruction: "Just answer."}}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	toolService := services.NewToolService(repo, slog.Default())
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+
+	tests := []struct {
+		name      string
+		responses []*llm.ChatResponse
+		reply     string
+		reask     map[string]interface{}
+		usage     interface{}
+	}{
+		{
+			name:      "usable reply",
+			responses: []*llm.ChatResponse{{Content: "Paris."}},
+			reply:     "Paris.",
+		},
+		{
+			name: "blank reply",
+			responses: []*llm.ChatResponse{
+				{Content: "  ", Usage: &llm.Usage{PromptTokens: 10, TotalTokens: 10}},
+				{Content: "Paris.", Usage: &llm.Usage{PromptTokens: 12, CompletionTokens: 2, TotalTokens: 14}},
+			},
+			reply: "Paris.",
+			reask: map[string]interface{}{"reason": services.ReaskEmpty, "resolved": true},
+			// Both calls count towards the usage of the reply
+			usage: map[string]interface{}{"prompt_tokens": 22, "completion_tokens": 2, "total_tokens": 24},
+		},
+		{
+			name: "refusal that persists",
+			responses: []*llm.ChatResponse{
+				{Content: "I’m sorry, but I can’t help with that."},
+				{Content: "I'm unable to answer."},
+			},
+			reply: "I'm unable to answer.",
+			reask: map[string]interface{}{"reason": services.ReaskRefusal, "resolved": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider.responses, provider.requests = tt.responses, nil
+			response, err := service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "Capital of France?"})
+			require.NoError(t, err)
+			assert.Equal(t, tt.reply, response.Response)
+			require.Len(t, provider.requests, len(tt.responses))
+
+			if tt.reask == nil {
+				assert.NotContains(t, response.Metadata, "reask")
+				return
+			}
+			assert.Equal(t, tt.reask, response.Metadata["reask"])
+			if tt.usage != nil {
+				assert.Equal(t, tt.usage, response.Metadata["usage"])
+			}
+
+			// The retry is the same request with the agent's instruction
+			retry := provider.requests[1].Messages
+			assert.Equal(t, llm.ChatMessage{Role: "system", Content: "Just answer."}, retry[len(retry)-1])
+			assert.Equal(t, provider.requests[0].Messages, retry[:len(retry)-1])
+		})
+	}
+}
+
+func TestChatService_ReaskStream(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "flaky", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{},
+		Reask: &models.AgentReask{Empty: true}}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{
+		chunks:    []llm.StreamChunk{{Content: ""}, {Content: "\n", Done: true}},
+		responses: []*llm.ChatResponse{{Content: "Paris."}},
+	}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	toolService := services.NewToolService(repo, slog.Default())
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+
+	chunks, err := service.Stream(ctx, &services.ChatRequest{SessionID: session.ID, Message: "Capital of France?"})
+	require.NoError(t, err)
+	var reply strings.Builder
+	for chunk := range chunks {
+		reply.WriteString(chunk.Content)
+	}
+	assert.Equal(t, "Paris.", strings.TrimSpace(reply.String()))
+
+	messages, _, err := repo.Message().ListBySessionID(ctx, session.ID, -1, -1)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.Equal(t, "Paris.", messages[1].Content)
+	assert.Equal(t, map[string]interface{}{"reason": services.ReaskEmpty, "resolved": true}, messages[1].Metadata["reask"])
+}
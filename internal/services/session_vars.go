@@ -0,0 +1,58 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"agent-server/internal/models"
+)
+
+// variablePlaceholder matches the {{name}} placeholders of session variables
+// in system prompts, as the text_processor template operation does. Dotted
+// names reach into object values.
+var variablePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.]*)\s*\}\}`)
+
+// renderVariables fills the placeholders of a system prompt with the
+// session's variables. Objects and arrays are written as JSON; placeholders
+// of variables that are not set are left in place.
+func renderVariables(prompt string, variables models.JSON) string {
+	if len(variables) == 0 || !strings.Contains(prompt, "{{") {
+		return prompt
+	}
+	return variablePlaceholder.ReplaceAllStringFunc(prompt, func(placeholder string) string {
+		value, ok := lookupVariable(variables, variablePlaceholder.FindStringSubmatch(placeholder)[1])
+		if !ok {
+			return placeholder
+		}
+		switch v := value.(type) {
+		case string:
+			return v
+		case map[string]interface{}, []interface{}:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return placeholder
+			}
+			return string(data)
+		default:
+			return fmt.Sprint(v)
+		}
+	})
+}
+
+// lookupVariable resolves a variable name, following dots into objects
+func lookupVariable(variables map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := variables[name]; ok {
+		return value, true
+	}
+	head, rest, found := strings.Cut(name, ".")
+	if !found {
+		return nil, false
+	}
+	object, ok := variables[head].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return lookupVariable(object, rest)
+}
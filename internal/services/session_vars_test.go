@@ -0,0 +1,47 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatService_SessionVariablesInPrompt(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "support", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{},
+		SystemPrompt: "You support {{customer.name}} ({{customer_id}}) on plan {{plan}}. Order: {{order}}."}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n", Variables: models.JSON{
+		"customer_id": "C-1042",
+		"customer":    map[string]interface{}{"name": "Acme"},
+		"order":       map[string]interface{}{"id": 7},
+	}}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{responses: []*llm.ChatResponse{{Content: "Hello Acme."}}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	toolService := services.NewToolService(repo, slog.Default())
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+
+	_, err = service.Chat(ctx, &services.ChatRequest{SessionID: session.ID, Message: "Hi"})
+	require.NoError(t, err)
+
+	// Objects are written as JSON; unset variables keep their placeholder
+	require.Len(t, provider.requests, 1)
+	assert.Equal(t, `You support Acme (C-1042) on plan {{plan}}. Order: {"id":7}.`, provider.requests[0].Messages[0].Content)
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// defaultSimulationTurns is the number of replies when a request sets none
+const defaultSimulationTurns = 6
+
+// simulationChat is the part of the chat service a simulation drives
+type simulationChat interface {
+	Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error)
+}
+
+// Simulator lets two agents talk to each other, e.g. an interviewer and a
+// candidate or a red-team agent and an assistant. Each agent answers in a
+// session of its own through the regular chat path, so context strategies,
+// budgets and cost attribution apply as in any other chat.
+type Simulator struct {
+	repo   storage.Repository
+	chat   simulationChat
+	logger *slog.Logger
+}
+
+// NewSimulator creates a simulator that answers through chat
+func NewSimulator(repo storage.Repository, chat simulationChat, logger *slog.Logger) *Simulator {
+	return &Simulator{repo: repo, chat: chat, logger: logger}
+}
+
+// Run plays a simulated conversation. The opening message is sent to the
+// first agent; after that each reply is sent to the other agent until the
+// requested number of turns is reached. User messages carry the simulation
+// ID, the turn number and the agent that spoke them in their metadata.
+//
+// When a turn fails the turns before it are kept: Run returns the partial,
+// incomplete result together with the error.
+func (s *Simulator) Run(ctx context.Context, req *models.SimulationRequest) (*models.SimulationResult, error) {
+	turns := req.Turns
+	if turns <= 0 {
+		turns = defaultSimulationTurns
+	}
+	if turns > models.MaxSimulationTurns {
+		turns = models.MaxSimulationTurns
+	}
+
+	first, err := s.agent(ctx, req.FirstAgentID)
+	if err != nil {
+		return nil, err
+	}
+	second, err := s.agent(ctx, req.SecondAgentID)
+	if err != nil {
+		return nil, err
+	}
+
+	title := req.Title
+	if title == "" {
+		title = fmt.Sprintf("Simulation: %s and %s", first.Name, second.Name)
+	}
+	firstSession, err := s.createSession(ctx, first, title)
+	if err != nil {
+		return nil, err
+	}
+	secondSession, err := s.createSession(ctx, second, title)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.SimulationResult{
+		ID:              uuid.New().String(),
+		Status:          models.SimulationStatusCompleted,
+		FirstSessionID:  firstSession.ID,
+		SecondSessionID: secondSession.ID,
+		OpeningMessage:  req.OpeningMessage,
+		Turns:           []models.SimulationTurn{},
+	}
+	logger := s.logger.With("simulation_id", result.ID)
+
+	speakers := [2]*models.Agent{first, second}
+	sessions := [2]*models.ChatSession{firstSession, secondSession}
+	message := req.OpeningMessage
+	metadata := map[string]interface{}{
+		"simulation_id":   result.ID,
+		"simulation_turn": 0,
+	}
+	for turn := 1; turn <= turns; turn++ {
+		agent, session := speakers[(turn-1)%2], sessions[(turn-1)%2]
+
+		// Simulations run in the background of live chats
+		response, err := s.chat.Chat(ctx, &ChatRequest{
+			SessionID: session.ID,
+			Message:   message,
+			Metadata:  metadata,
+			Priority:  models.PriorityBatch,
+		})
+		if err != nil {
+			logger.Error("Simulation turn failed", "turn", turn, "agent_id", agent.ID, "error", err)
+			result.Status = models.SimulationStatusIncomplete
+			result.Error = err.Error()
+			return result, err
+		}
+
+		result.Turns = append(result.Turns, models.SimulationTurn{
+			Turn:      turn,
+			AgentID:   agent.ID,
+			AgentName: agent.Name,
+			SessionID: session.ID,
+			MessageID: response.AssistantMessageID,
+			Content:   response.Response,
+		})
+		message = response.Response
+		metadata = map[string]interface{}{
+			"simulation_id":    result.ID,
+			"simulation_turn":  turn,
+			"speaker_agent_id": agent.ID,
+		}
+	}
+
+	logger.Info("Simulation completed",
+		"first_agent_id", first.ID,
+		"second_agent_id", second.ID,
+		"turns", len(result.Turns))
+	return result, nil
+}
+
+// agent returns an agent that can take part in a simulation
+func (s *Simulator) agent(ctx context.Context, id string) (*models.Agent, error) {
+	agent, err := s.repo.Agent().GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent: %w", err)
+	}
+	if agent == nil {
+		return nil, fmt.Errorf("%w: %s", ErrAgentNotFound, id)
+	}
+	if !agent.Enabled {
+		return nil, fmt.Errorf("%w: %s", ErrAgentDisabled, agent.Name)
+	}
+	return agent, nil
+}
+
+func (s *Simulator) createSession(ctx context.Context, agent *models.Agent, title string) (*models.ChatSession, error) {
+	session := &models.ChatSession{
+		AgentID:         agent.ID,
+		Title:           title,
+		ContextStrategy: "last_n",
+		ContextConfig:   make(models.JSON),
+	}
+	if err := s.repo.Session().Create(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return session, nil
+}
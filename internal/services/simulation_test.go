@@ -0,0 +1,116 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedChat answers every message with its number and the session it was
+// sent to, and fails the call numbered failAt
+type scriptedChat struct {
+	requests []*services.ChatRequest
+	failAt   int
+}
+
+func (c *scriptedChat) Chat(ctx context.Context, req *services.ChatRequest) (*services.ChatResponse, error) {
+	c.requests = append(c.requests, req)
+	if len(c.requests) == c.failAt {
+		return nil, services.ErrLLMRequest
+	}
+	n := len(c.requests)
+	return &services.ChatResponse{
+		AssistantMessageID: fmt.Sprintf("msg-%d", n),
+		Response:           fmt.Sprintf("reply %d", n),
+	}, nil
+}
+
+func TestSimulator_Run(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	interviewer := &models.Agent{Name: "interviewer", Provider: "ollama", Model: "llama3", Enabled: true}
+	require.NoError(t, repo.Agent().Create(ctx, interviewer))
+	candidate := &models.Agent{Name: "candidate", Provider: "ollama", Model: "llama3", Enabled: true}
+	require.NoError(t, repo.Agent().Create(ctx, candidate))
+
+	chat := &scriptedChat{}
+	simulator := services.NewSimulator(repo, chat, slog.Default())
+	result, err := simulator.Run(ctx, &models.SimulationRequest{
+		FirstAgentID:   interviewer.ID,
+		SecondAgentID:  candidate.ID,
+		OpeningMessage: "Start the interview.",
+		Turns:          3,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.SimulationStatusCompleted, result.Status)
+
+	first, err := repo.Session().GetByID(ctx, result.FirstSessionID)
+	require.NoError(t, err)
+	assert.Equal(t, interviewer.ID, first.AgentID)
+	assert.Equal(t, "Simulation: interviewer and candidate", first.Title)
+	second, err := repo.Session().GetByID(ctx, result.SecondSessionID)
+	require.NoError(t, err)
+	assert.Equal(t, candidate.ID, second.AgentID)
+
+	// Replies alternate, each sent to the other agent's session
+	require.Len(t, chat.requests, 3)
+	assert.Equal(t, first.ID, chat.requests[0].SessionID)
+	assert.Equal(t, "Start the interview.", chat.requests[0].Message)
+	assert.Equal(t, second.ID, chat.requests[1].SessionID)
+	assert.Equal(t, "reply 1", chat.requests[1].Message)
+	assert.Equal(t, interviewer.ID, chat.requests[1].Metadata["speaker_agent_id"])
+	assert.Equal(t, first.ID, chat.requests[2].SessionID)
+	assert.Equal(t, "reply 2", chat.requests[2].Message)
+	assert.Equal(t, candidate.ID, chat.requests[2].Metadata["speaker_agent_id"])
+	assert.Equal(t, 2, chat.requests[2].Metadata["simulation_turn"])
+	assert.Equal(t, result.ID, chat.requests[2].Metadata["simulation_id"])
+
+	require.Len(t, result.Turns, 3)
+	assert.Equal(t, models.SimulationTurn{
+		Turn: 2, AgentID: candidate.ID, AgentName: "candidate", SessionID: second.ID, MessageID: "msg-2", Content: "reply 2",
+	}, result.Turns[1])
+}
+
+func TestSimulator_Run_Failures(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "self-play", Provider: "ollama", Model: "llama3", Enabled: true}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+
+	// A failed turn keeps the turns before it
+	chat := &scriptedChat{failAt: 3}
+	result, err := services.NewSimulator(repo, chat, slog.Default()).Run(ctx, &models.SimulationRequest{
+		FirstAgentID:   agent.ID,
+		SecondAgentID:  agent.ID,
+		OpeningMessage: "Hello",
+		Turns:          5,
+	})
+	assert.ErrorIs(t, err, services.ErrLLMRequest)
+	require.NotNil(t, result)
+	assert.Equal(t, models.SimulationStatusIncomplete, result.Status)
+	assert.Len(t, result.Turns, 2)
+	assert.NotEqual(t, result.FirstSessionID, result.SecondSessionID)
+
+	// Unknown agents are rejected before anything is created
+	_, err = services.NewSimulator(repo, &scriptedChat{}, slog.Default()).Run(ctx, &models.SimulationRequest{
+		FirstAgentID:   agent.ID,
+		SecondAgentID:  "missing",
+		OpeningMessage: "Hello",
+	})
+	assert.True(t, errors.Is(err, services.ErrAgentNotFound))
+}
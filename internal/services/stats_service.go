@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+)
+
+// activeSessionWindow is how recently a session must have been used to count
+// as active
+const activeSessionWindow = 24 * time.Hour
+
+// StatsService computes dashboard statistics from persisted messages and
+// tool execution logs
+type StatsService struct {
+	repo storage.Repository
+}
+
+// NewStatsService creates a new stats service
+func NewStatsService(repo storage.Repository) *StatsService {
+	return &StatsService{repo: repo}
+}
+
+// AdminStats returns totals and per-day series covering the last days days
+func (s *StatsService) AdminStats(ctx context.Context, days int) (*models.AdminStats, error) {
+	now := time.Now()
+	since := now.AddDate(0, 0, -days)
+	stats := s.repo.Stats()
+
+	totals, err := stats.Totals(ctx, now.Add(-activeSessionWindow))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count totals: %w", err)
+	}
+
+	chatsPerDay, err := stats.ChatsPerDay(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count chats per day: %w", err)
+	}
+	for i := range chatsPerDay {
+		if chatsPerDay[i].Chats > 0 {
+			chatsPerDay[i].ErrorRate = float64(chatsPerDay[i].Errors) / float64(chatsPerDay[i].Chats)
+		}
+	}
+
+	tokens, err := stats.TokensByProvider(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum token usage: %w", err)
+	}
+
+	tools, err := s.ToolUsage(ctx, since, "")
+	if err != nil {
+		return nil, err
+	}
+
+	latencies, err := stats.ChatLatencies(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chat latencies: %w", err)
+	}
+
+	prompt, err := stats.PromptTokens(ctx, models.CostFilter{Since: since})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum prompt tokens: %w", err)
+	}
+
+	return &models.AdminStats{
+		Since:            since,
+		GeneratedAt:      now,
+		Totals:           *totals,
+		ChatsPerDay:      nonNil(chatsPerDay),
+		TokensByProvider: nonNil(tokens),
+		Tools:            tools,
+		Latency:          latencyStats(latencies),
+		PromptTokens:     *prompt,
+	}, nil
+}
+
+// ToolUsage returns execution counts, error rates and duration percentiles
+// per tool since the given time, optionally restricted to one tool
+func (s *StatsService) ToolUsage(ctx context.Context, since time.Time, toolName string) ([]models.ToolUsageStats, error) {
+	stats := s.repo.Stats()
+
+	tools, err := stats.ToolUsage(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate tool usage: %w", err)
+	}
+
+	samples, err := stats.ToolDurations(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tool durations: %w", err)
+	}
+	durations := make(map[string][]int64)
+	for _, sample := range samples {
+		durations[sample.ToolName] = append(durations[sample.ToolName], sample.DurationMS)
+	}
+
+	result := make([]models.ToolUsageStats, 0, len(tools))
+	for _, tool := range tools {
+		if toolName != "" && tool.ToolName != toolName {
+			continue
+		}
+		if tool.TotalCalls > 0 {
+			tool.ErrorRate = float64(tool.FailedCalls) / float64(tool.TotalCalls)
+		}
+		tool.P95Duration = percentile(durations[tool.ToolName], 95)
+		result = append(result, tool)
+	}
+
+	return result, nil
+}
+
+// latencyStats summarizes LLM latency samples
+func latencyStats(samples []int64) models.LatencyStats {
+	stats := models.LatencyStats{
+		Samples: len(samples),
+		P50MS:   percentile(samples, 50),
+		P95MS:   percentile(samples, 95),
+	}
+	for _, sample := range samples {
+		if sample > stats.MaxMS {
+			stats.MaxMS = sample
+		}
+	}
+	return stats
+}
+
+// percentile returns the nearest-rank p-th percentile of samples. It sorts
+// samples in place.
+func percentile(samples []int64, p int) int64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	rank := (p*len(samples) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return samples[rank-1]
+}
+
+// nonNil makes empty series encode as [] rather than null
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
@@ -0,0 +1,92 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsService_AdminStats(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "stats", Provider: "openai", Model: "gpt-4"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	// Three turns: two answered, one failed
+	for i, latency := range []int64{100, 300} {
+		require.NoError(t, repo.Message().Create(ctx, &models.Message{SessionID: session.ID, Role: "user", Content: "q"}))
+		require.NoError(t, repo.Message().Create(ctx, &models.Message{
+			SessionID: session.ID,
+			Role:      "assistant",
+			Content:   "a",
+			Metadata: models.JSON{
+				"latency_ms": latency,
+				"usage": map[string]interface{}{
+					"prompt_tokens":     10 * (i + 1),
+					"completion_tokens": 5,
+					"total_tokens":      10*(i+1) + 5,
+				},
+			},
+		}))
+	}
+	require.NoError(t, repo.Message().Create(ctx, &models.Message{
+		SessionID: session.ID, Role: "user", Content: "q", Status: models.MessageStatusIncomplete,
+	}))
+
+	answer, err := repo.Message().LastAssistantByAgent(ctx, agent.ID)
+	require.NoError(t, err)
+	for i, duration := range []int64{20, 40, 900} {
+		require.NoError(t, repo.ToolCall().Create(ctx, &models.ToolCall{
+			MessageID: answer.ID,
+			ToolName:  "http_get",
+			Success:   i != 2,
+			Duration:  duration,
+		}))
+	}
+
+	service := services.NewStatsService(repo)
+	stats, err := service.AdminStats(ctx, 7)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), stats.Totals.Agents)
+	assert.Equal(t, int64(1), stats.Totals.ActiveSessions)
+	assert.Equal(t, int64(5), stats.Totals.Messages)
+	assert.Greater(t, stats.Totals.DatabaseSizeBytes, int64(0))
+
+	require.Len(t, stats.ChatsPerDay, 1)
+	assert.Equal(t, time.Now().UTC().Format("2006-01-02"), stats.ChatsPerDay[0].Date)
+	assert.Equal(t, int64(3), stats.ChatsPerDay[0].Chats)
+	assert.Equal(t, int64(1), stats.ChatsPerDay[0].Errors)
+	assert.InDelta(t, 1.0/3, stats.ChatsPerDay[0].ErrorRate, 0.001)
+
+	require.Len(t, stats.TokensByProvider, 1)
+	assert.Equal(t, "openai", stats.TokensByProvider[0].Provider)
+	assert.Equal(t, int64(30), stats.TokensByProvider[0].PromptTokens)
+	assert.Equal(t, int64(40), stats.TokensByProvider[0].TotalTokens)
+	assert.Equal(t, int64(2), stats.TokensByProvider[0].Responses)
+
+	assert.Equal(t, 2, stats.Latency.Samples)
+	assert.Equal(t, int64(100), stats.Latency.P50MS)
+	assert.Equal(t, int64(300), stats.Latency.P95MS)
+	assert.Equal(t, int64(300), stats.Latency.MaxMS)
+
+	assert.Equal(t, int64(3), stats.Totals.ToolExecutions)
+	require.Len(t, stats.Tools, 1)
+	assert.Equal(t, "http_get", stats.Tools[0].ToolName)
+	assert.Equal(t, int64(3), stats.Tools[0].TotalCalls)
+	assert.Equal(t, int64(1), stats.Tools[0].FailedCalls)
+	assert.Equal(t, int64(900), stats.Tools[0].P95Duration)
+	assert.False(t, stats.Tools[0].LastUsed.IsZero())
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// streamPacer holds streamed content back to a number of tokens per second,
+// so that replies reach clients at an even pace and channel integrations
+// that edit a message per chunk stay under their platform's rate limits.
+// Tokens are estimated at four characters each.
+type streamPacer struct {
+	rate  float64 // tokens per second; 0 does not pace
+	start time.Time
+	sent  float64 // tokens forwarded so far
+}
+
+func newStreamPacer(tokensPerSecond int) *streamPacer {
+	return &streamPacer{rate: float64(tokensPerSecond)}
+}
+
+// pieces splits content into the parts forwarded one at a time: words when
+// pacing, so a large chunk is not released in one burst, and the whole
+// content otherwise
+func (p *streamPacer) pieces(content string) []string {
+	if p.rate <= 0 || content == "" {
+		return []string{content}
+	}
+	var pieces []string
+	for len(content) > 0 {
+		// A piece is a word with the whitespace around it
+		end := skipRun(content, 0, true)
+		end = skipRun(content, end, false)
+		end = skipRun(content, end, true)
+		pieces = append(pieces, content[:end])
+		content = content[end:]
+	}
+	return pieces
+}
+
+// skipRun returns the end of the run of whitespace, or of other characters,
+// starting at from
+func skipRun(s string, from int, space bool) int {
+	for i, r := range s[from:] {
+		if unicode.IsSpace(r) != space {
+			return from + i
+		}
+	}
+	return len(s)
+}
+
+// wait blocks until piece may be forwarded. The first piece goes out at
+// once; later ones follow at the configured rate.
+func (p *streamPacer) wait(ctx context.Context, piece string) error {
+	if p.rate <= 0 || piece == "" {
+		return nil
+	}
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+	due := p.start.Add(time.Duration(p.sent / p.rate * float64(time.Second)))
+	p.sent += float64(utf8.RuneCountInString(piece)) / 4
+
+	delay := time.Until(due)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
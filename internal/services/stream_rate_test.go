@@ -0,0 +1,59 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatService_StreamRate(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	// 10 tokens a second is 100ms per four characters
+	agent := &models.Agent{Name: "paced", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{}, StreamRate: 10}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{chunks: []llm.StreamChunk{
+		{Content: "one two three four"},
+		{Content: " five", Done: true},
+	}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	toolService := services.NewToolService(repo, slog.Default())
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+
+	start := time.Now()
+	chunks, err := service.Stream(ctx, &services.ChatRequest{SessionID: session.ID, Message: "count"})
+	require.NoError(t, err)
+
+	var pieces []string
+	var reply strings.Builder
+	for chunk := range chunks {
+		if chunk.Content != "" {
+			pieces = append(pieces, chunk.Content)
+		}
+		reply.WriteString(chunk.Content)
+	}
+
+	// Chunks go out a word at a time and nothing is lost
+	assert.Equal(t, []string{"one ", "two ", "three ", "four", " five"}, pieces)
+	assert.Equal(t, "one two three four five", reply.String())
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
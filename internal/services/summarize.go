@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+)
+
+// summaryPrompt instructs the model that summarizes compacted messages
+const summaryPrompt = `You summarize conversations between a user and an AI assistant.
+The summary replaces the messages in the assistant's memory, so keep every fact,
+decision, open question and preference a later reply may need, and the results
+of tool calls that were relied on. Write plain prose in the language of the
+conversation, without a heading or preamble.`
+
+// maxSummaryInput bounds the characters of one message passed to the
+// summary, so that a long tool result does not crowd out the conversation
+const maxSummaryInput = 4000
+
+// Summarize writes a summary of a session's messages with the session's
+// agent, for compaction. It returns the summary with the provider, model,
+// usage and cost of the call.
+func (s *ChatService) Summarize(ctx context.Context, session *models.ChatSession, messages []*models.Message) (string, map[string]interface{}, error) {
+	agent := &session.Agent
+	provider, exists := s.llmRegistry.Get(agent.Provider)
+	if !exists {
+		return "", nil, fmt.Errorf("%w: unsupported provider %s", ErrProviderUnavailable, agent.Provider)
+	}
+
+	var transcript strings.Builder
+	for _, message := range messages {
+		content := strings.TrimSpace(message.Content)
+		if content == "" {
+			continue
+		}
+		content, _ = truncateChars(content, maxSummaryInput)
+		fmt.Fprintf(&transcript, "%s: %s\n\n", message.Role, content)
+	}
+
+	response, err := provider.Chat(ctx, &llm.ChatRequest{
+		Model: agent.Model,
+		Messages: []llm.ChatMessage{
+			{Role: "system", Content: summaryPrompt},
+			{Role: "user", Content: transcript.String()},
+		},
+		Temperature: 0.2,
+		MaxTokens:   agent.MaxTokens,
+		Options:     agent.Config,
+	})
+	if err != nil {
+		return "", nil, s.providerError(agent, err)
+	}
+	summary := strings.TrimSpace(response.Content)
+	if summary == "" {
+		return "", nil, fmt.Errorf("%s returned an empty summary", agent.Provider)
+	}
+
+	metadata := map[string]interface{}{
+		"provider": agent.Provider,
+		"model":    agent.Model,
+	}
+	if response.Usage != nil {
+		metadata["usage"] = usageMetadata(response.Usage)
+	}
+	s.recordCost(metadata, agent, response.Usage)
+	return summary, metadata, nil
+}
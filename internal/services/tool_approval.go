@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"agent-server/internal/models"
+	"agent-server/internal/tools"
+)
+
+// ApprovalRejectedErrorCode is the error code of tool calls a person rejected
+const ApprovalRejectedErrorCode = "APPROVAL_REJECTED"
+
+// ErrNotAwaitingApproval is returned when approving or rejecting a tool
+// execution that is not held for approval
+var ErrNotAwaitingApproval = errors.New("tool execution is not awaiting approval")
+
+// SetApproval holds calls of the named tools until a person approves them.
+// Of tools that implement tools.Mutator only the calls that change external
+// state are held, such as the write actions of issue_tracker. The LLM
+// receives an execution ID and polls the check_execution tool, which is
+// registered here.
+func (ts *ToolService) SetApproval(toolNames []string) error {
+	if err := ts.registerCheckExecution(); err != nil {
+		return err
+	}
+	ts.approvalTools = make(map[string]bool, len(toolNames))
+	for _, name := range toolNames {
+		ts.approvalTools[name] = true
+	}
+	return nil
+}
+
+// needsApproval reports whether a call of the tool is held for approval
+func (ts *ToolService) needsApproval(agent *models.Agent, toolName string, arguments map[string]interface{}) bool {
+	if !ts.approvalTools[toolName] {
+		return false
+	}
+	tool, exists := ts.registry.Get(toolName)
+	if !exists {
+		return false
+	}
+	if mutator, ok := tool.(tools.Mutator); ok {
+		// Presets may fix the action; they are merged unresolved, as the
+		// decision does not depend on credentials
+		presets, _ := tools.SplitAllowedHosts(agent.ToolPresets(toolName))
+		return mutator.Mutates(tools.MergePresets(arguments, presets))
+	}
+	return true
+}
+
+// holdForApproval validates a tool call and records it as awaiting approval,
+// returning the execution ID in place of the tool result
+func (ts *ToolService) holdForApproval(ctx context.Context, session *models.ChatSession, toolCall models.LLMToolCall, arguments map[string]interface{}) models.ToolCallResult {
+	execution, failed := ts.deferExecution(ctx, session, toolCall, arguments, models.ToolExecutionAwaitingApproval, nil)
+	if failed != nil {
+		return *failed
+	}
+
+	ts.logger.Info("Tool call awaiting approval",
+		"tool_name", toolCall.Function.Name,
+		"session_id", session.ID,
+		"execution_id", execution.ID)
+
+	return models.ToolCallResult{
+		ID:       toolCall.ID,
+		ToolName: toolCall.Function.Name,
+		Success:  true,
+		Result: map[string]interface{}{
+			"execution_id": execution.ID,
+			"status":       execution.Status,
+			"message":      "The call needs a person's approval before it runs. Tell the user it awaits approval; call check_execution with this execution_id to learn whether it ran.",
+		},
+	}
+}
+
+// ApproveExecution runs a tool call held for approval and returns the
+// finished execution
+func (ts *ToolService) ApproveExecution(ctx context.Context, id string) (*models.ToolExecution, error) {
+	execution, err := ts.awaitingApproval(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ts.logger.Info("Tool call approved",
+		"tool_name", execution.ToolName,
+		"execution_id", execution.ID)
+	if err := ts.runExecution(ctx, execution); err != nil {
+		return nil, err
+	}
+	return ts.GetExecution(ctx, id)
+}
+
+// RejectExecution rejects a tool call held for approval; the LLM learns the
+// reason when it checks the execution
+func (ts *ToolService) RejectExecution(ctx context.Context, id, reason string) (*models.ToolExecution, error) {
+	execution, err := ts.awaitingApproval(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	message := "The call was rejected"
+	if reason = strings.TrimSpace(reason); reason != "" {
+		message += ": " + reason
+	}
+	now := time.Now()
+	execution.Status = models.ToolExecutionRejected
+	execution.Error = message
+	execution.ErrorCode = ApprovalRejectedErrorCode
+	execution.CompletedAt = &now
+	// The call never runs, so its arguments need not be kept unredacted
+	execution.Arguments = models.JSON(ts.redactor.Map(execution.Arguments))
+	if err := ts.repository.ToolExecution().Update(ctx, execution); err != nil {
+		return nil, fmt.Errorf("failed to reject tool execution: %w", err)
+	}
+
+	ts.logger.Info("Tool call rejected",
+		"tool_name", execution.ToolName,
+		"execution_id", execution.ID)
+	return ts.GetExecution(ctx, id)
+}
+
+// awaitingApproval returns the execution if it is held for approval
+func (ts *ToolService) awaitingApproval(ctx context.Context, id string) (*models.ToolExecution, error) {
+	execution, err := ts.repository.ToolExecution().GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tool execution: %w", err)
+	}
+	if execution == nil {
+		return nil, ErrToolExecutionNotFound
+	}
+	if execution.Status != models.ToolExecutionAwaitingApproval {
+		return nil, fmt.Errorf("%w: it is %s", ErrNotAwaitingApproval, execution.Status)
+	}
+	return execution, nil
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage/blob"
+	"agent-server/internal/tools"
+
+	"github.com/google/uuid"
+)
+
+// artifactPrefix is the blob key prefix of tool artifacts. An artifact's
+// data is kept under artifacts/<session ID>/<artifact ID>/<name>, its
+// description next to it in artifacts/<session ID>/<artifact ID>.json.
+const artifactPrefix = "artifacts/"
+
+// artifactInfo describes a stored artifact
+type artifactInfo struct {
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	ToolCallID  string    `json:"tool_call_id,omitempty"`
+	ToolName    string    `json:"tool_name,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// sessionArtifacts opens the artifacts of one session, so that tools cannot
+// read those of others
+type sessionArtifacts struct {
+	store     blob.Store
+	sessionID string
+}
+
+// Open returns an artifact of the session with its data
+func (a *sessionArtifacts) Open(ctx context.Context, id string) (*tools.Artifact, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("%w: %s", tools.ErrArtifactNotFound, id)
+	}
+
+	var info artifactInfo
+	if err := a.read(ctx, artifactPrefix+a.sessionID+"/"+id+".json", id, func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&info)
+	}); err != nil {
+		return nil, err
+	}
+	var data []byte
+	if err := a.read(ctx, artifactKey(a.sessionID, id, info.Name), id, func(r io.Reader) error {
+		var err error
+		data, err = io.ReadAll(r)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return &tools.Artifact{
+		ID:          id,
+		Name:        info.Name,
+		ContentType: info.ContentType,
+		Size:        int64(len(data)),
+		Data:        data,
+	}, nil
+}
+
+// read reads an object of an artifact
+func (a *sessionArtifacts) read(ctx context.Context, key, id string, read func(io.Reader) error) error {
+	reader, _, err := a.store.Get(ctx, key)
+	if errors.Is(err, blob.ErrNotFound) {
+		return fmt.Errorf("%w: %s", tools.ErrArtifactNotFound, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read artifact %s: %w", id, err)
+	}
+	defer reader.Close()
+	if err := read(reader); err != nil {
+		return fmt.Errorf("failed to read artifact %s: %w", id, err)
+	}
+	return nil
+}
+
+// artifactStore returns the artifacts of a session for tools to open, or nil
+// when no blob storage keeps them
+func (ts *ToolService) artifactStore(sessionID string) tools.ArtifactStore {
+	if ts.blobStore == nil || sessionID == "" {
+		return nil
+	}
+	return &sessionArtifacts{store: ts.blobStore, sessionID: sessionID}
+}
+
+// storeArtifacts keeps the artifacts a tool call returned in blob storage
+// and returns references to them with download URLs. Artifacts that cannot
+// be kept are left out.
+func (ts *ToolService) storeArtifacts(ctx context.Context, sessionID string, toolCall models.LLMToolCall, artifacts []tools.Artifact) models.ToolArtifacts {
+	if len(artifacts) == 0 {
+		return nil
+	}
+	if ts.blobStore == nil || sessionID == "" {
+		ts.logger.Warn("Dropped tool artifacts: blob storage is disabled",
+			"tool_name", toolCall.Function.Name,
+			"count", len(artifacts))
+		return nil
+	}
+
+	var refs models.ToolArtifacts
+	for _, artifact := range artifacts {
+		ref, err := ts.storeArtifact(ctx, sessionID, toolCall, artifact)
+		if err != nil {
+			ts.logger.Error("Failed to store tool artifact",
+				"tool_name", toolCall.Function.Name,
+				"artifact", artifact.Name,
+				"error", err)
+			continue
+		}
+		refs = append(refs, *ref)
+	}
+	return refs
+}
+
+// storeArtifact keeps one artifact in blob storage
+func (ts *ToolService) storeArtifact(ctx context.Context, sessionID string, toolCall models.LLMToolCall, artifact tools.Artifact) (*models.ToolArtifact, error) {
+	artifact = tools.NewArtifact(artifactName(artifact.Name), artifact.ContentType, artifact.Data)
+	id := uuid.New().String()
+	key := artifactKey(sessionID, id, artifact.Name)
+	if err := ts.blobStore.Put(ctx, key, bytes.NewReader(artifact.Data), artifact.Size, artifact.ContentType); err != nil {
+		return nil, err
+	}
+
+	info, err := json.Marshal(artifactInfo{
+		Name:        artifact.Name,
+		ContentType: artifact.ContentType,
+		Size:        artifact.Size,
+		ToolCallID:  toolCall.ID,
+		ToolName:    toolCall.Function.Name,
+		CreatedAt:   time.Now(),
+	})
+	if err == nil {
+		err = ts.blobStore.Put(ctx, artifactPrefix+sessionID+"/"+id+".json", bytes.NewReader(info), int64(len(info)), "application/json")
+	}
+	if err != nil {
+		_ = ts.blobStore.Delete(ctx, key)
+		return nil, err
+	}
+
+	ref := &models.ToolArtifact{
+		ID:          id,
+		Name:        artifact.Name,
+		ContentType: artifact.ContentType,
+		Size:        artifact.Size,
+		ToolCallID:  toolCall.ID,
+		ToolName:    toolCall.Function.Name,
+	}
+	if url, err := ts.blobStore.SignedURL(ctx, key, ts.signedURLExpiry); err == nil {
+		ref.URL = url
+	} else {
+		ts.logger.Warn("Failed to sign artifact URL", "key", key, "error", err)
+	}
+
+	ts.logger.Info("Stored tool artifact",
+		"tool_name", toolCall.Function.Name,
+		"artifact_id", id,
+		"content_type", artifact.ContentType,
+		"size", artifact.Size)
+	return ref, nil
+}
+
+// turnArtifacts collects the artifacts of a turn's tool calls
+func turnArtifacts(results []models.ToolCallResult) []models.ToolArtifact {
+	var artifacts []models.ToolArtifact
+	for _, result := range results {
+		artifacts = append(artifacts, result.Artifacts...)
+	}
+	return artifacts
+}
+
+// artifactName reduces the name a tool gave an artifact to a file name
+func artifactName(name string) string {
+	name = path.Base(path.Clean("/" + name))
+	if name == "/" {
+		return "artifact"
+	}
+	return name
+}
+
+// artifactKey returns the blob key of an artifact's data
+func artifactKey(sessionID, id, name string) string {
+	return artifactPrefix + sessionID + "/" + id + "/" + name
+}
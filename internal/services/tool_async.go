@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"agent-server/internal/jobs"
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+	"agent-server/internal/tools"
+	"agent-server/internal/tools/builtin"
+)
+
+// JobTypeToolExecution runs a long-running tool call in the background
+const JobTypeToolExecution = "tool_execution"
+
+// ErrToolExecutionNotFound is returned for unknown tool execution IDs
+var ErrToolExecutionNotFound = errors.New("tool execution not found")
+
+// SetAsync runs calls of the named tools in the background job runner. The
+// LLM receives an execution ID at once and polls the check_execution tool,
+// which is registered here, for the result.
+func (ts *ToolService) SetAsync(runner *jobs.Runner, toolNames []string) error {
+	if err := ts.registerCheckExecution(); err != nil {
+		return err
+	}
+
+	ts.jobRunner = runner
+	ts.asyncTools = make(map[string]bool, len(toolNames))
+	for _, name := range toolNames {
+		ts.asyncTools[name] = true
+	}
+	runner.Register(JobTypeToolExecution, ts.HandleToolExecutionJob)
+	return nil
+}
+
+// registerCheckExecution registers the check_execution tool, with which the
+// LLM polls background and approval-held executions, unless it is registered
+func (ts *ToolService) registerCheckExecution() error {
+	if _, exists := ts.registry.Get("check_execution"); exists {
+		return nil
+	}
+	if err := ts.registry.Register(builtin.NewCheckExecutionTool(ts.repository.ToolExecution())); err != nil {
+		return fmt.Errorf("failed to register check_execution tool: %w", err)
+	}
+	return nil
+}
+
+// isAsync reports whether calls of the tool run in the background
+func (ts *ToolService) isAsync(toolName string) bool {
+	return ts.jobRunner != nil && ts.asyncTools[toolName]
+}
+
+// startAsyncExecution validates a tool call and queues it for the job runner,
+// returning the execution ID in place of the tool result
+func (ts *ToolService) startAsyncExecution(ctx context.Context, session *models.ChatSession, toolCall models.LLMToolCall, arguments map[string]interface{}) models.ToolCallResult {
+	execution, failed := ts.deferExecution(ctx, session, toolCall, arguments, models.ToolExecutionPending, func(tx storage.Repository, execution *models.ToolExecution) error {
+		job := models.NewJob(JobTypeToolExecution, map[string]interface{}{"execution_id": execution.ID})
+		// Tool failures are results; the job is only retried when the
+		// execution could not be recorded
+		job.MaxAttempts = 3
+		if err := tx.Job().Create(ctx, job); err != nil {
+			return err
+		}
+		execution.JobID = job.ID
+		return tx.ToolExecution().Update(ctx, execution)
+	})
+	if failed != nil {
+		return *failed
+	}
+
+	ts.logger.Info("Started background tool execution",
+		"tool_name", toolCall.Function.Name,
+		"session_id", session.ID,
+		"execution_id", execution.ID)
+
+	return models.ToolCallResult{
+		ID:       toolCall.ID,
+		ToolName: toolCall.Function.Name,
+		Success:  true,
+		Result: map[string]interface{}{
+			"execution_id": execution.ID,
+			"status":       execution.Status,
+			"message":      "The tool is running in the background. Call check_execution with this execution_id to get its result.",
+		},
+	}
+}
+
+// deferExecution validates a tool call that does not run within the turn and
+// records it as an execution in the given status. then, if set, completes the
+// record in the same transaction. Invalid or unrecorded calls return the
+// failed result instead, so that the LLM learns of them now rather than when
+// it polls.
+func (ts *ToolService) deferExecution(ctx context.Context, session *models.ChatSession, toolCall models.LLMToolCall, arguments map[string]interface{}, status string, then func(tx storage.Repository, execution *models.ToolExecution) error) (*models.ToolExecution, *models.ToolCallResult) {
+	failed := func(code, message string) (*models.ToolExecution, *models.ToolCallResult) {
+		return nil, &models.ToolCallResult{
+			ID:        toolCall.ID,
+			ToolName:  toolCall.Function.Name,
+			Success:   false,
+			Error:     message,
+			ErrorCode: code,
+		}
+	}
+
+	tool, exists := ts.registry.Get(toolCall.Function.Name)
+	if !exists {
+		return failed("TOOL_NOT_FOUND", fmt.Sprintf("Tool '%s' not found", toolCall.Function.Name))
+	}
+	if !tool.IsAvailable(ctx) {
+		return failed("TOOL_UNAVAILABLE", fmt.Sprintf("Tool '%s' is not available", toolCall.Function.Name))
+	}
+	input, err := ts.applyPresets(ctx, &session.Agent, toolCall.Function.Name, arguments)
+	if err != nil {
+		return failed("", fmt.Sprintf("Failed to apply tool presets: %v", err))
+	}
+	if err := tool.Validate(input); err != nil {
+		return failed("", err.Error())
+	}
+
+	execution := &models.ToolExecution{
+		SessionID:  session.ID,
+		AgentID:    session.AgentID,
+		ToolCallID: toolCall.ID,
+		ToolName:   toolCall.Function.Name,
+		// Presets are applied when the tool runs so credentials are not stored
+		Arguments: models.JSON(arguments),
+		Status:    status,
+	}
+	err = ts.repository.WithTx(ctx, func(tx storage.Repository) error {
+		if err := tx.ToolExecution().Create(ctx, execution); err != nil {
+			return err
+		}
+		if then == nil {
+			return nil
+		}
+		return then(tx, execution)
+	})
+	if err != nil {
+		ts.logger.Error("Failed to record tool execution",
+			"tool_name", toolCall.Function.Name,
+			"session_id", session.ID,
+			"status", status,
+			"error", err)
+		return failed("", fmt.Sprintf("Failed to record the call: %v", err))
+	}
+	return execution, nil
+}
+
+// HandleToolExecutionJob is the job handler for JobTypeToolExecution
+func (ts *ToolService) HandleToolExecutionJob(ctx context.Context, job *models.Job) error {
+	executionID, _ := job.Payload["execution_id"].(string)
+	if executionID == "" {
+		return fmt.Errorf("tool execution job has no execution_id")
+	}
+
+	executions := ts.repository.ToolExecution()
+	execution, err := executions.GetByID(ctx, executionID)
+	if err != nil {
+		return fmt.Errorf("failed to get tool execution: %w", err)
+	}
+	// Executions deleted with their session or already finished need no run
+	if execution == nil || execution.Done() {
+		return nil
+	}
+	return ts.runExecution(ctx, execution)
+}
+
+// runExecution runs the tool call of an execution and records its result
+func (ts *ToolService) runExecution(ctx context.Context, execution *models.ToolExecution) error {
+	executions := ts.repository.ToolExecution()
+	now := time.Now()
+	execution.Status = models.ToolExecutionRunning
+	execution.StartedAt = &now
+	if err := executions.Update(ctx, execution); err != nil {
+		return fmt.Errorf("failed to mark tool execution running: %w", err)
+	}
+
+	arguments := map[string]interface{}(execution.Arguments)
+
+	runCtx := ctx
+	var audit *tools.HTTPAudit
+	if ts.captureHTTP {
+		runCtx, audit = tools.WithHTTPAudit(ctx, ts.redactor)
+	}
+
+	var result *tools.Result
+	session, err := ts.repository.Session().GetByID(ctx, execution.SessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		result = tools.ErrorResult("SESSION_NOT_FOUND", "session not found")
+	} else if input, err := ts.applyPresets(ctx, &session.Agent, execution.ToolName, arguments); err != nil {
+		result = tools.ErrorResult("PRESET_ERROR", fmt.Sprintf("Failed to apply tool presets: %v", err))
+	} else {
+		input = ts.scrubMemoryInput(ctx, &session.Agent, execution.ToolName, input)
+		runCtx = ts.withRecallHints(runCtx, session, execution.ToolName, input)
+		result = ts.executeToolWithContext(runCtx, execution.ToolName, execution.SessionID, execution.AgentID, session.ToolConfig.Timeout(), input)
+	}
+
+	encoded, _ := json.Marshal(arguments)
+	toolCall := models.LLMToolCall{
+		ID:       execution.ToolCallID,
+		Type:     "function",
+		Function: models.LLMToolCallFunction{Name: execution.ToolName, Arguments: string(encoded)},
+	}
+	callResult := models.ToolCallResult{
+		ID:       execution.ToolCallID,
+		ToolName: execution.ToolName,
+		Success:  result.Success,
+		Result:   ts.offloadLargeOutput(ctx, execution.SessionID, toolCall, result.Data),
+		Error:     result.Error,
+		Duration:  result.Duration.Milliseconds(),
+		ErrorCode: result.ErrorCode,
+		Cost:      ts.accounting.ToolCost(execution.ToolName, result),
+		Artifacts: ts.storeArtifacts(ctx, execution.SessionID, toolCall, result.Artifacts),
+	}
+
+	completed := time.Now()
+	execution.Status = models.ToolExecutionSucceeded
+	if !result.Success {
+		execution.Status = models.ToolExecutionFailed
+	}
+	// The arguments were kept to run the tool; credentials and personal
+	// data go now
+	var agent *models.Agent
+	if session != nil {
+		agent = &session.Agent
+	}
+	execution.Arguments = models.JSON(ts.redactor.Map(ts.scrubArguments(ctx, agent, arguments)))
+	execution.Error = ts.redactor.String(result.Error)
+	execution.ErrorCode = result.ErrorCode
+	execution.Duration = callResult.Duration
+	execution.CompletedAt = &completed
+	if callResult.Result != nil || len(callResult.Artifacts) > 0 {
+		resultJSON := models.JSON(map[string]interface{}{"data": ts.redactor.Value(callResult.Result)})
+		if len(callResult.Artifacts) > 0 {
+			resultJSON["artifacts"] = callResult.Artifacts
+		}
+		execution.Result = &resultJSON
+	}
+	if err := executions.Update(context.WithoutCancel(ctx), execution); err != nil {
+		return fmt.Errorf("failed to record tool execution result: %w", err)
+	}
+
+	if err := ts.logToolExecution(context.WithoutCancel(ctx), execution.SessionID, toolCall, callResult, audit); err != nil {
+		ts.logger.Error("Failed to log tool execution",
+			"tool_name", execution.ToolName,
+			"error", err)
+	}
+
+	ts.logger.Info("Background tool execution finished",
+		"tool_name", execution.ToolName,
+		"execution_id", execution.ID,
+		"status", execution.Status,
+		"duration_ms", execution.Duration)
+
+	return nil
+}
+
+// GetExecution returns a tool execution with credentials in its arguments
+// masked
+func (ts *ToolService) GetExecution(ctx context.Context, id string) (*models.ToolExecution, error) {
+	execution, err := ts.repository.ToolExecution().GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tool execution: %w", err)
+	}
+	if execution == nil {
+		return nil, ErrToolExecutionNotFound
+	}
+	execution.Arguments = models.JSON(ts.redactor.Map(execution.Arguments))
+	return execution, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+)
+
+var (
+	// ErrInvalidToolChoice is returned when a request forces a tool it does
+	// not offer
+	ErrInvalidToolChoice = errors.New("invalid tool choice")
+	// ErrToolChoiceUnmet is returned when the model answers without the tool
+	// call the request forces, even after being asked again
+	ErrToolChoiceUnmet = errors.New("model did not make the required tool call")
+)
+
+// resolveToolChoice parses a request's tool_choice and checks that a forced
+// tool is among the offered ones
+func resolveToolChoice(choice string, offered []string) (models.ToolChoice, error) {
+	parsed, err := models.ParseToolChoice(choice)
+	if err != nil {
+		return parsed, fmt.Errorf("%w: %v", ErrInvalidToolChoice, err)
+	}
+	if !parsed.Forces() {
+		return parsed, nil
+	}
+	if len(offered) == 0 {
+		return parsed, fmt.Errorf("%w: tool_choice %s but no tools are available", ErrInvalidToolChoice, parsed)
+	}
+	if parsed.Tool == "" {
+		return parsed, nil
+	}
+	for _, name := range offered {
+		if name == parsed.Tool {
+			return parsed, nil
+		}
+	}
+	return parsed, fmt.Errorf("%w: tool %s is not available to this request", ErrInvalidToolChoice, parsed.Tool)
+}
+
+// forcedToolRequest prepares a model call under a forced tool choice. A
+// provider that forces calls natively gets the choice as its tool_choice
+// option and all definitions. Other providers only get the forced tool's
+// definition, and the instruction to call it is added to the system prompt;
+// the caller checks the reply with toolChoiceMet.
+func forcedToolRequest(choice models.ToolChoice, native bool, definitions []models.ToolDefinition) (option interface{}, offered []models.ToolDefinition, instructions string) {
+	if native {
+		return llm.ForcedToolChoice(choice.Tool), definitions, ""
+	}
+	if choice.Tool == "" {
+		return models.ToolChoiceAuto, definitions, "\n\n=== TOOL CHOICE ===\nYou MUST call at least one of your tools before answering. Do not answer from memory.\n"
+	}
+	for _, definition := range definitions {
+		if definition.Function.Name == choice.Tool {
+			offered = append(offered, definition)
+		}
+	}
+	return models.ToolChoiceAuto, offered, fmt.Sprintf("\n\n=== TOOL CHOICE ===\nYou MUST call the %s tool before answering. Do not answer without calling it.\n", choice.Tool)
+}
+
+// toolChoiceMet reports whether the model's tool calls satisfy a forced
+// choice
+func toolChoiceMet(choice models.ToolChoice, calls []models.LLMToolCall) bool {
+	if choice.Tool == "" {
+		return len(calls) > 0
+	}
+	for _, call := range calls {
+		if call.Function.Name == choice.Tool {
+			return true
+		}
+	}
+	return false
+}
+
+// toolChoiceCorrection asks for the forced tool call again
+func toolChoiceCorrection(choice models.ToolChoice) string {
+	if choice.Tool == "" {
+		return "Your last answer did not call a tool. Call one of your tools now."
+	}
+	return fmt.Sprintf("Your last answer did not call the %s tool. Call it now.", choice.Tool)
+}
@@ -0,0 +1,100 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/llm/mock"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+)
+
+func TestChatService_ForcedToolChoice(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "tools", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{}}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	toolService := services.NewToolService(repo, slog.Default())
+	newService := func(provider llm.Provider) *services.ChatService {
+		registry := llm.NewRegistry()
+		registry.Register(provider)
+		return services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+			services.NewPromptService(toolService), slog.Default())
+	}
+	newSession := func(agent *models.Agent) string {
+		session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+		require.NoError(t, repo.Session().Create(ctx, session))
+		return session.ID
+	}
+	calculatorCall := &llm.ChatResponse{Metadata: map[string]interface{}{"tool_calls": []map[string]interface{}{
+		{"function": map[string]interface{}{"name": "calculator", "arguments": map[string]interface{}{"expression": "2 + 3"}}},
+	}}}
+
+	// Ollama cannot force calls: only the forced tool is offered, the prompt
+	// demands the call, and a reply without it is sent back once
+	provider := &scriptedProvider{responses: []*llm.ChatResponse{{Content: "It is 5."}, calculatorCall, {Content: "2 + 3 = 5"}}}
+	response, err := newService(provider).ChatWithTools(ctx, &models.EnhancedChatRequest{
+		Message:    "what is 2 + 3?",
+		Tools:      []string{"calculator", "http_get"},
+		ToolChoice: "tool:calculator",
+	}, newSession(agent))
+	require.NoError(t, err)
+	assert.Equal(t, "2 + 3 = 5", response.Response)
+	require.Len(t, response.ToolCalls, 1)
+	assert.Equal(t, "calculator", response.ToolCalls[0].ToolName)
+
+	require.Len(t, provider.requests, 3)
+	first := provider.requests[0]
+	offered := first.Options["tools"].([]models.ToolDefinition)
+	require.Len(t, offered, 1)
+	assert.Equal(t, "calculator", offered[0].Function.Name)
+	assert.Equal(t, models.ToolChoiceAuto, first.Options["tool_choice"])
+	assert.Contains(t, first.Messages[0].Content, "You MUST call the calculator tool")
+	retry := provider.requests[1].Messages
+	assert.Contains(t, retry[len(retry)-1].Content, "did not call the calculator tool")
+	assert.Len(t, provider.requests[2].Options["tools"], 2, "the choice is lifted after the call")
+	assert.NotContains(t, agent.Config, "tools")
+
+	// A second miss fails the turn
+	provider = &scriptedProvider{responses: []*llm.ChatResponse{{Content: "No."}, {Content: "Still no."}}}
+	_, err = newService(provider).ChatWithTools(ctx, &models.EnhancedChatRequest{
+		Message:    "what is 2 + 3?",
+		Tools:      []string{"calculator"},
+		ToolChoice: models.ToolChoiceRequired,
+	}, newSession(agent))
+	assert.ErrorIs(t, err, services.ErrToolChoiceUnmet)
+
+	// Forcing a tool that is not offered is rejected before anything is saved
+	sessionID := newSession(agent)
+	_, err = newService(provider).ChatWithTools(ctx, &models.EnhancedChatRequest{
+		Message:    "hi",
+		Tools:      []string{"calculator"},
+		ToolChoice: "tool:teleport",
+	}, sessionID)
+	assert.ErrorIs(t, err, services.ErrInvalidToolChoice)
+	messages, _, err := repo.Message().ListBySessionID(ctx, sessionID, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+
+	// The mock forces calls natively
+	mockAgent := &models.Agent{Name: "mock", Provider: mock.Name, Model: "mock", Enabled: true, Config: models.JSON{}}
+	require.NoError(t, repo.Agent().Create(ctx, mockAgent))
+	response, err = newService(mock.NewProvider(mock.Options{ReplyWords: 5})).ChatWithTools(ctx, &models.EnhancedChatRequest{
+		Message:    "hi",
+		Tools:      []string{"calculator"},
+		ToolChoice: models.ToolChoiceRequired,
+	}, newSession(mockAgent))
+	require.NoError(t, err)
+	require.Len(t, response.ToolCalls, 1)
+	assert.Equal(t, "calculator", response.ToolCalls[0].ToolName)
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+
+	"agent-server/internal/models"
+)
+
+// Event types reported while a tool-calling chat is in progress
+const (
+	EventThinking         = "thinking"
+	EventToolCallStarted  = "tool_call_started"
+	EventToolCallFinished = "tool_call_finished"
+	EventDone             = "done"
+	EventError            = "error"
+)
+
+// ToolChatEvent reports the progress of a tool-calling chat. Done events
+// carry the final response and error events the error that ended the turn.
+type ToolChatEvent struct {
+	Type       string                       `json:"type"`
+	Iteration  int                          `json:"iteration,omitempty"`
+	ToolCallID string                       `json:"tool_call_id,omitempty"`
+	ToolName   string                       `json:"tool_name,omitempty"`
+	Status     string                       `json:"status,omitempty"` // "success" or "error"
+	Duration   int64                        `json:"duration_ms,omitempty"`
+	Error      string                       `json:"error,omitempty"`
+	Artifacts  []models.ToolArtifact        `json:"artifacts,omitempty"` // returned by the finished call
+	Response   *models.EnhancedChatResponse `json:"response,omitempty"`
+	Err        error                        `json:"-"`
+}
+
+// StreamWithTools runs a tool-calling chat in the background and reports its
+// progress on the returned channel, ending with a done or error event. Errors
+// that prevent the turn from starting are returned directly.
+func (s *ChatService) StreamWithTools(ctx context.Context, req *models.EnhancedChatRequest, sessionID string) (<-chan ToolChatEvent, error) {
+	if s.dispatches(ctx) {
+		return s.remoteStreamWithTools(ctx, req, sessionID)
+	}
+
+	turn, err := s.startToolTurn(ctx, req, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan ToolChatEvent, 10)
+	go func() {
+		defer close(events)
+
+		emit := func(event ToolChatEvent) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+			}
+		}
+
+		response, err := s.finishToolTurn(ctx, turn, emit)
+		if err != nil {
+			emit(ToolChatEvent{Type: EventError, Error: err.Error(), Err: err})
+			return
+		}
+		emit(ToolChatEvent{Type: EventDone, Response: response})
+	}()
+
+	return events, nil
+}
+
+// toolCallFinishedEvent describes the outcome of one tool call
+func toolCallFinishedEvent(iteration int, result models.ToolCallResult) ToolChatEvent {
+	event := ToolChatEvent{
+		Type:       EventToolCallFinished,
+		Iteration:  iteration,
+		ToolCallID: result.ID,
+		ToolName:   result.ToolName,
+		Status:     "success",
+		Duration:   result.Duration,
+		Artifacts:  result.Artifacts,
+	}
+	if !result.Success {
+		event.Status = "error"
+		event.Error = result.Error
+	}
+	return event
+}
@@ -0,0 +1,103 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedProvider replies with its responses in order and streams its chunks
+type scriptedProvider struct {
+	responses []*llm.ChatResponse
+	chunks    []llm.StreamChunk
+	requests  []*llm.ChatRequest
+}
+
+func (p *scriptedProvider) Name() string { return "ollama" }
+
+func (p *scriptedProvider) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.requests = append(p.requests, req)
+	if len(p.responses) == 0 {
+		return nil, errors.New("no scripted response left")
+	}
+	response := p.responses[0]
+	p.responses = p.responses[1:]
+	return response, nil
+}
+
+func (p *scriptedProvider) Stream(ctx context.Context, req *llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	p.requests = append(p.requests, req)
+	chunks := make(chan llm.StreamChunk, len(p.chunks))
+	for _, chunk := range p.chunks {
+		chunks <- chunk
+	}
+	close(chunks)
+	return chunks, nil
+}
+
+func (p *scriptedProvider) Models(ctx context.Context) ([]string, error) { return nil, nil }
+
+func (p *scriptedProvider) ValidateConfig(config map[string]interface{}) error { return nil }
+
+func (p *scriptedProvider) IsAvailable(ctx context.Context) bool { return true }
+
+func TestChatService_StreamWithTools(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "tools", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{}}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	registry := llm.NewRegistry()
+	registry.Register(&scriptedProvider{responses: []*llm.ChatResponse{
+		{Metadata: map[string]interface{}{"tool_calls": []map[string]interface{}{
+			{"function": map[string]interface{}{"name": "calculator", "arguments": map[string]interface{}{"expression": "2 + 3"}}},
+		}}},
+		{Content: "2 + 3 = 5"},
+	}})
+	toolService := services.NewToolService(repo, slog.Default())
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+
+	events, err := service.StreamWithTools(ctx, &models.EnhancedChatRequest{Message: "what is 2 + 3?"}, session.ID)
+	require.NoError(t, err)
+
+	var types []string
+	var finished, done services.ToolChatEvent
+	for event := range events {
+		types = append(types, event.Type)
+		switch event.Type {
+		case services.EventToolCallFinished:
+			finished = event
+		case services.EventDone:
+			done = event
+		}
+	}
+
+	assert.Equal(t, []string{
+		services.EventThinking,
+		services.EventToolCallStarted,
+		services.EventToolCallFinished,
+		services.EventThinking,
+		services.EventDone,
+	}, types)
+	assert.Equal(t, "calculator", finished.ToolName)
+	assert.Equal(t, "success", finished.Status)
+	require.NotNil(t, done.Response)
+	assert.Equal(t, "2 + 3 = 5", done.Response.Response)
+	assert.Len(t, done.Response.ToolCalls, 1)
+}
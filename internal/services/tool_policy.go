@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"agent-server/internal/models"
+)
+
+// ErrToolNotRegistered is returned when setting the state of a tool the
+// server does not have
+var ErrToolNotRegistered = errors.New("tool is not registered")
+
+// toolPolicy holds which tools are disabled across the deployment
+type toolPolicy struct {
+	mu       sync.Mutex
+	config   map[string]bool // disabled by the config
+	refresh  time.Duration   // between reloads of the states set at runtime
+	stored   map[string]*models.ToolState
+	loadedAt time.Time
+}
+
+// SetDisabledTools disables the named tools across the deployment, whatever
+// the agents' settings: they are not offered to the LLM or listed, and their
+// calls fail with TOOL_DISABLED. States set at runtime override the config
+// and are reloaded every refresh, so instances sharing the database pick
+// them up.
+func (ts *ToolService) SetDisabledTools(names []string, refresh time.Duration) {
+	config := make(map[string]bool, len(names))
+	for _, name := range names {
+		config[name] = true
+	}
+	ts.policy.mu.Lock()
+	ts.policy.config = config
+	ts.policy.refresh = refresh
+	ts.policy.mu.Unlock()
+	ts.applyToolStates()
+}
+
+// LoadToolStates reads the tool states set at runtime
+func (ts *ToolService) LoadToolStates(ctx context.Context) error {
+	states, err := ts.repository.ToolState().List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list tool states: %w", err)
+	}
+	stored := make(map[string]*models.ToolState, len(states))
+	for _, state := range states {
+		state.Source = models.ToolStateSourceRuntime
+		stored[state.Name] = state
+	}
+
+	ts.policy.mu.Lock()
+	ts.policy.stored = stored
+	ts.policy.loadedAt = time.Now()
+	ts.policy.mu.Unlock()
+	ts.applyToolStates()
+	return nil
+}
+
+// refreshToolStates reloads the states set at runtime once the refresh
+// interval has passed. A failed reload keeps the states it has.
+func (ts *ToolService) refreshToolStates(ctx context.Context) {
+	ts.policy.mu.Lock()
+	stale := ts.policy.refresh > 0 && time.Since(ts.policy.loadedAt) >= ts.policy.refresh
+	if stale {
+		// Concurrent callers keep the current states meanwhile
+		ts.policy.loadedAt = time.Now()
+	}
+	ts.policy.mu.Unlock()
+	if !stale {
+		return
+	}
+	if err := ts.LoadToolStates(ctx); err != nil {
+		ts.logger.Warn("Failed to reload tool states", "error", err)
+	}
+}
+
+// applyToolStates disables the tools of the current states in the registry.
+// Tools registered later, such as those of embedding programs, are covered
+// too.
+func (ts *ToolService) applyToolStates() {
+	ts.policy.mu.Lock()
+	defer ts.policy.mu.Unlock()
+	var disabled []string
+	for name := range ts.policy.config {
+		if ts.policy.stored[name] == nil {
+			disabled = append(disabled, name)
+		}
+	}
+	for name, state := range ts.policy.stored {
+		if state.Disabled {
+			disabled = append(disabled, name)
+		}
+	}
+	ts.registry.SetDisabled(disabled)
+}
+
+// toolStates returns the state of every registered tool, by name
+func (ts *ToolService) toolStates() []*models.ToolState {
+	ts.policy.mu.Lock()
+	defer ts.policy.mu.Unlock()
+
+	names := ts.registry.ListAll()
+	states := make([]*models.ToolState, len(names))
+	for i, name := range names {
+		switch {
+		case ts.policy.stored[name] != nil:
+			states[i] = ts.policy.stored[name]
+		case ts.policy.config[name]:
+			states[i] = &models.ToolState{Name: name, Disabled: true, Source: models.ToolStateSourceConfig}
+		default:
+			states[i] = &models.ToolState{Name: name, Source: models.ToolStateSourceDefault}
+		}
+	}
+	return states
+}
+
+// ToolStates returns whether each registered tool is disabled and where
+// that is defined
+func (ts *ToolService) ToolStates(ctx context.Context) []*models.ToolState {
+	ts.refreshToolStates(ctx)
+	return ts.toolStates()
+}
+
+// SetToolState stores a tool's state at runtime, overriding the config
+func (ts *ToolService) SetToolState(ctx context.Context, state *models.ToolState) error {
+	if !ts.isRegistered(state.Name) {
+		return ErrToolNotRegistered
+	}
+	state.Source = models.ToolStateSourceRuntime
+	if err := ts.repository.ToolState().Save(ctx, state); err != nil {
+		return fmt.Errorf("failed to save tool state: %w", err)
+	}
+	ts.logger.Info("Tool state set",
+		"tool_name", state.Name,
+		"disabled", state.Disabled)
+	return ts.LoadToolStates(ctx)
+}
+
+// ResetToolState removes the state set at runtime of a tool, which reverts
+// to the config. It reports whether there was a state to remove.
+func (ts *ToolService) ResetToolState(ctx context.Context, name string) (bool, error) {
+	if err := ts.LoadToolStates(ctx); err != nil {
+		return false, err
+	}
+	ts.policy.mu.Lock()
+	_, stored := ts.policy.stored[name]
+	ts.policy.mu.Unlock()
+	if !stored {
+		return false, nil
+	}
+	if err := ts.repository.ToolState().Delete(ctx, name); err != nil {
+		return false, fmt.Errorf("failed to delete tool state: %w", err)
+	}
+	ts.logger.Info("Tool state reset", "tool_name", name)
+	return true, ts.LoadToolStates(ctx)
+}
+
+// isRegistered reports whether the registry has a tool, disabled or not
+func (ts *ToolService) isRegistered(name string) bool {
+	for _, registered := range ts.registry.ListAll() {
+		if registered == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"agent-server/internal/credentials"
+	"agent-server/internal/models"
+	"agent-server/internal/tools"
+)
+
+// credentialScheme prefixes preset values that name a configured credential
+const credentialScheme = "credential://"
+
+// tokenSuffix asks for the bare access token of an OAuth2 credential rather
+// than an Authorization header value
+const tokenSuffix = "#token"
+
+// SetCredentials sets the named credentials agents may inject into tool input
+// through their tool presets
+func (ts *ToolService) SetCredentials(credentials map[string]string) {
+	ts.credentials = credentials
+}
+
+// SetOAuth2 sets the manager of the OAuth2 credentials agents may inject
+// into tool input through their tool presets
+func (ts *ToolService) SetOAuth2(manager *credentials.Manager) {
+	ts.oauth2 = manager
+}
+
+// applyPresets merges the agent's presets for a tool into the arguments the
+// LLM supplied, resolving credential references. Credentials are only
+// injected when every URL the LLM supplied points at one of the preset's
+// allowed_hosts.
+func (ts *ToolService) applyPresets(ctx context.Context, agent *models.Agent, toolName string, arguments map[string]interface{}) (map[string]interface{}, error) {
+	presets, allowedHosts := tools.SplitAllowedHosts(agent.ToolPresets(toolName))
+	if len(presets) == 0 {
+		return arguments, nil
+	}
+	if hasCredential(presets) {
+		if err := checkCredentialHosts(toolName, arguments, presets, allowedHosts); err != nil {
+			return nil, err
+		}
+	}
+	resolved, err := ts.resolveCredentials(ctx, presets)
+	if err != nil {
+		return nil, err
+	}
+	return tools.MergePresets(arguments, resolved.(map[string]interface{})), nil
+}
+
+// checkCredentialHosts refuses arguments with a URL outside the allowed
+// hosts. URL parameters fixed by the presets are trusted; the ones the LLM
+// supplied ("url" or "*_url") must name an allowed host.
+func checkCredentialHosts(toolName string, arguments, presets map[string]interface{}, allowedHosts []string) error {
+	for key, value := range arguments {
+		if key != "url" && !strings.HasSuffix(key, "_url") {
+			continue
+		}
+		if _, fixed := presets[key]; fixed {
+			continue
+		}
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var host string
+		if u, err := url.Parse(raw); err == nil {
+			host = u.Hostname()
+		}
+		if !tools.HostAllowed(host, allowedHosts) {
+			return fmt.Errorf("credentials of tool %s are not sent to %q: host is not in its allowed_hosts", toolName, raw)
+		}
+	}
+	return nil
+}
+
+// hasCredential reports whether a preset value refers to a credential
+func hasCredential(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return strings.HasPrefix(v, credentialScheme)
+	case map[string]interface{}:
+		for _, item := range v {
+			if hasCredential(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if hasCredential(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveCredentials returns a copy of value with credential references
+// replaced by the configured credentials. An OAuth2 credential resolves to
+// "Bearer <access token>", or with the #token suffix to the bare token.
+func (ts *ToolService) resolveCredentials(ctx context.Context, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.HasPrefix(v, credentialScheme) {
+			return v, nil
+		}
+		name := strings.TrimPrefix(v, credentialScheme)
+		if credential, ok := ts.credentials[name]; ok {
+			return credential, nil
+		}
+		bare := strings.HasSuffix(name, tokenSuffix)
+		name = strings.TrimSuffix(name, tokenSuffix)
+		if !ts.oauth2.Has(name) {
+			return nil, fmt.Errorf("credential %q is not configured", name)
+		}
+		token, err := ts.oauth2.Token(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if bare {
+			return token, nil
+		}
+		return "Bearer " + token, nil
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			r, err := ts.resolveCredentials(ctx, item)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = r
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			r, err := ts.resolveCredentials(ctx, item)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = r
+		}
+		return resolved, nil
+	}
+	return value, nil
+}
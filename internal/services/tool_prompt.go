@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"agent-server/internal/models"
+)
+
+// BuildToolPrompt builds the system prompt describing the tools on offer in
+// the agent's tool prompt mode
+func (ps *PromptService) BuildToolPrompt(ctx context.Context, policy *models.AgentToolPrompt, basePrompt string, availableTools []string) string {
+	if policy != nil && policy.Mode == models.ToolPromptCompact {
+		return ps.BuildCompactSystemPrompt(ctx, basePrompt, availableTools)
+	}
+	return ps.BuildSystemPrompt(ctx, basePrompt, availableTools)
+}
+
+// BuildCompactSystemPrompt describes each tool in one line. The parameters
+// are left to the tool definitions passed natively to the model, which
+// already carry the full schemas.
+func (ps *PromptService) BuildCompactSystemPrompt(ctx context.Context, basePrompt string, availableTools []string) string {
+	var prompt strings.Builder
+	if basePrompt == "" {
+		basePrompt = SystemPrompts.ToolEnabled
+	}
+	prompt.WriteString(basePrompt)
+	prompt.WriteString("\n\n")
+	if len(availableTools) == 0 {
+		return prompt.String()
+	}
+
+	prompt.WriteString("=== AVAILABLE TOOLS ===\n")
+	prompt.WriteString("Call these tools through the tool interface, which describes their parameters:\n")
+	prompt.WriteString(ps.toolSummaries(availableTools))
+	prompt.WriteString("\n")
+
+	for _, toolName := range availableTools {
+		if citableTools[toolName] {
+			prompt.WriteString(citationInstructions)
+			break
+		}
+	}
+	prompt.WriteString("Use tools whenever they match the task instead of doing their work yourself.\n\n")
+	return prompt.String()
+}
+
+// toolSummaries lists tools one per line, with the first sentence of their
+// descriptions
+func (ps *PromptService) toolSummaries(availableTools []string) string {
+	var summaries strings.Builder
+	for _, toolName := range availableTools {
+		if tool, exists := ps.toolService.GetRegistry().Get(toolName); exists {
+			schema := tool.Schema()
+			summaries.WriteString("- " + schema.Name + ": " + firstSentence(schema.Description) + "\n")
+		}
+	}
+	return summaries.String()
+}
+
+// firstSentence returns the first sentence of a description
+func firstSentence(text string) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	if i := strings.Index(text, ". "); i >= 0 {
+		text = text[:i+1]
+	}
+	return text
+}
+
+// arithmetic matches expressions such as "2+2" or "15 * 23", which ask for
+// the calculator without naming it
+var arithmetic = regexp.MustCompile(`\d\s*[-+*/^%]\s*\d`)
+
+// toolStopwords are too common to tell tools apart
+var toolStopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "from": true, "this": true, "that": true,
+	"what": true, "which": true, "when": true, "how": true, "you": true, "your": true, "can": true,
+	"please": true, "use": true, "using": true, "tool": true, "tools": true, "into": true, "are": true,
+	"was": true, "will": true, "have": true, "has": true, "not": true, "all": true, "any": true,
+	"example": true, "usage": true, "always": true, "only": true, "also": true, "need": true,
+}
+
+// RelevantTools narrows the tools offered for a message to the limit most
+// relevant ones, scored by the words the message shares with their names,
+// descriptions, parameters and usage instructions. keep, a tool the request
+// forces, is always offered. When no tool matches, the first tools are
+// offered. The tools keep their order, so that the prompt stays stable.
+func (ps *PromptService) RelevantTools(message string, availableTools []string, limit int, keep string) []string {
+	if limit <= 0 || len(availableTools) <= limit {
+		return availableTools
+	}
+
+	terms := toolTerms(message)
+	if arithmetic.MatchString(message) {
+		terms = append(terms, "calculation")
+	}
+
+	scores := make(map[string]int, len(availableTools))
+	for _, toolName := range availableTools {
+		scores[toolName] = ps.relevance(toolName, terms)
+	}
+	ranked := append([]string(nil), availableTools...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i]] > scores[ranked[j]]
+	})
+
+	selected := make(map[string]bool, limit)
+	if keep != "" {
+		selected[keep] = true
+	}
+	matched := scores[ranked[0]] > 0
+	for _, toolName := range ranked {
+		if len(selected) >= limit {
+			break
+		}
+		if matched && scores[toolName] == 0 {
+			break
+		}
+		selected[toolName] = true
+	}
+
+	offered := make([]string, 0, len(selected))
+	for _, toolName := range availableTools {
+		if selected[toolName] {
+			offered = append(offered, toolName)
+		}
+	}
+	return offered
+}
+
+// relevance scores a tool for the terms of a message. A term matching a
+// word of the tool's name counts three times.
+func (ps *PromptService) relevance(toolName string, terms []string) int {
+	names := toolTerms(strings.ReplaceAll(toolName, "_", " "))
+	var text strings.Builder
+	if tool, exists := ps.toolService.GetRegistry().Get(toolName); exists {
+		schema := tool.Schema()
+		text.WriteString(schema.Description + " ")
+		for _, param := range schema.Parameters {
+			text.WriteString(param.Name + " " + param.Description + " ")
+		}
+		for _, example := range schema.Examples {
+			text.WriteString(example.Description + " ")
+		}
+	}
+	text.WriteString(ToolUsagePrompts[toolName])
+	words := toolTerms(text.String())
+
+	score := 0
+	for _, term := range terms {
+		switch {
+		case matchesAny(term, names):
+			score += 3
+		case matchesAny(term, words):
+			score++
+		}
+	}
+	return score
+}
+
+// toolTerms returns the distinct lowercase words of text worth matching
+func toolTerms(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := make(map[string]bool, len(fields))
+	terms := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if len(field) < 3 || toolStopwords[field] || seen[field] {
+			continue
+		}
+		seen[field] = true
+		terms = append(terms, field)
+	}
+	return terms
+}
+
+// matchesAny reports whether term matches one of words, also in another
+// form: "calculate" matches "calculator" and "fetch" matches "fetching"
+func matchesAny(term string, words []string) bool {
+	for _, word := range words {
+		if sameStem(term, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameStem reports whether two words are alike: one starts with the other
+// of at least four letters, or they share their first six
+func sameStem(a, b string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if a == b || (len(a) >= 4 && strings.HasPrefix(b, a)) {
+		return true
+	}
+	return len(a) >= 6 && a[:6] == b[:6]
+}
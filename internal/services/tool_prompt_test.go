@@ -0,0 +1,141 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptService_RelevantTools(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	prompts := services.NewPromptService(services.NewToolService(repo, slog.Default()))
+
+	all := []string{"calculator", "http_get", "web_scraper", "text_processor", "json_processor", "encoder"}
+	assert.Equal(t, []string{"calculator"}, prompts.RelevantTools("What is 15 * 23?", all, 2, ""))
+	assert.Contains(t, prompts.RelevantTools("Fetch the website at https://example.com", all, 2, ""), "http_get")
+	assert.Equal(t, []string{"encoder"}, prompts.RelevantTools("Give me the sha256 hash of hello", all, 1, ""))
+
+	// A forced tool stays on offer
+	assert.Equal(t, []string{"calculator", "json_processor"}, prompts.RelevantTools("What is 2+2?", all, 2, "json_processor"))
+
+	// Without a match the first tools are offered; a limit of 0 offers all
+	assert.Equal(t, []string{"calculator", "http_get"}, prompts.RelevantTools("Hello there", all, 2, ""))
+	assert.Equal(t, all, prompts.RelevantTools("Hello there", all, 0, ""))
+}
+
+func TestPromptService_CompactToolPrompt(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	prompts := services.NewPromptService(services.NewToolService(repo, slog.Default()))
+	ctx := context.Background()
+
+	tools := []string{"calculator", "http_get", "web_scraper", "text_processor", "json_processor", "encoder"}
+	full := prompts.BuildSystemPrompt(ctx, "Be brief.", tools)
+	compact := prompts.BuildToolPrompt(ctx, &models.AgentToolPrompt{Mode: models.ToolPromptCompact}, "Be brief.", tools)
+	assert.Contains(t, compact, "- calculator: ")
+	assert.NotContains(t, compact, "CALCULATOR TOOL USAGE")
+	assert.Less(t, len(compact)*3, len(full))
+
+	// Citable tools still get the citation instructions
+	assert.Contains(t, compact, "=== CITATIONS ===")
+	assert.Equal(t, full, prompts.BuildToolPrompt(ctx, nil, "Be brief.", tools))
+}
+
+func TestChatService_ToolPromptPolicy(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "toolbox", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{},
+		SystemPrompt: "You help.", ToolPrompt: &models.AgentToolPrompt{Mode: models.ToolPromptCompact, MaxTools: 1}}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{responses: []*llm.ChatResponse{{Content: "4"}}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	toolService := services.NewToolService(repo, slog.Default())
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+
+	_, err = service.ChatWithTools(ctx, &models.EnhancedChatRequest{Message: "What is 2+2?",
+		Tools: []string{"http_get", "calculator", "encoder"}}, session.ID)
+	require.NoError(t, err)
+
+	// Only the relevant tool is described, in one line, and defined natively
+	require.Len(t, provider.requests, 1)
+	request := provider.requests[0]
+	definitions := request.Options["tools"].([]models.ToolDefinition)
+	require.Len(t, definitions, 1)
+	assert.Equal(t, "calculator", definitions[0].Function.Name)
+	assert.Contains(t, request.Messages[0].Content, "- calculator: ")
+	assert.NotContains(t, request.Messages[0].Content, "http_get")
+	assert.NotContains(t, request.Messages[0].Content, "CALCULATOR TOOL USAGE")
+}
+
+func TestChatService_ModelToolSelection(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "toolbox", Provider: "ollama", Model: "llama3", Enabled: true, Config: models.JSON{}, SystemPrompt: "You help.",
+		ToolPrompt: &models.AgentToolPrompt{MaxTools: 1, Selector: models.ToolSelectorModel, SelectorModel: "qwen2:0.5b"}}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID, ContextStrategy: "last_n"}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	provider := &scriptedProvider{responses: []*llm.ChatResponse{
+		{Content: "1. encoder\n2. calculator", Usage: &llm.Usage{PromptTokens: 90, CompletionTokens: 4, TotalTokens: 94}},
+		{Content: "Done."},
+	}}
+	registry := llm.NewRegistry()
+	registry.Register(provider)
+	toolService := services.NewToolService(repo, slog.Default())
+	service := services.NewChatService(repo, registry, contextpkg.NewStrategyRegistry(), toolService,
+		services.NewPromptService(toolService), slog.Default())
+
+	tools := []string{"http_get", "calculator", "encoder"}
+	response, err := service.ChatWithTools(ctx, &models.EnhancedChatRequest{Message: "Hash my name", Tools: tools}, session.ID)
+	require.NoError(t, err)
+
+	// The small model sees the tools in one line each and picks the first
+	require.Len(t, provider.requests, 2)
+	assert.Equal(t, "qwen2:0.5b", provider.requests[0].Model)
+	assert.Contains(t, provider.requests[0].Messages[0].Content, "- encoder: ")
+	assert.Equal(t, "Hash my name", provider.requests[0].Messages[1].Content)
+	definitions := provider.requests[1].Options["tools"].([]models.ToolDefinition)
+	require.Len(t, definitions, 1)
+	assert.Equal(t, "encoder", definitions[0].Function.Name)
+
+	selection := response.Metadata["tool_selection"].(map[string]interface{})
+	assert.Equal(t, "model", selection["selector"])
+	assert.Equal(t, "qwen2:0.5b", selection["model"])
+	assert.Equal(t, []interface{}{"encoder"}, selection["tools"])
+	assert.NotNil(t, selection["usage"])
+
+	// A selector that cannot be reached falls back to keywords
+	agent.ToolPrompt.SelectorProvider = "openai"
+	require.NoError(t, repo.Agent().Update(ctx, agent))
+	provider.responses = []*llm.ChatResponse{{Content: "4"}}
+	response, err = service.ChatWithTools(ctx, &models.EnhancedChatRequest{Message: "What is 2+2?", Tools: tools}, session.ID)
+	require.NoError(t, err)
+	selection = response.Metadata["tool_selection"].(map[string]interface{})
+	assert.Equal(t, "keywords", selection["selector"])
+	assert.Equal(t, "model", selection["fallback_from"])
+	assert.Equal(t, []interface{}{"calculator"}, selection["tools"])
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"agent-server/internal/models"
+)
+
+// QuotaExceededErrorCode marks tool calls rejected by a quota
+const QuotaExceededErrorCode = "QUOTA_EXCEEDED"
+
+// ErrAgentNotFound is returned for unknown agent IDs
+var ErrAgentNotFound = errors.New("agent not found")
+
+// ToolQuota limits tool calls; a zero field leaves that limit off
+type ToolQuota struct {
+	PerTurn     int
+	PerSession  int
+	PerAgentDay int // per UTC day
+}
+
+func (q ToolQuota) isZero() bool {
+	return q == ToolQuota{}
+}
+
+// ToolQuotas holds the overall quota and per-tool caps
+type ToolQuotas struct {
+	ToolQuota
+	Tools map[string]ToolQuota
+}
+
+// SetQuotas sets the tool call quotas enforced for every session
+func (ts *ToolService) SetQuotas(quotas ToolQuotas) {
+	ts.quotas = quotas
+}
+
+// turnCounter counts the tool calls of one chat turn
+type turnCounter struct {
+	mu     sync.Mutex
+	total  int
+	byTool map[string]int
+}
+
+type turnCounterKey struct{}
+
+// withToolTurn starts counting tool calls against the per-turn quota; calls
+// made with the returned context belong to one turn
+func withToolTurn(ctx context.Context) context.Context {
+	return context.WithValue(ctx, turnCounterKey{}, &turnCounter{byTool: map[string]int{}})
+}
+
+// startOfDay returns midnight UTC of the day t falls on
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// checkQuota reports why a call of the tool in the session would exceed a
+// quota, or "" when it may run. Calls that may run count against the
+// per-turn quota.
+func (ts *ToolService) checkQuota(ctx context.Context, session *models.ChatSession, toolName string) string {
+	toolQuota := ts.quotas.Tools[toolName]
+	if ts.quotas.ToolQuota.isZero() && toolQuota.isZero() {
+		return ""
+	}
+
+	counter, _ := ctx.Value(turnCounterKey{}).(*turnCounter)
+	if counter != nil {
+		counter.mu.Lock()
+		defer counter.mu.Unlock()
+
+		if limit := ts.quotas.PerTurn; limit > 0 && counter.total >= limit {
+			return fmt.Sprintf("Tool call quota exceeded: at most %d tool calls per turn. Answer with the information you have.", limit)
+		}
+		if limit := toolQuota.PerTurn; limit > 0 && counter.byTool[toolName] >= limit {
+			return fmt.Sprintf("Tool call quota exceeded: at most %d %s calls per turn. Answer with the information you have.", limit, toolName)
+		}
+	}
+
+	checks := []struct {
+		limit  int
+		filter models.ToolCallCountFilter
+		scope  string
+	}{
+		{ts.quotas.PerSession, models.ToolCallCountFilter{SessionID: session.ID}, "tool calls per session"},
+		{toolQuota.PerSession, models.ToolCallCountFilter{SessionID: session.ID, ToolName: toolName}, toolName + " calls per session"},
+		{ts.quotas.PerAgentDay, models.ToolCallCountFilter{AgentID: session.AgentID, Since: startOfDay(time.Now())}, "tool calls per agent per day"},
+		{toolQuota.PerAgentDay, models.ToolCallCountFilter{AgentID: session.AgentID, ToolName: toolName, Since: startOfDay(time.Now())}, toolName + " calls per agent per day"},
+	}
+	for _, check := range checks {
+		if check.limit <= 0 {
+			continue
+		}
+		check.filter.ExcludeErrorCode = QuotaExceededErrorCode
+		count, err := ts.repository.ToolExecutionLog().CountCalls(ctx, check.filter)
+		if err != nil {
+			// A quota that cannot be read does not block the agent
+			ts.logger.Error("Failed to count tool calls for quota",
+				"tool_name", toolName,
+				"session_id", session.ID,
+				"error", err)
+			continue
+		}
+		if count >= int64(check.limit) {
+			return fmt.Sprintf("Tool call quota exceeded: at most %d %s.", check.limit, check.scope)
+		}
+	}
+
+	if counter != nil {
+		counter.total++
+		counter.byTool[toolName]++
+	}
+	return ""
+}
+
+// QuotaUsage returns the tool call counters of an agent, and of a session
+// when sessionID is set, against the configured quotas
+func (ts *ToolService) QuotaUsage(ctx context.Context, agentID, sessionID string) (*models.ToolQuotaUsage, error) {
+	if sessionID != "" {
+		session, err := ts.repository.Session().GetByID(ctx, sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get session: %w", err)
+		}
+		if session == nil {
+			return nil, ErrSessionNotFound
+		}
+		agentID = session.AgentID
+	} else {
+		agent, err := ts.repository.Agent().GetByID(ctx, agentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get agent: %w", err)
+		}
+		if agent == nil {
+			return nil, ErrAgentNotFound
+		}
+	}
+
+	day := startOfDay(time.Now())
+	usage := &models.ToolQuotaUsage{
+		AgentID:   agentID,
+		SessionID: sessionID,
+		Day:       day.Format("2006-01-02"),
+	}
+
+	counters := func(toolName string, quota ToolQuota) (models.ToolQuotaCounters, error) {
+		c := models.ToolQuotaCounters{
+			TurnLimit:     quota.PerTurn,
+			SessionLimit:  quota.PerSession,
+			AgentDayLimit: quota.PerAgentDay,
+		}
+		var err error
+		c.AgentDayCalls, err = ts.repository.ToolExecutionLog().CountCalls(ctx, models.ToolCallCountFilter{
+			AgentID:          agentID,
+			ToolName:         toolName,
+			Since:            day,
+			ExcludeErrorCode: QuotaExceededErrorCode,
+		})
+		if err != nil || sessionID == "" {
+			return c, err
+		}
+		c.SessionCalls, err = ts.repository.ToolExecutionLog().CountCalls(ctx, models.ToolCallCountFilter{
+			SessionID:        sessionID,
+			ToolName:         toolName,
+			ExcludeErrorCode: QuotaExceededErrorCode,
+		})
+		return c, err
+	}
+
+	var err error
+	if usage.Total, err = counters("", ts.quotas.ToolQuota); err != nil {
+		return nil, fmt.Errorf("failed to count tool calls: %w", err)
+	}
+
+	for name, quota := range ts.quotas.Tools {
+		c, err := counters(name, quota)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count %s calls: %w", name, err)
+		}
+		if usage.Tools == nil {
+			usage.Tools = make(map[string]models.ToolQuotaCounters, len(ts.quotas.Tools))
+		}
+		usage.Tools[name] = c
+	}
+
+	return usage, nil
+}
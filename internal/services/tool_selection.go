@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+)
+
+// toolSelectionPrompt instructs the model that picks the tools of a turn
+const toolSelectionPrompt = `You pick the tools an AI assistant may need to answer a user message.
+Reply with the names of at most %d of the tools below, most useful first, one per line and nothing else.
+Reply NONE when no tool helps.
+
+%s`
+
+// toolSelectionTimeout bounds the selector call, which holds up the reply
+const toolSelectionTimeout = 10 * time.Second
+
+// selectTools narrows the tools of a turn to the agent's max_tools most
+// relevant to the message, by keywords or by asking a small model. A tool
+// the choice forces is always kept. It returns the tools with the metadata
+// of the selection, which is nil when all tools are offered. A selector
+// model that fails, or picks nothing under a required choice, falls back to
+// keywords.
+func (s *ChatService) selectTools(ctx context.Context, agent *models.Agent, message string, availableTools []string, choice models.ToolChoice) ([]string, map[string]interface{}) {
+	policy := agent.ToolPrompt
+	if policy == nil || policy.MaxTools <= 0 || len(availableTools) <= policy.MaxTools {
+		return availableTools, nil
+	}
+
+	metadata := map[string]interface{}{"selector": models.ToolSelectorKeywords}
+	if policy.Selector == models.ToolSelectorModel {
+		metadata["selector"] = models.ToolSelectorModel
+		selected, err := s.selectToolsWithModel(ctx, agent, message, availableTools, choice.Tool, metadata)
+		if err == nil && (len(selected) > 0 || choice.Mode != models.ToolChoiceRequired) {
+			metadata["tools"] = selected
+			return selected, metadata
+		}
+		if err == nil {
+			err = fmt.Errorf("no tool picked under a required tool choice")
+		}
+		s.logger.Warn("Tool selection failed, falling back to keywords", "agent_id", agent.ID, "error", err)
+		metadata = map[string]interface{}{
+			"selector":      models.ToolSelectorKeywords,
+			"fallback_from": models.ToolSelectorModel,
+			"error":         err.Error(),
+		}
+	}
+
+	selected := s.promptService.RelevantTools(message, availableTools, policy.MaxTools, choice.Tool)
+	metadata["tools"] = selected
+	return selected, metadata
+}
+
+// selectToolsWithModel asks the agent's selector model for the tools of a
+// turn, recording the call's provider, model, usage and cost in metadata
+func (s *ChatService) selectToolsWithModel(ctx context.Context, agent *models.Agent, message string, availableTools []string, keep string, metadata map[string]interface{}) ([]string, error) {
+	policy := agent.ToolPrompt
+	selector := *agent
+	options := agent.Config
+	if policy.SelectorProvider != "" && policy.SelectorProvider != agent.Provider {
+		selector.Provider = policy.SelectorProvider
+		options = nil // the agent's options are for its own provider
+	}
+	if policy.SelectorModel != "" {
+		selector.Model = policy.SelectorModel
+	}
+	metadata["provider"] = selector.Provider
+	metadata["model"] = selector.Model
+
+	provider, exists := s.llmRegistry.Get(selector.Provider)
+	if !exists {
+		return nil, fmt.Errorf("%w: unsupported provider %s", ErrProviderUnavailable, selector.Provider)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, toolSelectionTimeout)
+	defer cancel()
+	start := time.Now()
+	response, err := provider.Chat(ctx, &llm.ChatRequest{
+		Model: selector.Model,
+		Messages: []llm.ChatMessage{
+			{Role: "system", Content: fmt.Sprintf(toolSelectionPrompt, policy.MaxTools, s.promptService.toolSummaries(availableTools))},
+			{Role: "user", Content: message},
+		},
+		Temperature: 0,
+		MaxTokens:   16*policy.MaxTools + 16,
+		Options:     options,
+	})
+	if err != nil {
+		return nil, err
+	}
+	metadata["latency_ms"] = time.Since(start).Milliseconds()
+	if response.Usage != nil {
+		metadata["usage"] = usageMetadata(response.Usage)
+	}
+	s.recordCost(metadata, &selector, response.Usage)
+
+	return pickedTools(response.Content, availableTools, policy.MaxTools, keep), nil
+}
+
+// pickedTools reads the tool names of a selector reply, up to limit and
+// besides keep, in the order the tools are offered
+func pickedTools(reply string, availableTools []string, limit int, keep string) []string {
+	offered := make(map[string]bool, len(availableTools))
+	for _, toolName := range availableTools {
+		offered[toolName] = true
+	}
+
+	selected := make(map[string]bool, limit)
+	if keep != "" {
+		selected[keep] = true
+	}
+	for _, line := range strings.Split(reply, "\n") {
+		if len(selected) >= limit {
+			break
+		}
+		name := strings.TrimLeft(strings.TrimSpace(line), "-*0123456789.) ")
+		name = strings.TrimRight(strings.Trim(name, "`'\""), ".,: ")
+		if offered[name] {
+			selected[name] = true
+		}
+	}
+
+	picked := make([]string, 0, len(selected))
+	for _, toolName := range availableTools {
+		if selected[toolName] {
+			picked = append(picked, toolName)
+		}
+	}
+	return picked
+}
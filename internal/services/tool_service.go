@@ -1,34 +1,92 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"agent-server/internal/credentials"
+	"agent-server/internal/egress"
+	"agent-server/internal/injection"
+	"agent-server/internal/jobs"
 	"agent-server/internal/models"
+	"agent-server/internal/pii"
+	"agent-server/internal/redact"
 	"agent-server/internal/storage"
+	"agent-server/internal/storage/blob"
 	"agent-server/internal/tools"
 	"agent-server/internal/tools/builtin"
 )
 
+// DefaultToolTimeout bounds tool executions without a configured timeout
+const DefaultToolTimeout = 60 * time.Second
+
 // ToolService handles tool execution and management
 type ToolService struct {
 	registry   *tools.Registry
 	executor   *tools.Executor
 	repository storage.Repository
 	logger     *slog.Logger
+
+	// Large tool outputs are offloaded to blob storage when configured
+	blobStore       blob.Store
+	blobThreshold   int
+	signedURLExpiry time.Duration
+
+	// captureHTTP records the outbound HTTP requests of tools in the
+	// execution log
+	captureHTTP bool
+
+	// redactor masks credentials before tool data is logged or persisted
+	redactor *redact.Redactor
+
+	// Calls of asyncTools run in the background job runner
+	jobRunner  *jobs.Runner
+	asyncTools map[string]bool
+
+	// Calls of approvalTools wait for a person's approval
+	approvalTools map[string]bool
+
+	// credentials are injected into tool input by agent tool presets
+	credentials map[string]string
+
+	// oauth2 hands out the access tokens of OAuth2 credentials
+	oauth2 *credentials.Manager
+
+	quotas ToolQuotas
+
+	// accounting prices tool calls and enforces spending budgets
+	accounting *Accounting
+
+	// piiScrubber removes personal data from the tool arguments and
+	// memories of agents with a data-handling policy
+	piiScrubber *pii.Scrubber
+
+	// policy disables tools across the deployment
+	policy toolPolicy
+
+	// injection guards the LLM against instructions planted in tool results
+	injection injectionDefense
 }
 
 // NewToolService creates a new tool service
 func NewToolService(repository storage.Repository, logger *slog.Logger) *ToolService {
 	registry := tools.NewRegistry()
-	executor := tools.NewExecutor(registry, 60*time.Second) // 60 second timeout
+	executor := tools.NewExecutor(registry, DefaultToolTimeout)
+	executor.SetLogger(logger)
 
 	// Register built-in tools
-	if err := builtin.RegisterBuiltinTools(registry, repository.Memory()); err != nil {
+	err := builtin.RegisterBuiltinTools(registry, repository.Memory())
+	if err == nil {
+		err = registry.Register(builtin.NewSessionVarsTool(repository.Session()))
+	}
+	if err != nil {
 		logger.Error("Failed to register built-in tools", "error", err)
 	} else {
 		logger.Info("Registered built-in tools", "count", registry.Count())
@@ -39,9 +97,108 @@ func NewToolService(repository storage.Repository, logger *slog.Logger) *ToolSer
 		executor:   executor,
 		repository: repository,
 		logger:     logger,
+		redactor:   redact.Default(),
+	}
+}
+
+// SetBlobStore enables offloading of tool outputs larger than threshold bytes
+// to the given store. The result returned to the LLM is replaced by a preview
+// and a signed download URL. The store also keeps the artifacts tools return.
+func (ts *ToolService) SetBlobStore(store blob.Store, threshold int, expiry time.Duration) {
+	ts.blobStore = store
+	ts.blobThreshold = threshold
+	ts.signedURLExpiry = expiry
+}
+
+// SetRedactor sets the redactor applied to tool arguments, results and HTTP
+// audit records before they are logged or persisted; nil disables redaction
+func (ts *ToolService) SetRedactor(r *redact.Redactor) {
+	ts.redactor = r
+}
+
+// SetHTTPCapture enables recording sanitized details of the outbound HTTP
+// requests made by tools (final URL, method, status, timing and redacted
+// headers) in the tool execution log
+func (ts *ToolService) SetHTTPCapture(enabled bool) {
+	ts.captureHTTP = enabled
+}
+
+// SetEgress routes the HTTP requests of registered tools according to the
+// egress proxy policy
+func (ts *ToolService) SetEgress(policy *egress.Policy) {
+	for _, name := range ts.registry.List() {
+		tool, _ := ts.registry.Get(name)
+		if setter, ok := tool.(tools.TransportSetter); ok {
+			setter.SetTransport(policy.Transport(name))
+		}
+	}
+}
+
+// SetRedirectPolicy sets how the HTTP requests of registered tools follow
+// redirects
+func (ts *ToolService) SetRedirectPolicy(policy tools.RedirectPolicy) {
+	for _, name := range ts.registry.List() {
+		tool, _ := ts.registry.Get(name)
+		if setter, ok := tool.(tools.RedirectPolicySetter); ok {
+			setter.SetRedirectPolicy(policy)
+		}
 	}
 }
 
+// SetMailer registers the send_email tool, which sends through sender to the
+// allowed recipients
+func (ts *ToolService) SetMailer(sender builtin.EmailSender, allowedRecipients []string) error {
+	if err := ts.registry.Register(builtin.NewSendEmailTool(sender, allowedRecipients)); err != nil {
+		return fmt.Errorf("failed to register send_email tool: %w", err)
+	}
+	return nil
+}
+
+// SetSandbox registers the run_code tool, which runs code with runner. Files
+// the code generates are kept in store, the session's sandbox, and linked by
+// URLs valid for urlExpiry.
+func (ts *ToolService) SetSandbox(runner builtin.CodeRunner, store blob.Store, urlExpiry time.Duration) error {
+	if err := ts.registry.Register(builtin.NewRunCodeTool(runner, store, urlExpiry)); err != nil {
+		return fmt.Errorf("failed to register run_code tool: %w", err)
+	}
+	return nil
+}
+
+// SetTimeouts configures the executor's timeouts: defaultTimeout applies to
+// tools without an entry in perTool, and max bounds per-call and per-session
+// overrides. Zero values keep the built-in default and leave overrides
+// unbounded.
+func (ts *ToolService) SetTimeouts(defaultTimeout, max time.Duration, perTool map[string]time.Duration) {
+	if defaultTimeout <= 0 {
+		defaultTimeout = DefaultToolTimeout
+	}
+	ts.executor.SetDefaultTimeout(defaultTimeout)
+	ts.executor.SetMaxTimeout(max)
+	for name, timeout := range perTool {
+		ts.executor.SetToolTimeout(name, timeout)
+	}
+}
+
+// SetRetryPolicies retries transient failures of the named tools. The
+// attempts made are recorded in the result metadata.
+func (ts *ToolService) SetRetryPolicies(policies map[string]tools.RetryPolicy) {
+	for name, policy := range policies {
+		ts.executor.SetRetryPolicy(name, policy)
+	}
+}
+
+// SetStrictOutput fails tool results that do not match their tool's output
+// schema, instead of flagging them in the result metadata
+func (ts *ToolService) SetStrictOutput(strict bool) {
+	ts.executor.SetStrictOutput(strict)
+}
+
+// SetAccounting records tool costs and stops tool calls once the budget is
+// spent
+func (ts *ToolService) SetAccounting(accounting *Accounting) {
+	ts.accounting = accounting
+}
+
 // GetRegistry returns the tool registry
 func (ts *ToolService) GetRegistry() *tools.Registry {
 	return ts.registry
@@ -54,6 +211,7 @@ func (ts *ToolService) GetExecutor() *tools.Executor {
 
 // ListTools returns information about available tools
 func (ts *ToolService) ListTools(ctx context.Context) (*models.ToolsListResponse, error) {
+	ts.refreshToolStates(ctx)
 	toolNames := ts.registry.List()
 	toolInfos := make([]models.ToolInfo, 0, len(toolNames))
 
@@ -93,6 +251,8 @@ func (ts *ToolService) ListTools(ctx context.Context) (*models.ToolsListResponse
 			Available:   tool.IsAvailable(ctx),
 			Category:    inferToolCategory(schema.Name),
 			Examples:    examples,
+
+			OutputSchema: schema.Output,
 		})
 	}
 
@@ -104,34 +264,33 @@ func (ts *ToolService) ListTools(ctx context.Context) (*models.ToolsListResponse
 
 // TestTool tests a tool with given parameters
 func (ts *ToolService) TestTool(ctx context.Context, req *models.ToolTestRequest) (*models.ToolTestResponse, error) {
-	timeout := 30 * time.Second
-	if req.Timeout != nil {
-		timeout = time.Duration(*req.Timeout) * time.Second
+	// A requested timeout overrides the tool's default, up to the maximum
+	var opts tools.ExecuteOptions
+	if req.Timeout != nil && *req.Timeout > 0 {
+		opts.Timeout = time.Duration(*req.Timeout) * time.Second
 	}
 
-	// Create a temporary executor with custom timeout
-	testExecutor := tools.NewExecutor(ts.registry, timeout)
-
 	// Execute the tool
-	result := testExecutor.Execute(ctx, req.ToolName, "test-session", req.Arguments)
+	result := ts.executor.ExecuteWithOptions(ctx, req.ToolName, "test-session", req.Arguments, opts)
 
 	response := &models.ToolTestResponse{
-		Success:  result.Success,
-		Result:   result.Data,
-		Error:    result.Error,
-		Duration: result.Duration.Milliseconds(),
+		Success:   result.Success,
+		Result:    result.Data,
+		Error:     result.Error,
+		ErrorCode: result.ErrorCode,
+		Duration:  result.Duration.Milliseconds(),
+
+		OutputErrors: outputErrors(result),
 	}
 
 	// Add validation errors if any
 	if !result.Success && result.ErrorCode == "VALIDATION_ERROR" {
-		// Parse validation errors from the error message
-		// This is a simplified approach - in production, you might want more structured validation
-		response.Validation = []models.ValidationError{
-			{
-				Parameter: "unknown",
-				Message:   result.Error,
-			},
+		validation := models.ValidationError{Message: result.Error}
+		if parameter, ok := result.Metadata["parameter"].(string); ok {
+			validation.Parameter = parameter
+			validation.Message, _ = result.Metadata["reason"].(string)
 		}
+		response.Validation = []models.ValidationError{validation}
 	}
 
 	return response, nil
@@ -140,13 +299,20 @@ func (ts *ToolService) TestTool(ctx context.Context, req *models.ToolTestRequest
 // ExecuteToolCalls executes multiple tool calls and returns results
 func (ts *ToolService) ExecuteToolCalls(ctx context.Context, sessionID string, toolCalls []models.LLMToolCall) ([]models.ToolCallResult, error) {
 	results := make([]models.ToolCallResult, len(toolCalls))
+	ts.refreshToolStates(ctx)
 
 	for i, toolCall := range toolCalls {
-		result := ts.executeSingleToolCall(ctx, sessionID, toolCall)
+		callCtx := ctx
+		var audit *tools.HTTPAudit
+		if ts.captureHTTP {
+			callCtx, audit = tools.WithHTTPAudit(ctx, ts.redactor)
+		}
+
+		result := ts.guardResult(ctx, sessionID, ts.executeSingleToolCall(callCtx, sessionID, toolCall))
 		results[i] = result
 
 		// Log the tool execution
-		if err := ts.logToolExecution(ctx, sessionID, toolCall, result); err != nil {
+		if err := ts.logToolExecution(ctx, sessionID, toolCall, result, audit); err != nil {
 			ts.logger.Error("Failed to log tool execution", 
 				"tool_name", toolCall.Function.Name,
 				"error", err)
@@ -163,12 +329,27 @@ func (ts *ToolService) executeSingleToolCall(ctx context.Context, sessionID stri
 		"session_id", sessionID,
 		"call_id", toolCall.ID)
 
+	// Tools disabled by policy are rejected before they are held or queued
+	if ts.registry.IsDisabled(toolCall.Function.Name) {
+		ts.logger.Warn("Tool call rejected by policy",
+			"tool_name", toolCall.Function.Name,
+			"session_id", sessionID)
+		result := tools.DisabledResult(toolCall.Function.Name)
+		return models.ToolCallResult{
+			ID:        toolCall.ID,
+			ToolName:  toolCall.Function.Name,
+			Success:   false,
+			Error:     result.Error,
+			ErrorCode: result.ErrorCode,
+		}
+	}
+
 	// Parse arguments
 	var arguments map[string]interface{}
 	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &arguments); err != nil {
 		ts.logger.Error("Failed to parse tool arguments",
 			"tool_name", toolCall.Function.Name,
-			"arguments", toolCall.Function.Arguments,
+			"arguments", ts.redactor.JSON(toolCall.Function.Arguments),
 			"error", err)
 		
 		return models.ToolCallResult{
@@ -180,8 +361,8 @@ func (ts *ToolService) executeSingleToolCall(ctx context.Context, sessionID stri
 		}
 	}
 
-	// Get agent ID from session
-	agentID, err := ts.getAgentIDFromSession(ctx, sessionID)
+	// The session provides the agent ID and may override the tool timeout
+	session, err := ts.getSession(ctx, sessionID)
 	if err != nil {
 		return models.ToolCallResult{
 			ID:       toolCall.ID,
@@ -192,28 +373,160 @@ func (ts *ToolService) executeSingleToolCall(ctx context.Context, sessionID stri
 		}
 	}
 
+	if reason := ts.checkQuota(ctx, session, toolCall.Function.Name); reason != "" {
+		ts.logger.Warn("Tool call rejected by quota",
+			"tool_name", toolCall.Function.Name,
+			"session_id", sessionID,
+			"agent_id", session.AgentID)
+		return models.ToolCallResult{
+			ID:        toolCall.ID,
+			ToolName:  toolCall.Function.Name,
+			Success:   false,
+			Error:     reason,
+			ErrorCode: QuotaExceededErrorCode,
+		}
+	}
+
+	if err := ts.accounting.CheckBudget(ctx, session); errors.Is(err, ErrBudgetExceeded) {
+		return models.ToolCallResult{
+			ID:        toolCall.ID,
+			ToolName:  toolCall.Function.Name,
+			Success:   false,
+			Error:     fmt.Sprintf("Spending budget exhausted: %v. Answer with the information you have.", err),
+			ErrorCode: BudgetExceededErrorCode,
+		}
+	} else if err != nil {
+		// A budget that cannot be read does not block the agent
+		ts.logger.Error("Failed to check budget",
+			"tool_name", toolCall.Function.Name,
+			"session_id", sessionID,
+			"error", err)
+	}
+
+	// Calls that need approval hand back an execution ID to poll; they run
+	// once a person approves them
+	if ts.needsApproval(&session.Agent, toolCall.Function.Name, arguments) {
+		return ts.holdForApproval(ctx, session, toolCall, arguments)
+	}
+
+	// Long-running tools hand back an execution ID to poll; they apply the
+	// agent's presets when they run
+	if ts.isAsync(toolCall.Function.Name) {
+		return ts.startAsyncExecution(ctx, session, toolCall, arguments)
+	}
+
+	// The agent's presets complete the input without passing through the LLM
+	input, err := ts.applyPresets(ctx, &session.Agent, toolCall.Function.Name, arguments)
+	if err != nil {
+		return models.ToolCallResult{
+			ID:       toolCall.ID,
+			ToolName: toolCall.Function.Name,
+			Success:  false,
+			Error:    fmt.Sprintf("Failed to apply tool presets: %v", err),
+			Duration: 0,
+		}
+	}
+
+	input = ts.scrubMemoryInput(ctx, &session.Agent, toolCall.Function.Name, input)
+	runCtx := ts.withRecallHints(ctx, session, toolCall.Function.Name, input)
+
 	// Execute the tool with proper context
 	start := time.Now()
-	result := ts.executeToolWithContext(ctx, toolCall.Function.Name, sessionID, agentID, arguments)
+	result := ts.executeToolWithContext(runCtx, toolCall.Function.Name, sessionID, session.AgentID, session.ToolConfig.Timeout(), input)
 	duration := time.Since(start)
 
 	return models.ToolCallResult{
 		ID:       toolCall.ID,
 		ToolName: toolCall.Function.Name,
 		Success:  result.Success,
-		Result:   result.Data,
-		Error:    result.Error,
-		Duration: duration.Milliseconds(),
+		Result:    ts.offloadLargeOutput(ctx, sessionID, toolCall, result.Data),
+		Error:     result.Error,
+		Duration:  duration.Milliseconds(),
+		ErrorCode: result.ErrorCode,
+		Cost:      ts.accounting.ToolCost(toolCall.Function.Name, result),
+		Artifacts: ts.storeArtifacts(ctx, sessionID, toolCall, result.Artifacts),
+
+		OutputErrors: outputErrors(result),
+	}
+}
+
+// outputErrors returns the output schema violations the executor flagged
+// on a result
+func outputErrors(result *tools.Result) []models.ValidationError {
+	errs, _ := result.Metadata["output_schema_errors"].([]tools.JSONSchemaError)
+	if len(errs) == 0 {
+		return nil
+	}
+	converted := make([]models.ValidationError, len(errs))
+	for i, err := range errs {
+		converted[i] = models.ValidationError{Parameter: err.Path, Message: err.Message}
+	}
+	return converted
+}
+
+// offloadLargeOutput stores tool output above the configured threshold in blob
+// storage and returns a compact reference in its place
+func (ts *ToolService) offloadLargeOutput(ctx context.Context, sessionID string, toolCall models.LLMToolCall, data interface{}) interface{} {
+	if ts.blobStore == nil || ts.blobThreshold <= 0 || data == nil {
+		return data
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil || len(payload) <= ts.blobThreshold {
+		return data
+	}
+
+	callID := toolCall.ID
+	if callID == "" {
+		callID = fmt.Sprintf("%s-%d", toolCall.Function.Name, time.Now().UnixNano())
+	}
+	key := fmt.Sprintf("tool-outputs/%s/%s.json", sessionID, callID)
+
+	if err := ts.blobStore.Put(ctx, key, bytes.NewReader(payload), int64(len(payload)), "application/json"); err != nil {
+		ts.logger.Error("Failed to offload tool output",
+			"tool_name", toolCall.Function.Name,
+			"key", key,
+			"error", err)
+		return data
+	}
+
+	downloadURL, err := ts.blobStore.SignedURL(ctx, key, ts.signedURLExpiry)
+	if err != nil {
+		ts.logger.Warn("Failed to sign tool output URL", "key", key, "error", err)
+	}
+
+	// The preview ends on a rune boundary, so that it stays valid UTF-8
+	previewLen := 1024
+	if previewLen >= len(payload) {
+		previewLen = len(payload)
+	} else {
+		for previewLen > 0 && !utf8.RuneStart(payload[previewLen]) {
+			previewLen--
+		}
+	}
+
+	ts.logger.Info("Offloaded large tool output",
+		"tool_name", toolCall.Function.Name,
+		"key", key,
+		"size", len(payload))
+
+	return map[string]interface{}{
+		"truncated":    true,
+		"size_bytes":   len(payload),
+		"preview":      string(payload[:previewLen]),
+		"blob_key":     key,
+		"download_url": downloadURL,
 	}
 }
 
 // logToolExecution logs tool execution to the database
-func (ts *ToolService) logToolExecution(ctx context.Context, sessionID string, toolCall models.LLMToolCall, result models.ToolCallResult) error {
+func (ts *ToolService) logToolExecution(ctx context.Context, sessionID string, toolCall models.LLMToolCall, result models.ToolCallResult, audit *tools.HTTPAudit) error {
 	// Parse arguments
 	var arguments map[string]interface{}
 	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &arguments); err != nil {
 		arguments = map[string]interface{}{"raw": toolCall.Function.Arguments}
 	}
+	arguments = ts.redactor.Map(ts.scrubArguments(ctx, ts.agentForSession(ctx, sessionID), arguments))
 
 	// Create tool execution log
 	log := &models.ToolExecutionLog{
@@ -222,18 +535,30 @@ func (ts *ToolService) logToolExecution(ctx context.Context, sessionID string, t
 		ToolName:   toolCall.Function.Name,
 		Arguments:  models.JSON(arguments),
 		Success:    result.Success,
-		Error:      result.Error,
+		Error:      ts.redactor.String(result.Error),
+		ErrorCode:  result.ErrorCode,
 		Duration:   result.Duration,
+		Cost:       result.Cost,
 		ExecutedAt: time.Now(),
+		Injection:  result.Injection,
+		Artifacts:  result.Artifacts,
 	}
 
 	if result.Result != nil {
-		resultJSON := models.JSON(map[string]interface{}{"data": result.Result})
+		resultJSON := models.JSON(map[string]interface{}{"data": ts.redactor.Value(result.Result)})
 		log.Result = &resultJSON
 	}
 
-	// Save to database (this would require extending the repository interface)
-	// For now, just log it
+	if audit != nil {
+		if exchanges := audit.Exchanges(); len(exchanges) > 0 {
+			log.Audit = &models.JSON{"http_requests": exchanges}
+		}
+	}
+
+	if err := ts.repository.ToolExecutionLog().Create(ctx, log); err != nil {
+		return fmt.Errorf("failed to save tool execution log: %w", err)
+	}
+
 	ts.logger.Info("Tool execution logged",
 		"tool_name", toolCall.Function.Name,
 		"success", result.Success,
@@ -242,9 +567,40 @@ func (ts *ToolService) logToolExecution(ctx context.Context, sessionID string, t
 	return nil
 }
 
+// ListExecutionLogs returns a page of a session's tool execution logs
+func (ts *ToolService) ListExecutionLogs(ctx context.Context, sessionID string, page, pageSize int) (*models.ToolExecutionLogList, error) {
+	session, err := ts.repository.Session().GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
+
+	logs, total, err := ts.repository.ToolExecutionLog().ListBySessionID(ctx, sessionID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tool execution logs: %w", err)
+	}
+
+	return &models.ToolExecutionLogList{
+		Logs:       logs,
+		TotalCount: total,
+		Page:       page,
+		PageSize:   pageSize,
+		HasMore:    int64(page*pageSize) < total,
+	}, nil
+}
+
 // GetToolDefinitions returns tool definitions for LLM providers
 func (ts *ToolService) GetToolDefinitions(ctx context.Context, toolNames []string) ([]models.ToolDefinition, error) {
+	return ts.GetAgentToolDefinitions(ctx, nil, toolNames)
+}
+
+// GetAgentToolDefinitions returns tool definitions for LLM providers without
+// the parameters the agent presets
+func (ts *ToolService) GetAgentToolDefinitions(ctx context.Context, agent *models.Agent, toolNames []string) ([]models.ToolDefinition, error) {
 	var definitions []models.ToolDefinition
+	ts.refreshToolStates(ctx)
 
 	// If no tool names specified, get all available tools
 	if len(toolNames) == 0 {
@@ -263,6 +619,9 @@ func (ts *ToolService) GetToolDefinitions(ctx context.Context, toolNames []strin
 		}
 
 		schema := tool.Schema()
+		if agent != nil {
+			schema = tools.OmitPresetParameters(schema, agent.ToolPresets(name))
+		}
 		
 		// Convert tool schema to LLM tool definition format
 		definition := models.ToolDefinition{
@@ -304,7 +663,23 @@ func (ts *ToolService) CreateToolResultMessages(results []models.ToolCallResult)
 		}
 
 		if result.Success {
-			content["result"] = result.Result
+			content["result"] = ts.frameResult(result.ToolName, result.Result)
+			if ts.injection.frame && result.Result != nil {
+				content["notice"] = injection.Notice
+			}
+			var warnings []string
+			if len(result.OutputErrors) > 0 {
+				warnings = append(warnings, "the result does not have the format the tool promises; check it before relying on it")
+			}
+			if result.Injection.Detected() {
+				warnings = append(warnings, injectionWarning)
+			}
+			if len(warnings) > 0 {
+				content["warning"] = strings.Join(warnings, "; ")
+			}
+			if len(result.Artifacts) > 0 {
+				content["artifacts"] = result.Artifacts
+			}
 		} else {
 			content["error"] = result.Error
 		}
@@ -392,20 +767,26 @@ func convertSchemaToJSONSchema(schema tools.Schema) map[string]interface{} {
 	return jsonSchema
 }
 
-// getAgentIDFromSession retrieves the agent ID associated with a session
-func (ts *ToolService) getAgentIDFromSession(ctx context.Context, sessionID string) (string, error) {
+// getSession retrieves the session a tool call belongs to
+func (ts *ToolService) getSession(ctx context.Context, sessionID string) (*models.ChatSession, error) {
 	session, err := ts.repository.Session().GetByID(ctx, sessionID)
 	if err != nil {
-		return "", fmt.Errorf("failed to get session: %w", err)
+		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
 	if session == nil {
-		return "", fmt.Errorf("session not found")
+		return nil, fmt.Errorf("session not found")
 	}
-	return session.AgentID, nil
+	return session, nil
 }
 
-// executeToolWithContext executes a tool with proper execution context
-func (ts *ToolService) executeToolWithContext(ctx context.Context, toolName, sessionID, agentID string, arguments map[string]interface{}) *tools.Result {
+// executeToolWithContext executes a tool through the executor, which bounds
+// it by the tool's timeout or the given override
+func (ts *ToolService) executeToolWithContext(ctx context.Context, toolName, sessionID, agentID string, timeout time.Duration, arguments map[string]interface{}) *tools.Result {
+	// The registry hides disabled tools; report them as disabled, not missing
+	if ts.registry.IsDisabled(toolName) {
+		return tools.DisabledResult(toolName)
+	}
+
 	// Get the tool from registry
 	tool, exists := ts.registry.Get(toolName)
 	if !exists {
@@ -417,16 +798,9 @@ func (ts *ToolService) executeToolWithContext(ctx context.Context, toolName, ses
 		return tools.ErrorResult("TOOL_UNAVAILABLE", fmt.Sprintf("Tool '%s' is not available", toolName))
 	}
 
-	// Create execution context
-	execCtx := tools.ExecutionContext{
-		Context:   ctx,
-		SessionID: sessionID,
+	return ts.executor.ExecuteWithOptions(ctx, toolName, sessionID, arguments, tools.ExecuteOptions{
 		AgentID:   agentID,
-		RequestID: "req-" + sessionID + "-" + toolName,
-		Timeout:   60 * time.Second,
-		Metadata:  make(map[string]interface{}),
-	}
-
-	// Execute the tool
-	return tool.Execute(execCtx, arguments)
-}
\ No newline at end of file
+		Timeout:   timeout,
+		Artifacts: ts.artifactStore(sessionID),
+	})
+}
@@ -0,0 +1,93 @@
+// Package share signs the tokens of session share links. A token names one
+// session and an expiry and grants read-only access to that session's
+// transcript; it carries no other rights.
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, were not signed
+// with this signer's key or have expired
+var ErrInvalidToken = errors.New("invalid or expired share token")
+
+// Claims are the contents of a share token
+type Claims struct {
+	SessionID    string `json:"sid"`
+	ExpiresAt    int64  `json:"exp"`             // Unix seconds
+	IncludeTools bool   `json:"tools,omitempty"` // whether tool calls and results are shown
+}
+
+// Expires returns the time the token stops being valid
+func (c Claims) Expires() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// Signer creates and verifies share tokens with HMAC-SHA256
+type Signer struct {
+	key []byte
+	now func() time.Time
+}
+
+// NewSigner creates a signer. When key is empty a random key is generated,
+// so tokens do not survive a restart.
+func NewSigner(key string) (*Signer, error) {
+	signingKey := []byte(key)
+	if len(signingKey) == 0 {
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+	}
+	return &Signer{key: signingKey, now: time.Now}, nil
+}
+
+// Sign returns the token for claims: the base64 of their JSON form and of
+// its signature, joined by a dot
+func (s *Signer) Sign(claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.signature(encoded)), nil
+}
+
+// Verify returns the claims of a token signed by Sign that has not expired
+func (s *Signer) Verify(token string) (*Claims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	provided, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(provided, s.signature(encoded)) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.SessionID == "" {
+		return nil, ErrInvalidToken
+	}
+	if !s.now().Before(claims.Expires()) {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// signature returns the MAC of an encoded payload
+func (s *Signer) signature(encoded string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,41 @@
+package share
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner_SignAndVerify(t *testing.T) {
+	signer, err := NewSigner("secret")
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	signer.now = func() time.Time { return now }
+
+	token, err := signer.Sign(Claims{SessionID: "s1", ExpiresAt: now.Add(time.Hour).Unix(), IncludeTools: true})
+	require.NoError(t, err)
+
+	claims, err := signer.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "s1", claims.SessionID)
+	assert.True(t, claims.IncludeTools)
+
+	// Tampered payloads, other keys and expired tokens are rejected
+	payload, signature, _ := strings.Cut(token, ".")
+	_, err = signer.Verify(payload[:len(payload)-2] + "xx." + signature)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = signer.Verify(payload)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	other, err := NewSigner("")
+	require.NoError(t, err)
+	_, err = other.Verify(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	now = now.Add(2 * time.Hour)
+	_, err = signer.Verify(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
@@ -0,0 +1,233 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStore implements Store on the local filesystem
+type LocalStore struct {
+	root       string
+	baseURL    string
+	signingKey []byte
+}
+
+// NewLocalStore creates a filesystem-backed store rooted at dir. Signed URLs are
+// built from baseURL and verified by the blob download endpoint; when signingKey
+// is empty a random key is generated, so URLs do not survive a restart.
+func NewLocalStore(dir, baseURL, signingKey string) (*LocalStore, error) {
+	if dir == "" {
+		dir = "./data/blobs"
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	key := []byte(signingKey)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+	}
+
+	return &LocalStore{
+		root:       dir,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		signingKey: key,
+	}, nil
+}
+
+// Name returns the backend name
+func (s *LocalStore) Name() string {
+	return "local"
+}
+
+// Put writes the object to disk atomically
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	filePath, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return fmt.Errorf("failed to store object: %w", err)
+	}
+
+	if contentType != "" {
+		metaPath, _ := s.metaPath(key)
+		if err := os.MkdirAll(filepath.Dir(metaPath), 0755); err == nil {
+			_ = os.WriteFile(metaPath, []byte(contentType), 0644)
+		}
+	}
+
+	return nil
+}
+
+// Get opens the object file for reading
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filePath, _ := s.objectPath(key)
+	f, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+
+	return f, info, nil
+}
+
+// Stat returns object metadata
+func (s *LocalStore) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	filePath, err := s.objectPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	cleaned, _ := CleanKey(key)
+	info := &ObjectInfo{
+		Key:        cleaned,
+		Size:       fi.Size(),
+		ModifiedAt: fi.ModTime(),
+	}
+
+	metaPath, _ := s.metaPath(key)
+	if data, err := os.ReadFile(metaPath); err == nil {
+		info.ContentType = string(data)
+	} else {
+		info.ContentType = mime.TypeByExtension(path.Ext(cleaned))
+	}
+	if info.ContentType == "" {
+		info.ContentType = "application/octet-stream"
+	}
+
+	return info, nil
+}
+
+// Delete removes the object and its metadata
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	filePath, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	metaPath, _ := s.metaPath(key)
+	_ = os.Remove(metaPath)
+
+	return nil
+}
+
+// SignedURL returns a URL served by the blob download endpoint
+func (s *LocalStore) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	expires := time.Now().Add(expiry).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", s.sign(cleaned, expires))
+
+	return s.baseURL + "/" + (&url.URL{Path: cleaned}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+// VerifySignature checks a signature produced by SignedURL
+func (s *LocalStore) VerifySignature(key, expires, signature string) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return ErrInvalidSignature
+	}
+
+	expected := s.sign(cleaned, exp)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// sign computes the HMAC signature for a key and expiry
+func (s *LocalStore) sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(key))
+	mac.Write([]byte("\n"))
+	mac.Write([]byte(strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// objectPath maps an object key to a path below the store root
+func (s *LocalStore) objectPath(key string) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(cleaned, ".meta/") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
+
+// metaPath maps an object key to its content-type sidecar file
+func (s *LocalStore) metaPath(key string) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, ".meta", filepath.FromSlash(cleaned)), nil
+}
@@ -0,0 +1,69 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStore_PutGetDelete(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir(), "/api/v1/blobs", "secret")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	err = store.Put(ctx, "exports/report.json", strings.NewReader(`{"ok":true}`), 11, "application/json")
+	require.NoError(t, err)
+
+	reader, info, err := store.Get(ctx, "exports/report.json")
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"ok":true}`, string(data))
+	assert.Equal(t, "exports/report.json", info.Key)
+	assert.Equal(t, int64(11), info.Size)
+	assert.Equal(t, "application/json", info.ContentType)
+
+	require.NoError(t, store.Delete(ctx, "exports/report.json"))
+	_, err = store.Stat(ctx, "exports/report.json")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Deleting a missing object is not an error
+	assert.NoError(t, store.Delete(ctx, "exports/report.json"))
+}
+
+func TestLocalStore_RejectsInvalidKeys(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir(), "", "secret")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	for _, key := range []string{"", "../escape", "a/../../escape", ".meta/x"} {
+		err := store.Put(ctx, key, strings.NewReader("x"), 1, "")
+		assert.ErrorIs(t, err, ErrInvalidKey, "key %q", key)
+	}
+}
+
+func TestLocalStore_SignedURL(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir(), "/api/v1/blobs/", "secret")
+	require.NoError(t, err)
+
+	signed, err := store.SignedURL(context.Background(), "media/a b.png", time.Minute)
+	require.NoError(t, err)
+
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/blobs/media/a b.png", u.Path)
+
+	expires := u.Query().Get("expires")
+	signature := u.Query().Get("signature")
+	assert.NoError(t, store.VerifySignature("media/a b.png", expires, signature))
+	assert.ErrorIs(t, store.VerifySignature("media/other.png", expires, signature), ErrInvalidSignature)
+	assert.ErrorIs(t, store.VerifySignature("media/a b.png", "1", signature), ErrInvalidSignature)
+}
@@ -2,17 +2,25 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"agent-server/internal/models"
 )
 
+// ErrSequenceConflict is returned when a message is created with a sequence
+// number that is not greater than the session's latest one
+var ErrSequenceConflict = errors.New("message sequence conflict")
+
 // AgentRepository defines the interface for agent storage operations
 type AgentRepository interface {
+	// Create stores the agent as given, so an agent with a false Enabled is
+	// created disabled
 	Create(ctx context.Context, agent *models.Agent) error
 	GetByID(ctx context.Context, id string) (*models.Agent, error)
 	Update(ctx context.Context, agent *models.Agent) error
 	Delete(ctx context.Context, id string) error
-	List(ctx context.Context, limit, offset int) ([]*models.Agent, int64, error)
+	List(ctx context.Context, filter *models.AgentFilter) ([]*models.Agent, int64, error)
 }
 
 // SessionRepository defines the interface for session storage operations
@@ -21,7 +29,19 @@ type SessionRepository interface {
 	GetByID(ctx context.Context, id string) (*models.ChatSession, error)
 	Update(ctx context.Context, session *models.ChatSession) error
 	Delete(ctx context.Context, id string) error
-	ListByAgentID(ctx context.Context, agentID string, limit, offset int) ([]*models.ChatSession, int64, error)
+	// List lists an agent's sessions that match the filter, most recently
+	// active first
+	List(ctx context.Context, filter *models.SessionFilter) ([]*models.ChatSession, int64, error)
+	// RecordActivity records a user or assistant message in the session's
+	// activity summary. unread adds one to the unread count, otherwise the
+	// count is reset.
+	RecordActivity(ctx context.Context, sessionID, role string, at time.Time, unread bool) error
+	// MarkRead resets the unread count
+	MarkRead(ctx context.Context, sessionID string, at time.Time) error
+	// SetLanguage records the language detected for the session
+	SetLanguage(ctx context.Context, sessionID, language string) error
+	// ListIdle returns active sessions not updated since the given time
+	ListIdle(ctx context.Context, before time.Time, limit int) ([]*models.ChatSession, error)
 }
 
 // MessageRepository defines the interface for message storage operations
@@ -31,6 +51,137 @@ type MessageRepository interface {
 	ListBySessionID(ctx context.Context, sessionID string, limit, offset int) ([]*models.Message, int64, error)
 	DeleteBySessionID(ctx context.Context, sessionID string) error
 	GetLastNMessages(ctx context.Context, sessionID string, n int) ([]*models.Message, error)
+
+	// ListAfter returns up to limit messages whose sequence is greater than
+	// after, in sequence order
+	ListAfter(ctx context.Context, sessionID string, after int64, limit int) ([]*models.Message, error)
+	// ListVisibleBySessionID and ListVisibleAfter are ListBySessionID and
+	// ListAfter without hidden messages, for transcripts
+	ListVisibleBySessionID(ctx context.Context, sessionID string, limit, offset int) ([]*models.Message, int64, error)
+	ListVisibleAfter(ctx context.Context, sessionID string, after int64, limit int) ([]*models.Message, error)
+	UpdateStatus(ctx context.Context, id, status string) error
+	UpdateTurnStatus(ctx context.Context, turnID, status string) error
+	ListByStatus(ctx context.Context, status string) ([]*models.Message, error)
+	LastAssistantByAgent(ctx context.Context, agentID string) (*models.Message, error)
+
+	// Compact deletes the session's messages up to sequence through, with
+	// their tool calls, and stores replacement in their place under that
+	// sequence
+	Compact(ctx context.Context, sessionID string, through int64, replacement *models.Message) error
+	// DeleteThrough deletes the session's messages up to sequence through,
+	// with their tool calls
+	DeleteThrough(ctx context.Context, sessionID string, through int64) error
+	// Restore stores an archived message under its own sequence, which may
+	// lie below the latest
+	Restore(ctx context.Context, message *models.Message) error
+}
+
+// ToolCallRepository defines the interface for persisted tool calls
+type ToolCallRepository interface {
+	Create(ctx context.Context, toolCall *models.ToolCall) error
+	ListByMessageID(ctx context.Context, messageID string) ([]*models.ToolCall, error)
+	ListBySessionID(ctx context.Context, sessionID string) ([]*models.ToolCall, error)
+	DeleteBySessionID(ctx context.Context, sessionID string) error
+}
+
+// ToolExecutionLogRepository defines the interface for the tool execution
+// audit log
+type ToolExecutionLogRepository interface {
+	Create(ctx context.Context, log *models.ToolExecutionLog) error
+	// ListBySessionID lists a session's tool executions, oldest first
+	ListBySessionID(ctx context.Context, sessionID string, limit, offset int) ([]*models.ToolExecutionLog, int64, error)
+	// CountCalls counts the distinct tool calls matching the filter; a
+	// background call logged when started and when finished counts once
+	CountCalls(ctx context.Context, filter models.ToolCallCountFilter) (int64, error)
+}
+
+// ToolExecutionRepository defines the interface for long-running tool
+// executions
+type ToolExecutionRepository interface {
+	Create(ctx context.Context, execution *models.ToolExecution) error
+	GetByID(ctx context.Context, id string) (*models.ToolExecution, error)
+	Update(ctx context.Context, execution *models.ToolExecution) error
+}
+
+// ArchiveRepository defines the interface for message archive storage
+type ArchiveRepository interface {
+	Create(ctx context.Context, archive *models.MessageArchive) error
+	GetByID(ctx context.Context, id string) (*models.MessageArchive, error)
+	ListBySessionID(ctx context.Context, sessionID string) ([]*models.MessageArchive, error)
+	Delete(ctx context.Context, id string) error
+	DeleteBySessionID(ctx context.Context, sessionID string) error
+}
+
+// JobRepository defines the interface for the job outbox
+type JobRepository interface {
+	Create(ctx context.Context, job *models.Job) error
+	GetByID(ctx context.Context, id string) (*models.Job, error)
+	List(ctx context.Context, status string, limit, offset int) ([]*models.Job, int64, error)
+
+	// ClaimNext atomically marks the oldest due job as running and returns it,
+	// or nil when no job is due
+	ClaimNext(ctx context.Context, now time.Time) (*models.Job, error)
+	MarkSucceeded(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id string, errMsg string, retryAt time.Time) error
+	MarkDead(ctx context.Context, id string, errMsg string) error
+
+	// Requeue resets a failed or dead job so it runs again immediately
+	Requeue(ctx context.Context, id string) error
+
+	// ReleaseStale returns jobs stuck in running since before the given time
+	// (e.g. after a crash) to the queue
+	ReleaseStale(ctx context.Context, lockedBefore time.Time) (int64, error)
+}
+
+// ChannelBindingRepository maps conversations on external chat platforms to
+// sessions
+type ChannelBindingRepository interface {
+	// Get returns the binding of a platform conversation, or nil when there is
+	// none
+	Get(ctx context.Context, platform, externalID string) (*models.ChannelBinding, error)
+	// Save creates the binding or points an existing one at another session
+	Save(ctx context.Context, binding *models.ChannelBinding) error
+	DeleteBySessionID(ctx context.Context, sessionID string) error
+}
+
+// ContextTraceRepository stores how the context of each turn was built
+type ContextTraceRepository interface {
+	// Save creates the trace of a turn or replaces it
+	Save(ctx context.Context, trace *models.ContextTrace) error
+	// GetByTurnID returns the trace of a turn, or nil when there is none
+	GetByTurnID(ctx context.Context, turnID string) (*models.ContextTrace, error)
+	DeleteBySessionID(ctx context.Context, sessionID string) error
+}
+
+// FeatureFlagRepository stores feature flags set at runtime
+type FeatureFlagRepository interface {
+	List(ctx context.Context) ([]*models.FeatureFlag, error)
+	// Save creates the flag or replaces it
+	Save(ctx context.Context, flag *models.FeatureFlag) error
+	Delete(ctx context.Context, name string) error
+}
+
+// ToolStateRepository stores the tool states set at runtime
+type ToolStateRepository interface {
+	List(ctx context.Context) ([]*models.ToolState, error)
+	// Save creates the state or replaces it
+	Save(ctx context.Context, state *models.ToolState) error
+	Delete(ctx context.Context, name string) error
+}
+
+// StatsRepository runs aggregate queries over persisted usage data
+type StatsRepository interface {
+	// Totals counts entities; sessions updated since activeSince count as active
+	Totals(ctx context.Context, activeSince time.Time) (*models.StatsTotals, error)
+	ChatsPerDay(ctx context.Context, since time.Time) ([]models.DailyChatStats, error)
+	TokensByProvider(ctx context.Context, since time.Time) ([]models.ProviderTokenStats, error)
+	ToolUsage(ctx context.Context, since time.Time) ([]models.ToolUsageStats, error)
+	ToolDurations(ctx context.Context, since time.Time) ([]models.ToolDuration, error)
+	ChatLatencies(ctx context.Context, since time.Time) ([]int64, error)
+	// Costs sums token usage and the priced cost of LLM replies and tool calls
+	Costs(ctx context.Context, filter models.CostFilter) (*models.CostSummary, error)
+	// PromptTokens sums the prompt breakdowns of LLM replies
+	PromptTokens(ctx context.Context, filter models.CostFilter) (*models.PromptTokenStats, error)
 }
 
 // Repository aggregates all repository interfaces
@@ -39,5 +190,25 @@ type Repository interface {
 	Session() SessionRepository
 	Message() MessageRepository
 	Memory() MemoryRepository
+	ToolCall() ToolCallRepository
+	ToolExecutionLog() ToolExecutionLogRepository
+	ToolExecution() ToolExecutionRepository
+	Job() JobRepository
+	Archive() ArchiveRepository
+	ChannelBinding() ChannelBindingRepository
+	ContextTrace() ContextTraceRepository
+	FeatureFlag() FeatureFlagRepository
+	ToolState() ToolStateRepository
+	Stats() StatsRepository
+
+	// WithTx runs fn against a repository bound to a single transaction. The
+	// transaction commits when fn returns nil and rolls back otherwise.
+	WithTx(ctx context.Context, fn func(tx Repository) error) error
+
+	// Ping verifies the database connection is usable
+	Ping(ctx context.Context) error
+	// CheckSchema verifies that all tables created by migrations exist
+	CheckSchema(ctx context.Context) error
+
 	Close() error
 }
\ No newline at end of file
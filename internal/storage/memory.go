@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"time"
 	"agent-server/internal/models"
 )
 
@@ -28,6 +29,10 @@ type MemoryRepository interface {
 	// ListByTopic retrieves memories for a specific topic
 	ListByTopic(ctx context.Context, agentID, topic string, limit, offset int) ([]*models.Memory, error)
 	
+	// RecordAccess counts a recall of the memories and sets their last
+	// access time, leaving updated_at alone
+	RecordAccess(ctx context.Context, ids []string, at time.Time) error
+	
 	// GetStats returns memory usage statistics for an agent
 	GetStats(ctx context.Context, agentID string) (*models.MemoryStats, error)
 	
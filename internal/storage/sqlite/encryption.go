@@ -0,0 +1,302 @@
+package sqlite
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"agent-server/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// encryptedPrefix marks encrypted values; values without it are read as
+// plain text, so databases written before encryption was enabled stay
+// readable. Writes encrypt every value, including ones that already carry
+// the prefix, so user content cannot pose as ciphertext.
+const encryptedPrefix = "enc:v1:"
+
+// encryptedJSONKey holds the ciphertext of an encrypted JSON column, which
+// keeps the column valid JSON
+const encryptedJSONKey = "$encrypted"
+
+// keyIDSize is the length of the key fingerprint stored with each value
+const keyIDSize = 4
+
+// encryptedFields lists the fields encrypted at rest per model. Columns that
+// queries filter on, such as memory topics, stay plain.
+var encryptedFields = map[reflect.Type][]string{
+	reflect.TypeOf(models.Message{}):          {"Content"},
+	reflect.TypeOf(models.Memory{}):           {"Content"},
+	reflect.TypeOf(models.ToolCall{}):         {"Arguments", "Result"},
+	reflect.TypeOf(models.ToolExecutionLog{}): {"Arguments", "Result"},
+	reflect.TypeOf(models.ToolExecution{}):    {"Arguments", "Result"},
+	reflect.TypeOf(models.MessageArchive{}):   {"Data"},
+}
+
+// Cipher encrypts values with AES-256-GCM. Values are encrypted with the
+// first key; the others only decrypt, so keys can be rotated.
+type Cipher struct {
+	keys map[string]cipher.AEAD
+	ids  []string
+}
+
+// NewCipher creates a cipher from 32-byte keys, the current key first
+func NewCipher(keys ...[]byte) (*Cipher, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("encryption requires a key")
+	}
+	c := &Cipher{keys: make(map[string]cipher.AEAD, len(keys))}
+	for i, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %d must be 32 bytes, got %d", i+1, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(key)
+		id := string(sum[:keyIDSize])
+		if _, exists := c.keys[id]; !exists {
+			c.keys[id] = aead
+			c.ids = append(c.ids, id)
+		}
+	}
+	return c, nil
+}
+
+// Encrypt returns the encrypted form of plaintext: the prefix followed by
+// the base64 of the key fingerprint, nonce and sealed data
+func (c *Cipher) Encrypt(plaintext []byte) (string, error) {
+	id := c.ids[0]
+	aead := c.keys[id]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := make([]byte, 0, keyIDSize+len(nonce)+len(plaintext)+aead.Overhead())
+	sealed = append(sealed, id...)
+	sealed = append(sealed, nonce...)
+	sealed = aead.Seal(sealed, nonce, plaintext, nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverses Encrypt. Values without the prefix are returned as they
+// are.
+func (c *Cipher) Decrypt(value string) ([]byte, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return []byte(value), nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(value[len(encryptedPrefix):])
+	if err != nil {
+		return nil, fmt.Errorf("decoding encrypted value: %w", err)
+	}
+	if len(sealed) < keyIDSize {
+		return nil, errors.New("encrypted value is truncated")
+	}
+	aead, ok := c.keys[string(sealed[:keyIDSize])]
+	if !ok {
+		return nil, errors.New("value was encrypted with an unknown key")
+	}
+	sealed = sealed[keyIDSize:]
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted value is truncated")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting value: %w", err)
+	}
+	return plaintext, nil
+}
+
+// register installs callbacks that encrypt the fields of encryptedFields
+// before they are written and decrypt them after writes and queries, so
+// callers only ever see plain values
+func (c *Cipher) register(db *gorm.DB) error {
+	encrypt := func(tx *gorm.DB) { c.apply(tx, c.encryptField) }
+	decrypt := func(tx *gorm.DB) { c.apply(tx, c.decryptField) }
+
+	if err := db.Callback().Create().Before("gorm:create").Register("sqlite:encrypt", encrypt); err != nil {
+		return err
+	}
+	if err := db.Callback().Create().After("gorm:create").Register("sqlite:decrypt", decrypt); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("sqlite:encrypt", encrypt); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("sqlite:decrypt", decrypt); err != nil {
+		return err
+	}
+	return db.Callback().Query().After("gorm:query").Register("sqlite:decrypt", decrypt)
+}
+
+// encryptionEnabled reports whether values are encrypted at rest in db, in
+// which case queries cannot match on the contents of encrypted columns
+func encryptionEnabled(db *gorm.DB) bool {
+	return db.Callback().Query().Get("sqlite:decrypt") != nil
+}
+
+// apply runs fn on the encrypted fields of the models a statement reads or
+// writes
+func (c *Cipher) apply(tx *gorm.DB, fn func(reflect.Value) error) {
+	if tx.Statement.Schema == nil || !tx.Statement.ReflectValue.IsValid() {
+		return
+	}
+	fields, ok := encryptedFields[tx.Statement.Schema.ModelType]
+	if !ok {
+		return
+	}
+
+	var each func(v reflect.Value)
+	each = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			if !v.IsNil() {
+				each(v.Elem())
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				each(v.Index(i))
+			}
+		case reflect.Struct:
+			if !v.CanAddr() || v.Type() != tx.Statement.Schema.ModelType {
+				return
+			}
+			for _, name := range fields {
+				if err := fn(v.FieldByName(name)); err != nil {
+					tx.AddError(fmt.Errorf("%s.%s: %w", v.Type().Name(), name, err))
+					return
+				}
+			}
+		}
+	}
+	each(tx.Statement.ReflectValue)
+}
+
+// encryptField replaces a plain field value with its encrypted form. Fields
+// hold plain values between callbacks, so values that look encrypted are
+// encrypted too.
+func (c *Cipher) encryptField(field reflect.Value) error {
+	switch value := field.Addr().Interface().(type) {
+	case *string:
+		if *value == "" {
+			return nil
+		}
+		encrypted, err := c.Encrypt([]byte(*value))
+		if err != nil {
+			return err
+		}
+		*value = encrypted
+	case *[]byte:
+		if len(*value) == 0 {
+			return nil
+		}
+		encrypted, err := c.Encrypt(*value)
+		if err != nil {
+			return err
+		}
+		*value = []byte(encrypted)
+	case *models.JSON:
+		return c.encryptJSON(value)
+	case **models.JSON:
+		if *value == nil {
+			return nil
+		}
+		// The pointer may be shared with the caller, so it is replaced
+		encrypted := **value
+		if err := c.encryptJSON(&encrypted); err != nil {
+			return err
+		}
+		*value = &encrypted
+	}
+	return nil
+}
+
+// decryptField replaces an encrypted field value with its plain form
+func (c *Cipher) decryptField(field reflect.Value) error {
+	switch value := field.Addr().Interface().(type) {
+	case *string:
+		plaintext, err := c.Decrypt(*value)
+		if err != nil {
+			return err
+		}
+		*value = string(plaintext)
+	case *[]byte:
+		if !bytes.HasPrefix(*value, []byte(encryptedPrefix)) {
+			return nil
+		}
+		plaintext, err := c.Decrypt(string(*value))
+		if err != nil {
+			return err
+		}
+		*value = plaintext
+	case *models.JSON:
+		return c.decryptJSON(value)
+	case **models.JSON:
+		if *value == nil {
+			return nil
+		}
+		decrypted := **value
+		if err := c.decryptJSON(&decrypted); err != nil {
+			return err
+		}
+		*value = &decrypted
+	}
+	return nil
+}
+
+// encryptJSON replaces a JSON document with one holding its ciphertext
+func (c *Cipher) encryptJSON(document *models.JSON) error {
+	if *document == nil {
+		return nil
+	}
+	data, err := json.Marshal(*document)
+	if err != nil {
+		return err
+	}
+	encrypted, err := c.Encrypt(data)
+	if err != nil {
+		return err
+	}
+	*document = models.JSON{encryptedJSONKey: encrypted}
+	return nil
+}
+
+// decryptJSON restores a document replaced by encryptJSON
+func (c *Cipher) decryptJSON(document *models.JSON) error {
+	if !isEncryptedJSON(*document) {
+		return nil
+	}
+	plaintext, err := c.Decrypt((*document)[encryptedJSONKey].(string))
+	if err != nil {
+		return err
+	}
+	var decrypted models.JSON
+	if err := json.Unmarshal(plaintext, &decrypted); err != nil {
+		return fmt.Errorf("decoding decrypted document: %w", err)
+	}
+	*document = decrypted
+	return nil
+}
+
+// isEncryptedJSON reports whether document holds a ciphertext
+func isEncryptedJSON(document models.JSON) bool {
+	if len(document) != 1 {
+		return false
+	}
+	value, ok := document[encryptedJSONKey].(string)
+	return ok && strings.HasPrefix(value, encryptedPrefix)
+}
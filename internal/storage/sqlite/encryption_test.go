@@ -0,0 +1,121 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCipher_RotatesKeys(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	old, err := NewCipher(oldKey)
+	require.NoError(t, err)
+	encrypted, err := old.Encrypt([]byte("hello"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, encryptedPrefix))
+
+	rotated, err := NewCipher(newKey, oldKey)
+	require.NoError(t, err)
+	plaintext, err := rotated.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(plaintext))
+
+	other, err := NewCipher(newKey)
+	require.NoError(t, err)
+	_, err = other.Decrypt(encrypted)
+	assert.Error(t, err)
+
+	// Plain values written before encryption are read unchanged
+	plaintext, err = other.Decrypt("plain text")
+	require.NoError(t, err)
+	assert.Equal(t, "plain text", string(plaintext))
+
+	_, err = NewCipher([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestRepository_EncryptsAtRest(t *testing.T) {
+	cipher, err := NewCipher(bytes.Repeat([]byte{7}, 32))
+	require.NoError(t, err)
+	opts := DefaultOptions()
+	opts.Cipher = cipher
+	r, err := NewRepositoryWithOptions(filepath.Join(t.TempDir(), "agents.db"), opts)
+	require.NoError(t, err)
+	defer r.Close()
+	repo := r.(*repository)
+	ctx := context.Background()
+
+	agent := &models.Agent{Name: "a", Provider: "ollama", Model: "llama3", SystemPrompt: "p"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	message := &models.Message{SessionID: session.ID, Role: "user", Content: "my secret plan"}
+	require.NoError(t, repo.Message().Create(ctx, message))
+	assert.Equal(t, "my secret plan", message.Content)
+
+	result := models.JSON{"data": "42"}
+	toolCall := &models.ToolCall{MessageID: message.ID, ToolName: "calculator", Arguments: models.JSON{"expression": "6 * 7"}, Result: &result}
+	require.NoError(t, repo.ToolCall().Create(ctx, toolCall))
+	assert.Equal(t, models.JSON{"data": "42"}, result)
+
+	memory := &models.Memory{AgentID: agent.ID, Topic: "plans", Content: "Launch on Friday", MemoryType: "fact", Importance: 5}
+	require.NoError(t, repo.Memory().Create(ctx, memory))
+
+	// The columns hold ciphertext
+	var rawContent, rawArguments, rawMemory string
+	require.NoError(t, repo.db.Raw("SELECT content FROM messages WHERE id = ?", message.ID).Scan(&rawContent).Error)
+	require.NoError(t, repo.db.Raw("SELECT arguments FROM tool_calls WHERE id = ?", toolCall.ID).Scan(&rawArguments).Error)
+	require.NoError(t, repo.db.Raw("SELECT content FROM memories WHERE id = ?", memory.ID).Scan(&rawMemory).Error)
+	assert.True(t, strings.HasPrefix(rawContent, encryptedPrefix))
+	assert.NotContains(t, rawArguments, "6 * 7")
+	assert.True(t, strings.HasPrefix(rawMemory, encryptedPrefix))
+
+	// Reads see plain values
+	saved, err := repo.Message().GetByID(ctx, message.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "my secret plan", saved.Content)
+	toolCalls, err := repo.ToolCall().ListByMessageID(ctx, message.ID)
+	require.NoError(t, err)
+	require.Len(t, toolCalls, 1)
+	assert.Equal(t, "6 * 7", toolCalls[0].Arguments["expression"])
+	assert.Equal(t, "42", (*toolCalls[0].Result)["data"])
+
+	// Rows written before encryption was enabled stay readable
+	require.NoError(t, repo.db.Exec("UPDATE messages SET content = ? WHERE id = ?", "legacy", message.ID).Error)
+	saved, err = repo.Message().GetByID(ctx, message.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", saved.Content)
+
+	// Plain values shaped like ciphertext are encrypted like any other
+	lookalike := &models.Message{SessionID: session.ID, Role: "user", Content: encryptedPrefix + "not really"}
+	require.NoError(t, repo.Message().Create(ctx, lookalike))
+	forged := models.JSON{encryptedJSONKey: encryptedPrefix + "AAAA"}
+	lookalikeCall := &models.ToolCall{MessageID: lookalike.ID, ToolName: "echo", Arguments: models.JSON{}, Result: &forged}
+	require.NoError(t, repo.ToolCall().Create(ctx, lookalikeCall))
+	require.NoError(t, repo.db.Raw("SELECT content FROM messages WHERE id = ?", lookalike.ID).Scan(&rawContent).Error)
+	assert.NotContains(t, rawContent, "not really")
+	saved, err = repo.Message().GetByID(ctx, lookalike.ID)
+	require.NoError(t, err)
+	assert.Equal(t, encryptedPrefix+"not really", saved.Content)
+	toolCalls, err = repo.ToolCall().ListByMessageID(ctx, lookalike.ID)
+	require.NoError(t, err)
+	require.Len(t, toolCalls, 1)
+	assert.Equal(t, forged, *toolCalls[0].Result)
+
+	// Memory search matches decrypted contents
+	query := "friday"
+	memories, err := repo.Memory().Search(ctx, &models.MemorySearchRequest{AgentID: agent.ID, Query: &query})
+	require.NoError(t, err)
+	require.Len(t, memories, 1)
+	assert.Equal(t, "Launch on Friday", memories[0].Content)
+}
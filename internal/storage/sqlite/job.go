@@ -0,0 +1,169 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+// jobRepository implements storage.JobRepository using GORM
+type jobRepository struct {
+	db *gorm.DB
+}
+
+// NewJobRepository creates a new job repository
+func NewJobRepository(db *gorm.DB) storage.JobRepository {
+	return &jobRepository{db: db}
+}
+
+func (r *jobRepository) Create(ctx context.Context, job *models.Job) error {
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+func (r *jobRepository) GetByID(ctx context.Context, id string) (*models.Job, error) {
+	var job models.Job
+	err := r.db.WithContext(ctx).First(&job, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *jobRepository) List(ctx context.Context, status string, limit, offset int) ([]*models.Job, int64, error) {
+	var jobs []*models.Job
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.Job{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Limit(limit).
+		Offset(offset).
+		Order("created_at DESC").
+		Find(&jobs).Error
+
+	return jobs, total, err
+}
+
+func (r *jobRepository) ClaimNext(ctx context.Context, now time.Time) (*models.Job, error) {
+	// Another worker may claim the same candidate first; the conditional update
+	// below only succeeds for one of them, so retry a few times on conflict.
+	for i := 0; i < 3; i++ {
+		var job models.Job
+		err := r.db.WithContext(ctx).
+			Where("status IN ? AND run_at <= ?", []string{models.JobStatusPending, models.JobStatusFailed}, now).
+			Order("run_at ASC").
+			First(&job).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, nil
+			}
+			return nil, err
+		}
+
+		result := r.db.WithContext(ctx).
+			Model(&models.Job{}).
+			Where("id = ? AND status = ?", job.ID, job.Status).
+			Updates(map[string]interface{}{
+				"status":    models.JobStatusRunning,
+				"attempts":  gorm.Expr("attempts + 1"),
+				"locked_at": now,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			job.Status = models.JobStatusRunning
+			job.Attempts++
+			job.LockedAt = &now
+			return &job, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *jobRepository) MarkSucceeded(ctx context.Context, id string) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).
+		Model(&models.Job{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":       models.JobStatusSucceeded,
+			"last_error":   "",
+			"locked_at":    nil,
+			"completed_at": now,
+		}).Error
+}
+
+func (r *jobRepository) MarkFailed(ctx context.Context, id string, errMsg string, retryAt time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.Job{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     models.JobStatusFailed,
+			"last_error": errMsg,
+			"locked_at":  nil,
+			"run_at":     retryAt,
+		}).Error
+}
+
+func (r *jobRepository) MarkDead(ctx context.Context, id string, errMsg string) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).
+		Model(&models.Job{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":       models.JobStatusDead,
+			"last_error":   errMsg,
+			"locked_at":    nil,
+			"completed_at": now,
+		}).Error
+}
+
+func (r *jobRepository) Requeue(ctx context.Context, id string) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.Job{}).
+		Where("id = ? AND status IN ?", id, []string{models.JobStatusFailed, models.JobStatusDead}).
+		Updates(map[string]interface{}{
+			"status":       models.JobStatusPending,
+			"attempts":     0,
+			"run_at":       time.Now(),
+			"locked_at":    nil,
+			"completed_at": nil,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("job %s is not failed or dead", id)
+	}
+	return nil
+}
+
+func (r *jobRepository) ReleaseStale(ctx context.Context, lockedBefore time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.Job{}).
+		Where("status = ? AND locked_at < ?", models.JobStatusRunning, lockedBefore).
+		Updates(map[string]interface{}{
+			"status":     models.JobStatusFailed,
+			"last_error": "worker lost while running job",
+			"locked_at":  nil,
+			"run_at":     time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
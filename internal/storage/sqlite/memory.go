@@ -97,7 +97,9 @@ func (r *memoryRepository) Search(ctx context.Context, req *models.MemorySearchR
 		query = query.Where("importance >= ?", *req.MinImportance)
 	}
 	
-	if req.Query != nil {
+	// Encrypted contents are matched once they have been read
+	matchContent := req.Query != nil && encryptionEnabled(r.db)
+	if req.Query != nil && !matchContent {
 		searchTerm := "%" + *req.Query + "%"
 		query = query.Where("content LIKE ? OR topic LIKE ?", searchTerm, searchTerm)
 	}
@@ -120,6 +122,14 @@ func (r *memoryRepository) Search(ctx context.Context, req *models.MemorySearchR
 	// Order by importance (descending) and created_at (descending)
 	query = query.Order("importance DESC, created_at DESC")
 	
+	if matchContent {
+		var memories []*models.Memory
+		if err := query.Find(&memories).Error; err != nil {
+			return nil, err
+		}
+		return paginate(matchingMemories(memories, *req.Query), req.Limit, req.Offset), nil
+	}
+
 	// Add pagination
 	if req.Limit != nil {
 		query = query.Limit(*req.Limit)
@@ -134,6 +144,38 @@ func (r *memoryRepository) Search(ctx context.Context, req *models.MemorySearchR
 	return memories, err
 }
 
+// matchingMemories keeps the memories whose content or topic contains
+// term, ignoring case like SQLite's LIKE
+func matchingMemories(memories []*models.Memory, term string) []*models.Memory {
+	term = strings.ToLower(term)
+	matching := memories[:0]
+	for _, memory := range memories {
+		if strings.Contains(strings.ToLower(memory.Content), term) || strings.Contains(strings.ToLower(memory.Topic), term) {
+			matching = append(matching, memory)
+		}
+	}
+	return matching
+}
+
+// paginate applies a limit and offset to memories read in full
+func paginate(memories []*models.Memory, limit, offset *int) []*models.Memory {
+	if limit == nil {
+		return memories
+	}
+	start := 0
+	if offset != nil && *offset > 0 {
+		start = *offset
+	}
+	if start >= len(memories) {
+		return nil
+	}
+	end := len(memories)
+	if *limit >= 0 && start+*limit < end {
+		end = start + *limit
+	}
+	return memories[start:end]
+}
+
 // ListByAgent retrieves all memories for an agent
 func (r *memoryRepository) ListByAgent(ctx context.Context, agentID string, limit, offset int) ([]*models.Memory, error) {
 	req := &models.MemorySearchRequest{
@@ -155,6 +197,20 @@ func (r *memoryRepository) ListByTopic(ctx context.Context, agentID, topic strin
 	return r.Search(ctx, req)
 }
 
+// RecordAccess counts a recall of the memories and sets their last access
+// time; the columns are written directly so updated_at is kept
+func (r *memoryRepository) RecordAccess(ctx context.Context, ids []string, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Model(&models.Memory{}).
+		Where("id IN ?", ids).
+		UpdateColumns(map[string]interface{}{
+			"access_count":     gorm.Expr("access_count + 1"),
+			"last_accessed_at": at,
+		}).Error
+}
+
 // GetStats returns memory usage statistics for an agent
 func (r *memoryRepository) GetStats(ctx context.Context, agentID string) (*models.MemoryStats, error) {
 	stats := &models.MemoryStats{
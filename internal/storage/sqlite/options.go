@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Options controls SQLite pragmas and connection pooling
+type Options struct {
+	JournalMode     string        // e.g. WAL, DELETE; empty keeps the driver default
+	BusyTimeout     time.Duration // how long a connection waits on a locked database
+	Synchronous     string        // OFF, NORMAL, FULL or EXTRA
+	ForeignKeys     bool
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	// SerializeWrites funnels all write statements through a single in-process
+	// lock so concurrent writers queue instead of failing with "database is locked"
+	SerializeWrites bool
+
+	// Cipher encrypts message contents, tool arguments and results, memories
+	// and archives at rest; nil stores them in plain text
+	Cipher *Cipher
+
+	// ReadOnly opens an existing database for inspection, without migrating
+	// it; journal and synchronous modes are left as they are
+	ReadOnly bool
+}
+
+// DefaultOptions returns options tuned for a concurrent API server
+func DefaultOptions() Options {
+	return Options{
+		JournalMode:     "WAL",
+		BusyTimeout:     5 * time.Second,
+		Synchronous:     "NORMAL",
+		MaxOpenConns:    8,
+		MaxIdleConns:    4,
+		ConnMaxLifetime: time.Hour,
+		SerializeWrites: true,
+	}
+}
+
+// isMemoryPath reports whether the path refers to an in-memory database
+func isMemoryPath(dbPath string) bool {
+	return dbPath == ":memory:" || strings.Contains(dbPath, "mode=memory")
+}
+
+// buildDSN appends driver pragma parameters to the database path
+func buildDSN(dbPath string, opts Options) string {
+	params := url.Values{}
+	if opts.ReadOnly {
+		// SQLite only reads the mode of URI file names
+		if !strings.HasPrefix(dbPath, "file:") {
+			dbPath = "file:" + dbPath
+		}
+		params.Set("mode", "ro")
+		opts.JournalMode = ""
+		opts.Synchronous = ""
+	}
+	if opts.JournalMode != "" {
+		params.Set("_journal_mode", strings.ToUpper(opts.JournalMode))
+	}
+	if opts.BusyTimeout > 0 {
+		params.Set("_busy_timeout", fmt.Sprintf("%d", opts.BusyTimeout.Milliseconds()))
+	}
+	if opts.Synchronous != "" {
+		params.Set("_synchronous", strings.ToUpper(opts.Synchronous))
+	}
+	if opts.ForeignKeys {
+		params.Set("_foreign_keys", "1")
+	}
+
+	if len(params) == 0 {
+		return dbPath
+	}
+
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+	return dbPath + separator + params.Encode()
+}
+
+// writeSerializer holds the process-wide writer lock for a database
+type writeSerializer struct {
+	mu sync.Mutex
+}
+
+const writeLockKey = "sqlite:write_lock_held"
+
+// register installs callbacks that hold the writer lock for each write
+// statement. Statements already running inside a transaction are skipped;
+// transactions take the lock for their whole duration instead.
+func (w *writeSerializer) register(db *gorm.DB) error {
+	acquire := func(tx *gorm.DB) {
+		if inTransaction(tx) {
+			return
+		}
+		w.mu.Lock()
+		tx.InstanceSet(writeLockKey, true)
+	}
+	release := func(tx *gorm.DB) {
+		if held, ok := tx.InstanceGet(writeLockKey); ok && held.(bool) {
+			tx.InstanceSet(writeLockKey, false)
+			w.mu.Unlock()
+		}
+	}
+
+	if err := db.Callback().Create().Before("gorm:begin_transaction").Register("sqlite:write_lock", acquire); err != nil {
+		return err
+	}
+	if err := db.Callback().Create().After("gorm:commit_or_rollback_transaction").Register("sqlite:write_unlock", release); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:begin_transaction").Register("sqlite:write_lock", acquire); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:commit_or_rollback_transaction").Register("sqlite:write_unlock", release); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:begin_transaction").Register("sqlite:write_lock", acquire); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().After("gorm:commit_or_rollback_transaction").Register("sqlite:write_unlock", release); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// inTransaction reports whether the statement runs on an open transaction
+func inTransaction(tx *gorm.DB) bool {
+	_, ok := tx.Statement.ConnPool.(gorm.TxCommitter)
+	return ok
+}
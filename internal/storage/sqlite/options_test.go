@@ -0,0 +1,64 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"agent-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildDSN(t *testing.T) {
+	dsn := buildDSN("./data/agents.db", Options{
+		JournalMode: "wal",
+		BusyTimeout: 2 * time.Second,
+		Synchronous: "normal",
+	})
+	assert.Equal(t, "./data/agents.db?_busy_timeout=2000&_journal_mode=WAL&_synchronous=NORMAL", dsn)
+
+	assert.Equal(t, ":memory:", buildDSN(":memory:", Options{}))
+	assert.Equal(t, "file:test.db?cache=shared&_foreign_keys=1", buildDSN("file:test.db?cache=shared", Options{ForeignKeys: true}))
+	assert.Equal(t, "file:test.db?mode=ro", buildDSN("test.db", Options{JournalMode: "wal", ReadOnly: true}))
+}
+
+func TestRepository_ConcurrentWrites(t *testing.T) {
+	repo, err := NewRepositoryWithOptions(filepath.Join(t.TempDir(), "agents.db"), DefaultOptions())
+	require.NoError(t, err)
+	defer repo.Close()
+
+	ctx := context.Background()
+	agent := &models.Agent{Name: "writer", Provider: "ollama", Model: "llama3"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- repo.Message().Create(ctx, &models.Message{
+				SessionID: session.ID,
+				Role:      "user",
+				Content:   fmt.Sprintf("message %d", i),
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	_, total, err := repo.Message().ListBySessionID(ctx, session.ID, 100, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), total)
+}
@@ -3,6 +3,8 @@ package sqlite
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"agent-server/internal/models"
 	"agent-server/internal/storage"
@@ -14,43 +16,219 @@ import (
 
 type repository struct {
 	db      *gorm.DB
+	writes  *writeSerializer
 	agent   storage.AgentRepository
 	session storage.SessionRepository
 	message storage.MessageRepository
 	memory  storage.MemoryRepository
+	tool    storage.ToolCallRepository
+	toolLog storage.ToolExecutionLogRepository
+	toolRun storage.ToolExecutionRepository
+	job     storage.JobRepository
+	archive storage.ArchiveRepository
+	binding storage.ChannelBindingRepository
+	traces  storage.ContextTraceRepository
+	flags   storage.FeatureFlagRepository
+	tools   storage.ToolStateRepository
+	stats   storage.StatsRepository
 }
 
-// NewRepository creates a new SQLite repository
+// NewRepository creates a new SQLite repository with default options
 func NewRepository(dbPath string) (storage.Repository, error) {
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
+	return NewRepositoryWithOptions(dbPath, DefaultOptions())
+}
+
+// NewRepositoryWithOptions creates a new SQLite repository using the given
+// pragmas and connection pool settings
+func NewRepositoryWithOptions(dbPath string, opts Options) (storage.Repository, error) {
+	db, err := gorm.Open(sqlite.Open(buildDSN(dbPath, opts)), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to access connection pool: %w", err)
+	}
+
+	// Every connection to ":memory:" opens a separate database, so pin the pool
+	if isMemoryPath(dbPath) {
+		opts.MaxOpenConns = 1
+		opts.MaxIdleConns = 1
+		opts.ConnMaxLifetime = 0
+	}
+	if opts.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	if opts.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+
+	var writes *writeSerializer
+	if opts.SerializeWrites {
+		writes = &writeSerializer{}
+		if err := writes.register(db); err != nil {
+			return nil, fmt.Errorf("failed to register write serializer: %w", err)
+		}
+	}
+
+	if opts.Cipher != nil {
+		if err := opts.Cipher.register(db); err != nil {
+			return nil, fmt.Errorf("failed to register encryption: %w", err)
+		}
+	}
+
+	if opts.ReadOnly {
+		return newRepository(db, writes), nil
+	}
+
+	if err := backfillMessageSequences(db); err != nil {
+		return nil, fmt.Errorf("failed to backfill message sequences: %w", err)
+	}
+	if err := backfillSessionActivity(db); err != nil {
+		return nil, fmt.Errorf("failed to backfill session activity: %w", err)
+	}
+	if err := backfillAgentEnabled(db); err != nil {
+		return nil, fmt.Errorf("failed to backfill agent status: %w", err)
+	}
+
 	// Auto-migrate the schema
-	if err := db.AutoMigrate(
-		&models.Agent{}, 
-		&models.ChatSession{}, 
+	if err := db.AutoMigrate(schemaModels()...); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return newRepository(db, writes), nil
+}
+
+// schemaModels lists every model with a table managed by AutoMigrate
+func schemaModels() []interface{} {
+	return []interface{}{
+		&models.Agent{},
+		&models.ChatSession{},
 		&models.Message{},
 		&models.ToolCall{},
 		&models.ToolExecutionLog{},
+		&models.ToolExecution{},
 		&models.Memory{},
-	); err != nil {
-		return nil, fmt.Errorf("failed to migrate database: %w", err)
+		&models.Job{},
+		&models.MessageArchive{},
+		&models.ChannelBinding{},
+		&models.ContextTrace{},
+		&models.FeatureFlag{},
+		&models.ToolState{},
+	}
+}
+
+// CheckSchema compares the database of a SQLite repository with the schema
+// the server migrates to. It returns the tables and columns, as
+// "table.column", that the next start would create.
+func CheckSchema(repo storage.Repository) ([]string, error) {
+	r, ok := repo.(*repository)
+	if !ok {
+		return nil, fmt.Errorf("not a SQLite repository")
+	}
+
+	migrator := r.db.Migrator()
+	var missing []string
+	for _, model := range schemaModels() {
+		stmt := &gorm.Statement{DB: r.db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		if !migrator.HasTable(model) {
+			missing = append(missing, stmt.Schema.Table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.IgnoreMigration {
+				continue
+			}
+			if !migrator.HasColumn(model, field.DBName) {
+				missing = append(missing, stmt.Schema.Table+"."+field.DBName)
+			}
+		}
+	}
+	return missing, nil
+}
+
+// backfillMessageSequences numbers the messages of databases created before
+// messages had a sequence, in creation order, so the unique (session_id,
+// sequence) index can be built
+func backfillMessageSequences(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.Message{}) || migrator.HasColumn(&models.Message{}, "Sequence") {
+		return nil
+	}
+	if err := migrator.AddColumn(&models.Message{}, "Sequence"); err != nil {
+		return err
 	}
+	return db.Exec(`UPDATE messages SET sequence = (
+		SELECT COUNT(*) FROM messages AS earlier
+		WHERE earlier.session_id = messages.session_id
+		AND (earlier.created_at < messages.created_at
+			OR (earlier.created_at = messages.created_at AND earlier.id <= messages.id)))`).Error
+}
 
-	repo := &repository{
-		db: db,
+// backfillSessionActivity adds the last message columns to sessions created
+// before they existed and fills them from the sessions' messages
+func backfillSessionActivity(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.ChatSession{}) || migrator.HasColumn(&models.ChatSession{}, "LastMessageAt") {
+		return nil
+	}
+	for _, field := range []string{"LastMessageAt", "LastMessageRole"} {
+		if err := migrator.AddColumn(&models.ChatSession{}, field); err != nil {
+			return err
+		}
 	}
+	if !migrator.HasTable(&models.Message{}) {
+		return nil
+	}
+	return db.Exec(`UPDATE chat_sessions SET
+		last_message_at = (SELECT created_at FROM messages
+			WHERE session_id = chat_sessions.id AND role IN ('user', 'assistant')
+			ORDER BY created_at DESC, id DESC LIMIT 1),
+		last_message_role = COALESCE((SELECT role FROM messages
+			WHERE session_id = chat_sessions.id AND role IN ('user', 'assistant')
+			ORDER BY created_at DESC, id DESC LIMIT 1), '')`).Error
+}
 
-	repo.agent = &agentRepository{db: db}
-	repo.session = &sessionRepository{db: db}
-	repo.message = &messageRepository{db: db}
-	repo.memory = NewMemoryRepository(db)
+// backfillAgentEnabled adds the enabled column to agents created before it
+// existed, enabling them. The column has no default of its own, since GORM
+// would write the default in place of a false Enabled.
+func backfillAgentEnabled(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.Agent{}) || migrator.HasColumn(&models.Agent{}, "Enabled") {
+		return nil
+	}
+	return db.Exec("ALTER TABLE `agents` ADD COLUMN `enabled` numeric NOT NULL DEFAULT true").Error
+}
 
-	return repo, nil
+// newRepository wires the entity repositories to a database handle
+func newRepository(db *gorm.DB, writes *writeSerializer) *repository {
+	return &repository{
+		db:      db,
+		writes:  writes,
+		agent:   &agentRepository{db: db},
+		session: &sessionRepository{db: db},
+		message: &messageRepository{db: db, writes: writes},
+		memory:  NewMemoryRepository(db),
+		tool:    &toolCallRepository{db: db},
+		toolLog: &toolExecutionLogRepository{db: db},
+		toolRun: &toolExecutionRepository{db: db},
+		job:     NewJobRepository(db),
+		archive: &archiveRepository{db: db},
+		binding: &channelBindingRepository{db: db},
+		traces:  &contextTraceRepository{db: db},
+		flags:   &featureFlagRepository{db: db},
+		tools:   &toolStateRepository{db: db},
+		stats:   NewStatsRepository(db),
+	}
 }
 
 func (r *repository) Agent() storage.AgentRepository {
@@ -69,6 +247,85 @@ func (r *repository) Memory() storage.MemoryRepository {
 	return r.memory
 }
 
+func (r *repository) ToolCall() storage.ToolCallRepository {
+	return r.tool
+}
+
+func (r *repository) ToolExecutionLog() storage.ToolExecutionLogRepository {
+	return r.toolLog
+}
+
+func (r *repository) ToolExecution() storage.ToolExecutionRepository {
+	return r.toolRun
+}
+
+func (r *repository) Job() storage.JobRepository {
+	return r.job
+}
+
+func (r *repository) Archive() storage.ArchiveRepository {
+	return r.archive
+}
+
+func (r *repository) ChannelBinding() storage.ChannelBindingRepository {
+	return r.binding
+}
+
+func (r *repository) ContextTrace() storage.ContextTraceRepository {
+	return r.traces
+}
+
+func (r *repository) FeatureFlag() storage.FeatureFlagRepository {
+	return r.flags
+}
+
+func (r *repository) ToolState() storage.ToolStateRepository {
+	return r.tools
+}
+
+func (r *repository) Stats() storage.StatsRepository {
+	return r.stats
+}
+
+func (r *repository) WithTx(ctx context.Context, fn func(tx storage.Repository) error) error {
+	// Hold the writer lock for the whole transaction; statements inside it
+	// skip the per-statement lock.
+	if r.writes != nil {
+		r.writes.mu.Lock()
+		defer r.writes.mu.Unlock()
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(newRepository(tx, nil))
+	})
+}
+
+func (r *repository) Ping(ctx context.Context) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (r *repository) CheckSchema(ctx context.Context) error {
+	migrator := r.db.WithContext(ctx).Migrator()
+	var missing []string
+	for _, model := range schemaModels() {
+		if !migrator.HasTable(model) {
+			stmt := &gorm.Statement{DB: r.db}
+			if err := stmt.Parse(model); err != nil {
+				return err
+			}
+			missing = append(missing, stmt.Schema.Table)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing tables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
 func (r *repository) Close() error {
 	sqlDB, err := r.db.DB()
 	if err != nil {
@@ -106,20 +363,59 @@ func (r *agentRepository) Delete(ctx context.Context, id string) error {
 	return r.db.WithContext(ctx).Delete(&models.Agent{}, "id = ?", id).Error
 }
 
-func (r *agentRepository) List(ctx context.Context, limit, offset int) ([]*models.Agent, int64, error) {
+func (r *agentRepository) List(ctx context.Context, filter *models.AgentFilter) ([]*models.Agent, int64, error) {
 	var agents []*models.Agent
 	var total int64
 
-	// Get total count
-	if err := r.db.WithContext(ctx).Model(&models.Agent{}).Count(&total).Error; err != nil {
+	query := r.db.WithContext(ctx).Model(&models.Agent{})
+	if filter.Provider != "" {
+		query = query.Where("provider = ?", filter.Provider)
+	}
+	if filter.Model != "" {
+		query = query.Where("model = ?", filter.Model)
+	}
+	if filter.Name != "" {
+		query = query.Where("LOWER(name) LIKE ?", "%"+strings.ToLower(filter.Name)+"%")
+	}
+	if filter.Tag != "" {
+		query = query.Where("tags LIKE ?", "%\""+strings.ToLower(filter.Tag)+"\"%")
+	}
+
+	// Get total count of all matching agents, independent of the page
+	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
-	// Get paginated results
-	err := r.db.WithContext(ctx).
-		Limit(limit).
-		Offset(offset).
-		Order("created_at DESC").
+	column := models.AgentSortCreatedAt
+	switch filter.SortBy {
+	case models.AgentSortUpdatedAt, models.AgentSortName:
+		column = filter.SortBy
+	}
+	direction, cmp := "ASC", ">"
+	if filter.Desc {
+		direction, cmp = "DESC", "<"
+	}
+
+	if filter.Cursor != nil {
+		var value interface{} = filter.Cursor.Value
+		if column != models.AgentSortName {
+			t, err := time.Parse(time.RFC3339Nano, filter.Cursor.Value)
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid cursor: %w", err)
+			}
+			value = t
+		}
+		// Keyset pagination; id breaks ties between equal sort values
+		query = query.Where(
+			fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", column, cmp, column, cmp),
+			value, value, filter.Cursor.ID)
+	} else if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	err := query.
+		Limit(filter.Limit).
+		Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
 		Find(&agents).Error
 
 	return agents, total, err
@@ -147,45 +443,219 @@ func (r *sessionRepository) GetByID(ctx context.Context, id string) (*models.Cha
 }
 
 func (r *sessionRepository) Update(ctx context.Context, session *models.ChatSession) error {
-	return r.db.WithContext(ctx).Save(session).Error
+	// The activity columns change with every message; saving a session that
+	// was read earlier must not roll them back
+	return r.db.WithContext(ctx).Omit(models.ActivityColumns...).Save(session).Error
+}
+
+func (r *sessionRepository) RecordActivity(ctx context.Context, sessionID, role string, at time.Time, unread bool) error {
+	unreadCount := gorm.Expr("0")
+	if unread {
+		unreadCount = gorm.Expr("unread_count + 1")
+	}
+	return r.db.WithContext(ctx).Model(&models.ChatSession{}).
+		Where("id = ?", sessionID).
+		UpdateColumns(map[string]interface{}{
+			"last_message_at":   at,
+			"last_message_role": role,
+			"unread_count":      unreadCount,
+		}).Error
+}
+
+func (r *sessionRepository) MarkRead(ctx context.Context, sessionID string, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.ChatSession{}).
+		Where("id = ?", sessionID).
+		UpdateColumns(map[string]interface{}{
+			"unread_count": 0,
+			"last_read_at": at,
+		}).Error
+}
+
+func (r *sessionRepository) SetLanguage(ctx context.Context, sessionID, language string) error {
+	return r.db.WithContext(ctx).Model(&models.ChatSession{}).
+		Where("id = ?", sessionID).
+		UpdateColumn("language", language).Error
 }
 
 func (r *sessionRepository) Delete(ctx context.Context, id string) error {
-	// Delete all messages first
+	// Delete all messages, archived messages and tool executions first
 	if err := r.db.WithContext(ctx).Delete(&models.Message{}, "session_id = ?", id).Error; err != nil {
 		return err
 	}
+	if err := r.db.WithContext(ctx).Delete(&models.MessageArchive{}, "session_id = ?", id).Error; err != nil {
+		return err
+	}
+	if err := r.db.WithContext(ctx).Delete(&models.ToolExecutionLog{}, "session_id = ?", id).Error; err != nil {
+		return err
+	}
+	if err := r.db.WithContext(ctx).Delete(&models.ToolExecution{}, "session_id = ?", id).Error; err != nil {
+		return err
+	}
+	if err := r.db.WithContext(ctx).Delete(&models.ChannelBinding{}, "session_id = ?", id).Error; err != nil {
+		return err
+	}
+	if err := r.db.WithContext(ctx).Delete(&models.ContextTrace{}, "session_id = ?", id).Error; err != nil {
+		return err
+	}
 	// Delete the session
 	return r.db.WithContext(ctx).Delete(&models.ChatSession{}, "id = ?", id).Error
 }
 
-func (r *sessionRepository) ListByAgentID(ctx context.Context, agentID string, limit, offset int) ([]*models.ChatSession, int64, error) {
+func (r *sessionRepository) List(ctx context.Context, filter *models.SessionFilter) ([]*models.ChatSession, int64, error) {
 	var sessions []*models.ChatSession
 	var total int64
 
+	query := r.db.WithContext(ctx).Model(&models.ChatSession{}).Where("agent_id = ?", filter.AgentID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Tag != "" {
+		query = query.Where("tags LIKE ?", "%\""+strings.ToLower(filter.Tag)+"\"%")
+	}
+	if filter.Starred != nil {
+		query = query.Where("starred = ?", *filter.Starred)
+	}
+	for key, value := range filter.Metadata {
+		// Values compare as text, so that numbers match their query form
+		query = query.Where("CAST(json_extract(metadata, ?) AS TEXT) = ?", metadataPath(key), value)
+	}
+
 	// Get total count
-	if err := r.db.WithContext(ctx).Model(&models.ChatSession{}).Where("agent_id = ?", agentID).Count(&total).Error; err != nil {
+	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
 	// Get paginated results
-	err := r.db.WithContext(ctx).
-		Where("agent_id = ?", agentID).
-		Limit(limit).
-		Offset(offset).
-		Order("updated_at DESC").
+	err := query.
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Order("COALESCE(last_message_at, created_at) DESC").
 		Find(&sessions).Error
 
 	return sessions, total, err
 }
 
+// metadataPath is the JSON path of a top-level metadata key
+func metadataPath(key string) string {
+	return `$."` + strings.ReplaceAll(key, `"`, `\"`) + `"`
+}
+
+func (r *sessionRepository) ListIdle(ctx context.Context, before time.Time, limit int) ([]*models.ChatSession, error) {
+	var sessions []*models.ChatSession
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND updated_at < ?", models.SessionStatusActive, before).
+		Order("updated_at ASC").
+		Limit(limit).
+		Find(&sessions).Error
+	return sessions, err
+}
+
 // Message repository implementation
 type messageRepository struct {
-	db *gorm.DB
+	db     *gorm.DB
+	writes *writeSerializer
 }
 
+// Create stores a message. A zero Sequence is assigned the session's next
+// sequence number; an explicit one must be greater than the latest.
 func (r *messageRepository) Create(ctx context.Context, message *models.Message) error {
-	return r.db.WithContext(ctx).Create(message).Error
+	// Reading the latest sequence and inserting must not interleave with
+	// another writer, so hold the writer lock like WithTx does
+	if r.writes != nil {
+		r.writes.mu.Lock()
+		defer r.writes.mu.Unlock()
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		last, err := (&messageRepository{db: tx}).lastSequence(ctx, message.SessionID)
+		if err != nil {
+			return err
+		}
+		if message.Sequence == 0 {
+			message.Sequence = last + 1
+		} else if message.Sequence <= last {
+			return fmt.Errorf("%w: sequence %d is not after %d", storage.ErrSequenceConflict, message.Sequence, last)
+		}
+
+		if err := tx.Create(message).Error; err != nil {
+			// Only reachable when writes are not serialized
+			if isSequenceConflict(err) {
+				return fmt.Errorf("%w: %v", storage.ErrSequenceConflict, err)
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// Compact replaces the messages up to through with one message. The
+// replacement may take a sequence below the latest, since the ones it
+// replaces are gone.
+func (r *messageRepository) Compact(ctx context.Context, sessionID string, through int64, replacement *models.Message) error {
+	if r.writes != nil {
+		r.writes.mu.Lock()
+		defer r.writes.mu.Unlock()
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		compacted := tx.Model(&models.Message{}).Select("id").Where("session_id = ? AND sequence <= ?", sessionID, through)
+		if err := tx.Where("message_id IN (?)", compacted).Delete(&models.ToolCall{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Message{}, "session_id = ? AND sequence <= ?", sessionID, through).Error; err != nil {
+			return err
+		}
+		replacement.SessionID = sessionID
+		replacement.Sequence = through
+		return tx.Create(replacement).Error
+	})
+}
+
+func (r *messageRepository) DeleteThrough(ctx context.Context, sessionID string, through int64) error {
+	if r.writes != nil {
+		r.writes.mu.Lock()
+		defer r.writes.mu.Unlock()
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		deleted := tx.Model(&models.Message{}).Select("id").Where("session_id = ? AND sequence <= ?", sessionID, through)
+		if err := tx.Where("message_id IN (?)", deleted).Delete(&models.ToolCall{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Message{}, "session_id = ? AND sequence <= ?", sessionID, through).Error
+	})
+}
+
+func (r *messageRepository) Restore(ctx context.Context, message *models.Message) error {
+	if r.writes != nil {
+		r.writes.mu.Lock()
+		defer r.writes.mu.Unlock()
+	}
+
+	if err := r.db.WithContext(ctx).Create(message).Error; err != nil {
+		if isSequenceConflict(err) {
+			return fmt.Errorf("%w: %v", storage.ErrSequenceConflict, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *messageRepository) lastSequence(ctx context.Context, sessionID string) (int64, error) {
+	var last int64
+	err := r.db.WithContext(ctx).
+		Model(&models.Message{}).
+		Where("session_id = ?", sessionID).
+		Select("COALESCE(MAX(sequence), 0)").
+		Row().Scan(&last)
+	return last, err
+}
+
+// isSequenceConflict reports whether err is a violation of the
+// (session_id, sequence) unique index
+func isSequenceConflict(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "UNIQUE constraint failed") && strings.Contains(msg, "messages.sequence")
 }
 
 func (r *messageRepository) GetByID(ctx context.Context, id string) (*models.Message, error) {
@@ -201,25 +671,60 @@ func (r *messageRepository) GetByID(ctx context.Context, id string) (*models.Mes
 }
 
 func (r *messageRepository) ListBySessionID(ctx context.Context, sessionID string, limit, offset int) ([]*models.Message, int64, error) {
+	return r.listBySessionID(ctx, sessionID, limit, offset, false)
+}
+
+func (r *messageRepository) ListVisibleBySessionID(ctx context.Context, sessionID string, limit, offset int) ([]*models.Message, int64, error) {
+	return r.listBySessionID(ctx, sessionID, limit, offset, true)
+}
+
+func (r *messageRepository) listBySessionID(ctx context.Context, sessionID string, limit, offset int, visibleOnly bool) ([]*models.Message, int64, error) {
 	var messages []*models.Message
 	var total int64
 
 	// Get total count
-	if err := r.db.WithContext(ctx).Model(&models.Message{}).Where("session_id = ?", sessionID).Count(&total).Error; err != nil {
+	if err := r.sessionMessages(ctx, sessionID, visibleOnly).Model(&models.Message{}).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
 	// Get paginated results
-	err := r.db.WithContext(ctx).
-		Where("session_id = ?", sessionID).
+	err := r.sessionMessages(ctx, sessionID, visibleOnly).
 		Limit(limit).
 		Offset(offset).
-		Order("created_at ASC").
+		Order("sequence ASC").
 		Find(&messages).Error
 
 	return messages, total, err
 }
 
+func (r *messageRepository) ListAfter(ctx context.Context, sessionID string, after int64, limit int) ([]*models.Message, error) {
+	return r.listAfter(ctx, sessionID, after, limit, false)
+}
+
+func (r *messageRepository) ListVisibleAfter(ctx context.Context, sessionID string, after int64, limit int) ([]*models.Message, error) {
+	return r.listAfter(ctx, sessionID, after, limit, true)
+}
+
+func (r *messageRepository) listAfter(ctx context.Context, sessionID string, after int64, limit int, visibleOnly bool) ([]*models.Message, error) {
+	var messages []*models.Message
+	err := r.sessionMessages(ctx, sessionID, visibleOnly).
+		Where("sequence > ?", after).
+		Order("sequence ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// sessionMessages scopes a query to a session's messages, optionally leaving
+// out hidden ones
+func (r *messageRepository) sessionMessages(ctx context.Context, sessionID string, visibleOnly bool) *gorm.DB {
+	query := r.db.WithContext(ctx).Where("session_id = ?", sessionID)
+	if visibleOnly {
+		query = query.Where("hidden = ?", false)
+	}
+	return query
+}
+
 func (r *messageRepository) DeleteBySessionID(ctx context.Context, sessionID string) error {
 	return r.db.WithContext(ctx).Delete(&models.Message{}, "session_id = ?", sessionID).Error
 }
@@ -228,7 +733,7 @@ func (r *messageRepository) GetLastNMessages(ctx context.Context, sessionID stri
 	var messages []*models.Message
 	err := r.db.WithContext(ctx).
 		Where("session_id = ?", sessionID).
-		Order("created_at DESC").
+		Order("sequence DESC").
 		Limit(n).
 		Find(&messages).Error
 
@@ -242,4 +747,271 @@ func (r *messageRepository) GetLastNMessages(ctx context.Context, sessionID stri
 	}
 
 	return messages, nil
-}
\ No newline at end of file
+}
+
+func (r *messageRepository) UpdateStatus(ctx context.Context, id, status string) error {
+	return r.db.WithContext(ctx).Model(&models.Message{}).Where("id = ?", id).Update("status", status).Error
+}
+
+func (r *messageRepository) UpdateTurnStatus(ctx context.Context, turnID, status string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.Message{}).
+		Where("id = ? OR turn_id = ?", turnID, turnID).
+		Update("status", status).Error
+}
+
+func (r *messageRepository) ListByStatus(ctx context.Context, status string) ([]*models.Message, error) {
+	var messages []*models.Message
+	err := r.db.WithContext(ctx).
+		Where("status = ?", status).
+		Order("created_at ASC").
+		Find(&messages).Error
+	return messages, err
+}
+
+func (r *messageRepository) LastAssistantByAgent(ctx context.Context, agentID string) (*models.Message, error) {
+	var message models.Message
+	err := r.db.WithContext(ctx).
+		Joins("JOIN chat_sessions ON chat_sessions.id = messages.session_id").
+		Where("chat_sessions.agent_id = ? AND messages.role = ? AND messages.status = ?",
+			agentID, "assistant", models.MessageStatusComplete).
+		Order("messages.created_at DESC").
+		First(&message).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &message, nil
+}
+
+// Tool call repository implementation
+type toolCallRepository struct {
+	db *gorm.DB
+}
+
+func (r *toolCallRepository) Create(ctx context.Context, toolCall *models.ToolCall) error {
+	return r.db.WithContext(ctx).Omit("Message").Create(toolCall).Error
+}
+
+func (r *toolCallRepository) ListBySessionID(ctx context.Context, sessionID string) ([]*models.ToolCall, error) {
+	var toolCalls []*models.ToolCall
+	err := r.db.WithContext(ctx).
+		Where("message_id IN (?)", r.db.Model(&models.Message{}).Select("id").Where("session_id = ?", sessionID)).
+		Order("created_at ASC").
+		Find(&toolCalls).Error
+	return toolCalls, err
+}
+
+func (r *toolCallRepository) DeleteBySessionID(ctx context.Context, sessionID string) error {
+	return r.db.WithContext(ctx).
+		Where("message_id IN (?)", r.db.Model(&models.Message{}).Select("id").Where("session_id = ?", sessionID)).
+		Delete(&models.ToolCall{}).Error
+}
+
+func (r *toolCallRepository) ListByMessageID(ctx context.Context, messageID string) ([]*models.ToolCall, error) {
+	var toolCalls []*models.ToolCall
+	err := r.db.WithContext(ctx).
+		Where("message_id = ?", messageID).
+		Order("created_at ASC").
+		Find(&toolCalls).Error
+	return toolCalls, err
+}
+// Tool execution log repository implementation
+type toolExecutionLogRepository struct {
+	db *gorm.DB
+}
+
+func (r *toolExecutionLogRepository) Create(ctx context.Context, log *models.ToolExecutionLog) error {
+	return r.db.WithContext(ctx).Omit("Session", "ToolCall").Create(log).Error
+}
+
+func (r *toolExecutionLogRepository) ListBySessionID(ctx context.Context, sessionID string, limit, offset int) ([]*models.ToolExecutionLog, int64, error) {
+	var logs []*models.ToolExecutionLog
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.ToolExecutionLog{}).Where("session_id = ?", sessionID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("executed_at ASC").Limit(limit).Offset(offset).Find(&logs).Error
+	return logs, total, err
+}
+
+func (r *toolExecutionLogRepository) CountCalls(ctx context.Context, filter models.ToolCallCountFilter) (int64, error) {
+	query := r.db.WithContext(ctx).
+		Model(&models.ToolExecutionLog{}).
+		Where("tool_execution_logs.executed_at >= ?", filter.Since)
+	if filter.SessionID != "" {
+		query = query.Where("tool_execution_logs.session_id = ?", filter.SessionID)
+	}
+	if filter.AgentID != "" {
+		query = query.Joins("JOIN chat_sessions ON chat_sessions.id = tool_execution_logs.session_id").
+			Where("chat_sessions.agent_id = ?", filter.AgentID)
+	}
+	if filter.ToolName != "" {
+		query = query.Where("tool_execution_logs.tool_name = ?", filter.ToolName)
+	}
+	if filter.ExcludeErrorCode != "" {
+		query = query.Where("COALESCE(tool_execution_logs.error_code, '') <> ?", filter.ExcludeErrorCode)
+	}
+
+	var count int64
+	err := query.
+		Select("COUNT(DISTINCT tool_execution_logs.session_id || ':' || tool_execution_logs.tool_call_id)").
+		Scan(&count).Error
+	return count, err
+}
+
+// Tool execution repository implementation
+type toolExecutionRepository struct {
+	db *gorm.DB
+}
+
+func (r *toolExecutionRepository) Create(ctx context.Context, execution *models.ToolExecution) error {
+	return r.db.WithContext(ctx).Create(execution).Error
+}
+
+func (r *toolExecutionRepository) GetByID(ctx context.Context, id string) (*models.ToolExecution, error) {
+	var execution models.ToolExecution
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&execution).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &execution, nil
+}
+
+func (r *toolExecutionRepository) Update(ctx context.Context, execution *models.ToolExecution) error {
+	return r.db.WithContext(ctx).Save(execution).Error
+}
+
+// Archive repository implementation
+type archiveRepository struct {
+	db *gorm.DB
+}
+
+func (r *archiveRepository) Create(ctx context.Context, archive *models.MessageArchive) error {
+	return r.db.WithContext(ctx).Create(archive).Error
+}
+
+func (r *archiveRepository) GetByID(ctx context.Context, id string) (*models.MessageArchive, error) {
+	var archive models.MessageArchive
+	err := r.db.WithContext(ctx).First(&archive, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &archive, nil
+}
+
+func (r *archiveRepository) ListBySessionID(ctx context.Context, sessionID string) ([]*models.MessageArchive, error) {
+	var archives []*models.MessageArchive
+	err := r.db.WithContext(ctx).
+		Where("session_id = ?", sessionID).
+		Order("created_at ASC").
+		Find(&archives).Error
+	return archives, err
+}
+
+func (r *archiveRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Delete(&models.MessageArchive{}, "id = ?", id).Error
+}
+
+func (r *archiveRepository) DeleteBySessionID(ctx context.Context, sessionID string) error {
+	return r.db.WithContext(ctx).Delete(&models.MessageArchive{}, "session_id = ?", sessionID).Error
+}
+
+// Channel binding repository implementation
+type channelBindingRepository struct {
+	db *gorm.DB
+}
+
+func (r *channelBindingRepository) Get(ctx context.Context, platform, externalID string) (*models.ChannelBinding, error) {
+	var binding models.ChannelBinding
+	err := r.db.WithContext(ctx).First(&binding, "platform = ? AND external_id = ?", platform, externalID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &binding, nil
+}
+
+func (r *channelBindingRepository) Save(ctx context.Context, binding *models.ChannelBinding) error {
+	return r.db.WithContext(ctx).Save(binding).Error
+}
+
+func (r *channelBindingRepository) DeleteBySessionID(ctx context.Context, sessionID string) error {
+	return r.db.WithContext(ctx).Delete(&models.ChannelBinding{}, "session_id = ?", sessionID).Error
+}
+
+// Context trace repository implementation
+type contextTraceRepository struct {
+	db *gorm.DB
+}
+
+func (r *contextTraceRepository) Save(ctx context.Context, trace *models.ContextTrace) error {
+	return r.db.WithContext(ctx).Save(trace).Error
+}
+
+func (r *contextTraceRepository) GetByTurnID(ctx context.Context, turnID string) (*models.ContextTrace, error) {
+	var trace models.ContextTrace
+	err := r.db.WithContext(ctx).First(&trace, "turn_id = ?", turnID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &trace, nil
+}
+
+func (r *contextTraceRepository) DeleteBySessionID(ctx context.Context, sessionID string) error {
+	return r.db.WithContext(ctx).Delete(&models.ContextTrace{}, "session_id = ?", sessionID).Error
+}
+
+// Feature flag repository implementation
+type featureFlagRepository struct {
+	db *gorm.DB
+}
+
+func (r *featureFlagRepository) List(ctx context.Context) ([]*models.FeatureFlag, error) {
+	var flags []*models.FeatureFlag
+	err := r.db.WithContext(ctx).Order("name ASC").Find(&flags).Error
+	return flags, err
+}
+
+func (r *featureFlagRepository) Save(ctx context.Context, flag *models.FeatureFlag) error {
+	return r.db.WithContext(ctx).Save(flag).Error
+}
+
+func (r *featureFlagRepository) Delete(ctx context.Context, name string) error {
+	return r.db.WithContext(ctx).Delete(&models.FeatureFlag{}, "name = ?", name).Error
+}
+
+// Tool state repository implementation
+type toolStateRepository struct {
+	db *gorm.DB
+}
+
+func (r *toolStateRepository) List(ctx context.Context) ([]*models.ToolState, error) {
+	var states []*models.ToolState
+	err := r.db.WithContext(ctx).Order("name ASC").Find(&states).Error
+	return states, err
+}
+
+func (r *toolStateRepository) Save(ctx context.Context, state *models.ToolState) error {
+	return r.db.WithContext(ctx).Save(state).Error
+}
+
+func (r *toolStateRepository) Delete(ctx context.Context, name string) error {
+	return r.db.WithContext(ctx).Delete(&models.ToolState{}, "name = ?", name).Error
+}
@@ -0,0 +1,393 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentRepository_ListFiltered(t *testing.T) {
+	repo, err := NewRepository(filepath.Join(t.TempDir(), "agents.db"))
+	require.NoError(t, err)
+	defer repo.Close()
+
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+	fixtures := []struct {
+		name     string
+		provider string
+		model    string
+		tags     []string
+	}{
+		{"Support Bot", "openai", "gpt-4", []string{"support", "prod"}},
+		{"Sales Bot", "openai", "gpt-3.5-turbo", []string{"sales"}},
+		{"Code Helper", "ollama", "codellama", []string{"dev", "prod"}},
+		{"Support Triage", "anthropic", "claude-3", []string{"support"}},
+	}
+	for i, f := range fixtures {
+		agent := &models.Agent{
+			Name:         f.name,
+			Provider:     f.provider,
+			Model:        f.model,
+			SystemPrompt: "test",
+			Tags:         models.NormalizeTags(f.tags),
+			CreatedAt:    base.Add(time.Duration(i) * time.Minute),
+		}
+		require.NoError(t, repo.Agent().Create(ctx, agent))
+	}
+
+	names := func(agents []*models.Agent) []string {
+		result := make([]string, len(agents))
+		for i, agent := range agents {
+			result[i] = agent.Name
+		}
+		return result
+	}
+
+	agents, total, err := repo.Agent().List(ctx, &models.AgentFilter{Provider: "openai", Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, []string{"Support Bot", "Sales Bot"}, names(agents))
+
+	agents, total, err = repo.Agent().List(ctx, &models.AgentFilter{Name: "support", Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.ElementsMatch(t, []string{"Support Bot", "Support Triage"}, names(agents))
+
+	agents, _, err = repo.Agent().List(ctx, &models.AgentFilter{Tag: "PROD", SortBy: models.AgentSortName, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Code Helper", "Support Bot"}, names(agents))
+
+	// Walk all agents newest first, two per page
+	filter := &models.AgentFilter{SortBy: models.AgentSortCreatedAt, Desc: true, Limit: 2}
+	agents, total, err = repo.Agent().List(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+	assert.Equal(t, []string{"Support Triage", "Code Helper"}, names(agents))
+
+	filter.Cursor = models.NewAgentCursor(agents[1], filter.SortBy)
+	agents, total, err = repo.Agent().List(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+	assert.Equal(t, []string{"Sales Bot", "Support Bot"}, names(agents))
+
+	filter.Cursor = models.NewAgentCursor(agents[1], filter.SortBy)
+	agents, _, err = repo.Agent().List(ctx, filter)
+	require.NoError(t, err)
+	assert.Empty(t, agents)
+}
+
+func TestAgentRepository_CreateDisabled(t *testing.T) {
+	repo, err := NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	disabled := &models.Agent{Name: "paused", Provider: "ollama", Model: "llama3", MaintenanceMessage: "Back soon"}
+	require.NoError(t, repo.Agent().Create(ctx, disabled))
+	enabled := &models.Agent{Name: "live", Provider: "ollama", Model: "llama3", Enabled: true}
+	require.NoError(t, repo.Agent().Create(ctx, enabled))
+
+	stored, err := repo.Agent().GetByID(ctx, disabled.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.Enabled, "a disabled agent is stored disabled")
+	assert.Equal(t, "Back soon", stored.MaintenanceMessage)
+	stored, err = repo.Agent().GetByID(ctx, enabled.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.Enabled)
+}
+
+func TestSessionRepository_ListFiltered(t *testing.T) {
+	repo, err := NewRepository(filepath.Join(t.TempDir(), "sessions.db"))
+	require.NoError(t, err)
+	defer repo.Close()
+
+	ctx := context.Background()
+	agent := &models.Agent{Name: "inbox", Provider: "ollama", Model: "llama3", SystemPrompt: "test"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+
+	base := time.Now().Add(-time.Hour)
+	fixtures := []struct {
+		title    string
+		tags     []string
+		metadata models.JSON
+		starred  bool
+		status   string
+	}{
+		{"Refund", []string{"billing"}, models.JSON{"customer": "acme", "priority": 1}, true, models.SessionStatusActive},
+		{"Invoice", []string{"billing", "urgent"}, models.JSON{"customer": "globex"}, false, models.SessionStatusActive},
+		{"Login", []string{"support"}, models.JSON{"customer": "acme", "priority": 2}, false, models.SessionStatusActive},
+		{"Old refund", []string{"billing"}, models.JSON{"customer": "acme"}, true, models.SessionStatusArchived},
+	}
+	for i, f := range fixtures {
+		session := &models.ChatSession{
+			AgentID:   agent.ID,
+			Title:     f.title,
+			Tags:      models.NormalizeTags(f.tags),
+			Metadata:  f.metadata,
+			Starred:   f.starred,
+			Status:    f.status,
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+			UpdatedAt: base.Add(time.Duration(i) * time.Minute),
+		}
+		require.NoError(t, repo.Session().Create(ctx, session))
+	}
+
+	titles := func(filter *models.SessionFilter) []string {
+		filter.AgentID = agent.ID
+		filter.Limit = 10
+		sessions, total, err := repo.Session().List(ctx, filter)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(sessions)), total)
+		result := make([]string, len(sessions))
+		for i, session := range sessions {
+			result[i] = session.Title
+		}
+		return result
+	}
+	starred := true
+
+	assert.Equal(t, []string{"Old refund", "Login", "Invoice", "Refund"}, titles(&models.SessionFilter{}))
+	assert.Equal(t, []string{"Invoice", "Refund"},
+		titles(&models.SessionFilter{Status: models.SessionStatusActive, Tag: "Billing"}))
+	assert.Equal(t, []string{"Old refund", "Refund"}, titles(&models.SessionFilter{Starred: &starred}))
+	assert.Equal(t, []string{"Old refund", "Login", "Refund"},
+		titles(&models.SessionFilter{Metadata: map[string]string{"customer": "acme"}}))
+	assert.Equal(t, []string{"Login"},
+		titles(&models.SessionFilter{Metadata: map[string]string{"customer": "acme", "priority": "2"}}))
+	assert.Empty(t, titles(&models.SessionFilter{Metadata: map[string]string{"missing": "x"}}))
+}
+
+func TestSessionRepository_Activity(t *testing.T) {
+	repo, err := NewRepository(filepath.Join(t.TempDir(), "activity.db"))
+	require.NoError(t, err)
+	defer repo.Close()
+
+	ctx := context.Background()
+	agent := &models.Agent{Name: "inbox", Provider: "ollama", Model: "llama3", SystemPrompt: "test"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	base := time.Now().Add(-time.Hour)
+	quiet := &models.ChatSession{AgentID: agent.ID, Title: "Quiet", CreatedAt: base.Add(time.Minute)}
+	busy := &models.ChatSession{AgentID: agent.ID, Title: "Busy", CreatedAt: base}
+	require.NoError(t, repo.Session().Create(ctx, quiet))
+	require.NoError(t, repo.Session().Create(ctx, busy))
+
+	// A stale copy of the session is saved after the replies arrived
+	stale, err := repo.Session().GetByID(ctx, busy.ID)
+	require.NoError(t, err)
+	require.NoError(t, repo.Session().RecordActivity(ctx, busy.ID, "user", base.Add(2*time.Minute), false))
+	require.NoError(t, repo.Session().RecordActivity(ctx, busy.ID, "assistant", base.Add(3*time.Minute), true))
+	require.NoError(t, repo.Session().RecordActivity(ctx, busy.ID, "assistant", base.Add(4*time.Minute), true))
+	stale.Title = "Renamed"
+	require.NoError(t, repo.Session().Update(ctx, stale))
+
+	saved, err := repo.Session().GetByID(ctx, busy.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", saved.Title)
+	assert.Equal(t, "assistant", saved.LastMessageRole)
+	assert.Equal(t, 2, saved.UnreadCount)
+	require.NotNil(t, saved.LastMessageAt)
+	assert.WithinDuration(t, base.Add(4*time.Minute), *saved.LastMessageAt, time.Millisecond)
+
+	// Sessions are listed by their last message, or creation without messages
+	sessions, _, err := repo.Session().List(ctx, &models.SessionFilter{AgentID: agent.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, []string{"Renamed", "Quiet"}, []string{sessions[0].Title, sessions[1].Title})
+
+	require.NoError(t, repo.Session().MarkRead(ctx, busy.ID, time.Now()))
+	saved, err = repo.Session().GetByID(ctx, busy.ID)
+	require.NoError(t, err)
+	assert.Zero(t, saved.UnreadCount)
+	assert.NotNil(t, saved.LastReadAt)
+}
+
+func TestMessageRepository_Sequence(t *testing.T) {
+	repo, err := NewRepository(filepath.Join(t.TempDir(), "messages.db"))
+	require.NoError(t, err)
+	defer repo.Close()
+
+	ctx := context.Background()
+	agent := &models.Agent{Name: "seq", Provider: "ollama", Model: "llama3"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID}
+	require.NoError(t, repo.Session().Create(ctx, session))
+
+	// Identical timestamps must not affect ordering
+	createdAt := time.Now()
+	for i := 0; i < 5; i++ {
+		message := &models.Message{
+			SessionID: session.ID,
+			Role:      "user",
+			Content:   fmt.Sprintf("message %d", i+1),
+			CreatedAt: createdAt,
+		}
+		require.NoError(t, repo.Message().Create(ctx, message))
+		assert.Equal(t, int64(i+1), message.Sequence)
+	}
+
+	err = repo.Message().Create(ctx, &models.Message{SessionID: session.ID, Role: "user", Content: "stale", Sequence: 3})
+	assert.ErrorIs(t, err, storage.ErrSequenceConflict)
+
+	last, err := repo.Message().GetLastNMessages(ctx, session.ID, 2)
+	require.NoError(t, err)
+	require.Len(t, last, 2)
+	assert.Equal(t, "message 4", last[0].Content)
+	assert.Equal(t, "message 5", last[1].Content)
+
+	page, err := repo.Message().ListAfter(ctx, session.ID, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, int64(3), page[0].Sequence)
+	assert.Equal(t, int64(4), page[1].Sequence)
+}
+
+func TestNewRepository_BackfillsMessageSequences(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	repo, err := NewRepository(path)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	agent := &models.Agent{Name: "legacy", Provider: "ollama", Model: "llama3"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID}
+	require.NoError(t, repo.Session().Create(ctx, session))
+	base := time.Now()
+	for i, content := range []string{"second", "first", "third"} {
+		offset := []time.Duration{time.Minute, 0, 2 * time.Minute}[i]
+		require.NoError(t, repo.Message().Create(ctx, &models.Message{
+			SessionID: session.ID, Role: "user", Content: content, CreatedAt: base.Add(offset),
+		}))
+	}
+
+	// Reduce the table to its shape from before sequences existed
+	db := repo.(*repository).db
+	require.NoError(t, db.Migrator().DropIndex(&models.Message{}, "idx_messages_session_sequence"))
+	require.NoError(t, db.Migrator().DropColumn(&models.Message{}, "Sequence"))
+	require.NoError(t, repo.Close())
+
+	repo, err = NewRepository(path)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	messages, _, err := repo.Message().ListBySessionID(ctx, session.ID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 3)
+	for i, content := range []string{"first", "second", "third"} {
+		assert.Equal(t, content, messages[i].Content)
+		assert.Equal(t, int64(i+1), messages[i].Sequence)
+	}
+}
+
+func TestNewRepository_BackfillsSessionActivity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	repo, err := NewRepository(path)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	agent := &models.Agent{Name: "legacy", Provider: "ollama", Model: "llama3"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+	session := &models.ChatSession{AgentID: agent.ID}
+	empty := &models.ChatSession{AgentID: agent.ID}
+	require.NoError(t, repo.Session().Create(ctx, session))
+	require.NoError(t, repo.Session().Create(ctx, empty))
+	base := time.Now()
+	for i, role := range []string{"user", "assistant", "tool"} {
+		require.NoError(t, repo.Message().Create(ctx, &models.Message{
+			SessionID: session.ID, Role: role, Content: role, CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	// Reduce the table to its shape from before activity tracking
+	db := repo.(*repository).db
+	for _, field := range []string{"LastMessageAt", "LastMessageRole"} {
+		require.NoError(t, db.Migrator().DropColumn(&models.ChatSession{}, field))
+	}
+	require.NoError(t, repo.Close())
+
+	repo, err = NewRepository(path)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	saved, err := repo.Session().GetByID(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "assistant", saved.LastMessageRole)
+	require.NotNil(t, saved.LastMessageAt)
+	assert.WithinDuration(t, base.Add(time.Minute), *saved.LastMessageAt, time.Millisecond)
+
+	saved, err = repo.Session().GetByID(ctx, empty.ID)
+	require.NoError(t, err)
+	assert.Nil(t, saved.LastMessageAt)
+	assert.Empty(t, saved.LastMessageRole)
+}
+
+func TestNewRepository_BackfillsAgentEnabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	repo, err := NewRepository(path)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	agent := &models.Agent{Name: "legacy", Provider: "ollama", Model: "llama3"}
+	require.NoError(t, repo.Agent().Create(ctx, agent))
+
+	// Reduce the table to its shape from before agents could be disabled
+	db := repo.(*repository).db
+	require.NoError(t, db.Exec("DROP INDEX idx_agents_enabled").Error)
+	require.NoError(t, db.Migrator().DropColumn(&models.Agent{}, "Enabled"))
+	require.NoError(t, repo.Close())
+
+	for i := 0; i < 2; i++ {
+		repo, err = NewRepository(path)
+		require.NoError(t, err)
+		saved, err := repo.Agent().GetByID(ctx, agent.ID)
+		require.NoError(t, err)
+		assert.True(t, saved.Enabled, "existing agents stay enabled")
+		require.NoError(t, repo.Close())
+	}
+
+	repo, err = NewRepository(path)
+	require.NoError(t, err)
+	defer repo.Close()
+	disabled := &models.Agent{Name: "paused", Provider: "ollama", Model: "llama3"}
+	require.NoError(t, repo.Agent().Create(ctx, disabled))
+	saved, err := repo.Agent().GetByID(ctx, disabled.ID)
+	require.NoError(t, err)
+	assert.False(t, saved.Enabled)
+}
+
+func TestCheckSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	repo, err := NewRepository(path)
+	require.NoError(t, err)
+	db := repo.(*repository).db
+	require.NoError(t, db.Migrator().DropTable(&models.ChannelBinding{}))
+	require.NoError(t, db.Migrator().DropIndex(&models.Message{}, "idx_messages_session_sequence"))
+	require.NoError(t, db.Migrator().DropColumn(&models.Message{}, "Sequence"))
+	require.NoError(t, repo.Close())
+
+	// Inspecting leaves the database as it is
+	for i := 0; i < 2; i++ {
+		repo, err = NewRepositoryWithOptions(path, Options{ReadOnly: true})
+		require.NoError(t, err)
+		missing, err := CheckSchema(repo)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"channel_bindings", "messages.sequence"}, missing)
+		require.Error(t, repo.Agent().Create(context.Background(), &models.Agent{Name: "x", Provider: "ollama", Model: "m"}))
+		require.NoError(t, repo.Close())
+	}
+
+	repo, err = NewRepository(path)
+	require.NoError(t, err)
+	defer repo.Close()
+	missing, err := CheckSchema(repo)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+}
@@ -0,0 +1,233 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+// timestampFormat is the layout the SQLite driver uses to store time values
+const timestampFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// statsRepository runs aggregate queries for the admin dashboard
+type statsRepository struct {
+	db *gorm.DB
+}
+
+// NewStatsRepository creates a new stats repository
+func NewStatsRepository(db *gorm.DB) storage.StatsRepository {
+	return &statsRepository{db: db}
+}
+
+func (r *statsRepository) Totals(ctx context.Context, activeSince time.Time) (*models.StatsTotals, error) {
+	var totals models.StatsTotals
+	db := r.db.WithContext(ctx)
+
+	counts := []struct {
+		target *int64
+		query  *gorm.DB
+	}{
+		{&totals.Agents, db.Model(&models.Agent{})},
+		{&totals.Sessions, db.Model(&models.ChatSession{})},
+		{&totals.ActiveSessions, db.Model(&models.ChatSession{}).
+			Where("status = ? AND updated_at >= ?", models.SessionStatusActive, activeSince)},
+		{&totals.ArchivedSessions, db.Model(&models.ChatSession{}).
+			Where("status = ?", models.SessionStatusArchived)},
+		{&totals.Messages, db.Model(&models.Message{})},
+		{&totals.ToolExecutions, db.Model(&models.ToolCall{})},
+	}
+	for _, count := range counts {
+		if err := count.query.Count(count.target).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var pageCount, pageSize int64
+	if err := db.Raw("PRAGMA page_count").Scan(&pageCount).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Raw("PRAGMA page_size").Scan(&pageSize).Error; err != nil {
+		return nil, err
+	}
+	totals.DatabaseSizeBytes = pageCount * pageSize
+
+	return &totals, nil
+}
+
+func (r *statsRepository) ChatsPerDay(ctx context.Context, since time.Time) ([]models.DailyChatStats, error) {
+	var days []models.DailyChatStats
+	err := r.db.WithContext(ctx).
+		Model(&models.Message{}).
+		Select("date(created_at) AS date, COUNT(*) AS chats, SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS errors",
+			models.MessageStatusIncomplete).
+		Where("role = ? AND created_at >= ?", "user", since).
+		Group("date(created_at)").
+		Order("date ASC").
+		Scan(&days).Error
+	return days, err
+}
+
+func (r *statsRepository) TokensByProvider(ctx context.Context, since time.Time) ([]models.ProviderTokenStats, error) {
+	var providers []models.ProviderTokenStats
+	// Metadata is stored as a JSON blob; cast it so json_extract accepts it
+	err := r.db.WithContext(ctx).
+		Table("messages").
+		Select(`agents.provider AS provider,
+			COALESCE(SUM(json_extract(CAST(messages.metadata AS TEXT), '$.usage.prompt_tokens')), 0) AS prompt_tokens,
+			COALESCE(SUM(json_extract(CAST(messages.metadata AS TEXT), '$.usage.completion_tokens')), 0) AS completion_tokens,
+			COALESCE(SUM(json_extract(CAST(messages.metadata AS TEXT), '$.usage.total_tokens')), 0) AS total_tokens,
+			COUNT(*) AS responses`).
+		Joins("JOIN chat_sessions ON chat_sessions.id = messages.session_id").
+		Joins("JOIN agents ON agents.id = chat_sessions.agent_id").
+		Where("messages.role = ? AND messages.created_at >= ?", "assistant", since).
+		Group("agents.provider").
+		Order("total_tokens DESC").
+		Scan(&providers).Error
+	return providers, err
+}
+
+func (r *statsRepository) ToolUsage(ctx context.Context, since time.Time) ([]models.ToolUsageStats, error) {
+	var rows []struct {
+		ToolName    string
+		TotalCalls  int64
+		FailedCalls int64
+		AvgDuration float64
+		LastUsed    string
+	}
+	// Every executed tool call is persisted with its outcome in tool_calls
+	err := r.db.WithContext(ctx).
+		Model(&models.ToolCall{}).
+		Select(`tool_name, COUNT(*) AS total_calls,
+			SUM(CASE WHEN success THEN 0 ELSE 1 END) AS failed_calls,
+			AVG(duration) AS avg_duration, MAX(created_at) AS last_used`).
+		Where("created_at >= ?", since).
+		Group("tool_name").
+		Order("total_calls DESC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tools := make([]models.ToolUsageStats, len(rows))
+	for i, row := range rows {
+		tools[i] = models.ToolUsageStats{
+			ToolName:        row.ToolName,
+			TotalCalls:      row.TotalCalls,
+			SuccessfulCalls: row.TotalCalls - row.FailedCalls,
+			FailedCalls:     row.FailedCalls,
+			AvgDuration:     row.AvgDuration,
+		}
+		// Aggregates lose the column type, so the timestamp comes back as text
+		if lastUsed, err := time.Parse(timestampFormat, row.LastUsed); err == nil {
+			tools[i].LastUsed = lastUsed
+		}
+	}
+	return tools, nil
+}
+
+func (r *statsRepository) ToolDurations(ctx context.Context, since time.Time) ([]models.ToolDuration, error) {
+	var durations []models.ToolDuration
+	err := r.db.WithContext(ctx).
+		Model(&models.ToolCall{}).
+		Select("tool_name, duration AS duration_ms").
+		Where("created_at >= ?", since).
+		Scan(&durations).Error
+	return durations, err
+}
+
+func (r *statsRepository) ChatLatencies(ctx context.Context, since time.Time) ([]int64, error) {
+	var latencies []int64
+	err := r.db.WithContext(ctx).
+		Model(&models.Message{}).
+		Where("role = ? AND created_at >= ?", "assistant", since).
+		Where("json_extract(CAST(metadata AS TEXT), '$.latency_ms') IS NOT NULL").
+		Pluck("json_extract(CAST(metadata AS TEXT), '$.latency_ms')", &latencies).Error
+	return latencies, err
+}
+
+// filterScope restricts a query on table to the rows filter selects
+func filterScope(query *gorm.DB, filter models.CostFilter, table, timeColumn string) *gorm.DB {
+	query = query.Where(table+"."+timeColumn+" >= ?", filter.Since)
+	if filter.SessionID != "" {
+		query = query.Where(table+".session_id = ?", filter.SessionID)
+	}
+	if filter.AgentID != "" {
+		query = query.Joins("JOIN chat_sessions ON chat_sessions.id = "+table+".session_id").
+			Where("chat_sessions.agent_id = ?", filter.AgentID)
+	}
+	return query
+}
+
+func (r *statsRepository) Costs(ctx context.Context, filter models.CostFilter) (*models.CostSummary, error) {
+	var summary models.CostSummary
+	// Assistant replies carry their token usage and cost in the metadata blob
+	err := filterScope(r.db.WithContext(ctx).Table("messages"), filter, "messages", "created_at").
+		Select(`COALESCE(SUM(json_extract(CAST(messages.metadata AS TEXT), '$.usage.prompt_tokens')), 0) AS prompt_tokens,
+			COALESCE(SUM(json_extract(CAST(messages.metadata AS TEXT), '$.usage.completion_tokens')), 0) AS completion_tokens,
+			COALESCE(SUM(json_extract(CAST(messages.metadata AS TEXT), '$.cost')), 0) AS llm_cost`).
+		Where("messages.role = ?", "assistant").
+		Scan(&summary).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = filterScope(r.db.WithContext(ctx).Table("tool_execution_logs"), filter, "tool_execution_logs", "executed_at").
+		Select("COALESCE(SUM(tool_execution_logs.cost), 0)").
+		Scan(&summary.ToolCost).Error
+	if err != nil {
+		return nil, err
+	}
+
+	summary.TotalCost = summary.LLMCost + summary.ToolCost
+	return &summary, nil
+}
+
+func (r *statsRepository) PromptTokens(ctx context.Context, filter models.CostFilter) (*models.PromptTokenStats, error) {
+	var row struct {
+		Replies      int64
+		SystemPrompt int64
+		ToolSchemas  int64
+		History      int64
+		UserMessage  int64
+		Total        int64
+	}
+	// Assistant replies carry the breakdown of their prompt in the metadata blob
+	err := filterScope(r.db.WithContext(ctx).Table("messages"), filter, "messages", "created_at").
+		Select(`COUNT(*) AS replies,
+			COALESCE(SUM(json_extract(CAST(messages.metadata AS TEXT), '$.prompt_breakdown.system_prompt')), 0) AS system_prompt,
+			COALESCE(SUM(json_extract(CAST(messages.metadata AS TEXT), '$.prompt_breakdown.tool_schemas')), 0) AS tool_schemas,
+			COALESCE(SUM(json_extract(CAST(messages.metadata AS TEXT), '$.prompt_breakdown.history')), 0) AS history,
+			COALESCE(SUM(json_extract(CAST(messages.metadata AS TEXT), '$.prompt_breakdown.user_message')), 0) AS user_message,
+			COALESCE(SUM(json_extract(CAST(messages.metadata AS TEXT), '$.prompt_breakdown.total')), 0) AS total`).
+		Where("messages.role = ?", "assistant").
+		Where("json_extract(CAST(messages.metadata AS TEXT), '$.prompt_breakdown') IS NOT NULL").
+		Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.PromptTokenStats{
+		Replies: row.Replies,
+		Total: models.PromptBreakdown{
+			SystemPrompt: row.SystemPrompt,
+			ToolSchemas:  row.ToolSchemas,
+			History:      row.History,
+			UserMessage:  row.UserMessage,
+			Total:        row.Total,
+		},
+	}
+	if row.Replies > 0 {
+		stats.PerReply = models.PromptBreakdown{
+			SystemPrompt: row.SystemPrompt / row.Replies,
+			ToolSchemas:  row.ToolSchemas / row.Replies,
+			History:      row.History / row.Replies,
+			UserMessage:  row.UserMessage / row.Replies,
+			Total:        row.Total / row.Replies,
+		}
+	}
+	return stats, nil
+}
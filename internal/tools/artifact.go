@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ErrArtifactNotFound is returned for artifacts that do not exist or belong
+// to another session
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// Artifact is a binary file a tool returns, such as an image, a CSV export
+// or a PDF. The tool service keeps artifacts in media storage and passes the
+// LLM references to them, whose IDs later calls of the session can use.
+type Artifact struct {
+	ID          string `json:"id,omitempty"` // assigned when the artifact is stored
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url,omitempty"` // time-limited download URL
+
+	// Data is the content; it is not kept in results once stored
+	Data []byte `json:"-"`
+}
+
+// NewArtifact returns an artifact of data. An empty content type is inferred
+// from the name, or else from the data.
+func NewArtifact(name, contentType string, data []byte) Artifact {
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(name))
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return Artifact{Name: name, ContentType: contentType, Size: int64(len(data)), Data: data}
+}
+
+// ArtifactStore opens the artifacts returned by earlier tool calls of a
+// session
+type ArtifactStore interface {
+	// Open returns an artifact with its data, or ErrArtifactNotFound
+	Open(ctx context.Context, id string) (*Artifact, error)
+}
+
+// OpenArtifact opens an artifact of the session by ID
+func (ctx ExecutionContext) OpenArtifact(id string) (*Artifact, error) {
+	if ctx.Artifacts == nil {
+		return nil, fmt.Errorf("%w: %s: artifacts are not kept", ErrArtifactNotFound, id)
+	}
+	return ctx.Artifacts.Open(ctx.Context, id)
+}
+
+// IsBinaryContent reports whether content is binary rather than text an LLM
+// can read, judged by its media type or, without one, by sniffing data
+func IsBinaryContent(contentType string, data []byte) bool {
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return false
+	}
+	if strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return false
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/ecmascript",
+		"application/x-www-form-urlencoded", "application/yaml", "application/x-yaml", "application/graphql":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"agent-server/internal/redact"
+)
+
+// HTTPExchange records one outbound HTTP request made by a tool. Redirects
+// are recorded as separate exchanges, so the last one carries the final URL.
+type HTTPExchange struct {
+	Method         string            `json:"method"`
+	URL            string            `json:"url"`
+	StatusCode     int               `json:"status_code,omitempty"`
+	Duration       int64             `json:"duration_ms"`
+	RequestHeaders map[string]string `json:"request_headers,omitempty"`
+	Error          string            `json:"error,omitempty"`
+}
+
+// HTTPAudit collects the HTTP exchanges made while a tool executes
+type HTTPAudit struct {
+	redactor  *redact.Redactor
+	mu        sync.Mutex
+	exchanges []HTTPExchange
+}
+
+type httpAuditKey struct{}
+
+// WithHTTPAudit returns a context that records the HTTP exchanges of tools
+// using an audit transport into the returned audit, masking credentials with r
+func WithHTTPAudit(ctx context.Context, r *redact.Redactor) (context.Context, *HTTPAudit) {
+	audit := &HTTPAudit{redactor: r}
+	return context.WithValue(ctx, httpAuditKey{}, audit), audit
+}
+
+// HTTPAuditFromContext returns the audit attached to ctx, or nil
+func HTTPAuditFromContext(ctx context.Context) *HTTPAudit {
+	audit, _ := ctx.Value(httpAuditKey{}).(*HTTPAudit)
+	return audit
+}
+
+// Exchanges returns the recorded exchanges in request order
+func (a *HTTPAudit) Exchanges() []HTTPExchange {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]HTTPExchange(nil), a.exchanges...)
+}
+
+func (a *HTTPAudit) record(exchange HTTPExchange) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.exchanges = append(a.exchanges, exchange)
+}
+
+// AuditTransport records sanitized details of each request into the
+// HTTPAudit of the request context. Requests without one pass through.
+type AuditTransport struct {
+	Base http.RoundTripper
+}
+
+// NewAuditTransport wraps base, or http.DefaultTransport when base is nil
+func NewAuditTransport(base http.RoundTripper) *AuditTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &AuditTransport{Base: base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *AuditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	audit := HTTPAuditFromContext(req.Context())
+	if audit == nil {
+		return t.Base.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.Base.RoundTrip(req)
+
+	exchange := HTTPExchange{
+		Method:         req.Method,
+		URL:            RedactURL(req.URL, audit.redactor),
+		Duration:       time.Since(start).Milliseconds(),
+		RequestHeaders: RedactHeaders(req.Header, audit.redactor),
+	}
+	if err != nil {
+		exchange.Error = err.Error()
+	} else {
+		exchange.StatusCode = resp.StatusCode
+	}
+	audit.record(exchange)
+
+	return resp, err
+}
+
+// RedactHeaders flattens headers and masks the values of credential headers
+// such as Authorization and Cookie. URLs in Referer headers are redacted like
+// request URLs.
+func RedactHeaders(header http.Header, r *redact.Redactor) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+	redacted := make(map[string]string, len(header))
+	for name, values := range header {
+		switch {
+		case r.IsSensitiveKey(name):
+			redacted[name] = redact.Mask
+		case http.CanonicalHeaderKey(name) == "Referer":
+			// Redirects copy the previous URL, credentials included
+			if u, err := url.Parse(strings.Join(values, ", ")); err == nil {
+				redacted[name] = RedactURL(u, r)
+			} else {
+				redacted[name] = redact.Mask
+			}
+		default:
+			redacted[name] = r.String(strings.Join(values, ", "))
+		}
+	}
+	return redacted
+}
+
+// RedactURL renders u without its password and with credential query
+// parameters masked
+func RedactURL(u *url.URL, r *redact.Redactor) string {
+	if u == nil {
+		return ""
+	}
+	clean := *u
+	if clean.User != nil {
+		clean.User = url.User(clean.User.Username())
+	}
+	if clean.RawQuery != "" {
+		query := clean.Query()
+		for name := range query {
+			if r.IsSensitiveKey(name) {
+				query.Set(name, redact.Mask)
+			}
+		}
+		clean.RawQuery = query.Encode()
+	}
+	return clean.String()
+}
@@ -2,6 +2,9 @@ package tools
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
 	"time"
 )
 
@@ -45,12 +48,7 @@ func (bt *BaseTool) Execute(ctx ExecutionContext, input map[string]interface{})
 	// Sanitize input
 	sanitized, err := SanitizeInput(bt.schema, input)
 	if err != nil {
-		return &Result{
-			Success:   false,
-			Error:     err.Error(),
-			ErrorCode: "VALIDATION_ERROR",
-			Duration:  0,
-		}
+		return ValidationErrorResult(err)
 	}
 	
 	// Check for context cancellation
@@ -74,15 +72,9 @@ func (bt *BaseTool) Execute(ctx ExecutionContext, input map[string]interface{})
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				done <- &Result{
-					Success:   false,
-					Error:     "tool execution panicked",
-					ErrorCode: "PANIC",
-					Duration:  time.Since(start),
-					Metadata: map[string]interface{}{
-						"panic": r,
-					},
-				}
+				result := PanicResult(r, debug.Stack())
+				result.Duration = time.Since(start)
+				done <- result
 			}
 		}()
 		
@@ -201,11 +193,32 @@ func ErrorResult(errorCode, message string, metadata ...map[string]interface{})
 	return result
 }
 
-// ValidationErrorResult creates a validation error result
+// ToolDisabledErrorCode is the error code of calls of tools disabled by
+// policy
+const ToolDisabledErrorCode = "TOOL_DISABLED"
+
+// DisabledResult creates the result of a call of a tool disabled by policy
+func DisabledResult(toolName string) *Result {
+	return ErrorResult(ToolDisabledErrorCode, fmt.Sprintf("Tool '%s' is disabled by policy on this server", toolName))
+}
+
+// ValidationErrorResult creates a validation error result. For a
+// *ValidationError the offending parameter and reason are kept in the
+// metadata so callers can report them per field.
 func ValidationErrorResult(err error) *Result {
-	return &Result{
+	result := &Result{
 		Success:   false,
 		Error:     err.Error(),
 		ErrorCode: "VALIDATION_ERROR",
 	}
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		result.Metadata = map[string]interface{}{
+			"parameter": validationErr.Parameter,
+			"reason":    validationErr.Message,
+		}
+	}
+
+	return result
 }
\ No newline at end of file
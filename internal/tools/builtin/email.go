@@ -0,0 +1,127 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"agent-server/internal/mail"
+	"agent-server/internal/tools"
+)
+
+// EmailSender delivers outgoing email
+type EmailSender interface {
+	Send(ctx context.Context, msg *mail.Message) error
+}
+
+// SendEmailTool sends plain-text email through the configured SMTP server
+type SendEmailTool struct {
+	*tools.BaseTool
+	sender  EmailSender
+	allowed []string
+}
+
+// NewSendEmailTool creates a send_email tool. allowedRecipients lists the
+// addresses and "@domain" entries the tool may write to; empty allows any.
+func NewSendEmailTool(sender EmailSender, allowedRecipients []string) *SendEmailTool {
+	schema := tools.Schema{
+		Name:        "send_email",
+		Description: "Sends a plain-text email",
+		Parameters: []tools.Parameter{
+			{
+				Name:        "to",
+				Type:        "string",
+				Description: "Recipient addresses, separated by commas",
+				Required:    true,
+			},
+			{
+				Name:        "subject",
+				Type:        "string",
+				Description: "Subject line",
+				Required:    true,
+			},
+			{
+				Name:        "body",
+				Type:        "string",
+				Description: "Plain-text message body",
+				Required:    true,
+			},
+			{
+				Name:        "cc",
+				Type:        "string",
+				Description: "Copy recipient addresses, separated by commas",
+				Required:    false,
+			},
+			{
+				Name:        "in_reply_to",
+				Type:        "string",
+				Description: "Message-ID of the email this one answers, e.g. <id@example.com>, to keep the thread together",
+				Required:    false,
+			},
+		},
+		Examples: []tools.Example{
+			{
+				Description: "Send a short note",
+				Input: map[string]interface{}{
+					"to":      "alice@example.com",
+					"subject": "Meeting notes",
+					"body":    "Hi Alice,\n\nhere are the notes from today.",
+				},
+				Output: map[string]interface{}{
+					"message_id": "<2f1c...@example.com>",
+					"recipients": []string{"alice@example.com"},
+				},
+			},
+		},
+	}
+
+	tool := &SendEmailTool{sender: sender, allowed: allowedRecipients}
+	tool.BaseTool = tools.NewBaseTool("send_email", schema, tool.execute)
+
+	return tool
+}
+
+func (t *SendEmailTool) execute(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
+	to, _ := input["to"].(string)
+	cc, _ := input["cc"].(string)
+	subject, _ := input["subject"].(string)
+	body, _ := input["body"].(string)
+	inReplyTo, _ := input["in_reply_to"].(string)
+
+	recipients, err := mail.ParseAddressList([]string{to, cc})
+	if err != nil {
+		return tools.ErrorResult("INVALID_RECIPIENT", err.Error())
+	}
+	if len(recipients) == 0 {
+		return tools.ErrorResult("INVALID_RECIPIENT", "at least one recipient is required")
+	}
+	for _, recipient := range recipients {
+		if !mail.MatchAddress(recipient, t.allowed) {
+			return tools.ErrorResult("RECIPIENT_NOT_ALLOWED", fmt.Sprintf("sending email to %s is not allowed", recipient))
+		}
+	}
+	if strings.ContainsAny(subject, "\r\n") || strings.ContainsAny(inReplyTo, "\r\n") {
+		return tools.ErrorResult("INVALID_INPUT", "subject and in_reply_to must be a single line")
+	}
+
+	msg := &mail.Message{
+		To:        []string{to},
+		Subject:   subject,
+		Body:      body,
+		InReplyTo: strings.TrimSpace(inReplyTo),
+	}
+	if cc != "" {
+		msg.Cc = []string{cc}
+	}
+	if msg.InReplyTo != "" {
+		msg.References = []string{msg.InReplyTo}
+	}
+	if err := t.sender.Send(ctx.Context, msg); err != nil {
+		return tools.ErrorResult("SEND_FAILED", fmt.Sprintf("Failed to send email: %v", err))
+	}
+
+	return tools.SuccessResult(map[string]interface{}{
+		"message_id": msg.MessageID,
+		"recipients": recipients,
+	})
+}
@@ -0,0 +1,50 @@
+package builtin
+
+import (
+	"context"
+	"testing"
+
+	"agent-server/internal/mail"
+	"agent-server/internal/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSender struct {
+	sent []*mail.Message
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg *mail.Message) error {
+	msg.MessageID = "<sent-1@example.org>"
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestSendEmailTool(t *testing.T) {
+	sender := &recordingSender{}
+	tool := NewSendEmailTool(sender, []string{"@example.com", "boss@corp.test"})
+	ctx := tools.ExecutionContext{Context: context.Background()}
+
+	result := tool.Execute(ctx, map[string]interface{}{
+		"to":          "Alice <alice@example.com>",
+		"cc":          "boss@corp.test",
+		"subject":     "Notes",
+		"body":        "Here they are.",
+		"in_reply_to": "<q1@example.com>",
+	})
+	require.True(t, result.Success, result.Error)
+	data := result.Data.(map[string]interface{})
+	assert.Equal(t, "<sent-1@example.org>", data["message_id"])
+	assert.Equal(t, []string{"alice@example.com", "boss@corp.test"}, data["recipients"])
+	require.Len(t, sender.sent, 1)
+	assert.Equal(t, []string{"<q1@example.com>"}, sender.sent[0].References)
+
+	result = tool.Execute(ctx, map[string]interface{}{"to": "eve@other.test", "subject": "Hi", "body": "Hi"})
+	assert.False(t, result.Success)
+	assert.Equal(t, "RECIPIENT_NOT_ALLOWED", result.ErrorCode)
+
+	result = tool.Execute(ctx, map[string]interface{}{"to": "alice@example.com", "subject": "Hi\r\nBcc: eve@other.test", "body": "Hi"})
+	assert.False(t, result.Success)
+	assert.Len(t, sender.sent, 1)
+}
@@ -0,0 +1,214 @@
+package builtin
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"agent-server/internal/tools"
+
+	"github.com/google/uuid"
+)
+
+// EncoderTool provides encoding, hashing and identifier operations
+type EncoderTool struct {
+	*tools.BaseTool
+}
+
+// NewEncoderTool creates a new encoder tool
+func NewEncoderTool() *EncoderTool {
+	schema := tools.Schema{
+		Name:        "encoder",
+		Description: "Encodes, decodes and hashes text: base64, URL and hex encoding, SHA-256 and MD5 hashes, JWT decoding and UUID generation",
+		Parameters: []tools.Parameter{
+			{
+				Name:        "operation",
+				Type:        "string",
+				Description: "Operation to perform",
+				Required:    true,
+				Enum: []string{
+					"base64_encode", "base64_decode", "url_encode", "url_decode",
+					"hex_encode", "hex_decode", "sha256", "md5", "jwt_decode", "uuid",
+				},
+			},
+			{
+				Name:        "input",
+				Type:        "string",
+				Description: "Text to process; not needed for uuid",
+				Required:    false,
+			},
+		},
+		Examples: []tools.Example{
+			{
+				Description: "Base64 encode text",
+				Input: map[string]interface{}{
+					"operation": "base64_encode",
+					"input":     "hello",
+				},
+				Output: map[string]interface{}{
+					"result":    "aGVsbG8=",
+					"operation": "base64_encode",
+				},
+			},
+			{
+				Description: "Hash text with SHA-256",
+				Input: map[string]interface{}{
+					"operation": "sha256",
+					"input":     "hello",
+				},
+				Output: map[string]interface{}{
+					"result":    "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+					"operation": "sha256",
+				},
+			},
+		},
+	}
+
+	tool := &EncoderTool{}
+	tool.BaseTool = tools.NewBaseTool("encoder", schema, tool.execute)
+
+	return tool
+}
+
+func (e *EncoderTool) execute(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
+	operation, _ := input["operation"].(string)
+	text, hasInput := input["input"].(string)
+	if !hasInput && operation != "uuid" {
+		return tools.ErrorResult("MISSING_INPUT", fmt.Sprintf("input is required for %s operation", operation))
+	}
+
+	var result interface{}
+	var err error
+
+	switch operation {
+	case "base64_encode":
+		result = base64.StdEncoding.EncodeToString([]byte(text))
+	case "base64_decode":
+		var decoded []byte
+		decoded, err = decodeBase64(text)
+		if err == nil {
+			return decodedResult(operation, decoded)
+		}
+	case "url_encode":
+		result = url.QueryEscape(text)
+	case "url_decode":
+		result, err = url.QueryUnescape(text)
+	case "hex_encode":
+		result = hex.EncodeToString([]byte(text))
+	case "hex_decode":
+		var decoded []byte
+		decoded, err = hex.DecodeString(strings.TrimSpace(text))
+		if err == nil {
+			return decodedResult(operation, decoded)
+		}
+	case "sha256":
+		sum := sha256.Sum256([]byte(text))
+		result = hex.EncodeToString(sum[:])
+	case "md5":
+		sum := md5.Sum([]byte(text))
+		result = hex.EncodeToString(sum[:])
+	case "jwt_decode":
+		result, err = decodeJWT(text)
+	case "uuid":
+		result = uuid.NewString()
+	default:
+		return tools.ErrorResult("INVALID_OPERATION", fmt.Sprintf("Unknown operation: %s", operation))
+	}
+
+	if err != nil {
+		return tools.ErrorResult("ENCODING_ERROR", fmt.Sprintf("Failed to %s: %v", strings.ReplaceAll(operation, "_", " "), err))
+	}
+
+	return tools.SuccessResult(map[string]interface{}{
+		"result":    result,
+		"operation": operation,
+	})
+}
+
+// decodedResult returns decoded bytes as text, or as hex when they are not
+// valid UTF-8
+func decodedResult(operation string, decoded []byte) *tools.Result {
+	if utf8.Valid(decoded) {
+		return tools.SuccessResult(map[string]interface{}{
+			"result":    string(decoded),
+			"operation": operation,
+			"encoding":  "utf-8",
+		})
+	}
+	return tools.SuccessResult(map[string]interface{}{
+		"result":    hex.EncodeToString(decoded),
+		"operation": operation,
+		"encoding":  "hex",
+	})
+}
+
+// decodeBase64 accepts standard and URL-safe base64, with or without padding
+func decodeBase64(text string) ([]byte, error) {
+	text = strings.TrimSpace(text)
+	encodings := []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding,
+		base64.URLEncoding, base64.RawURLEncoding,
+	}
+	var err error
+	for _, encoding := range encodings {
+		var decoded []byte
+		if decoded, err = encoding.DecodeString(text); err == nil {
+			return decoded, nil
+		}
+	}
+	return nil, err
+}
+
+// decodeJWT decodes the header and claims of a JWT without verifying its
+// signature
+func decodeJWT(token string) (map[string]interface{}, error) {
+	token = strings.TrimPrefix(strings.TrimSpace(token), "Bearer ")
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token must have 3 dot-separated parts, got %d", len(parts))
+	}
+
+	decodePart := func(name, part string) (map[string]interface{}, error) {
+		data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(part, "="))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s encoding: %v", name, err)
+		}
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return nil, fmt.Errorf("invalid %s JSON: %v", name, err)
+		}
+		return decoded, nil
+	}
+
+	header, err := decodePart("header", parts[0])
+	if err != nil {
+		return nil, err
+	}
+	payload, err := decodePart("payload", parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := map[string]interface{}{
+		"header":        header,
+		"payload":       payload,
+		"has_signature": parts[2] != "",
+		"verified":      false,
+	}
+	if exp, ok := payload["exp"].(float64); ok {
+		expiresAt := time.Unix(int64(exp), 0).UTC()
+		decoded["expires_at"] = expiresAt.Format(time.RFC3339)
+		decoded["expired"] = time.Now().After(expiresAt)
+	}
+	if iat, ok := payload["iat"].(float64); ok {
+		decoded["issued_at"] = time.Unix(int64(iat), 0).UTC().Format(time.RFC3339)
+	}
+	return decoded, nil
+}
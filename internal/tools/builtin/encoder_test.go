@@ -0,0 +1,105 @@
+package builtin_test
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"agent-server/internal/tools"
+	"agent-server/internal/tools/builtin"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoderTool(t *testing.T) {
+	encoder := builtin.NewEncoderTool()
+	ctx := tools.ExecutionContext{
+		Context:   context.Background(),
+		SessionID: "test-session",
+	}
+
+	run := func(t *testing.T, operation, input string) map[string]interface{} {
+		result := encoder.Execute(ctx, map[string]interface{}{
+			"operation": operation,
+			"input":     input,
+		})
+		require.True(t, result.Success, result.Error)
+		return result.Data.(map[string]interface{})
+	}
+
+	t.Run("Tool Metadata", func(t *testing.T) {
+		assert.Equal(t, "encoder", encoder.Name())
+
+		schema := encoder.Schema()
+		assert.Len(t, schema.Parameters, 2)
+		assert.Len(t, schema.Parameters[0].Enum, 10)
+	})
+
+	t.Run("Round Trips", func(t *testing.T) {
+		cases := []struct {
+			encode, decode, input, encoded string
+		}{
+			{"base64_encode", "base64_decode", "hello world", "aGVsbG8gd29ybGQ="},
+			{"url_encode", "url_decode", "a b&c=d", "a+b%26c%3Dd"},
+			{"hex_encode", "hex_decode", "hi", "6869"},
+		}
+		for _, tc := range cases {
+			assert.Equal(t, tc.encoded, run(t, tc.encode, tc.input)["result"], tc.encode)
+			assert.Equal(t, tc.input, run(t, tc.decode, tc.encoded)["result"], tc.decode)
+		}
+	})
+
+	t.Run("Base64 Decode Variants", func(t *testing.T) {
+		// URL-safe alphabet without padding
+		assert.Equal(t, "??>", run(t, "base64_decode", "Pz8-")["result"])
+		assert.Equal(t, "hello world", run(t, "base64_decode", "aGVsbG8gd29ybGQ")["result"])
+
+		// Binary data is returned as hex
+		data := run(t, "base64_decode", base64.StdEncoding.EncodeToString([]byte{0xff, 0x00}))
+		assert.Equal(t, "ff00", data["result"])
+		assert.Equal(t, "hex", data["encoding"])
+	})
+
+	t.Run("Hashes", func(t *testing.T) {
+		assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", run(t, "sha256", "hello")["result"])
+		assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", run(t, "md5", "hello")["result"])
+	})
+
+	t.Run("JWT Decode", func(t *testing.T) {
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+		payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"42","exp":1000}`))
+		token := header + "." + payload + ".c2lnbmF0dXJl"
+
+		decoded := run(t, "jwt_decode", "Bearer "+token)["result"].(map[string]interface{})
+		assert.Equal(t, "HS256", decoded["header"].(map[string]interface{})["alg"])
+		assert.Equal(t, "42", decoded["payload"].(map[string]interface{})["sub"])
+		assert.Equal(t, false, decoded["verified"])
+		assert.Equal(t, true, decoded["expired"])
+		assert.Equal(t, "1970-01-01T00:16:40Z", decoded["expires_at"])
+
+		result := encoder.Execute(ctx, map[string]interface{}{
+			"operation": "jwt_decode",
+			"input":     "not-a-token",
+		})
+		assert.False(t, result.Success)
+		assert.Equal(t, "ENCODING_ERROR", result.ErrorCode)
+	})
+
+	t.Run("UUID", func(t *testing.T) {
+		result := encoder.Execute(ctx, map[string]interface{}{"operation": "uuid"})
+		require.True(t, result.Success)
+
+		id, ok := result.Data.(map[string]interface{})["result"].(string)
+		require.True(t, ok)
+		_, err := uuid.Parse(id)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Missing Input", func(t *testing.T) {
+		result := encoder.Execute(ctx, map[string]interface{}{"operation": "sha256"})
+		assert.False(t, result.Success)
+		assert.Equal(t, "MISSING_INPUT", result.ErrorCode)
+	})
+}
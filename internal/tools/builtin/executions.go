@@ -0,0 +1,97 @@
+package builtin
+
+import (
+	"fmt"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage"
+	"agent-server/internal/tools"
+)
+
+// CheckExecutionTool reports the status and result of a long-running tool
+// call that runs in the background or of a call held for approval
+type CheckExecutionTool struct {
+	*tools.BaseTool
+	executions storage.ToolExecutionRepository
+}
+
+// NewCheckExecutionTool creates a new check_execution tool
+func NewCheckExecutionTool(executions storage.ToolExecutionRepository) *CheckExecutionTool {
+	schema := tools.Schema{
+		Name:        "check_execution",
+		Description: "Check the status of a long-running tool call started in the background, or of a call awaiting approval, and get its result once it has finished",
+		Parameters: []tools.Parameter{
+			{
+				Name:        "execution_id",
+				Type:        "string",
+				Description: "The execution_id returned when the tool call was started",
+				Required:    true,
+			},
+		},
+		Examples: []tools.Example{
+			{
+				Description: "Check a background web scrape",
+				Input: map[string]interface{}{
+					"execution_id": "abc-123",
+				},
+				Output: map[string]interface{}{
+					"execution_id": "abc-123",
+					"tool_name":    "web_scraper",
+					"status":       "succeeded",
+					"result":       map[string]interface{}{},
+				},
+			},
+		},
+	}
+
+	tool := &CheckExecutionTool{executions: executions}
+	tool.BaseTool = tools.NewBaseTool("check_execution", schema, tool.execute)
+
+	return tool
+}
+
+func (t *CheckExecutionTool) execute(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
+	executionID, ok := input["execution_id"].(string)
+	if !ok || executionID == "" {
+		return tools.ErrorResult("MISSING_EXECUTION_ID", "execution_id is required")
+	}
+
+	execution, err := t.executions.GetByID(ctx.Context, executionID)
+	if err != nil {
+		return tools.ErrorResult("DATABASE_ERROR", fmt.Sprintf("Failed to get execution: %v", err))
+	}
+	// Executions of other sessions are not visible
+	if execution == nil || execution.SessionID != ctx.SessionID {
+		return tools.ErrorResult("EXECUTION_NOT_FOUND", fmt.Sprintf("Execution '%s' not found", executionID))
+	}
+
+	status := map[string]interface{}{
+		"execution_id": execution.ID,
+		"tool_name":    execution.ToolName,
+		"status":       execution.Status,
+	}
+
+	switch execution.Status {
+	case models.ToolExecutionSucceeded:
+		if execution.Result != nil {
+			status["result"] = (*execution.Result)["data"]
+			if artifacts, ok := (*execution.Result)["artifacts"]; ok {
+				status["artifacts"] = artifacts
+			}
+		}
+		status["duration_ms"] = execution.Duration
+	case models.ToolExecutionFailed:
+		status["error"] = execution.Error
+		status["error_code"] = execution.ErrorCode
+		status["duration_ms"] = execution.Duration
+	case models.ToolExecutionAwaitingApproval:
+		status["message"] = "The call is waiting for a person's approval; check again later"
+	case models.ToolExecutionRejected:
+		status["error"] = execution.Error
+		status["error_code"] = execution.ErrorCode
+	default:
+		status["message"] = "The tool is still running; check again later"
+	}
+
+	return tools.SuccessResult(status)
+}
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
+	"path"
 	"strings"
 	"time"
 
@@ -68,9 +70,7 @@ func NewHTTPGetTool() *HTTPGetTool {
 
 	tool := &HTTPGetTool{
 		HTTPBaseTool: tools.NewHTTPBaseTool("http_get", schema, "", 30*time.Second),
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		client: tools.NewHTTPClient(30 * time.Second),
 	}
 
 	// Set the execute function
@@ -79,9 +79,22 @@ func NewHTTPGetTool() *HTTPGetTool {
 	return tool
 }
 
+// SetTransport routes the tool's requests through base, keeping audit capture
+func (h *HTTPGetTool) SetTransport(base http.RoundTripper) {
+	h.client.Transport = tools.NewAuditTransport(base)
+}
+
+// SetRedirectPolicy sets how the tool's requests follow redirects
+func (h *HTTPGetTool) SetRedirectPolicy(policy tools.RedirectPolicy) {
+	h.client.CheckRedirect = policy.CheckRedirect
+}
+
 func (h *HTTPGetTool) execute(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
 	// Extract parameters
-	urlStr := input["url"].(string)
+	urlStr, ok := input["url"].(string)
+	if !ok || urlStr == "" {
+		return tools.ErrorResult("MISSING_URL", "url is required")
+	}
 	
 	// Validate URL
 	if _, err := url.Parse(urlStr); err != nil {
@@ -127,9 +140,31 @@ func (h *HTTPGetTool) execute(ctx tools.ExecutionContext, input map[string]inter
 		responseHeaders[key] = strings.Join(values, ", ")
 	}
 
+	// Binary content, such as images or PDFs, is returned as an artifact
+	contentType := resp.Header.Get("Content-Type")
+	if tools.IsBinaryContent(contentType, body) {
+		artifact := tools.NewArtifact(artifactName(resp.Request.URL, contentType), contentType, body)
+		data := fmt.Sprintf("binary content (%s, %d bytes) returned as artifact %s", artifact.ContentType, len(body), artifact.Name)
+		if ctx.Artifacts == nil {
+			data = fmt.Sprintf("binary content (%s, %d bytes) omitted: artifacts are not kept", artifact.ContentType, len(body))
+		}
+		result := tools.SuccessResult(map[string]interface{}{
+			"status_code":  resp.StatusCode,
+			"data":         data,
+			"headers":      responseHeaders,
+			"content_type": contentType,
+		}, map[string]interface{}{
+			"url":           urlStr,
+			"response_size": len(body),
+		})
+		if ctx.Artifacts != nil {
+			result.Artifacts = []tools.Artifact{artifact}
+		}
+		return result
+	}
+
 	// Try to parse JSON if content type suggests it
 	var responseData interface{}
-	contentType := resp.Header.Get("Content-Type")
 	if strings.Contains(contentType, "application/json") {
 		var jsonData interface{}
 		if err := json.Unmarshal(body, &jsonData); err == nil {
@@ -152,6 +187,18 @@ func (h *HTTPGetTool) execute(ctx tools.ExecutionContext, input map[string]inter
 	})
 }
 
+// artifactName names an artifact downloaded from a URL after the last
+// segment of its path, or else after its content type
+func artifactName(u *url.URL, contentType string) string {
+	if name := path.Base(u.Path); name != "." && name != "/" {
+		return name
+	}
+	if extensions, _ := mime.ExtensionsByType(contentType); len(extensions) > 0 {
+		return "download" + extensions[0]
+	}
+	return "download"
+}
+
 // HTTPPostTool provides HTTP POST functionality
 type HTTPPostTool struct {
 	*tools.HTTPBaseTool
@@ -190,6 +237,12 @@ func NewHTTPPostTool() *HTTPPostTool {
 				Required:    false,
 				Default:     "application/json",
 			},
+			{
+				Name:        "artifact_id",
+				Type:        "string",
+				Description: "ID of an artifact returned by an earlier tool call to send as the body, with its content type, instead of data",
+				Required:    false,
+			},
 			{
 				Name:        "timeout",
 				Type:        "number",
@@ -223,9 +276,7 @@ func NewHTTPPostTool() *HTTPPostTool {
 
 	tool := &HTTPPostTool{
 		HTTPBaseTool: tools.NewHTTPBaseTool("http_post", schema, "", 30*time.Second),
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		client: tools.NewHTTPClient(30 * time.Second),
 	}
 
 	// Set the execute function
@@ -234,9 +285,22 @@ func NewHTTPPostTool() *HTTPPostTool {
 	return tool
 }
 
+// SetTransport routes the tool's requests through base, keeping audit capture
+func (h *HTTPPostTool) SetTransport(base http.RoundTripper) {
+	h.client.Transport = tools.NewAuditTransport(base)
+}
+
+// SetRedirectPolicy sets how the tool's requests follow redirects
+func (h *HTTPPostTool) SetRedirectPolicy(policy tools.RedirectPolicy) {
+	h.client.CheckRedirect = policy.CheckRedirect
+}
+
 func (h *HTTPPostTool) execute(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
 	// Extract parameters
-	urlStr := input["url"].(string)
+	urlStr, ok := input["url"].(string)
+	if !ok || urlStr == "" {
+		return tools.ErrorResult("MISSING_URL", "url is required")
+	}
 	
 	// Validate URL
 	if _, err := url.Parse(urlStr); err != nil {
@@ -251,7 +315,17 @@ func (h *HTTPPostTool) execute(ctx tools.ExecutionContext, input map[string]inte
 		contentType = ctVal
 	}
 
-	if data, ok := input["data"]; ok && data != nil {
+	if artifactID, _ := input["artifact_id"].(string); artifactID != "" {
+		if data, ok := input["data"]; ok && data != nil {
+			return tools.ErrorResult("INVALID_INPUT", "pass either data or artifact_id, not both")
+		}
+		artifact, err := ctx.OpenArtifact(artifactID)
+		if err != nil {
+			return tools.ErrorResult("ARTIFACT_NOT_FOUND", err.Error())
+		}
+		body = bytes.NewReader(artifact.Data)
+		contentType = artifact.ContentType
+	} else if data, ok := input["data"]; ok && data != nil {
 		if contentType == "application/json" {
 			jsonData, err := json.Marshal(data)
 			if err != nil {
@@ -385,9 +459,7 @@ func NewWebScraperTool() *WebScraperTool {
 
 	tool := &WebScraperTool{
 		HTTPBaseTool: tools.NewHTTPBaseTool("web_scraper", schema, "", 30*time.Second),
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		client: tools.NewHTTPClient(30 * time.Second),
 	}
 
 	// Set the execute function
@@ -396,9 +468,22 @@ func NewWebScraperTool() *WebScraperTool {
 	return tool
 }
 
+// SetTransport routes the tool's requests through base, keeping audit capture
+func (w *WebScraperTool) SetTransport(base http.RoundTripper) {
+	w.client.Transport = tools.NewAuditTransport(base)
+}
+
+// SetRedirectPolicy sets how the tool's requests follow redirects
+func (w *WebScraperTool) SetRedirectPolicy(policy tools.RedirectPolicy) {
+	w.client.CheckRedirect = policy.CheckRedirect
+}
+
 func (w *WebScraperTool) execute(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
 	// Extract parameters
-	urlStr := input["url"].(string)
+	urlStr, ok := input["url"].(string)
+	if !ok || urlStr == "" {
+		return tools.ErrorResult("MISSING_URL", "url is required")
+	}
 	
 	// Validate URL
 	if _, err := url.Parse(urlStr); err != nil {
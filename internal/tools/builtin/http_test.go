@@ -2,6 +2,8 @@ package builtin_test
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -217,7 +219,7 @@ func TestHTTPPostTool(t *testing.T) {
 		schema := httpPost.Schema()
 		assert.Equal(t, "http_post", schema.Name)
 		assert.Contains(t, schema.Description, "HTTP POST")
-		assert.Len(t, schema.Parameters, 5)
+		assert.Len(t, schema.Parameters, 6)
 	})
 
 	t.Run("Successful POST with JSON Data", func(t *testing.T) {
@@ -473,4 +475,138 @@ func TestWebScraperTool(t *testing.T) {
 		assert.False(t, result.Success)
 		assert.Contains(t, result.Error, "HTTP request failed")
 	})
-}
\ No newline at end of file
+}
+func TestHTTPTools_RedirectCredentials(t *testing.T) {
+	// The other origin reports the headers it received
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"api_key":       r.Header.Get("X-API-Key"),
+			"authorization": r.Header.Get("Authorization"),
+			"referer":       r.Header.Get("Referer"),
+			"accept":        r.Header.Get("Accept"),
+		})
+	}))
+	defer other.Close()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/away":
+			http.Redirect(w, r, other.URL+"/landing", http.StatusFound)
+		case "/away-post":
+			http.Redirect(w, r, other.URL+"/landing", http.StatusTemporaryRedirect)
+		case "/local":
+			http.Redirect(w, r, "/landing", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"api_key": r.Header.Get("X-API-Key")})
+		}
+	}))
+	defer origin.Close()
+
+	ctx := tools.ExecutionContext{Context: context.Background(), SessionID: "test-session"}
+	headers := map[string]interface{}{
+		"X-API-Key":     "secret",
+		"Authorization": "Bearer secret",
+		"Accept":        "application/json",
+	}
+	received := func(result *tools.Result) map[string]interface{} {
+		require.True(t, result.Success, result.Error)
+		data, _ := result.Data.(map[string]interface{})["data"].(map[string]interface{})
+		return data
+	}
+
+	httpGet := builtin.NewHTTPGetTool()
+
+	// Credentials stay with the origin of the first request
+	data := received(httpGet.Execute(ctx, map[string]interface{}{"url": origin.URL + "/away?token=secret", "headers": headers}))
+	assert.Empty(t, data["api_key"])
+	assert.Empty(t, data["authorization"])
+	assert.Empty(t, data["referer"])
+	assert.Equal(t, "application/json", data["accept"])
+
+	data = received(httpGet.Execute(ctx, map[string]interface{}{"url": origin.URL + "/local", "headers": headers}))
+	assert.Equal(t, "secret", data["api_key"])
+
+	// A body is not resent to another origin; the redirect is returned
+	httpPost := builtin.NewHTTPPostTool()
+	result := httpPost.Execute(ctx, map[string]interface{}{
+		"url":     origin.URL + "/away-post",
+		"data":    map[string]interface{}{"api_key": "secret"},
+		"headers": headers,
+	})
+	require.True(t, result.Success, result.Error)
+	assert.Equal(t, http.StatusTemporaryRedirect, result.Data.(map[string]interface{})["status_code"])
+
+	// Operators can allow forwarding, or stop following redirects
+	httpGet.SetRedirectPolicy(tools.RedirectPolicy{MaxRedirects: 10, CrossOriginCredentials: true})
+	data = received(httpGet.Execute(ctx, map[string]interface{}{"url": origin.URL + "/away", "headers": headers}))
+	assert.Equal(t, "secret", data["api_key"])
+
+	httpGet.SetRedirectPolicy(tools.RedirectPolicy{})
+	result = httpGet.Execute(ctx, map[string]interface{}{"url": origin.URL + "/away", "headers": headers})
+	require.True(t, result.Success, result.Error)
+	assert.Equal(t, http.StatusFound, result.Data.(map[string]interface{})["status_code"])
+}
+
+// memoryArtifacts holds artifacts in memory, as media storage would
+type memoryArtifacts map[string]*tools.Artifact
+
+func (m memoryArtifacts) Open(ctx context.Context, id string) (*tools.Artifact, error) {
+	artifact, ok := m[id]
+	if !ok {
+		return nil, tools.ErrArtifactNotFound
+	}
+	return artifact, nil
+}
+
+func TestHTTPTools_Artifacts(t *testing.T) {
+	pdf := []byte("%PDF-1.7 report")
+	var uploaded []byte
+	var uploadedType string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/reports/q3.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write(pdf)
+		case "/upload":
+			uploaded, _ = io.ReadAll(r.Body)
+			uploadedType = r.Header.Get("Content-Type")
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer testServer.Close()
+
+	store := memoryArtifacts{}
+	ctx := tools.ExecutionContext{Context: context.Background(), SessionID: "s1", Artifacts: store}
+
+	// Binary responses are returned as artifacts instead of text
+	result := builtin.NewHTTPGetTool().Execute(ctx, map[string]interface{}{"url": testServer.URL + "/reports/q3.pdf"})
+	require.True(t, result.Success, result.Error)
+	assert.Contains(t, result.Data.(map[string]interface{})["data"], "returned as artifact q3.pdf")
+	require.Len(t, result.Artifacts, 1)
+	assert.Equal(t, "q3.pdf", result.Artifacts[0].Name)
+	assert.Equal(t, "application/pdf", result.Artifacts[0].ContentType)
+	assert.Equal(t, pdf, result.Artifacts[0].Data)
+
+	// and omitted where artifacts are not kept
+	result = builtin.NewHTTPGetTool().Execute(tools.ExecutionContext{Context: context.Background()},
+		map[string]interface{}{"url": testServer.URL + "/reports/q3.pdf"})
+	require.True(t, result.Success, result.Error)
+	assert.Contains(t, result.Data.(map[string]interface{})["data"], "omitted")
+	assert.Empty(t, result.Artifacts)
+
+	// Artifacts are posted by ID with their content type
+	artifact := tools.NewArtifact("q3.pdf", "", pdf)
+	store["a1"] = &artifact
+	post := builtin.NewHTTPPostTool()
+	result = post.Execute(ctx, map[string]interface{}{"url": testServer.URL + "/upload", "artifact_id": "a1"})
+	require.True(t, result.Success, result.Error)
+	assert.Equal(t, pdf, uploaded)
+	assert.Equal(t, "application/pdf", uploadedType)
+
+	result = post.Execute(ctx, map[string]interface{}{"url": testServer.URL + "/upload", "artifact_id": "a2"})
+	assert.Equal(t, "ARTIFACT_NOT_FOUND", result.ErrorCode)
+	result = post.Execute(ctx, map[string]interface{}{"url": testServer.URL + "/upload", "artifact_id": "a1", "data": map[string]interface{}{}})
+	assert.Equal(t, "INVALID_INPUT", result.ErrorCode)
+}
@@ -0,0 +1,895 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"agent-server/internal/tools"
+)
+
+// Issue tracker backends
+const (
+	TrackerJira   = "jira"
+	TrackerLinear = "linear"
+)
+
+// DefaultLinearURL is the GraphQL endpoint of Linear
+const DefaultLinearURL = "https://api.linear.app/graphql"
+
+// maxTrackerResponse bounds the tracker responses read
+const maxTrackerResponse = 4 << 20
+
+// Issue is an issue as the issue_tracker tool returns it, whatever the backend
+type Issue struct {
+	Key         string         `json:"key"`
+	Title       string         `json:"title"`
+	Status      string         `json:"status,omitempty"`
+	Assignee    string         `json:"assignee,omitempty"`
+	Description string         `json:"description,omitempty"`
+	URL         string         `json:"url,omitempty"`
+	Updated     string         `json:"updated,omitempty"`
+	Comments    []IssueComment `json:"comments,omitempty"`
+}
+
+// IssueComment is a comment on an issue
+type IssueComment struct {
+	Author  string `json:"author,omitempty"`
+	Body    string `json:"body"`
+	Created string `json:"created,omitempty"`
+}
+
+// IssueTracker is a backend of the issue_tracker tool. Keys are issue keys
+// such as ENG-123; project is a Jira project key or a Linear team key.
+type IssueTracker interface {
+	Search(ctx context.Context, query, project string, limit int) ([]Issue, error)
+	Get(ctx context.Context, key string) (*Issue, error)
+	Create(ctx context.Context, project, issueType, title, description string) (*Issue, error)
+	Comment(ctx context.Context, key, body string) error
+	Transition(ctx context.Context, key, state string) (*Issue, error)
+}
+
+// TrackerError is an error response of an issue tracker
+type TrackerError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *TrackerError) Error() string {
+	if e.StatusCode == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("tracker returned %d: %s", e.StatusCode, e.Message)
+}
+
+// errUnknownState is returned for transitions to a state the issue cannot
+// move to
+var errUnknownState = errors.New("unknown state")
+
+// IssueTrackerTool searches, reads, creates, comments on and transitions
+// Jira and Linear issues. The tracker, its URL and the authorization are
+// meant to be set by agent tool presets.
+type IssueTrackerTool struct {
+	*tools.BaseTool
+	client *http.Client
+}
+
+// NewIssueTrackerTool creates a new issue_tracker tool
+func NewIssueTrackerTool() *IssueTrackerTool {
+	schema := tools.Schema{
+		Name:        "issue_tracker",
+		Description: "Searches, reads, creates, comments on and changes the status of issues in Jira or Linear",
+		Parameters: []tools.Parameter{
+			{
+				Name:        "action",
+				Type:        "string",
+				Description: "search, get, create, comment or transition",
+				Required:    true,
+				Enum:        []string{"search", "get", "create", "comment", "transition"},
+			},
+			{
+				Name:        "tracker",
+				Type:        "string",
+				Description: "The issue tracker",
+				Required:    true,
+				Enum:        []string{TrackerJira, TrackerLinear},
+			},
+			{
+				Name:        "base_url",
+				Type:        "string",
+				Description: "The Jira site URL, e.g. https://example.atlassian.net, or the Linear API URL",
+				Required:    false,
+				Pattern:     `^https?://.*`,
+			},
+			{
+				Name:        "authorization",
+				Type:        "string",
+				Description: "Authorization header value for the tracker's API",
+				Required:    true,
+			},
+			{
+				Name:        "project",
+				Type:        "string",
+				Description: "Jira project key or Linear team key, for search and create",
+				Required:    false,
+			},
+			{
+				Name:        "query",
+				Type:        "string",
+				Description: "Search text; Jira also takes JQL",
+				Required:    false,
+			},
+			{
+				Name:        "issue",
+				Type:        "string",
+				Description: "Issue key such as ENG-123, for get, comment and transition",
+				Required:    false,
+			},
+			{
+				Name:        "title",
+				Type:        "string",
+				Description: "Title of the new issue",
+				Required:    false,
+			},
+			{
+				Name:        "description",
+				Type:        "string",
+				Description: "Description of the new issue",
+				Required:    false,
+			},
+			{
+				Name:        "issue_type",
+				Type:        "string",
+				Description: "Jira issue type of the new issue (default: Task)",
+				Required:    false,
+			},
+			{
+				Name:        "comment",
+				Type:        "string",
+				Description: "Text of the comment",
+				Required:    false,
+			},
+			{
+				Name:        "state",
+				Type:        "string",
+				Description: "Status to move the issue to, e.g. In Progress or Done",
+				Required:    false,
+			},
+			{
+				Name:        "limit",
+				Type:        "number",
+				Description: "Maximum number of search results (default: 10)",
+				Required:    false,
+				Minimum:     func() *float64 { v := 1.0; return &v }(),
+				Maximum:     func() *float64 { v := 50.0; return &v }(),
+				Default:     10,
+			},
+		},
+		Examples: []tools.Example{
+			{
+				Description: "Find open login bugs",
+				Input: map[string]interface{}{
+					"action": "search",
+					"query":  "login error",
+				},
+				Output: map[string]interface{}{
+					"issues": []interface{}{
+						map[string]interface{}{"key": "ENG-123", "title": "Login fails with SSO", "status": "In Progress"},
+					},
+					"count": 1,
+				},
+			},
+			{
+				Description: "Comment on an issue",
+				Input: map[string]interface{}{
+					"action":  "comment",
+					"issue":   "ENG-123",
+					"comment": "The customer confirmed the fix.",
+				},
+				Output: map[string]interface{}{
+					"issue":     "ENG-123",
+					"commented": true,
+				},
+			},
+		},
+	}
+
+	tool := &IssueTrackerTool{
+		client: tools.NewHTTPClient(30 * time.Second),
+	}
+	tool.BaseTool = tools.NewBaseTool("issue_tracker", schema, tool.execute)
+
+	return tool
+}
+
+// SetTransport routes the tool's requests through base, keeping audit capture
+func (t *IssueTrackerTool) SetTransport(base http.RoundTripper) {
+	t.client.Transport = tools.NewAuditTransport(base)
+}
+
+// SetRedirectPolicy sets how the tool's requests follow redirects
+func (t *IssueTrackerTool) SetRedirectPolicy(policy tools.RedirectPolicy) {
+	t.client.CheckRedirect = policy.CheckRedirect
+}
+
+// Mutates reports whether the call changes the tracker: create, comment and
+// transition do, search and get do not
+func (t *IssueTrackerTool) Mutates(input map[string]interface{}) bool {
+	action, _ := input["action"].(string)
+	return action != "search" && action != "get"
+}
+
+func (t *IssueTrackerTool) execute(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
+	str := func(name string) string {
+		value, _ := input[name].(string)
+		return strings.TrimSpace(value)
+	}
+	required := func(names ...string) *tools.Result {
+		for _, name := range names {
+			if str(name) == "" {
+				return tools.ErrorResult("MISSING_PARAMETER", fmt.Sprintf("%s is required for %s", name, str("action")))
+			}
+		}
+		return nil
+	}
+
+	var tracker IssueTracker
+	switch str("tracker") {
+	case TrackerJira:
+		if result := required("base_url"); result != nil {
+			return result
+		}
+		tracker = &JiraTracker{client: t.client, baseURL: strings.TrimRight(str("base_url"), "/"), authorization: str("authorization")}
+	case TrackerLinear:
+		apiURL := str("base_url")
+		if apiURL == "" {
+			apiURL = DefaultLinearURL
+		}
+		tracker = &LinearTracker{client: t.client, apiURL: apiURL, authorization: str("authorization")}
+	default:
+		return tools.ErrorResult("INVALID_TRACKER", fmt.Sprintf("Unknown issue tracker '%s'", str("tracker")))
+	}
+
+	var (
+		data interface{}
+		err  error
+	)
+	switch str("action") {
+	case "search":
+		limit := 10
+		if value, ok := input["limit"].(float64); ok && value >= 1 {
+			limit = int(value)
+		}
+		var issues []Issue
+		issues, err = tracker.Search(ctx.Context, str("query"), str("project"), limit)
+		data = map[string]interface{}{"issues": issues, "count": len(issues)}
+	case "get":
+		if result := required("issue"); result != nil {
+			return result
+		}
+		var issue *Issue
+		issue, err = tracker.Get(ctx.Context, str("issue"))
+		data = map[string]interface{}{"issue": issue}
+	case "create":
+		if result := required("project", "title"); result != nil {
+			return result
+		}
+		issueType := str("issue_type")
+		if issueType == "" {
+			issueType = "Task"
+		}
+		var issue *Issue
+		issue, err = tracker.Create(ctx.Context, str("project"), issueType, str("title"), str("description"))
+		data = map[string]interface{}{"issue": issue, "created": true}
+	case "comment":
+		if result := required("issue", "comment"); result != nil {
+			return result
+		}
+		err = tracker.Comment(ctx.Context, str("issue"), str("comment"))
+		data = map[string]interface{}{"issue": str("issue"), "commented": true}
+	case "transition":
+		if result := required("issue", "state"); result != nil {
+			return result
+		}
+		var issue *Issue
+		if issue, err = tracker.Transition(ctx.Context, str("issue"), str("state")); err == nil {
+			data = map[string]interface{}{"issue": issue.Key, "status": issue.Status}
+		}
+	default:
+		return tools.ErrorResult("INVALID_ACTION", fmt.Sprintf("Unknown action '%s'", str("action")))
+	}
+	if err != nil {
+		return trackerErrorResult(err)
+	}
+	return tools.SuccessResult(data)
+}
+
+// trackerErrorResult maps a backend error to a tool error code
+func trackerErrorResult(err error) *tools.Result {
+	var trackerErr *TrackerError
+	switch {
+	case errors.Is(err, errUnknownState):
+		return tools.ErrorResult("INVALID_STATE", err.Error())
+	case errors.As(err, &trackerErr) && (trackerErr.StatusCode == http.StatusUnauthorized || trackerErr.StatusCode == http.StatusForbidden):
+		return tools.ErrorResult("AUTH_FAILED", err.Error())
+	case errors.As(err, &trackerErr) && trackerErr.StatusCode == http.StatusNotFound:
+		return tools.ErrorResult("ISSUE_NOT_FOUND", err.Error())
+	case errors.As(err, &trackerErr):
+		return tools.ErrorResult("TRACKER_ERROR", err.Error())
+	}
+	return tools.ErrorResult("REQUEST_FAILED", err.Error())
+}
+
+// doJSON sends a JSON request and decodes the JSON response into out, which
+// may be nil
+func doJSON(ctx context.Context, client *http.Client, method, endpoint, authorization string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxTrackerResponse))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &TrackerError{StatusCode: resp.StatusCode, Message: errorMessage(data)}
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
+
+// errorMessage extracts the messages of a Jira or GraphQL error body
+func errorMessage(body []byte) string {
+	var parsed struct {
+		ErrorMessages []string        `json:"errorMessages"`
+		Errors        json.RawMessage `json:"errors"`
+		Message       string          `json:"message"`
+	}
+	if json.Unmarshal(body, &parsed) != nil {
+		return strings.TrimSpace(truncateText(string(body), 200))
+	}
+	messages := parsed.ErrorMessages
+	var fieldErrors map[string]string
+	if json.Unmarshal(parsed.Errors, &fieldErrors) == nil {
+		for field, message := range fieldErrors {
+			messages = append(messages, field+": "+message)
+		}
+	}
+	var graphQLErrors []struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(parsed.Errors, &graphQLErrors) == nil {
+		for _, e := range graphQLErrors {
+			messages = append(messages, e.Message)
+		}
+	}
+	if parsed.Message != "" {
+		messages = append(messages, parsed.Message)
+	}
+	if len(messages) == 0 {
+		return strings.TrimSpace(truncateText(string(body), 200))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// truncateText shortens text to at most n bytes
+func truncateText(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	return text[:n] + "..."
+}
+
+// JiraTracker is the IssueTracker of Jira Cloud's REST API v3. The
+// authorization is "Basic <base64 of email:API token>" or an OAuth2 bearer
+// token.
+type JiraTracker struct {
+	client        *http.Client
+	baseURL       string
+	authorization string
+}
+
+// jiraFields are the issue fields requested from Jira
+const jiraFields = "summary,status,assignee,updated,description,comment"
+
+// jiraIssue is an issue of the Jira API
+type jiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary     string          `json:"summary"`
+		Description json.RawMessage `json:"description"`
+		Updated     string          `json:"updated"`
+		Status      *struct {
+			Name string `json:"name"`
+		} `json:"status"`
+		Assignee *struct {
+			DisplayName string `json:"displayName"`
+		} `json:"assignee"`
+		Comment *struct {
+			Comments []struct {
+				Author *struct {
+					DisplayName string `json:"displayName"`
+				} `json:"author"`
+				Body    json.RawMessage `json:"body"`
+				Created string          `json:"created"`
+			} `json:"comments"`
+		} `json:"comment"`
+	} `json:"fields"`
+}
+
+func (j *JiraTracker) issue(raw jiraIssue, full bool) Issue {
+	issue := Issue{
+		Key:     raw.Key,
+		Title:   raw.Fields.Summary,
+		Updated: raw.Fields.Updated,
+		URL:     j.baseURL + "/browse/" + raw.Key,
+	}
+	if raw.Fields.Status != nil {
+		issue.Status = raw.Fields.Status.Name
+	}
+	if raw.Fields.Assignee != nil {
+		issue.Assignee = raw.Fields.Assignee.DisplayName
+	}
+	if !full {
+		return issue
+	}
+	issue.Description = adfText(raw.Fields.Description)
+	if raw.Fields.Comment != nil {
+		for _, comment := range raw.Fields.Comment.Comments {
+			c := IssueComment{Body: adfText(comment.Body), Created: comment.Created}
+			if comment.Author != nil {
+				c.Author = comment.Author.DisplayName
+			}
+			issue.Comments = append(issue.Comments, c)
+		}
+	}
+	return issue
+}
+
+func (j *JiraTracker) endpoint(path string) string {
+	return j.baseURL + "/rest/api/3/" + path
+}
+
+// Search runs a JQL query, or a text search when query is not JQL
+func (j *JiraTracker) Search(ctx context.Context, query, project string, limit int) ([]Issue, error) {
+	jql := query
+	if !looksLikeJQL(query) {
+		var clauses []string
+		if project != "" {
+			clauses = append(clauses, fmt.Sprintf("project = %q", project))
+		}
+		if query != "" {
+			clauses = append(clauses, fmt.Sprintf("text ~ %q", query))
+		}
+		jql = strings.Join(clauses, " AND ") + " ORDER BY updated DESC"
+	}
+	params := url.Values{
+		"jql":        {strings.TrimSpace(jql)},
+		"maxResults": {fmt.Sprint(limit)},
+		"fields":     {"summary,status,assignee,updated"},
+	}
+
+	var response struct {
+		Issues []jiraIssue `json:"issues"`
+	}
+	if err := doJSON(ctx, j.client, http.MethodGet, j.endpoint("search/jql?"+params.Encode()), j.authorization, nil, &response); err != nil {
+		return nil, err
+	}
+	issues := make([]Issue, 0, len(response.Issues))
+	for _, raw := range response.Issues {
+		issues = append(issues, j.issue(raw, false))
+	}
+	return issues, nil
+}
+
+// looksLikeJQL reports whether a query uses JQL operators
+func looksLikeJQL(query string) bool {
+	upper := strings.ToUpper(query)
+	return strings.ContainsAny(query, "=~") || strings.Contains(upper, " ORDER BY ") ||
+		strings.HasPrefix(upper, "ORDER BY ") || strings.Contains(upper, " IN (")
+}
+
+// Get reads an issue with its description and comments
+func (j *JiraTracker) Get(ctx context.Context, key string) (*Issue, error) {
+	var raw jiraIssue
+	if err := doJSON(ctx, j.client, http.MethodGet, j.endpoint("issue/"+url.PathEscape(key)+"?fields="+jiraFields), j.authorization, nil, &raw); err != nil {
+		return nil, err
+	}
+	issue := j.issue(raw, true)
+	return &issue, nil
+}
+
+// Create creates an issue in a project
+func (j *JiraTracker) Create(ctx context.Context, project, issueType, title, description string) (*Issue, error) {
+	fields := map[string]interface{}{
+		"project":   map[string]interface{}{"key": project},
+		"issuetype": map[string]interface{}{"name": issueType},
+		"summary":   title,
+	}
+	if description != "" {
+		fields["description"] = adfDocument(description)
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := doJSON(ctx, j.client, http.MethodPost, j.endpoint("issue"), j.authorization, map[string]interface{}{"fields": fields}, &created); err != nil {
+		return nil, err
+	}
+	return &Issue{Key: created.Key, Title: title, URL: j.baseURL + "/browse/" + created.Key}, nil
+}
+
+// Comment adds a comment to an issue
+func (j *JiraTracker) Comment(ctx context.Context, key, body string) error {
+	return doJSON(ctx, j.client, http.MethodPost, j.endpoint("issue/"+url.PathEscape(key)+"/comment"), j.authorization,
+		map[string]interface{}{"body": adfDocument(body)}, nil)
+}
+
+// Transition moves an issue through the workflow transition that is named
+// state or leads to the status state
+func (j *JiraTracker) Transition(ctx context.Context, key, state string) (*Issue, error) {
+	path := j.endpoint("issue/" + url.PathEscape(key) + "/transitions")
+	var response struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			To   struct {
+				Name string `json:"name"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	if err := doJSON(ctx, j.client, http.MethodGet, path, j.authorization, nil, &response); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(response.Transitions))
+	for _, transition := range response.Transitions {
+		if strings.EqualFold(transition.To.Name, state) || strings.EqualFold(transition.Name, state) {
+			body := map[string]interface{}{"transition": map[string]interface{}{"id": transition.ID}}
+			if err := doJSON(ctx, j.client, http.MethodPost, path, j.authorization, body, nil); err != nil {
+				return nil, err
+			}
+			return &Issue{Key: key, Status: transition.To.Name}, nil
+		}
+		names = append(names, transition.To.Name)
+	}
+	return nil, fmt.Errorf("%w: %s cannot move to '%s'; possible states: %s", errUnknownState, key, state, strings.Join(names, ", "))
+}
+
+// adfDocument wraps plain text in an Atlassian Document Format document, one
+// paragraph per line
+func adfDocument(text string) map[string]interface{} {
+	var paragraphs []interface{}
+	for _, line := range strings.Split(text, "\n") {
+		paragraph := map[string]interface{}{"type": "paragraph", "content": []interface{}{}}
+		if line != "" {
+			paragraph["content"] = []interface{}{map[string]interface{}{"type": "text", "text": line}}
+		}
+		paragraphs = append(paragraphs, paragraph)
+	}
+	return map[string]interface{}{"type": "doc", "version": 1, "content": paragraphs}
+}
+
+// adfText returns the plain text of an Atlassian Document Format document,
+// with a line per block
+func adfText(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	var node adfNode
+	if json.Unmarshal(raw, &node) != nil {
+		return ""
+	}
+	var b strings.Builder
+	node.write(&b)
+	return strings.TrimSpace(b.String())
+}
+
+// adfNode is a node of an Atlassian Document Format document
+type adfNode struct {
+	Type    string    `json:"type"`
+	Text    string    `json:"text"`
+	Content []adfNode `json:"content"`
+}
+
+func (n adfNode) write(b *strings.Builder) {
+	switch n.Type {
+	case "text":
+		b.WriteString(n.Text)
+		return
+	case "hardBreak":
+		b.WriteString("\n")
+		return
+	}
+	for _, child := range n.Content {
+		child.write(b)
+	}
+	switch n.Type {
+	case "paragraph", "heading", "codeBlock", "listItem", "blockquote":
+		if !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+	}
+}
+
+// LinearTracker is the IssueTracker of Linear's GraphQL API. The
+// authorization is a personal API key or "Bearer <OAuth2 token>".
+type LinearTracker struct {
+	client        *http.Client
+	apiURL        string
+	authorization string
+}
+
+// linearIssueFields are the issue fields requested from Linear
+const linearIssueFields = `id identifier title url updatedAt state { name } assignee { name }`
+
+// linearIssue is an issue of the Linear API
+type linearIssue struct {
+	ID          string `json:"id"`
+	Identifier  string `json:"identifier"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	UpdatedAt   string `json:"updatedAt"`
+	State       *struct {
+		Name string `json:"name"`
+	} `json:"state"`
+	Assignee *struct {
+		Name string `json:"name"`
+	} `json:"assignee"`
+	Comments *struct {
+		Nodes []struct {
+			Body      string `json:"body"`
+			CreatedAt string `json:"createdAt"`
+			User      *struct {
+				Name string `json:"name"`
+			} `json:"user"`
+		} `json:"nodes"`
+	} `json:"comments"`
+	Team *struct {
+		States struct {
+			Nodes []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"nodes"`
+		} `json:"states"`
+	} `json:"team"`
+}
+
+func (raw linearIssue) issue() Issue {
+	issue := Issue{
+		Key:         raw.Identifier,
+		Title:       raw.Title,
+		Description: raw.Description,
+		URL:         raw.URL,
+		Updated:     raw.UpdatedAt,
+	}
+	if raw.State != nil {
+		issue.Status = raw.State.Name
+	}
+	if raw.Assignee != nil {
+		issue.Assignee = raw.Assignee.Name
+	}
+	if raw.Comments != nil {
+		for _, comment := range raw.Comments.Nodes {
+			c := IssueComment{Body: comment.Body, Created: comment.CreatedAt}
+			if comment.User != nil {
+				c.Author = comment.User.Name
+			}
+			issue.Comments = append(issue.Comments, c)
+		}
+	}
+	return issue
+}
+
+// query runs a GraphQL query or mutation, decoding its data into out
+func (l *LinearTracker) query(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	var response struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message    string `json:"message"`
+			Extensions struct {
+				Code string `json:"code"`
+			} `json:"extensions"`
+		} `json:"errors"`
+	}
+	body := map[string]interface{}{"query": query, "variables": variables}
+	if err := doJSON(ctx, l.client, http.MethodPost, l.apiURL, l.authorization, body, &response); err != nil {
+		return err
+	}
+	// GraphQL reports errors with status 200
+	if len(response.Errors) > 0 {
+		status := http.StatusBadRequest
+		messages := make([]string, len(response.Errors))
+		for i, e := range response.Errors {
+			messages[i] = e.Message
+			switch e.Extensions.Code {
+			case "AUTHENTICATION_ERROR":
+				status = http.StatusUnauthorized
+			case "FORBIDDEN":
+				status = http.StatusForbidden
+			case "ENTITY_NOT_FOUND":
+				status = http.StatusNotFound
+			}
+		}
+		return &TrackerError{StatusCode: status, Message: strings.Join(messages, "; ")}
+	}
+	return json.Unmarshal(response.Data, out)
+}
+
+// Search finds issues by text, within a team when project is set
+func (l *LinearTracker) Search(ctx context.Context, query, project string, limit int) ([]Issue, error) {
+	variables := map[string]interface{}{"term": query, "first": limit}
+	gql := `query Search($term: String!, $first: Int) { searchIssues(term: $term, first: $first) { nodes { ` + linearIssueFields + ` } } }`
+	if project != "" {
+		variables["team"] = project
+		gql = `query Search($term: String!, $first: Int, $team: String!) {
+  searchIssues(term: $term, first: $first, filter: { team: { key: { eq: $team } } }) { nodes { ` + linearIssueFields + ` } }
+}`
+	}
+	var data struct {
+		SearchIssues struct {
+			Nodes []linearIssue `json:"nodes"`
+		} `json:"searchIssues"`
+	}
+	if err := l.query(ctx, gql, variables, &data); err != nil {
+		return nil, err
+	}
+	issues := make([]Issue, 0, len(data.SearchIssues.Nodes))
+	for _, raw := range data.SearchIssues.Nodes {
+		issues = append(issues, raw.issue())
+	}
+	return issues, nil
+}
+
+// get reads an issue with the fields given
+func (l *LinearTracker) get(ctx context.Context, key, fields string) (*linearIssue, error) {
+	var data struct {
+		Issue *linearIssue `json:"issue"`
+	}
+	gql := `query Issue($id: String!) { issue(id: $id) { ` + fields + ` } }`
+	if err := l.query(ctx, gql, map[string]interface{}{"id": key}, &data); err != nil {
+		return nil, err
+	}
+	if data.Issue == nil {
+		return nil, &TrackerError{StatusCode: http.StatusNotFound, Message: fmt.Sprintf("issue %s not found", key)}
+	}
+	return data.Issue, nil
+}
+
+// Get reads an issue with its description and comments
+func (l *LinearTracker) Get(ctx context.Context, key string) (*Issue, error) {
+	raw, err := l.get(ctx, key, linearIssueFields+` description comments(first: 50) { nodes { body createdAt user { name } } }`)
+	if err != nil {
+		return nil, err
+	}
+	issue := raw.issue()
+	return &issue, nil
+}
+
+// Create creates an issue in the team with the key project
+func (l *LinearTracker) Create(ctx context.Context, project, issueType, title, description string) (*Issue, error) {
+	var teams struct {
+		Teams struct {
+			Nodes []struct {
+				ID string `json:"id"`
+			} `json:"nodes"`
+		} `json:"teams"`
+	}
+	gql := `query Team($key: String!) { teams(filter: { key: { eq: $key } }) { nodes { id } } }`
+	if err := l.query(ctx, gql, map[string]interface{}{"key": project}, &teams); err != nil {
+		return nil, err
+	}
+	if len(teams.Teams.Nodes) == 0 {
+		return nil, &TrackerError{StatusCode: http.StatusNotFound, Message: fmt.Sprintf("team %s not found", project)}
+	}
+
+	var data struct {
+		IssueCreate struct {
+			Success bool        `json:"success"`
+			Issue   linearIssue `json:"issue"`
+		} `json:"issueCreate"`
+	}
+	input := map[string]interface{}{"teamId": teams.Teams.Nodes[0].ID, "title": title}
+	if description != "" {
+		input["description"] = description
+	}
+	gql = `mutation Create($input: IssueCreateInput!) { issueCreate(input: $input) { success issue { ` + linearIssueFields + ` } } }`
+	if err := l.query(ctx, gql, map[string]interface{}{"input": input}, &data); err != nil {
+		return nil, err
+	}
+	if !data.IssueCreate.Success {
+		return nil, &TrackerError{Message: "Linear did not create the issue"}
+	}
+	issue := data.IssueCreate.Issue.issue()
+	return &issue, nil
+}
+
+// Comment adds a comment to an issue
+func (l *LinearTracker) Comment(ctx context.Context, key, body string) error {
+	raw, err := l.get(ctx, key, "id")
+	if err != nil {
+		return err
+	}
+	var data struct {
+		CommentCreate struct {
+			Success bool `json:"success"`
+		} `json:"commentCreate"`
+	}
+	gql := `mutation Comment($input: CommentCreateInput!) { commentCreate(input: $input) { success } }`
+	if err := l.query(ctx, gql, map[string]interface{}{"input": map[string]interface{}{"issueId": raw.ID, "body": body}}, &data); err != nil {
+		return err
+	}
+	if !data.CommentCreate.Success {
+		return &TrackerError{Message: "Linear did not add the comment"}
+	}
+	return nil
+}
+
+// Transition moves an issue to the workflow state of its team named state
+func (l *LinearTracker) Transition(ctx context.Context, key, state string) (*Issue, error) {
+	raw, err := l.get(ctx, key, "id identifier team { states { nodes { id name } } }")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if raw.Team != nil {
+		for _, candidate := range raw.Team.States.Nodes {
+			if !strings.EqualFold(candidate.Name, state) {
+				names = append(names, candidate.Name)
+				continue
+			}
+			var data struct {
+				IssueUpdate struct {
+					Success bool `json:"success"`
+				} `json:"issueUpdate"`
+			}
+			gql := `mutation Transition($id: String!, $input: IssueUpdateInput!) { issueUpdate(id: $id, input: $input) { success } }`
+			variables := map[string]interface{}{"id": raw.ID, "input": map[string]interface{}{"stateId": candidate.ID}}
+			if err := l.query(ctx, gql, variables, &data); err != nil {
+				return nil, err
+			}
+			if !data.IssueUpdate.Success {
+				return nil, &TrackerError{Message: "Linear did not update the issue"}
+			}
+			return &Issue{Key: raw.Identifier, Status: candidate.Name}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s cannot move to '%s'; possible states: %s", errUnknownState, key, state, strings.Join(names, ", "))
+}
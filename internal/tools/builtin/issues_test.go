@@ -0,0 +1,160 @@
+package builtin_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"agent-server/internal/tools"
+	"agent-server/internal/tools/builtin"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueTrackerTool_Jira(t *testing.T) {
+	var comment map[string]interface{}
+	var transitioned string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Basic dGVzdA==" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errorMessages": ["Not authenticated"]}`))
+			return
+		}
+		switch {
+		case r.URL.Path == "/rest/api/3/search/jql":
+			assert.Equal(t, `project = "SUP" AND text ~ "login error" ORDER BY updated DESC`, r.URL.Query().Get("jql"))
+			w.Write([]byte(`{"issues": [{"key": "SUP-7", "fields": {"summary": "Login fails", "status": {"name": "Open"}}}]}`))
+		case r.URL.Path == "/rest/api/3/issue/SUP-7" && r.Method == http.MethodGet:
+			w.Write([]byte(`{"key": "SUP-7", "fields": {"summary": "Login fails", "status": {"name": "Open"},
+				"description": {"type": "doc", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "SSO users see a 500."}]}]},
+				"comment": {"comments": [{"author": {"displayName": "Ann"}, "body": {"type": "doc", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "Seen it too."}]}]}}]}}}`))
+		case r.URL.Path == "/rest/api/3/issue/SUP-7/comment":
+			json.NewDecoder(r.Body).Decode(&comment)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "100"}`))
+		case r.URL.Path == "/rest/api/3/issue/SUP-7/transitions" && r.Method == http.MethodGet:
+			w.Write([]byte(`{"transitions": [{"id": "21", "name": "Start", "to": {"name": "In Progress"}}, {"id": "31", "name": "Resolve", "to": {"name": "Done"}}]}`))
+		case r.URL.Path == "/rest/api/3/issue/SUP-7/transitions":
+			var body struct {
+				Transition struct {
+					ID string `json:"id"`
+				} `json:"transition"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			transitioned = body.Transition.ID
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errorMessages": ["Issue does not exist"]}`))
+		}
+	}))
+	defer server.Close()
+
+	tool := builtin.NewIssueTrackerTool()
+	ctx := tools.ExecutionContext{Context: context.Background(), SessionID: "s1"}
+	run := func(input map[string]interface{}) *tools.Result {
+		input["tracker"] = "jira"
+		input["base_url"] = server.URL
+		if _, ok := input["authorization"]; !ok {
+			input["authorization"] = "Basic dGVzdA=="
+		}
+		return tool.Execute(ctx, input)
+	}
+
+	result := run(map[string]interface{}{"action": "search", "query": "login error", "project": "SUP"})
+	require.True(t, result.Success, result.Error)
+	issues := result.Data.(map[string]interface{})["issues"].([]builtin.Issue)
+	require.Len(t, issues, 1)
+	assert.Equal(t, "SUP-7", issues[0].Key)
+	assert.Equal(t, server.URL+"/browse/SUP-7", issues[0].URL)
+
+	result = run(map[string]interface{}{"action": "get", "issue": "SUP-7"})
+	require.True(t, result.Success, result.Error)
+	issue := result.Data.(map[string]interface{})["issue"].(*builtin.Issue)
+	assert.Equal(t, "SSO users see a 500.", issue.Description)
+	require.Len(t, issue.Comments, 1)
+	assert.Equal(t, builtin.IssueComment{Author: "Ann", Body: "Seen it too."}, issue.Comments[0])
+
+	result = run(map[string]interface{}{"action": "comment", "issue": "SUP-7", "comment": "Fixed in 2.1"})
+	require.True(t, result.Success, result.Error)
+	assert.Equal(t, "doc", comment["body"].(map[string]interface{})["type"])
+
+	result = run(map[string]interface{}{"action": "transition", "issue": "SUP-7", "state": "done"})
+	require.True(t, result.Success, result.Error)
+	assert.Equal(t, "31", transitioned)
+	assert.Equal(t, "Done", result.Data.(map[string]interface{})["status"])
+
+	result = run(map[string]interface{}{"action": "transition", "issue": "SUP-7", "state": "Closed"})
+	assert.Equal(t, "INVALID_STATE", result.ErrorCode)
+	assert.Contains(t, result.Error, "In Progress, Done")
+
+	result = run(map[string]interface{}{"action": "get", "issue": "SUP-8"})
+	assert.Equal(t, "ISSUE_NOT_FOUND", result.ErrorCode)
+	assert.Contains(t, result.Error, "Issue does not exist")
+
+	result = run(map[string]interface{}{"action": "get", "issue": "SUP-7", "authorization": "Basic bad"})
+	assert.Equal(t, "AUTH_FAILED", result.ErrorCode)
+
+	result = run(map[string]interface{}{"action": "create", "project": "SUP"})
+	assert.Equal(t, "MISSING_PARAMETER", result.ErrorCode)
+}
+
+func TestIssueTrackerTool_Linear(t *testing.T) {
+	var updated map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "lin_api_test", r.Header.Get("Authorization"))
+		var body struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch {
+		case strings.Contains(body.Query, "searchIssues"):
+			assert.Equal(t, "ENG", body.Variables["team"])
+			w.Write([]byte(`{"data": {"searchIssues": {"nodes": [{"id": "u1", "identifier": "ENG-4", "title": "Crash on save", "state": {"name": "Todo"}}]}}}`))
+		case strings.Contains(body.Query, "issueUpdate"):
+			updated = body.Variables
+			w.Write([]byte(`{"data": {"issueUpdate": {"success": true}}}`))
+		case strings.Contains(body.Query, "issue(id:") && body.Variables["id"] == "ENG-4":
+			w.Write([]byte(`{"data": {"issue": {"id": "u1", "identifier": "ENG-4", "team": {"states": {"nodes": [{"id": "s1", "name": "Todo"}, {"id": "s2", "name": "Done"}]}}}}}`))
+		default:
+			w.Write([]byte(`{"data": null, "errors": [{"message": "Entity not found", "extensions": {"code": "ENTITY_NOT_FOUND"}}]}`))
+		}
+	}))
+	defer server.Close()
+
+	tool := builtin.NewIssueTrackerTool()
+	ctx := tools.ExecutionContext{Context: context.Background(), SessionID: "s1"}
+	run := func(input map[string]interface{}) *tools.Result {
+		input["tracker"] = "linear"
+		input["base_url"] = server.URL
+		input["authorization"] = "lin_api_test"
+		return tool.Execute(ctx, input)
+	}
+
+	result := run(map[string]interface{}{"action": "search", "query": "crash", "project": "ENG"})
+	require.True(t, result.Success, result.Error)
+	issues := result.Data.(map[string]interface{})["issues"].([]builtin.Issue)
+	require.Len(t, issues, 1)
+	assert.Equal(t, builtin.Issue{Key: "ENG-4", Title: "Crash on save", Status: "Todo"}, issues[0])
+
+	result = run(map[string]interface{}{"action": "transition", "issue": "ENG-4", "state": "Done"})
+	require.True(t, result.Success, result.Error)
+	assert.Equal(t, "u1", updated["id"])
+	assert.Equal(t, map[string]interface{}{"stateId": "s2"}, updated["input"])
+
+	result = run(map[string]interface{}{"action": "get", "issue": "ENG-99"})
+	assert.Equal(t, "ISSUE_NOT_FOUND", result.ErrorCode)
+}
+
+func TestIssueTrackerTool_Mutates(t *testing.T) {
+	tool := builtin.NewIssueTrackerTool()
+	assert.False(t, tool.Mutates(map[string]interface{}{"action": "search"}))
+	assert.False(t, tool.Mutates(map[string]interface{}{"action": "get"}))
+	assert.True(t, tool.Mutates(map[string]interface{}{"action": "create"}))
+	assert.True(t, tool.Mutates(map[string]interface{}{"action": "transition"}))
+}
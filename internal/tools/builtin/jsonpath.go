@@ -0,0 +1,438 @@
+package builtin
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSON paths used by json_processor follow a small JSONPath subset:
+//
+//	user.name            object keys, optionally prefixed with "$" or "$."
+//	items[0].name        array indexes; negative indexes count from the end
+//	items[*].name        wildcard over array elements or object values
+//	*.id                 wildcard as a dotted segment
+//	..name               recursive descent: "name" at any depth
+//	['key.with.dots']    quoted keys
+type jsonPathSegmentKind int
+
+const (
+	segmentKey jsonPathSegmentKind = iota
+	segmentIndex
+	segmentWildcard
+	segmentRecursive
+)
+
+type jsonPathSegment struct {
+	kind  jsonPathSegmentKind
+	key   string
+	index int
+}
+
+func (s jsonPathSegment) String() string {
+	switch s.kind {
+	case segmentIndex:
+		return fmt.Sprintf("[%d]", s.index)
+	case segmentWildcard:
+		return "*"
+	case segmentRecursive:
+		return ".." + s.key
+	}
+	return s.key
+}
+
+// parseJSONPath splits a path into segments
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	if !strings.HasPrefix(path, "..") {
+		path = strings.TrimPrefix(path, ".")
+	}
+
+	var segments []jsonPathSegment
+	for i := 0; i < len(path); {
+		switch {
+		case strings.HasPrefix(path[i:], ".."):
+			i += 2
+			key, n := readJSONPathKey(path[i:])
+			if key == "" {
+				return nil, fmt.Errorf("recursive descent needs a key at position %d", i)
+			}
+			segments = append(segments, jsonPathSegment{kind: segmentRecursive, key: key})
+			i += n
+
+		case path[i] == '.':
+			i++
+
+		case path[i] == '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed '[' at position %d", i)
+			}
+			inner := strings.TrimSpace(path[i+1 : i+end])
+			i += end + 1
+
+			switch {
+			case inner == "*":
+				segments = append(segments, jsonPathSegment{kind: segmentWildcard})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segments = append(segments, jsonPathSegment{kind: segmentKey, key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid array index '%s'", inner)
+				}
+				segments = append(segments, jsonPathSegment{kind: segmentIndex, index: index})
+			}
+
+		default:
+			key, n := readJSONPathKey(path[i:])
+			if key == "*" {
+				segments = append(segments, jsonPathSegment{kind: segmentWildcard})
+			} else {
+				segments = append(segments, jsonPathSegment{kind: segmentKey, key: key})
+			}
+			i += n
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("path is empty")
+	}
+	return segments, nil
+}
+
+// readJSONPathKey reads a dotted key up to the next '.' or '['
+func readJSONPathKey(s string) (string, int) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+	return s[:end], end
+}
+
+// isDefinite reports whether the path selects at most one value
+func isDefinite(segments []jsonPathSegment) bool {
+	for _, s := range segments {
+		if s.kind == segmentWildcard || s.kind == segmentRecursive {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveIndex turns a possibly negative index into a position in an array of
+// the given length
+func resolveIndex(index, length int) (int, bool) {
+	if index < 0 {
+		index += length
+	}
+	return index, index >= 0 && index < length
+}
+
+// queryJSON returns every value the path selects
+func queryJSON(data interface{}, segments []jsonPathSegment) []interface{} {
+	current := []interface{}{data}
+	for _, segment := range segments {
+		var next []interface{}
+		for _, value := range current {
+			next = append(next, stepJSON(value, segment)...)
+		}
+		current = next
+	}
+	return current
+}
+
+// stepJSON applies one segment to a value
+func stepJSON(value interface{}, segment jsonPathSegment) []interface{} {
+	switch segment.kind {
+	case segmentKey:
+		if obj, ok := value.(map[string]interface{}); ok {
+			if child, exists := obj[segment.key]; exists {
+				return []interface{}{child}
+			}
+		}
+	case segmentIndex:
+		if arr, ok := value.([]interface{}); ok {
+			if i, ok := resolveIndex(segment.index, len(arr)); ok {
+				return []interface{}{arr[i]}
+			}
+		}
+	case segmentWildcard:
+		switch v := value.(type) {
+		case []interface{}:
+			return append([]interface{}{}, v...)
+		case map[string]interface{}:
+			children := make([]interface{}, 0, len(v))
+			for _, key := range sortedKeys(v) {
+				children = append(children, v[key])
+			}
+			return children
+		}
+	case segmentRecursive:
+		var found []interface{}
+		collectJSONKey(value, segment.key, &found)
+		return found
+	}
+	return nil
+}
+
+// collectJSONKey appends the values stored under key at any depth
+func collectJSONKey(value interface{}, key string, found *[]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			if k == key {
+				*found = append(*found, v[k])
+			}
+			collectJSONKey(v[k], key, found)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectJSONKey(item, key, found)
+		}
+	}
+}
+
+// getJSONValue returns the value at a definite path, or all matches of a
+// wildcard or recursive path
+func getJSONValue(data interface{}, path string) (interface{}, error) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if !isDefinite(segments) {
+		matches := queryJSON(data, segments)
+		if matches == nil {
+			matches = []interface{}{}
+		}
+		return matches, nil
+	}
+
+	current := data
+	for _, segment := range segments {
+		switch segment.kind {
+		case segmentKey:
+			obj, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("cannot access key '%s' on non-object", segment.key)
+			}
+			value, exists := obj[segment.key]
+			if !exists {
+				return nil, fmt.Errorf("key '%s' not found", segment.key)
+			}
+			current = value
+		case segmentIndex:
+			arr, ok := current.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("cannot index non-array with %s", segment)
+			}
+			i, ok := resolveIndex(segment.index, len(arr))
+			if !ok {
+				return nil, fmt.Errorf("index %d out of range (length %d)", segment.index, len(arr))
+			}
+			current = arr[i]
+		}
+	}
+	return current, nil
+}
+
+// setJSONValue stores value at a definite path, creating missing objects on
+// the way. An index equal to the array length appends.
+func setJSONValue(data interface{}, path string, value interface{}) (interface{}, error) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if !isDefinite(segments) {
+		return nil, fmt.Errorf("set_value needs a path without wildcards")
+	}
+	return setAt(data, segments, value)
+}
+
+func setAt(current interface{}, segments []jsonPathSegment, value interface{}) (interface{}, error) {
+	if len(segments) == 0 {
+		return value, nil
+	}
+	segment, rest := segments[0], segments[1:]
+
+	switch segment.kind {
+	case segmentKey:
+		if current == nil {
+			current = map[string]interface{}{}
+		}
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot set key '%s' on non-object", segment.key)
+		}
+		child, err := setAt(obj[segment.key], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		obj[segment.key] = child
+		return obj, nil
+
+	default:
+		arr, ok := current.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot index non-array with %s", segment)
+		}
+		if segment.index == len(arr) {
+			child, err := setAt(nil, rest, value)
+			if err != nil {
+				return nil, err
+			}
+			return append(arr, child), nil
+		}
+		i, ok := resolveIndex(segment.index, len(arr))
+		if !ok {
+			return nil, fmt.Errorf("index %d out of range (length %d)", segment.index, len(arr))
+		}
+		child, err := setAt(arr[i], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = child
+		return arr, nil
+	}
+}
+
+// deleteJSONValue removes the object key or array element at a definite path
+func deleteJSONValue(data interface{}, path string) (interface{}, error) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if !isDefinite(segments) {
+		return nil, fmt.Errorf("delete_key needs a path without wildcards")
+	}
+	return deleteAt(data, segments)
+}
+
+func deleteAt(current interface{}, segments []jsonPathSegment) (interface{}, error) {
+	segment, rest := segments[0], segments[1:]
+
+	switch segment.kind {
+	case segmentKey:
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot access key '%s' on non-object", segment.key)
+		}
+		child, exists := obj[segment.key]
+		if !exists {
+			return nil, fmt.Errorf("key '%s' not found", segment.key)
+		}
+		if len(rest) == 0 {
+			delete(obj, segment.key)
+			return obj, nil
+		}
+		child, err := deleteAt(child, rest)
+		if err != nil {
+			return nil, err
+		}
+		obj[segment.key] = child
+		return obj, nil
+
+	default:
+		arr, ok := current.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot index non-array with %s", segment)
+		}
+		i, ok := resolveIndex(segment.index, len(arr))
+		if !ok {
+			return nil, fmt.Errorf("index %d out of range (length %d)", segment.index, len(arr))
+		}
+		if len(rest) == 0 {
+			return append(arr[:i], arr[i+1:]...), nil
+		}
+		child, err := deleteAt(arr[i], rest)
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = child
+		return arr, nil
+	}
+}
+
+// jsonChange describes one difference between two JSON documents
+type jsonChange struct {
+	Path string      `json:"path"`
+	Type string      `json:"type"` // "added", "removed" or "changed"
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// diffJSON lists the changes that turn a into b, ordered by path
+func diffJSON(a, b interface{}) []jsonChange {
+	changes := []jsonChange{}
+	collectJSONDiff("$", a, b, &changes)
+	return changes
+}
+
+func collectJSONDiff(path string, a, b interface{}, changes *[]jsonChange) {
+	aObj, aIsObj := a.(map[string]interface{})
+	bObj, bIsObj := b.(map[string]interface{})
+	if aIsObj && bIsObj {
+		keys := sortedKeys(aObj)
+		for _, key := range sortedKeys(bObj) {
+			if _, exists := aObj[key]; !exists {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			childPath := joinJSONPath(path, key)
+			aValue, inA := aObj[key]
+			bValue, inB := bObj[key]
+			switch {
+			case !inA:
+				*changes = append(*changes, jsonChange{Path: childPath, Type: "added", New: bValue})
+			case !inB:
+				*changes = append(*changes, jsonChange{Path: childPath, Type: "removed", Old: aValue})
+			default:
+				collectJSONDiff(childPath, aValue, bValue, changes)
+			}
+		}
+		return
+	}
+
+	aArr, aIsArr := a.([]interface{})
+	bArr, bIsArr := b.([]interface{})
+	if aIsArr && bIsArr {
+		for i := 0; i < len(aArr) || i < len(bArr); i++ {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(aArr):
+				*changes = append(*changes, jsonChange{Path: childPath, Type: "added", New: bArr[i]})
+			case i >= len(bArr):
+				*changes = append(*changes, jsonChange{Path: childPath, Type: "removed", Old: aArr[i]})
+			default:
+				collectJSONDiff(childPath, aArr[i], bArr[i], changes)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, jsonChange{Path: path, Type: "changed", Old: a, New: b})
+	}
+}
+
+// joinJSONPath appends a key to a path, quoting keys that are not plain
+func joinJSONPath(path, key string) string {
+	if key == "" || strings.ContainsAny(key, ".[]'\" ") {
+		return fmt.Sprintf("%s['%s']", path, key)
+	}
+	return path + "." + key
+}
+
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -104,9 +104,7 @@ func NewMCPProxyTool() *MCPProxyTool {
 
 	tool := &MCPProxyTool{
 		HTTPBaseTool: tools.NewHTTPBaseTool("mcp_proxy", schema, "", 60*time.Second),
-		client: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+		client: tools.NewHTTPClient(60 * time.Second),
 	}
 
 	// Set the execute function
@@ -115,9 +113,22 @@ func NewMCPProxyTool() *MCPProxyTool {
 	return tool
 }
 
+// SetTransport routes the tool's requests through base, keeping audit capture
+func (m *MCPProxyTool) SetTransport(base http.RoundTripper) {
+	m.client.Transport = tools.NewAuditTransport(base)
+}
+
+// SetRedirectPolicy sets how the tool's requests follow redirects
+func (m *MCPProxyTool) SetRedirectPolicy(policy tools.RedirectPolicy) {
+	m.client.CheckRedirect = policy.CheckRedirect
+}
+
 func (m *MCPProxyTool) execute(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
-	serverURL := input["server_url"].(string)
-	action := input["action"].(string)
+	serverURL, ok := input["server_url"].(string)
+	if !ok || serverURL == "" {
+		return tools.ErrorResult("MISSING_SERVER_URL", "server_url is required")
+	}
+	action, _ := input["action"].(string)
 
 	switch action {
 	case "initialize":
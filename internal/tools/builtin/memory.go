@@ -3,6 +3,7 @@ package builtin
 import (
 	"context"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -270,7 +271,7 @@ func (m *MemoryTool) handleStore(ctx tools.ExecutionContext, input map[string]in
 	})
 }
 
-// handleRecall retrieves memories by topic
+// handleRecall retrieves the best ranked memories of a topic
 func (m *MemoryTool) handleRecall(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
 	topic, ok := input["topic"].(string)
 	if !ok || topic == "" {
@@ -284,29 +285,60 @@ func (m *MemoryTool) handleRecall(ctx tools.ExecutionContext, input map[string]i
 		}
 	}
 
-	memories, err := m.memoryRepo.ListByTopic(context.Background(), ctx.AgentID, topic, limit, 0)
+	if limit <= 0 {
+		limit = 10
+	}
+
+	// Read more memories than asked for and return the best ranked
+	candidates := limit * recallCandidates
+	if candidates > maxRecallCandidates {
+		candidates = maxRecallCandidates
+	}
+	memories, err := m.memoryRepo.ListByTopic(context.Background(), ctx.AgentID, topic, candidates, 0)
 	if err != nil {
 		return tools.ErrorResult("RECALL_FAILED", fmt.Sprintf("Failed to recall memories: %v", err))
 	}
 
-	memoriesData := make([]map[string]interface{}, len(memories))
-	for i, memory := range memories {
+	now := time.Now()
+	ranked := rankMemories(memories, recallHintsFrom(ctx.Context), now)
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	ids := make([]string, len(ranked))
+	for i, r := range ranked {
+		ids[i] = r.memory.ID
+	}
+	// Access statistics only inform later rankings; failing to record them
+	// does not fail the recall
+	accessed := m.memoryRepo.RecordAccess(context.Background(), ids, now) == nil
+
+	memoriesData := make([]map[string]interface{}, len(ranked))
+	for i, r := range ranked {
+		memory := r.memory
+		if accessed {
+			memory.AccessCount++
+			memory.LastAccessedAt = &now
+		}
 		memoriesData[i] = map[string]interface{}{
-			"id":          memory.ID,
-			"topic":       memory.Topic,
-			"content":     memory.Content,
-			"memory_type": memory.MemoryType,
-			"importance":  memory.Importance,
-			"tags":        memory.Tags,
-			"created_at":  memory.CreatedAt,
-			"updated_at":  memory.UpdatedAt,
+			"id":               memory.ID,
+			"topic":            memory.Topic,
+			"content":          memory.Content,
+			"memory_type":      memory.MemoryType,
+			"importance":       memory.Importance,
+			"tags":             memory.Tags,
+			"created_at":       memory.CreatedAt,
+			"updated_at":       memory.UpdatedAt,
+			"access_count":     memory.AccessCount,
+			"last_accessed_at": memory.LastAccessedAt,
+			"score":            math.Round(r.score*1000) / 1000,
 		}
 	}
 
 	return tools.SuccessResult(map[string]interface{}{
 		"success":  true,
 		"memories": memoriesData,
-		"count":    len(memories),
+		"count":    len(ranked),
 		"topic":    topic,
 	})
 }
@@ -0,0 +1,164 @@
+package builtin
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"agent-server/internal/models"
+)
+
+// recallCandidates is how many memories of a topic are read per recalled
+// memory, to be ranked before the best are returned
+const recallCandidates = 5
+
+// maxRecallCandidates bounds the memories read for one recall
+const maxRecallCandidates = 500
+
+// RecallHints carry what the memory tool ranks recalled memories by besides
+// the memories themselves
+type RecallHints struct {
+	Ranking *models.AgentMemoryRanking // nil uses models.DefaultMemoryRanking
+	Message string                     // the user's latest message
+}
+
+type recallHintsKey struct{}
+
+// WithRecallHints returns a context whose memory recalls are ranked with
+// hints
+func WithRecallHints(ctx context.Context, hints RecallHints) context.Context {
+	return context.WithValue(ctx, recallHintsKey{}, hints)
+}
+
+// recallHintsFrom returns the hints of ctx
+func recallHintsFrom(ctx context.Context) RecallHints {
+	if ctx == nil {
+		return RecallHints{}
+	}
+	hints, _ := ctx.Value(recallHintsKey{}).(RecallHints)
+	return hints
+}
+
+// rankedMemory is a recalled memory with its score
+type rankedMemory struct {
+	memory *models.Memory
+	score  float64
+}
+
+// rankMemories orders memories by a score between 0 and 1 that weighs their
+// importance, recency, recall count and similarity to the message. Recency
+// halves every half-life since the memory was last changed or recalled;
+// recall counts are compared on a log scale with the most recalled memory.
+// Memories with equal scores keep their order.
+func rankMemories(memories []*models.Memory, hints RecallHints, now time.Time) []rankedMemory {
+	ranking := models.DefaultMemoryRanking
+	if hints.Ranking != nil {
+		ranking = *hints.Ranking
+	}
+	halfLife := time.Duration(ranking.HalfLife) * time.Hour
+	if halfLife <= 0 {
+		halfLife = time.Duration(models.DefaultMemoryRanking.HalfLife) * time.Hour
+	}
+
+	maxAccess := 0
+	for _, memory := range memories {
+		if memory.AccessCount > maxAccess {
+			maxAccess = memory.AccessCount
+		}
+	}
+	message := rankingTerms(hints.Message)
+	// Without a message similarity tells the memories nothing
+	weights := ranking.Importance + ranking.Recency + ranking.Frequency
+	if len(message) > 0 {
+		weights += ranking.Similarity
+	}
+
+	ranked := make([]rankedMemory, len(memories))
+	for i, memory := range memories {
+		ranked[i].memory = memory
+		if weights <= 0 {
+			continue
+		}
+
+		score := ranking.Importance * math.Min(math.Max(float64(memory.Importance), 0), 10) / 10
+
+		touched := memory.UpdatedAt
+		if touched.IsZero() {
+			touched = memory.CreatedAt
+		}
+		if memory.LastAccessedAt != nil && memory.LastAccessedAt.After(touched) {
+			touched = *memory.LastAccessedAt
+		}
+		if age := now.Sub(touched); age > 0 {
+			score += ranking.Recency * math.Pow(0.5, float64(age)/float64(halfLife))
+		} else {
+			score += ranking.Recency
+		}
+
+		if maxAccess > 0 {
+			score += ranking.Frequency * math.Log1p(float64(memory.AccessCount)) / math.Log1p(float64(maxAccess))
+		}
+
+		if len(message) > 0 {
+			score += ranking.Similarity * termSimilarity(message, rankingTerms(memory.Topic+" "+memory.Content+" "+memoryTags(memory)))
+		}
+		ranked[i].score = score / weights
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+	return ranked
+}
+
+// memoryTags joins the tags of a memory
+func memoryTags(memory *models.Memory) string {
+	switch tags := memory.Tags["tags"].(type) {
+	case []string:
+		return strings.Join(tags, " ")
+	case []interface{}:
+		words := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			if s, ok := tag.(string); ok {
+				words = append(words, s)
+			}
+		}
+		return strings.Join(words, " ")
+	}
+	return ""
+}
+
+// rankingStopwords are frequent words that say nothing about a memory
+var rankingStopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "was": true, "you": true, "your": true,
+	"what": true, "with": true, "that": true, "this": true, "have": true, "has": true, "from": true,
+	"not": true, "but": true, "can": true, "does": true, "about": true, "how": true, "who": true,
+}
+
+// rankingTerms returns the distinct words of text that are worth comparing
+func rankingTerms(text string) map[string]bool {
+	terms := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) >= 3 && !rankingStopwords[word] {
+			terms[word] = true
+		}
+	}
+	return terms
+}
+
+// termSimilarity is the cosine similarity of two word sets
+func termSimilarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for term := range a {
+		if b[term] {
+			shared++
+		}
+	}
+	return float64(shared) / math.Sqrt(float64(len(a))*float64(len(b)))
+}
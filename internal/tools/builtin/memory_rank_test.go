@@ -0,0 +1,66 @@
+package builtin_test
+
+import (
+	"context"
+	"testing"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage/sqlite"
+	"agent-server/internal/tools"
+	"agent-server/internal/tools/builtin"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryTool_RecallRanking(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	stored := map[string]*models.Memory{
+		"diet":   {AgentID: "a1", Topic: "user", Content: "Vegetarian, no mushrooms", MemoryType: "preference", Importance: 5},
+		"tone":   {AgentID: "a1", Topic: "user", Content: "Prefers short answers", MemoryType: "preference", Importance: 9},
+		"travel": {AgentID: "a1", Topic: "user", Content: "Flies to Lisbon in March", MemoryType: "fact", Importance: 7},
+	}
+	for _, memory := range stored {
+		require.NoError(t, repo.Memory().Create(ctx, memory))
+	}
+
+	tool := builtin.NewMemoryTool(repo.Memory())
+	recall := func(hints builtin.RecallHints, limit float64) []map[string]interface{} {
+		result := tool.Execute(tools.ExecutionContext{Context: builtin.WithRecallHints(ctx, hints), AgentID: "a1"},
+			map[string]interface{}{"action": "recall", "topic": "user", "limit": limit})
+		require.True(t, result.Success, result.Error)
+		return result.Data.(map[string]interface{})["memories"].([]map[string]interface{})
+	}
+
+	// Fresh memories of equal recency rank by importance
+	memories := recall(builtin.RecallHints{}, 2)
+	require.Len(t, memories, 2)
+	assert.Equal(t, stored["tone"].ID, memories[0]["id"])
+	assert.Equal(t, stored["travel"].ID, memories[1]["id"])
+	assert.Equal(t, 1, memories[0]["access_count"])
+
+	// The recall was recorded without touching updated_at
+	tone, err := repo.Memory().GetByID(ctx, stored["tone"].ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, tone.AccessCount)
+	require.NotNil(t, tone.LastAccessedAt)
+	assert.Equal(t, stored["tone"].UpdatedAt.Unix(), tone.UpdatedAt.Unix())
+
+	// Similarity to the message outweighs importance when weighted so
+	similar := &models.AgentMemoryRanking{Importance: 0.2, Similarity: 1}
+	for i := 0; i < 2; i++ {
+		memories = recall(builtin.RecallHints{Ranking: similar, Message: "Any mushrooms in the vegetarian menu?"}, 1)
+		require.Len(t, memories, 1)
+		assert.Equal(t, stored["diet"].ID, memories[0]["id"])
+	}
+
+	// Frequently recalled memories rise
+	frequent := &models.AgentMemoryRanking{Frequency: 1}
+	memories = recall(builtin.RecallHints{Ranking: frequent}, 3)
+	assert.Equal(t, stored["diet"].ID, memories[0]["id"])
+	assert.Equal(t, 1.0, memories[0]["score"])
+}
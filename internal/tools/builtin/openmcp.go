@@ -123,9 +123,7 @@ func NewOpenMCPProxyTool() *OpenMCPProxyTool {
 
 	tool := &OpenMCPProxyTool{
 		HTTPBaseTool: tools.NewHTTPBaseTool("openmcp_proxy", schema, "", 60*time.Second),
-		client: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+		client: tools.NewHTTPClient(60 * time.Second),
 	}
 
 	// Set the execute function
@@ -134,9 +132,22 @@ func NewOpenMCPProxyTool() *OpenMCPProxyTool {
 	return tool
 }
 
+// SetTransport routes the tool's requests through base, keeping audit capture
+func (o *OpenMCPProxyTool) SetTransport(base http.RoundTripper) {
+	o.client.Transport = tools.NewAuditTransport(base)
+}
+
+// SetRedirectPolicy sets how the tool's requests follow redirects
+func (o *OpenMCPProxyTool) SetRedirectPolicy(policy tools.RedirectPolicy) {
+	o.client.CheckRedirect = policy.CheckRedirect
+}
+
 func (o *OpenMCPProxyTool) execute(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
-	serverURL := input["server_url"].(string)
-	action := input["action"].(string)
+	serverURL, ok := input["server_url"].(string)
+	if !ok || serverURL == "" {
+		return tools.ErrorResult("MISSING_SERVER_URL", "server_url is required")
+	}
+	action, _ := input["action"].(string)
 
 	switch action {
 	case "discovery":
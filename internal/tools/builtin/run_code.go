@@ -0,0 +1,259 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+
+	"agent-server/internal/sandbox"
+	"agent-server/internal/storage/blob"
+	"agent-server/internal/tools"
+)
+
+// sandboxPrefix is the blob key prefix of session sandboxes; a session's
+// files are kept under sandbox/<session ID>/
+const sandboxPrefix = "sandbox/"
+
+// CodeRunner runs code in a sandbox
+type CodeRunner interface {
+	Languages() []string
+	Network() bool
+	Run(ctx context.Context, req sandbox.Request) (*sandbox.Result, error)
+}
+
+// RunCodeTool runs code snippets in short-lived containers. Files the code
+// writes are kept in the session's sandbox, where later runs can read them.
+type RunCodeTool struct {
+	*tools.BaseTool
+	runner    CodeRunner
+	store     blob.Store
+	urlExpiry time.Duration
+}
+
+// NewRunCodeTool creates a run_code tool. Generated files are kept in store
+// and linked by URLs valid for urlExpiry; without a store they are listed
+// but not kept.
+func NewRunCodeTool(runner CodeRunner, store blob.Store, urlExpiry time.Duration) *RunCodeTool {
+	network := "without network access"
+	if runner.Network() {
+		network = "with network access"
+	}
+	schema := tools.Schema{
+		Name: "run_code",
+		Description: "Runs a code snippet in an isolated container " + network + " and returns its stdout, stderr and exit code. " +
+			"Files the code writes to its working directory are kept in the conversation's sandbox and returned as artifacts; pass their names as input_files to use them in later runs.",
+		Parameters: []tools.Parameter{
+			{
+				Name:        "language",
+				Type:        "string",
+				Description: "The language of the code",
+				Required:    true,
+				Enum:        runner.Languages(),
+			},
+			{
+				Name:        "code",
+				Type:        "string",
+				Description: "The program to run; print results to stdout",
+				Required:    true,
+			},
+			{
+				Name:        "files",
+				Type:        "object",
+				Description: "Text files to create in the working directory first, mapping file names to their content",
+				Required:    false,
+			},
+			{
+				Name:        "input_files",
+				Type:        "array",
+				Description: "Names of files in the conversation's sandbox, written by earlier runs, to copy into the working directory",
+				Required:    false,
+			},
+			{
+				Name:        "artifacts",
+				Type:        "array",
+				Description: "IDs of artifacts returned by earlier tool calls, such as downloaded images or CSV files, to copy into the working directory under their names",
+				Required:    false,
+			},
+		},
+		Examples: []tools.Example{
+			{
+				Description: "Summarize a CSV file",
+				Input: map[string]interface{}{
+					"language": "python",
+					"code":     "import csv\nrows = list(csv.DictReader(open('sales.csv')))\nprint(sum(float(r['amount']) for r in rows))",
+					"files":    map[string]interface{}{"sales.csv": "region,amount\nnorth,120.5\nsouth,80\n"},
+				},
+				Output: map[string]interface{}{
+					"language":    "python",
+					"exit_code":   0,
+					"stdout":      "200.5\n",
+					"stderr":      "",
+					"timed_out":   false,
+					"duration_ms": 412,
+				},
+			},
+		},
+	}
+
+	tool := &RunCodeTool{runner: runner, store: store, urlExpiry: urlExpiry}
+	tool.BaseTool = tools.NewBaseTool("run_code", schema, tool.execute)
+
+	return tool
+}
+
+func (t *RunCodeTool) execute(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
+	language, _ := input["language"].(string)
+	code, _ := input["code"].(string)
+	if code == "" {
+		return tools.ErrorResult("INVALID_INPUT", "code is required")
+	}
+
+	files := map[string][]byte{}
+	if inline, ok := input["files"].(map[string]interface{}); ok {
+		for name, content := range inline {
+			text, ok := content.(string)
+			if !ok {
+				return tools.ErrorResult("INVALID_INPUT", fmt.Sprintf("content of file %s must be a string", name))
+			}
+			files[name] = []byte(text)
+		}
+	}
+	if names, ok := input["input_files"].([]interface{}); ok {
+		for _, entry := range names {
+			name, _ := entry.(string)
+			if _, exists := files[name]; exists {
+				return tools.ErrorResult("INVALID_INPUT", fmt.Sprintf("file %s is given twice", name))
+			}
+			data, err := t.readSandboxFile(ctx.Context, ctx.SessionID, name)
+			if err != nil {
+				return tools.ErrorResult("FILE_NOT_FOUND", err.Error())
+			}
+			files[name] = data
+		}
+	}
+	if ids, ok := input["artifacts"].([]interface{}); ok {
+		for _, entry := range ids {
+			id, _ := entry.(string)
+			artifact, err := ctx.OpenArtifact(id)
+			if err != nil {
+				return tools.ErrorResult("ARTIFACT_NOT_FOUND", err.Error())
+			}
+			if _, exists := files[artifact.Name]; exists {
+				return tools.ErrorResult("INVALID_INPUT", fmt.Sprintf("file %s is given twice", artifact.Name))
+			}
+			files[artifact.Name] = artifact.Data
+		}
+	}
+
+	result, err := t.runner.Run(ctx.Context, sandbox.Request{Language: language, Code: code, Files: files})
+	switch {
+	case errors.Is(err, sandbox.ErrUnsupportedLanguage), errors.Is(err, sandbox.ErrInvalidFileName):
+		return tools.ErrorResult("INVALID_INPUT", err.Error())
+	case err != nil:
+		return tools.ErrorResult("SANDBOX_ERROR", fmt.Sprintf("Failed to run code: %v", err))
+	}
+
+	output := map[string]interface{}{
+		"language":    result.Language,
+		"exit_code":   result.ExitCode,
+		"stdout":      result.Stdout,
+		"stderr":      result.Stderr,
+		"timed_out":   result.TimedOut,
+		"duration_ms": result.Duration.Milliseconds(),
+	}
+	if result.Truncated {
+		output["truncated"] = true
+	}
+	var artifacts []tools.Artifact
+	if len(result.Files) > 0 {
+		saved, note := t.saveFiles(ctx.Context, ctx.SessionID, result.Files)
+		output["files"] = saved
+		if note != "" {
+			output["note"] = note
+		}
+		// Generated files are artifacts too, which chat responses link and
+		// other tools accept
+		if ctx.Artifacts != nil {
+			for _, file := range result.Files {
+				artifacts = append(artifacts, tools.NewArtifact(file.Name, "", file.Data))
+			}
+		}
+	}
+	if len(result.Skipped) > 0 {
+		output["skipped_files"] = result.Skipped
+	}
+	toolResult := tools.SuccessResult(output)
+	toolResult.Artifacts = artifacts
+	return toolResult
+}
+
+// readSandboxFile reads a file of the session's sandbox
+func (t *RunCodeTool) readSandboxFile(ctx context.Context, sessionID, name string) ([]byte, error) {
+	if t.store == nil || sessionID == "" {
+		return nil, fmt.Errorf("file %s not found: the sandbox keeps no files", name)
+	}
+	key, err := sandboxKey(sessionID, name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file name %q", name)
+	}
+	reader, _, err := t.store.Get(ctx, key)
+	if errors.Is(err, blob.ErrNotFound) {
+		return nil, fmt.Errorf("file %s not found in the sandbox", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// saveFiles keeps generated files in the session's sandbox and describes
+// them, with download URLs. The note explains files that were not kept.
+func (t *RunCodeTool) saveFiles(ctx context.Context, sessionID string, files []sandbox.File) ([]map[string]interface{}, string) {
+	var saved []map[string]interface{}
+	note := ""
+	for _, file := range files {
+		contentType := mime.TypeByExtension(path.Ext(file.Name))
+		if contentType == "" {
+			contentType = http.DetectContentType(file.Data)
+		}
+		entry := map[string]interface{}{
+			"name":         file.Name,
+			"size":         len(file.Data),
+			"content_type": contentType,
+		}
+		saved = append(saved, entry)
+
+		if t.store == nil || sessionID == "" {
+			note = "generated files were not kept: no sandbox storage for this conversation"
+			continue
+		}
+		key, err := sandboxKey(sessionID, file.Name)
+		if err == nil {
+			err = t.store.Put(ctx, key, bytes.NewReader(file.Data), int64(len(file.Data)), contentType)
+		}
+		if err != nil {
+			note = fmt.Sprintf("some generated files were not kept: %v", err)
+			continue
+		}
+		if url, err := t.store.SignedURL(ctx, key, t.urlExpiry); err == nil {
+			entry["url"] = url
+		}
+	}
+	return saved, note
+}
+
+// sandboxKey returns the blob key of a file in a session's sandbox
+func sandboxKey(sessionID, name string) (string, error) {
+	cleaned, err := blob.CleanKey(name)
+	if err != nil || cleaned != name {
+		return "", blob.ErrInvalidKey
+	}
+	return blob.CleanKey(sandboxPrefix + sessionID + "/" + name)
+}
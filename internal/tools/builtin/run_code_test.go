@@ -0,0 +1,154 @@
+package builtin_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"agent-server/internal/sandbox"
+	"agent-server/internal/storage/blob"
+	"agent-server/internal/tools"
+	"agent-server/internal/tools/builtin"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRunner reverses the lines of data.txt into result.txt, as code in a
+// container could
+type fakeRunner struct {
+	requests []sandbox.Request
+	network  bool
+}
+
+func (r *fakeRunner) Languages() []string { return []string{sandbox.Python} }
+
+func (r *fakeRunner) Network() bool { return r.network }
+
+func (r *fakeRunner) Run(ctx context.Context, req sandbox.Request) (*sandbox.Result, error) {
+	r.requests = append(r.requests, req)
+	data := req.Files["data.txt"]
+	reversed := make([]byte, len(data))
+	for i, b := range data {
+		reversed[len(data)-1-i] = b
+	}
+	return &sandbox.Result{
+		Language: sandbox.Python,
+		Stdout:   "done\n",
+		Duration: 50 * time.Millisecond,
+		Files:    []sandbox.File{{Name: "out/result.txt", Data: reversed}},
+		Skipped:  []string{"huge.bin"},
+	}, nil
+}
+
+func TestRunCodeTool(t *testing.T) {
+	store, err := blob.NewLocalStore(t.TempDir(), "/api/v1/blobs", "secret")
+	require.NoError(t, err)
+	runner := &fakeRunner{}
+	tool := builtin.NewRunCodeTool(runner, store, time.Hour)
+	ctx := tools.ExecutionContext{Context: context.Background(), SessionID: "s1"}
+
+	result := tool.Execute(ctx, map[string]interface{}{
+		"language": "python",
+		"code":     "...",
+		"files":    map[string]interface{}{"data.txt": "abc"},
+	})
+	require.True(t, result.Success, result.Error)
+	data := result.Data.(map[string]interface{})
+	assert.Equal(t, 0, data["exit_code"])
+	assert.Equal(t, "done\n", data["stdout"])
+	assert.EqualValues(t, 50, data["duration_ms"])
+	assert.Equal(t, []string{"huge.bin"}, data["skipped_files"])
+	files := data["files"].([]map[string]interface{})
+	require.Len(t, files, 1)
+	assert.Equal(t, "out/result.txt", files[0]["name"])
+	assert.Contains(t, files[0]["content_type"], "text/plain")
+	assert.Contains(t, files[0]["url"], "/api/v1/blobs/sandbox/s1/out/result.txt")
+
+	// Generated files are kept in the session's sandbox
+	reader, _, err := store.Get(context.Background(), "sandbox/s1/out/result.txt")
+	require.NoError(t, err)
+	content, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "cba", string(content))
+
+	// and later runs of the session can read them
+	result = tool.Execute(ctx, map[string]interface{}{
+		"language":    "python",
+		"code":        "...",
+		"input_files": []interface{}{"out/result.txt"},
+	})
+	require.True(t, result.Success, result.Error)
+	assert.Equal(t, []byte("cba"), runner.requests[1].Files["out/result.txt"])
+
+	// a sandbox file cannot replace an inline file of the same name
+	result = tool.Execute(ctx, map[string]interface{}{
+		"language":    "python",
+		"code":        "...",
+		"files":       map[string]interface{}{"out/result.txt": "abc"},
+		"input_files": []interface{}{"out/result.txt"},
+	})
+	assert.Equal(t, "INVALID_INPUT", result.ErrorCode)
+
+	// other sessions cannot
+	other := tools.ExecutionContext{Context: context.Background(), SessionID: "s2"}
+	result = tool.Execute(other, map[string]interface{}{
+		"language":    "python",
+		"code":        "...",
+		"input_files": []interface{}{"out/result.txt"},
+	})
+	assert.Equal(t, "FILE_NOT_FOUND", result.ErrorCode)
+	result = tool.Execute(other, map[string]interface{}{
+		"language":    "python",
+		"code":        "...",
+		"input_files": []interface{}{"../s1/out/result.txt"},
+	})
+	assert.Equal(t, "FILE_NOT_FOUND", result.ErrorCode)
+	assert.Len(t, runner.requests, 2)
+
+	// Artifacts of earlier calls are copied in by ID, and generated files
+	// are returned as artifacts
+	input := tools.NewArtifact("data.txt", "", []byte("xyz"))
+	ctx.Artifacts = memoryArtifacts{"a1": &input}
+	result = tool.Execute(ctx, map[string]interface{}{
+		"language":  "python",
+		"code":      "...",
+		"artifacts": []interface{}{"a1"},
+	})
+	require.True(t, result.Success, result.Error)
+	require.Len(t, result.Artifacts, 1)
+	assert.Equal(t, "out/result.txt", result.Artifacts[0].Name)
+	assert.Equal(t, []byte("zyx"), result.Artifacts[0].Data)
+	result = tool.Execute(ctx, map[string]interface{}{
+		"language":  "python",
+		"code":      "...",
+		"artifacts": []interface{}{"a2"},
+	})
+	assert.Equal(t, "ARTIFACT_NOT_FOUND", result.ErrorCode)
+	result = tool.Execute(ctx, map[string]interface{}{
+		"language":  "python",
+		"code":      "...",
+		"files":     map[string]interface{}{"data.txt": "abc"},
+		"artifacts": []interface{}{"a1"},
+	})
+	assert.Equal(t, "INVALID_INPUT", result.ErrorCode)
+	ctx.Artifacts = nil
+
+	// Without storage, files are listed but not kept
+	tool = builtin.NewRunCodeTool(runner, nil, time.Hour)
+	result = tool.Execute(ctx, map[string]interface{}{"language": "python", "code": "..."})
+	require.True(t, result.Success, result.Error)
+	data = result.Data.(map[string]interface{})
+	assert.NotContains(t, data["files"].([]map[string]interface{})[0], "url")
+	assert.NotEmpty(t, data["note"])
+}
+
+func TestRunCodeTool_DescribesNetworkAccess(t *testing.T) {
+	offline := builtin.NewRunCodeTool(&fakeRunner{}, nil, time.Hour)
+	assert.Contains(t, offline.Schema().Description, "without network access")
+
+	online := builtin.NewRunCodeTool(&fakeRunner{network: true}, nil, time.Hour)
+	assert.Contains(t, online.Schema().Description, "with network access")
+	assert.NotContains(t, online.Schema().Description, "without network access")
+}
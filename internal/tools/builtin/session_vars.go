@@ -0,0 +1,81 @@
+package builtin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"agent-server/internal/storage"
+	"agent-server/internal/tools"
+)
+
+// SessionVarsTool reads the structured variables an integration has set on
+// the session, such as a customer or order ID
+type SessionVarsTool struct {
+	*tools.BaseTool
+	sessions storage.SessionRepository
+}
+
+// NewSessionVarsTool creates a new session_vars tool
+func NewSessionVarsTool(sessions storage.SessionRepository) *SessionVarsTool {
+	schema := tools.Schema{
+		Name:        "session_vars",
+		Description: "Read the variables of the current conversation, such as customer or order IDs set by the application",
+		Parameters: []tools.Parameter{
+			{
+				Name:        "name",
+				Type:        "string",
+				Description: "The variable to read; omit it to read all variables",
+				Required:    false,
+			},
+		},
+		Examples: []tools.Example{
+			{
+				Description: "Read the customer ID of the conversation",
+				Input: map[string]interface{}{
+					"name": "customer_id",
+				},
+				Output: map[string]interface{}{
+					"name":  "customer_id",
+					"value": "C-1042",
+				},
+			},
+		},
+	}
+
+	tool := &SessionVarsTool{sessions: sessions}
+	tool.BaseTool = tools.NewBaseTool("session_vars", schema, tool.execute)
+
+	return tool
+}
+
+func (t *SessionVarsTool) execute(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
+	session, err := t.sessions.GetByID(ctx.Context, ctx.SessionID)
+	if err != nil {
+		return tools.ErrorResult("DATABASE_ERROR", fmt.Sprintf("Failed to get session: %v", err))
+	}
+	if session == nil {
+		return tools.ErrorResult("SESSION_NOT_FOUND", "The conversation has no session")
+	}
+
+	name, _ := input["name"].(string)
+	if name == "" {
+		variables := map[string]interface{}(session.Variables)
+		if variables == nil {
+			variables = map[string]interface{}{}
+		}
+		return tools.SuccessResult(map[string]interface{}{"variables": variables})
+	}
+
+	value, ok := session.Variables[name]
+	if !ok {
+		names := make([]string, 0, len(session.Variables))
+		for variable := range session.Variables {
+			names = append(names, variable)
+		}
+		sort.Strings(names)
+		return tools.ErrorResult("VARIABLE_NOT_FOUND",
+			fmt.Sprintf("Variable '%s' is not set; set variables: %s", name, strings.Join(names, ", ")))
+	}
+	return tools.SuccessResult(map[string]interface{}{"name": name, "value": value})
+}
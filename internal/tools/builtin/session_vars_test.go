@@ -0,0 +1,47 @@
+package builtin_test
+
+import (
+	"context"
+	"testing"
+
+	"agent-server/internal/models"
+	"agent-server/internal/storage/sqlite"
+	"agent-server/internal/tools"
+	"agent-server/internal/tools/builtin"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionVarsTool(t *testing.T) {
+	repo, err := sqlite.NewRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+
+	agent := &models.Agent{Name: "support", Provider: "ollama", Model: "llama3", SystemPrompt: "test"}
+	require.NoError(t, repo.Agent().Create(context.Background(), agent))
+	session := &models.ChatSession{AgentID: agent.ID, Variables: models.JSON{"customer_id": "C-1042", "order": map[string]interface{}{"id": float64(7)}}}
+	require.NoError(t, repo.Session().Create(context.Background(), session))
+
+	tool := builtin.NewSessionVarsTool(repo.Session())
+	ctx := tools.ExecutionContext{Context: context.Background(), SessionID: session.ID}
+
+	result := tool.Execute(ctx, map[string]interface{}{"name": "customer_id"})
+	require.True(t, result.Success, result.Error)
+	assert.Equal(t, "C-1042", result.Data.(map[string]interface{})["value"])
+
+	result = tool.Execute(ctx, map[string]interface{}{})
+	require.True(t, result.Success, result.Error)
+	variables := result.Data.(map[string]interface{})["variables"].(map[string]interface{})
+	assert.Len(t, variables, 2)
+
+	// Unknown variables are reported with the ones that are set
+	result = tool.Execute(ctx, map[string]interface{}{"name": "order_id"})
+	assert.False(t, result.Success)
+	assert.Equal(t, "VARIABLE_NOT_FOUND", result.ErrorCode)
+	assert.Contains(t, result.Error, "customer_id, order")
+
+	// A session that does not exist has no variables
+	result = tool.Execute(tools.ExecutionContext{Context: context.Background(), SessionID: "missing"}, map[string]interface{}{})
+	assert.Equal(t, "SESSION_NOT_FOUND", result.ErrorCode)
+}
@@ -0,0 +1,395 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// maxPatternLength bounds user supplied regular expressions
+	maxPatternLength = 1000
+
+	// regexTimeout bounds how long a single regex operation may run. Go's
+	// RE2 engine matches in linear time, so there is no catastrophic
+	// backtracking, but large inputs with expensive patterns are still cut
+	// off rather than holding the tool call.
+	regexTimeout = 2 * time.Second
+
+	// wordsPerMinute is the reading speed used for reading time estimates
+	wordsPerMinute = 200
+)
+
+var (
+	templatePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.]*)\s*\}\}`)
+	sentenceEnd         = regexp.MustCompile(`[.!?]+(\s+|$)`)
+	paragraphBreak      = regexp.MustCompile(`\n\s*\n`)
+)
+
+// compilePattern validates and compiles a user supplied regular expression
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
+	if len(pattern) > maxPatternLength {
+		return nil, fmt.Errorf("pattern exceeds %d characters", maxPatternLength)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+	return re, nil
+}
+
+// runWithTimeout runs fn, giving up when regexTimeout or the context
+// deadline passes first
+func runWithTimeout(ctx context.Context, fn func() interface{}) (interface{}, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, regexTimeout)
+	defer cancel()
+
+	done := make(chan interface{}, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case result := <-done:
+		return result, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("regex operation timed out: %v", ctx.Err())
+	}
+}
+
+// regexMatch reports whether the pattern matches and lists the matches
+func regexMatch(ctx context.Context, text string, re *regexp.Regexp) (interface{}, error) {
+	return runWithTimeout(ctx, func() interface{} {
+		matches := re.FindAllString(text, -1)
+		if matches == nil {
+			matches = []string{}
+		}
+		return map[string]interface{}{
+			"matched": len(matches) > 0,
+			"count":   len(matches),
+			"matches": matches,
+		}
+	})
+}
+
+// regexExtract returns every match with its capture groups, keyed by name for
+// named groups
+func regexExtract(ctx context.Context, text string, re *regexp.Regexp) (interface{}, error) {
+	return runWithTimeout(ctx, func() interface{} {
+		names := re.SubexpNames()
+		extracted := []map[string]interface{}{}
+		for _, submatches := range re.FindAllStringSubmatch(text, -1) {
+			named := map[string]string{}
+			for i, name := range names {
+				if i > 0 && name != "" {
+					named[name] = submatches[i]
+				}
+			}
+			extracted = append(extracted, map[string]interface{}{
+				"match":  submatches[0],
+				"groups": submatches[1:],
+				"named":  named,
+			})
+		}
+		return extracted
+	})
+}
+
+// regexReplace replaces every match; the replacement may reference groups
+// as $1 or ${name}
+func regexReplace(ctx context.Context, text string, re *regexp.Regexp, replacement string) (interface{}, error) {
+	return runWithTimeout(ctx, func() interface{} {
+		return re.ReplaceAllString(text, replacement)
+	})
+}
+
+// splitText splits on the pattern, or on line breaks when re is nil
+func splitText(ctx context.Context, text string, re *regexp.Regexp) (interface{}, error) {
+	if re == nil {
+		return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n"), nil
+	}
+	return runWithTimeout(ctx, func() interface{} {
+		return re.Split(text, -1)
+	})
+}
+
+// renderTemplate substitutes {{name}} placeholders with variables. Unknown
+// placeholders are left in place and reported as missing.
+func renderTemplate(text string, variables map[string]interface{}) map[string]interface{} {
+	missing := []string{}
+	seen := map[string]bool{}
+	rendered := templatePlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := templatePlaceholder.FindStringSubmatch(placeholder)[1]
+		value, ok := variables[name]
+		if !ok {
+			if !seen[name] {
+				seen[name] = true
+				missing = append(missing, name)
+			}
+			return placeholder
+		}
+		return fmt.Sprint(value)
+	})
+	return map[string]interface{}{
+		"text":    rendered,
+		"missing": missing,
+	}
+}
+
+// textStats summarizes the length and readability of text
+func textStats(text string) map[string]interface{} {
+	words := len(strings.Fields(text))
+	trimmed := strings.TrimSpace(text)
+
+	sentences := 0
+	paragraphs := 0
+	if trimmed != "" {
+		sentences = len(sentenceEnd.FindAllStringIndex(trimmed, -1))
+		// Text without terminal punctuation still holds one sentence
+		if last := rune(trimmed[len(trimmed)-1]); !strings.ContainsRune(".!?", last) {
+			sentences++
+		}
+		paragraphs = len(paragraphBreak.Split(trimmed, -1))
+	}
+
+	letters := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			letters++
+		}
+	}
+
+	avgWords := 0.0
+	if sentences > 0 {
+		avgWords = math.Round(float64(words)/float64(sentences)*10) / 10
+	}
+
+	return map[string]interface{}{
+		"characters":             len([]rune(text)),
+		"letters":                letters,
+		"words":                  words,
+		"sentences":              sentences,
+		"paragraphs":             paragraphs,
+		"avg_words_per_sentence": avgWords,
+		"reading_time_minutes":   math.Ceil(float64(words) / wordsPerMinute),
+	}
+}
+
+const (
+	// defaultChunkSize is the chunk size when none is given
+	defaultChunkSize = 200
+
+	// diffContextLines is the number of unchanged lines around each hunk
+	diffContextLines = 3
+
+	// maxDiffCells bounds the line comparison table of a diff
+	maxDiffCells = 4_000_000
+)
+
+// chunkText splits text into chunks of size units with overlap units shared
+// between neighbours. Units are sentences, or tokens approximated by
+// whitespace-separated words.
+func chunkText(text, by string, size, overlap int) (map[string]interface{}, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("chunk_size must be positive")
+	}
+	if overlap < 0 || overlap >= size {
+		return nil, fmt.Errorf("overlap must be between 0 and chunk_size-1")
+	}
+
+	var units []string
+	switch by {
+	case "", "tokens":
+		by = "tokens"
+		units = strings.Fields(text)
+	case "sentences":
+		units = splitSentences(text)
+	default:
+		return nil, fmt.Errorf("unknown chunk_by '%s'", by)
+	}
+
+	chunks := []map[string]interface{}{}
+	for start := 0; start < len(units); start += size - overlap {
+		end := start + size
+		if end > len(units) {
+			end = len(units)
+		}
+		chunks = append(chunks, map[string]interface{}{
+			"index": len(chunks),
+			"text":  strings.Join(units[start:end], " "),
+			"start": start,
+			"units": end - start,
+		})
+		if end == len(units) {
+			break
+		}
+	}
+
+	return map[string]interface{}{
+		"chunks":   chunks,
+		"count":    len(chunks),
+		"chunk_by": by,
+	}, nil
+}
+
+// splitSentences returns the trimmed sentences of text
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for _, loc := range sentenceEnd.FindAllStringIndex(text, -1) {
+		if sentence := strings.TrimSpace(text[start:loc[1]]); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = loc[1]
+	}
+	if rest := strings.TrimSpace(text[start:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	return sentences
+}
+
+// diffOp is one line of a line diff: ' ' unchanged, '-' removed, '+' added.
+// aLine and bLine are the zero-based positions in both texts before the line.
+type diffOp struct {
+	kind  byte
+	text  string
+	aLine int
+	bLine int
+}
+
+// unifiedDiff returns a unified diff that turns text into other
+func unifiedDiff(text, other string) (map[string]interface{}, error) {
+	a := splitLines(text)
+	b := splitLines(other)
+	if len(a)*len(b) > maxDiffCells {
+		return nil, fmt.Errorf("texts are too large to diff (%d x %d lines)", len(a), len(b))
+	}
+
+	ops := diffLines(a, b)
+	additions, deletions := 0, 0
+	var changes []int
+	for i, op := range ops {
+		switch op.kind {
+		case '+':
+			additions++
+		case '-':
+			deletions++
+		default:
+			continue
+		}
+		changes = append(changes, i)
+	}
+
+	var out strings.Builder
+	if len(changes) > 0 {
+		out.WriteString("--- original\n+++ modified\n")
+	}
+	for i := 0; i < len(changes); {
+		// Changes closer than twice the context share a hunk
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*diffContextLines {
+			j++
+		}
+		from := changes[i] - diffContextLines
+		if from < 0 {
+			from = 0
+		}
+		to := changes[j] + diffContextLines
+		if to >= len(ops) {
+			to = len(ops) - 1
+		}
+		writeHunk(&out, ops[from:to+1])
+		i = j + 1
+	}
+
+	return map[string]interface{}{
+		"diff":      out.String(),
+		"equal":     len(changes) == 0,
+		"additions": additions,
+		"deletions": deletions,
+	}, nil
+}
+
+// diffLines computes the line operations from a longest common subsequence
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', text: a[i], aLine: i, bLine: j})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, diffOp{kind: '+', text: b[j], aLine: i, bLine: j})
+			j++
+		default:
+			ops = append(ops, diffOp{kind: '-', text: a[i], aLine: i, bLine: j})
+			i++
+		}
+	}
+	return ops
+}
+
+// writeHunk writes one hunk with its @@ header
+func writeHunk(out *strings.Builder, ops []diffOp) {
+	aCount, bCount := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			aCount++
+		}
+		if op.kind != '-' {
+			bCount++
+		}
+	}
+	// Empty ranges name the line before them, as in diff -u
+	aStart, bStart := ops[0].aLine, ops[0].bLine
+	if aCount > 0 {
+		aStart++
+	}
+	if bCount > 0 {
+		bStart++
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+	for _, op := range ops {
+		out.WriteByte(op.kind)
+		out.WriteString(op.text)
+		out.WriteByte('\n')
+	}
+}
+
+// splitLines splits text into lines without their line breaks
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -43,6 +43,14 @@ func NewCalculatorTool() *CalculatorTool {
 				Output:      map[string]interface{}{"result": 4, "expression": "sqrt(16)"},
 			},
 		},
+		Output: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"result":     map[string]interface{}{"type": "number", "description": "Value of the expression"},
+				"expression": map[string]interface{}{"type": "string", "description": "The expression as evaluated"},
+			},
+			"required": []string{"result", "expression"},
+		},
 	}
 
 	tool := &CalculatorTool{}
@@ -52,7 +60,10 @@ func NewCalculatorTool() *CalculatorTool {
 }
 
 func (c *CalculatorTool) execute(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
-	expression := input["expression"].(string)
+	expression, ok := input["expression"].(string)
+	if !ok || expression == "" {
+		return tools.ErrorResult("MISSING_EXPRESSION", "expression is required")
+	}
 	
 	// Simple expression evaluator (basic implementation)
 	result, err := evaluateExpression(expression)
@@ -88,12 +99,52 @@ func NewTextProcessorTool() *TextProcessorTool {
 				Type:        "string",
 				Description: "Text operation to perform",
 				Required:    true,
-				Enum:        []string{"uppercase", "lowercase", "title_case", "word_count", "char_count", "reverse", "trim", "extract_emails", "extract_urls"},
+				Enum:        []string{"uppercase", "lowercase", "title_case", "word_count", "char_count", "reverse", "trim", "extract_emails", "extract_urls", "regex_match", "regex_extract", "regex_replace", "split", "template", "stats", "chunk", "diff"},
 			},
 			{
 				Name:        "pattern",
 				Type:        "string",
-				Description: "Pattern for regex operations (optional)",
+				Description: "Regular expression (RE2 syntax) for regex_match, regex_extract, regex_replace and split; split uses line breaks without one",
+				Required:    false,
+			},
+			{
+				Name:        "replacement",
+				Type:        "string",
+				Description: "Replacement for regex_replace; reference groups as $1 or ${name}",
+				Required:    false,
+			},
+			{
+				Name:        "variables",
+				Type:        "object",
+				Description: "Values for the {{name}} placeholders in text for the template operation",
+				Required:    false,
+			},
+			{
+				Name:        "chunk_by",
+				Type:        "string",
+				Description: "Unit for the chunk operation; tokens are approximated by words",
+				Required:    false,
+				Enum:        []string{"tokens", "sentences"},
+				Default:     "tokens",
+			},
+			{
+				Name:        "chunk_size",
+				Type:        "number",
+				Description: "Units per chunk for the chunk operation",
+				Required:    false,
+				Default:     defaultChunkSize,
+			},
+			{
+				Name:        "overlap",
+				Type:        "number",
+				Description: "Units shared between neighbouring chunks for the chunk operation",
+				Required:    false,
+				Default:     0,
+			},
+			{
+				Name:        "other_text",
+				Type:        "string",
+				Description: "Changed text to compare text against for the diff operation",
 				Required:    false,
 			},
 		},
@@ -130,8 +181,11 @@ func NewTextProcessorTool() *TextProcessorTool {
 }
 
 func (t *TextProcessorTool) execute(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
-	text := input["text"].(string)
-	operation := input["operation"].(string)
+	text, ok := input["text"].(string)
+	if !ok {
+		return tools.ErrorResult("MISSING_TEXT", "text is required")
+	}
+	operation, _ := input["operation"].(string)
 
 	var result interface{}
 	var err error
@@ -156,6 +210,52 @@ func (t *TextProcessorTool) execute(ctx tools.ExecutionContext, input map[string
 		result, err = extractEmails(text)
 	case "extract_urls":
 		result, err = extractURLs(text)
+	case "regex_match", "regex_extract", "regex_replace", "split":
+		pattern, _ := input["pattern"].(string)
+		var re *regexp.Regexp
+		if pattern != "" {
+			if re, err = compilePattern(pattern); err != nil {
+				return tools.ErrorResult("INVALID_PATTERN", err.Error())
+			}
+		} else if operation != "split" {
+			return tools.ErrorResult("MISSING_PATTERN", fmt.Sprintf("pattern is required for %s operation", operation))
+		}
+		switch operation {
+		case "regex_match":
+			result, err = regexMatch(ctx.Context, text, re)
+		case "regex_extract":
+			result, err = regexExtract(ctx.Context, text, re)
+		case "regex_replace":
+			replacement, ok := input["replacement"].(string)
+			if !ok {
+				return tools.ErrorResult("MISSING_REPLACEMENT", "replacement is required for regex_replace operation")
+			}
+			result, err = regexReplace(ctx.Context, text, re, replacement)
+		default:
+			result, err = splitText(ctx.Context, text, re)
+		}
+	case "template":
+		variables, _ := input["variables"].(map[string]interface{})
+		result = renderTemplate(text, variables)
+	case "stats":
+		result = textStats(text)
+	case "chunk":
+		chunkBy, _ := input["chunk_by"].(string)
+		size := defaultChunkSize
+		if v, ok := input["chunk_size"].(float64); ok {
+			size = int(v)
+		}
+		overlap := 0
+		if v, ok := input["overlap"].(float64); ok {
+			overlap = int(v)
+		}
+		result, err = chunkText(text, chunkBy, size, overlap)
+	case "diff":
+		otherText, ok := input["other_text"].(string)
+		if !ok {
+			return tools.ErrorResult("MISSING_OTHER_TEXT", "other_text is required for diff operation")
+		}
+		result, err = unifiedDiff(text, otherText)
 	default:
 		return tools.ErrorResult("INVALID_OPERATION", fmt.Sprintf("Unknown operation: %s", operation))
 	}
@@ -193,12 +293,30 @@ func NewJSONProcessorTool() *JSONProcessorTool {
 				Type:        "string",
 				Description: "JSON operation to perform",
 				Required:    true,
-				Enum:        []string{"validate", "pretty_print", "minify", "extract_keys", "get_value"},
+				Enum:        []string{"validate", "pretty_print", "minify", "extract_keys", "get_value", "set_value", "delete_key", "diff"},
 			},
 			{
 				Name:        "path",
 				Type:        "string",
-				Description: "JSON path for get_value operation (e.g., 'user.name')",
+				Description: "JSON path for get_value, set_value and delete_key (e.g., 'user.name', 'items[0].id', 'items[*].id', '$..id')",
+				Required:    false,
+			},
+			{
+				Name:        "value",
+				Type:        "string",
+				Description: "JSON-encoded value for set_value; plain text is stored as a string",
+				Required:    false,
+			},
+			{
+				Name:        "other_json",
+				Type:        "string",
+				Description: "JSON string to compare json_data against for diff",
+				Required:    false,
+			},
+			{
+				Name:        "schema",
+				Type:        "string",
+				Description: "JSON Schema to check json_data against for validate",
 				Required:    false,
 			},
 		},
@@ -223,8 +341,11 @@ func NewJSONProcessorTool() *JSONProcessorTool {
 }
 
 func (j *JSONProcessorTool) execute(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
-	jsonData := input["json_data"].(string)
-	operation := input["operation"].(string)
+	jsonData, ok := input["json_data"].(string)
+	if !ok {
+		return tools.ErrorResult("MISSING_JSON_DATA", "json_data is required")
+	}
+	operation, _ := input["operation"].(string)
 
 	// Parse JSON first
 	var data interface{}
@@ -237,9 +358,27 @@ func (j *JSONProcessorTool) execute(ctx tools.ExecutionContext, input map[string
 
 	switch operation {
 	case "validate":
+		schemaJSON, _ := input["schema"].(string)
+		if schemaJSON == "" {
+			result = map[string]interface{}{
+				"valid":   true,
+				"message": "JSON is valid",
+			}
+			break
+		}
+		var schema map[string]interface{}
+		if err := json.Unmarshal([]byte(schemaJSON), &schema); err != nil {
+			return tools.ErrorResult("INVALID_SCHEMA", fmt.Sprintf("Invalid JSON Schema: %v", err))
+		}
+		errs := tools.ValidateJSONSchema(data, schema)
+		message := "JSON matches the schema"
+		if len(errs) > 0 {
+			message = fmt.Sprintf("JSON does not match the schema: %d error(s)", len(errs))
+		}
 		result = map[string]interface{}{
-			"valid":   true,
-			"message": "JSON is valid",
+			"valid":   len(errs) == 0,
+			"message": message,
+			"errors":  errs,
 		}
 	case "pretty_print":
 		var prettyJSON []byte
@@ -261,6 +400,44 @@ func (j *JSONProcessorTool) execute(ctx tools.ExecutionContext, input map[string
 			return tools.ErrorResult("MISSING_PATH", "path is required for get_value operation")
 		}
 		result, err = getJSONValue(data, path)
+	case "set_value", "delete_key":
+		path, ok := input["path"].(string)
+		if !ok {
+			return tools.ErrorResult("MISSING_PATH", fmt.Sprintf("path is required for %s operation", operation))
+		}
+		var updated interface{}
+		if operation == "set_value" {
+			rawValue, ok := input["value"].(string)
+			if !ok {
+				return tools.ErrorResult("MISSING_VALUE", "value is required for set_value operation")
+			}
+			var value interface{}
+			if json.Unmarshal([]byte(rawValue), &value) != nil {
+				value = rawValue
+			}
+			updated, err = setJSONValue(data, path, value)
+		} else {
+			updated, err = deleteJSONValue(data, path)
+		}
+		if err == nil {
+			var encoded []byte
+			encoded, err = json.Marshal(updated)
+			result = string(encoded)
+		}
+	case "diff":
+		otherJSON, ok := input["other_json"].(string)
+		if !ok {
+			return tools.ErrorResult("MISSING_OTHER_JSON", "other_json is required for diff operation")
+		}
+		var other interface{}
+		if err := json.Unmarshal([]byte(otherJSON), &other); err != nil {
+			return tools.ErrorResult("INVALID_JSON", fmt.Sprintf("Invalid other_json: %v", err))
+		}
+		changes := diffJSON(data, other)
+		result = map[string]interface{}{
+			"equal":   len(changes) == 0,
+			"changes": changes,
+		}
 	default:
 		return tools.ErrorResult("INVALID_OPERATION", fmt.Sprintf("Unknown operation: %s", operation))
 	}
@@ -396,25 +573,6 @@ func extractJSONKeys(data interface{}) []string {
 	return keys
 }
 
-func getJSONValue(data interface{}, path string) (interface{}, error) {
-	keys := strings.Split(path, ".")
-	current := data
-	
-	for _, key := range keys {
-		if obj, ok := current.(map[string]interface{}); ok {
-			if value, exists := obj[key]; exists {
-				current = value
-			} else {
-				return nil, fmt.Errorf("key '%s' not found", key)
-			}
-		} else {
-			return nil, fmt.Errorf("cannot access key '%s' on non-object", key)
-		}
-	}
-	
-	return current, nil
-}
-
 // RegisterBuiltinTools registers all built-in tools with the registry
 func RegisterBuiltinTools(registry *tools.Registry, memoryRepo storage.MemoryRepository) error {
 	builtinTools := []tools.Tool{
@@ -424,9 +582,11 @@ func RegisterBuiltinTools(registry *tools.Registry, memoryRepo storage.MemoryRep
 		NewCalculatorTool(),
 		NewTextProcessorTool(),
 		NewJSONProcessorTool(),
+		NewEncoderTool(),
 		NewMCPProxyTool(),
 		NewOpenMCPProxyTool(),
 		NewMemoryTool(memoryRepo),
+		NewIssueTrackerTool(),
 	}
 
 	for _, tool := range builtinTools {
@@ -2,6 +2,7 @@ package builtin_test
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 
 	"agent-server/internal/storage/sqlite"
@@ -111,7 +112,7 @@ func TestTextProcessorTool(t *testing.T) {
 		schema := processor.Schema()
 		assert.Equal(t, "text_processor", schema.Name)
 		assert.Contains(t, schema.Description, "text")
-		assert.Len(t, schema.Parameters, 3)
+		assert.Len(t, schema.Parameters, 9)
 		
 		// Check parameters
 		params := make(map[string]tools.Parameter)
@@ -122,7 +123,7 @@ func TestTextProcessorTool(t *testing.T) {
 		assert.True(t, params["text"].Required)
 		assert.True(t, params["operation"].Required)
 		assert.False(t, params["pattern"].Required)
-		assert.Len(t, params["operation"].Enum, 9) // 9 operations
+		assert.Len(t, params["operation"].Enum, 17) // 17 operations
 	})
 
 	t.Run("Uppercase Operation", func(t *testing.T) {
@@ -266,6 +267,212 @@ func TestTextProcessorTool(t *testing.T) {
 		assert.False(t, result.Success)
 		assert.Contains(t, result.Error, "Unknown operation")
 	})
+
+	t.Run("Regex Match Operation", func(t *testing.T) {
+		ctx := tools.ExecutionContext{
+			Context:   context.Background(),
+			SessionID: "test-session",
+		}
+
+		result := processor.Execute(ctx, map[string]interface{}{
+			"text":      "order 12 and order 345",
+			"operation": "regex_match",
+			"pattern":   `\d+`,
+		})
+		assert.True(t, result.Success)
+		match := result.Data.(map[string]interface{})["result"].(map[string]interface{})
+		assert.Equal(t, true, match["matched"])
+		assert.Equal(t, []string{"12", "345"}, match["matches"])
+	})
+
+	t.Run("Regex Extract Operation", func(t *testing.T) {
+		ctx := tools.ExecutionContext{
+			Context:   context.Background(),
+			SessionID: "test-session",
+		}
+
+		result := processor.Execute(ctx, map[string]interface{}{
+			"text":      "alice=1, bob=2",
+			"operation": "regex_extract",
+			"pattern":   `(?P<name>\w+)=(\d+)`,
+		})
+		assert.True(t, result.Success)
+		extracted := result.Data.(map[string]interface{})["result"].([]map[string]interface{})
+		require.Len(t, extracted, 2)
+		assert.Equal(t, "bob=2", extracted[1]["match"])
+		assert.Equal(t, []string{"bob", "2"}, extracted[1]["groups"])
+		assert.Equal(t, map[string]string{"name": "bob"}, extracted[1]["named"])
+	})
+
+	t.Run("Regex Replace Operation", func(t *testing.T) {
+		ctx := tools.ExecutionContext{
+			Context:   context.Background(),
+			SessionID: "test-session",
+		}
+
+		result := processor.Execute(ctx, map[string]interface{}{
+			"text":        "2024-01-31",
+			"operation":   "regex_replace",
+			"pattern":     `(\d+)-(\d+)-(\d+)`,
+			"replacement": "$3.$2.$1",
+		})
+		assert.True(t, result.Success)
+		assert.Equal(t, "31.01.2024", result.Data.(map[string]interface{})["result"])
+	})
+
+	t.Run("Invalid Pattern", func(t *testing.T) {
+		ctx := tools.ExecutionContext{
+			Context:   context.Background(),
+			SessionID: "test-session",
+		}
+
+		result := processor.Execute(ctx, map[string]interface{}{
+			"text":      "hello",
+			"operation": "regex_match",
+			"pattern":   `(a+`,
+		})
+		assert.False(t, result.Success)
+		assert.Equal(t, "INVALID_PATTERN", result.ErrorCode)
+
+		// Backreferences are not supported by the linear-time engine
+		result = processor.Execute(ctx, map[string]interface{}{
+			"text":      "hello",
+			"operation": "regex_match",
+			"pattern":   `(a)\1`,
+		})
+		assert.False(t, result.Success)
+		assert.Equal(t, "INVALID_PATTERN", result.ErrorCode)
+	})
+
+	t.Run("Split Operation", func(t *testing.T) {
+		ctx := tools.ExecutionContext{
+			Context:   context.Background(),
+			SessionID: "test-session",
+		}
+
+		result := processor.Execute(ctx, map[string]interface{}{
+			"text":      "a, b;c",
+			"operation": "split",
+			"pattern":   `[,;]\s*`,
+		})
+		assert.True(t, result.Success)
+		assert.Equal(t, []string{"a", "b", "c"}, result.Data.(map[string]interface{})["result"])
+
+		result = processor.Execute(ctx, map[string]interface{}{
+			"text":      "line one\r\nline two",
+			"operation": "split",
+		})
+		assert.True(t, result.Success)
+		assert.Equal(t, []string{"line one", "line two"}, result.Data.(map[string]interface{})["result"])
+	})
+
+	t.Run("Template Operation", func(t *testing.T) {
+		ctx := tools.ExecutionContext{
+			Context:   context.Background(),
+			SessionID: "test-session",
+		}
+
+		result := processor.Execute(ctx, map[string]interface{}{
+			"text":      "Hello {{ name }}, you have {{count}} new {{items}}",
+			"operation": "template",
+			"variables": map[string]interface{}{"name": "Ada", "count": float64(3)},
+		})
+		assert.True(t, result.Success)
+		rendered := result.Data.(map[string]interface{})["result"].(map[string]interface{})
+		assert.Equal(t, "Hello Ada, you have 3 new {{items}}", rendered["text"])
+		assert.Equal(t, []string{"items"}, rendered["missing"])
+	})
+
+	t.Run("Stats Operation", func(t *testing.T) {
+		ctx := tools.ExecutionContext{
+			Context:   context.Background(),
+			SessionID: "test-session",
+		}
+
+		result := processor.Execute(ctx, map[string]interface{}{
+			"text":      "First sentence. Second one!\n\nA new paragraph",
+			"operation": "stats",
+		})
+		assert.True(t, result.Success)
+		stats := result.Data.(map[string]interface{})["result"].(map[string]interface{})
+		assert.Equal(t, 7, stats["words"])
+		assert.Equal(t, 3, stats["sentences"])
+		assert.Equal(t, 2, stats["paragraphs"])
+		assert.Equal(t, float64(1), stats["reading_time_minutes"])
+	})
+
+	t.Run("Chunk Operation", func(t *testing.T) {
+		ctx := tools.ExecutionContext{
+			Context:   context.Background(),
+			SessionID: "test-session",
+		}
+
+		result := processor.Execute(ctx, map[string]interface{}{
+			"text":       "one two three four five six seven",
+			"operation":  "chunk",
+			"chunk_size": float64(3),
+			"overlap":    float64(1),
+		})
+		assert.True(t, result.Success)
+		chunked := result.Data.(map[string]interface{})["result"].(map[string]interface{})
+		chunks := chunked["chunks"].([]map[string]interface{})
+		require.Len(t, chunks, 3)
+		assert.Equal(t, "one two three", chunks[0]["text"])
+		assert.Equal(t, "three four five", chunks[1]["text"])
+		assert.Equal(t, "five six seven", chunks[2]["text"])
+
+		result = processor.Execute(ctx, map[string]interface{}{
+			"text":       "First one. Second one? Third one! Fourth",
+			"operation":  "chunk",
+			"chunk_by":   "sentences",
+			"chunk_size": float64(2),
+		})
+		assert.True(t, result.Success)
+		chunked = result.Data.(map[string]interface{})["result"].(map[string]interface{})
+		chunks = chunked["chunks"].([]map[string]interface{})
+		require.Len(t, chunks, 2)
+		assert.Equal(t, "First one. Second one?", chunks[0]["text"])
+		assert.Equal(t, "Third one! Fourth", chunks[1]["text"])
+
+		result = processor.Execute(ctx, map[string]interface{}{
+			"text":       "a b c",
+			"operation":  "chunk",
+			"chunk_size": float64(2),
+			"overlap":    float64(2),
+		})
+		assert.False(t, result.Success)
+		assert.Contains(t, result.Error, "overlap")
+	})
+
+	t.Run("Diff Operation", func(t *testing.T) {
+		ctx := tools.ExecutionContext{
+			Context:   context.Background(),
+			SessionID: "test-session",
+		}
+
+		result := processor.Execute(ctx, map[string]interface{}{
+			"text":       "a\nb\nc\nd\ne\nf\ng\nh\ni\n",
+			"operation":  "diff",
+			"other_text": "a\nb\nc\nD\ne\nf\ng\nh\ni\nj\n",
+		})
+		assert.True(t, result.Success)
+		diff := result.Data.(map[string]interface{})["result"].(map[string]interface{})
+		assert.Equal(t, false, diff["equal"])
+		assert.Equal(t, 2, diff["additions"])
+		assert.Equal(t, 1, diff["deletions"])
+		assert.Equal(t, "--- original\n+++ modified\n"+
+			"@@ -1,9 +1,10 @@\n a\n b\n c\n-d\n+D\n e\n f\n g\n h\n i\n+j\n", diff["diff"])
+
+		result = processor.Execute(ctx, map[string]interface{}{
+			"text":       "same",
+			"operation":  "diff",
+			"other_text": "same",
+		})
+		assert.True(t, result.Success)
+		diff = result.Data.(map[string]interface{})["result"].(map[string]interface{})
+		assert.Equal(t, true, diff["equal"])
+		assert.Equal(t, "", diff["diff"])
+	})
 }
 
 func TestJSONProcessorTool(t *testing.T) {
@@ -277,7 +484,7 @@ func TestJSONProcessorTool(t *testing.T) {
 		schema := processor.Schema()
 		assert.Equal(t, "json_processor", schema.Name)
 		assert.Contains(t, schema.Description, "JSON")
-		assert.Len(t, schema.Parameters, 3)
+		assert.Len(t, schema.Parameters, 6)
 	})
 
 	t.Run("Validate JSON", func(t *testing.T) {
@@ -411,6 +618,191 @@ func TestJSONProcessorTool(t *testing.T) {
 		assert.Contains(t, result.Error, "path is required")
 	})
 
+	t.Run("Get Value with Array Index", func(t *testing.T) {
+		ctx := tools.ExecutionContext{
+			Context:   context.Background(),
+			SessionID: "test-session",
+		}
+
+		jsonData := `{"items": [{"name": "first"}, {"name": "second"}]}`
+		for path, expected := range map[string]interface{}{
+			"items[0].name":    "first",
+			"$.items[-1].name": "second",
+		} {
+			result := processor.Execute(ctx, map[string]interface{}{
+				"json_data": jsonData,
+				"operation": "get_value",
+				"path":      path,
+			})
+			assert.True(t, result.Success, path)
+			data := result.Data.(map[string]interface{})
+			assert.Equal(t, expected, data["result"], path)
+		}
+
+		result := processor.Execute(ctx, map[string]interface{}{
+			"json_data": jsonData,
+			"operation": "get_value",
+			"path":      "items[5].name",
+		})
+		assert.False(t, result.Success)
+		assert.Contains(t, result.Error, "out of range")
+	})
+
+	t.Run("Get Value with Wildcards", func(t *testing.T) {
+		ctx := tools.ExecutionContext{
+			Context:   context.Background(),
+			SessionID: "test-session",
+		}
+
+		jsonData := `{"items": [{"id": 1, "tags": [{"id": 3}]}, {"id": 2}]}`
+
+		result := processor.Execute(ctx, map[string]interface{}{
+			"json_data": jsonData,
+			"operation": "get_value",
+			"path":      "items[*].id",
+		})
+		assert.True(t, result.Success)
+		data := result.Data.(map[string]interface{})
+		assert.Equal(t, []interface{}{float64(1), float64(2)}, data["result"])
+
+		result = processor.Execute(ctx, map[string]interface{}{
+			"json_data": jsonData,
+			"operation": "get_value",
+			"path":      "$..id",
+		})
+		assert.True(t, result.Success)
+		data = result.Data.(map[string]interface{})
+		assert.ElementsMatch(t, []interface{}{float64(1), float64(2), float64(3)}, data["result"])
+	})
+
+	t.Run("Set Value", func(t *testing.T) {
+		ctx := tools.ExecutionContext{
+			Context:   context.Background(),
+			SessionID: "test-session",
+		}
+
+		result := processor.Execute(ctx, map[string]interface{}{
+			"json_data": `{"items": [{"name": "first"}]}`,
+			"operation": "set_value",
+			"path":      "items[0].tags",
+			"value":     `["a", "b"]`,
+		})
+		assert.True(t, result.Success)
+		data := result.Data.(map[string]interface{})
+		assert.Equal(t, `{"items":[{"name":"first","tags":["a","b"]}]}`, data["result"])
+
+		result = processor.Execute(ctx, map[string]interface{}{
+			"json_data": `{}`,
+			"operation": "set_value",
+			"path":      "user.name",
+			"value":     "John",
+		})
+		assert.True(t, result.Success)
+		data = result.Data.(map[string]interface{})
+		assert.Equal(t, `{"user":{"name":"John"}}`, data["result"])
+	})
+
+	t.Run("Delete Key", func(t *testing.T) {
+		ctx := tools.ExecutionContext{
+			Context:   context.Background(),
+			SessionID: "test-session",
+		}
+
+		result := processor.Execute(ctx, map[string]interface{}{
+			"json_data": `{"user": {"name": "John", "age": 30}, "items": [1, 2, 3]}`,
+			"operation": "delete_key",
+			"path":      "items[1]",
+		})
+		assert.True(t, result.Success)
+		data := result.Data.(map[string]interface{})
+		assert.Equal(t, `{"items":[1,3],"user":{"age":30,"name":"John"}}`, data["result"])
+
+		result = processor.Execute(ctx, map[string]interface{}{
+			"json_data": `{"user": {"name": "John"}}`,
+			"operation": "delete_key",
+			"path":      "user.email",
+		})
+		assert.False(t, result.Success)
+		assert.Contains(t, result.Error, "key 'email' not found")
+	})
+
+	t.Run("Diff JSON", func(t *testing.T) {
+		ctx := tools.ExecutionContext{
+			Context:   context.Background(),
+			SessionID: "test-session",
+		}
+
+		result := processor.Execute(ctx, map[string]interface{}{
+			"json_data":  `{"name": "John", "age": 30, "tags": ["a"]}`,
+			"operation":  "diff",
+			"other_json": `{"name": "Jane", "tags": ["a", "b"], "city": "Berlin"}`,
+		})
+		assert.True(t, result.Success)
+
+		encoded, err := json.Marshal(result.Data.(map[string]interface{})["result"])
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"equal": false,
+			"changes": [
+				{"path": "$.age", "type": "removed", "old": 30},
+				{"path": "$.city", "type": "added", "new": "Berlin"},
+				{"path": "$.name", "type": "changed", "old": "John", "new": "Jane"},
+				{"path": "$.tags[1]", "type": "added", "new": "b"}
+			]
+		}`, string(encoded))
+	})
+
+	t.Run("Validate Against Schema", func(t *testing.T) {
+		ctx := tools.ExecutionContext{
+			Context:   context.Background(),
+			SessionID: "test-session",
+		}
+
+		schema := `{
+			"type": "object",
+			"required": ["name", "age"],
+			"properties": {
+				"name": {"type": "string", "minLength": 1},
+				"age": {"type": "integer", "minimum": 0},
+				"tags": {"type": "array", "items": {"type": "string"}}
+			},
+			"additionalProperties": false
+		}`
+
+		result := processor.Execute(ctx, map[string]interface{}{
+			"json_data": `{"name": "John", "age": 30, "tags": ["a"]}`,
+			"operation": "validate",
+			"schema":    schema,
+		})
+		assert.True(t, result.Success)
+		resultMap := result.Data.(map[string]interface{})["result"].(map[string]interface{})
+		assert.Equal(t, true, resultMap["valid"])
+
+		result = processor.Execute(ctx, map[string]interface{}{
+			"json_data": `{"name": "", "age": 1.5, "tags": [1], "extra": true}`,
+			"operation": "validate",
+			"schema":    schema,
+		})
+		assert.True(t, result.Success)
+
+		encoded, err := json.Marshal(result.Data.(map[string]interface{})["result"])
+		require.NoError(t, err)
+		var report struct {
+			Valid  bool `json:"valid"`
+			Errors []struct {
+				Path string `json:"path"`
+			} `json:"errors"`
+		}
+		require.NoError(t, json.Unmarshal(encoded, &report))
+		assert.False(t, report.Valid)
+
+		var paths []string
+		for _, e := range report.Errors {
+			paths = append(paths, e.Path)
+		}
+		assert.ElementsMatch(t, []string{"$.age", "$.extra", "$.name", "$.tags[0]"}, paths)
+	})
+
 	t.Run("Invalid JSON", func(t *testing.T) {
 		ctx := tools.ExecutionContext{
 			Context:   context.Background(),
@@ -439,8 +831,8 @@ func TestRegisterBuiltinTools(t *testing.T) {
 		err = builtin.RegisterBuiltinTools(registry, repo.Memory())
 		require.NoError(t, err)
 		
-		// Should have 9 built-in tools (including memory)
-		assert.Equal(t, 9, registry.Count())
+		// Should have 11 built-in tools (including memory)
+		assert.Equal(t, 11, registry.Count())
 		
 		// Check that all expected tools are registered
 		expectedTools := []string{
@@ -450,9 +842,11 @@ func TestRegisterBuiltinTools(t *testing.T) {
 			"calculator",
 			"text_processor",
 			"json_processor",
+			"encoder",
 			"mcp_proxy",
 			"openmcp_proxy",
 			"memory",
+			"issue_tracker",
 		}
 		
 		registeredTools := registry.List()
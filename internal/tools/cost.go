@@ -0,0 +1,33 @@
+package tools
+
+// CostMetadataKey is the Result metadata key under which paid tools report
+// what a call cost, e.g. the per-query price of a search API
+const CostMetadataKey = "cost"
+
+// WithCost records the cost of the call in the result metadata and returns
+// the result
+func (r *Result) WithCost(cost float64) *Result {
+	if r.Metadata == nil {
+		r.Metadata = make(map[string]interface{})
+	}
+	r.Metadata[CostMetadataKey] = cost
+	return r
+}
+
+// Cost returns the cost the tool reported and whether it reported one
+func (r *Result) Cost() (float64, bool) {
+	if r == nil {
+		return 0, false
+	}
+	switch cost := r.Metadata[CostMetadataKey].(type) {
+	case float64:
+		return cost, true
+	case float32:
+		return float64(cost), true
+	case int:
+		return float64(cost), true
+	case int64:
+		return float64(cost), true
+	}
+	return 0, false
+}
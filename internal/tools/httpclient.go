@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultMaxRedirects is how many redirects HTTP tools follow unless
+// configured otherwise
+const DefaultMaxRedirects = 10
+
+// RedirectPolicy controls how the HTTP client of tools follows redirects
+type RedirectPolicy struct {
+	// MaxRedirects caps the redirects of a request. With 0 none are
+	// followed and the redirect response is returned.
+	MaxRedirects int
+
+	// CrossOriginCredentials forwards a request's headers and body on
+	// redirects to another origin (scheme, host and port). Without it they
+	// reach only the origin of the first request, so credentials that tool
+	// presets supply for one domain never leave it.
+	CrossOriginCredentials bool
+}
+
+// DefaultRedirectPolicy follows up to DefaultMaxRedirects redirects and
+// keeps credentials within the origin of the first request
+var DefaultRedirectPolicy = RedirectPolicy{MaxRedirects: DefaultMaxRedirects}
+
+// RedirectPolicySetter is implemented by tools that make HTTP requests, so
+// their redirect policy can be set after creation
+type RedirectPolicySetter interface {
+	SetRedirectPolicy(policy RedirectPolicy)
+}
+
+// crossOriginHeaders are the request headers kept on redirects to another
+// origin; they carry no credentials
+var crossOriginHeaders = map[string]bool{
+	"Accept":          true,
+	"Accept-Encoding": true,
+	"Accept-Language": true,
+	"User-Agent":      true,
+}
+
+// NewHTTPClient returns the HTTP client of tools: requests pass the audit
+// transport and redirects follow DefaultRedirectPolicy
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     NewAuditTransport(nil),
+		CheckRedirect: DefaultRedirectPolicy.CheckRedirect,
+	}
+}
+
+// CheckRedirect implements http.Client.CheckRedirect. On a redirect to
+// another origin every header but crossOriginHeaders is dropped, the
+// Referer included; a redirect that would resend a body there is not
+// followed, and its response is returned instead.
+func (p RedirectPolicy) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if p.MaxRedirects == 0 {
+		return http.ErrUseLastResponse
+	}
+	if len(via) > p.MaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", p.MaxRedirects)
+	}
+	if p.CrossOriginCredentials || sameOrigin(req.URL, via[0].URL) {
+		return nil
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		return http.ErrUseLastResponse
+	}
+	for key := range req.Header {
+		if !crossOriginHeaders[key] {
+			req.Header.Del(key)
+		}
+	}
+	return nil
+}
+
+// sameOrigin reports whether two URLs share scheme, host and port
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) &&
+		strings.EqualFold(a.Hostname(), b.Hostname()) &&
+		port(a) == port(b)
+}
+
+// port returns the port of a URL, defaulting by scheme
+func port(u *url.URL) string {
+	if p := u.Port(); p != "" {
+		return p
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
@@ -3,6 +3,11 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -25,6 +30,10 @@ type Schema struct {
 	Description string      `json:"description"`
 	Parameters  []Parameter `json:"parameters"`
 	Examples    []Example   `json:"examples,omitempty"`
+
+	// Output is the JSON Schema of the data of successful results; the
+	// executor checks results against it
+	Output map[string]interface{} `json:"output,omitempty"`
 }
 
 // Example represents a tool usage example
@@ -43,6 +52,10 @@ type ExecutionContext struct {
 	RequestID   string
 	Timeout     time.Duration
 	Metadata    map[string]interface{}
+
+	// Artifacts opens the artifacts earlier calls of the session returned;
+	// nil when no media storage keeps them
+	Artifacts ArtifactStore
 }
 
 // Result represents the result of tool execution
@@ -53,6 +66,13 @@ type Result struct {
 	ErrorCode string                 `json:"error_code,omitempty"`
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 	Duration  time.Duration          `json:"duration"`
+
+	// Artifacts are binary files the tool returns; the tool service stores
+	// them and sets their IDs and URLs
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+
+	// stack holds the stack trace of a panicked tool until it is logged
+	stack []byte
 }
 
 // CallInfo represents information about a tool call
@@ -62,6 +82,19 @@ type CallInfo struct {
 	CallID    string                 `json:"call_id,omitempty"`
 }
 
+// TransportSetter is implemented by tools that make HTTP requests, so their
+// transport (e.g. routing through an egress proxy) can be set after creation
+type TransportSetter interface {
+	SetTransport(base http.RoundTripper)
+}
+
+// Mutator is implemented by tools that change external state only for some
+// inputs, such as the write actions of issue_tracker, so that approval mode
+// holds just those calls
+type Mutator interface {
+	Mutates(input map[string]interface{}) bool
+}
+
 // Tool defines the interface for all tools
 type Tool interface {
 	// Name returns the tool's unique name
@@ -80,9 +113,14 @@ type Tool interface {
 	IsAvailable(ctx context.Context) bool
 }
 
-// Registry manages tool registration and discovery
+// Registry manages tool registration and discovery. Tools disabled by
+// policy stay registered but are hidden from lookups and listings, and the
+// executor rejects their calls.
 type Registry struct {
 	tools map[string]Tool
+
+	mu       sync.RWMutex
+	disabled map[string]bool
 }
 
 // NewRegistry creates a new tool registry
@@ -111,34 +149,71 @@ func (r *Registry) Register(tool Tool) error {
 	return nil
 }
 
-// Get retrieves a tool by name
+// Get retrieves a tool by name; disabled tools are not found
 func (r *Registry) Get(name string) (Tool, bool) {
+	if r.IsDisabled(name) {
+		return nil, false
+	}
 	tool, exists := r.tools[name]
 	return tool, exists
 }
 
-// List returns all registered tool names
+// List returns the names of the registered tools that are not disabled
 func (r *Registry) List() []string {
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		if !r.IsDisabled(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ListAll returns the names of all registered tools, disabled or not, in
+// order
+func (r *Registry) ListAll() []string {
 	names := make([]string, 0, len(r.tools))
 	for name := range r.tools {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
 
+// SetDisabled replaces the tools disabled by policy. Unlike registration it
+// is safe while the registry is in use.
+func (r *Registry) SetDisabled(names []string) {
+	disabled := make(map[string]bool, len(names))
+	for _, name := range names {
+		disabled[name] = true
+	}
+	r.mu.Lock()
+	r.disabled = disabled
+	r.mu.Unlock()
+}
+
+// IsDisabled reports whether a tool is disabled by policy
+func (r *Registry) IsDisabled(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.disabled[name]
+}
+
 // GetSchemas returns schemas for all tools or specific tools
 func (r *Registry) GetSchemas(toolNames ...string) []Schema {
 	var schemas []Schema
 	
 	if len(toolNames) == 0 {
 		// Return all schemas
-		for _, tool := range r.tools {
-			schemas = append(schemas, tool.Schema())
+		for name, tool := range r.tools {
+			if !r.IsDisabled(name) {
+				schemas = append(schemas, tool.Schema())
+			}
 		}
 	} else {
 		// Return specific schemas
 		for _, name := range toolNames {
-			if tool, exists := r.tools[name]; exists {
+			if tool, exists := r.Get(name); exists {
 				schemas = append(schemas, tool.Schema())
 			}
 		}
@@ -168,20 +243,103 @@ func (r *Registry) Count() int {
 
 // Executor provides high-level tool execution capabilities
 type Executor struct {
-	registry *Registry
-	timeout  time.Duration
+	registry     *Registry
+	timeout      time.Duration
+	maxTimeout   time.Duration
+	toolTimeouts map[string]time.Duration
+	retries      map[string]RetryPolicy
+	strictOutput bool
+	logger       *slog.Logger
+}
+
+// ExecuteOptions tune a single tool execution
+type ExecuteOptions struct {
+	AgentID string
+
+	// Timeout overrides the tool's default timeout, bounded by the
+	// executor's maximum; zero keeps the default
+	Timeout time.Duration
+
+	// Artifacts opens the session's artifacts for the tool
+	Artifacts ArtifactStore
 }
 
 // NewExecutor creates a new tool executor
 func NewExecutor(registry *Registry, timeout time.Duration) *Executor {
 	return &Executor{
-		registry: registry,
-		timeout:  timeout,
+		registry:     registry,
+		timeout:      timeout,
+		toolTimeouts: make(map[string]time.Duration),
+		retries:      make(map[string]RetryPolicy),
+		logger:       slog.Default(),
+	}
+}
+
+// SetLogger sets the logger that records panicking tools
+func (e *Executor) SetLogger(logger *slog.Logger) {
+	e.logger = logger
+}
+
+// SetDefaultTimeout sets the timeout of tools without their own
+func (e *Executor) SetDefaultTimeout(timeout time.Duration) {
+	e.timeout = timeout
+}
+
+// SetStrictOutput fails results whose data does not match the tool's output
+// schema with INVALID_OUTPUT. Otherwise they are only flagged in their
+// metadata.
+func (e *Executor) SetStrictOutput(strict bool) {
+	e.strictOutput = strict
+}
+
+// SetRetryPolicy retries failed executions of one tool according to policy.
+// Configure the executor before it is used concurrently.
+func (e *Executor) SetRetryPolicy(toolName string, policy RetryPolicy) {
+	e.retries[toolName] = policy
+}
+
+// SetToolTimeout sets the default timeout of one tool. Configure the
+// executor before it is used concurrently.
+func (e *Executor) SetToolTimeout(toolName string, timeout time.Duration) {
+	e.toolTimeouts[toolName] = timeout
+}
+
+// SetMaxTimeout bounds every timeout, including per-call overrides; zero
+// leaves timeouts unbounded
+func (e *Executor) SetMaxTimeout(max time.Duration) {
+	e.maxTimeout = max
+}
+
+// TimeoutFor resolves the timeout of a call: the override if set, else the
+// tool's default, else the executor's, capped at the maximum
+func (e *Executor) TimeoutFor(toolName string, override time.Duration) time.Duration {
+	timeout := e.timeout
+	if toolTimeout, ok := e.toolTimeouts[toolName]; ok && toolTimeout > 0 {
+		timeout = toolTimeout
+	}
+	if override > 0 {
+		timeout = override
 	}
+	if e.maxTimeout > 0 && timeout > e.maxTimeout {
+		timeout = e.maxTimeout
+	}
+	return timeout
 }
 
 // Execute executes a tool with the given parameters
 func (e *Executor) Execute(ctx context.Context, toolName string, sessionID string, input map[string]interface{}) *Result {
+	return e.ExecuteWithOptions(ctx, toolName, sessionID, input, ExecuteOptions{})
+}
+
+// ExecuteWithOptions executes a tool with per-call options. A panicking tool
+// yields a TOOL_PANIC result instead of crashing the caller.
+func (e *Executor) ExecuteWithOptions(ctx context.Context, toolName string, sessionID string, input map[string]interface{}, opts ExecuteOptions) (result *Result) {
+	defer e.recoverPanic(toolName, &result)
+	
+	if e.registry.IsDisabled(toolName) {
+		return DisabledResult(toolName)
+	}
+	
 	// Get the tool
 	tool, exists := e.registry.Get(toolName)
 	if !exists {
@@ -203,37 +361,111 @@ func (e *Executor) Execute(ctx context.Context, toolName string, sessionID strin
 		}
 	}
 	
-	// Validate input
-	if err := tool.Validate(input); err != nil {
-		return &Result{
-			Success:   false,
-			Error:     err.Error(),
-			ErrorCode: "VALIDATION_ERROR",
-			Duration:  0,
+	// Apply defaults and coerce LLM-produced values before validating, so
+	// tools receive the types their schema declares
+	sanitized, err := SanitizeInput(tool.Schema(), input)
+	if err != nil {
+		return ValidationErrorResult(err)
+	}
+	// Unknown parameters are kept for validation to report
+	for key, value := range input {
+		if _, known := sanitized[key]; !known {
+			sanitized[key] = value
 		}
 	}
+	input = sanitized
 	
-	// Create execution context with timeout
-	execCtx, cancel := context.WithTimeout(ctx, e.timeout)
-	defer cancel()
+	// Validate input
+	if err := tool.Validate(input); err != nil {
+		return ValidationErrorResult(err)
+	}
 	
-	executionContext := ExecutionContext{
-		Context:   execCtx,
-		SessionID: sessionID,
-		AgentID:   "default-agent", // TODO: Get from session lookup
-		RequestID: generateRequestID(),
-		Timeout:   e.timeout,
-		Metadata:  make(map[string]interface{}),
+	timeout := e.TimeoutFor(toolName, opts.Timeout)
+	agentID := opts.AgentID
+	if agentID == "" {
+		agentID = "default-agent"
 	}
 	
-	// Execute the tool
+	policy, hasPolicy := e.retries[toolName]
 	start := time.Now()
-	result := tool.Execute(executionContext, input)
+	var retriedErrors []string
+	
+	for attempt := 1; ; attempt++ {
+		// Each attempt gets the full timeout
+		result = e.executeAttempt(ctx, tool, ExecutionContext{
+			SessionID: sessionID,
+			AgentID:   agentID,
+			RequestID: generateRequestID(),
+			Timeout:   timeout,
+			Metadata:  make(map[string]interface{}),
+			Artifacts: opts.Artifacts,
+		}, input)
+		e.logPanic(toolName, result)
+	
+		if !hasPolicy || attempt >= policy.MaxAttempts || !policy.retries(result) || ctx.Err() != nil {
+			break
+		}
+		retriedErrors = append(retriedErrors, result.ErrorCode)
+	
+		select {
+		case <-time.After(policy.delay(attempt)):
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
 	result.Duration = time.Since(start)
+	e.checkOutput(tool.Schema(), result)
+	
+	if hasPolicy {
+		if result.Metadata == nil {
+			result.Metadata = make(map[string]interface{})
+		}
+		result.Metadata["attempts"] = len(retriedErrors) + 1
+		if len(retriedErrors) > 0 {
+			result.Metadata["retried_errors"] = retriedErrors
+		}
+	}
 	
 	return result
 }
 
+// checkOutput flags, or in strict mode fails, a successful result whose data
+// does not match the tool's output schema
+func (e *Executor) checkOutput(schema Schema, result *Result) {
+	if !result.Success {
+		return
+	}
+	errs := ValidateOutput(schema, result.Data)
+	if len(errs) == 0 {
+		return
+	}
+	e.logger.Warn("Tool output does not match its schema",
+		"tool", schema.Name,
+		"errors", len(errs),
+		"first_error", errs[0].Path+": "+errs[0].Message)
+
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata["output_schema_errors"] = errs
+	if e.strictOutput {
+		result.Success = false
+		result.ErrorCode = "INVALID_OUTPUT"
+		result.Error = fmt.Sprintf("tool output does not match its schema: %s: %s", errs[0].Path, errs[0].Message)
+	}
+}
+
+// executeAttempt runs the tool once under the execution context's timeout
+func (e *Executor) executeAttempt(ctx context.Context, tool Tool, executionContext ExecutionContext, input map[string]interface{}) *Result {
+	execCtx, cancel := context.WithTimeout(ctx, executionContext.Timeout)
+	defer cancel()
+	
+	executionContext.Context = execCtx
+	return tool.Execute(executionContext, input)
+}
+
 // ExecuteMultiple executes multiple tools concurrently
 func (e *Executor) ExecuteMultiple(ctx context.Context, sessionID string, calls []CallInfo) map[string]*Result {
 	results := make(map[string]*Result)
@@ -245,11 +477,16 @@ func (e *Executor) ExecuteMultiple(ctx context.Context, sessionID string, calls
 	// Execute tools concurrently
 	for _, call := range calls {
 		go func(call CallInfo) {
-			result := e.Execute(ctx, call.ToolName, sessionID, call.Arguments)
-			resultChan <- struct {
-				callID string
-				result *Result
-			}{call.CallID, result}
+			var result *Result
+			// Always report a result, or collection below would block
+			defer func() {
+				resultChan <- struct {
+					callID string
+					result *Result
+				}{call.CallID, result}
+			}()
+			defer e.recoverPanic(call.ToolName, &result)
+			result = e.Execute(ctx, call.ToolName, sessionID, call.Arguments)
 		}(call)
 	}
 	
@@ -286,6 +286,45 @@ func TestExecutor(t *testing.T) {
 		assert.Equal(t, "VALIDATION_ERROR", result.ErrorCode)
 	})
 
+	t.Run("Execute Tool with Sanitized Input", func(t *testing.T) {
+		registry := tools.NewRegistry()
+		executor := tools.NewExecutor(registry, 5*time.Second)
+
+		var received map[string]interface{}
+		registry.Register(&mockTool{
+			name: "sanitized_tool",
+			schema: tools.Schema{
+				Name: "sanitized_tool",
+				Parameters: []tools.Parameter{
+					{Name: "url", Type: "string", Required: true},
+					{Name: "timeout", Type: "number", Default: 30},
+					{Name: "verbose", Type: "boolean"},
+				},
+			},
+			available: true,
+			executeFunc: func(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
+				received = input
+				return tools.SuccessResult("ok")
+			},
+		})
+
+		ctx := context.Background()
+		result := executor.Execute(ctx, "sanitized_tool", "test-session", map[string]interface{}{
+			"url":     "https://example.com",
+			"verbose": "true",
+		})
+
+		require.True(t, result.Success)
+		assert.Equal(t, map[string]interface{}{"url": "https://example.com", "timeout": 30.0, "verbose": true}, received)
+
+		result = executor.Execute(ctx, "sanitized_tool", "test-session", map[string]interface{}{
+			"url":   "https://example.com",
+			"extra": 1,
+		})
+		assert.Equal(t, "VALIDATION_ERROR", result.ErrorCode)
+		assert.Contains(t, result.Error, "unknown parameter")
+	})
+
 	t.Run("Execute Tool with Timeout", func(t *testing.T) {
 		registry := tools.NewRegistry()
 		executor := tools.NewExecutor(registry, 100*time.Millisecond) // Short timeout
@@ -311,6 +350,132 @@ func TestExecutor(t *testing.T) {
 		assert.NotNil(t, result)
 	})
 
+	t.Run("Resolve Timeouts", func(t *testing.T) {
+		executor := tools.NewExecutor(tools.NewRegistry(), 60*time.Second)
+		executor.SetToolTimeout("web_scraper", 120*time.Second)
+		executor.SetMaxTimeout(300 * time.Second)
+
+		assert.Equal(t, 60*time.Second, executor.TimeoutFor("calculator", 0))
+		assert.Equal(t, 120*time.Second, executor.TimeoutFor("web_scraper", 0))
+		assert.Equal(t, 5*time.Second, executor.TimeoutFor("web_scraper", 5*time.Second))
+		assert.Equal(t, 300*time.Second, executor.TimeoutFor("calculator", time.Hour))
+	})
+
+	t.Run("Execute With Timeout Override", func(t *testing.T) {
+		registry := tools.NewRegistry()
+		executor := tools.NewExecutor(registry, 5*time.Second)
+
+		var deadline time.Duration
+		var agentID string
+		registry.Register(&mockTool{
+			name:      "deadline_tool",
+			schema:    tools.Schema{Name: "deadline_tool"},
+			available: true,
+			executeFunc: func(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
+				due, _ := ctx.Context.Deadline()
+				deadline = time.Until(due)
+				agentID = ctx.AgentID
+				return tools.SuccessResult("ok")
+			},
+		})
+
+		result := executor.ExecuteWithOptions(context.Background(), "deadline_tool", "test-session", map[string]interface{}{},
+			tools.ExecuteOptions{AgentID: "agent-1", Timeout: 50 * time.Millisecond})
+
+		assert.True(t, result.Success)
+		assert.LessOrEqual(t, deadline, 50*time.Millisecond)
+		assert.Equal(t, "agent-1", agentID)
+	})
+
+	t.Run("Retry Transient Failures", func(t *testing.T) {
+		registry := tools.NewRegistry()
+		executor := tools.NewExecutor(registry, 5*time.Second)
+		executor.SetRetryPolicy("flaky_tool", tools.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond})
+
+		calls := 0
+		registry.Register(&mockTool{
+			name:      "flaky_tool",
+			schema:    tools.Schema{Name: "flaky_tool"},
+			available: true,
+			executeFunc: func(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
+				calls++
+				if calls == 1 {
+					return tools.ErrorResult("REQUEST_FAILED", "connection reset")
+				}
+				return tools.SuccessResult("ok")
+			},
+		})
+
+		result := executor.Execute(context.Background(), "flaky_tool", "test-session", map[string]interface{}{})
+
+		assert.True(t, result.Success)
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, 2, result.Metadata["attempts"])
+		assert.Equal(t, []string{"REQUEST_FAILED"}, result.Metadata["retried_errors"])
+	})
+
+	t.Run("Do Not Retry Permanent Failures", func(t *testing.T) {
+		registry := tools.NewRegistry()
+		executor := tools.NewExecutor(registry, 5*time.Second)
+		executor.SetRetryPolicy("failing_tool", tools.RetryPolicy{MaxAttempts: 3, RetryOn: []string{"TIMEOUT"}})
+
+		calls := 0
+		registry.Register(&mockTool{
+			name:      "failing_tool",
+			schema:    tools.Schema{Name: "failing_tool"},
+			available: true,
+			executeFunc: func(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
+				calls++
+				return tools.ErrorResult("REQUEST_FAILED", "connection reset")
+			},
+		})
+
+		result := executor.Execute(context.Background(), "failing_tool", "test-session", map[string]interface{}{})
+
+		assert.False(t, result.Success)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, 1, result.Metadata["attempts"])
+		assert.NotContains(t, result.Metadata, "retried_errors")
+	})
+
+	t.Run("Recover From Panicking Tools", func(t *testing.T) {
+		registry := tools.NewRegistry()
+		executor := tools.NewExecutor(registry, 5*time.Second)
+
+		registry.Register(&mockTool{
+			name:      "panicking_tool",
+			schema:    tools.Schema{Name: "panicking_tool"},
+			available: true,
+			executeFunc: func(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
+				var missing map[string]interface{}
+				_ = missing["url"].(string)
+				return nil
+			},
+		})
+		registry.Register(tools.NewBaseTool("panicking_base_tool", tools.Schema{Name: "panicking_base_tool"},
+			func(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
+				panic("boom")
+			}))
+
+		ctx := context.Background()
+		result := executor.Execute(ctx, "panicking_tool", "test-session", map[string]interface{}{})
+		assert.False(t, result.Success)
+		assert.Equal(t, "TOOL_PANIC", result.ErrorCode)
+		assert.Contains(t, result.Error, "interface conversion")
+
+		result = executor.Execute(ctx, "panicking_base_tool", "test-session", map[string]interface{}{})
+		assert.Equal(t, "TOOL_PANIC", result.ErrorCode)
+		assert.Equal(t, "tool panicked: boom", result.Error)
+
+		results := executor.ExecuteMultiple(ctx, "test-session", []tools.CallInfo{
+			{ToolName: "panicking_tool", Arguments: map[string]interface{}{}, CallID: "call_1"},
+			{ToolName: "panicking_base_tool", Arguments: map[string]interface{}{}, CallID: "call_2"},
+		})
+		require.Len(t, results, 2)
+		assert.Equal(t, "TOOL_PANIC", results["call_1"].ErrorCode)
+		assert.Equal(t, "TOOL_PANIC", results["call_2"].ErrorCode)
+	})
+
 	t.Run("Execute Multiple Tools Concurrently", func(t *testing.T) {
 		registry := tools.NewRegistry()
 		executor := tools.NewExecutor(registry, 5*time.Second)
@@ -398,4 +563,88 @@ func (m *mockTool) Execute(ctx tools.ExecutionContext, input map[string]interfac
 
 func (m *mockTool) IsAvailable(ctx context.Context) bool {
 	return m.available
-}
\ No newline at end of file
+}
+func TestExecutor_OutputSchema(t *testing.T) {
+	registry := tools.NewRegistry()
+	executor := tools.NewExecutor(registry, 5*time.Second)
+
+	data := map[string]interface{}{"temperature": "warm"}
+	registry.Register(&mockTool{
+		name: "weather",
+		schema: tools.Schema{
+			Name: "weather",
+			Output: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"temperature": map[string]interface{}{"type": "number"}},
+				"required":   []string{"temperature", "unit"},
+			},
+		},
+		executeFunc: func(ctx tools.ExecutionContext, input map[string]interface{}) *tools.Result {
+			return tools.SuccessResult(data)
+		},
+		available: true,
+	})
+
+	// Malformed output is flagged
+	result := executor.Execute(context.Background(), "weather", "test-session", map[string]interface{}{})
+	assert.True(t, result.Success)
+	errs, ok := result.Metadata["output_schema_errors"].([]tools.JSONSchemaError)
+	require.True(t, ok)
+	assert.Equal(t, []tools.JSONSchemaError{
+		{Path: "$.unit", Message: "required property missing"},
+		{Path: "$.temperature", Message: "expected number, got string"},
+	}, errs)
+
+	// and fails the call in strict mode
+	executor.SetStrictOutput(true)
+	result = executor.Execute(context.Background(), "weather", "test-session", map[string]interface{}{})
+	assert.False(t, result.Success)
+	assert.Equal(t, "INVALID_OUTPUT", result.ErrorCode)
+	assert.Contains(t, result.Error, "$.unit: required property missing")
+
+	// Output that matches passes untouched
+	data = map[string]interface{}{"temperature": 21, "unit": "C"}
+	result = executor.Execute(context.Background(), "weather", "test-session", map[string]interface{}{})
+	assert.True(t, result.Success)
+	assert.NotContains(t, result.Metadata, "output_schema_errors")
+}
+
+func TestExecutor_DisabledTools(t *testing.T) {
+	registry := tools.NewRegistry()
+	executor := tools.NewExecutor(registry, 5*time.Second)
+	require.NoError(t, registry.Register(&mockTool{name: "http_post", schema: tools.Schema{Name: "http_post"}, available: true}))
+	require.NoError(t, registry.Register(&mockTool{name: "calculator", schema: tools.Schema{Name: "calculator"}, available: true}))
+
+	registry.SetDisabled([]string{"http_post"})
+
+	_, exists := registry.Get("http_post")
+	assert.False(t, exists)
+	assert.Equal(t, []string{"calculator"}, registry.List())
+	assert.Equal(t, []string{"calculator", "http_post"}, registry.ListAll())
+	assert.Len(t, registry.GetSchemas(), 1)
+
+	result := executor.Execute(context.Background(), "http_post", "s1", map[string]interface{}{})
+	assert.False(t, result.Success)
+	assert.Equal(t, tools.ToolDisabledErrorCode, result.ErrorCode)
+
+	registry.SetDisabled(nil)
+	result = executor.Execute(context.Background(), "http_post", "s1", map[string]interface{}{})
+	assert.True(t, result.Success, result.Error)
+}
+
+func TestIsBinaryContent(t *testing.T) {
+	for _, contentType := range []string{"text/csv", "application/json; charset=utf-8", "application/ld+json", "application/xml"} {
+		assert.False(t, tools.IsBinaryContent(contentType, nil), contentType)
+	}
+	for _, contentType := range []string{"image/png", "application/pdf", "application/octet-stream"} {
+		assert.True(t, tools.IsBinaryContent(contentType, nil), contentType)
+	}
+
+	// Without a content type the data is sniffed
+	assert.False(t, tools.IsBinaryContent("", []byte("plain words")))
+	assert.True(t, tools.IsBinaryContent("", []byte("\x89PNG\r\n\x1a\n")))
+
+	artifact := tools.NewArtifact("sales.csv", "", []byte("a,b\n"))
+	assert.Equal(t, "text/csv; charset=utf-8", artifact.ContentType)
+	assert.EqualValues(t, 4, artifact.Size)
+}
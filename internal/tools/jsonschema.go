@@ -0,0 +1,302 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSONSchemaError reports where a document violates a schema
+type JSONSchemaError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidateJSONSchema checks data against a JSON Schema. It supports the
+// commonly used keywords: type, enum, const, properties, required,
+// additionalProperties, items, min/maxItems, uniqueItems, min/maxLength,
+// pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf,
+// allOf, anyOf, oneOf and not. Both data and schema are expected as decoded
+// by encoding/json.
+func ValidateJSONSchema(data interface{}, schema map[string]interface{}) []JSONSchemaError {
+	errs := []JSONSchemaError{}
+	checkJSONSchema("$", data, schema, &errs)
+	return errs
+}
+
+// ValidateOutput checks the data of a successful result against the tool's
+// output schema. Data and schema may hold any Go values that encode to
+// JSON; tools without an output schema always pass.
+func ValidateOutput(schema Schema, data interface{}) []JSONSchemaError {
+	if len(schema.Output) == 0 {
+		return nil
+	}
+	var decodedSchema map[string]interface{}
+	if err := roundTripJSON(schema.Output, &decodedSchema); err != nil {
+		return []JSONSchemaError{{Path: "$", Message: fmt.Sprintf("invalid output schema: %v", err)}}
+	}
+	var decoded interface{}
+	if err := roundTripJSON(data, &decoded); err != nil {
+		return []JSONSchemaError{{Path: "$", Message: fmt.Sprintf("output is not JSON: %v", err)}}
+	}
+	return ValidateJSONSchema(decoded, decodedSchema)
+}
+
+// roundTripJSON decodes the JSON encoding of value into target
+func roundTripJSON(value interface{}, target interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+func checkJSONSchema(path string, value interface{}, schema map[string]interface{}, errs *[]JSONSchemaError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, JSONSchemaError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !matchesAnyType(value, types) {
+		fail("expected %s, got %s", joinTypes(types), jsonTypeOf(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if reflect.DeepEqual(value, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value must be one of %s", compactJSON(enum))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !reflect.DeepEqual(value, constant) {
+		fail("value must be %s", compactJSON(constant))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		checkJSONObject(path, v, schema, errs)
+	case []interface{}:
+		checkJSONArray(path, v, schema, errs)
+	case string:
+		length := utf8.RuneCountInString(v)
+		if min, ok := schemaNumber(schema, "minLength"); ok && float64(length) < min {
+			fail("string must be at least %g characters", min)
+		}
+		if max, ok := schemaNumber(schema, "maxLength"); ok && float64(length) > max {
+			fail("string must be at most %g characters", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				fail("invalid pattern in schema: %v", err)
+			} else if !re.MatchString(v) {
+				fail("string does not match pattern %s", pattern)
+			}
+		}
+	case float64:
+		if min, ok := schemaNumber(schema, "minimum"); ok && v < min {
+			fail("value must be >= %g", min)
+		}
+		if max, ok := schemaNumber(schema, "maximum"); ok && v > max {
+			fail("value must be <= %g", max)
+		}
+		if min, ok := schemaNumber(schema, "exclusiveMinimum"); ok && v <= min {
+			fail("value must be > %g", min)
+		}
+		if max, ok := schemaNumber(schema, "exclusiveMaximum"); ok && v >= max {
+			fail("value must be < %g", max)
+		}
+		if step, ok := schemaNumber(schema, "multipleOf"); ok && step > 0 {
+			if q := v / step; math.Abs(q-math.Round(q)) > 1e-9 {
+				fail("value must be a multiple of %g", step)
+			}
+		}
+	}
+
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			if subSchema, ok := sub.(map[string]interface{}); ok {
+				checkJSONSchema(path, value, subSchema, errs)
+			}
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok && countMatchingSchemas(value, anyOf) == 0 {
+		fail("value does not match any schema in anyOf")
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		if n := countMatchingSchemas(value, oneOf); n != 1 {
+			fail("value must match exactly one schema in oneOf, matched %d", n)
+		}
+	}
+	if not, ok := schema["not"].(map[string]interface{}); ok && countMatchingSchemas(value, []interface{}{not}) == 1 {
+		fail("value must not match the schema in not")
+	}
+}
+
+func checkJSONObject(path string, obj map[string]interface{}, schema map[string]interface{}, errs *[]JSONSchemaError) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, exists := obj[key]; !exists {
+					*errs = append(*errs, JSONSchemaError{Path: schemaPath(path, key), Message: "required property missing"})
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	for _, key := range schemaKeys(obj) {
+		childPath := schemaPath(path, key)
+		if propSchema, ok := properties[key].(map[string]interface{}); ok {
+			checkJSONSchema(childPath, obj[key], propSchema, errs)
+			continue
+		}
+		if _, declared := properties[key]; declared {
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				*errs = append(*errs, JSONSchemaError{Path: childPath, Message: "additional property not allowed"})
+			}
+		case map[string]interface{}:
+			checkJSONSchema(childPath, obj[key], additional, errs)
+		}
+	}
+}
+
+func checkJSONArray(path string, arr []interface{}, schema map[string]interface{}, errs *[]JSONSchemaError) {
+	if min, ok := schemaNumber(schema, "minItems"); ok && float64(len(arr)) < min {
+		*errs = append(*errs, JSONSchemaError{Path: path, Message: fmt.Sprintf("array must have at least %g items", min)})
+	}
+	if max, ok := schemaNumber(schema, "maxItems"); ok && float64(len(arr)) > max {
+		*errs = append(*errs, JSONSchemaError{Path: path, Message: fmt.Sprintf("array must have at most %g items", max)})
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if reflect.DeepEqual(arr[i], arr[j]) {
+					*errs = append(*errs, JSONSchemaError{Path: path, Message: fmt.Sprintf("items %d and %d are equal", i, j)})
+				}
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range arr {
+			checkJSONSchema(fmt.Sprintf("%s[%d]", path, i), item, items, errs)
+		}
+	}
+}
+
+// countMatchingSchemas returns how many of the schemas value satisfies
+func countMatchingSchemas(value interface{}, schemas []interface{}) int {
+	matched := 0
+	for _, sub := range schemas {
+		subSchema, ok := sub.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var subErrs []JSONSchemaError
+		checkJSONSchema("$", value, subSchema, &subErrs)
+		if len(subErrs) == 0 {
+			matched++
+		}
+	}
+	return matched
+}
+
+func schemaTypes(t interface{}) []string {
+	switch v := t.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var types []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func matchesAnyType(value interface{}, types []string) bool {
+	actual := jsonTypeOf(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonTypeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func joinTypes(types []string) string {
+	sorted := append([]string{}, types...)
+	sort.Strings(sorted)
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	return fmt.Sprintf("one of %v", sorted)
+}
+
+func schemaNumber(schema map[string]interface{}, keyword string) (float64, bool) {
+	n, ok := schema[keyword].(float64)
+	return n, ok
+}
+
+func compactJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+// schemaPath appends an object key to a JSONPath
+func schemaPath(path, key string) string {
+	if key == "" || strings.ContainsAny(key, ".[]'\" ") {
+		return fmt.Sprintf("%s['%s']", path, key)
+	}
+	return path + "." + key
+}
+
+func schemaKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
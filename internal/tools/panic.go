@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicErrorCode marks results of tools that panicked
+const PanicErrorCode = "TOOL_PANIC"
+
+// PanicResult converts a recovered panic into a TOOL_PANIC result. The stack
+// trace is logged by the executor but never returned to callers.
+func PanicResult(value interface{}, stack []byte) *Result {
+	result := ErrorResult(PanicErrorCode, fmt.Sprintf("tool panicked: %v", value))
+	result.stack = stack
+	return result
+}
+
+// recoverPanic turns a panic in the calling function into a TOOL_PANIC result
+// stored in result. It must be deferred directly.
+func (e *Executor) recoverPanic(toolName string, result **Result) {
+	if r := recover(); r != nil {
+		*result = PanicResult(r, debug.Stack())
+		e.logPanic(toolName, *result)
+	}
+}
+
+// logPanic logs the stack trace of a panicked tool once
+func (e *Executor) logPanic(toolName string, result *Result) {
+	if result == nil || result.stack == nil {
+		return
+	}
+	e.logger.Error("Tool execution panicked",
+		"tool_name", toolName,
+		"error", result.Error,
+		"stack", string(result.stack))
+	result.stack = nil
+}
@@ -0,0 +1,103 @@
+package tools
+
+import "strings"
+
+// AllowedHostsKey names the preset entry listing the hosts the preset
+// credentials of a tool may be sent to. It is not passed to the tool.
+const AllowedHostsKey = "allowed_hosts"
+
+// MergePresets returns a copy of input with the preset values applied.
+// Presets take precedence so the LLM cannot override them; nested objects
+// such as headers are merged key by key. Keys are matched case-insensitively,
+// so an input "authorization" header does not survive next to a preset
+// "Authorization".
+func MergePresets(input, presets map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(input)+len(presets))
+	for key, value := range input {
+		merged[key] = value
+	}
+	for key, preset := range presets {
+		for inputKey := range input {
+			if inputKey != key && strings.EqualFold(inputKey, key) {
+				delete(merged, inputKey)
+			}
+		}
+		presetMap, presetIsMap := preset.(map[string]interface{})
+		inputMap, inputIsMap := merged[key].(map[string]interface{})
+		if presetIsMap && inputIsMap {
+			merged[key] = MergePresets(inputMap, presetMap)
+		} else {
+			merged[key] = preset
+		}
+	}
+	return merged
+}
+
+// OmitPresetParameters returns schema without the parameters whose values
+// are fixed by presets, so they are not offered to the LLM. Object presets
+// such as headers leave the parameter in place for additional keys.
+func OmitPresetParameters(schema Schema, presets map[string]interface{}) Schema {
+	if len(presets) == 0 {
+		return schema
+	}
+	parameters := make([]Parameter, 0, len(schema.Parameters))
+	for _, param := range schema.Parameters {
+		if preset, ok := presets[param.Name]; ok {
+			if _, isMap := preset.(map[string]interface{}); !isMap {
+				continue
+			}
+		}
+		parameters = append(parameters, param)
+	}
+	schema.Parameters = parameters
+	return schema
+}
+
+// SplitAllowedHosts returns the presets without the allowed_hosts entry, and
+// the hosts it lists
+func SplitAllowedHosts(presets map[string]interface{}) (map[string]interface{}, []string) {
+	entry, ok := presets[AllowedHostsKey]
+	if !ok {
+		return presets, nil
+	}
+	rest := make(map[string]interface{}, len(presets)-1)
+	for key, value := range presets {
+		if key != AllowedHostsKey {
+			rest[key] = value
+		}
+	}
+	var hosts []string
+	switch v := entry.(type) {
+	case string:
+		hosts = append(hosts, v)
+	case []interface{}:
+		for _, item := range v {
+			if host, ok := item.(string); ok && host != "" {
+				hosts = append(hosts, host)
+			}
+		}
+	case []string:
+		hosts = append(hosts, v...)
+	}
+	return rest, hosts
+}
+
+// HostAllowed reports whether host matches one of the allowed hosts. An entry
+// of the form *.example.com matches the subdomains of example.com.
+func HostAllowed(host string, allowed []string) bool {
+	if host == "" {
+		return false
+	}
+	for _, pattern := range allowed {
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(strings.ToLower(host), "."+strings.ToLower(suffix)) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(host, pattern) {
+			return true
+		}
+	}
+	return false
+}
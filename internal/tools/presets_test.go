@@ -0,0 +1,38 @@
+package tools_test
+
+import (
+	"testing"
+
+	"agent-server/internal/tools"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePresets_HeaderCase(t *testing.T) {
+	merged := tools.MergePresets(map[string]interface{}{
+		"url":     "https://api.example.com",
+		"headers": map[string]interface{}{"authorization": "Bearer stolen", "Accept": "text/plain"},
+	}, map[string]interface{}{
+		"headers": map[string]interface{}{"Authorization": "Bearer s3cret"},
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"url":     "https://api.example.com",
+		"headers": map[string]interface{}{"Authorization": "Bearer s3cret", "Accept": "text/plain"},
+	}, merged)
+}
+
+func TestSplitAllowedHosts(t *testing.T) {
+	presets, hosts := tools.SplitAllowedHosts(map[string]interface{}{
+		"allowed_hosts": []interface{}{"api.example.com", "*.internal.example.com"},
+		"method":        "GET",
+	})
+	assert.Equal(t, map[string]interface{}{"method": "GET"}, presets)
+	assert.Equal(t, []string{"api.example.com", "*.internal.example.com"}, hosts)
+
+	assert.True(t, tools.HostAllowed("API.example.com", hosts))
+	assert.True(t, tools.HostAllowed("docs.internal.example.com", hosts))
+	assert.False(t, tools.HostAllowed("internal.example.com", hosts))
+	assert.False(t, tools.HostAllowed("api.example.com.evil.net", hosts))
+	assert.False(t, tools.HostAllowed("", hosts))
+}
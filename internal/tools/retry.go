@@ -0,0 +1,53 @@
+package tools
+
+import "time"
+
+// DefaultRetryOn lists the error codes retried when a policy names none
+var DefaultRetryOn = []string{"REQUEST_FAILED", "TIMEOUT"}
+
+// RetryPolicy retries a tool whose execution fails with a transient error
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt; values below 2 disable retries
+	MaxAttempts int
+
+	// Backoff is the wait before the first retry, doubled for each further
+	// retry and capped at MaxBackoff when that is set
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// RetryOn lists the error codes worth retrying; empty selects
+	// DefaultRetryOn
+	RetryOn []string
+}
+
+// retries reports whether a failed result should be retried
+func (p RetryPolicy) retries(result *Result) bool {
+	if result == nil || result.Success {
+		return false
+	}
+	codes := p.RetryOn
+	if len(codes) == 0 {
+		codes = DefaultRetryOn
+	}
+	for _, code := range codes {
+		if code == result.ErrorCode {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the wait before the given retry, counting from 1
+func (p RetryPolicy) delay(retry int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < retry && delay > 0; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay
+}
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"regexp"
@@ -208,8 +209,7 @@ func SanitizeInput(schema Schema, input map[string]interface{}) (map[string]inte
 		
 		// Use default value if parameter is missing and has default
 		if !exists && param.Default != nil {
-			sanitized[param.Name] = param.Default
-			continue
+			value, exists = param.Default, true
 		}
 		
 		// Skip missing optional parameters
@@ -242,17 +242,33 @@ func convertValue(expectedType string, value interface{}) (interface{}, error) {
 	
 	switch expectedType {
 	case "string":
-		if str, ok := value.(string); ok {
-			return str, nil
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case float64:
+			// Avoid exponent notation for large JSON numbers
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("cannot convert to string")
 		}
 		return fmt.Sprintf("%v", value), nil
 		
 	case "number":
+		// Numbers are normalized to float64, the type JSON decoding produces,
+		// so tools can rely on a single type
 		switch v := value.(type) {
-		case float64, float32, int, int32, int64:
+		case float64:
 			return v, nil
+		case float32:
+			return float64(v), nil
+		case int:
+			return float64(v), nil
+		case int32:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
 		case string:
-			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
 				return parsed, nil
 			}
 		}
@@ -274,6 +290,18 @@ func convertValue(expectedType string, value interface{}) (interface{}, error) {
 		return nil, fmt.Errorf("cannot convert to boolean")
 		
 	case "object", "array":
+		// LLMs sometimes send nested values as JSON-encoded strings
+		if str, ok := value.(string); ok {
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(str), &decoded); err == nil {
+				if _, isObject := decoded.(map[string]interface{}); isObject && expectedType == "object" {
+					return decoded, nil
+				}
+				if _, isArray := decoded.([]interface{}); isArray && expectedType == "array" {
+					return decoded, nil
+				}
+			}
+		}
 		return value, nil
 		
 	default:
@@ -317,4 +317,27 @@ func TestSanitizeInput(t *testing.T) {
 		assert.Equal(t, 99.5, sanitized["number_param"])
 		assert.Equal(t, false, sanitized["boolean_param"])
 	})
+
+	t.Run("Normalize Nested and Numeric Values", func(t *testing.T) {
+		schema := tools.Schema{
+			Parameters: []tools.Parameter{
+				{Name: "id", Type: "string"},
+				{Name: "timeout", Type: "number", Default: 30},
+				{Name: "headers", Type: "object"},
+				{Name: "tags", Type: "array"},
+			},
+		}
+
+		sanitized, err := tools.SanitizeInput(schema, map[string]interface{}{
+			"id":      1234567890.0,
+			"headers": `{"Accept":"application/json"}`,
+			"tags":    `["a","b"]`,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "1234567890", sanitized["id"])
+		assert.Equal(t, 30.0, sanitized["timeout"])
+		assert.Equal(t, map[string]interface{}{"Accept": "application/json"}, sanitized["headers"])
+		assert.Equal(t, []interface{}{"a", "b"}, sanitized["tags"])
+	})
 }
\ No newline at end of file
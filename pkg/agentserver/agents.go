@@ -0,0 +1,156 @@
+package agentserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"agent-server/internal/llm"
+	"agent-server/internal/models"
+	"agent-server/internal/services"
+	"agent-server/internal/tools"
+)
+
+// Types of the service layer, so that embedding programs can use them
+// without importing internal packages
+type (
+	Agent                = models.Agent
+	CreateAgentRequest   = models.CreateAgentRequest
+	Session              = models.ChatSession
+	CreateSessionRequest = models.CreateSessionRequest
+	Message              = models.Message
+	ChatRequest          = models.EnhancedChatRequest
+	ChatResponse         = models.EnhancedChatResponse
+
+	Provider = llm.Provider
+
+	Tool             = tools.Tool
+	ToolSchema       = tools.Schema
+	ToolParameter    = tools.Parameter
+	ToolResult       = tools.Result
+	ExecutionContext = tools.ExecutionContext
+)
+
+var (
+	// ErrInvalidAgent is returned when an agent to be created fails
+	// validation or enables tools it cannot use
+	ErrInvalidAgent = errors.New("invalid agent")
+
+	// Errors of the chat service, matched with errors.Is
+	ErrAgentNotFound       = services.ErrAgentNotFound
+	ErrAgentDisabled       = services.ErrAgentDisabled
+	ErrSessionNotFound     = services.ErrSessionNotFound
+	ErrSessionArchived     = services.ErrSessionArchived
+	ErrProviderUnavailable = services.ErrProviderUnavailable
+	ErrToolLoopExceeded    = services.ErrToolLoopExceeded
+	ErrBudgetExceeded      = services.ErrBudgetExceeded
+	ErrAgentBusy           = services.ErrAgentBusy
+)
+
+// NewTool creates a tool from its schema and a function that runs it
+func NewTool(schema ToolSchema, execute func(ExecutionContext, map[string]interface{}) *ToolResult) Tool {
+	return tools.NewBaseTool(schema.Name, schema, execute)
+}
+
+// RegisterTool makes a tool available to agents next to the built-in ones
+func (r *Runtime) RegisterTool(tool Tool) error {
+	return r.Tools.GetRegistry().Register(tool)
+}
+
+// RegisterProvider registers an LLM provider under its name, replacing a
+// provider registered under the same name
+func (r *Runtime) RegisterProvider(provider Provider) {
+	r.Providers.Register(provider)
+}
+
+// CreateAgent validates and saves a new agent, as POST /agents does
+func (r *Runtime) CreateAgent(ctx context.Context, req *CreateAgentRequest) (*Agent, error) {
+	if err := r.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAgent, err)
+	}
+
+	agent := req.ToAgent()
+	if errs := r.Status.ValidateTools(ctx, agent); len(errs) > 0 {
+		reasons := make([]string, len(errs))
+		for i, e := range errs {
+			reasons[i] = e.Parameter + ": " + e.Message
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAgent, strings.Join(reasons, "; "))
+	}
+
+	if err := r.Repo.Agent().Create(ctx, agent); err != nil {
+		return nil, fmt.Errorf("failed to create agent: %w", err)
+	}
+	return agent, nil
+}
+
+// GetAgent returns an agent, or nil if it does not exist
+func (r *Runtime) GetAgent(ctx context.Context, id string) (*Agent, error) {
+	return r.Repo.Agent().GetByID(ctx, id)
+}
+
+// ListAgents returns all agents, oldest first
+func (r *Runtime) ListAgents(ctx context.Context) ([]*Agent, error) {
+	agents, _, err := r.Repo.Agent().List(ctx, &models.AgentFilter{Limit: -1})
+	return agents, err
+}
+
+// DeleteAgent deletes an agent
+func (r *Runtime) DeleteAgent(ctx context.Context, id string) error {
+	return r.Repo.Agent().Delete(ctx, id)
+}
+
+// CreateSession starts a session with an agent, as POST
+// /agents/:id/sessions does. req may be nil.
+func (r *Runtime) CreateSession(ctx context.Context, agentID string, req *CreateSessionRequest) (*Session, error) {
+	agent, err := r.Repo.Agent().GetByID(ctx, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent: %w", err)
+	}
+	if agent == nil {
+		return nil, ErrAgentNotFound
+	}
+	if !agent.Enabled {
+		return nil, ErrAgentDisabled
+	}
+
+	if req == nil {
+		req = &CreateSessionRequest{}
+	}
+	if err := r.validator.Struct(req); err != nil {
+		return nil, err
+	}
+
+	session := req.ToSession(agentID)
+	if err := r.Repo.Session().Create(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return session, nil
+}
+
+// GetSession returns a session, or nil if it does not exist
+func (r *Runtime) GetSession(ctx context.Context, id string) (*Session, error) {
+	return r.Repo.Session().GetByID(ctx, id)
+}
+
+// Messages returns up to limit of a session's messages, oldest first
+func (r *Runtime) Messages(ctx context.Context, sessionID string, limit int) ([]*Message, error) {
+	messages, _, err := r.Repo.Message().ListBySessionID(ctx, sessionID, limit, 0)
+	return messages, err
+}
+
+// Send sends a message in a session and runs the agent's tool calls until
+// it answers
+func (r *Runtime) Send(ctx context.Context, sessionID, message string) (*ChatResponse, error) {
+	return r.Chat.ChatWithTools(ctx, &ChatRequest{Message: message}, sessionID)
+}
+
+// ChatWithTools sends a chat request in a session, as POST
+// /sessions/:id/chat does
+func (r *Runtime) ChatWithTools(ctx context.Context, sessionID string, req *ChatRequest) (*ChatResponse, error) {
+	if err := r.validator.Struct(req); err != nil {
+		return nil, err
+	}
+	return r.Chat.ChatWithTools(ctx, req, sessionID)
+}
@@ -0,0 +1,39 @@
+package agentserver
+
+import (
+	"context"
+	"fmt"
+
+	"agent-server/internal/llm/mock"
+)
+
+// demoTools are the tools of the demo agent; they need no network or
+// credentials
+var demoTools = []interface{}{"calculator", "text_processor", "json_processor", "memory"}
+
+// SeedDemo creates the sample agent and session of demo mode. The agent
+// answers with the mock provider, which calls a tool when a message has a
+// line such as `/tool calculator {"expression": "2+2"}`.
+func (r *Runtime) SeedDemo(ctx context.Context) (*Agent, *Session, error) {
+	agent, err := r.CreateAgent(ctx, &CreateAgentRequest{
+		Name:         "Demo Assistant",
+		Description:  "Sample agent of demo mode; replies come from the mock provider",
+		Provider:     mock.Name,
+		Model:        "mock",
+		SystemPrompt: "You are a helpful assistant. Use the calculator for arithmetic and remember what the user tells you about themselves.",
+		Config:       map[string]interface{}{"tools": demoTools},
+		Tags:         []string{"demo"},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create demo agent: %w", err)
+	}
+
+	session, err := r.CreateSession(ctx, agent.ID, &CreateSessionRequest{
+		Title: "Demo session",
+		Tags:  []string{"demo"},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create demo session: %w", err)
+	}
+	return agent, session, nil
+}
@@ -0,0 +1,22 @@
+// Package agentserver runs the agent runtime in-process, without the HTTP
+// server. It wires the same services the server uses: agents, sessions and
+// chats in which models call tools, with the configured storage, providers,
+// quotas, budgets and moderation.
+//
+//	runtime, err := agentserver.Open(cfg)
+//	if err != nil {
+//		return err
+//	}
+//	defer runtime.Close()
+//
+//	runtime.RegisterTool(agentserver.NewTool(agentserver.ToolSchema{
+//		Name:        "lookup_order",
+//		Description: "Looks up the status of a customer order",
+//	}, lookupOrder))
+//	agent, err := runtime.CreateAgent(ctx, &agentserver.CreateAgentRequest{...})
+//	session, err := runtime.CreateSession(ctx, agent.ID, nil)
+//	response, err := runtime.Send(ctx, session.ID, "Where is order A-1?")
+//
+// The services themselves are exported as fields of Runtime for everything
+// the convenience methods do not cover.
+package agentserver
@@ -0,0 +1,22 @@
+// Package httpapi serves the agent server's REST API from an engine owned by
+// the embedding program
+package httpapi
+
+import (
+	"agent-server/internal/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	// Server is the mounted API; it runs the background work with
+	// StartBackground and StopBackground
+	Server = api.Server
+	// MountOptions configure how MountRoutes adds the API to an engine
+	MountOptions = api.MountOptions
+)
+
+// MountRoutes adds the API to engine under prefix. See api.MountRoutes.
+func MountRoutes(engine *gin.Engine, prefix string, opts MountOptions) (*Server, error) {
+	return api.MountRoutes(engine, prefix, opts)
+}
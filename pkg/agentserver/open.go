@@ -0,0 +1,153 @@
+package agentserver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"agent-server/internal/config"
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/egress"
+	"agent-server/internal/llm"
+	"agent-server/internal/llm/mock"
+	"agent-server/internal/llm/ollama"
+	"agent-server/internal/metrics"
+	"agent-server/internal/services"
+	"agent-server/internal/storage"
+	"agent-server/internal/storage/sqlite"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Config is the agent server configuration
+type Config = config.Config
+
+// DefaultConfig returns the configuration the server runs with when no
+// config file or environment variable sets anything
+func DefaultConfig() *Config {
+	return config.Default()
+}
+
+// LoadConfig loads the configuration like the server does: the config file
+// at path (or ./configs/config.yaml), profile overlays and AGENT_SERVER_*
+// environment variables over the defaults
+func LoadConfig(path string) (*Config, error) {
+	return config.Load(path)
+}
+
+// Open opens the configured database and LLM providers and wires the
+// runtime around them. A nil cfg means DefaultConfig. Close releases the
+// database.
+func Open(cfg *Config) (*Runtime, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	llmRegistry, err := NewProviderRegistry(cfg)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := OpenRepository(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime := NewRuntime(cfg, repo, contextpkg.NewStrategyRegistry(), llmRegistry)
+	runtime.ownsRepo = true
+	return runtime, nil
+}
+
+// OpenRepository opens the configured database, creating its directory and
+// encrypting sensitive columns when a key is set. Tool-calling turns
+// interrupted by a previous crash are marked incomplete.
+func OpenRepository(cfg *Config) (storage.Repository, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.Database.Path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	var dbCipher *sqlite.Cipher
+	if keys, err := cfg.Database.Encryption.Keys(); err != nil {
+		return nil, fmt.Errorf("invalid database encryption: %w", err)
+	} else if keys != nil {
+		if dbCipher, err = sqlite.NewCipher(keys...); err != nil {
+			return nil, fmt.Errorf("invalid database encryption: %w", err)
+		}
+	}
+	repo, err := sqlite.NewRepositoryWithOptions(cfg.Database.Path, sqlite.Options{
+		JournalMode:     cfg.Database.JournalMode,
+		BusyTimeout:     time.Duration(cfg.Database.BusyTimeout) * time.Millisecond,
+		Synchronous:     cfg.Database.Synchronous,
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: time.Duration(cfg.Database.ConnMaxLifetime) * time.Second,
+		SerializeWrites: cfg.Database.SerializeWrites,
+		Cipher:          dbCipher,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	// Mark tool-calling turns interrupted by a previous crash as incomplete
+	if recovered, err := services.RecoverIncompleteTurns(context.Background(), repo); err != nil {
+		logrus.Errorf("Failed to recover incomplete turns: %v", err)
+	} else if recovered > 0 {
+		logrus.Warnf("Marked %d interrupted chat turns as incomplete", recovered)
+	}
+	return repo, nil
+}
+
+// NewProviderRegistry registers the configured LLM providers. Providers
+// record request sizes and latencies for /metrics and the message metadata.
+func NewProviderRegistry(cfg *Config) (*llm.Registry, error) {
+	llmRegistry := llm.NewRegistry()
+	llmMetrics := llm.NewMetrics(metrics.Default)
+
+	// Outbound provider traffic follows the egress proxy policy
+	egressPolicy, err := egress.New(cfg.Egress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid egress configuration: %w", err)
+	}
+	if egressPolicy.Forced() {
+		logrus.Info("Forcing all outbound traffic through the egress proxy")
+	}
+
+	// Provider clients pool keep-alive connections; streams have no overall
+	// timeout
+	httpOptions := llm.HTTPOptions{
+		DialTimeout:           time.Duration(cfg.LLM.HTTP.DialTimeout) * time.Second,
+		KeepAlive:             time.Duration(cfg.LLM.HTTP.KeepAlive) * time.Second,
+		TLSHandshakeTimeout:   time.Duration(cfg.LLM.HTTP.TLSHandshakeTimeout) * time.Second,
+		ResponseHeaderTimeout: time.Duration(cfg.LLM.HTTP.ResponseHeaderTimeout) * time.Second,
+		IdleConnTimeout:       time.Duration(cfg.LLM.HTTP.IdleConnTimeout) * time.Second,
+		MaxIdleConnsPerHost:   cfg.LLM.HTTP.MaxIdleConnsPerHost,
+		RequestTimeout:        time.Duration(cfg.LLM.HTTP.RequestTimeout) * time.Second,
+	}
+
+	// Register Ollama provider
+	if providerCfg, exists := cfg.LLM.Providers["ollama"]; exists {
+		ollamaProvider := ollama.NewProvider(providerCfg.BaseURL)
+		ollamaProvider.SetTransport(llm.TuneTransport(egressPolicy.Transport(""), httpOptions))
+		ollamaProvider.SetRequestTimeout(httpOptions.RequestTimeout)
+		llmRegistry.Register(llm.Instrument(ollamaProvider, llmMetrics))
+		logrus.Info("Registered Ollama LLM provider")
+	}
+
+	// Register the mock provider for load tests
+	if cfg.LLM.Mock.Enabled {
+		mockProvider := mock.NewProvider(mock.Options{
+			Latency:     time.Duration(cfg.LLM.Mock.Latency) * time.Millisecond,
+			Jitter:      time.Duration(cfg.LLM.Mock.Jitter) * time.Millisecond,
+			StreamDelay: time.Duration(cfg.LLM.Mock.StreamDelay) * time.Millisecond,
+			ReplyWords:  cfg.LLM.Mock.ReplyWords,
+			ErrorRate:   cfg.LLM.Mock.ErrorRate,
+		})
+		llmRegistry.Register(llm.Instrument(mockProvider, llmMetrics))
+		logrus.Warn("Registered mock LLM provider; agents using it do not call a real model")
+	}
+	return llmRegistry, nil
+}
@@ -0,0 +1,465 @@
+package agentserver
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"agent-server/internal/config"
+	contextpkg "agent-server/internal/context"
+	"agent-server/internal/credentials"
+	"agent-server/internal/egress"
+	"agent-server/internal/injection"
+	"agent-server/internal/jobs"
+	"agent-server/internal/llm"
+	"agent-server/internal/mail"
+	"agent-server/internal/models"
+	"agent-server/internal/moderation"
+	"agent-server/internal/pii"
+	"agent-server/internal/postprocess"
+	"agent-server/internal/redact"
+	"agent-server/internal/redis"
+	"agent-server/internal/sandbox"
+	"agent-server/internal/services"
+	"agent-server/internal/storage"
+	"agent-server/internal/storage/blob"
+	"agent-server/internal/tools"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Runtime is the agent runtime without the HTTP server: storage, the LLM
+// providers and the services for agents, sessions and tool-calling chats.
+// The HTTP server is built on top of it; other programs can embed it with
+// Open or NewRuntime.
+type Runtime struct {
+	Config    *config.Config
+	Repo      storage.Repository
+	Contexts  *contextpkg.StrategyRegistry
+	Providers *llm.Registry
+
+	Tools       *services.ToolService
+	Prompts     *services.PromptService
+	Chat        *services.ChatService
+	ModelPuller *services.ModelPuller
+	Accounting  *services.Accounting
+	Archive     *services.ArchiveService
+	Status      *services.AgentStatusService
+	Alerts      *services.AlertMonitor // nil when alerts are disabled
+	Features    *services.FeatureFlags
+	OAuth2      *credentials.Manager // access tokens of the tools' OAuth2 credentials
+
+	Jobs      *jobs.Runner
+	BlobStore blob.Store // nil when no blob backend is configured
+	Egress    *egress.Policy
+	Mailer    *mail.Sender  // nil when no SMTP host is configured
+	Redis     *redis.Client // nil when no Redis address is configured
+	Redactor  *redact.Redactor
+	Logger    *slog.Logger
+
+	validator *validator.Validate
+	ownsRepo  bool // Close closes the repository Open opened
+
+	mu      sync.Mutex
+	started bool
+}
+
+// NewRuntime wires the service layer from the configuration around an
+// existing repository and registries. Parts of the configuration that are
+// invalid are logged and left disabled, as the server does at startup.
+func NewRuntime(cfg *config.Config, repo storage.Repository, ctxRegistry *contextpkg.StrategyRegistry, llmRegistry *llm.Registry) *Runtime {
+	// Credentials are masked in logs and stored tool data
+	redactor, err := redact.NewFromConfig(cfg.Redaction)
+	if err != nil {
+		logrus.WithError(err).Error("Invalid redaction configuration, using default patterns")
+		redactor = redact.Default()
+	}
+
+	// Initialize logger
+	logger := slog.New(redact.NewSlogHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}), redactor))
+
+	// Initialize tool service
+	toolService := services.NewToolService(repo, logger)
+	toolService.SetHTTPCapture(cfg.Tools.Audit.CaptureHTTP)
+	toolService.SetRedactor(redactor)
+
+	perToolTimeouts := make(map[string]time.Duration, len(cfg.Tools.Timeouts.Tools))
+	for name, seconds := range cfg.Tools.Timeouts.Tools {
+		perToolTimeouts[name] = time.Duration(seconds) * time.Second
+	}
+	toolService.SetTimeouts(
+		time.Duration(cfg.Tools.Timeouts.Default)*time.Second,
+		time.Duration(cfg.Tools.Timeouts.Max)*time.Second,
+		perToolTimeouts,
+	)
+
+	retryPolicies := make(map[string]tools.RetryPolicy, len(cfg.Tools.Retries))
+	for name, retry := range cfg.Tools.Retries {
+		retryPolicies[name] = tools.RetryPolicy{
+			MaxAttempts: retry.MaxAttempts,
+			Backoff:     time.Duration(retry.Backoff) * time.Millisecond,
+			MaxBackoff:  time.Duration(retry.MaxBackoff) * time.Millisecond,
+			RetryOn:     retry.RetryOn,
+		}
+	}
+	toolService.SetRetryPolicies(retryPolicies)
+	toolService.SetStrictOutput(cfg.Tools.StrictOutput)
+	toolService.SetCredentials(cfg.Tools.Credentials)
+
+	quotas := services.ToolQuotas{
+		ToolQuota: services.ToolQuota{
+			PerTurn:     cfg.Tools.Quotas.PerTurn,
+			PerSession:  cfg.Tools.Quotas.PerSession,
+			PerAgentDay: cfg.Tools.Quotas.PerAgentDay,
+		},
+		Tools: make(map[string]services.ToolQuota, len(cfg.Tools.Quotas.Tools)),
+	}
+	for name, quota := range cfg.Tools.Quotas.Tools {
+		quotas.Tools[name] = services.ToolQuota{
+			PerTurn:     quota.PerTurn,
+			PerSession:  quota.PerSession,
+			PerAgentDay: quota.PerAgentDay,
+		}
+	}
+	toolService.SetQuotas(quotas)
+
+	// Paid tools and model tokens are priced and capped by the budgets
+	accounting := services.NewAccounting(repo)
+	modelPrices := make(map[string]services.ModelPrice, len(cfg.Pricing.Models))
+	for model, price := range cfg.Pricing.Models {
+		modelPrices[model] = services.ModelPrice{
+			Prompt:     price.Prompt,
+			Completion: price.Completion,
+		}
+	}
+	accounting.SetModelPrices(modelPrices)
+	accounting.SetToolCosts(cfg.Pricing.Tools)
+	accounting.SetBudget(services.Budget{
+		PerSession:  cfg.Pricing.Budgets.PerSession,
+		PerAgentDay: cfg.Pricing.Budgets.PerAgentDay,
+	})
+	toolService.SetAccounting(accounting)
+
+	// Route outbound tool and webhook traffic through the egress proxies
+	egressPolicy, err := egress.New(cfg.Egress)
+	if err != nil {
+		logger.Error("Invalid egress configuration, using proxy environment variables", "error", err)
+		egressPolicy, _ = egress.New(config.EgressConfig{})
+	}
+	toolService.SetEgress(egressPolicy)
+
+	// Credentials of tool presets stay with the origin they were sent to.
+	// Embedded configs built without the defaults leave max_redirects 0.
+	maxRedirects := cfg.Tools.HTTP.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = tools.DefaultMaxRedirects
+	}
+	toolService.SetRedirectPolicy(tools.RedirectPolicy{
+		MaxRedirects:           maxRedirects,
+		CrossOriginCredentials: cfg.Tools.HTTP.CrossOriginCredentials,
+	})
+
+	// OAuth2 tokens for tool presets, requested through the egress proxies
+	oauth2 := newOAuth2Credentials(cfg, egressPolicy)
+	toolService.SetOAuth2(oauth2)
+
+	// Outgoing email for the send_email tool and the email channel
+	var mailer *mail.Sender
+	if cfg.Tools.Email.Host != "" {
+		mailer = mail.NewSender(cfg.Tools.Email)
+		if err := toolService.SetMailer(mailer, cfg.Tools.Email.AllowedRecipients); err != nil {
+			logger.Error("Failed to enable email sending", "error", err)
+		}
+	}
+
+	// Initialize blob storage; it stays disabled when no backend is configured
+	var blobStore blob.Store
+	if cfg.Storage.Blob.Backend != "" {
+		store, err := blob.NewFromConfig(cfg.Storage.Blob)
+		if err != nil {
+			logger.Error("Failed to initialize blob storage", "backend", cfg.Storage.Blob.Backend, "error", err)
+		} else {
+			blobStore = store
+			expiry := time.Duration(cfg.Storage.Blob.SignedURLExpiry) * time.Second
+			toolService.SetBlobStore(store, cfg.Storage.Blob.ToolOutputThreshold, expiry)
+			logger.Info("Blob storage initialized", "backend", store.Name())
+		}
+	}
+
+	// Code execution in containers for the run_code tool; generated files
+	// are kept in blob storage
+	if cfg.Tools.Sandbox.Enabled {
+		if blobStore == nil {
+			logger.Warn("Blob storage is disabled, run_code will not keep generated files")
+		}
+		runner, err := sandbox.New(cfg.Tools.Sandbox)
+		if err == nil {
+			err = toolService.SetSandbox(runner, blobStore, time.Duration(cfg.Storage.Blob.SignedURLExpiry)*time.Second)
+		}
+		if err != nil {
+			logger.Error("Failed to enable the code sandbox", "error", err)
+		}
+	}
+
+	// One Redis client serves the queue, the event bus and job leader
+	// election
+	var redisClient *redis.Client
+	if cfg.Redis.Address != "" {
+		redisClient = redis.New(redis.Options{
+			Address:  cfg.Redis.Address,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+	}
+
+	// Initialize background job runner
+	jobRunner := jobs.NewRunner(repo.Job(), jobs.Options{
+		Workers:      cfg.Jobs.Workers,
+		PollInterval: time.Duration(cfg.Jobs.PollInterval) * time.Millisecond,
+		BackoffBase:  time.Duration(cfg.Jobs.BackoffBase) * time.Second,
+		BackoffMax:   time.Duration(cfg.Jobs.BackoffMax) * time.Second,
+		JobTimeout:   time.Duration(cfg.Jobs.JobTimeout) * time.Second,
+
+		SweepInterval: time.Duration(cfg.Jobs.SweepInterval) * time.Second,
+	}, logger)
+	if cfg.Jobs.LeaderElection {
+		// The lease outlives two missed sweeps
+		jobRunner.SetElector(jobs.NewRedisLease(redisClient, cfg.Jobs.LeaderKey, uuid.New().String(),
+			3*time.Duration(cfg.Jobs.SweepInterval)*time.Second))
+	}
+	jobRunner.Register(jobs.JobTypeWebhook, jobs.NewWebhookHandler(&http.Client{
+		Timeout:   30 * time.Second,
+		Transport: egressPolicy.Transport(""),
+	}))
+
+	// Initialize session archival
+	archiveService := services.NewArchiveService(repo, logger)
+	if blobStore != nil {
+		archiveService.SetBlobStore(blobStore, cfg.Storage.Archive.BlobThreshold)
+	}
+	jobRunner.Register(services.JobTypeArchiveSession, archiveService.HandleArchiveJob)
+	jobRunner.Register(services.JobTypeCompactSession, archiveService.HandleCompactJob)
+
+	// Long-running tools run in the background when the job runner is enabled
+	if len(cfg.Tools.Async.Tools) > 0 {
+		if !cfg.Jobs.Enabled {
+			logger.Warn("Async tools require jobs.enabled, running them synchronously", "tools", cfg.Tools.Async.Tools)
+		} else if err := toolService.SetAsync(jobRunner, cfg.Tools.Async.Tools); err != nil {
+			logger.Error("Failed to enable async tools", "error", err)
+		}
+	}
+
+	// Calls of these tools wait for a person's approval
+	if len(cfg.Tools.Approval.Tools) > 0 {
+		if err := toolService.SetApproval(cfg.Tools.Approval.Tools); err != nil {
+			logger.Error("Failed to enable tool approval", "error", err)
+		}
+	}
+
+	// Tool results are sanitized, framed and classified before the LLM reads
+	// them
+	classifier, err := injection.NewFromConfig(cfg.Tools.Injection.Classifier)
+	if err != nil {
+		logger.Error("Invalid injection classifier configuration, classification disabled", "error", err)
+	}
+	toolService.SetInjectionDefense(
+		cfg.Tools.Injection.Sanitize,
+		cfg.Tools.Injection.Frame,
+		classifier,
+		cfg.Tools.Injection.Classifier.Threshold,
+		cfg.Tools.Injection.Classifier.Action,
+	)
+
+	// Tools turned off for every agent; the admin API changes them at runtime
+	toolService.SetDisabledTools(cfg.Tools.Disabled, time.Duration(cfg.Features.RefreshInterval)*time.Second)
+
+	// Initialize prompt service
+	promptService := services.NewPromptService(toolService)
+
+	// Initialize unified chat service with tool support
+	chatService := services.NewChatService(repo, llmRegistry, ctxRegistry, toolService, promptService, logger)
+	chatService.SetRedactor(redactor)
+	chatService.SetAccounting(accounting)
+	chatService.SetMaxResponseLength(cfg.LLM.MaxResponseLength)
+	if pipeline, err := postprocess.New(cfg.LLM.PostProcess); err != nil {
+		logger.Error("Invalid post-processing configuration, replies are not post-processed", "error", err)
+	} else {
+		chatService.SetPostProcessing(pipeline)
+	}
+	modelPuller := services.NewModelPuller(llmRegistry, logger)
+	chatService.SetModelPuller(modelPuller, cfg.LLM.AutoPull)
+	chatService.SetContextWindows(services.NewContextWindows(llmRegistry, cfg.LLM.ContextWindows))
+	chatService.SetContextTracing(cfg.Context.Trace)
+	features := newFeatureFlags(cfg, repo, logger)
+	chatService.SetFeatureFlags(features)
+	chatService.SetConcurrencyLimits(services.ConcurrencyLimits{
+		DefaultLimit: cfg.LLM.Concurrency.DefaultLimit,
+		QueueTimeout: time.Duration(cfg.LLM.Concurrency.QueueTimeout) * time.Second,
+		MaxQueue:     cfg.LLM.Concurrency.MaxQueue,
+	})
+	chatService.SetModeration(
+		moderation.NewFromConfig(cfg.Moderation, egressPolicy.Transport("")),
+		moderation.PolicyFromConfig(cfg.Moderation),
+	)
+
+	// Personal data is scrubbed from what agents with a data-handling policy store
+	piiScrubber := pii.NewFromConfig(cfg.PII, egressPolicy.Transport(""))
+	chatService.SetPIIScrubber(piiScrubber)
+	toolService.SetPIIScrubber(piiScrubber)
+
+	// Compaction summarizes with the session's agent
+	archiveService.SetSummarizer(chatService.Summarize)
+
+	// Initialize agent status reporting
+	statusService := services.NewAgentStatusService(repo, llmRegistry, toolService, chatService, logger)
+
+	// Alert rules are checked in the background and notified by webhook and
+	// email
+	var alertMonitor *services.AlertMonitor
+	if cfg.Alerts.Enabled {
+		alertMonitor = newAlertMonitor(cfg, repo, llmRegistry, jobRunner, mailer, logger)
+	}
+
+	return &Runtime{
+		Config:      cfg,
+		Repo:        repo,
+		Contexts:    ctxRegistry,
+		Providers:   llmRegistry,
+		Tools:       toolService,
+		Prompts:     promptService,
+		Chat:        chatService,
+		ModelPuller: modelPuller,
+		Accounting:  accounting,
+		Archive:     archiveService,
+		Status:      statusService,
+		Alerts:      alertMonitor,
+		Features:    features,
+		OAuth2:      oauth2,
+		Jobs:        jobRunner,
+		BlobStore:   blobStore,
+		Egress:      egressPolicy,
+		Mailer:      mailer,
+		Redis:       redisClient,
+		Redactor:    redactor,
+		Logger:      logger,
+		validator:   validator.New(),
+	}
+}
+
+// newFeatureFlags creates the feature flags with the configured ones as
+// defaults; flags set at runtime are loaded on first use
+func newFeatureFlags(cfg *config.Config, repo storage.Repository, logger *slog.Logger) *services.FeatureFlags {
+	defaults := make([]*models.FeatureFlag, len(cfg.Features.Flags))
+	for i, flag := range cfg.Features.Flags {
+		defaults[i] = &models.FeatureFlag{
+			Name:        flag.Name,
+			Description: flag.Description,
+			Enabled:     flag.Enabled,
+			Agents:      models.StringList(flag.Agents),
+			Rollout:     flag.Rollout,
+			Tools:       models.StringList(flag.Tools),
+		}
+	}
+	return services.NewFeatureFlags(repo, defaults, time.Duration(cfg.Features.RefreshInterval)*time.Second, logger)
+}
+
+// newOAuth2Credentials creates the manager of the configured OAuth2 clients
+func newOAuth2Credentials(cfg *config.Config, egressPolicy *egress.Policy) *credentials.Manager {
+	clients := make(map[string]credentials.OAuth2Client, len(cfg.Tools.OAuth2))
+	for name, client := range cfg.Tools.OAuth2 {
+		clients[name] = credentials.OAuth2Client{
+			TokenURL:     client.TokenURL,
+			ClientID:     client.ClientID,
+			ClientSecret: client.ClientSecret,
+			Scopes:       client.Scopes,
+			Audience:     client.Audience,
+			RefreshToken: client.RefreshToken,
+			AuthStyle:    client.AuthStyle,
+		}
+	}
+	return credentials.NewManager(clients, &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: egressPolicy.Transport(""),
+	})
+}
+
+// newAlertMonitor creates the monitor of the configured alert rules
+func newAlertMonitor(cfg *config.Config, repo storage.Repository, llmRegistry *llm.Registry, jobRunner *jobs.Runner, mailer *mail.Sender, logger *slog.Logger) *services.AlertMonitor {
+	rules := make([]services.AlertRule, len(cfg.Alerts.Rules))
+	for i, rule := range cfg.Alerts.Rules {
+		rules[i] = services.AlertRule{
+			Name:      rule.Name,
+			Type:      rule.Type,
+			Threshold: rule.Threshold,
+			Window:    time.Duration(rule.Window) * time.Second,
+			MinEvents: rule.MinEvents,
+			Provider:  rule.Provider,
+			Tool:      rule.Tool,
+			AgentID:   rule.AgentID,
+		}
+	}
+	monitor := services.NewAlertMonitor(repo, llmRegistry, rules, time.Duration(cfg.Alerts.Interval)*time.Second, logger)
+
+	if len(cfg.Alerts.Webhooks) > 0 && !cfg.Jobs.Enabled {
+		logger.Warn("Alert webhooks require jobs.enabled, they are queued but not delivered")
+	}
+	for _, webhook := range cfg.Alerts.Webhooks {
+		monitor.AddNotifier(services.WebhookAlertNotifier(jobRunner, webhook.URL, webhook.Secret))
+	}
+	if len(cfg.Alerts.Email) > 0 && mailer != nil {
+		monitor.AddNotifier(services.EmailAlertNotifier(mailer, cfg.Alerts.Email))
+	}
+	if cfg.Jobs.LeaderElection {
+		monitor.SetLeader(jobRunner.Leader)
+	}
+	return monitor
+}
+
+// Start starts the background job runner when jobs are enabled and the
+// alert monitor when alerts are. Runtimes that only chat need not be
+// started.
+func (r *Runtime) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		return
+	}
+	if r.Config.Jobs.Enabled {
+		r.Jobs.Start(ctx)
+	}
+	if r.Alerts != nil {
+		r.Alerts.Start(ctx)
+	}
+	r.started = true
+}
+
+// Stop stops the background work Start started
+func (r *Runtime) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		if r.Config.Jobs.Enabled {
+			r.Jobs.Stop()
+		}
+		if r.Alerts != nil {
+			r.Alerts.Stop()
+		}
+		r.started = false
+	}
+}
+
+// Close stops the background work and closes the repository if Open opened it
+func (r *Runtime) Close() error {
+	r.Stop()
+	if r.ownsRepo {
+		return r.Repo.Close()
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package agentserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntime_Embedded(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Database.Path = filepath.Join(t.TempDir(), "agents.db")
+	cfg.LLM.Mock.Enabled = true
+	cfg.LLM.Mock.Latency = 0
+	cfg.LLM.Mock.Jitter = 0
+
+	runtime, err := Open(cfg)
+	require.NoError(t, err)
+	defer runtime.Close()
+	ctx := context.Background()
+
+	// A tool registered by the embedding program is offered like a built-in one
+	var calls int
+	require.NoError(t, runtime.RegisterTool(NewTool(ToolSchema{
+		Name:        "lookup_order",
+		Description: "Looks up the status of a customer order",
+		Parameters: []ToolParameter{
+			{Name: "order_id", Type: "string", Description: "The order number", Required: true},
+		},
+	}, func(_ ExecutionContext, input map[string]interface{}) *ToolResult {
+		calls++
+		return &ToolResult{Success: true, Data: map[string]interface{}{"order_id": input["order_id"], "status": "shipped"}}
+	})))
+
+	agent, err := runtime.CreateAgent(ctx, &CreateAgentRequest{
+		Name:         "Support",
+		Provider:     "mock",
+		Model:        "mock",
+		SystemPrompt: "You answer questions about orders.",
+		Config:       map[string]interface{}{"tools": []string{"lookup_order"}},
+	})
+	require.NoError(t, err)
+
+	session, err := runtime.CreateSession(ctx, agent.ID, nil)
+	require.NoError(t, err)
+
+	response, err := runtime.Send(ctx, session.ID, "Where is my order?\n/tool lookup_order {\"order_id\": \"A-1\"}")
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	require.Len(t, response.ToolCalls, 1)
+	assert.Equal(t, "lookup_order", response.ToolCalls[0].ToolName)
+	assert.Contains(t, response.Response, "shipped")
+
+	messages, err := runtime.Messages(ctx, session.ID, 10)
+	require.NoError(t, err)
+	assert.NotEmpty(t, messages)
+
+	agents, err := runtime.ListAgents(ctx)
+	require.NoError(t, err)
+	assert.Len(t, agents, 1)
+
+	// Agents are validated as the API validates them
+	_, err = runtime.CreateAgent(ctx, &CreateAgentRequest{
+		Name:         "Broken",
+		Provider:     "mock",
+		Model:        "mock",
+		SystemPrompt: "You help.",
+		Config:       map[string]interface{}{"tools": []string{"no_such_tool"}},
+	})
+	assert.True(t, errors.Is(err, ErrInvalidAgent))
+
+	// Agents can be created disabled
+	disabled := false
+	paused, err := runtime.CreateAgent(ctx, &CreateAgentRequest{
+		Name:         "Paused",
+		Provider:     "mock",
+		Model:        "mock",
+		SystemPrompt: "You help.",
+		Enabled:      &disabled,
+	})
+	require.NoError(t, err)
+	stored, err := runtime.GetAgent(ctx, paused.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.Enabled)
+
+	_, err = runtime.CreateSession(ctx, "missing", nil)
+	assert.True(t, errors.Is(err, ErrAgentNotFound))
+	_, err = runtime.Send(ctx, "missing", "hello")
+	assert.True(t, errors.Is(err, ErrSessionNotFound))
+}
+
+func TestRuntime_DefaultMaxRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "/landing", http.StatusFound)
+			return
+		}
+		w.Write([]byte("landed"))
+	}))
+	defer server.Close()
+
+	// An embedded config without the defaults leaves max_redirects at 0
+	cfg := DefaultConfig()
+	cfg.Database.Path = filepath.Join(t.TempDir(), "agents.db")
+	cfg.Tools.HTTP.MaxRedirects = 0
+
+	runtime, err := Open(cfg)
+	require.NoError(t, err)
+	defer runtime.Close()
+
+	result := runtime.Tools.GetExecutor().Execute(context.Background(), "http_get", "session", map[string]interface{}{"url": server.URL + "/moved"})
+	require.True(t, result.Success, result.Error)
+	data := result.Data.(map[string]interface{})
+	assert.EqualValues(t, http.StatusOK, data["status_code"])
+}
+
+func TestRuntime_SeedDemo(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Database.Path = ":memory:"
+	cfg.LLM.Mock.Enabled = true
+	cfg.LLM.Mock.Latency = 0
+	cfg.LLM.Mock.Jitter = 0
+
+	runtime, err := Open(cfg)
+	require.NoError(t, err)
+	defer runtime.Close()
+	ctx := context.Background()
+
+	agent, session, err := runtime.SeedDemo(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Demo Assistant", agent.Name)
+	assert.Equal(t, agent.ID, session.AgentID)
+
+	response, err := runtime.Send(ctx, session.ID, "What is 6 times 7?\n/tool calculator {\"expression\": \"6*7\"}")
+	require.NoError(t, err)
+	require.Len(t, response.ToolCalls, 1)
+	assert.Equal(t, "calculator", response.ToolCalls[0].ToolName)
+	assert.Contains(t, response.Response, "42")
+}
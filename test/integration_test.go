@@ -277,6 +277,39 @@ func (suite *IntegrationTestSuite) TestHealthCheck() {
 	assert.Equal(suite.T(), "ok", response["status"])
 }
 
+func (suite *IntegrationTestSuite) TestProbes() {
+	router := suite.server.GetRouter()
+
+	req := httptest.NewRequest("GET", "/livez", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("GET", "/readyz", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response struct {
+		Status     string `json:"status"`
+		Components map[string]struct {
+			Status string `json:"status"`
+		} `json:"components"`
+	}
+	require.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+
+	assert.Equal(suite.T(), "ok", response.Components["database"].Status)
+	assert.Equal(suite.T(), "ok", response.Components["migrations"].Status)
+	assert.Equal(suite.T(), "disabled", response.Components["jobs"].Status)
+
+	// Readiness follows provider reachability; no Ollama runs during tests
+	if response.Components["providers"].Status == "ok" {
+		assert.Equal(suite.T(), http.StatusOK, w.Code)
+	} else {
+		assert.Equal(suite.T(), http.StatusServiceUnavailable, w.Code)
+		assert.Equal(suite.T(), "failing", response.Status)
+	}
+}
+
 func (suite *IntegrationTestSuite) TestContextStrategies() {
 	router := suite.server.GetRouter()
 